@@ -1,9 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,21 +19,245 @@ type Config struct {
 	NetsapiensSecret   string
 
 	// Application Configuration
-	AppEnv       string
-	AppPort      string
+	AppEnv        string
+	AppPort       string
 	SessionSecret string
 
 	// Database Configuration
-	DatabasePath string
+	DatabasePath            string
+	DatabaseJournalMode     string // WAL lets reports read while searches write
+	DatabaseBusyTimeout     time.Duration
+	DatabaseSynchronous     string
+	DatabaseMaxOpenConns    int
+	DatabaseMaxIdleConns    int
+	DatabaseConnMaxLifetime time.Duration
+	StoreSearches           bool // keep completed searches and their CDR summaries for reports
+
+	// Database maintenance (ANALYZE, VACUUM and growth snapshots)
+	DatabaseMaintenanceInterval time.Duration // 0 disables scheduled maintenance
+	DatabaseVacuum              bool
+	WREventRetention            time.Duration // Web Responder events older than this are pruned; 0 keeps them
+
+	// Scheduled searches (run with the NetSapiens credentials above)
+	SearchSchedulerInterval time.Duration // how often due searches are looked for; 0 disables them
+
+	// Alert rules (sent to the keyword alert channels)
+	AlertRulesInterval time.Duration // how often analytics rules are checked; 0 only on request
+
+	// Daily digest (emailed through the SMTP server of the alert emails)
+	DigestEmailTo string // comma-separated addresses; empty disables the digest
+	DigestHour    int    // UTC hour after which the previous day's digest is sent
+
+	// PII Masking Configuration
+	PIIMaskingMode       string // none, truncate, hash
+	PIIMaskingRoles      string // e.g. "viewer:hash,analyst:truncate"
+	PIIMaskingRoleTokens string // e.g. "analyst:<token>"; a request's role is the one its X-Role-Token names
+	PIIHashSalt          string
+
+	// Admin Configuration
+	AdminToken string
+
+	// Web Responder Dashboard Configuration
+	DashboardToken          string // defaults to ADMIN_TOKEN
+	DashboardAllowedOrigins string // comma-separated WebSocket origins; "" allows same-origin only, "*" allows any
+
+	// Event Backplane Configuration (shares dashboard events between instances)
+	EventsRedisURL     string // e.g. "redis://:password@redis:6379"; "" keeps events in-process
+	EventsRedisChannel string
+
+	// PBX Call Events (NetSapiens event subscription for calls outside the Web Responder)
+	PBXEventsDomain      string
+	PBXEventsCallbackURL string // public URL of this server NetSapiens posts notifications to
+	PBXEventsSecret      string // token notifications must carry; "" disables PBX events
+
+	// Recording Archive (copies call recordings out of NetSapiens)
+	ArchiveStorage        string // none, local, s3
+	ArchiveDir            string
+	ArchiveS3Bucket       string
+	ArchiveS3Prefix       string
+	ArchiveS3Region       string
+	ArchiveS3Endpoint     string        // S3-compatible endpoint; "" uses AWS
+	ArchiveRetention      time.Duration // archived recordings are deleted after this; 0 keeps them
+	ArchiveRetentionRules string        // per-domain retention, e.g. "acme.example.com:720h"
+	AWSAccessKeyID        string
+	AWSSecretAccessKey    string
+	AWSSessionToken       string
+
+	// Elasticsearch/OpenSearch sink (indexes session CDRs into a cluster)
+	ElasticsearchURL           string
+	ElasticsearchIndex         string // index name template, e.g. "cdrs-{yyyy}.{MM}.{dd}"
+	ElasticsearchUsername      string
+	ElasticsearchPassword      string
+	ElasticsearchAPIKey        string // used instead of basic auth when set
+	ElasticsearchView          string // raw or normalized documents
+	ElasticsearchMasking       string // none, truncate or hash
+	ElasticsearchBatchSize     int
+	ElasticsearchIndexSearches bool // index every search as it completes
+
+	// ClickHouse (keeps searched and imported CDRs for analytics)
+	ClickHouseURL           string
+	ClickHouseDatabase      string
+	ClickHouseTable         string
+	ClickHouseUsername      string
+	ClickHousePassword      string
+	ClickHouseBatchSize     int
+	ClickHouseRetentionDays int  // 0 keeps rows
+	ClickHouseWriteSearches bool // write every search as it completes
+
+	// BigQuery (scheduled searches with "exports": ["bigquery"] load their CDRs)
+	BigQueryCredentials string // service account key file or JSON
+	BigQueryProject     string
+	BigQueryDataset     string
+	BigQueryTable       string // table name template, e.g. "cdrs_{yyyy}{MM}{dd}"
+	BigQueryLocation    string
+
+	// Parquet Export (hive-partitioned Parquet files of sessions and stored CDRs)
+	ParquetStorage    string // none, local, s3
+	ParquetDir        string
+	ParquetS3Bucket   string
+	ParquetS3Prefix   string
+	ParquetS3Region   string
+	ParquetS3Endpoint string // S3-compatible endpoint; "" uses AWS
+
+	// Recording Transcription (speech-to-text of archived recordings)
+	TranscriptionBackend  string // none, whisper, deepgram
+	TranscriptionURL      string // endpoint override, e.g. a local Whisper server
+	TranscriptionAPIKey   string
+	TranscriptionModel    string
+	TranscriptionLanguage string // "" detects the language
+	TranscriptionInterval time.Duration
+	SentimentAnalyzer     string // none, lexicon, http
+	SentimentURL          string // endpoint of the http analyzer
+	SentimentAPIKey       string
+
+	// Keyword Alerts (sent when transcripts mention watched phrases)
+	AlertWebhookURL           string
+	AlertSlackWebhookURL      string
+	AlertSlackBotToken        string // posts with chat.postMessage instead of the webhook
+	AlertSlackChannel         string // required with the bot token
+	AlertSlackSearchSummaries bool   // post every scheduled search run to Slack, not only drift
+	AlertTeamsWebhookURL      string
+	AlertTeamsSearchSummaries bool   // post every scheduled search run to Teams, not only drift
+	AlertPagerDutyRoutingKey  string // Events API v2 integration key for alert rules with the pagerduty action
+	AlertEmailTo              string // comma-separated addresses
+	AlertEmailFrom            string
+	AlertLinkBaseURL          string // public URL of this server for links in alerts
+	SMTPHost                  string
+	SMTPPort                  int
+	SMTPUsername              string
+	SMTPPassword              string
+
+	// Carrier/LRN and CNAM Enrichment (Twilio reuses the SMS credentials)
+	EnrichmentProvider   string // none, telnyx, twilio
+	CNAMProvider         string // none, telnyx, twilio
+	TelnyxAPIKey         string
+	EnrichmentCacheTTL   time.Duration
+	EnrichmentMaxLookups int // new numbers looked up per search
+
+	// Fraud Detection (alerts go to the keyword alert channels)
+	FraudScanSearches    bool
+	FraudBurstCalls      int
+	FraudBurstWindow     time.Duration
+	FraudSequentialCalls int
+	FraudMinutesFactor   float64
+	FraudMinMinutes      int
+
+	// Spam Scoring of inbound calls
+	SpamScoreSearches bool
+	SpamRepeatCalls   int
+	SpamThreshold     int
+
+	// Telephony KPIs (ASR, ACD, NER) over search results
+	KPIRecordSearches bool
+	KPITrunkFields    string // comma-separated CDR fields naming a call's trunk or route
+
+	// Billing reconciliation of carrier invoices against the CDRs
+	BillingTimeTolerance     time.Duration
+	BillingDurationTolerance time.Duration
+	BillingIncludeInbound    bool
+
+	// Weather and Air Quality Configuration
+	AQIProvider       string // simulated, airnow, openaq
+	AQIAPIKey         string
+	AQICacheTTL       time.Duration
+	WeatherCacheTTL   time.Duration
+	GeoCachePrecision int // decimal places lat/lon are rounded to for cache keys
+
+	// SMS Follow-up Configuration
+	SMSProvider         string // none, log, netsapiens, twilio
+	SMSFromNumber       string
+	SMSNetsapiensDomain string
+	SMSNetsapiensUser   string
+	TwilioAccountSID    string
+	TwilioAuthToken     string
+
+	// IVR Configuration
+	IVRSessionStore string // memory, sqlite, cookie
+	IVRSessionTTL   time.Duration
+	IVRFlowsDir     string
+	IVRMenu         string // e.g. "1:weather,2:cdr-lookup:your recent calls"
+	IVROperator     string // e.g. "queue:support", "ext:100" or "+14155551234"; "" disables press 0
+
+	// IVR Queue Status Line
+	IVRQueueDomain     string
+	IVRQueue           string        // call queue read back at /wr/queue-status
+	IVRQueueHandleTime time.Duration // average call length, for wait estimates
+
+	// IVR Voice Configuration (reloadable without restart)
+	IVRVoice        string
+	IVRLanguage     string
+	IVRSpeakingRate string
+	IVRPromptsFile  string
+	IVRAudioBaseURL string // public URL NetSapiens fetches recordings from, e.g. "https://ivr.example.com"
+
+	// IVR Speech Input (reloadable without restart)
+	IVRSpeechEnabled    bool
+	IVRSpeechConfidence float64 // minimum recognizer confidence (0-1) to accept a SpeechResult
+
+	// Runtime Configuration (reloadable without restart)
+	ResultsTTL    time.Duration
+	DebugLogging  bool
+	AreaCodesFile string // CSV or JSON area code database; "" uses the copy built into the binary
+	ZIPCodesFile  string // CSV ZIP code database; "" uses the sample built into the binary
+
+	// Call Classification as answered, abandoned or missed (reloadable without restart)
+	CallMinAnsweredSeconds int    // connected calls shorter than this are abandoned
+	CallAbandonedReasons   string // comma-separated disconnect reasons meaning the caller hung up first
+	CallMissedReasons      string // comma-separated disconnect reasons meaning nobody answered
 }
 
+// processEnvKeys records variables set by the process environment before .env was applied,
+// so reloads keep the same precedence as startup
+var processEnvKeys = make(map[string]bool)
+
 // LoadConfig loads configuration from environment variables and .env file
 func LoadConfig() *Config {
+	for _, entry := range os.Environ() {
+		if key, _, ok := strings.Cut(entry, "="); ok {
+			processEnvKeys[key] = true
+		}
+	}
+
 	// Load .env file if it exists (for local development)
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using system environment variables")
 	}
 
+	config, err := buildConfig()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Remove the validation since tokens come from users now
+	// if config.NetsapiensToken == "" {
+	//     log.Fatal("NETSAPIENS_ACCESS_TOKEN is required but not set")
+	// }
+
+	return config
+}
+
+// buildConfig reads the current environment into a Config
+func buildConfig() (*Config, error) {
 	config := &Config{
 		// NetSapiens Configuration
 		NetsapiensBaseURL:  getEnv("NETSAPIENS_BASE_URL", "https://ns-api.com"),
@@ -44,15 +271,256 @@ func LoadConfig() *Config {
 		SessionSecret: getEnv("SESSION_SECRET", "default-secret-change-in-production"),
 
 		// Database Configuration
-		DatabasePath: getEnv("DATABASE_PATH", "./data/odango.db"),
+		DatabasePath:            getEnv("DATABASE_PATH", "./data/odango.db"),
+		DatabaseJournalMode:     getEnv("DATABASE_JOURNAL_MODE", "WAL"),
+		DatabaseBusyTimeout:     getEnvAsDuration("DATABASE_BUSY_TIMEOUT", 5*time.Second),
+		DatabaseSynchronous:     getEnv("DATABASE_SYNCHRONOUS", "NORMAL"),
+		DatabaseMaxOpenConns:    getEnvAsInt("DATABASE_MAX_OPEN_CONNS", 0),
+		DatabaseMaxIdleConns:    getEnvAsInt("DATABASE_MAX_IDLE_CONNS", 2),
+		DatabaseConnMaxLifetime: getEnvAsDuration("DATABASE_CONN_MAX_LIFETIME", 0),
+		StoreSearches:           getEnvAsBool("STORE_SEARCHES", true),
+
+		// Database maintenance
+		DatabaseMaintenanceInterval: getEnvAsDuration("DATABASE_MAINTENANCE_INTERVAL", 24*time.Hour),
+		DatabaseVacuum:              getEnvAsBool("DATABASE_VACUUM", true),
+		WREventRetention:            getEnvAsDuration("WR_EVENT_RETENTION", 90*24*time.Hour),
+
+		// Scheduled searches
+		SearchSchedulerInterval: getEnvAsDuration("SEARCH_SCHEDULER_INTERVAL", time.Minute),
+
+		// Alert rules
+		AlertRulesInterval: getEnvAsDuration("ALERT_RULES_INTERVAL", 15*time.Minute),
+
+		// Daily digest
+		DigestEmailTo: getEnv("DIGEST_EMAIL_TO", ""),
+		DigestHour:    getEnvAsInt("DIGEST_HOUR", 7),
+
+		// PII Masking Configuration
+		PIIMaskingMode:       getEnv("PII_MASKING_MODE", "none"),
+		PIIMaskingRoles:      getEnv("PII_MASKING_ROLES", ""),
+		PIIMaskingRoleTokens: getEnv("PII_MASKING_ROLE_TOKENS", ""),
+		PIIHashSalt:          getEnv("PII_HASH_SALT", ""),
+
+		// Admin Configuration
+		AdminToken: getEnv("ADMIN_TOKEN", ""),
+
+		// Web Responder Dashboard Configuration
+		DashboardToken:          getEnv("DASHBOARD_TOKEN", ""),
+		DashboardAllowedOrigins: getEnv("DASHBOARD_ALLOWED_ORIGINS", ""),
+
+		// Event Backplane Configuration
+		EventsRedisURL:     getEnv("EVENTS_REDIS_URL", ""),
+		EventsRedisChannel: getEnv("EVENTS_REDIS_CHANNEL", "odango:call_events"),
+
+		// PBX Call Events
+		PBXEventsDomain:      getEnv("PBX_EVENTS_DOMAIN", ""),
+		PBXEventsCallbackURL: getEnv("PBX_EVENTS_CALLBACK_URL", ""),
+		PBXEventsSecret:      getEnv("PBX_EVENTS_SECRET", ""),
+
+		// Recording Archive
+		ArchiveStorage:        getEnv("ARCHIVE_STORAGE", "none"),
+		ArchiveDir:            getEnv("ARCHIVE_DIR", "./data/archive"),
+		ArchiveS3Bucket:       getEnv("ARCHIVE_S3_BUCKET", ""),
+		ArchiveS3Prefix:       getEnv("ARCHIVE_S3_PREFIX", ""),
+		ArchiveS3Region:       getEnv("ARCHIVE_S3_REGION", getEnv("AWS_REGION", os.Getenv("AWS_DEFAULT_REGION"))),
+		ArchiveS3Endpoint:     getEnv("ARCHIVE_S3_ENDPOINT", ""),
+		ArchiveRetention:      getEnvAsDuration("ARCHIVE_RETENTION", 0),
+		ArchiveRetentionRules: getEnv("ARCHIVE_RETENTION_RULES", ""),
+		AWSAccessKeyID:        getEnv("AWS_ACCESS_KEY_ID", ""),
+		AWSSecretAccessKey:    getEnv("AWS_SECRET_ACCESS_KEY", ""),
+		AWSSessionToken:       getEnv("AWS_SESSION_TOKEN", ""),
+
+		// Elasticsearch/OpenSearch sink
+		ElasticsearchURL:           getEnv("ELASTICSEARCH_URL", ""),
+		ElasticsearchIndex:         getEnv("ELASTICSEARCH_INDEX", "cdrs-{yyyy}.{MM}.{dd}"),
+		ElasticsearchUsername:      getEnv("ELASTICSEARCH_USERNAME", ""),
+		ElasticsearchPassword:      getEnv("ELASTICSEARCH_PASSWORD", ""),
+		ElasticsearchAPIKey:        getEnv("ELASTICSEARCH_API_KEY", ""),
+		ElasticsearchView:          getEnv("ELASTICSEARCH_VIEW", "raw"),
+		ElasticsearchMasking:       getEnv("ELASTICSEARCH_MASKING", "none"),
+		ElasticsearchBatchSize:     getEnvAsInt("ELASTICSEARCH_BATCH_SIZE", 500),
+		ElasticsearchIndexSearches: getEnvAsBool("ELASTICSEARCH_INDEX_SEARCHES", false),
+
+		// ClickHouse
+		ClickHouseURL:           getEnv("CLICKHOUSE_URL", ""),
+		ClickHouseDatabase:      getEnv("CLICKHOUSE_DATABASE", "default"),
+		ClickHouseTable:         getEnv("CLICKHOUSE_TABLE", "cdrs"),
+		ClickHouseUsername:      getEnv("CLICKHOUSE_USERNAME", ""),
+		ClickHousePassword:      getEnv("CLICKHOUSE_PASSWORD", ""),
+		ClickHouseBatchSize:     getEnvAsInt("CLICKHOUSE_BATCH_SIZE", 10000),
+		ClickHouseRetentionDays: getEnvAsInt("CLICKHOUSE_RETENTION_DAYS", 0),
+		ClickHouseWriteSearches: getEnvAsBool("CLICKHOUSE_WRITE_SEARCHES", true),
+
+		// BigQuery
+		BigQueryCredentials: getEnv("BIGQUERY_CREDENTIALS", os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")),
+		BigQueryProject:     getEnv("BIGQUERY_PROJECT", ""),
+		BigQueryDataset:     getEnv("BIGQUERY_DATASET", "odango"),
+		BigQueryTable:       getEnv("BIGQUERY_TABLE", "cdrs_{yyyy}{MM}{dd}"),
+		BigQueryLocation:    getEnv("BIGQUERY_LOCATION", ""),
+
+		// Parquet Export
+		ParquetStorage:    getEnv("PARQUET_STORAGE", "none"),
+		ParquetDir:        getEnv("PARQUET_DIR", "./data/parquet"),
+		ParquetS3Bucket:   getEnv("PARQUET_S3_BUCKET", ""),
+		ParquetS3Prefix:   getEnv("PARQUET_S3_PREFIX", ""),
+		ParquetS3Region:   getEnv("PARQUET_S3_REGION", getEnv("AWS_REGION", os.Getenv("AWS_DEFAULT_REGION"))),
+		ParquetS3Endpoint: getEnv("PARQUET_S3_ENDPOINT", ""),
+
+		// Recording Transcription
+		TranscriptionBackend:  getEnv("TRANSCRIPTION_BACKEND", "none"),
+		TranscriptionURL:      getEnv("TRANSCRIPTION_URL", ""),
+		TranscriptionAPIKey:   getEnv("TRANSCRIPTION_API_KEY", ""),
+		TranscriptionModel:    getEnv("TRANSCRIPTION_MODEL", ""),
+		TranscriptionLanguage: getEnv("TRANSCRIPTION_LANGUAGE", ""),
+		TranscriptionInterval: getEnvAsDuration("TRANSCRIPTION_INTERVAL", time.Minute),
+		SentimentAnalyzer:     getEnv("SENTIMENT_ANALYZER", "none"),
+		SentimentURL:          getEnv("SENTIMENT_URL", ""),
+		SentimentAPIKey:       getEnv("SENTIMENT_API_KEY", ""),
+
+		// Keyword Alerts
+		AlertWebhookURL:           getEnv("ALERT_WEBHOOK_URL", ""),
+		AlertSlackWebhookURL:      getEnv("ALERT_SLACK_WEBHOOK_URL", ""),
+		AlertSlackBotToken:        getEnv("ALERT_SLACK_BOT_TOKEN", ""),
+		AlertSlackChannel:         getEnv("ALERT_SLACK_CHANNEL", ""),
+		AlertSlackSearchSummaries: getEnvAsBool("ALERT_SLACK_SEARCH_SUMMARIES", false),
+		AlertTeamsWebhookURL:      getEnv("ALERT_TEAMS_WEBHOOK_URL", ""),
+		AlertTeamsSearchSummaries: getEnvAsBool("ALERT_TEAMS_SEARCH_SUMMARIES", false),
+		AlertPagerDutyRoutingKey:  getEnv("ALERT_PAGERDUTY_ROUTING_KEY", ""),
+		AlertEmailTo:              getEnv("ALERT_EMAIL_TO", ""),
+		AlertEmailFrom:            getEnv("ALERT_EMAIL_FROM", ""),
+		AlertLinkBaseURL:          getEnv("ALERT_LINK_BASE_URL", ""),
+		SMTPHost:                  getEnv("SMTP_HOST", ""),
+		SMTPPort:                  getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername:              getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:              getEnv("SMTP_PASSWORD", ""),
+
+		// Carrier/LRN and CNAM Enrichment
+		EnrichmentProvider:   getEnv("ENRICHMENT_PROVIDER", "none"),
+		CNAMProvider:         getEnv("CNAM_PROVIDER", "none"),
+		TelnyxAPIKey:         getEnv("TELNYX_API_KEY", ""),
+		EnrichmentCacheTTL:   getEnvAsDuration("ENRICHMENT_CACHE_TTL", 30*24*time.Hour),
+		EnrichmentMaxLookups: getEnvAsInt("ENRICHMENT_MAX_LOOKUPS", 200),
+
+		// Fraud Detection
+		FraudScanSearches:    getEnvAsBool("FRAUD_SCAN_SEARCHES", false),
+		FraudBurstCalls:      getEnvAsInt("FRAUD_BURST_CALLS", 5),
+		FraudBurstWindow:     getEnvAsDuration("FRAUD_BURST_WINDOW", time.Hour),
+		FraudSequentialCalls: getEnvAsInt("FRAUD_SEQUENTIAL_CALLS", 5),
+		FraudMinutesFactor:   getEnvAsFloat("FRAUD_MINUTES_FACTOR", 5),
+		FraudMinMinutes:      getEnvAsInt("FRAUD_MIN_MINUTES", 120),
+
+		// Spam Scoring
+		SpamScoreSearches: getEnvAsBool("SPAM_SCORE_SEARCHES", true),
+		SpamRepeatCalls:   getEnvAsInt("SPAM_REPEAT_CALLS", 5),
+		SpamThreshold:     getEnvAsInt("SPAM_THRESHOLD", 50),
+
+		// Telephony KPIs
+		KPIRecordSearches: getEnvAsBool("KPI_RECORD_SEARCHES", true),
+		KPITrunkFields:    getEnv("KPI_TRUNK_FIELDS", "call-route,call-term-route,call-orig-route"),
+
+		// Billing Reconciliation
+		BillingTimeTolerance:     getEnvAsDuration("BILLING_TIME_TOLERANCE", 2*time.Minute),
+		BillingDurationTolerance: getEnvAsDuration("BILLING_DURATION_TOLERANCE", time.Minute),
+		BillingIncludeInbound:    getEnvAsBool("BILLING_INCLUDE_INBOUND", false),
+
+		// Weather and Air Quality Configuration
+		AQIProvider:       getEnv("AQI_PROVIDER", "simulated"),
+		AQIAPIKey:         getEnv("AQI_API_KEY", ""),
+		AQICacheTTL:       getEnvAsDuration("AQI_CACHE_TTL", 30*time.Minute),
+		WeatherCacheTTL:   getEnvAsDuration("WEATHER_CACHE_TTL", 10*time.Minute),
+		GeoCachePrecision: getEnvAsInt("GEO_CACHE_PRECISION", 2),
+
+		// SMS Follow-up Configuration
+		SMSProvider:         getEnv("SMS_PROVIDER", "none"),
+		SMSFromNumber:       getEnv("SMS_FROM_NUMBER", ""),
+		SMSNetsapiensDomain: getEnv("SMS_NETSAPIENS_DOMAIN", ""),
+		SMSNetsapiensUser:   getEnv("SMS_NETSAPIENS_USER", ""),
+		TwilioAccountSID:    getEnv("TWILIO_ACCOUNT_SID", ""),
+		TwilioAuthToken:     getEnv("TWILIO_AUTH_TOKEN", ""),
+
+		// IVR Configuration
+		IVRSessionStore: getEnv("IVR_SESSION_STORE", "memory"),
+		IVRSessionTTL:   getEnvAsDuration("IVR_SESSION_TTL", 30*time.Minute),
+		IVRFlowsDir:     getEnv("IVR_FLOWS_DIR", "./flows"),
+		IVRMenu:         getEnv("IVR_MENU", "1:weather:local time and weather,2:cdr-lookup:your recent calls,3:survey:a short survey,4:voicemail:voicemail"),
+		IVROperator:     getEnv("IVR_OPERATOR", ""),
+
+		// IVR Queue Status Line
+		IVRQueueDomain:     getEnv("IVR_QUEUE_DOMAIN", ""),
+		IVRQueue:           getEnv("IVR_QUEUE", ""),
+		IVRQueueHandleTime: getEnvAsDuration("IVR_QUEUE_HANDLE_TIME", 4*time.Minute),
+
+		// IVR Voice Configuration
+		IVRVoice:        getEnv("IVR_VOICE", "female"),
+		IVRLanguage:     getEnv("IVR_LANGUAGE", "en-US"),
+		IVRSpeakingRate: getEnv("IVR_SPEAKING_RATE", ""),
+		IVRPromptsFile:  getEnv("IVR_PROMPTS_FILE", ""),
+		IVRAudioBaseURL: getEnv("IVR_AUDIO_BASE_URL", ""),
+
+		// IVR Speech Input
+		IVRSpeechEnabled:    getEnvAsBool("IVR_SPEECH_ENABLED", false),
+		IVRSpeechConfidence: getEnvAsFloat("IVR_SPEECH_CONFIDENCE", 0.5),
+
+		// Runtime Configuration
+		ResultsTTL:    getEnvAsDuration("RESULTS_TTL", 1*time.Hour),
+		DebugLogging:  getEnvAsBool("DEBUG_LOGGING", true),
+		AreaCodesFile: getEnv("AREA_CODES_FILE", ""),
+		ZIPCodesFile:  getEnv("ZIP_CODES_FILE", ""),
+
+		// Call Classification
+		CallMinAnsweredSeconds: getEnvAsInt("CALL_MIN_ANSWERED_SECONDS", 0),
+		CallAbandonedReasons:   getEnv("CALL_ABANDONED_REASONS", "originator cancel,cancel,request terminated,487,abandon,caller hangup"),
+		CallMissedReasons:      getEnv("CALL_MISSED_REASONS", "no answer,busy,timeout,unavailable,declined,480,486,408,603"),
 	}
 
-	// Remove the validation since tokens come from users now
-	// if config.NetsapiensToken == "" {
-	//     log.Fatal("NETSAPIENS_ACCESS_TOKEN is required but not set")
-	// }
+	// Resolve secret:// references from Vault or AWS Secrets Manager
+	resolver := NewSecretResolver()
+	secretFields := map[string]*string{
+		"NETSAPIENS_ACCESS_TOKEN":     &config.NetsapiensToken,
+		"NETSAPIENS_CLIENT_SECRET":    &config.NetsapiensSecret,
+		"SESSION_SECRET":              &config.SessionSecret,
+		"DATABASE_PATH":               &config.DatabasePath,
+		"PII_HASH_SALT":               &config.PIIHashSalt,
+		"PII_MASKING_ROLE_TOKENS":     &config.PIIMaskingRoleTokens,
+		"ADMIN_TOKEN":                 &config.AdminToken,
+		"DASHBOARD_TOKEN":             &config.DashboardToken,
+		"EVENTS_REDIS_URL":            &config.EventsRedisURL,
+		"PBX_EVENTS_SECRET":           &config.PBXEventsSecret,
+		"AQI_API_KEY":                 &config.AQIAPIKey,
+		"TWILIO_AUTH_TOKEN":           &config.TwilioAuthToken,
+		"TRANSCRIPTION_API_KEY":       &config.TranscriptionAPIKey,
+		"ELASTICSEARCH_PASSWORD":      &config.ElasticsearchPassword,
+		"ELASTICSEARCH_API_KEY":       &config.ElasticsearchAPIKey,
+		"CLICKHOUSE_PASSWORD":         &config.ClickHousePassword,
+		"BIGQUERY_CREDENTIALS":        &config.BigQueryCredentials,
+		"SENTIMENT_API_KEY":           &config.SentimentAPIKey,
+		"ALERT_WEBHOOK_URL":           &config.AlertWebhookURL,
+		"ALERT_SLACK_WEBHOOK_URL":     &config.AlertSlackWebhookURL,
+		"ALERT_SLACK_BOT_TOKEN":       &config.AlertSlackBotToken,
+		"ALERT_TEAMS_WEBHOOK_URL":     &config.AlertTeamsWebhookURL,
+		"ALERT_PAGERDUTY_ROUTING_KEY": &config.AlertPagerDutyRoutingKey,
+		"SMTP_PASSWORD":               &config.SMTPPassword,
+		"TELNYX_API_KEY":              &config.TelnyxAPIKey,
+	}
+	for name, field := range secretFields {
+		value, err := resolver.Resolve(*field)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve %s: %w", name, err)
+		}
+		*field = value
+	}
 
-	return config
+	// Hash with the session secret unless a dedicated salt is configured
+	if config.PIIHashSalt == "" {
+		config.PIIHashSalt = config.SessionSecret
+	}
+
+	// Operators can sign in to the dashboard with the admin token unless it has its own
+	if config.DashboardToken == "" {
+		config.DashboardToken = config.AdminToken
+	}
+
+	return config, nil
 }
 
 // getEnv gets an environment variable with a fallback default value
@@ -73,6 +541,16 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvAsFloat gets an environment variable as float with fallback
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 // getEnvAsBool gets an environment variable as boolean with fallback
 func getEnvAsBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
@@ -83,6 +561,16 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getEnvAsDuration gets an environment variable as a duration (e.g. "30m") with fallback
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
 // IsProduction checks if we're running in production
 func (c *Config) IsProduction() bool {
 	return c.AppEnv == "production"
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+
+	"o-dan-go/services"
+)
+
+func TestDefaultIVRMenuPrompts(t *testing.T) {
+	t.Setenv("IVR_MENU", "")
+	config, err := buildConfig()
+	if err != nil {
+		t.Fatalf("buildConfig: %v", err)
+	}
+	options, err := services.ParseMenuOptions(config.IVRMenu)
+	if err != nil {
+		t.Fatalf("ParseMenuOptions(%q): %v", config.IVRMenu, err)
+	}
+
+	want := []string{
+		"For local time and weather, press 1.",
+		"For your recent calls, press 2.",
+		"For a short survey, press 3.",
+		"For voicemail, press 4.",
+	}
+	if len(options) != len(want) {
+		t.Fatalf("default menu has %d options, want %d", len(options), len(want))
+	}
+	prompts := services.NewPrompts()
+	for i, option := range options {
+		if prompt := prompts.Text("menu.option", map[string]string{"label": option.Label, "digit": option.Digit}); prompt != want[i] {
+			t.Errorf("option %s = %q, want %q", option.Digit, prompt, want[i])
+		}
+	}
+}
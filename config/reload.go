@@ -0,0 +1,102 @@
+package config
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// ReloadHook applies the reloadable parts of a freshly loaded Config
+type ReloadHook func(cfg *Config)
+
+// Reloader re-reads configuration on demand and hands it to registered hooks.
+// Only settings that hooks apply change at runtime; everything else (port,
+// database path, secrets) still requires a restart.
+type Reloader struct {
+	mu         sync.RWMutex
+	current    *Config
+	hooks      []ReloadHook
+	lastReload time.Time
+}
+
+// NewReloader creates a reloader starting from the config loaded at startup
+func NewReloader(initial *Config) *Reloader {
+	return &Reloader{
+		current: initial,
+	}
+}
+
+// OnReload registers a hook and applies it to the current config immediately
+func (r *Reloader) OnReload(hook ReloadHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.hooks = append(r.hooks, hook)
+	hook(r.current)
+}
+
+// Current returns the most recently loaded config
+func (r *Reloader) Current() *Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.current
+}
+
+// LastReload returns when the config was last reloaded (zero if never)
+func (r *Reloader) LastReload() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.lastReload
+}
+
+// Reload re-reads .env and the environment, then runs every hook.
+// On error the running config is left untouched.
+func (r *Reloader) Reload() (*Config, error) {
+	// Re-apply .env values, without overriding variables from the process environment
+	if values, err := godotenv.Read(); err == nil {
+		for key, value := range values {
+			if !processEnvKeys[key] {
+				os.Setenv(key, value)
+			}
+		}
+	}
+
+	cfg, err := buildConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.current = cfg
+	r.lastReload = time.Now()
+	for _, hook := range r.hooks {
+		hook(cfg)
+	}
+
+	log.Printf("[Config] Configuration reloaded")
+	return cfg, nil
+}
+
+// WatchSignals reloads configuration whenever the process receives SIGHUP
+func (r *Reloader) WatchSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for range signals {
+			log.Printf("[Config] SIGHUP received, reloading configuration")
+			if _, err := r.Reload(); err != nil {
+				log.Printf("[Config] Reload failed, keeping current configuration: %v", err)
+			}
+		}
+	}()
+}
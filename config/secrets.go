@@ -0,0 +1,275 @@
+package config
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// secretScheme prefixes config values that should be resolved from a secrets provider.
+//
+//	secret://vault/<mount>/<path>#<key>   HashiCorp Vault KV v2
+//	secret://aws/<secret-id>#<key>        AWS Secrets Manager (key optional for plain strings)
+const secretScheme = "secret://"
+
+// SecretProvider fetches a secret document by path
+type SecretProvider interface {
+	// Fetch returns the secret at path as key/value pairs, or under "" for plain strings
+	Fetch(path string) (map[string]string, error)
+}
+
+// SecretResolver resolves secret:// references, caching each document it fetches
+type SecretResolver struct {
+	providers map[string]SecretProvider
+	cache     map[string]map[string]string
+}
+
+// NewSecretResolver creates a resolver with providers configured from the environment
+func NewSecretResolver() *SecretResolver {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	return &SecretResolver{
+		providers: map[string]SecretProvider{
+			"vault": &VaultProvider{
+				client:    client,
+				address:   strings.TrimRight(os.Getenv("VAULT_ADDR"), "/"),
+				token:     os.Getenv("VAULT_TOKEN"),
+				namespace: os.Getenv("VAULT_NAMESPACE"),
+			},
+			"aws": &AWSSecretsManagerProvider{
+				client:       client,
+				region:       getEnv("AWS_REGION", os.Getenv("AWS_DEFAULT_REGION")),
+				accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+				secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+				sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+			},
+		},
+		cache: make(map[string]map[string]string),
+	}
+}
+
+// IsSecretRef reports whether a config value is a secret:// reference
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, secretScheme)
+}
+
+// Resolve returns the secret a reference points to, or the value unchanged if it isn't one
+func (sr *SecretResolver) Resolve(value string) (string, error) {
+	if !IsSecretRef(value) {
+		return value, nil
+	}
+
+	ref := strings.TrimPrefix(value, secretScheme)
+	ref, key, _ := strings.Cut(ref, "#")
+
+	providerName, path, ok := strings.Cut(ref, "/")
+	if !ok || path == "" {
+		return "", fmt.Errorf("invalid secret reference %q: expected secret://<provider>/<path>", value)
+	}
+
+	provider, exists := sr.providers[providerName]
+	if !exists {
+		return "", fmt.Errorf("unknown secrets provider %q", providerName)
+	}
+
+	cacheKey := providerName + "/" + path
+	document, cached := sr.cache[cacheKey]
+	if !cached {
+		var err error
+		document, err = provider.Fetch(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch secret %s: %w", cacheKey, err)
+		}
+		sr.cache[cacheKey] = document
+	}
+
+	secret, exists := document[key]
+	if !exists {
+		return "", fmt.Errorf("secret %s has no key %q", cacheKey, key)
+	}
+	return secret, nil
+}
+
+// VaultProvider reads secrets from a HashiCorp Vault KV v2 engine
+type VaultProvider struct {
+	client    *http.Client
+	address   string
+	token     string
+	namespace string
+}
+
+// Fetch reads <mount>/<path> from Vault's KV v2 API
+func (vp *VaultProvider) Fetch(path string) (map[string]string, error) {
+	if vp.address == "" || vp.token == "" {
+		return nil, fmt.Errorf("VAULT_ADDR and VAULT_TOKEN must be set")
+	}
+
+	mount, secretPath, ok := strings.Cut(path, "/")
+	if !ok {
+		return nil, fmt.Errorf("vault path must be <mount>/<path>")
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/v1/%s/data/%s", vp.address, mount, secretPath), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", vp.token)
+	if vp.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", vp.namespace)
+	}
+
+	resp, err := vp.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned HTTP %d", resp.StatusCode)
+	}
+
+	var payload struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode vault response: %w", err)
+	}
+
+	document := make(map[string]string, len(payload.Data.Data))
+	for key, value := range payload.Data.Data {
+		document[key] = fmt.Sprintf("%v", value)
+	}
+	return document, nil
+}
+
+// AWSSecretsManagerProvider reads secrets from AWS Secrets Manager
+type AWSSecretsManagerProvider struct {
+	client       *http.Client
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+// Fetch calls GetSecretValue; JSON secrets are split into keys, plain strings are stored under ""
+func (ap *AWSSecretsManagerProvider) Fetch(secretID string) (map[string]string, error) {
+	if ap.region == "" || ap.accessKey == "" || ap.secretKey == "" {
+		return nil, fmt.Errorf("AWS_REGION, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+
+	body, err := json.Marshal(map[string]string{"SecretId": secretID})
+	if err != nil {
+		return nil, err
+	}
+
+	host := fmt.Sprintf("secretsmanager.%s.amazonaws.com", ap.region)
+	req, err := http.NewRequest("POST", "https://"+host+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	ap.sign(req, host, body, time.Now().UTC())
+
+	resp, err := ap.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("secrets manager returned HTTP %d: %s", resp.StatusCode, detail)
+	}
+
+	var payload struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode secrets manager response: %w", err)
+	}
+
+	document := map[string]string{"": payload.SecretString}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(payload.SecretString), &fields); err == nil {
+		for key, value := range fields {
+			document[key] = fmt.Sprintf("%v", value)
+		}
+	}
+	return document, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to the request
+func (ap *AWSSecretsManagerProvider) sign(req *http.Request, host string, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+
+	req.Header.Set("Host", host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	headers := [][2]string{
+		{"content-type", req.Header.Get("Content-Type")},
+		{"host", host},
+		{"x-amz-date", amzDate},
+	}
+	if ap.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", ap.sessionToken)
+		headers = append(headers, [2]string{"x-amz-security-token", ap.sessionToken})
+	}
+	headers = append(headers, [2]string{"x-amz-target", req.Header.Get("X-Amz-Target")})
+
+	scope, signedHeaders, signature := signV4("POST", "/", "", headers, body, ap.secretKey, ap.region, "secretsmanager", now)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		ap.accessKey, scope, signedHeaders, signature))
+}
+
+// signV4 returns the credential scope, signed header list and Signature Version 4
+// signature of a request. headers are the lowercase names and values to sign, sorted by
+// name; query is the canonical query string.
+func signV4(method, path, query string, headers [][2]string, payload []byte, secretKey, region, service string, now time.Time) (string, string, string) {
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	var canonicalHeaders strings.Builder
+	names := make([]string, 0, len(headers))
+	for _, header := range headers {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", header[0], header[1])
+		names = append(names, header[0])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method, path, query, canonicalHeaders.String(), signedHeaders, sha256Hex(payload),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", dateStamp, region, service)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretKey), dateStamp)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	return scope, signedHeaders, hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSecrets serves secret documents from memory, counting fetches
+type fakeSecrets struct {
+	documents map[string]map[string]string
+	fetches   int
+}
+
+func (fs *fakeSecrets) Fetch(path string) (map[string]string, error) {
+	fs.fetches++
+	document, exists := fs.documents[path]
+	if !exists {
+		return nil, fmt.Errorf("no secret at %s", path)
+	}
+	return document, nil
+}
+
+func TestResolveSecretRefs(t *testing.T) {
+	fake := &fakeSecrets{documents: map[string]map[string]string{
+		"db":        {"password": "hunter2", "user": "odango"},
+		"api/token": {"": "plain-token"},
+	}}
+	resolver := &SecretResolver{
+		providers: map[string]SecretProvider{"fake": fake},
+		cache:     make(map[string]map[string]string),
+	}
+
+	tests := []struct {
+		value   string
+		want    string
+		wantErr string
+	}{
+		{value: "not-a-secret", want: "not-a-secret"},
+		{value: "", want: ""},
+		{value: "vault://kv/db#password", want: "vault://kv/db#password"},
+		{value: "secret://fake/db#password", want: "hunter2"},
+		{value: "secret://fake/db#user", want: "odango"},
+		{value: "secret://fake/api/token", want: "plain-token"},
+		{value: "secret://", wantErr: "invalid secret reference"},
+		{value: "secret://fake", wantErr: "invalid secret reference"},
+		{value: "secret://fake/", wantErr: "invalid secret reference"},
+		{value: "secret://fake#password", wantErr: "invalid secret reference"},
+		{value: "secret://gcp/db#password", wantErr: `unknown secrets provider "gcp"`},
+		{value: "secret://fake/db#missing", wantErr: `has no key "missing"`},
+		{value: "secret://fake/db", wantErr: `has no key ""`},
+		{value: "secret://fake/nowhere#key", wantErr: "failed to fetch secret fake/nowhere"},
+	}
+	for _, tt := range tests {
+		got, err := resolver.Resolve(tt.value)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Resolve(%q) = %q, %v; want an error containing %q", tt.value, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", tt.value, got, err, tt.want)
+		}
+	}
+
+	// db and api/token are fetched once each; the failed lookup isn't cached
+	if fake.fetches != 3 {
+		t.Errorf("fetched %d documents, want 3", fake.fetches)
+	}
+}
+
+// TestSignV4PublishedExample signs the GET ListUsers request from AWS's Signature
+// Version 4 documentation and checks the published signature
+func TestSignV4PublishedExample(t *testing.T) {
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	headers := [][2]string{
+		{"content-type", "application/x-www-form-urlencoded; charset=utf-8"},
+		{"host", "iam.amazonaws.com"},
+		{"x-amz-date", "20150830T123600Z"},
+	}
+	scope, signedHeaders, signature := signV4("GET", "/", "Action=ListUsers&Version=2010-05-08", headers, nil,
+		"wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "us-east-1", "iam", now)
+
+	if scope != "20150830/us-east-1/iam/aws4_request" {
+		t.Errorf("scope = %s", scope)
+	}
+	if signedHeaders != "content-type;host;x-amz-date" {
+		t.Errorf("signed headers = %s", signedHeaders)
+	}
+	if want := "5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"; signature != want {
+		t.Errorf("signature = %s, want %s", signature, want)
+	}
+
+	// Secrets Manager requests sign the session token too
+	provider := &AWSSecretsManagerProvider{region: "us-east-1", accessKey: "AKIDEXAMPLE",
+		secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", sessionToken: "session"}
+	req := httptest.NewRequest("POST", "https://secretsmanager.us-east-1.amazonaws.com/", nil)
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	provider.sign(req, "secretsmanager.us-east-1.amazonaws.com", []byte(`{"SecretId":"odango"}`), now)
+	authorization := req.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/secretsmanager/aws4_request, "+
+		"SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, Signature=") {
+		t.Errorf("Authorization = %s", authorization)
+	}
+	if req.Header.Get("X-Amz-Security-Token") != "session" || req.Header.Get("X-Amz-Date") != "20150830T123600Z" {
+		t.Errorf("headers = %v, want the session token and date set", req.Header)
+	}
+}
+
+func TestVaultProviderFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/secret/data/odango/prod" || r.Header.Get("X-Vault-Namespace") != "ops" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data": {"data": {"password": "hunter2", "port": 5432}, "metadata": {"version": 3}}}`))
+	}))
+	defer server.Close()
+
+	vault := &VaultProvider{client: server.Client(), address: server.URL, token: "vault-token", namespace: "ops"}
+	resolver := &SecretResolver{
+		providers: map[string]SecretProvider{"vault": vault},
+		cache:     make(map[string]map[string]string),
+	}
+	for value, want := range map[string]string{
+		"secret://vault/secret/odango/prod#password": "hunter2",
+		"secret://vault/secret/odango/prod#port":     "5432",
+	} {
+		if got, err := resolver.Resolve(value); err != nil || got != want {
+			t.Errorf("Resolve(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+
+	if _, err := resolver.Resolve("secret://vault/secret/other#password"); err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("missing secret: %v, want vault's 404", err)
+	}
+	if _, err := (&VaultProvider{client: server.Client(), address: server.URL, token: "wrong"}).Fetch("secret/odango/prod"); err == nil ||
+		!strings.Contains(err.Error(), "HTTP 403") {
+		t.Errorf("wrong token: %v, want vault's 403", err)
+	}
+	if _, err := (&VaultProvider{client: server.Client(), address: server.URL, token: "vault-token"}).Fetch("odango"); err == nil {
+		t.Error("a path without a mount was fetched")
+	}
+	if _, err := (&VaultProvider{client: server.Client()}).Fetch("secret/odango/prod"); err == nil || !strings.Contains(err.Error(), "VAULT_ADDR") {
+		t.Errorf("unconfigured vault: %v", err)
+	}
+}
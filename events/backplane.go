@@ -0,0 +1,237 @@
+package events
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backplane shares call events between server instances, so every dashboard sees every call
+type Backplane interface {
+	// Publish sends a local event to the other instances
+	Publish(event CallEvent) error
+	// Start delivers events published by other instances until the process exits
+	Start(deliver func(CallEvent))
+}
+
+// backplaneMessage is the wire format, tagged with the sending instance so it can skip its own events
+type backplaneMessage struct {
+	Origin string    `json:"origin"`
+	Event  CallEvent `json:"event"`
+}
+
+// RedisBackplane fans call events out over Redis pub/sub
+type RedisBackplane struct {
+	address  string
+	password string
+	channel  string
+	instance string
+
+	mu   sync.Mutex // guards the publishing connection
+	conn net.Conn
+	rw   *bufio.ReadWriter
+}
+
+// NewRedisBackplane creates a backplane from a redis://[:password@]host[:port] URL
+func NewRedisBackplane(redisURL, channel string) (*RedisBackplane, error) {
+	parsed, err := url.Parse(redisURL)
+	if err != nil || (parsed.Scheme != "redis" && parsed.Scheme != "tcp") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid Redis URL %q: expected redis://[:password@]host[:port]", redisURL)
+	}
+
+	address := parsed.Host
+	if parsed.Port() == "" {
+		address = net.JoinHostPort(parsed.Hostname(), "6379")
+	}
+	password, _ := parsed.User.Password()
+	if password == "" && parsed.User != nil {
+		password = parsed.User.Username()
+	}
+
+	return &RedisBackplane{
+		address:  address,
+		password: password,
+		channel:  channel,
+		instance: instanceID(),
+	}, nil
+}
+
+// instanceID identifies this process on the backplane
+func instanceID() string {
+	hostname, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// Publish sends an event on the channel, reconnecting once if the connection was lost
+func (rb *RedisBackplane) Publish(event CallEvent) error {
+	payload, err := json.Marshal(backplaneMessage{Origin: rb.instance, Event: event})
+	if err != nil {
+		return err
+	}
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if rb.conn == nil {
+			conn, rw, err := rb.dial()
+			if err != nil {
+				return err
+			}
+			rb.conn, rb.rw = conn, rw
+		}
+
+		rb.conn.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, err = rb.command(rb.rw, "PUBLISH", rb.channel, string(payload)); err == nil {
+			return nil
+		}
+
+		rb.conn.Close()
+		rb.conn, rb.rw = nil, nil
+	}
+	return fmt.Errorf("failed to publish to Redis: %w", err)
+}
+
+// Start subscribes to the channel in the background, reconnecting with backoff
+func (rb *RedisBackplane) Start(deliver func(CallEvent)) {
+	go func() {
+		backoff := time.Second
+		for {
+			err := rb.subscribe(deliver, func() { backoff = time.Second })
+			log.Printf("[Events] Redis subscription to %s lost, retrying in %s: %v", rb.channel, backoff, err)
+			time.Sleep(backoff)
+			if backoff < 30*time.Second {
+				backoff *= 2
+			}
+		}
+	}()
+}
+
+// subscribe reads messages until the connection fails
+func (rb *RedisBackplane) subscribe(deliver func(CallEvent), connected func()) error {
+	conn, rw, err := rb.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := rb.command(rw, "SUBSCRIBE", rb.channel); err != nil {
+		return err
+	}
+	log.Printf("[Events] Subscribed to Redis channel %s as %s", rb.channel, rb.instance)
+	connected()
+
+	for {
+		reply, err := readReply(rw.Reader)
+		if err != nil {
+			return err
+		}
+
+		// Pushed messages are ["message", channel, payload]
+		parts, ok := reply.([]interface{})
+		if !ok || len(parts) != 3 || parts[0] != "message" {
+			continue
+		}
+		payload, _ := parts[2].(string)
+
+		var message backplaneMessage
+		if err := json.Unmarshal([]byte(payload), &message); err != nil {
+			log.Printf("[Events] Ignoring malformed backplane message: %v", err)
+			continue
+		}
+		if message.Origin == rb.instance {
+			continue
+		}
+		deliver(message.Event)
+	}
+}
+
+// dial connects and authenticates
+func (rb *RedisBackplane) dial() (net.Conn, *bufio.ReadWriter, error) {
+	conn, err := net.DialTimeout("tcp", rb.address, 5*time.Second)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to Redis at %s: %w", rb.address, err)
+	}
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	if rb.password != "" {
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		if _, err := rb.command(rw, "AUTH", rb.password); err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("failed to authenticate with Redis: %w", err)
+		}
+		conn.SetDeadline(time.Time{})
+	}
+
+	return conn, rw, nil
+}
+
+// command sends a command and reads its reply
+func (rb *RedisBackplane) command(rw *bufio.ReadWriter, args ...string) (interface{}, error) {
+	fmt.Fprintf(rw, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(rw, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := rw.Flush(); err != nil {
+		return nil, err
+	}
+	return readReply(rw.Reader)
+}
+
+// readReply parses one RESP value: strings, integers, bulk strings and arrays
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty Redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		length, err := strconv.Atoi(line[1:])
+		if err != nil || length < 0 {
+			return nil, err
+		}
+		data := make([]byte, length+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:length]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, count)
+		for i := range values {
+			if values[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unexpected Redis reply %q", line)
+}
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis implements just enough pub/sub for the backplane
+type fakeRedis struct {
+	listener    net.Listener
+	mu          sync.Mutex
+	subscribers []net.Conn
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr := &fakeRedis{listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go fr.serve(conn)
+		}
+	}()
+	return fr
+}
+
+func (fr *fakeRedis) serve(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	for {
+		reply, err := readReply(reader)
+		if err != nil {
+			return
+		}
+		args := reply.([]interface{})
+
+		fr.mu.Lock()
+		switch args[0] {
+		case "SUBSCRIBE":
+			fr.subscribers = append(fr.subscribers, conn)
+			fmt.Fprintf(conn, "*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(args[1].(string)), args[1])
+		case "PUBLISH":
+			channel, payload := args[1].(string), args[2].(string)
+			for _, sub := range fr.subscribers {
+				fmt.Fprintf(sub, "*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(channel), channel, len(payload), payload)
+			}
+			fmt.Fprintf(conn, ":%d\r\n", len(fr.subscribers))
+		default:
+			fmt.Fprintf(conn, "+OK\r\n")
+		}
+		fr.mu.Unlock()
+	}
+}
+
+func (fr *fakeRedis) subscriberCount() int {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	return len(fr.subscribers)
+}
+
+func TestRedisBackplaneDeliversToOtherInstances(t *testing.T) {
+	fr := newFakeRedis(t)
+	redisURL := "redis://" + fr.listener.Addr().String()
+
+	first, err := NewRedisBackplane(redisURL, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewRedisBackplane(redisURL, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstReceived := make(chan CallEvent, 1)
+	secondReceived := make(chan CallEvent, 1)
+	first.Start(func(event CallEvent) { firstReceived <- event })
+	second.Start(func(event CallEvent) { secondReceived <- event })
+
+	for deadline := time.Now().Add(2 * time.Second); fr.subscriberCount() < 2; {
+		if time.Now().After(deadline) {
+			t.Fatal("backplanes did not subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := first.Publish(CallEvent{CallID: "call_1", EventType: "call_started"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	select {
+	case event := <-secondReceived:
+		if event.CallID != "call_1" || event.EventType != "call_started" {
+			t.Errorf("unexpected event %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("second instance did not receive the event")
+	}
+
+	select {
+	case event := <-firstReceived:
+		t.Errorf("publisher received its own event %+v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNewRedisBackplaneURL(t *testing.T) {
+	backplane, err := NewRedisBackplane("redis://:pw@cache", "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backplane.address != "cache:6379" || backplane.password != "pw" {
+		t.Errorf("got address %q password %q", backplane.address, backplane.password)
+	}
+
+	if _, err := NewRedisBackplane("http://cache", "events"); err == nil {
+		t.Error("expected an error for a non-redis URL")
+	}
+}
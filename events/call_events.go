@@ -1,12 +1,15 @@
 package events
 
 import (
+	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // CallEvent represents a Web Responder call event for the dashboard
 type CallEvent struct {
+	App       string    `json:"app,omitempty"` // IVR app that sent the event
 	SessionID string    `json:"session_id"`
 	CallID    string    `json:"call_id"`
 	CallerNum string    `json:"caller_number"`
@@ -15,10 +18,12 @@ type CallEvent struct {
 	EventType string    `json:"event_type"`
 	Details   string    `json:"details"`
 	Timestamp time.Time `json:"timestamp"`
+	Remote    bool      `json:"-"` // received from another instance over the backplane
 }
 
 // ActiveCall represents an ongoing call in the system
 type ActiveCall struct {
+	App        string    `json:"app,omitempty"` // app that started the call ("pbx" for PBX calls)
 	CallID     string    `json:"call_id"`
 	SessionID  string    `json:"session_id"`
 	CallerNum  string    `json:"caller_number"`
@@ -36,25 +41,94 @@ type EventManager struct {
 	activeCalls  map[string]*ActiveCall
 	EventChannel chan CallEvent
 	listeners    []chan CallEvent
+
+	// Ring buffer of the most recent events, oldest overwritten first
+	history     []CallEvent
+	historyNext int
+
+	// Optional backplane sharing events with other instances
+	backplane    Backplane
+	publishQueue chan CallEvent
+
+	// Counters for admin introspection
+	eventsSent      atomic.Uint64
+	eventsDropped   atomic.Uint64
+	eventsProcessed atomic.Uint64
+	listenerDrops   atomic.Uint64
+	remoteEvents    atomic.Uint64
+	backplaneErrors atomic.Uint64
+}
+
+// EventManagerStats summarizes event flow for admin introspection
+type EventManagerStats struct {
+	ActiveCalls     int    `json:"active_calls"`
+	Listeners       int    `json:"listeners"`
+	QueuedEvents    int    `json:"queued_events"`
+	QueueCapacity   int    `json:"queue_capacity"`
+	EventsSent      uint64 `json:"events_sent"`
+	EventsDropped   uint64 `json:"events_dropped"` // Rejected because the queue was full
+	EventsProcessed uint64 `json:"events_processed"`
+	ListenerDrops   uint64 `json:"listener_drops"` // Skipped deliveries to slow listeners
+	Backplane       bool   `json:"backplane"`
+	RemoteEvents    uint64 `json:"remote_events"`    // Received from other instances
+	BackplaneErrors uint64 `json:"backplane_errors"` // Failed or dropped publishes
 }
 
+// HistorySize is how many recent events the event manager keeps in memory
+const HistorySize = 500
+
 // Global event manager instance
 var Manager = &EventManager{
 	activeCalls:  make(map[string]*ActiveCall),
 	EventChannel: make(chan CallEvent, 100),
 	listeners:    make([]chan CallEvent, 0),
+	history:      make([]CallEvent, 0, HistorySize),
+}
+
+// SetBackplane shares events with other instances. Call it before Start.
+func (em *EventManager) SetBackplane(backplane Backplane) {
+	em.backplane = backplane
+	em.publishQueue = make(chan CallEvent, 100)
 }
 
 // Start begins processing events
 func (em *EventManager) Start() {
+	if em.backplane != nil {
+		go em.publishEvents()
+		em.backplane.Start(func(event CallEvent) {
+			event.Remote = true
+			em.remoteEvents.Add(1)
+			SendEvent(event)
+		})
+	}
+
 	go func() {
 		for event := range em.EventChannel {
 			em.processEvent(event)
 			em.broadcast(event)
+			em.eventsProcessed.Add(1)
+
+			if em.backplane != nil && !event.Remote {
+				select {
+				case em.publishQueue <- event:
+				default:
+					em.backplaneErrors.Add(1)
+				}
+			}
 		}
 	}()
 }
 
+// publishEvents sends local events to the backplane without holding up the event loop
+func (em *EventManager) publishEvents() {
+	for event := range em.publishQueue {
+		if err := em.backplane.Publish(event); err != nil {
+			em.backplaneErrors.Add(1)
+			log.Printf("[Events] Failed to publish %s for %s: %v", event.EventType, event.CallID, err)
+		}
+	}
+}
+
 // Subscribe adds a new listener for events
 func (em *EventManager) Subscribe() chan CallEvent {
 	em.mu.Lock()
@@ -84,9 +158,17 @@ func (em *EventManager) processEvent(event CallEvent) {
 	em.mu.Lock()
 	defer em.mu.Unlock()
 
+	if len(em.history) < HistorySize {
+		em.history = append(em.history, event)
+	} else {
+		em.history[em.historyNext] = event
+	}
+	em.historyNext = (em.historyNext + 1) % HistorySize
+
 	switch event.EventType {
 	case "call_started":
 		em.activeCalls[event.CallID] = &ActiveCall{
+			App:        event.App,
 			CallID:     event.CallID,
 			SessionID:  event.SessionID,
 			CallerNum:  event.CallerNum,
@@ -102,12 +184,12 @@ func (em *EventManager) processEvent(event CallEvent) {
 			call.LastAction = event.Details
 		}
 
-	case "response_sent":
+	case "response_sent", "app_selected":
 		if call, exists := em.activeCalls[event.CallID]; exists {
 			call.LastAction = event.Details
 		}
 
-	case "call_ended":
+	case "call_ended", "call_transferred":
 		delete(em.activeCalls, event.CallID)
 	}
 
@@ -127,6 +209,7 @@ func (em *EventManager) broadcast(event CallEvent) {
 		case listener <- event:
 		default:
 			// Don't block if listener is full
+			em.listenerDrops.Add(1)
 		}
 	}
 }
@@ -143,11 +226,49 @@ func (em *EventManager) GetActiveCalls() []ActiveCall {
 	return calls
 }
 
+// RecentEvents returns up to limit of the most recent events, newest first
+func (em *EventManager) RecentEvents(limit int) []CallEvent {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+
+	if limit <= 0 || limit > len(em.history) {
+		limit = len(em.history)
+	}
+
+	recent := make([]CallEvent, 0, limit)
+	for i := 1; i <= limit; i++ {
+		recent = append(recent, em.history[(em.historyNext-i+HistorySize)%HistorySize])
+	}
+	return recent
+}
+
+// Stats returns current event manager counters
+func (em *EventManager) Stats() EventManagerStats {
+	em.mu.RLock()
+	defer em.mu.RUnlock()
+
+	return EventManagerStats{
+		ActiveCalls:     len(em.activeCalls),
+		Listeners:       len(em.listeners),
+		QueuedEvents:    len(em.EventChannel),
+		QueueCapacity:   cap(em.EventChannel),
+		EventsSent:      em.eventsSent.Load(),
+		EventsDropped:   em.eventsDropped.Load(),
+		EventsProcessed: em.eventsProcessed.Load(),
+		ListenerDrops:   em.listenerDrops.Load(),
+		Backplane:       em.backplane != nil,
+		RemoteEvents:    em.remoteEvents.Load(),
+		BackplaneErrors: em.backplaneErrors.Load(),
+	}
+}
+
 // SendEvent is a helper to send events to the manager
 func SendEvent(event CallEvent) {
 	select {
 	case Manager.EventChannel <- event:
+		Manager.eventsSent.Add(1)
 	default:
 		// Channel full, drop event
+		Manager.eventsDropped.Add(1)
 	}
 }
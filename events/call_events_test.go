@@ -0,0 +1,36 @@
+package events
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRecentEventsWrapsAround(t *testing.T) {
+	em := &EventManager{
+		activeCalls: make(map[string]*ActiveCall),
+		history:     make([]CallEvent, 0, HistorySize),
+	}
+
+	if got := em.RecentEvents(10); len(got) != 0 {
+		t.Fatalf("expected no events, got %d", len(got))
+	}
+
+	for i := 0; i < HistorySize+5; i++ {
+		em.processEvent(CallEvent{CallID: fmt.Sprintf("call_%d", i), EventType: "dtmf_received"})
+	}
+
+	recent := em.RecentEvents(3)
+	for i, want := range []string{"call_504", "call_503", "call_502"} {
+		if recent[i].CallID != want {
+			t.Errorf("event %d: got %s, want %s", i, recent[i].CallID, want)
+		}
+	}
+
+	all := em.RecentEvents(0)
+	if len(all) != HistorySize {
+		t.Fatalf("expected %d events, got %d", HistorySize, len(all))
+	}
+	if oldest := all[len(all)-1].CallID; oldest != "call_5" {
+		t.Errorf("oldest event: got %s, want call_5", oldest)
+	}
+}
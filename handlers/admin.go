@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"o-dan-go/config"
+	"o-dan-go/events"
+	"o-dan-go/services"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler handles administrative API routes
+type AdminHandler struct {
+	reloader  *config.Reloader
+	startTime time.Time
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(reloader *config.Reloader) *AdminHandler {
+	return &AdminHandler{
+		reloader:  reloader,
+		startTime: time.Now(),
+	}
+}
+
+// AdminAuth requires "Authorization: Bearer <ADMIN_TOKEN>" on admin routes.
+// Without a configured token, admin routes are only open in development.
+func AdminAuth(token string, allowWithoutToken bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			if allowWithoutToken {
+				c.Next()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Admin API is disabled: ADMIN_TOKEN is not configured",
+			})
+			return
+		}
+
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid admin token",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ReloadConfig re-reads reloadable settings without restarting the server
+func (ah *AdminHandler) ReloadConfig(c *gin.Context) {
+	cfg, err := ah.reloader.Reload()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Reload failed, keeping current configuration: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "reloaded",
+		"reloaded_at": ah.reloader.LastReload(),
+		"settings":    reloadableSettings(cfg),
+	})
+}
+
+// GetConfig returns the current reloadable settings
+func (ah *AdminHandler) GetConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"last_reload": ah.reloader.LastReload(),
+		"settings":    reloadableSettings(ah.reloader.Current()),
+	})
+}
+
+// reloadableSettings lists the settings that take effect on reload
+func reloadableSettings(cfg *config.Config) gin.H {
+	return gin.H{
+		"results_ttl":           cfg.ResultsTTL.String(),
+		"debug_logging":         cfg.DebugLogging,
+		"ivr_flows_dir":         cfg.IVRFlowsDir,
+		"ivr_voice":             cfg.IVRVoice,
+		"ivr_language":          cfg.IVRLanguage,
+		"ivr_speaking_rate":     cfg.IVRSpeakingRate,
+		"ivr_prompts_file":      cfg.IVRPromptsFile,
+		"ivr_audio_base_url":    cfg.IVRAudioBaseURL,
+		"ivr_speech_enabled":    cfg.IVRSpeechEnabled,
+		"ivr_speech_confidence": cfg.IVRSpeechConfidence,
+		"area_codes_file":       cfg.AreaCodesFile,
+		"zip_codes_file":        cfg.ZIPCodesFile,
+	}
+}
+
+// GetRuntime returns a summary of process, results store, discovery, event and cache state
+func (ah *AdminHandler) GetRuntime(c *gin.Context) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	c.JSON(http.StatusOK, gin.H{
+		"uptime":     time.Since(ah.startTime).Round(time.Second).String(),
+		"go_version": runtime.Version(),
+		"goroutines": runtime.NumGoroutine(),
+		"memory": gin.H{
+			"alloc_bytes":       mem.Alloc,
+			"total_alloc_bytes": mem.TotalAlloc,
+			"sys_bytes":         mem.Sys,
+			"num_gc":            mem.NumGC,
+		},
+		"results_store": gin.H{
+			"count": services.GlobalResultsStore.Count(),
+			"ttl":   services.GlobalResultsStore.TTL().String(),
+		},
+		"active_discoveries": len(services.GlobalDiscoveryTracker.GetActive()),
+		"events":             events.Manager.Stats(),
+		"caches":             services.GeoCacheStatsAll(),
+	})
+}
+
+// GetResults lists cached discovery results with counts and approximate sizes
+func (ah *AdminHandler) GetResults(c *gin.Context) {
+	stats := services.GlobalResultsStore.Stats()
+
+	totalBytes := 0
+	for _, stat := range stats {
+		totalBytes += stat.SizeBytes
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":       len(stats),
+		"total_bytes": totalBytes,
+		"ttl":         services.GlobalResultsStore.TTL().String(),
+		"sessions":    stats,
+	})
+}
+
+// DeleteResult evicts a single cached session
+func (ah *AdminHandler) DeleteResult(c *gin.Context) {
+	sessionID := c.Param("session_id")
+
+	if _, exists := services.GlobalResultsStore.Get(sessionID); !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Session not found or expired",
+		})
+		return
+	}
+
+	services.GlobalResultsStore.Delete(sessionID)
+	log.Printf("[Admin] Evicted cached session %s", sessionID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "deleted",
+		"session_id": sessionID,
+	})
+}
+
+// ClearResults evicts every cached session
+func (ah *AdminHandler) ClearResults(c *gin.Context) {
+	count := services.GlobalResultsStore.Count()
+	services.GlobalResultsStore.Clear()
+	log.Printf("[Admin] Cleared %d cached sessions", count)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "cleared",
+		"removed": count,
+	})
+}
+
+// GetDiscoveries lists discovery sessions that are still running
+func (ah *AdminHandler) GetDiscoveries(c *gin.Context) {
+	active := services.GlobalDiscoveryTracker.GetActive()
+
+	c.JSON(http.StatusOK, gin.H{
+		"count":    len(active),
+		"sessions": active,
+	})
+}
+
+// GetEventStats returns event manager counters
+func (ah *AdminHandler) GetEventStats(c *gin.Context) {
+	c.JSON(http.StatusOK, events.Manager.Stats())
+}
+
+// GetAreaCodes describes the loaded area code database and the unknown codes callers have used
+func (ah *AdminHandler) GetAreaCodes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"area_codes": services.GlobalAreaCodes.Info(),
+		"stats":      services.GetAreaCodeStats(),
+		"unknown":    services.GlobalAreaCodes.UnknownCodes(),
+		"zip_codes":  services.GlobalZIPCodes.Info(),
+	})
+}
+
+// ReloadAreaCodes re-reads AREA_CODES_FILE and ZIP_CODES_FILE without reloading the rest of the configuration
+func (ah *AdminHandler) ReloadAreaCodes(c *gin.Context) {
+	cfg := ah.reloader.Current()
+	if err := services.GlobalAreaCodes.Load(cfg.AreaCodesFile); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Reload failed, keeping current area codes: %v", err),
+		})
+		return
+	}
+	if err := services.GlobalZIPCodes.Load(cfg.ZIPCodesFile); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Reload failed, keeping current ZIP codes: %v", err),
+		})
+		return
+	}
+
+	log.Printf("[WR] Reloaded area codes from %s, ZIP codes from %s",
+		services.GlobalAreaCodes.Info().Source, services.GlobalZIPCodes.Info().Source)
+	c.JSON(http.StatusOK, gin.H{
+		"status":     "reloaded",
+		"area_codes": services.GlobalAreaCodes.Info(),
+		"zip_codes":  services.GlobalZIPCodes.Info(),
+	})
+}
+
+// GetCacheStats returns hit metrics for the weather and air quality caches
+func (ah *AdminHandler) GetCacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"caches": services.GeoCacheStatsAll()})
+}
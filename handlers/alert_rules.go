@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AlertRulesHandler manages alert rules and lists the alerts they sent
+type AlertRulesHandler struct {
+	db     *services.DatabaseService
+	engine *services.AlertRuleEngine
+}
+
+// NewAlertRulesHandler creates a new alert rules handler
+func NewAlertRulesHandler(db *services.DatabaseService, engine *services.AlertRuleEngine) *AlertRulesHandler {
+	return &AlertRulesHandler{
+		db:     db,
+		engine: engine,
+	}
+}
+
+// GetAlertRules lists every alert rule
+func (ah *AlertRulesHandler) GetAlertRules(c *gin.Context) {
+	rules, err := ah.db.GetAlertRules()
+	if err != nil {
+		log.Printf("[Admin] Failed to load alert rules: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load alert rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules": rules,
+		"count": len(rules),
+	})
+}
+
+// CreateAlertRule stores a rule from the JSON body ({"name", "metric", "operator",
+// "threshold", "domain", "window_days", "cooldown_minutes", "actions", "disabled"})
+func (ah *AlertRulesHandler) CreateAlertRule(c *gin.Context) {
+	var rule services.AlertRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert rule: " + err.Error()})
+		return
+	}
+	rule.ID = 0
+
+	if err := ah.db.SaveAlertRule(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[Admin] Added alert rule %s (%s %s %g)", rule.Name, rule.Metric, rule.Operator, rule.Threshold)
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateAlertRule replaces a rule with the JSON body
+func (ah *AlertRulesHandler) UpdateAlertRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert rule ID"})
+		return
+	}
+	var rule services.AlertRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert rule: " + err.Error()})
+		return
+	}
+	rule.ID = id
+
+	err = ah.db.SaveAlertRule(&rule)
+	if errors.Is(err, services.ErrAlertRuleNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[Admin] Saved alert rule %s", rule.Name)
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteAlertRule removes a rule by ID; the alerts it sent are kept
+func (ah *AlertRulesHandler) DeleteAlertRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert rule ID"})
+		return
+	}
+
+	deleted, err := ah.db.DeleteAlertRule(id)
+	if err != nil {
+		log.Printf("[Admin] Failed to delete alert rule %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "deleted",
+		"id":     id,
+	})
+}
+
+// EvaluateAnalytics checks the analytics rules now instead of waiting for the interval
+func (ah *AlertRulesHandler) EvaluateAnalytics(c *gin.Context) {
+	events, err := ah.engine.EvaluateAnalytics(time.Now())
+	if err != nil {
+		log.Printf("[Admin] Failed to check alert rules: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check alert rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"count":  len(events),
+	})
+}
+
+// GetAlertRuleEvents lists recent alerts, newest first (?rule_id=&limit=50)
+func (ah *AlertRulesHandler) GetAlertRuleEvents(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	ruleID, _ := strconv.ParseInt(c.Query("rule_id"), 10, 64)
+
+	events, err := ah.db.GetAlertRuleEvents(ruleID, limit)
+	if err != nil {
+		log.Printf("[Admin] Failed to load alert rule events: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load alert rule events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"count":  len(events),
+	})
+}
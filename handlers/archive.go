@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ArchiveHandler archives a search session's call recordings
+type ArchiveHandler struct {
+	archiver *services.RecordingArchiver
+}
+
+// NewArchiveHandler creates a new archive handler
+func NewArchiveHandler(archiver *services.RecordingArchiver) *ArchiveHandler {
+	return &ArchiveHandler{
+		archiver: archiver,
+	}
+}
+
+// ArchiveSession starts copying the recordings of a stored search session's CDRs to
+// archive storage; progress is reported by GetArchive
+func (ah *ArchiveHandler) ArchiveSession(c *gin.Context) {
+	sessionID := c.Param("session_id")
+
+	if !ah.archiver.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Recording archive is not configured (ARCHIVE_STORAGE)"})
+		return
+	}
+
+	result, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+
+	run, err := ah.archiver.Begin(sessionID, len(result.AllCDRs))
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	go ah.archiver.ArchiveSession(sessionID, result.AllCDRs)
+
+	c.JSON(http.StatusAccepted, run)
+}
+
+// GetArchive returns a session's latest archive run and its manifest
+func (ah *ArchiveHandler) GetArchive(c *gin.Context) {
+	sessionID := c.Param("session_id")
+
+	recordings, err := ah.archiver.Recordings(sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load archived recordings"})
+		return
+	}
+
+	response := gin.H{"session_id": sessionID, "recordings": recordings}
+	if run, exists := ah.archiver.Run(sessionID); exists {
+		response["run"] = run
+	}
+	c.JSON(http.StatusOK, response)
+}
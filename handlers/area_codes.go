@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultNearbyRadius is the search radius in miles when none is given
+const defaultNearbyRadius = 100
+
+// GetAreaCode returns the location of an area code
+func GetAreaCode(c *gin.Context) {
+	areaCode := c.Param("code")
+	location, exists := services.GlobalAreaCodes.Lookup(areaCode)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Area code not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"area_code":   areaCode,
+		"location":    location,
+		"description": services.GetLocationString(areaCode),
+		"country":     services.GetCountryForAreaCode(areaCode),
+	})
+}
+
+// GetNearbyAreaCodes returns area codes near another area code (?radius=100 miles)
+func GetNearbyAreaCodes(c *gin.Context) {
+	areaCode := c.Param("code")
+	if !services.IsValidAreaCode(areaCode) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Area code not found"})
+		return
+	}
+
+	radius, err := strconv.ParseFloat(c.DefaultQuery("radius", strconv.Itoa(defaultNearbyRadius)), 64)
+	if err != nil || !finite(radius) || radius <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "radius must be a positive number of miles"})
+		return
+	}
+
+	nearby := services.GetNearbyAreaCodes(areaCode, radius)
+	c.JSON(http.StatusOK, gin.H{
+		"area_code":    areaCode,
+		"radius_miles": radius,
+		"count":        len(nearby),
+		"area_codes":   nearby,
+	})
+}
+
+// GetNearestAreaCodes returns the area codes closest to a point (?lat=&lon=&limit=5&radius=miles)
+func GetNearestAreaCodes(c *gin.Context) {
+	lat, latErr := strconv.ParseFloat(c.Query("lat"), 64)
+	lon, lonErr := strconv.ParseFloat(c.Query("lon"), 64)
+	if latErr != nil || lonErr != nil || !finite(lat, lon) || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat and lon must be valid coordinates"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if err != nil || limit <= 0 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+
+	var radius float64
+	if value := c.Query("radius"); value != "" {
+		radius, err = strconv.ParseFloat(value, 64)
+		if err != nil || !finite(radius) || radius <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "radius must be a positive number of miles"})
+			return
+		}
+	}
+
+	nearest := services.NearestAreaCodes(lat, lon, limit, radius)
+	c.JSON(http.StatusOK, gin.H{
+		"lat":        lat,
+		"lon":        lon,
+		"count":      len(nearest),
+		"area_codes": nearest,
+	})
+}
+
+// finite reports whether none of the values is NaN or infinite, which ParseFloat accepts
+// and range checks let through
+func finite(values ...float64) bool {
+	for _, value := range values {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetNearestAreaCodesRejectsNonFiniteCoordinates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/nearest", GetNearestAreaCodes)
+	router.GET("/nearby/:code", GetNearbyAreaCodes)
+
+	for query, want := range map[string]int{
+		"/nearest?lat=40.7&lon=-74.0":               http.StatusOK,
+		"/nearest?lat=NaN&lon=-74.0":                http.StatusBadRequest,
+		"/nearest?lat=40.7&lon=nan":                 http.StatusBadRequest,
+		"/nearest?lat=Inf&lon=-74.0":                http.StatusBadRequest,
+		"/nearest?lat=40.7&lon=-Inf":                http.StatusBadRequest,
+		"/nearest?lat=91&lon=-74.0":                 http.StatusBadRequest,
+		"/nearest?lat=40.7&lon=-74.0&radius=NaN":    http.StatusBadRequest,
+		"/nearest?lat=40.7&lon=-74.0&radius=%2BInf": http.StatusBadRequest,
+		"/nearest?lat=40.7&lon=-74.0&radius=25":     http.StatusOK,
+		"/nearby/212?radius=NaN":                    http.StatusBadRequest,
+		"/nearby/212?radius=Infinity":               http.StatusBadRequest,
+	} {
+		req := httptest.NewRequest(http.MethodGet, query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("%s = %d, want %d: %s", query, w.Code, want, w.Body.String())
+		}
+	}
+}
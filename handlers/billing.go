@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxInvoiceUploadBytes bounds the size of an uploaded invoice CSV
+const maxInvoiceUploadBytes = 64 << 20
+
+// BillingHandler imports carrier invoices and reconciles them with searches
+type BillingHandler struct {
+	db         *services.DatabaseService
+	reconciler *services.BillingReconciler
+}
+
+// NewBillingHandler creates a new billing handler
+func NewBillingHandler(db *services.DatabaseService, reconciler *services.BillingReconciler) *BillingHandler {
+	return &BillingHandler{
+		db:         db,
+		reconciler: reconciler,
+	}
+}
+
+// ImportInvoice imports an invoice CSV, uploaded as the "file" form field or sent as the
+// request body (?carrier=&timezone=UTC)
+func (bh *BillingHandler) ImportInvoice(c *gin.Context) {
+	carrier := strings.TrimSpace(c.Query("carrier"))
+	if carrier == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "carrier is required"})
+		return
+	}
+	loc, err := time.LoadLocation(c.DefaultQuery("timezone", "UTC"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid timezone"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxInvoiceUploadBytes)
+	var body io.Reader = c.Request.Body
+	filename := ""
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the invoice CSV as the file field"})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the uploaded invoice"})
+			return
+		}
+		defer file.Close()
+		body, filename = file, header.Filename
+	}
+
+	invoice, skipped, err := bh.reconciler.ImportInvoice(carrier, filename, body, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "skipped": skipped})
+		return
+	}
+	log.Printf("[Admin] Imported %s invoice %d with %d lines (%d skipped)", carrier, invoice.ID, invoice.Lines, len(skipped))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"invoice": invoice,
+		"skipped": skipped,
+	})
+}
+
+// GetInvoices lists imported invoices, newest first (?limit=50)
+func (bh *BillingHandler) GetInvoices(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	invoices, err := bh.db.GetInvoices(limit)
+	if err != nil {
+		log.Printf("[Admin] Failed to load invoices: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invoices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invoices": invoices,
+		"count":    len(invoices),
+	})
+}
+
+// DeleteInvoice removes an invoice and its lines
+func (bh *BillingHandler) DeleteInvoice(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+
+	deleted, err := bh.db.DeleteInvoice(id)
+	if err != nil {
+		log.Printf("[Admin] Failed to delete invoice %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete invoice"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "deleted",
+		"id":     id,
+	})
+}
+
+// Reconcile compares an invoice with the CDRs of a stored search (?format=json|csv)
+func (bh *BillingHandler) Reconcile(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invoice ID"})
+		return
+	}
+	sessionID := c.Param("session_id")
+	result, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+
+	report, err := bh.reconciler.Reconcile(id, sessionID, result.AllCDRs)
+	if err != nil {
+		log.Printf("[Admin] Reconciling invoice %d with %s failed: %v", id, sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Reconciliation failed"})
+		return
+	}
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invoice not found"})
+		return
+	}
+	log.Printf("[Admin] Reconciled invoice %d with %s: %d matched, %d discrepancies", id, sessionID, report.Matched, len(report.Discrepancies))
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, report)
+	case "csv":
+		exportDiscrepanciesCSV(c, report)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+	}
+}
+
+// exportDiscrepanciesCSV writes a reconciliation's discrepancies as a CSV download
+func exportDiscrepanciesCSV(c *gin.Context, report *services.ReconciliationReport) {
+	filename := fmt.Sprintf("reconciliation_%d_%s.csv", report.Invoice.ID, report.SessionID)
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	c.Writer.Write([]byte("type,invoice_line,number,billed_start,billed_seconds,cdr_id,cdr_start,cdr_seconds,difference_seconds,amount\n"))
+	for _, discrepancy := range report.Discrepancies {
+		line, number, billedStart, cdrStart := "", "", "", ""
+		if discrepancy.Line != nil {
+			line = strconv.Itoa(discrepancy.Line.Line)
+			number = discrepancy.Line.Number
+			billedStart = discrepancy.Line.StartedAt.Format(time.RFC3339)
+		}
+		if discrepancy.CDRStart != nil {
+			cdrStart = discrepancy.CDRStart.UTC().Format(time.RFC3339)
+		}
+		fields := []string{
+			discrepancy.Type, line, escapeCSV(number), billedStart, strconv.Itoa(discrepancy.BilledSeconds),
+			escapeCSV(discrepancy.CDRID), cdrStart, strconv.Itoa(discrepancy.CDRSeconds),
+			strconv.Itoa(discrepancy.Difference), strconv.FormatFloat(discrepancy.Amount, 'f', 4, 64),
+		}
+		c.Writer.Write([]byte(strings.Join(fields, ",") + "\n"))
+	}
+}
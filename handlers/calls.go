@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CallsHandler places and controls PBX calls from the dashboard
+type CallsHandler struct {
+	calls *services.CallControlService
+}
+
+// NewCallsHandler creates a new calls handler
+func NewCallsHandler(calls *services.CallControlService) *CallsHandler {
+	return &CallsHandler{
+		calls: calls,
+	}
+}
+
+// PlaceCall connects two numbers through the PBX: the from number (or the user's own
+// phones) rings first, then the call goes out to the to number
+func (ch *CallsHandler) PlaceCall(c *gin.Context) {
+	var req services.OriginateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid call request: " + err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	call, err := ch.calls.Originate(req)
+	if err != nil {
+		log.Printf("[Calls] Failed to place call to %s as %s@%s: %v", req.To, req.User, req.Domain, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to place call"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, call)
+}
+
+// HangUp ends a PBX or click-to-call call
+func (ch *CallsHandler) HangUp(c *gin.Context) {
+	ch.respond(c, "hang up", ch.calls.HangUp(c.Param("call_id")))
+}
+
+// Hold puts a PBX or click-to-call call on hold
+func (ch *CallsHandler) Hold(c *gin.Context) {
+	ch.respond(c, "hold", ch.calls.Hold(c.Param("call_id")))
+}
+
+// Resume takes a call off hold
+func (ch *CallsHandler) Resume(c *gin.Context) {
+	ch.respond(c, "resume", ch.calls.Resume(c.Param("call_id")))
+}
+
+// Transfer moves a call to the number or extension in {"to": "..."}
+func (ch *CallsHandler) Transfer(c *gin.Context) {
+	var req services.TransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer request: " + err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ch.respond(c, "transfer", ch.calls.Transfer(c.Param("call_id"), req))
+}
+
+// respond reports the outcome of a call control action
+func (ch *CallsHandler) respond(c *gin.Context, action string, err error) {
+	callID := c.Param("call_id")
+	switch {
+	case errors.Is(err, services.ErrCallNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Call not found or can't be controlled"})
+	case err != nil:
+		log.Printf("[Calls] Failed to %s call %s: %v", action, callID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to " + action + " call"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"call_id": callID, "action": action})
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/models"
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCDRDetail returns one CDR of a session with every raw field, the endpoints that
+// returned it, the other legs of its call, its enrichment, notes and flag, for the
+// results page's detail drawer. ?merged=true shows the merged record and where each
+// field came from, and ?view=normalized the normalized CDR in place of its raw fields;
+// masking applies to phone numbers.
+func (sh *StoredSessionsHandler) GetCDRDetail(c *gin.Context) {
+	sessionID, cdrID := sessionParam(c), c.Param("cdr_id")
+	view, err := services.ParseCDRView(c.Query("view"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	result, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+	if c.Query("merged") == "true" {
+		result = result.Merged()
+	}
+
+	policy, maskMode := maskingFromContext(c)
+	detail, found := services.BuildCDRDetail(result, cdrID, policy, maskMode)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "CDR not found in session"})
+		return
+	}
+	if view == services.CDRViewNormalized {
+		normalized := services.NormalizeCDR(models.FlexibleCDR{RawData: detail.Raw})
+		detail.Normalized, detail.Fields, detail.Raw = &normalized, nil, nil
+	}
+
+	score, err := sh.db.GetSpamScore(cdrID)
+	if err != nil {
+		log.Printf("[Sessions] Failed to load spam score of %s: %v", cdrID, err)
+	} else if score != nil {
+		score.Caller, score.Called = policy.MaskValue(score.Caller, maskMode), policy.MaskValue(score.Called, maskMode)
+		detail.Enrichment.SpamScore = score
+	}
+	if detail.Notes, err = sh.db.GetSessionNotes(sessionID, cdrID); err != nil {
+		log.Printf("[Sessions] Failed to load notes of %s: %v", cdrID, err)
+		detail.Notes = []services.SessionNote{}
+	}
+	if flags, err := sh.db.GetCDRFlags(sessionID); err != nil {
+		log.Printf("[Sessions] Failed to load flags of %s: %v", sessionID, err)
+	} else {
+		for i := range flags {
+			if flags[i].CDRID == cdrID {
+				detail.Flag = &flags[i]
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
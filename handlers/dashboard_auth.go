@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+)
+
+const dashboardSessionName = "wr-dashboard"
+
+// DashboardAuth protects the Web Responder dashboard, its data routes and WebSocket.
+// Browsers sign in once with the dashboard token and get a signed session cookie;
+// API clients can send "Authorization: Bearer <token>" instead.
+type DashboardAuth struct {
+	token             string
+	allowWithoutToken bool
+	store             *sessions.CookieStore
+	allowedOrigins    map[string]bool
+	allowAnyOrigin    bool
+}
+
+// NewDashboardAuth creates dashboard auth. Without a token the dashboard is only open when
+// allowWithoutToken is set (development). allowedOrigins is a comma-separated list of WebSocket
+// origins; empty allows same-origin connections only.
+func NewDashboardAuth(token, sessionSecret string, allowWithoutToken bool, allowedOrigins string) *DashboardAuth {
+	store := sessions.NewCookieStore([]byte(sessionSecret))
+	store.Options = &sessions.Options{
+		Path:     "/",
+		MaxAge:   12 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	auth := &DashboardAuth{
+		token:             token,
+		allowWithoutToken: allowWithoutToken,
+		store:             store,
+		allowedOrigins:    make(map[string]bool),
+	}
+	for _, origin := range strings.Split(allowedOrigins, ",") {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		switch origin {
+		case "":
+		case "*":
+			auth.allowAnyOrigin = true
+		default:
+			auth.allowedOrigins[strings.ToLower(origin)] = true
+		}
+	}
+
+	return auth
+}
+
+// Middleware requires a signed-in session or bearer token
+func (da *DashboardAuth) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if da.token == "" {
+			if da.allowWithoutToken {
+				c.Next()
+				return
+			}
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "Dashboard is disabled: DASHBOARD_TOKEN (or ADMIN_TOKEN) is not configured",
+			})
+			return
+		}
+
+		if da.authenticated(c) {
+			c.Next()
+			return
+		}
+
+		// Send people opening a page to the sign-in form; everything else gets a 401
+		if c.Request.Method == http.MethodGet && c.GetHeader("Upgrade") == "" &&
+			strings.Contains(c.GetHeader("Accept"), "text/html") {
+			c.Redirect(http.StatusFound, "/wr/login?next="+url.QueryEscape(c.Request.URL.RequestURI()))
+			c.Abort()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "Dashboard authentication required",
+		})
+	}
+}
+
+// authenticated checks the bearer token, then the session cookie
+func (da *DashboardAuth) authenticated(c *gin.Context) bool {
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return da.validToken(strings.TrimPrefix(header, "Bearer "))
+	}
+
+	session, err := da.store.Get(c.Request, dashboardSessionName)
+	if err != nil {
+		return false
+	}
+	// Sessions are tied to the token they signed in with, so changing the token signs everyone out
+	tokenHash, _ := session.Values["token_hash"].(string)
+	return subtle.ConstantTimeCompare([]byte(tokenHash), []byte(da.tokenHash())) == 1
+}
+
+func (da *DashboardAuth) validToken(provided string) bool {
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(da.token)) == 1
+}
+
+func (da *DashboardAuth) tokenHash() string {
+	sum := sha256.Sum256([]byte(da.token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ShowLogin displays the dashboard sign-in form
+func (da *DashboardAuth) ShowLogin(c *gin.Context) {
+	c.HTML(http.StatusOK, "wr_login.html", gin.H{
+		"title": "Web Responder Dashboard",
+		"next":  safeNext(c.Query("next")),
+	})
+}
+
+// Login checks the submitted token and starts a dashboard session
+func (da *DashboardAuth) Login(c *gin.Context) {
+	next := safeNext(c.PostForm("next"))
+
+	if da.token == "" || !da.validToken(c.PostForm("token")) {
+		log.Printf("[WR] Dashboard sign-in failed from %s", c.ClientIP())
+		c.HTML(http.StatusUnauthorized, "wr_login.html", gin.H{
+			"title": "Web Responder Dashboard",
+			"next":  next,
+			"error": "Invalid token",
+		})
+		return
+	}
+
+	session, _ := da.store.New(c.Request, dashboardSessionName)
+	session.Values["token_hash"] = da.tokenHash()
+	if err := session.Save(c.Request, c.Writer); err != nil {
+		c.String(http.StatusInternalServerError, "Session error")
+		return
+	}
+
+	c.Redirect(http.StatusFound, next)
+}
+
+// Logout ends the dashboard session
+func (da *DashboardAuth) Logout(c *gin.Context) {
+	session, _ := da.store.Get(c.Request, dashboardSessionName)
+	session.Options.MaxAge = -1
+	session.Save(c.Request, c.Writer)
+
+	c.Redirect(http.StatusFound, "/wr/login")
+}
+
+// CheckOrigin allows WebSocket upgrades from the same origin or a configured allowed origin
+func (da *DashboardAuth) CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || da.allowAnyOrigin {
+		// Non-browser clients don't send an Origin
+		return true
+	}
+
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(parsed.Host, r.Host) || da.allowedOrigins[strings.ToLower(strings.TrimRight(origin, "/"))] {
+		return true
+	}
+
+	log.Printf("[WR] Rejected dashboard WebSocket from origin %s", origin)
+	return false
+}
+
+// safeNext keeps post-login redirects on this site
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/wr/dashboard"
+	}
+	return next
+}
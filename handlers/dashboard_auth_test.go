@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"o-dan-go/events"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestDashboardAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(auth *DashboardAuth) *gin.Engine {
+		router := gin.New()
+		router.LoadHTMLGlob("../templates/*")
+		router.POST("/wr/login", auth.Login)
+		router.GET("/wr/data", auth.Middleware(), func(c *gin.Context) { c.String(http.StatusOK, "data") })
+		return router
+	}
+	get := func(router *gin.Engine, headers map[string]string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/wr/data", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	login := func(router *gin.Engine, token string) *httptest.ResponseRecorder {
+		form := url.Values{"token": {token}, "next": {"/wr/data"}}
+		req := httptest.NewRequest(http.MethodPost, "/wr/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	router := newRouter(NewDashboardAuth("dash-token", "secret", false, ""))
+	if w := get(router, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("no credentials = %d, want 401", w.Code)
+	}
+	if w := get(router, map[string]string{"Accept": "text/html"}); w.Code != http.StatusFound || w.Header().Get("Location") != "/wr/login?next=%2Fwr%2Fdata" {
+		t.Errorf("page without credentials = %d to %q, want a redirect to sign in", w.Code, w.Header().Get("Location"))
+	}
+	if w := get(router, map[string]string{"Authorization": "Bearer wrong-token"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong bearer token = %d, want 401", w.Code)
+	}
+	if w := get(router, map[string]string{"Authorization": "Bearer dash-token"}); w.Code != http.StatusOK {
+		t.Errorf("bearer token = %d, want 200", w.Code)
+	}
+
+	// Signing in sets a session cookie that stands in for the token
+	if w := login(router, "wrong-token"); w.Code != http.StatusUnauthorized || len(w.Result().Cookies()) != 0 {
+		t.Errorf("sign-in with a wrong token = %d with cookies %v, want 401 and none", w.Code, w.Result().Cookies())
+	}
+	signedIn := login(router, "dash-token")
+	cookies := signedIn.Result().Cookies()
+	if signedIn.Code != http.StatusFound || signedIn.Header().Get("Location") != "/wr/data" || len(cookies) != 1 {
+		t.Fatalf("sign-in = %d to %q with cookies %v, want a session and a redirect back", signedIn.Code, signedIn.Header().Get("Location"), cookies)
+	}
+	if w := get(router, nil, cookies[0]); w.Code != http.StatusOK {
+		t.Errorf("session cookie = %d, want 200", w.Code)
+	}
+	if w := get(router, map[string]string{"Authorization": "Bearer wrong-token"}, cookies[0]); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong bearer token with a session = %d, want the token checked", w.Code)
+	}
+	forged := *cookies[0]
+	forged.Value = strings.ToUpper(forged.Value)
+	if w := get(router, nil, &forged); w.Code != http.StatusUnauthorized {
+		t.Errorf("tampered session cookie = %d, want 401", w.Code)
+	}
+
+	// Changing the token signs existing sessions out
+	if w := get(newRouter(NewDashboardAuth("new-token", "secret", false, "")), nil, cookies[0]); w.Code != http.StatusUnauthorized {
+		t.Errorf("session from the old token = %d, want 401", w.Code)
+	}
+
+	// Without a token the dashboard is closed, unless it is allowed open for development
+	if w := get(newRouter(NewDashboardAuth("", "secret", false, "")), nil); w.Code != http.StatusForbidden {
+		t.Errorf("no token configured = %d, want 403", w.Code)
+	}
+	if w := get(newRouter(NewDashboardAuth("", "secret", true, "")), nil); w.Code != http.StatusOK {
+		t.Errorf("no token in development = %d, want 200", w.Code)
+	}
+}
+
+func TestDashboardWebSocketRejectsCrossOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := NewDashboardAuth("dash-token", "secret", false, "https://ops.example.com/")
+	handler := &WRDashboardHandler{hub: newDashboardHub(), upgrader: websocket.Upgrader{CheckOrigin: auth.CheckOrigin}}
+	go handler.hub.run(make(chan events.CallEvent))
+
+	router := gin.New()
+	router.GET("/ws", handler.HandleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	for origin, allowed := range map[string]bool{
+		"":                         true,
+		server.URL:                 true,
+		"https://ops.example.com":  true,
+		"https://evil.example.com": false,
+		"null":                     false,
+	} {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if conn != nil {
+			conn.Close()
+		}
+		if allowed && err != nil {
+			t.Errorf("origin %q: %v, want the connection accepted", origin, err)
+		}
+		if !allowed && (err == nil || resp == nil || resp.StatusCode != http.StatusForbidden) {
+			t.Errorf("origin %q connected (%v), want it rejected with 403", origin, err)
+		}
+	}
+}
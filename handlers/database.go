@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DatabaseHandler reports database size and growth and runs maintenance on demand
+type DatabaseHandler struct {
+	maintainer *services.DatabaseMaintainer
+}
+
+// NewDatabaseHandler creates a new database handler
+func NewDatabaseHandler(maintainer *services.DatabaseMaintainer) *DatabaseHandler {
+	return &DatabaseHandler{
+		maintainer: maintainer,
+	}
+}
+
+// GetDatabaseStats returns the database size, table row counts and their growth over the
+// snapshots of the last days (?days=30)
+func (dh *DatabaseHandler) GetDatabaseStats(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days <= 0 || days > 366 {
+		days = 30
+	}
+
+	report, err := dh.maintainer.Report(days)
+	if err != nil {
+		log.Printf("[Admin] Failed to report database stats: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read database stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// RunMaintenance runs database maintenance now
+func (dh *DatabaseHandler) RunMaintenance(c *gin.Context) {
+	run, err := dh.maintainer.Run()
+	if run == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("[Admin] Database maintenance failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database maintenance failed", "run": run})
+		return
+	}
+	log.Printf("[Admin] Database maintenance took %dms, size %d -> %d bytes", run.DurationMS, run.SizeBeforeBytes, run.SizeAfterBytes)
+
+	c.JSON(http.StatusOK, run)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeviceHandler serves device registration reports
+type DeviceHandler struct {
+	devices *services.DeviceService
+}
+
+// NewDeviceHandler creates a new device handler
+func NewDeviceHandler(devices *services.DeviceService) *DeviceHandler {
+	return &DeviceHandler{
+		devices: devices,
+	}
+}
+
+// GetDevices reports which devices are registered for each user in the domain, with the
+// failed calls each user received (?user= for one user, ?hours=24 for the failure window)
+func (dh *DeviceHandler) GetDevices(c *gin.Context) {
+	domain := c.Param("domain")
+
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil || hours <= 0 || hours > 24*31 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hours must be between 1 and 744"})
+		return
+	}
+
+	report, err := dh.devices.GetDeviceReport(domain, c.Query("user"), time.Duration(hours)*time.Hour)
+	if err != nil {
+		log.Printf("[Devices] Failed to load devices for %s: %v", domain, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to load devices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetEndpoints lists the NetSapiens device endpoints the report uses
+func (dh *DeviceHandler) GetEndpoints(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"endpoints": dh.devices.GetSupportedEndpoints()})
+}
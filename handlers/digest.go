@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DigestHandler previews and sends the daily digest
+type DigestHandler struct {
+	digester *services.Digester
+}
+
+// NewDigestHandler creates a new digest handler
+func NewDigestHandler(digester *services.Digester) *DigestHandler {
+	return &DigestHandler{
+		digester: digester,
+	}
+}
+
+// GetDigest builds a day's digest without sending it (?day=YYYY-MM-DD, default yesterday)
+func (dh *DigestHandler) GetDigest(c *gin.Context) {
+	day, ok := digestDay(c)
+	if !ok {
+		return
+	}
+
+	digest, err := dh.digester.Build(day)
+	if err != nil {
+		log.Printf("[Admin] Failed to build the digest: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build the digest"})
+		return
+	}
+
+	c.JSON(http.StatusOK, digest)
+}
+
+// SendDigest sends a day's digest now, even if it was sent already (?day=YYYY-MM-DD)
+func (dh *DigestHandler) SendDigest(c *gin.Context) {
+	day, ok := digestDay(c)
+	if !ok {
+		return
+	}
+
+	digest, err := dh.digester.Send(day)
+	if digest == nil {
+		log.Printf("[Admin] Failed to build the digest: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build the digest"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "sent",
+		"digest": digest,
+	})
+}
+
+// digestDay reads the day parameter, writing the error response when it's invalid
+func digestDay(c *gin.Context) (time.Time, bool) {
+	value := c.Query("day")
+	if value == "" {
+		return time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1), true
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "day must be a date like 2024-01-31"})
+		return time.Time{}, false
+	}
+	return day, true
+}
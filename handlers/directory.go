@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DirectoryHandler lists domains, users and sites for the search form's dropdowns
+type DirectoryHandler struct {
+	directory *services.DirectoryService
+}
+
+// NewDirectoryHandler creates a new directory handler
+func NewDirectoryHandler(directory *services.DirectoryService) *DirectoryHandler {
+	return &DirectoryHandler{
+		directory: directory,
+	}
+}
+
+// GetDomains lists every domain on the PBX
+func (dh *DirectoryHandler) GetDomains(c *gin.Context) {
+	domains, err := dh.directory.GetDomains()
+	if err != nil {
+		log.Printf("[Directory] Failed to list domains: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to list domains"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"domains": domains})
+}
+
+// GetUsers lists the users in a domain
+func (dh *DirectoryHandler) GetUsers(c *gin.Context) {
+	domain := c.Param("domain")
+
+	users, err := dh.directory.GetUsers(domain)
+	if err != nil {
+		log.Printf("[Directory] Failed to list users in %s: %v", domain, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to list users"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"domain": domain, "users": users})
+}
+
+// GetSites lists the sites in a domain
+func (dh *DirectoryHandler) GetSites(c *gin.Context) {
+	domain := c.Param("domain")
+
+	sites, err := dh.directory.GetSites(domain)
+	if err != nil {
+		log.Printf("[Directory] Failed to list sites in %s: %v", domain, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to list sites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"domain": domain, "sites": sites})
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DiscoveryAnalyticsHandler serves and resets the statistics of how endpoints answer searches
+type DiscoveryAnalyticsHandler struct {
+	analytics *services.DiscoveryAnalytics
+}
+
+// NewDiscoveryAnalyticsHandler creates a new discovery analytics handler
+func NewDiscoveryAnalyticsHandler(analytics *services.DiscoveryAnalytics) *DiscoveryAnalyticsHandler {
+	return &DiscoveryAnalyticsHandler{
+		analytics: analytics,
+	}
+}
+
+// discoveryAnalyticsFilter reads ?base_url=, ?domain= and ?endpoint=
+func discoveryAnalyticsFilter(c *gin.Context) services.DiscoveryAnalyticsFilter {
+	return services.DiscoveryAnalyticsFilter{
+		BaseURL:  c.Query("base_url"),
+		Domain:   c.Query("domain"),
+		Endpoint: c.Query("endpoint"),
+	}
+}
+
+// GetTopCombinations lists endpoint and parameter combinations, best performing first.
+// ?min_queries= (default 1) leaves out combinations tried fewer times; ?limit= defaults to 20.
+func (dah *DiscoveryAnalyticsHandler) GetTopCombinations(c *gin.Context) {
+	minQueries, err := strconv.Atoi(c.DefaultQuery("min_queries", "1"))
+	if err != nil || minQueries < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_queries must be a positive number"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+
+	combinations, err := dah.analytics.TopCombinations(discoveryAnalyticsFilter(c), minQueries, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"combinations": combinations})
+}
+
+// GetEndpointTrends returns each endpoint's daily success over the last ?days= (default 30)
+func (dah *DiscoveryAnalyticsHandler) GetEndpointTrends(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > 366 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 366"})
+		return
+	}
+
+	trends, err := dah.analytics.EndpointTrends(discoveryAnalyticsFilter(c), days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"days": days, "endpoints": trends})
+}
+
+// GetSuggestions suggests criteria that have returned CDRs quickly for domains starting
+// with ?domain=, optionally only on ?base_url=; ?limit= defaults to 5
+func (dah *DiscoveryAnalyticsHandler) GetSuggestions(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+
+	domain := c.Query("domain")
+	suggestions, err := dah.analytics.Suggestions(c.Query("base_url"), domain, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"domain": domain, "suggestions": suggestions})
+}
+
+// ResetAnalytics deletes the statistics matching ?base_url=, ?domain= and ?endpoint=, or
+// all of them
+func (dah *DiscoveryAnalyticsHandler) ResetAnalytics(c *gin.Context) {
+	removed, err := dah.analytics.Reset(discoveryAnalyticsFilter(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"removed": removed})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DomainOverviewHandler serves the per-domain dashboard
+type DomainOverviewHandler struct {
+	db *services.DatabaseService
+}
+
+// NewDomainOverviewHandler creates a new domain overview handler
+func NewDomainOverviewHandler(db *services.DatabaseService) *DomainOverviewHandler {
+	return &DomainOverviewHandler{
+		db: db,
+	}
+}
+
+// GetDomainOverview returns a domain's recent searches, daily volume, top users,
+// failure reasons and KPIs between ?start= and ?end= (the last seven days by default)
+func (dh *DomainOverviewHandler) GetDomainOverview(c *gin.Context) {
+	domain := c.Param("domain")
+	start, end, err := parseKPIDays(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	overview, err := dh.db.GetDomainOverview(domain, start, end)
+	if err != nil {
+		log.Printf("[Domains] Failed to build overview for %s: %v", domain, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build domain overview"})
+		return
+	}
+
+	c.JSON(http.StatusOK, overview)
+}
+
+// ShowDomainOverview renders the domain overview as a page, with the same parameters as
+// GetDomainOverview
+func (dh *DomainOverviewHandler) ShowDomainOverview(c *gin.Context) {
+	domain := c.Param("domain")
+	start, end, err := parseKPIDays(c)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"title": "Domain Overview Error",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	overview, err := dh.db.GetDomainOverview(domain, start, end)
+	if err != nil {
+		log.Printf("[Domains] Failed to build overview for %s: %v", domain, err)
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+			"title": "Domain Overview Error",
+			"error": "Failed to build domain overview",
+		})
+		return
+	}
+
+	// Bars for the volume chart, as a percentage of the busiest day
+	type volumeBar struct {
+		services.DomainVolume
+		Width int
+	}
+	busiest := 1
+	for _, day := range overview.Volume {
+		if day.Calls > busiest {
+			busiest = day.Calls
+		}
+	}
+	bars := make([]volumeBar, 0, len(overview.Volume))
+	for _, day := range overview.Volume {
+		bars = append(bars, volumeBar{DomainVolume: day, Width: 100 * day.Calls / busiest})
+	}
+
+	c.HTML(http.StatusOK, "domain_overview.html", gin.H{
+		"title":    "Domain " + domain,
+		"overview": overview,
+		"volume":   bars,
+	})
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"o-dan-go/models"
+	"o-dan-go/services"
+	"strings"
+	"time"
+)
+
+// exportColumnValue resolves a column for a CDR, evaluating the user's export fields
+// over the raw CDR JSON
+func exportColumnValue(prefs *services.UserPreferences, cdr *models.FlexibleCDR, column, sessionID string, loc *time.Location) string {
+	if field := prefs.ExportField(column); field != nil {
+		return field.Value(cdr)
+	}
+	return cdrColumnValue(cdr, column, sessionID, loc)
+}
+
+// cdrColumnValue resolves an export column name to its display value for a CDR
+func cdrColumnValue(cdr *models.FlexibleCDR, column, sessionID string, loc *time.Location) string {
+	switch column {
+	case "call_id":
+		return cdr.GetID()
+	case "domain":
+		return cdr.GetDomain()
+	case "user":
+		return firstNonEmpty(cdr.GetString("user"), cdr.GetOrigUser())
+	case "orig_number":
+		return firstNonEmpty(cdr.GetString("call-orig-caller-id"), cdr.GetString("orig-number"))
+	case "term_number":
+		return firstNonEmpty(cdr.GetString("call-term-caller-id"), cdr.GetString("term-number"))
+	case "start_time":
+		return formatCDRTime(cdr, loc, "call-start-datetime", "start-time")
+	case "end_time":
+		return formatCDRTime(cdr, loc, "call-end-datetime", "end-time")
+	case "duration":
+		return fmt.Sprintf("%d", cdr.GetCallDuration())
+	case "call_type":
+		return cdr.GetString("call-type")
+	case "direction":
+		return firstNonEmpty(cdr.GetString("call-direction"), cdr.GetString("direction"))
+	case "disposition":
+		return firstNonEmpty(cdr.GetDisconnectReason(), cdr.GetString("disposition"))
+	case "call_class":
+		return services.ClassifyCall(*cdr)
+	case "session_id":
+		return sessionID
+	case "orig_caller_name", "orig_carrier", "orig_line_type", "orig_lrn", "orig_ported",
+		"term_carrier", "term_line_type", "term_lrn", "term_ported":
+		// Added by number enrichment, e.g. orig_lrn reads orig-lrn
+		return cdr.GetString(strings.ReplaceAll(column, "_", "-"))
+	}
+	return ""
+}
+
+// formatCDRTime formats the first parseable time field in the given location,
+// falling back to the raw string when the value can't be parsed
+func formatCDRTime(cdr *models.FlexibleCDR, loc *time.Location, fields ...string) string {
+	for _, field := range fields {
+		if !cdr.HasField(field) {
+			continue
+		}
+		if t, err := cdr.GetTime(field); err == nil {
+			return t.In(loc).Format("2006-01-02 15:04:05 MST")
+		}
+		return cdr.GetString(field)
+	}
+	return ""
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
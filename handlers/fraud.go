@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FraudHandler scans search results for toll fraud and serves the alerts raised
+type FraudHandler struct {
+	db       *services.DatabaseService
+	detector *services.FraudDetector
+}
+
+// NewFraudHandler creates a new fraud handler
+func NewFraudHandler(db *services.DatabaseService, detector *services.FraudDetector) *FraudHandler {
+	return &FraudHandler{
+		db:       db,
+		detector: detector,
+	}
+}
+
+// ScanSession runs the fraud rules over a stored search, returning the new alerts
+func (fh *FraudHandler) ScanSession(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	result, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+
+	alerts, err := fh.detector.Scan(sessionID, result.AllCDRs)
+	if err != nil {
+		log.Printf("[Admin] Fraud scan of %s failed: %v", sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Fraud scan failed"})
+		return
+	}
+	log.Printf("[Admin] Scanned %s for fraud: %d new alerts", sessionID, len(alerts))
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"scanned":    len(result.AllCDRs),
+		"alerts":     alerts,
+		"count":      len(alerts),
+	})
+}
+
+// GetFraudAlerts lists recent alerts without their evidence, newest first (?rule=&limit=50)
+func (fh *FraudHandler) GetFraudAlerts(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	alerts, err := fh.db.GetFraudAlerts(c.Query("rule"), limit)
+	if err != nil {
+		log.Printf("[Admin] Failed to load fraud alerts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load fraud alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"alerts": alerts,
+		"count":  len(alerts),
+	})
+}
+
+// GetFraudAlert returns an alert with its evidence report
+func (fh *FraudHandler) GetFraudAlert(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fraud alert ID"})
+		return
+	}
+
+	alert, err := fh.db.GetFraudAlert(id)
+	if err != nil {
+		log.Printf("[Admin] Failed to load fraud alert %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load fraud alert"})
+		return
+	}
+	if alert == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fraud alert not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
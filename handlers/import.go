@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCDRImportBytes bounds the size of an import request
+const maxCDRImportBytes = 256 << 20
+
+// ImportHandler loads CDR exports into a session as if they had been searched for
+type ImportHandler struct {
+	hooks *SearchHooks
+}
+
+// NewImportHandler creates a new import handler; imported sessions go through the same
+// background processing as web searches
+func NewImportHandler(hooks *SearchHooks) *ImportHandler {
+	return &ImportHandler{hooks: hooks}
+}
+
+// ImportCDRs imports NetSapiens CSV or JSON exports, uploaded as one or more "file" form
+// fields or sent as the request body (?format=csv|json), into a new session
+func (ih *ImportHandler) ImportCDRs(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCDRImportBytes)
+
+	var files []services.CDRImportFile
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		form, err := c.MultipartForm()
+		if err != nil || len(form.File["file"]) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Upload the exports as file fields"})
+			return
+		}
+		for _, header := range form.File["file"] {
+			file, err := header.Open()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read " + header.Filename})
+				return
+			}
+			defer file.Close()
+			format := ""
+			if dot := strings.LastIndex(header.Filename, "."); dot >= 0 {
+				format = strings.ToLower(header.Filename[dot+1:])
+			}
+			files = append(files, services.CDRImportFile{Name: header.Filename, Format: format, Reader: file})
+		}
+	} else {
+		files = append(files, services.CDRImportFile{Name: "request", Format: c.Query("format"), Reader: c.Request.Body})
+	}
+
+	result, err := services.ImportCDRs(files)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ih.hooks.complete(result)
+	log.Printf("[Admin] Imported %d CDRs from %d files into %s", result.UniqueCDRs, len(files), result.SessionID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"session_id":  result.SessionID,
+		"total_cdrs":  result.TotalCDRs,
+		"unique_cdrs": result.UniqueCDRs,
+		"files":       result.EndpointResults,
+		"errors":      result.Errors,
+		"results_url": "/web/results/" + result.SessionID,
+	})
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeywordAlertsHandler manages keyword lists and lists the alerts they raised
+type KeywordAlertsHandler struct {
+	db *services.DatabaseService
+}
+
+// NewKeywordAlertsHandler creates a new keyword alerts handler
+func NewKeywordAlertsHandler(db *services.DatabaseService) *KeywordAlertsHandler {
+	return &KeywordAlertsHandler{
+		db: db,
+	}
+}
+
+// GetKeywordLists lists every keyword list
+func (kh *KeywordAlertsHandler) GetKeywordLists(c *gin.Context) {
+	lists, err := kh.db.GetKeywordLists()
+	if err != nil {
+		log.Printf("[Admin] Failed to load keyword lists: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load keyword lists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"lists": lists,
+		"count": len(lists),
+	})
+}
+
+// CreateKeywordList stores a list from the JSON body ({"name", "keywords", "domain", "disabled"})
+func (kh *KeywordAlertsHandler) CreateKeywordList(c *gin.Context) {
+	var list services.KeywordList
+	if err := c.ShouldBindJSON(&list); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid keyword list: " + err.Error()})
+		return
+	}
+	list.ID = 0
+
+	if err := kh.db.SaveKeywordList(&list); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[Admin] Added keyword list %s (%d keywords)", list.Name, len(list.Keywords))
+
+	c.JSON(http.StatusCreated, list)
+}
+
+// UpdateKeywordList replaces a list with the JSON body
+func (kh *KeywordAlertsHandler) UpdateKeywordList(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid keyword list ID"})
+		return
+	}
+	var list services.KeywordList
+	if err := c.ShouldBindJSON(&list); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid keyword list: " + err.Error()})
+		return
+	}
+	list.ID = id
+
+	err = kh.db.SaveKeywordList(&list)
+	if errors.Is(err, services.ErrKeywordListNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Keyword list not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[Admin] Saved keyword list %s (%d keywords)", list.Name, len(list.Keywords))
+
+	c.JSON(http.StatusOK, list)
+}
+
+// DeleteKeywordList removes a list by ID; alerts it raised are kept
+func (kh *KeywordAlertsHandler) DeleteKeywordList(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid keyword list ID"})
+		return
+	}
+
+	deleted, err := kh.db.DeleteKeywordList(id)
+	if err != nil {
+		log.Printf("[Admin] Failed to delete keyword list %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete keyword list"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Keyword list not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "deleted",
+		"id":     id,
+	})
+}
+
+// GetKeywordAlerts lists recent alerts, newest first (?cdr_id=&limit=50)
+func (kh *KeywordAlertsHandler) GetKeywordAlerts(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	alerts, err := kh.db.GetKeywordAlerts(c.Query("cdr_id"), limit)
+	if err != nil {
+		log.Printf("[Admin] Failed to load keyword alerts: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load keyword alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"alerts": alerts,
+		"count":  len(alerts),
+	})
+}
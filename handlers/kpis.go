@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KPIHandler records call outcomes and serves the telephony KPI reports
+type KPIHandler struct {
+	kpis *services.KPIService
+}
+
+// NewKPIHandler creates a new KPI handler
+func NewKPIHandler(kpis *services.KPIService) *KPIHandler {
+	return &KPIHandler{
+		kpis: kpis,
+	}
+}
+
+// RecordSession records the call outcomes of a stored search
+func (kh *KPIHandler) RecordSession(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	result, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+
+	outcomes, err := kh.kpis.RecordCDRs(result.AllCDRs)
+	if err != nil {
+		log.Printf("[Admin] Recording call outcomes of %s failed: %v", sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Recording call outcomes failed"})
+		return
+	}
+	log.Printf("[Admin] Recorded %d call outcomes from %s for KPIs", len(outcomes), sessionID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"recorded":   len(outcomes),
+	})
+}
+
+// GetKPIs reports ASR, ACD and NER (?domain=&trunk=&start=&end=&group_by=domain,day&format=json|csv)
+func (kh *KPIHandler) GetKPIs(c *gin.Context) {
+	query, err := parseKPIQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	report, err := kh.kpis.Report(query)
+	if err != nil {
+		log.Printf("[KPI] Failed to build KPI report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build KPI report"})
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, report)
+	case "csv":
+		exportKPIsCSV(c, report)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+	}
+}
+
+// GetTrafficReport reports a domain's busy-hour traffic in Erlangs and the trunk channels
+// needed to carry it (?start=&end=&blocking=0.01,0.02,0.05)
+func (kh *KPIHandler) GetTrafficReport(c *gin.Context) {
+	domain := c.Param("domain")
+	start, end, err := parseKPIDays(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	targets := []float64{}
+	for _, value := range strings.Split(c.Query("blocking"), ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		target, err := strconv.ParseFloat(value, 64)
+		if err != nil || target <= 0 || target >= 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "blocking must be probabilities between 0 and 1, e.g. 0.01"})
+			return
+		}
+		targets = append(targets, target)
+	}
+
+	report, err := kh.kpis.TrafficReport(domain, start, end, targets)
+	if err != nil {
+		log.Printf("[KPI] Failed to build traffic report for %s: %v", domain, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build traffic report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetDomainHeatmap counts a domain's recorded calls and connected minutes by weekday and
+// hour, between ?start= and ?end= (the last seven days by default) in ?tz= (UTC by
+// default)
+func (kh *KPIHandler) GetDomainHeatmap(c *gin.Context) {
+	domain := c.Param("domain")
+	start, end, err := parseKPIDays(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	loc, err := requestLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	heatmap, err := kh.kpis.DomainHeatmap(domain, start, end, loc)
+	if err != nil {
+		log.Printf("[KPI] Failed to build heatmap for %s: %v", domain, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build heatmap"})
+		return
+	}
+
+	c.JSON(http.StatusOK, heatmap)
+}
+
+// ShowKPIReport renders the KPI report as a page, with the same parameters as GetKPIs
+func (kh *KPIHandler) ShowKPIReport(c *gin.Context) {
+	query, err := parseKPIQuery(c)
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"title": "KPI Report Error",
+			"error": err.Error(),
+		})
+		return
+	}
+	report, err := kh.kpis.Report(query)
+	if err != nil {
+		log.Printf("[KPI] Failed to build KPI report: %v", err)
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+			"title": "KPI Report Error",
+			"error": "Failed to build KPI report",
+		})
+		return
+	}
+
+	c.HTML(http.StatusOK, "kpi_report.html", gin.H{
+		"title":  "Telephony KPIs",
+		"report": report,
+		"domain": query.Domain,
+		"trunk":  query.Trunk,
+		"csvURL": "/api/v1/kpis?" + withFormat(c, "csv"),
+	})
+}
+
+// exportKPIsCSV writes a KPI report as a CSV download, totals last
+func exportKPIsCSV(c *gin.Context, report *services.KPIReport) {
+	filename := fmt.Sprintf("kpis_%s_%s.csv", report.Start, report.End)
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	c.Writer.Write([]byte("domain,trunk,day,seizures,answered,network_failures,talk_seconds,asr,ner,acd_seconds\n"))
+	totals := report.Totals
+	totals.Domain = "total"
+	for _, row := range append(append([]services.TelephonyKPIs{}, report.Rows...), totals) {
+		fields := []string{
+			escapeCSV(row.Domain), escapeCSV(row.Trunk), row.Day,
+			strconv.Itoa(row.Seizures), strconv.Itoa(row.Answered), strconv.Itoa(row.NetworkFailures),
+			strconv.Itoa(row.TalkSeconds),
+			strconv.FormatFloat(row.ASR, 'f', 1, 64), strconv.FormatFloat(row.NER, 'f', 1, 64),
+			strconv.FormatFloat(row.ACD, 'f', 1, 64),
+		}
+		c.Writer.Write([]byte(strings.Join(fields, ",") + "\n"))
+	}
+}
+
+// parseKPIQuery reads the KPI report parameters, defaulting to the last 7 days by domain and day
+func parseKPIQuery(c *gin.Context) (services.KPIQuery, error) {
+	query := services.KPIQuery{
+		Domain: c.Query("domain"),
+		Trunk:  c.Query("trunk"),
+	}
+	var err error
+	if query.Start, query.End, err = parseKPIDays(c); err != nil {
+		return query, err
+	}
+
+	for _, group := range strings.Split(c.DefaultQuery("group_by", "domain,day"), ",") {
+		switch group = strings.TrimSpace(group); group {
+		case "":
+		case services.KPIGroupDomain, services.KPIGroupTrunk, services.KPIGroupDay:
+			query.GroupBy = append(query.GroupBy, group)
+		default:
+			return query, fmt.Errorf("group_by may only include domain, trunk and day")
+		}
+	}
+	return query, nil
+}
+
+// parseKPIDays reads ?start= and ?end=, defaulting to the last 7 days
+func parseKPIDays(c *gin.Context) (time.Time, time.Time, error) {
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	if value := c.Query("end"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("end must be a date like 2006-01-02")
+		}
+		end = parsed
+	}
+	start := end.AddDate(0, 0, -6)
+	if value := c.Query("start"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("start must be a date like 2006-01-02")
+		}
+		start = parsed
+	}
+	if start.After(end) || end.Sub(start) > 366*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("start must be before end and at most a year earlier")
+	}
+	return start, end, nil
+}
+
+// withFormat is the request's query string with format set
+func withFormat(c *gin.Context, format string) string {
+	values := c.Request.URL.Query()
+	values.Set("format", format)
+	return values.Encode()
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	roleTokenHeader      = "X-Role-Token"
+	maskingPolicyContext = "masking_policy"
+	maskingModeContext   = "masking_mode"
+)
+
+// ResolveMasking is middleware that determines the PII masking mode for a request.
+// The role comes from the role token the request presents, never from what the client
+// says it is; without one the default mode applies. The role's mode is the floor; a
+// ?mask= parameter can only make masking stricter.
+func ResolveMasking(policy *services.MaskingPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mode := policy.ModeForRole(policy.RoleForToken(c.GetHeader(roleTokenHeader)))
+
+		if requested := c.Query("mask"); requested != "" {
+			if requestedMode, err := services.ParseMaskingMode(requested); err == nil {
+				mode = services.StricterMaskingMode(mode, requestedMode)
+			}
+		}
+
+		c.Set(maskingPolicyContext, policy)
+		c.Set(maskingModeContext, mode)
+		c.Next()
+	}
+}
+
+// maskingFromContext returns the policy and mode set by ResolveMasking
+func maskingFromContext(c *gin.Context) (*services.MaskingPolicy, services.MaskingMode) {
+	policyValue, exists := c.Get(maskingPolicyContext)
+	if !exists {
+		return &services.MaskingPolicy{DefaultMode: services.MaskingNone}, services.MaskingNone
+	}
+
+	policy, _ := policyValue.(*services.MaskingPolicy)
+	mode, _ := c.MustGet(maskingModeContext).(services.MaskingMode)
+	return policy, mode
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"o-dan-go/services"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResolveMaskingIgnoresClientRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policy, err := services.NewMaskingPolicy("hash", "admin:none", "salt")
+	if err != nil {
+		t.Fatalf("NewMaskingPolicy: %v", err)
+	}
+	if err := policy.BindRoleTokens("admin:s3cret"); err != nil {
+		t.Fatalf("BindRoleTokens: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/mode", ResolveMasking(policy), func(c *gin.Context) {
+		_, mode := maskingFromContext(c)
+		c.String(http.StatusOK, string(mode))
+	})
+	modeFor := func(headers map[string]string, query string) string {
+		req := httptest.NewRequest(http.MethodGet, "/mode"+query, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	// Claiming a role, or naming it in place of its token, doesn't lower masking
+	for _, headers := range []map[string]string{
+		{},
+		{"X-User-Role": "admin"},
+		{roleTokenHeader: "admin"},
+		{roleTokenHeader: "wrong"},
+	} {
+		if mode := modeFor(headers, ""); mode != string(services.MaskingHash) {
+			t.Errorf("headers %v got masking %q, want hash", headers, mode)
+		}
+	}
+	if mode := modeFor(nil, "?mask=none"); mode != string(services.MaskingHash) {
+		t.Errorf("?mask=none got masking %q, want hash", mode)
+	}
+
+	if mode := modeFor(map[string]string{roleTokenHeader: "s3cret"}, ""); mode != string(services.MaskingNone) {
+		t.Errorf("admin token got masking %q, want none", mode)
+	}
+	if mode := modeFor(map[string]string{roleTokenHeader: "s3cret"}, "?mask=truncate"); mode != string(services.MaskingTruncate) {
+		t.Errorf("admin token with ?mask=truncate got masking %q", mode)
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParquetExportHandler writes sessions and stored CDRs as partitioned Parquet files
+type ParquetExportHandler struct {
+	exporter *services.ParquetExporter
+}
+
+// NewParquetExportHandler creates a new Parquet export handler
+func NewParquetExportHandler(exporter *services.ParquetExporter) *ParquetExportHandler {
+	return &ParquetExportHandler{
+		exporter: exporter,
+	}
+}
+
+// ExportSession writes a cached result's CDRs to the Parquet destination
+func (peh *ParquetExportHandler) ExportSession(c *gin.Context) {
+	sessionID := c.Param("session_id")
+
+	if !peh.exporter.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Parquet export is not configured (PARQUET_STORAGE)"})
+		return
+	}
+
+	result, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+
+	export, err := peh.exporter.ExportSession(sessionID, result.AllCDRs, result.StartTime)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "export": export})
+		return
+	}
+	c.JSON(http.StatusOK, export)
+}
+
+// ExportRange writes the stored CDRs between ?start= and ?end= (dates, inclusive; the
+// last 7 days by default), optionally only ?domain=, to the Parquet destination
+func (peh *ParquetExportHandler) ExportRange(c *gin.Context) {
+	if !peh.exporter.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Parquet export is not configured (PARQUET_STORAGE)"})
+		return
+	}
+
+	start, end, err := parseKPIDays(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	export, err := peh.exporter.ExportRange(c.Query("domain"), start, end)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "export": export})
+		return
+	}
+	c.JSON(http.StatusOK, export)
+}
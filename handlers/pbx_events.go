@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxNotificationSize bounds a NetSapiens event notification body
+const maxNotificationSize = 1 << 20
+
+// PBXEventsHandler receives NetSapiens call event notifications
+type PBXEventsHandler struct {
+	service *services.PBXEventService
+}
+
+// NewPBXEventsHandler creates a new PBX events handler
+func NewPBXEventsHandler(service *services.PBXEventService) *PBXEventsHandler {
+	return &PBXEventsHandler{
+		service: service,
+	}
+}
+
+// Callback ingests a notification, authenticated by ?token= or a bearer token matching PBX_EVENTS_SECRET
+func (ph *PBXEventsHandler) Callback(c *gin.Context) {
+	if !ph.service.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "PBX events are not enabled"})
+		return
+	}
+
+	token := c.Query("token")
+	if token == "" {
+		token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
+	if !ph.service.Authorized(token) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxNotificationSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read notification"})
+		return
+	}
+
+	sent, err := ph.service.Ingest(body)
+	if err != nil {
+		log.Printf("[PBX] Rejected notification: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ok",
+		"events": sent,
+	})
+}
+
+// GetStats returns the subscription state and notification counters
+func (ph *PBXEventsHandler) GetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, ph.service.Stats())
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+)
+
+const (
+	userIDCookie       = "odango_user"
+	userIDContext      = "user_id"
+	preferencesContext = "preferences"
+)
+
+// PreferencesHandler handles per-user preference routes
+type PreferencesHandler struct {
+	db *services.DatabaseService
+}
+
+// NewPreferencesHandler creates a new preferences handler
+func NewPreferencesHandler(db *services.DatabaseService) *PreferencesHandler {
+	return &PreferencesHandler{
+		db: db,
+	}
+}
+
+// LoadPreferences is middleware that attaches the current user's preferences to the request
+func (ph *PreferencesHandler) LoadPreferences() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := currentUserID(c)
+
+		prefs, err := ph.db.GetUserPreferences(userID)
+		if err != nil {
+			log.Printf("[Preferences] Failed to load preferences for %s: %v", userID, err)
+			prefs = services.DefaultUserPreferences(userID)
+		}
+
+		c.Set(preferencesContext, prefs)
+		c.Next()
+	}
+}
+
+// GetPreferences returns the current user's preferences as JSON
+func (ph *PreferencesHandler) GetPreferences(c *gin.Context) {
+	userID := currentUserID(c)
+
+	prefs, err := ph.db.GetUserPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to load preferences: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preferences":       prefs,
+		"available_columns": services.ExportColumns,
+	})
+}
+
+// UpdatePreferences saves the current user's preferences
+func (ph *PreferencesHandler) UpdatePreferences(c *gin.Context) {
+	userID := currentUserID(c)
+
+	// Start from stored values so partial updates keep existing settings
+	prefs, err := ph.db.GetUserPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to load preferences: %v", err),
+		})
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid preferences: %v", err),
+		})
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid preferences: %v", err),
+		})
+		return
+	}
+	if err := json.Unmarshal(body, prefs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid preferences: %v", err),
+		})
+		return
+	}
+	prefs.UserID = userID
+
+	// Sending visible_columns chooses them; null goes back to the defaults
+	if columns, sent := fields["visible_columns"]; sent {
+		prefs.ColumnsChosen = string(columns) != "null"
+		if !prefs.ColumnsChosen {
+			prefs.VisibleColumns = services.DefaultUserPreferences(userID).VisibleColumns
+		}
+	}
+
+	if err := ph.db.SaveUserPreferences(prefs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	log.Printf("[Preferences] Saved preferences for %s", userID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"preferences": prefs,
+	})
+}
+
+// Identify is middleware that identifies the user by a cookie signed with sessionSecret,
+// issuing one on their first visit. Unsigned or tampered cookies get a new identity, so
+// nobody can act as another user by naming them.
+func Identify(sessionSecret string) gin.HandlerFunc {
+	store := sessions.NewCookieStore([]byte(sessionSecret))
+	store.Options = &sessions.Options{
+		Path:     "/",
+		MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	return func(c *gin.Context) {
+		session, _ := store.Get(c.Request, userIDCookie)
+		userID, _ := session.Values["user_id"].(string)
+		if userID == "" {
+			userID = fmt.Sprintf("user_%d", time.Now().UnixNano())
+			session.Values["user_id"] = userID
+			if err := session.Save(c.Request, c.Writer); err != nil {
+				log.Printf("[Preferences] Failed to issue user cookie: %v", err)
+			}
+		}
+
+		c.Set(userIDContext, userID)
+		c.Next()
+	}
+}
+
+// currentUserID returns the user identified by Identify
+func currentUserID(c *gin.Context) string {
+	return c.GetString(userIDContext)
+}
+
+// preferencesFromContext returns preferences loaded by LoadPreferences, or defaults
+func preferencesFromContext(c *gin.Context) *services.UserPreferences {
+	if value, exists := c.Get(preferencesContext); exists {
+		if prefs, ok := value.(*services.UserPreferences); ok {
+			return prefs
+		}
+	}
+	return services.DefaultUserPreferences("")
+}
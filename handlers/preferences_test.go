@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdentifyUsesSignedCookieOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/whoami", Identify("secret"), func(c *gin.Context) {
+		c.String(http.StatusOK, currentUserID(c))
+	})
+	request := func(headers map[string]string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := request(nil)
+	userID := first.Body.String()
+	cookies := first.Result().Cookies()
+	if userID == "" || len(cookies) != 1 || cookies[0].Name != userIDCookie {
+		t.Fatalf("first visit = %q with cookies %v, want a new user and their cookie", userID, cookies)
+	}
+	if again := request(nil, cookies[0]).Body.String(); again != userID {
+		t.Errorf("signed cookie identified %q, want %q", again, userID)
+	}
+
+	// Naming another user, by header or unsigned cookie, gets a new identity
+	if spoofed := request(map[string]string{"X-User-ID": userID}).Body.String(); spoofed == userID {
+		t.Error("X-User-ID header was trusted")
+	}
+	if forged := request(nil, &http.Cookie{Name: userIDCookie, Value: userID}).Body.String(); forged == userID {
+		t.Error("unsigned user cookie was trusted")
+	}
+}
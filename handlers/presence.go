@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PresenceHandler serves user presence for the dashboard
+type PresenceHandler struct {
+	presence *services.PresenceService
+}
+
+// NewPresenceHandler creates a new presence handler
+func NewPresenceHandler(presence *services.PresenceService) *PresenceHandler {
+	return &PresenceHandler{
+		presence: presence,
+	}
+}
+
+// GetDomainPresence returns who in the domain is available, on a call, ringing or away
+// (?state=on_call limits the users to one state; counts always cover everyone)
+func (ph *PresenceHandler) GetDomainPresence(c *gin.Context) {
+	domain := c.Param("domain")
+
+	presence, err := ph.presence.GetDomainPresence(domain)
+	if err != nil {
+		log.Printf("[Presence] Failed to load presence for %s: %v", domain, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to load presence"})
+		return
+	}
+
+	if state := c.Query("state"); state != "" {
+		users := []services.UserPresence{}
+		for _, user := range presence.Users {
+			if user.State == state {
+				users = append(users, user)
+			}
+		}
+		presence.Users = users
+	}
+
+	c.JSON(http.StatusOK, presence)
+}
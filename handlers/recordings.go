@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecordingsHandler serves caller recordings captured by IVR apps
+type RecordingsHandler struct {
+	db *services.DatabaseService
+}
+
+// NewRecordingsHandler creates a new recordings handler
+func NewRecordingsHandler(db *services.DatabaseService) *RecordingsHandler {
+	return &RecordingsHandler{
+		db: db,
+	}
+}
+
+// RecordingCallback receives NetSapiens recording status callbacks
+func (rh *RecordingsHandler) RecordingCallback(c *gin.Context) {
+	if err := c.Request.ParseForm(); err != nil {
+		c.String(http.StatusBadRequest, "Invalid request parameters")
+		return
+	}
+	form := c.Request.Form
+
+	duration, _ := strconv.Atoi(form.Get("RecordingDuration"))
+	recording := &services.Recording{
+		RecordingID:     form.Get("RecordingSid"),
+		CallerNumber:    form.Get("NmsAni"),
+		URL:             form.Get("RecordingUrl"),
+		DurationSeconds: duration,
+		Status:          form.Get("RecordingStatus"),
+	}
+
+	if err := rh.db.SaveRecording(recording); err != nil {
+		log.Printf("[WR] Recording callback failed: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// GetRecordings returns recent recordings for the dashboard (?kind=voicemail|callback&limit=50)
+func (rh *RecordingsHandler) GetRecordings(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	recordings, err := rh.db.GetRecordings(c.Query("kind"), limit)
+	if err != nil {
+		log.Printf("[WR] Failed to load recordings: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load recordings"})
+		return
+	}
+	if recordings == nil {
+		recordings = []services.Recording{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recordings": recordings,
+		"count":      len(recordings),
+	})
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RerunSearch repeats a session's search criteria against current data with the API
+// credentials from the form. The original comes from the results store, or from the
+// database once it has expired there. The new session is linked to the original.
+func RerunSearch(hooks *SearchHooks) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		originalID := c.Param("session_id")
+		apiURL := c.PostForm("api_url")
+		apiToken := c.PostForm("api_token")
+		if apiURL == "" || apiToken == "" {
+			c.HTML(http.StatusBadRequest, "error.html", gin.H{
+				"title": "Authentication Error - O Dan Go",
+				"error": "API URL and Bearer Token are required",
+			})
+			return
+		}
+
+		var criteria services.CDRSearchCriteria
+		if original, exists := services.GlobalResultsStore.Get(originalID); exists {
+			criteria = original.SearchCriteria
+		} else {
+			stored, err := hooks.Storage.Session(originalID)
+			if err != nil {
+				log.Printf("[Web Handler] ERROR: loading session %s failed: %v", originalID, err)
+			}
+			if stored == nil {
+				c.HTML(http.StatusNotFound, "error.html", gin.H{
+					"title": "Re-run Error - O Dan Go",
+					"error": "Session not found or expired",
+				})
+				return
+			}
+			criteria = stored.Criteria
+		}
+
+		log.Printf("[Web Handler] Re-running search of session %s", originalID)
+		result, err := services.NewCDRDiscoveryService(apiURL, apiToken).GetComprehensiveCDRs(criteria)
+		if err != nil {
+			log.Printf("[Web Handler] ERROR: re-run of %s failed: %v", originalID, err)
+			c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+				"title": "Re-run Error - O Dan Go",
+				"error": fmt.Sprintf("CDR search failed: %v", err),
+			})
+			return
+		}
+		result.RerunOf = originalID
+		log.Printf("[Web Handler] Session %s re-ran %s: %d unique CDRs", result.SessionID, originalID, result.UniqueCDRs)
+
+		hooks.complete(result)
+
+		c.Redirect(http.StatusFound, "/web/results/"+result.SessionID)
+	}
+}
+
+// CompareRerun compares a re-run session with the session it repeated. Both must still
+// be in the results store.
+func CompareRerun(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	rerun, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+	if rerun.RerunOf == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Session is not a re-run"})
+		return
+	}
+	original, exists := services.GlobalResultsStore.Get(rerun.RerunOf)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Original session " + rerun.RerunOf + " has expired"})
+		return
+	}
+
+	c.JSON(http.StatusOK, services.CompareSessions(original, rerun))
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleHandler manages business hours and holidays for open/closed IVR routing
+type ScheduleHandler struct {
+	db       *services.DatabaseService
+	schedule *services.ScheduleService
+}
+
+// NewScheduleHandler creates a new schedule handler
+func NewScheduleHandler(db *services.DatabaseService, schedule *services.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{
+		db:       db,
+		schedule: schedule,
+	}
+}
+
+// GetSchedules lists every number's business hours
+func (sh *ScheduleHandler) GetSchedules(c *gin.Context) {
+	schedules, err := sh.db.GetBusinessSchedules()
+	if err != nil {
+		log.Printf("[Admin] Failed to load schedules: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load schedules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"schedules": schedules,
+		"count":     len(schedules),
+	})
+}
+
+// GetSchedule returns one number's business hours ("default" for the fallback)
+func (sh *ScheduleHandler) GetSchedule(c *gin.Context) {
+	did, err := services.NormalizeScheduleDID(c.Param("did"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, err := sh.db.GetBusinessSchedule(did)
+	if err != nil {
+		log.Printf("[Admin] Failed to load schedule %s: %v", did, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load schedule"})
+		return
+	}
+	if schedule == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No schedule for " + did})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// SaveSchedule replaces a number's business hours with the JSON body
+func (sh *ScheduleHandler) SaveSchedule(c *gin.Context) {
+	var schedule services.BusinessSchedule
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule: " + err.Error()})
+		return
+	}
+	schedule.DID = c.Param("did")
+
+	if err := sh.db.SaveBusinessSchedule(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[Admin] Saved business hours for %s (%d periods)", schedule.DID, len(schedule.Hours))
+
+	saved, err := sh.db.GetBusinessSchedule(schedule.DID)
+	if err != nil || saved == nil {
+		saved = &schedule
+	}
+	c.JSON(http.StatusOK, saved)
+}
+
+// DeleteSchedule removes a number's business hours so it falls back to the default
+func (sh *ScheduleHandler) DeleteSchedule(c *gin.Context) {
+	did, err := services.NormalizeScheduleDID(c.Param("did"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deleted, err := sh.db.DeleteBusinessSchedule(did)
+	if err != nil {
+		log.Printf("[Admin] Failed to delete schedule %s: %v", did, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete schedule"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No schedule for " + did})
+		return
+	}
+	log.Printf("[Admin] Deleted business hours for %s", did)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "deleted",
+		"did":    did,
+	})
+}
+
+// GetScheduleStatus reports whether a number is open now, or at ?at= (RFC 3339)
+func (sh *ScheduleHandler) GetScheduleStatus(c *gin.Context) {
+	at := time.Now()
+	if value := c.Query("at"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at must be an RFC 3339 time"})
+			return
+		}
+		at = parsed
+	}
+
+	status, err := sh.schedule.Status(c.Param("did"), at)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// GetHolidays lists holidays, for one number (plus the default's) with ?did=
+func (sh *ScheduleHandler) GetHolidays(c *gin.Context) {
+	did := ""
+	if value := c.Query("did"); value != "" {
+		normalized, err := services.NormalizeScheduleDID(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		did = normalized
+	}
+
+	holidays, err := sh.db.GetHolidays(did)
+	if err != nil {
+		log.Printf("[Admin] Failed to load holidays: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load holidays"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"holidays": holidays,
+		"count":    len(holidays),
+	})
+}
+
+// AddHoliday stores a holiday from the JSON body ({"did", "date", "name"}; did defaults to every number)
+func (sh *ScheduleHandler) AddHoliday(c *gin.Context) {
+	var holiday services.Holiday
+	if err := c.ShouldBindJSON(&holiday); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid holiday: " + err.Error()})
+		return
+	}
+
+	if err := sh.db.AddHoliday(&holiday); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[Admin] Added holiday %s on %s for %s", holiday.Name, holiday.Date, holiday.DID)
+
+	c.JSON(http.StatusOK, holiday)
+}
+
+// DeleteHoliday removes a holiday by ID
+func (sh *ScheduleHandler) DeleteHoliday(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid holiday ID"})
+		return
+	}
+
+	deleted, err := sh.db.DeleteHoliday(id)
+	if err != nil {
+		log.Printf("[Admin] Failed to delete holiday %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete holiday"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Holiday not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "deleted",
+		"id":     id,
+	})
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduledSearchesHandler manages scheduled searches and shows how their runs drift
+type ScheduledSearchesHandler struct {
+	db        *services.DatabaseService
+	scheduler *services.SearchScheduler
+}
+
+// NewScheduledSearchesHandler creates a new scheduled searches handler
+func NewScheduledSearchesHandler(db *services.DatabaseService, scheduler *services.SearchScheduler) *ScheduledSearchesHandler {
+	return &ScheduledSearchesHandler{
+		db:        db,
+		scheduler: scheduler,
+	}
+}
+
+// GetScheduledSearches lists every scheduled search
+func (sh *ScheduledSearchesHandler) GetScheduledSearches(c *gin.Context) {
+	searches, err := sh.db.GetScheduledSearches()
+	if err != nil {
+		log.Printf("[Admin] Failed to load scheduled searches: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load scheduled searches"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"searches": searches,
+		"count":    len(searches),
+	})
+}
+
+// CreateScheduledSearch stores a search from the JSON body ({"name", "criteria",
+// "interval_minutes", "lookback_days", "drift_alert_percent", "drift_alert_missing", "disabled"})
+func (sh *ScheduledSearchesHandler) CreateScheduledSearch(c *gin.Context) {
+	var search services.ScheduledSearch
+	if err := c.ShouldBindJSON(&search); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled search: " + err.Error()})
+		return
+	}
+	search.ID = 0
+
+	if err := sh.db.SaveScheduledSearch(&search); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[Admin] Added scheduled search %s (every %d minutes)", search.Name, search.IntervalMinutes)
+
+	c.JSON(http.StatusCreated, search)
+}
+
+// UpdateScheduledSearch replaces a search with the JSON body; its run history is kept
+func (sh *ScheduledSearchesHandler) UpdateScheduledSearch(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled search ID"})
+		return
+	}
+	var search services.ScheduledSearch
+	if err := c.ShouldBindJSON(&search); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled search: " + err.Error()})
+		return
+	}
+	search.ID = id
+
+	err = sh.db.SaveScheduledSearch(&search)
+	if errors.Is(err, services.ErrScheduledSearchNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled search not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("[Admin] Saved scheduled search %s", search.Name)
+
+	c.JSON(http.StatusOK, search)
+}
+
+// DeleteScheduledSearch removes a search and its drift history
+func (sh *ScheduledSearchesHandler) DeleteScheduledSearch(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled search ID"})
+		return
+	}
+
+	deleted, err := sh.db.DeleteScheduledSearch(id)
+	if err != nil {
+		log.Printf("[Admin] Failed to delete scheduled search %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete scheduled search"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled search not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "deleted",
+		"id":     id,
+	})
+}
+
+// RunScheduledSearch runs a search now, whether or not it is due
+func (sh *ScheduledSearchesHandler) RunScheduledSearch(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled search ID"})
+		return
+	}
+
+	result, drift, err := sh.scheduler.Run(id)
+	if errors.Is(err, services.ErrScheduledSearchNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled search not found"})
+		return
+	}
+	if result == nil && err != nil {
+		log.Printf("[Admin] Scheduled search %d failed: %v", id, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("[Admin] Scheduled search %d ran but its drift was not recorded: %v", id, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id":  result.SessionID,
+		"unique_cdrs": result.UniqueCDRs,
+		"drift":       drift,
+	})
+}
+
+// GetSearchDrift lists how a search's runs changed, newest first (?limit=20)
+func (sh *ScheduledSearchesHandler) GetSearchDrift(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scheduled search ID"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 20
+	}
+
+	drifts, err := sh.db.GetSearchDrift(id, limit)
+	if err != nil {
+		log.Printf("[Admin] Failed to load drift of scheduled search %d: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load search drift"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"drift": drifts,
+		"count": len(drifts),
+	})
+}
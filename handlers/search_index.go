@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchIndexHandler indexes search sessions' CDRs into Elasticsearch or OpenSearch
+type SearchIndexHandler struct {
+	sink *services.ElasticsearchSink
+}
+
+// NewSearchIndexHandler creates a new search index handler
+func NewSearchIndexHandler(sink *services.ElasticsearchSink) *SearchIndexHandler {
+	return &SearchIndexHandler{
+		sink: sink,
+	}
+}
+
+// IndexSession starts indexing a search session's CDRs into the configured cluster;
+// progress is reported by GetIndexRun
+func (sih *SearchIndexHandler) IndexSession(c *gin.Context) {
+	sessionID := c.Param("session_id")
+
+	if !sih.sink.Enabled() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Elasticsearch sink is not configured (ELASTICSEARCH_URL)"})
+		return
+	}
+
+	result, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+
+	run, err := sih.sink.Begin(sessionID, len(result.AllCDRs))
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	go sih.sink.IndexSession(sessionID, result.AllCDRs, result.StartTime)
+
+	c.JSON(http.StatusAccepted, run)
+}
+
+// GetIndexRun returns a session's latest indexing run
+func (sih *SearchIndexHandler) GetIndexRun(c *gin.Context) {
+	sessionID := c.Param("session_id")
+
+	run, exists := sih.sink.Run(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session has not been indexed"})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// flaggedContext holds the flagged CDR IDs of a ?flagged=true request
+const flaggedContext = "flagged_cdrs"
+
+// LoadFlags is middleware that, for requests with ?flagged=true, attaches the session's
+// flagged CDR IDs so previews and exports can keep only those
+func (sh *StoredSessionsHandler) LoadFlags() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Query("flagged") != "true" {
+			c.Next()
+			return
+		}
+
+		sessionID := sessionParam(c)
+		flagged, err := sh.db.FlaggedCDRIDs(sessionID)
+		if err != nil {
+			log.Printf("[Sessions] Failed to load flags of %s: %v", sessionID, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to load flagged CDRs"})
+			return
+		}
+
+		c.Set(flaggedContext, flagged)
+		c.Next()
+	}
+}
+
+// flaggedOnly narrows a result to its flagged CDRs when LoadFlags loaded them
+func flaggedOnly(c *gin.Context, result *services.CDRDiscoveryResult) *services.CDRDiscoveryResult {
+	if value, exists := c.Get(flaggedContext); exists {
+		if flagged, ok := value.(map[string]bool); ok {
+			return result.FlaggedOnly(flagged)
+		}
+	}
+	return result
+}
+
+// ListFlags returns a session's flagged CDRs in the order they were flagged, each with
+// its record while the session's results are in memory, normalized with ?view=normalized
+func (sh *StoredSessionsHandler) ListFlags(c *gin.Context) {
+	sessionID := sessionParam(c)
+	view, err := services.ParseCDRView(c.Query("view"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	flags, err := sh.db.GetCDRFlags(sessionID)
+	if err != nil {
+		log.Printf("[Sessions] Failed to load flags of %s: %v", sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load flagged CDRs"})
+		return
+	}
+
+	type flaggedCDR struct {
+		services.CDRFlag
+		CDR interface{} `json:"cdr,omitempty"`
+	}
+	records := map[string]interface{}{}
+	if result, ok := services.GlobalResultsStore.Get(sessionID); ok {
+		policy, maskMode := maskingFromContext(c)
+		for _, cdr := range result.FlaggedOnly(flagIDs(flags)).AllCDRs {
+			masked := policy.MaskCDR(cdr, maskMode)
+			if view == services.CDRViewNormalized {
+				records[cdr.GetID()] = services.NormalizeCDR(masked)
+			} else {
+				records[cdr.GetID()] = masked.RawData
+			}
+		}
+	}
+	flagged := make([]flaggedCDR, 0, len(flags))
+	for _, flag := range flags {
+		flagged = append(flagged, flaggedCDR{CDRFlag: flag, CDR: records[flag.CDRID]})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"flags":      flagged,
+		"count":      len(flagged),
+	})
+}
+
+// flagIDs returns the CDR IDs of flags
+func flagIDs(flags []services.CDRFlag) map[string]bool {
+	ids := make(map[string]bool, len(flags))
+	for _, flag := range flags {
+		ids[flag.CDRID] = true
+	}
+	return ids
+}
+
+// FlagCDR flags one of a session's CDRs, with an optional {"reason": "..."}, as the
+// current user; a flagged_by in the body is ignored. Flagging an already flagged CDR
+// updates it.
+func (sh *StoredSessionsHandler) FlagCDR(c *gin.Context) {
+	sessionID, cdrID := sessionParam(c), c.Param("cdr_id")
+	var flag services.CDRFlag
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&flag); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+			return
+		}
+	}
+
+	// A session still in memory must hold the CDR; a stored one is taken at its word
+	if result, ok := services.GlobalResultsStore.Get(sessionID); ok {
+		if len(result.FlaggedOnly(map[string]bool{cdrID: true}).AllCDRs) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "CDR not found in session"})
+			return
+		}
+	} else {
+		exists, err := sh.sessionExists(sessionID)
+		if err != nil {
+			log.Printf("[Sessions] Failed to look up session %s: %v", sessionID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to flag CDR"})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+	}
+
+	flag.SessionID, flag.CDRID = sessionID, cdrID
+	flag.FlaggedBy = currentUserID(c)
+	if err := sh.db.FlagCDR(&flag); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, flag)
+}
+
+// UnflagCDR clears a CDR's flag
+func (sh *StoredSessionsHandler) UnflagCDR(c *gin.Context) {
+	sessionID, cdrID := sessionParam(c), c.Param("cdr_id")
+	unflagged, err := sh.db.UnflagCDR(sessionID, cdrID)
+	if err != nil {
+		log.Printf("[Sessions] Failed to unflag %s of %s: %v", cdrID, sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unflag CDR"})
+		return
+	}
+	if !unflagged {
+		c.JSON(http.StatusNotFound, gin.H{"error": "CDR is not flagged"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "unflagged",
+		"cdr_id": cdrID,
+	})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"o-dan-go/models"
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestFlagCDRIgnoresBodyFlaggedBy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := services.NewDatabaseService(filepath.Join(t.TempDir(), "flags.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+	services.GlobalResultsStore.Store("flags-session", &services.CDRDiscoveryResult{
+		AllCDRs: []models.FlexibleCDR{{RawData: map[string]interface{}{"id": "cdr-1"}}},
+	})
+
+	router := gin.New()
+	router.PUT("/flags/:session_id/:cdr_id", Identify("secret"), NewStoredSessionsHandler(db, nil).FlagCDR)
+	req := httptest.NewRequest(http.MethodPut, "/flags/flags-session/cdr-1", strings.NewReader(`{"reason": "disputed", "flagged_by": "supervisor"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("flag CDR = %d %s, want 200", w.Code, w.Body.String())
+	}
+
+	var flag services.CDRFlag
+	if err := json.Unmarshal(w.Body.Bytes(), &flag); err != nil {
+		t.Fatal(err)
+	}
+	if flag.FlaggedBy == "supervisor" || !strings.HasPrefix(flag.FlaggedBy, "user_") || flag.Reason != "disputed" {
+		t.Errorf("flag = %+v, want it flagged by the identified user, not the one in the body", flag)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionParam reads the session ID, named session_id on the results page's /web routes
+func sessionParam(c *gin.Context) string {
+	if sessionID := c.Param("id"); sessionID != "" {
+		return sessionID
+	}
+	return c.Param("session_id")
+}
+
+// sessionExists reports whether a session is stored or still held in memory
+func (sh *StoredSessionsHandler) sessionExists(sessionID string) (bool, error) {
+	if _, ok := services.GlobalResultsStore.Get(sessionID); ok {
+		return true, nil
+	}
+	session, err := sh.db.GetSearchSession(sessionID)
+	return session != nil, err
+}
+
+// ListNotes returns a session's notes, oldest first; ?cdr_id= narrows them to one CDR
+func (sh *StoredSessionsHandler) ListNotes(c *gin.Context) {
+	sessionID := sessionParam(c)
+	notes, err := sh.db.GetSessionNotes(sessionID, c.Query("cdr_id"))
+	if err != nil {
+		log.Printf("[Sessions] Failed to load notes of %s: %v", sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load notes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notes": notes,
+		"count": len(notes),
+	})
+}
+
+// AddNote attaches a note to a session, or to one of its CDRs when cdr_id is given. The
+// author is always the current user; an author in the body is ignored.
+func (sh *StoredSessionsHandler) AddNote(c *gin.Context) {
+	sessionID := sessionParam(c)
+	var note services.SessionNote
+	if err := c.ShouldBindJSON(&note); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body: " + err.Error()})
+		return
+	}
+
+	exists, err := sh.sessionExists(sessionID)
+	if err != nil {
+		log.Printf("[Sessions] Failed to look up session %s: %v", sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save note"})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	note.SessionID = sessionID
+	note.Author = currentUserID(c)
+	if err := sh.db.AddSessionNote(&note); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, note)
+}
+
+// DeleteNote removes one of a session's notes
+func (sh *StoredSessionsHandler) DeleteNote(c *gin.Context) {
+	sessionID := sessionParam(c)
+	id, err := strconv.ParseInt(c.Param("note_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		return
+	}
+
+	deleted, err := sh.db.DeleteSessionNote(sessionID, id)
+	if err != nil {
+		log.Printf("[Sessions] Failed to delete note %d of %s: %v", id, sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "deleted",
+		"id":     id,
+	})
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAddNoteIgnoresBodyAuthor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := services.NewDatabaseService(filepath.Join(t.TempDir(), "notes.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+	services.GlobalResultsStore.Store("notes-session", &services.CDRDiscoveryResult{})
+
+	router := gin.New()
+	router.POST("/notes/:session_id", Identify("secret"), NewStoredSessionsHandler(db, nil).AddNote)
+	req := httptest.NewRequest(http.MethodPost, "/notes/notes-session", strings.NewReader(`{"text": "callback owed", "author": "supervisor"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("add note = %d %s, want 201", w.Code, w.Body.String())
+	}
+
+	var note services.SessionNote
+	if err := json.Unmarshal(w.Body.Bytes(), &note); err != nil {
+		t.Fatal(err)
+	}
+	if note.Author == "supervisor" || !strings.HasPrefix(note.Author, "user_") {
+		t.Errorf("author = %q, want the identified user, not the one in the body", note.Author)
+	}
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFieldStats describes one raw field across a session's CDRs: how often it is
+// present, null or empty, its distinct values, range and most common values. CDRs are
+// masked first, and ?filter= narrows the CDRs measured.
+func GetFieldStats(c *gin.Context) {
+	sessionID := c.Param("id")
+	field := c.Param("field")
+
+	result, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+
+	filter, err := services.ParseCDRFilter(c.Query("filter"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	top, _ := strconv.Atoi(c.DefaultQuery("top", "10"))
+
+	policy, maskMode := maskingFromContext(c)
+	cdrs := filter.Apply(policy.MaskCDRs(result.AllCDRs, maskMode))
+	stats := services.ComputeFieldStats(cdrs, field, top)
+	if stats.CDRs > 0 && stats.Missing == stats.CDRs {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("No CDR in session %s has the field %s", sessionID, field)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"filter":     filter.String(),
+		"stats":      stats,
+	})
+}
+
+// GetPivot groups a session's CDRs by the fields in ?group_by= (comma separated, the
+// names filters use) with each group's count and total, average and longest duration.
+// ?filter= narrows the CDRs grouped, ?merged=true groups the merged CDRs and ?limit=
+// caps the groups returned (100 by default).
+func GetPivot(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	result, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+	if c.Query("merged") == "true" {
+		result = result.Merged()
+	}
+
+	filter, err := services.ParseCDRFilter(c.Query("filter"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	policy, maskMode := maskingFromContext(c)
+	cdrs := filter.Apply(policy.MaskCDRs(result.AllCDRs, maskMode))
+	pivot, err := services.PivotCDRs(cdrs, strings.Split(c.Query("group_by"), ","), limit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"filter":     filter.String(),
+		"pivot":      pivot,
+	})
+}
+
+// GetTimeSeries counts a session's calls (or their minutes with ?metric=minutes) per
+// ?interval= (5m by default) of call start time, in the user's timezone or ?tz=. It
+// serves both the results page chart and external dashboards; ?filter= and
+// ?merged=true apply as they do for pivots.
+func GetTimeSeries(c *gin.Context) {
+	// The results page names the parameter as the other /web routes do
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		sessionID = c.Param("session_id")
+	}
+
+	result, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+	if c.Query("merged") == "true" {
+		result = result.Merged()
+	}
+
+	filter, err := services.ParseCDRFilter(c.Query("filter"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	interval, err := services.ParseTimeSeriesInterval(c.DefaultQuery("interval", "5m"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	loc, err := requestLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, maskMode := maskingFromContext(c)
+	cdrs := filter.Apply(policy.MaskCDRs(result.AllCDRs, maskMode))
+	series, err := services.BuildTimeSeries(cdrs, interval, c.Query("metric"), loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"filter":     filter.String(),
+		"series":     series,
+	})
+}
+
+// GetHeatmap counts a session's calls and connected minutes by weekday and hour of call
+// start time, in the user's timezone or ?tz=. ?filter= and ?merged=true apply as they
+// do for pivots.
+func GetHeatmap(c *gin.Context) {
+	sessionID := c.Param("id")
+
+	result, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+	if c.Query("merged") == "true" {
+		result = result.Merged()
+	}
+
+	filter, err := services.ParseCDRFilter(c.Query("filter"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	loc, err := requestLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, maskMode := maskingFromContext(c)
+	heatmap := services.HeatmapFromCDRs(filter.Apply(policy.MaskCDRs(result.AllCDRs, maskMode)), loc)
+	heatmap.SessionID = sessionID
+	c.JSON(http.StatusOK, heatmap)
+}
+
+// requestLocation is the timezone named by ?tz=, or the user's
+func requestLocation(c *gin.Context) (*time.Location, error) {
+	tz := c.Query("tz")
+	if tz == "" {
+		return preferencesFromContext(c).Location(), nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %s", tz)
+	}
+	return loc, nil
+}
+
+// GetGeo places a session's calls at the centroid of their number's area code, with the
+// calls and minutes of each, for a map layer. ?number= names the field the number is read
+// from (orig_number by default, or term_number) and ?format=geojson returns a GeoJSON
+// feature collection. Only area codes are returned, so numbers are read before masking.
+// ?filter= and ?merged=true apply as they do for pivots.
+func GetGeo(c *gin.Context) {
+	// The results page names the parameter as the other /web routes do
+	sessionID := c.Param("id")
+	if sessionID == "" {
+		sessionID = c.Param("session_id")
+	}
+
+	result, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+	if c.Query("merged") == "true" {
+		result = result.Merged()
+	}
+
+	filter, err := services.ParseCDRFilter(c.Query("filter"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	geo := services.LocateCDRs(filter.Apply(result.AllCDRs), c.Query("number"))
+	if c.Query("format") == "geojson" {
+		c.JSON(http.StatusOK, geo.GeoJSON())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"session_id": sessionID,
+		"filter":     filter.String(),
+		"geo":        geo,
+	})
+}
+
+// GetSessionReport builds a report of type :type (e.g. agents) over a session's CDRs as
+// JSON, or as a CSV download with ?format=csv. ?filter= and ?merged=true apply as they
+// do for pivots.
+func GetSessionReport(c *gin.Context) {
+	sessionID := c.Param("id")
+	reportType := c.Param("type")
+
+	result, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+	if c.Query("merged") == "true" {
+		result = result.Merged()
+	}
+
+	filter, err := services.ParseCDRFilter(c.Query("filter"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, maskMode := maskingFromContext(c)
+	report, err := services.BuildSessionReport(reportType, sessionID, filter.Apply(policy.MaskCDRs(result.AllCDRs, maskMode)), "")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") == "csv" {
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s_%s.csv\"", reportType, sessionID))
+		if err := report.WriteCSV(c.Writer); err != nil {
+			log.Printf("[Reports] Failed to write %s report for %s: %v", reportType, sessionID, err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
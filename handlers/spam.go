@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SpamHandler scores inbound calls for spam and serves the spam reports
+type SpamHandler struct {
+	db     *services.DatabaseService
+	scorer *services.SpamScorer
+}
+
+// NewSpamHandler creates a new spam handler
+func NewSpamHandler(db *services.DatabaseService, scorer *services.SpamScorer) *SpamHandler {
+	return &SpamHandler{
+		db:     db,
+		scorer: scorer,
+	}
+}
+
+// ScoreSession scores the inbound calls of a stored search
+func (sh *SpamHandler) ScoreSession(c *gin.Context) {
+	sessionID := c.Param("session_id")
+	result, exists := services.GlobalResultsStore.Get(sessionID)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found or expired"})
+		return
+	}
+
+	scores, err := sh.scorer.ScoreCDRs(sessionID, result.AllCDRs)
+	if err != nil {
+		log.Printf("[Admin] Spam scoring of %s failed: %v", sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Spam scoring failed"})
+		return
+	}
+	likely := 0
+	for _, score := range scores {
+		if score.Score >= sh.scorer.Threshold() {
+			likely++
+		}
+	}
+	log.Printf("[Admin] Scored %d inbound calls in %s for spam, %d likely spam", len(scores), sessionID, likely)
+
+	c.JSON(http.StatusOK, gin.H{
+		"session_id":  sessionID,
+		"scored":      len(scores),
+		"likely_spam": likely,
+	})
+}
+
+// GetSpamSummary reports how many inbound calls to a domain were likely spam and the
+// numbers behind them (?hours=168&threshold=&limit=20)
+func (sh *SpamHandler) GetSpamSummary(c *gin.Context) {
+	domain := c.Param("domain")
+	since, threshold, ok := sh.reportWindow(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 20
+	}
+
+	summary, err := sh.db.GetSpamSummary(domain, since, threshold, limit)
+	if err != nil {
+		log.Printf("[Spam] Failed to build spam summary for %s: %v", domain, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build spam summary"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// GetSpamNumber lists the scored calls from one number to a domain (?hours=168&threshold=)
+func (sh *SpamHandler) GetSpamNumber(c *gin.Context) {
+	domain, number := c.Param("domain"), c.Param("number")
+	since, threshold, ok := sh.reportWindow(c)
+	if !ok {
+		return
+	}
+
+	report, err := sh.db.GetSpamNumberReport(domain, number, since, threshold)
+	if err != nil {
+		log.Printf("[Spam] Failed to build spam report for %s in %s: %v", number, domain, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build spam report"})
+		return
+	}
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No scored calls from this number"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// reportWindow reads ?hours= and ?threshold=, responding with an error when they're invalid
+func (sh *SpamHandler) reportWindow(c *gin.Context) (time.Time, int, bool) {
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "168"))
+	if err != nil || hours <= 0 || hours > 24*366 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hours must be between 1 and 8784"})
+		return time.Time{}, 0, false
+	}
+	threshold, err := strconv.Atoi(c.DefaultQuery("threshold", strconv.Itoa(sh.scorer.Threshold())))
+	if err != nil || threshold < 0 || threshold > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "threshold must be between 0 and 100"})
+		return time.Time{}, 0, false
+	}
+	return time.Now().Add(-time.Duration(hours) * time.Hour), threshold, true
+}
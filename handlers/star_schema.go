@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StarSchemaHandler exports stored CDRs as dimension and fact tables for BI tools
+type StarSchemaHandler struct {
+	db *services.DatabaseService
+}
+
+// NewStarSchemaHandler creates a new star schema handler
+func NewStarSchemaHandler(db *services.DatabaseService) *StarSchemaHandler {
+	return &StarSchemaHandler{
+		db: db,
+	}
+}
+
+// ExportStarSchema builds dim_domain, dim_user, dim_date and fact_calls from the CDRs
+// stored between ?start= and ?end= (the last 7 days by default), optionally only
+// ?domain=. They download as a ZIP of CSV files and schema.sql, or ?format=json.
+func (ssh *StarSchemaHandler) ExportStarSchema(c *gin.Context) {
+	start, end, err := parseKPIDays(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := c.DefaultQuery("format", "zip")
+	if format != "zip" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be zip or json"})
+		return
+	}
+
+	schema, err := ssh.db.BuildStarSchema(c.Query("domain"), start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if format == "json" {
+		c.JSON(http.StatusOK, schema)
+		return
+	}
+
+	filename := fmt.Sprintf("star_schema_%s_%s.zip", schema.Start, schema.End)
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	if err := schema.WriteZip(c.Writer); err != nil {
+		log.Printf("[Star Schema] ERROR: export of %s to %s failed: %v", schema.Start, schema.End, err)
+	}
+}
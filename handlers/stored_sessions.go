@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StoredSessionsHandler manages the search sessions kept in the database
+type StoredSessionsHandler struct {
+	db      *services.DatabaseService
+	archive services.ArchiveStorage // nil when recordings aren't archived
+}
+
+// NewStoredSessionsHandler creates a new stored sessions handler; deleting a session also
+// deletes its recordings from archive
+func NewStoredSessionsHandler(db *services.DatabaseService, archive services.ArchiveStorage) *StoredSessionsHandler {
+	return &StoredSessionsHandler{
+		db:      db,
+		archive: archive,
+	}
+}
+
+// ListSessions returns a page of stored search sessions, newest first
+// (?limit=50&offset=0&from=&to=&domain=&q=)
+func (sh *StoredSessionsHandler) ListSessions(c *gin.Context) {
+	query := services.SessionQuery{
+		Domain: strings.TrimSpace(c.Query("domain")),
+		Text:   c.Query("q"),
+		Limit:  services.DefaultSessionPageSize,
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && limit <= services.MaxSessionPageSize {
+		query.Limit = limit
+	}
+	if offset, err := strconv.Atoi(c.Query("offset")); err == nil && offset > 0 {
+		query.Offset = offset
+	}
+	var err error
+	if query.From, err = parseTimeParam(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.To, err = parseTimeParam(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sessions, total, err := sh.db.ListSearchSessions(query)
+	if err != nil {
+		log.Printf("[Sessions] Failed to list sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": sessions,
+		"count":    len(sessions),
+		"total":    total,
+		"limit":    query.Limit,
+		"offset":   query.Offset,
+	})
+}
+
+// DeleteSession erases a search session with its CDRs, reports, alerts and archived
+// recordings, and drops its results from memory
+func (sh *StoredSessionsHandler) DeleteSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	deletion, err := sh.db.DeleteSearchSession(sessionID, sh.archive)
+	if err != nil {
+		log.Printf("[Sessions] Failed to delete session %s: %v", sessionID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete session"})
+		return
+	}
+	if !deletion.Found() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	log.Printf("[Sessions] Deleted session %s: %d CDRs, %d reports, %d recordings", sessionID, deletion.CDRs, deletion.Reports, deletion.Recordings)
+	c.JSON(http.StatusOK, deletion)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TranscriptsHandler serves transcripts of archived call recordings
+type TranscriptsHandler struct {
+	db *services.DatabaseService
+}
+
+// NewTranscriptsHandler creates a new transcripts handler
+func NewTranscriptsHandler(db *services.DatabaseService) *TranscriptsHandler {
+	return &TranscriptsHandler{
+		db: db,
+	}
+}
+
+// SearchTranscripts finds transcripts containing the search text (?q=refund&domain=&limit=50)
+func (th *TranscriptsHandler) SearchTranscripts(c *gin.Context) {
+	query := c.Query("q")
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	matches, err := th.db.SearchTranscripts(query, c.Query("domain"), limit)
+	if errors.Is(err, services.ErrInvalidTranscriptQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid search query"})
+		return
+	}
+	if err != nil {
+		log.Printf("[Transcription] Search failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search transcripts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"query": query, "results": matches})
+}
+
+// GetTranscripts returns the transcripts of a CDR's recordings
+func (th *TranscriptsHandler) GetTranscripts(c *gin.Context) {
+	cdrID := c.Param("cdr_id")
+
+	transcripts, err := th.db.GetTranscripts(cdrID)
+	if err != nil {
+		log.Printf("[Transcription] Failed to load transcripts for CDR %s: %v", cdrID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load transcripts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"cdr_id": cdrID, "transcripts": transcripts})
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VoicemailReportHandler serves the calls to voicemail report
+type VoicemailReportHandler struct {
+	reports *services.VoicemailReportService
+}
+
+// NewVoicemailReportHandler creates a new voicemail report handler
+func NewVoicemailReportHandler(reports *services.VoicemailReportService) *VoicemailReportHandler {
+	return &VoicemailReportHandler{
+		reports: reports,
+	}
+}
+
+// GetVoicemailReport counts the calls that went to each user's voicemail, with how many
+// left a message and how long they rang first (?user= for one user, ?hours=24 for the window)
+func (vh *VoicemailReportHandler) GetVoicemailReport(c *gin.Context) {
+	domain := c.Param("domain")
+
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil || hours <= 0 || hours > 24*31 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hours must be between 1 and 744"})
+		return
+	}
+
+	report, err := vh.reports.GetVoicemailReport(domain, c.Query("user"), time.Duration(hours)*time.Hour)
+	if err != nil {
+		log.Printf("[Voicemail] Failed to build voicemail report for %s: %v", domain, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to build voicemail report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -5,10 +5,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log" // logging line
 	"net/http"
 	"o-dan-go/services"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -19,8 +22,11 @@ import (
 
 // ShowSPA serves the single page application
 func ShowSPA(c *gin.Context) {
+	prefs := preferencesFromContext(c)
+
 	c.HTML(http.StatusOK, "spa.html", gin.H{
-		"title": "O Dan Go - CDR Discovery",
+		"title":        "O Dan Go - CDR Discovery",
+		"defaultLimit": prefs.DefaultLimit,
 	})
 }
 
@@ -34,13 +40,19 @@ func ShowWelcomePage(c *gin.Context) {
 
 // ShowSearchForm displays the CDR search form
 func ShowSearchForm(c *gin.Context) {
+	prefs := preferencesFromContext(c)
+
 	c.HTML(http.StatusOK, "search.html", gin.H{
-		"title": "CDR Search - O Dan Go",
+		"title":        "CDR Search - O Dan Go",
+		"defaultLimit": prefs.DefaultLimit,
 	})
 }
 
-// ProcessSearchForm handles search form submission with enhanced validation, with API credentials
-func ProcessSearchForm(cdrService *services.CDRDiscoveryService) gin.HandlerFunc {
+// ProcessSearchForm handles search form submission with enhanced validation, with API credentials.
+// Results are enriched with carrier and LRN data when an enrichment provider is configured,
+// and scanned for toll fraud, scored for spam and stored in the background when those are turned on.
+// Alert rules are checked against every search.
+func ProcessSearchForm(cdrService *services.CDRDiscoveryService, hooks *SearchHooks) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get API credentials from form
 		apiURL := c.PostForm("api_url")
@@ -71,12 +83,13 @@ func ProcessSearchForm(cdrService *services.CDRDiscoveryService) gin.HandlerFunc
 
 		startDate := c.PostForm("start_date")
 		endDate := c.PostForm("end_date")
-		limitStr := c.DefaultPostForm("limit", "100")
+		prefs := preferencesFromContext(c)
+		limitStr := c.DefaultPostForm("limit", strconv.Itoa(prefs.DefaultLimit))
 
 		// Parse limit safely
 		limit, err := strconv.Atoi(limitStr)
 		if err != nil {
-			limit = 100 // Default fallback
+			limit = prefs.DefaultLimit // Default fallback
 		}
 
 		// **** Validation
@@ -104,6 +117,22 @@ func ProcessSearchForm(cdrService *services.CDRDiscoveryService) gin.HandlerFunc
 			OriginatingNumber: originatingNumber,
 			TerminatingNumber: terminatingNumber,
 			AnyPhoneNumber:    anyPhoneNumber,
+			Dedup:             c.PostForm("dedup"),
+			QueryAllEndpoints: c.PostForm("query_all_endpoints") != "",
+			CountFirst:        c.PostForm("count_first") != "",
+			AllowLargePull:    c.PostForm("allow_large_pull") != "",
+		}
+		for _, endpoint := range strings.Split(c.PostForm("dedup_priority"), ",") {
+			if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+				criteria.DedupPriority = append(criteria.DedupPriority, endpoint)
+			}
+		}
+		if err := criteria.ValidateDedup(); err != nil {
+			c.HTML(http.StatusBadRequest, "error.html", gin.H{
+				"title": "Validation Error - O Dan Go",
+				"error": fmt.Sprintf("Search validation failed: %v", err),
+			})
+			return
 		}
 
 		// Parse dates if provided
@@ -124,6 +153,17 @@ func ProcessSearchForm(cdrService *services.CDRDiscoveryService) gin.HandlerFunc
 		// Use the user-provided CDR service instead of the default one
 		result, err := userCDRService.GetComprehensiveCDRs(criteria)
 
+		if errors.Is(err, services.ErrLargePull) {
+			// Offer to send the same search again with the large pull allowed
+			c.HTML(http.StatusBadRequest, "error.html", gin.H{
+				"title":          "Large Search - O Dan Go",
+				"error":          fmt.Sprintf("CDR search failed: %v", err),
+				"resubmit":       c.Request.PostForm,
+				"resubmitAction": "/web/search",
+				"resubmitLabel":  fmt.Sprintf("Fetch all %d CDRs", result.Plan.PlannedCDRs),
+			})
+			return
+		}
 		if err != nil {
 			log.Printf("[Web Handler] ERROR: CDR search failed: %v", err) // logging
 
@@ -139,13 +179,110 @@ func ProcessSearchForm(cdrService *services.CDRDiscoveryService) gin.HandlerFunc
 		log.Printf("[Web Handler] Session ID: %s", result.SessionID)
 		log.Printf("[Web Handler] Total CDRs: %d, Unique: %d", result.TotalCDRs, result.UniqueCDRs)
 
-		services.GlobalResultsStore.Store(result.SessionID, result)
+		hooks.complete(result)
 
 		// Redirect to results page with session ID
 		c.Redirect(http.StatusFound, "/web/results/"+result.SessionID)
 	}
 }
 
+// SearchHooks are the services a completed search passes through, whichever of them are
+// turned on. main builds them once for every way a search can complete.
+type SearchHooks struct {
+	Enricher      *services.NumberEnricher
+	FraudDetector *services.FraudDetector
+	SpamScorer    *services.SpamScorer
+	KPIs          *services.KPIService
+	Storage       *services.SearchStorage
+	Rules         *services.AlertRuleEngine
+	Sink          *services.ElasticsearchSink
+	ClickHouse    *services.ClickHouseWriter
+}
+
+// SearchCompleted handles a search that finished outside a request, such as a scheduled
+// run, the way a form search is handled: enriched, kept in the results store and passed
+// to the search hooks
+func SearchCompleted(hooks *SearchHooks) func(*services.CDRDiscoveryResult) {
+	return hooks.complete
+}
+
+// complete enriches a finished search, keeps it in the results store and runs the hooks
+func (h *SearchHooks) complete(result *services.CDRDiscoveryResult) {
+	if h.Enricher.Enabled() {
+		h.Enricher.EnrichCDRs(result.AllCDRs)
+	}
+	services.GlobalResultsStore.Store(result.SessionID, result)
+	h.run(result)
+}
+
+// run scans, scores, records, stores, indexes and writes to ClickHouse a completed
+// search and checks the alert rules against it in the background, for whichever of
+// those are turned on
+func (h *SearchHooks) run(result *services.CDRDiscoveryResult) {
+	if h.FraudDetector.ScansSearches() {
+		go func() {
+			if _, err := h.FraudDetector.Scan(result.SessionID, result.AllCDRs); err != nil {
+				log.Printf("[Web Handler] Fraud scan of %s failed: %v", result.SessionID, err)
+			}
+		}()
+	}
+	if h.SpamScorer.ScoresSearches() {
+		go func() {
+			if _, err := h.SpamScorer.ScoreCDRs(result.SessionID, result.AllCDRs); err != nil {
+				log.Printf("[Web Handler] Spam scoring of %s failed: %v", result.SessionID, err)
+			}
+		}()
+	}
+	if h.KPIs.RecordsSearches() {
+		go func() {
+			if _, err := h.KPIs.RecordCDRs(result.AllCDRs); err != nil {
+				log.Printf("[Web Handler] Recording call outcomes of %s failed: %v", result.SessionID, err)
+			}
+		}()
+	}
+	if h.Sink.IndexesSearches() {
+		if _, err := h.Sink.Begin(result.SessionID, len(result.AllCDRs)); err != nil {
+			log.Printf("[Web Handler] Indexing %s failed: %v", result.SessionID, err)
+		} else {
+			go h.Sink.IndexSession(result.SessionID, result.AllCDRs, result.StartTime)
+		}
+	}
+	if h.ClickHouse.WritesSearches() {
+		go func() {
+			if _, err := h.ClickHouse.WriteCDRs(result.SessionID, result.AllCDRs, result.StartTime); err != nil {
+				log.Printf("[Web Handler] Writing %s to ClickHouse failed: %v", result.SessionID, err)
+			}
+		}()
+	}
+	if h.Rules != nil {
+		go func() {
+			if _, err := h.Rules.EvaluateSession(result); err != nil {
+				log.Printf("[Web Handler] Checking alert h.Rules on %s failed: %v", result.SessionID, err)
+			}
+		}()
+	}
+	if h.Storage.StoresSearches() {
+		go func() {
+			stats, err := h.Storage.Store(result.SessionID, result.SearchCriteria, result.AllCDRs)
+			if err != nil {
+				log.Printf("[Web Handler] Storing %s failed: %v", result.SessionID, err)
+				return
+			}
+			if result.Quality != nil {
+				if err := h.Storage.RecordQuality(result.SessionID, result.Quality); err != nil {
+					log.Printf("[Web Handler] Recording the data quality of %s failed: %v", result.SessionID, err)
+				}
+			}
+			if result.RerunOf != "" {
+				if err := h.Storage.LinkRerun(result.SessionID, result.RerunOf); err != nil {
+					log.Printf("[Web Handler] Linking %s to %s failed: %v", result.SessionID, result.RerunOf, err)
+				}
+			}
+			log.Printf("[Web Handler] Stored %d CDR summaries of %s in %dms (%.0f rows/s)", stats.Rows, result.SessionID, stats.DurationMS, stats.RowsPerSecond)
+		}()
+	}
+}
+
 // NEW: Enhanced search validation function
 func validateSearchCriteria(domain, user, site, callID, originatingNumber, terminatingNumber, anyPhoneNumber, startDate, endDate string) []string {
 	var errors []string
@@ -242,6 +379,7 @@ func isValidPhoneNumber(phone string) bool {
 // ShowResults displays search results
 func ShowResults(c *gin.Context) {
 	sessionID := c.Param("session_id")
+	prefs := preferencesFromContext(c)
 
 	// Try to get results from memory store
 	result, exists := services.GlobalResultsStore.Get(sessionID)
@@ -250,6 +388,14 @@ func ShowResults(c *gin.Context) {
 		// Calculate query time
 		queryTime := result.EndTime.Sub(result.StartTime).Seconds()
 
+		// Mask phone numbers in endpoint URLs
+		policy, maskMode := maskingFromContext(c)
+		endpoints := make([]services.EndpointResult, len(result.EndpointResults))
+		for i, endpoint := range result.EndpointResults {
+			endpoint.URL = policy.MaskURL(endpoint.URL, maskMode)
+			endpoints[i] = endpoint
+		}
+
 		c.HTML(http.StatusOK, "results.html", gin.H{
 			"title":     "Search Results - O Dan Go",
 			"sessionID": sessionID,
@@ -259,7 +405,15 @@ func ShowResults(c *gin.Context) {
 			"uniqueCDRs":    result.UniqueCDRs,
 			"endpointCount": len(result.EndpointResults),
 			"queryTime":     fmt.Sprintf("%.2f", queryTime),
-			"endpoints":     result.EndpointResults,
+			"endpoints":     endpoints,
+			"skipped":       result.Skipped,
+			"plan":          result.Plan,
+			"exportFormat":  prefs.DefaultExportFormat,
+			"maskMode":      string(maskMode),
+			"rerunOf":       result.RerunOf,
+			"quality":       result.Quality,
+			"dedupStrategy": result.DedupStrategy,
+			"dedupMerged":   len(result.DedupWinners),
 		})
 	} else {
 		c.HTML(http.StatusOK, "results.html", gin.H{
@@ -285,7 +439,8 @@ func HealthCheck(c *gin.Context) {
 // ExportCDRs handles export requests for CDR data
 func ExportCDRs(c *gin.Context) {
 	sessionID := c.Param("session_id")
-	format := c.DefaultQuery("format", "csv")
+	prefs := preferencesFromContext(c)
+	format := c.DefaultQuery("format", prefs.DefaultExportFormat)
 
 	// Retrieve results from store
 	result, exists := services.GlobalResultsStore.Get(sessionID)
@@ -297,11 +452,62 @@ func ExportCDRs(c *gin.Context) {
 		return
 	}
 
+	filter, err := services.ParseCDRFilter(c.Query("filter"))
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"title": "Export Error",
+			"error": err.Error(),
+		})
+		return
+	}
+	view, err := services.ParseCDRView(c.Query("view"))
+	if err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"title": "Export Error",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Combine every endpoint's fields of each CDR when asked
+	if c.Query("merged") == "true" {
+		result = result.Merged()
+	}
+	// Keep only the flagged CDRs with ?flagged=true
+	result = flaggedOnly(c, result)
+
+	// Mask subscriber PII according to role and request
+	policy, maskMode := maskingFromContext(c)
+	if maskMode != services.MaskingNone {
+		masked := *result
+		masked.AllCDRs = policy.MaskCDRs(result.AllCDRs, maskMode)
+		masked.SearchCriteria = policy.MaskCriteria(result.SearchCriteria, maskMode)
+		masked.EndpointResults = make([]services.EndpointResult, len(result.EndpointResults))
+		for i, endpoint := range result.EndpointResults {
+			endpoint.URL = policy.MaskURL(endpoint.URL, maskMode)
+			masked.EndpointResults[i] = endpoint
+		}
+		result = &masked
+	}
+	// Export only the CDRs that pass the filter, checked after masking
+	if filter != nil {
+		filtered := *result
+		filtered.AllCDRs = filter.Apply(result.AllCDRs)
+		filtered.UniqueCDRs = len(filtered.AllCDRs)
+		result = &filtered
+	}
+
 	switch format {
 	case "csv":
-		exportCSV(c, result)
+		exportCSV(c, result, prefs)
 	case "json":
-		exportJSON(c, result)
+		exportJSON(c, result, view)
+	case "sqlite":
+		exportSQLite(c, result, maskMode)
+	case "xml":
+		exportXML(c, result)
+	case "es-bulk":
+		exportBulk(c, result, view)
 	default:
 		c.HTML(http.StatusBadRequest, "error.html", gin.H{
 			"title": "Export Error",
@@ -310,53 +516,32 @@ func ExportCDRs(c *gin.Context) {
 	}
 }
 
-// exportCSV exports CDR data as CSV
-func exportCSV(c *gin.Context, result *services.CDRDiscoveryResult) {
+// exportCSV exports CDR data as CSV using the user's visible columns and timezone
+func exportCSV(c *gin.Context, result *services.CDRDiscoveryResult, prefs *services.UserPreferences) {
 	// Set headers for CSV download
 	filename := fmt.Sprintf("cdrs_%s.csv", result.SessionID)
 	c.Header("Content-Type", "text/csv")
 	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
 
-	// Write CSV header - using common CDR fields
-	csvHeader := []string{
-		"call_id",
-		"domain",
-		"user",
-		"orig_number",
-		"term_number",
-		"start_time",
-		"end_time",
-		"duration",
-		"call_type",
-		"direction",
-		"disposition",
-		"session_id",
-	}
+	// Write CSV header - using the user's chosen columns
+	csvHeader := prefs.CSVColumns()
+	loc := prefs.Location()
 
 	c.Writer.Write([]byte(strings.Join(csvHeader, ",") + "\n"))
 
 	// Write CDR data
-	for _, cdr := range result.AllCDRs {
-		row := []string{
-			escapeCSV(cdr.GetString("call-id")),
-			escapeCSV(cdr.GetDomain()),
-			escapeCSV(cdr.GetString("user")),
-			escapeCSV(cdr.GetString("orig-number")),
-			escapeCSV(cdr.GetString("term-number")),
-			escapeCSV(cdr.GetString("start-time")),
-			escapeCSV(cdr.GetString("end-time")),
-			escapeCSV(fmt.Sprintf("%d", cdr.GetInt("duration"))),
-			escapeCSV(cdr.GetString("call-type")),
-			escapeCSV(cdr.GetString("direction")),
-			escapeCSV(cdr.GetString("disposition")),
-			escapeCSV(result.SessionID),
+	for i := range result.AllCDRs {
+		row := make([]string, 0, len(csvHeader))
+		for _, column := range csvHeader {
+			row = append(row, escapeCSV(exportColumnValue(prefs, &result.AllCDRs[i], column, result.SessionID, loc)))
 		}
 		c.Writer.Write([]byte(strings.Join(row, ",") + "\n"))
 	}
 }
 
-// exportJSON exports CDR data as JSON
-func exportJSON(c *gin.Context, result *services.CDRDiscoveryResult) {
+// exportJSON exports CDR data as JSON, the raw CDRs or with ?view=normalized the
+// normalized ones
+func exportJSON(c *gin.Context, result *services.CDRDiscoveryResult, view string) {
 	// Set headers for JSON download
 	filename := fmt.Sprintf("cdrs_%s.json", result.SessionID)
 	c.Header("Content-Type", "application/json")
@@ -372,6 +557,17 @@ func exportJSON(c *gin.Context, result *services.CDRDiscoveryResult) {
 		"export_time":     time.Now().UTC(),
 		"cdrs":            result.AllCDRs,
 	}
+	if _, maskMode := maskingFromContext(c); maskMode != services.MaskingNone {
+		export["masking"] = maskMode
+	}
+	if view == services.CDRViewNormalized {
+		export["view"] = view
+		export["cdrs"] = services.NormalizeCDRs(result.AllCDRs)
+	}
+	if result.FieldSources != nil {
+		export["merged"] = true
+		export["field_sources"] = result.FieldSources
+	}
 
 	// Pretty print JSON
 	encoder := json.NewEncoder(c.Writer)
@@ -379,6 +575,69 @@ func exportJSON(c *gin.Context, result *services.CDRDiscoveryResult) {
 	encoder.Encode(export)
 }
 
+// exportXML exports CDR data as an IPDR-style XML document
+func exportXML(c *gin.Context, result *services.CDRDiscoveryResult) {
+	filename := fmt.Sprintf("cdrs_%s.xml", result.SessionID)
+	c.Header("Content-Type", "application/xml")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	if err := services.WriteIPDRDocument(c.Writer, result); err != nil {
+		log.Printf("[Web Handler] ERROR: XML export of %s failed: %v", result.SessionID, err)
+	}
+}
+
+// exportBulk exports CDR data as Elasticsearch bulk actions into ?index=
+func exportBulk(c *gin.Context, result *services.CDRDiscoveryResult, view string) {
+	index := c.DefaultQuery("index", services.DefaultBulkIndex)
+	if err := services.ValidateBulkIndex(index); err != nil {
+		c.HTML(http.StatusBadRequest, "error.html", gin.H{
+			"title": "Export Error",
+			"error": err.Error(),
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("cdrs_%s.ndjson", result.SessionID)
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	if err := services.WriteBulkActions(c.Writer, result.AllCDRs, index, view); err != nil {
+		log.Printf("[Web Handler] ERROR: bulk export of %s failed: %v", result.SessionID, err)
+	}
+}
+
+// exportSQLite exports the session, its endpoint results and raw CDRs as a SQLite file
+func exportSQLite(c *gin.Context, result *services.CDRDiscoveryResult, maskMode services.MaskingMode) {
+	dir, err := os.MkdirTemp("", "odango-export-")
+	if err != nil {
+		log.Printf("[Web Handler] ERROR: SQLite export of %s failed: %v", result.SessionID, err)
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+			"title": "Export Error",
+			"error": "Failed to create the session database",
+		})
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	filename := fmt.Sprintf("cdrs_%s.sqlite", result.SessionID)
+	path := filepath.Join(dir, filename)
+	masking := ""
+	if maskMode != services.MaskingNone {
+		masking = string(maskMode)
+	}
+	if err := services.WriteSessionDatabase(result, masking, path); err != nil {
+		log.Printf("[Web Handler] ERROR: SQLite export of %s failed: %v", result.SessionID, err)
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+			"title": "Export Error",
+			"error": "Failed to create the session database",
+		})
+		return
+	}
+
+	c.Header("Content-Type", "application/vnd.sqlite3")
+	c.FileAttachment(path, filename)
+}
+
 // escapeCSV escapes special characters in CSV fields
 func escapeCSV(field string) string {
 	// If field contains comma, quote, or newline, wrap in quotes
@@ -410,23 +669,52 @@ func GetCDRsAPI(c *gin.Context) {
 
 	log.Printf("[GetCDRsAPI] Found session with %d CDRs", len(result.AllCDRs))
 
-	// Prepare CDR data for preview
+	filter, err := services.ParseCDRFilter(c.Query("filter"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	merged := c.Query("merged") == "true"
+	if merged {
+		result = result.Merged()
+	}
+	result = flaggedOnly(c, result)
+
+	// Preview only the columns the user has chosen, in their timezone
+	prefs := preferencesFromContext(c)
+	columns := prefs.Columns()
+	loc := prefs.Location()
+	policy, maskMode := maskingFromContext(c)
+
+	// Prepare CDR data for preview. The filter sees the CDRs as masked, so masked
+	// fields can't be probed with it.
 	var previewCDRs []map[string]interface{}
-	count := 0
-	for _, cdr := range result.AllCDRs {
-		if count >= limit {
+	var previewIDs []string
+	count, matched := 0, 0
+	for i := range result.AllCDRs {
+		if filter == nil && count >= limit {
+			matched = len(result.AllCDRs)
 			break
 		}
 
-		// Extract common fields for preview
-		previewCDRs = append(previewCDRs, map[string]interface{}{
-			"call_id":     cdr.GetID(),                          // Use GetID() method
-			"domain":      cdr.GetDomain(),                      // Use GetDomain() method
-			"orig_number": cdr.GetString("call-orig-caller-id"), // Correct field name
-			"term_number": cdr.GetString("call-term-caller-id"), // Correct field name
-			"start_time":  cdr.GetString("call-start-datetime"), // Correct field name
-			"duration":    cdr.GetInt("call-duration"),          // Correct field name
-		})
+		cdr := policy.MaskCDR(result.AllCDRs[i], maskMode)
+		if !filter.Match(&cdr) {
+			continue
+		}
+		matched++
+		if count >= limit {
+			continue
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for _, column := range columns {
+			row[column] = exportColumnValue(prefs, &cdr, column, sessionID, loc)
+		}
+		previewCDRs = append(previewCDRs, row)
+		previewIDs = append(previewIDs, cdr.GetID())
 		count++
 	}
 
@@ -435,7 +723,14 @@ func GetCDRsAPI(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"session_id": sessionID,
 		"total":      len(result.AllCDRs),
+		"matched":    matched,
+		"filter":     filter.String(),
+		"merged":     merged,
 		"limit":      limit,
+		"columns":    columns,
+		"timezone":   prefs.Timezone,
+		"masking":    maskMode,
 		"cdrs":       previewCDRs,
+		"cdr_ids":    previewIDs, // of each previewed CDR, for flagging
 	})
 }
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"fmt"
+	"log"
 	"net/http"
 	"o-dan-go/services"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,30 +22,82 @@ func NewWebResponderHandler(wrService *services.WebResponderService) *WebRespond
 	}
 }
 
-// HandleWeatherIVR handles weather IVR requests from NetSapiens
-func (wrh *WebResponderHandler) HandleWeatherIVR(c *gin.Context) {
-	// Get parameters from NetSapiens
-	callerNumber := c.Query("NmsAni")
-	digits := c.Query("Digits")
+// HandleIVRApp dispatches /wr/:app requests from NetSapiens to the registered IVR app
+func (wrh *WebResponderHandler) HandleIVRApp(c *gin.Context) {
+	appName := c.Param("app")
 
-	// Get or create session
-	session, err := wrh.wrService.GetSession(c.Request, "weather-ivr-session")
+	app, exists := wrh.wrService.GetApp(appName)
+	if !exists {
+		c.String(http.StatusNotFound, "Unknown IVR app")
+		return
+	}
+
+	// Collect parameters from NetSapiens (query string for GET, form body for POST)
+	if err := c.Request.ParseForm(); err != nil {
+		c.String(http.StatusBadRequest, "Invalid request parameters")
+		return
+	}
+	values := make(map[string]string, len(c.Request.Form))
+	for key := range c.Request.Form {
+		values[key] = c.Request.Form.Get(key)
+	}
+
+	params := services.IVRParams{
+		App:          appName,
+		CallerNumber: values["NmsAni"],
+		DialedNumber: values["NmsDnis"],
+		Digits:       values["Digits"],
+		Speech:       values["SpeechResult"],
+		Confidence:   1,
+		Values:       values,
+		Timezone:     services.CallerTimezone(values["NmsAni"]),
+	}
+	if confidence, err := strconv.ParseFloat(values["Confidence"], 64); err == nil {
+		params.Confidence = confidence
+	}
+
+	// Get or create session, one cookie per app
+	session, err := wrh.wrService.GetSession(c.Request, fmt.Sprintf("%s-ivr-session", appName))
 	if err != nil {
 		c.String(http.StatusInternalServerError, "Session error")
 		return
 	}
 
+	// Map spoken input to digits using the choices offered by the previous Gather
+	params = wrh.wrService.ResolveSpeech(session, params)
+
 	// Process the IVR request
-	xmlResponse, err := wrh.wrService.ProcessWeatherIVR(session, callerNumber, digits)
+	response, err := app.Handle(session, params)
 	if err != nil {
+		log.Printf("[WR] App %s failed: %v", appName, err)
 		c.String(http.StatusInternalServerError, "Processing error")
 		return
 	}
 
+	wrh.wrService.PrepareSpeech(session, &response)
+
 	// Save session
 	session.Save(c.Request, c.Writer)
 
 	// Return XML response for NetSapiens
 	c.Header("Content-Type", "text/xml")
-	c.String(http.StatusOK, xmlResponse)
+	c.String(http.StatusOK, wrh.wrService.GenerateXMLResponse(response))
+}
+
+// ListIVRApps returns the registered IVR apps and their endpoints
+func (wrh *WebResponderHandler) ListIVRApps(c *gin.Context) {
+	names := wrh.wrService.AppNames()
+
+	apps := make([]gin.H, 0, len(names))
+	for _, name := range names {
+		apps = append(apps, gin.H{
+			"name":     name,
+			"endpoint": "/wr/" + name,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"apps":  apps,
+		"count": len(apps),
+	})
 }
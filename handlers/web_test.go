@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"o-dan-go/models"
+	"o-dan-go/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProcessSearchFormLargePull(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pbx := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/count") {
+			w.Write([]byte(`{"count": 3000000}`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer pbx.Close()
+
+	router := gin.New()
+	router.LoadHTMLGlob("../templates/*")
+	router.POST("/web/search", ProcessSearchForm(nil, &SearchHooks{}))
+	search := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/web/search", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	form := url.Values{"api_url": {pbx.URL}, "api_token": {"token"}, "domain": {"acme.example.com"}, "limit": {"1000000"}, "count_first": {"1"}}
+	refused := search(form)
+	if refused.Code != http.StatusBadRequest || !strings.Contains(refused.Body.String(), `name="allow_large_pull" value="1"`) {
+		t.Fatalf("large pull = %d, want it refused with a form to allow it:\n%s", refused.Code, refused.Body.String())
+	}
+	if !strings.Contains(refused.Body.String(), `name="limit" value="1000000"`) {
+		t.Error("the refusal doesn't send the search back")
+	}
+
+	form.Set("allow_large_pull", "1")
+	if allowed := search(form); allowed.Code != http.StatusFound || !strings.HasPrefix(allowed.Header().Get("Location"), "/web/results/") {
+		t.Errorf("allowed large pull = %d %s, want a redirect to the results", allowed.Code, allowed.Body.String())
+	}
+}
+
+func TestExportSQLiteMasksEndpointURLs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policy, err := services.NewMaskingPolicy("none", "", "salt")
+	if err != nil {
+		t.Fatalf("NewMaskingPolicy: %v", err)
+	}
+	services.GlobalResultsStore.Store("export-masked", &services.CDRDiscoveryResult{
+		SessionID:      "export-masked",
+		SearchCriteria: services.CDRSearchCriteria{OriginatingNumber: "4155551234"},
+		AllCDRs:        []models.FlexibleCDR{{RawData: map[string]interface{}{"id": "cdr-1", "call-orig-caller-id": "4155551234"}}},
+		EndpointResults: []services.EndpointResult{{
+			EndpointName: "global_cdrs",
+			URL:          "http://pbx.example.com/ns-api/v2/cdrs?limit=10&orig_number=4155551234",
+			Success:      true,
+		}},
+	})
+	defer services.GlobalResultsStore.Delete("export-masked")
+
+	router := gin.New()
+	router.LoadHTMLGlob("../templates/*")
+	router.GET("/export/:session_id", ResolveMasking(policy), ExportCDRs)
+	export := func(query string) []byte {
+		req := httptest.NewRequest(http.MethodGet, "/export/export-masked?format=sqlite"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("export%s = %d %s", query, w.Code, w.Body.String())
+		}
+		return w.Body.Bytes()
+	}
+
+	if !bytes.Contains(export(""), []byte("4155551234")) {
+		t.Fatal("unmasked export doesn't hold the number; the test can't tell masking apart")
+	}
+	if masked := export("&mask=hash"); bytes.Contains(masked, []byte("4155551234")) {
+		t.Error("masked SQLite export still holds the searched number")
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"o-dan-go/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WRAnalyticsHandler serves Web Responder call analytics
+type WRAnalyticsHandler struct {
+	analytics *services.WRAnalyticsService
+}
+
+// NewWRAnalyticsHandler creates a new analytics handler
+func NewWRAnalyticsHandler(analytics *services.WRAnalyticsService) *WRAnalyticsHandler {
+	return &WRAnalyticsHandler{
+		analytics: analytics,
+	}
+}
+
+// GetCalls returns recent IVR calls (?limit=50)
+func (h *WRAnalyticsHandler) GetCalls(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 50
+	}
+
+	calls, err := h.analytics.RecentCalls(limit)
+	if err != nil {
+		log.Printf("[Analytics] Failed to load calls: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calls"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"calls": calls,
+		"count": len(calls),
+	})
+}
+
+// GetCallVolume returns calls per day or hour (?from=&to=&interval=day|hour)
+func (h *WRAnalyticsHandler) GetCallVolume(c *gin.Context) {
+	from, to, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	interval := c.DefaultQuery("interval", "day")
+
+	points, err := h.analytics.CallVolume(from, to, interval)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	total := 0
+	for _, point := range points {
+		total += point.Calls
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":        from,
+		"to":          to,
+		"interval":    interval,
+		"volume":      points,
+		"total_calls": total,
+	})
+}
+
+// GetMenuPopularity returns how often each menu digit was chosen (?from=&to=&app=)
+func (h *WRAnalyticsHandler) GetMenuPopularity(c *gin.Context) {
+	from, to, err := parseTimeRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	options, err := h.analytics.MenuPopularity(from, to, c.Query("app"))
+	if err != nil {
+		log.Printf("[Analytics] Failed to load menu popularity: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load menu popularity"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":    from,
+		"to":      to,
+		"options": options,
+	})
+}
+
+// GetStats returns summary metrics for today, or for ?date=YYYY-MM-DD
+func (h *WRAnalyticsHandler) GetStats(c *gin.Context) {
+	day := time.Now()
+	if date := c.Query("date"); date != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", date, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date: use YYYY-MM-DD"})
+			return
+		}
+		day = parsed
+	}
+
+	stats, err := h.analytics.Stats(day)
+	if err != nil {
+		log.Printf("[Analytics] Failed to compute stats: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// parseTimeRange reads ?from= and ?to= as RFC3339 or YYYY-MM-DD, defaulting to the last 7 days
+func parseTimeRange(c *gin.Context) (time.Time, time.Time, error) {
+	from, err := parseTimeParam(c, "from")
+	if err != nil {
+		return from, time.Time{}, err
+	}
+	to, err := parseTimeParam(c, "to")
+	if err != nil {
+		return from, to, err
+	}
+
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		from = to.AddDate(0, 0, -7)
+	}
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("from must be before to")
+	}
+
+	return from, to, nil
+}
+
+// parseTimeParam reads a query parameter as RFC3339 or YYYY-MM-DD, returning the zero time if it's absent
+func parseTimeParam(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid %s %q: use RFC3339 or YYYY-MM-DD", name, value)
+}
@@ -3,11 +3,11 @@ package handlers
 import (
 	"fmt"
 	"log"
-	"math/rand"
 	"net/http"
 	"o-dan-go/events"
 	"o-dan-go/services"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -15,27 +15,26 @@ import (
 
 // WRDashboardHandler handles the Web Responder dashboard
 type WRDashboardHandler struct {
-	clients   map[*websocket.Conn]bool
-	broadcast chan events.CallEvent
+	hub       *dashboardHub
 	upgrader  websocket.Upgrader
+	analytics *services.WRAnalyticsService
+	simulator *services.CallSimulator
 }
 
-// NewWRDashboardHandler creates a new dashboard handler
-func NewWRDashboardHandler() *WRDashboardHandler {
+// NewWRDashboardHandler creates a new dashboard handler; checkOrigin decides which
+// origins may open the dashboard WebSocket
+func NewWRDashboardHandler(analytics *services.WRAnalyticsService, simulator *services.CallSimulator, checkOrigin func(r *http.Request) bool) *WRDashboardHandler {
 	handler := &WRDashboardHandler{
-		clients:   make(map[*websocket.Conn]bool),
-		broadcast: make(chan events.CallEvent),
+		hub: newDashboardHub(),
 		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				// Allow all origins in development
-				// TODO: Restrict in production
-				return true
-			},
+			CheckOrigin: checkOrigin,
 		},
+		analytics: analytics,
+		simulator: simulator,
 	}
 
 	// Start broadcasting events
-	go handler.broadcastEvents()
+	go handler.hub.run(events.Manager.Subscribe())
 
 	return handler
 }
@@ -47,255 +46,192 @@ func (h *WRDashboardHandler) ShowDashboard(c *gin.Context) {
 	})
 }
 
-// GetActiveCalls returns current active calls as JSON
+// GetActiveCalls returns current active calls as JSON, filtered by ?area_code=&caller_prefix=&from=&to=
+// (from and to apply to the call's start time)
 func (h *WRDashboardHandler) GetActiveCalls(c *gin.Context) {
-	calls := events.Manager.GetActiveCalls()
+	query, err := parseEventQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	calls := []events.ActiveCall{}
+	for _, call := range events.Manager.GetActiveCalls() {
+		if !query.MatchesCaller(call.AreaCode, call.CallerNum) {
+			continue
+		}
+		if !query.From.IsZero() && call.StartTime.Before(query.From) {
+			continue
+		}
+		if !query.To.IsZero() && !call.StartTime.Before(query.To) {
+			continue
+		}
+		calls = append(calls, call)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"calls": calls,
 		"count": len(calls),
 	})
 }
 
-// GetRecentEvents returns recent events (mock data for now)
+// GetRecentEvents returns a page of event history, newest first
+// (?limit=50&offset=0&from=&to=&area_code=&event_type=&caller_prefix=)
 func (h *WRDashboardHandler) GetRecentEvents(c *gin.Context) {
-	// TODO: Implement actual event history storage
-	events := []gin.H{
-		{
-			"timestamp": time.Now().Add(-5 * time.Minute).Format(time.RFC3339),
-			"type":      "call_started",
-			"details":   "Call from 415-555-1234",
-		},
-		{
-			"timestamp": time.Now().Add(-3 * time.Minute).Format(time.RFC3339),
-			"type":      "dtmf_received",
-			"details":   "Pressed 2 for temperature",
-		},
-		{
-			"timestamp": time.Now().Add(-2 * time.Minute).Format(time.RFC3339),
-			"type":      "response_sent",
-			"details":   "Temperature: 72°F",
-		},
+	query, err := parseEventQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, total, err := h.analytics.Events(query)
+	source := "database"
+	if err != nil {
+		// Fall back to the events still held in memory
+		log.Printf("[WR] Failed to load event history, using in-memory events: %v", err)
+		history, total = memoryEvents(query)
+		source = "memory"
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"events": events,
+		"events": history,
+		"count":  len(history),
+		"total":  total,
+		"limit":  query.Limit,
+		"offset": query.Offset,
+		"source": source,
 	})
 }
 
-// HandleWebSocket manages WebSocket connections for real-time updates
-func (h *WRDashboardHandler) HandleWebSocket(c *gin.Context) {
-	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
-	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
-		return
+// parseEventQuery reads the dashboard's paging and filter parameters
+func parseEventQuery(c *gin.Context) (services.EventQuery, error) {
+	query := services.EventQuery{
+		Limit:     50,
+		EventType: strings.TrimSpace(c.Query("event_type")),
 	}
-	defer conn.Close()
-
-	// Register new client
-	h.clients[conn] = true
-	log.Printf("WebSocket client connected. Total clients: %d", len(h.clients))
-
-	// Subscribe to events
-	eventListener := events.Manager.Subscribe()
-	defer events.Manager.Unsubscribe(eventListener)
-
-	// Send initial state
-	activeCalls := events.Manager.GetActiveCalls()
-	err = conn.WriteJSON(gin.H{
-		"type":  "initial",
-		"calls": activeCalls,
-	})
-	if err != nil {
-		log.Printf("Error sending initial state: %v", err)
-		delete(h.clients, conn)
-		return
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && limit <= events.HistorySize {
+		query.Limit = limit
+	}
+	if offset, err := strconv.Atoi(c.Query("offset")); err == nil && offset > 0 {
+		query.Offset = offset
 	}
 
-	// Create channels for coordinating goroutines
-	done := make(chan struct{})
-
-	// Handle incoming messages from client (ping/pong)
-	go func() {
-		defer close(done)
-		for {
-			_, _, err := conn.ReadMessage()
-			if err != nil {
-				log.Printf("WebSocket read error: %v", err)
-				return
-			}
+	if areaCode := strings.TrimSpace(c.Query("area_code")); areaCode != "" {
+		if len(areaCode) != 3 || strings.Trim(areaCode, "0123456789") != "" {
+			return query, fmt.Errorf("invalid area_code %q: expected 3 digits", areaCode)
 		}
-	}()
-
-	// Send events to this client
-	for {
-		select {
-		case event := <-eventListener:
-			// Send event to client
-			err := conn.WriteJSON(gin.H{
-				"type":  "event",
-				"event": event,
-			})
-			if err != nil {
-				log.Printf("WebSocket write error: %v", err)
-				delete(h.clients, conn)
-				return
-			}
-
-			// Also send updated active calls
-			if event.EventType == "call_started" || event.EventType == "call_ended" {
-				activeCalls := events.Manager.GetActiveCalls()
-				err = conn.WriteJSON(gin.H{
-					"type":  "update",
-					"calls": activeCalls,
-				})
-				if err != nil {
-					log.Printf("WebSocket write error: %v", err)
-					delete(h.clients, conn)
-					return
-				}
-			}
+		query.AreaCode = areaCode
+	}
 
-		case <-done:
-			// Client disconnected
-			delete(h.clients, conn)
-			log.Printf("WebSocket client disconnected. Total clients: %d", len(h.clients))
-			return
+	// Callers type numbers with punctuation; the match also ignores the leading 1 on either side
+	query.CallerPrefix = strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
 		}
+		return -1
+	}, c.Query("caller_prefix"))
+
+	var err error
+	if query.From, err = parseTimeParam(c, "from"); err != nil {
+		return query, err
 	}
+	if query.To, err = parseTimeParam(c, "to"); err != nil {
+		return query, err
+	}
+
+	return query, nil
 }
 
-// broadcastEvents sends events to all connected clients
-func (h *WRDashboardHandler) broadcastEvents() {
-	for event := range h.broadcast {
-		for client := range h.clients {
-			err := client.WriteJSON(gin.H{
-				"type":  "event",
-				"event": event,
-			})
-			if err != nil {
-				log.Printf("Broadcast error: %v", err)
-				client.Close()
-				delete(h.clients, client)
-			}
+// memoryEvents pages through the event manager's ring buffer
+func memoryEvents(query services.EventQuery) ([]events.CallEvent, int) {
+	matched := []events.CallEvent{}
+	for _, event := range events.Manager.RecentEvents(0) {
+		if query.Matches(event) {
+			matched = append(matched, event)
 		}
 	}
-}
 
-// TestCall simulates an incoming call for testing
-func (h *WRDashboardHandler) TestCall(c *gin.Context) {
-	// Test phone numbers from different cities
-	testNumbers := []string{
-		"4155551234", // San Francisco
-		"2125551234", // New York
-		"3125551234", // Chicago
-		"5125551234", // Austin
-		"7025551234", // Las Vegas
-		"3055551234", // Miami
-		"2065551234", // Seattle
-		"6175551234", // Boston
+	total := len(matched)
+	if query.Offset >= total {
+		return []events.CallEvent{}, total
 	}
+	end := query.Offset + query.Limit
+	if end > total {
+		end = total
+	}
+	return matched[query.Offset:end], total
+}
 
-	// Pick a random number
-	randomNum := testNumbers[rand.Intn(len(testNumbers))]
-	areaCode := randomNum[:3]
-
-	// Look up location
-	location, exists := services.CompleteAreaCodes[areaCode]
-	if !exists {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid test number",
-		})
+// HandleWebSocket manages WebSocket connections for real-time updates
+func (h *WRDashboardHandler) HandleWebSocket(c *gin.Context) {
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
 
-	// Generate IDs
-	sessionID := fmt.Sprintf("test_%s_%d", areaCode, time.Now().Unix())
-	callID := fmt.Sprintf("call_%d", time.Now().Unix())
-
-	// Send call started event
-	startEvent := events.CallEvent{
-		SessionID: sessionID,
-		CallID:    callID,
-		CallerNum: randomNum,
-		AreaCode:  areaCode,
-		Location:  fmt.Sprintf("%s, %s", location.City, location.State),
-		EventType: "call_started",
-		Details:   "Test call initiated",
-		Timestamp: time.Now(),
+	client := &dashboardClient{
+		hub:  h.hub,
+		conn: conn,
+		send: make(chan gin.H, wsSendQueueSize),
 	}
-	events.SendEvent(startEvent)
 
-	// Simulate DTMF after 2 seconds
-	go func() {
-		time.Sleep(2 * time.Second)
+	// Send initial state ahead of any events
+	client.send <- gin.H{
+		"type":  "initial",
+		"calls": events.Manager.GetActiveCalls(),
+	}
+	h.hub.register <- client
 
-		// Random button press
-		digits := []string{"1", "2", "3"}
-		digit := digits[rand.Intn(len(digits))]
+	go client.writePump()
+	client.readPump()
+}
 
-		dtmfEvent := events.CallEvent{
-			SessionID: sessionID,
-			CallID:    callID,
-			CallerNum: randomNum,
-			AreaCode:  areaCode,
-			Location:  fmt.Sprintf("%s, %s", location.City, location.State),
-			EventType: "dtmf_received",
-			Details:   fmt.Sprintf("Pressed %s", digit),
-			Timestamp: time.Now(),
+// SimulateCall plays a scripted call against the IVR apps. The body is a SimulationScenario;
+// an empty body runs the demo scenario. With ?async=true the call runs in the background
+// (the dashboard's Simulate Call button), otherwise the response reports every step.
+func (h *WRDashboardHandler) SimulateCall(c *gin.Context) {
+	scenario := services.DefaultSimulationScenario()
+	if c.Request.ContentLength != 0 {
+		scenario = services.SimulationScenario{}
+		if err := c.ShouldBindJSON(&scenario); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid scenario: %v", err)})
+			return
 		}
-		events.SendEvent(dtmfEvent)
-
-		// Simulate response after 1 second
-		time.Sleep(1 * time.Second)
+	}
 
-		var responseDetail string
-		switch digit {
-		case "1":
-			responseDetail = "Local time: 3:45 PM"
-		case "2":
-			responseDetail = fmt.Sprintf("Temperature: %d°F", rand.Intn(30)+50)
-		case "3":
-			responseDetail = fmt.Sprintf("AQI: %d (Good)", rand.Intn(50)+20)
-		}
+	if err := h.simulator.Validate(&scenario); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-		responseEvent := events.CallEvent{
-			SessionID: sessionID,
-			CallID:    callID,
-			CallerNum: randomNum,
-			AreaCode:  areaCode,
-			Location:  fmt.Sprintf("%s, %s", location.City, location.State),
-			EventType: "response_sent",
-			Details:   responseDetail,
-			Timestamp: time.Now(),
-		}
-		events.SendEvent(responseEvent)
+	if c.Query("async") == "true" {
+		go h.simulator.Run(scenario)
+		c.JSON(http.StatusAccepted, gin.H{
+			"status":  "started",
+			"call_id": scenario.CallID,
+			"caller":  scenario.CallerNumber,
+			"app":     scenario.App,
+		})
+		return
+	}
 
-		// End call after 2 seconds
-		time.Sleep(2 * time.Second)
+	c.JSON(http.StatusOK, h.simulator.Run(scenario))
+}
 
-		endEvent := events.CallEvent{
-			SessionID: sessionID,
-			CallID:    callID,
-			CallerNum: randomNum,
-			AreaCode:  areaCode,
-			Location:  fmt.Sprintf("%s, %s", location.City, location.State),
-			EventType: "call_ended",
-			Details:   "Test call completed",
-			Timestamp: time.Now(),
-		}
-		events.SendEvent(endEvent)
-	}()
+// RunLoadTest runs many simulated calls and reports latency percentiles (body: LoadTestConfig).
+// It answers once every call has finished.
+func (h *WRDashboardHandler) RunLoadTest(c *gin.Context) {
+	var config services.LoadTestConfig
+	if err := c.ShouldBindJSON(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid load test: %v", err)})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"status":   "success",
-		"message":  "Test call initiated",
-		"call_id":  callID,
-		"caller":   randomNum,
-		"location": fmt.Sprintf("%s, %s", location.City, location.State),
-	})
-}
+	if err := h.simulator.ValidateLoadTest(&config); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-// SimulateCall is an alias for TestCall for compatibility
-func (h *WRDashboardHandler) SimulateCall(c *gin.Context) {
-	h.TestCall(c)
+	c.JSON(http.StatusOK, h.simulator.RunLoadTest(config))
 }
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"log"
+	"o-dan-go/events"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a message to a dashboard
+	wsWriteWait = 10 * time.Second
+	// Dashboards must answer pings within this long or they are disconnected
+	wsPongWait = 60 * time.Second
+	// How often to ping dashboards; less than wsPongWait
+	wsPingPeriod = (wsPongWait * 9) / 10
+	// Dashboards only send pongs, so reads are small
+	wsMaxMessageSize = 512
+	// Messages queued per dashboard before it is considered too slow and dropped
+	wsSendQueueSize = 64
+)
+
+// dashboardClient is one connected dashboard. Only its writePump writes to conn.
+type dashboardClient struct {
+	hub  *dashboardHub
+	conn *websocket.Conn
+	send chan gin.H
+}
+
+// dashboardHub owns the set of connected dashboards and fans call events out to them.
+// The clients map is only touched by run, so registration needs no locking.
+type dashboardHub struct {
+	clients    map[*dashboardClient]bool
+	register   chan *dashboardClient
+	unregister chan *dashboardClient
+}
+
+func newDashboardHub() *dashboardHub {
+	return &dashboardHub{
+		clients:    make(map[*dashboardClient]bool),
+		register:   make(chan *dashboardClient),
+		unregister: make(chan *dashboardClient),
+	}
+}
+
+// run delivers events from the event manager to every dashboard
+func (hub *dashboardHub) run(listener chan events.CallEvent) {
+	for {
+		select {
+		case client := <-hub.register:
+			hub.clients[client] = true
+			log.Printf("WebSocket client connected. Total clients: %d", len(hub.clients))
+
+		case client := <-hub.unregister:
+			if hub.clients[client] {
+				hub.remove(client)
+				log.Printf("WebSocket client disconnected. Total clients: %d", len(hub.clients))
+			}
+
+		case event, ok := <-listener:
+			if !ok {
+				return
+			}
+			hub.broadcast(gin.H{"type": "event", "event": event})
+
+			// Also send updated active calls
+			switch event.EventType {
+			case "call_started", "call_ended", "call_transferred":
+				hub.broadcast(gin.H{"type": "update", "calls": events.Manager.GetActiveCalls()})
+			}
+		}
+	}
+}
+
+// broadcast queues a message for every dashboard, dropping any that have fallen behind
+func (hub *dashboardHub) broadcast(message gin.H) {
+	for client := range hub.clients {
+		select {
+		case client.send <- message:
+		default:
+			log.Printf("WebSocket client too slow, disconnecting")
+			hub.remove(client)
+		}
+	}
+}
+
+// remove forgets a client and closes its queue, which ends its writePump
+func (hub *dashboardHub) remove(client *dashboardClient) {
+	delete(hub.clients, client)
+	close(client.send)
+}
+
+// readPump watches for pongs and disconnects; dashboards don't send anything else
+func (client *dashboardClient) readPump() {
+	defer func() {
+		client.hub.unregister <- client
+		client.conn.Close()
+	}()
+
+	client.conn.SetReadLimit(wsMaxMessageSize)
+	client.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	client.conn.SetPongHandler(func(string) error {
+		return client.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		if _, _, err := client.conn.ReadMessage(); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("WebSocket read error: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// writePump sends queued messages and keepalive pings until the queue is closed or a write fails
+func (client *dashboardClient) writePump() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		client.conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-client.send:
+			client.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				// The hub dropped this client
+				client.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := client.conn.WriteJSON(message); err != nil {
+				log.Printf("WebSocket write error: %v", err)
+				return
+			}
+
+		case <-ticker.C:
+			client.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := client.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"o-dan-go/events"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func TestDashboardHubBroadcastsToEveryClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := &WRDashboardHandler{hub: newDashboardHub()}
+	listener := make(chan events.CallEvent)
+	go handler.hub.run(listener)
+
+	router := gin.New()
+	router.GET("/ws", handler.HandleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+	var conns []*websocket.Conn
+	for i := 0; i < 3; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+
+		var initial map[string]interface{}
+		if err := conn.ReadJSON(&initial); err != nil || initial["type"] != "initial" {
+			t.Fatalf("expected initial state, got %v (%v)", initial, err)
+		}
+		conns = append(conns, conn)
+	}
+
+	// Each client is registered before its initial state is written, so all three are known now
+	listener <- events.CallEvent{CallID: "call_1", EventType: "dtmf_received", Details: "Pressed 1"}
+
+	for i, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var message struct {
+			Type  string           `json:"type"`
+			Event events.CallEvent `json:"event"`
+		}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("client %d: %v", i, err)
+		}
+		if message.Type != "event" || message.Event.CallID != "call_1" {
+			t.Errorf("client %d: unexpected message %+v", i, message)
+		}
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -19,6 +24,19 @@ func main() {
 	// Load configuration first
 	cfg := config.LoadConfig()
 
+	// load-test runs simulated calls against the IVR apps in this process instead of serving,
+	// so it leaves out the background work that talks to other instances and the PBX
+	loadTestMode := len(os.Args) > 1 && os.Args[1] == "load-test"
+
+	// Share dashboard events with other instances when a Redis backplane is configured
+	if cfg.EventsRedisURL != "" && !loadTestMode {
+		backplane, err := events.NewRedisBackplane(cfg.EventsRedisURL, cfg.EventsRedisChannel)
+		if err != nil {
+			log.Fatalf("Failed to configure event backplane: %v", err)
+		}
+		events.Manager.SetBackplane(backplane)
+	}
+
 	// Start the event manager for dashboard
 	events.Manager.Start()
 
@@ -28,27 +46,444 @@ func main() {
 		return
 	}
 
+	// mock-netsapiens serves fake CDR endpoints instead of the app
+	if len(os.Args) > 1 && os.Args[1] == "mock-netsapiens" {
+		runMockNetSapiens(os.Args[2:])
+		return
+	}
+
 	// Set Gin mode based on environment
 	if cfg.IsProduction() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	if loadTestMode {
+		gin.SetMode(gin.ReleaseMode)
+		gin.DefaultWriter = io.Discard
+	}
+
+	// Apply reloadable settings now and again on SIGHUP or /api/v1/admin/reload
+	reloader := config.NewReloader(cfg)
+	reloader.OnReload(func(c *config.Config) {
+		services.GlobalResultsStore.UpdateTTL(c.ResultsTTL)
+		services.SetDebugLogging(c.DebugLogging)
+		services.SetCallClassification(services.CallClassificationSettings{
+			MinAnsweredSeconds: c.CallMinAnsweredSeconds,
+			AbandonedReasons:   c.CallAbandonedReasons,
+			MissedReasons:      c.CallMissedReasons,
+		})
+		if err := services.GlobalAreaCodes.Load(c.AreaCodesFile); err != nil {
+			log.Printf("[WR] Failed to load area codes, keeping current area codes: %v", err)
+		}
+		if err := services.GlobalZIPCodes.Load(c.ZIPCodesFile); err != nil {
+			log.Printf("[WR] Failed to load ZIP codes, keeping current ZIP codes: %v", err)
+		}
+	})
+	reloader.WatchSignals()
+
+	// Initialize Database Service
+	if err := os.MkdirAll(filepath.Dir(cfg.DatabasePath), 0755); err != nil {
+		log.Fatalf("Failed to create database directory: %v", err)
+	}
+	db, err := services.NewDatabaseServiceWithSettings(cfg.DatabasePath, services.DatabaseSettings{
+		JournalMode:     cfg.DatabaseJournalMode,
+		BusyTimeout:     cfg.DatabaseBusyTimeout,
+		Synchronous:     cfg.DatabaseSynchronous,
+		MaxOpenConns:    cfg.DatabaseMaxOpenConns,
+		MaxIdleConns:    cfg.DatabaseMaxIdleConns,
+		ConnMaxLifetime: cfg.DatabaseConnMaxLifetime,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	// Initialize Admin Handler
+	adminHandler := handlers.NewAdminHandler(reloader)
+
+	// Initialize Preferences Handler
+	prefsHandler := handlers.NewPreferencesHandler(db)
+
+	// Initialize PII masking policy
+	maskingPolicy, err := services.NewMaskingPolicy(cfg.PIIMaskingMode, cfg.PIIMaskingRoles, cfg.PIIHashSalt)
+	if err != nil {
+		log.Fatalf("Invalid PII masking configuration: %v", err)
+	}
+	if err := maskingPolicy.BindRoleTokens(cfg.PIIMaskingRoleTokens); err != nil {
+		log.Fatalf("Invalid PII_MASKING_ROLE_TOKENS: %v", err)
+	}
+
 	// Initialize CDR Discovery Service
 	cdrService := services.NewCDRDiscoveryService(
 		cfg.NetsapiensBaseURL,
 		cfg.NetsapiensToken,
 	)
 
-	// Initialize Dashboard Handler
-	wrDashboard := handlers.NewWRDashboardHandler()
+	// Record how every endpoint answers each search's parameters
+	services.GlobalDiscoveryAnalytics = services.NewDiscoveryAnalytics(db)
+	discoveryAnalyticsHandler := handlers.NewDiscoveryAnalyticsHandler(services.GlobalDiscoveryAnalytics)
+
+	// Record every IVR call for analytics and the dashboard's event history
+	wrAnalytics := services.NewWRAnalyticsService(db)
+	wrAnalytics.Start(events.Manager)
+
+	// Show real PBX calls on the dashboard alongside Web Responder calls
+	pbxEvents := services.NewPBXEventService(services.PBXEventSettings{
+		BaseURL:     cfg.NetsapiensBaseURL,
+		AccessToken: cfg.NetsapiensToken,
+		Domain:      cfg.PBXEventsDomain,
+		CallbackURL: cfg.PBXEventsCallbackURL,
+		Secret:      cfg.PBXEventsSecret,
+	})
+	if !loadTestMode {
+		pbxEvents.Start()
+	}
+	pbxEventsHandler := handlers.NewPBXEventsHandler(pbxEvents)
+	presenceHandler := handlers.NewPresenceHandler(services.NewPresenceService(cfg.NetsapiensBaseURL, cfg.NetsapiensToken))
+	deviceHandler := handlers.NewDeviceHandler(services.NewDeviceService(cfg.NetsapiensBaseURL, cfg.NetsapiensToken, cdrService))
+	voicemailReportService := services.NewVoicemailReportService(cfg.NetsapiensBaseURL, cfg.NetsapiensToken, cdrService)
+	voicemailReportHandler := handlers.NewVoicemailReportHandler(voicemailReportService)
+	directoryHandler := handlers.NewDirectoryHandler(services.NewDirectoryService(cfg.NetsapiensBaseURL, cfg.NetsapiensToken))
+	callControl := services.NewCallControlService(cfg.NetsapiensBaseURL, cfg.NetsapiensToken)
+	callControl.AddLocator(pbxEvents.LocateCall)
+	callsHandler := handlers.NewCallsHandler(callControl)
+	wrAnalyticsHandler := handlers.NewWRAnalyticsHandler(wrAnalytics)
 
 	// Initialize Web Responder Service
-	wrService := services.NewWebResponderService(cfg.SessionSecret)
+	ivrSessions, err := services.NewIVRSessionStore(cfg.IVRSessionStore, cfg.SessionSecret, db, cfg.IVRSessionTTL)
+	if err != nil {
+		log.Fatalf("Invalid IVR session configuration: %v", err)
+	}
+	wrService := services.NewWebResponderService(ivrSessions)
 	wrHandler := handlers.NewWebResponderHandler(wrService)
+	recordingsHandler := handlers.NewRecordingsHandler(db)
+	domainOverviewHandler := handlers.NewDomainOverviewHandler(db)
+
+	// Archive call recordings from search sessions to local disk or S3
+	retentionRules, err := services.ParseRetentionRules(cfg.ArchiveRetentionRules)
+	if err != nil {
+		log.Fatalf("Invalid ARCHIVE_RETENTION_RULES: %v", err)
+	}
+	archiveStorage := services.NewArchiveStorage(services.ArchiveSettings{
+		Storage:        cfg.ArchiveStorage,
+		Dir:            cfg.ArchiveDir,
+		S3Bucket:       cfg.ArchiveS3Bucket,
+		S3Prefix:       cfg.ArchiveS3Prefix,
+		S3Region:       cfg.ArchiveS3Region,
+		S3Endpoint:     cfg.ArchiveS3Endpoint,
+		S3AccessKey:    cfg.AWSAccessKeyID,
+		S3SecretKey:    cfg.AWSSecretAccessKey,
+		S3SessionToken: cfg.AWSSessionToken,
+	})
+	archiver := services.NewRecordingArchiver(db, archiveStorage, cfg.NetsapiensBaseURL, cfg.NetsapiensToken, cfg.ArchiveRetention, retentionRules)
+	if !loadTestMode {
+		archiver.Start()
+	}
+	archiveHandler := handlers.NewArchiveHandler(archiver)
+	storedSessionsHandler := handlers.NewStoredSessionsHandler(db, archiveStorage)
+
+	// Index search sessions' CDRs into Elasticsearch or OpenSearch
+	if err := services.ValidateIndexTemplate(cfg.ElasticsearchIndex); err != nil {
+		log.Fatalf("Invalid ELASTICSEARCH_INDEX: %v", err)
+	}
+	elasticsearchView, err := services.ParseCDRView(cfg.ElasticsearchView)
+	if err != nil {
+		log.Fatalf("Invalid ELASTICSEARCH_VIEW: %v", err)
+	}
+	elasticsearchMasking, err := services.ParseMaskingMode(cfg.ElasticsearchMasking)
+	if err != nil {
+		log.Fatalf("Invalid ELASTICSEARCH_MASKING: %v", err)
+	}
+	elasticsearchSink := services.NewElasticsearchSink(services.ElasticsearchSettings{
+		URL:           cfg.ElasticsearchURL,
+		IndexTemplate: cfg.ElasticsearchIndex,
+		Username:      cfg.ElasticsearchUsername,
+		Password:      cfg.ElasticsearchPassword,
+		APIKey:        cfg.ElasticsearchAPIKey,
+		View:          elasticsearchView,
+		Masking:       elasticsearchMasking,
+		BatchSize:     cfg.ElasticsearchBatchSize,
+		IndexSearches: cfg.ElasticsearchIndexSearches,
+	}, maskingPolicy)
+	searchIndexHandler := handlers.NewSearchIndexHandler(elasticsearchSink)
+
+	// Keep searched and imported CDRs in ClickHouse for analytics
+	clickHouse, err := services.NewClickHouseWriter(services.ClickHouseSettings{
+		URL:           cfg.ClickHouseURL,
+		Database:      cfg.ClickHouseDatabase,
+		Table:         cfg.ClickHouseTable,
+		Username:      cfg.ClickHouseUsername,
+		Password:      cfg.ClickHousePassword,
+		BatchSize:     cfg.ClickHouseBatchSize,
+		RetentionDays: cfg.ClickHouseRetentionDays,
+		WriteSearches: cfg.ClickHouseWriteSearches,
+	})
+	if err != nil {
+		log.Fatalf("Invalid ClickHouse configuration: %v", err)
+	}
+
+	// Transcribe archived recordings, index the transcripts for search and score their sentiment
+	transcriptionWorker := services.NewTranscriptionWorker(db, archiveStorage, services.NewTranscriber(services.TranscriptionSettings{
+		Backend:  cfg.TranscriptionBackend,
+		URL:      cfg.TranscriptionURL,
+		APIKey:   cfg.TranscriptionAPIKey,
+		Model:    cfg.TranscriptionModel,
+		Language: cfg.TranscriptionLanguage,
+	}), cfg.TranscriptionInterval)
+	transcriptionWorker.AnalyzeSentiment(services.NewSentimentAnalyzer(services.SentimentSettings{
+		Analyzer: cfg.SentimentAnalyzer,
+		URL:      cfg.SentimentURL,
+		APIKey:   cfg.SentimentAPIKey,
+	}))
+	alertNotifiers := services.NewAlertNotifiers(services.AlertSettings{
+		WebhookURL:      cfg.AlertWebhookURL,
+		SlackWebhookURL: cfg.AlertSlackWebhookURL,
+		SlackBotToken:   cfg.AlertSlackBotToken,
+		SlackChannel:    cfg.AlertSlackChannel,
+		TeamsWebhookURL: cfg.AlertTeamsWebhookURL,
+		EmailTo:         cfg.AlertEmailTo,
+		EmailFrom:       cfg.AlertEmailFrom,
+		SMTPHost:        cfg.SMTPHost,
+		SMTPPort:        cfg.SMTPPort,
+		SMTPUsername:    cfg.SMTPUsername,
+		SMTPPassword:    cfg.SMTPPassword,
+	})
+	transcriptionWorker.SpotKeywords(services.NewKeywordSpotter(db, alertNotifiers, cfg.AlertLinkBaseURL))
+	if !loadTestMode {
+		transcriptionWorker.Start()
+	}
+	transcriptsHandler := handlers.NewTranscriptsHandler(db)
+
+	// Carrier, LRN and caller name data for the numbers in search results and reports
+	enrichmentSettings := services.EnrichmentSettings{
+		Provider:         cfg.EnrichmentProvider,
+		TelnyxAPIKey:     cfg.TelnyxAPIKey,
+		TwilioAccountSID: cfg.TwilioAccountSID,
+		TwilioAuthToken:  cfg.TwilioAuthToken,
+	}
+	enricher := services.NewNumberEnricher(db, services.NewEnrichmentProvider(enrichmentSettings), cfg.EnrichmentCacheTTL, cfg.EnrichmentMaxLookups)
+	enrichmentSettings.Provider = cfg.CNAMProvider
+	enricher.LookupCallerNames(services.NewCallerNameProvider(enrichmentSettings))
+	voicemailReportService.EnrichNumbers(enricher)
+	keywordAlertsHandler := handlers.NewKeywordAlertsHandler(db)
+	scheduleService := services.NewScheduleService(db)
+
+	// Toll-fraud detection over search results, off-hours per the default business hours
+	fraudDetector := services.NewFraudDetector(db, scheduleService, alertNotifiers, services.FraudSettings{
+		ScanSearches:    cfg.FraudScanSearches,
+		BurstCalls:      cfg.FraudBurstCalls,
+		BurstWindow:     cfg.FraudBurstWindow,
+		SequentialCalls: cfg.FraudSequentialCalls,
+		MinutesFactor:   cfg.FraudMinutesFactor,
+		MinMinutes:      cfg.FraudMinMinutes,
+	}, cfg.AlertLinkBaseURL)
+	fraudHandler := handlers.NewFraudHandler(db, fraudDetector)
+
+	// Robocall/spam scores for the inbound calls in search results
+	spamScorer := services.NewSpamScorer(db, services.SpamSettings{
+		ScoreSearches: cfg.SpamScoreSearches,
+		RepeatCalls:   cfg.SpamRepeatCalls,
+		Threshold:     cfg.SpamThreshold,
+	})
+	spamHandler := handlers.NewSpamHandler(db, spamScorer)
+
+	// ASR, ACD and NER per domain, trunk and day over the calls in search results
+	kpiService := services.NewKPIService(db, services.KPISettings{
+		RecordSearches: cfg.KPIRecordSearches,
+		TrunkFields:    cfg.KPITrunkFields,
+	})
+	kpiHandler := handlers.NewKPIHandler(kpiService)
+	searchStorage := services.NewSearchStorage(db, cfg.StoreSearches)
+
+	// import-cdrs loads exported CDRs into the database instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "import-cdrs" {
+		runImportCDRs(db, kpiService, clickHouse, os.Args[2:])
+		return
+	}
+
+	maintainer := services.NewDatabaseMaintainer(db, cfg.DatabaseMaintenanceInterval, cfg.DatabaseVacuum, cfg.WREventRetention)
+	if !loadTestMode {
+		maintainer.Start()
+	}
+	databaseHandler := handlers.NewDatabaseHandler(maintainer)
+
+	// Alert rules on completed searches and, every interval, on call analytics
+	alertRules := services.NewAlertRuleEngine(db, alertNotifiers, cfg.AlertLinkBaseURL, cfg.AlertRulesInterval)
+	if cfg.AlertPagerDutyRoutingKey != "" {
+		alertRules.PageWith(services.NewPagerDutyNotifier(cfg.AlertPagerDutyRoutingKey))
+	}
+	if !loadTestMode {
+		alertRules.Start()
+	}
+	alertRulesHandler := handlers.NewAlertRulesHandler(db, alertRules)
+
+	// Every completed search (form, re-run, import or scheduled) goes through the same hooks
+	searchHooks := &handlers.SearchHooks{
+		Enricher:      enricher,
+		FraudDetector: fraudDetector,
+		SpamScorer:    spamScorer,
+		KPIs:          kpiService,
+		Storage:       searchStorage,
+		Rules:         alertRules,
+		Sink:          elasticsearchSink,
+		ClickHouse:    clickHouse,
+	}
+
+	// Saved searches run on an interval; each run is diffed against the one before
+	searchScheduler := services.NewSearchScheduler(db, cdrService, alertNotifiers, cfg.AlertLinkBaseURL, cfg.SearchSchedulerInterval)
+	searchScheduler.OnResult(handlers.SearchCompleted(searchHooks))
+	summaryChannels := []string{}
+	if cfg.AlertSlackSearchSummaries {
+		summaryChannels = append(summaryChannels, "slack")
+	}
+	if cfg.AlertTeamsSearchSummaries {
+		summaryChannels = append(summaryChannels, "teams")
+	}
+	searchScheduler.SendRunSummaries(services.NotifiersNamed(alertNotifiers, summaryChannels...))
+
+	// Scheduled searches can load each run's CDRs into BigQuery
+	bigQuery, err := services.NewBigQueryLoader(services.BigQuerySettings{
+		Credentials:   cfg.BigQueryCredentials,
+		Project:       cfg.BigQueryProject,
+		Dataset:       cfg.BigQueryDataset,
+		TableTemplate: cfg.BigQueryTable,
+		Location:      cfg.BigQueryLocation,
+	})
+	if err != nil {
+		log.Fatalf("Invalid BigQuery configuration: %v", err)
+	}
+
+	// and write them as partitioned Parquet files
+	parquetExporter := services.NewParquetExporter(services.NewArchiveStorage(services.ArchiveSettings{
+		Storage:        cfg.ParquetStorage,
+		Dir:            cfg.ParquetDir,
+		S3Bucket:       cfg.ParquetS3Bucket,
+		S3Prefix:       cfg.ParquetS3Prefix,
+		S3Region:       cfg.ParquetS3Region,
+		S3Endpoint:     cfg.ParquetS3Endpoint,
+		S3AccessKey:    cfg.AWSAccessKeyID,
+		S3SecretKey:    cfg.AWSSecretAccessKey,
+		S3SessionToken: cfg.AWSSessionToken,
+	}), db)
+	searchScheduler.ExportTo(bigQuery, parquetExporter)
+	parquetHandler := handlers.NewParquetExportHandler(parquetExporter)
+	starSchemaHandler := handlers.NewStarSchemaHandler(db)
+	if !loadTestMode {
+		searchScheduler.Start()
+	}
+	scheduledSearchesHandler := handlers.NewScheduledSearchesHandler(db, searchScheduler)
+
+	// Daily digest of the previous day's calls per domain
+	digester := services.NewDigester(db, services.NewAlertNotifiers(services.AlertSettings{
+		EmailTo:      cfg.DigestEmailTo,
+		EmailFrom:    cfg.AlertEmailFrom,
+		SMTPHost:     cfg.SMTPHost,
+		SMTPPort:     cfg.SMTPPort,
+		SMTPUsername: cfg.SMTPUsername,
+		SMTPPassword: cfg.SMTPPassword,
+	}), cfg.DigestHour, cfg.AlertLinkBaseURL)
+	if !loadTestMode {
+		digester.Start()
+	}
+	digestHandler := handlers.NewDigestHandler(digester)
+
+	// Carrier invoices, reconciled against the CDRs of a search
+	billingHandler := handlers.NewBillingHandler(db, services.NewBillingReconciler(db, services.BillingSettings{
+		TimeTolerance:     cfg.BillingTimeTolerance,
+		DurationTolerance: cfg.BillingDurationTolerance,
+		IncludeInbound:    cfg.BillingIncludeInbound,
+	}))
+	scheduleHandler := handlers.NewScheduleHandler(db, scheduleService)
+	importHandler := handlers.NewImportHandler(searchHooks)
+
+	// Voice settings and prompt catalog shared by every IVR app (reloadable)
+	prompts := services.NewPrompts()
+	reloader.OnReload(func(c *config.Config) {
+		voice := services.VoiceSettings{Voice: c.IVRVoice, Language: c.IVRLanguage, Rate: c.IVRSpeakingRate}
+		if err := prompts.Load(voice, c.IVRPromptsFile); err != nil {
+			log.Printf("[WR] Failed to load IVR prompts, keeping current prompts: %v", err)
+		}
+		if err := prompts.LoadAudio(services.AudioDir, c.IVRAudioBaseURL); err != nil {
+			log.Printf("[WR] Failed to load recorded prompts: %v", err)
+		}
+		wrService.SetSpeechSettings(services.SpeechSettings{
+			Enabled:             c.IVRSpeechEnabled,
+			ConfidenceThreshold: c.IVRSpeechConfidence,
+		})
+	})
+
+	// "Press 9 to receive this by text" follow-ups
+	smsFollowUp := services.NewSMSFollowUp(services.NewSMSProvider(services.SMSSettings{
+		Provider:          cfg.SMSProvider,
+		FromNumber:        cfg.SMSFromNumber,
+		NetsapiensBaseURL: cfg.NetsapiensBaseURL,
+		NetsapiensToken:   cfg.NetsapiensToken,
+		NetsapiensDomain:  cfg.SMSNetsapiensDomain,
+		NetsapiensUser:    cfg.SMSNetsapiensUser,
+		TwilioAccountSID:  cfg.TwilioAccountSID,
+		TwilioAuthToken:   cfg.TwilioAuthToken,
+	}), db, prompts)
+
+	// Register IVR apps (each is served at /wr/<name>)
+	weatherProvider := services.NewWeatherProvider(cfg.WeatherCacheTTL, cfg.GeoCachePrecision)
+	aqiProvider := services.NewAQIProvider(cfg.AQIProvider, cfg.AQIAPIKey, cfg.AQICacheTTL, cfg.GeoCachePrecision)
+	weatherApp := services.NewWeatherApp(weatherProvider, aqiProvider, smsFollowUp, prompts)
+	for _, app := range []services.IVRApp{
+		weatherApp,
+		services.NewCDRLookupApp(cdrService, smsFollowUp, prompts),
+		services.NewVoicemailApp(db, prompts),
+		services.NewQueueStatusApp(services.NewCallQueueService(cfg.NetsapiensBaseURL, cfg.NetsapiensToken, cfg.IVRQueueHandleTime), cfg.IVRQueueDomain, cfg.IVRQueue, prompts),
+	} {
+		if err := wrService.RegisterApp(app); err != nil {
+			log.Fatalf("Failed to register IVR app: %v", err)
+		}
+	}
+
+	// The main menu routes to the apps above and any flows mounted below
+	menuOptions, err := services.ParseMenuOptions(cfg.IVRMenu)
+	if err != nil {
+		log.Fatalf("Invalid IVR menu configuration: %v", err)
+	}
+	var operator *services.HandoffTarget
+	if cfg.IVROperator != "" {
+		target, err := services.ParseHandoffTarget(cfg.IVROperator)
+		if err != nil {
+			log.Fatalf("Invalid IVR operator configuration: %v", err)
+		}
+		operator = &target
+	}
+	if err := wrService.RegisterApp(services.NewMenuApp(wrService, menuOptions, operator, prompts)); err != nil {
+		log.Fatalf("Failed to register IVR app: %v", err)
+	}
+
+	// Mount declarative IVR flows, reloading them with the rest of the configuration
+	flowActions := weatherApp.FlowActions()
+	for name, action := range smsFollowUp.FlowActions() {
+		flowActions[name] = action
+	}
+	for name, action := range scheduleService.FlowActions() {
+		flowActions[name] = action
+	}
+	reloader.OnReload(func(c *config.Config) {
+		flows, err := services.LoadFlowApps(c.IVRFlowsDir, flowActions, prompts)
+		if err != nil {
+			log.Printf("[WR] Failed to load IVR flows, keeping current apps: %v", err)
+			return
+		}
+		for _, flow := range flows {
+			wrService.MountApp(flow)
+		}
+	})
 
 	// Create a Gin router with default middleware
 	r := gin.Default()
 
+	// Initialize Dashboard Handler, signed in with DASHBOARD_TOKEN. Its simulator plays
+	// scripted calls through this router, like NetSapiens would.
+	dashboardAuth := handlers.NewDashboardAuth(cfg.DashboardToken, cfg.SessionSecret, cfg.IsDevelopment(), cfg.DashboardAllowedOrigins)
+	simulator := services.NewCallSimulator(wrService, r)
+	wrDashboard := handlers.NewWRDashboardHandler(wrAnalytics, simulator, dashboardAuth.CheckOrigin)
+
 	// Load HTML templates for web interface
 	r.LoadHTMLGlob("templates/*")
 
@@ -82,39 +517,229 @@ func main() {
 	})
 
 	// Web Interface Routes (existing CDR functionality)
-	r.GET("/web", handlers.ShowWelcomePage)
-	r.GET("/web/search", handlers.ShowSearchForm)
-	r.POST("/web/search", handlers.ProcessSearchForm(cdrService))
-	r.GET("/web/results/:session_id", handlers.ShowResults)
-	r.GET("/spa", handlers.ShowSPA)
+	identify := handlers.Identify(cfg.SessionSecret)
+	web := r.Group("/web", identify, prefsHandler.LoadPreferences(), handlers.ResolveMasking(maskingPolicy), storedSessionsHandler.LoadFlags())
+	{
+		web.GET("", handlers.ShowWelcomePage)
+		web.GET("/search", handlers.ShowSearchForm)
+		web.POST("/search", handlers.ProcessSearchForm(cdrService, searchHooks))
+		web.GET("/results/:session_id", handlers.ShowResults)
+		web.POST("/rerun/:session_id", handlers.RerunSearch(searchHooks))
+		web.GET("/export/:session_id", handlers.ExportCDRs)
+		web.GET("/api/cdrs/:session_id", handlers.GetCDRsAPI)
+		web.GET("/api/compare/:session_id", handlers.CompareRerun)
+		web.GET("/api/timeseries/:session_id", handlers.GetTimeSeries)
+		web.GET("/api/geo/:session_id", handlers.GetGeo)
+		web.GET("/api/notes/:session_id", dashboardAuth.Middleware(), storedSessionsHandler.ListNotes)
+		web.POST("/api/notes/:session_id", dashboardAuth.Middleware(), storedSessionsHandler.AddNote)
+		web.GET("/api/cdrs/:session_id/:cdr_id", dashboardAuth.Middleware(), storedSessionsHandler.GetCDRDetail)
+		web.GET("/api/flags/:session_id", dashboardAuth.Middleware(), storedSessionsHandler.ListFlags)
+		web.PUT("/api/flags/:session_id/:cdr_id", dashboardAuth.Middleware(), storedSessionsHandler.FlagCDR)
+		web.DELETE("/api/flags/:session_id/:cdr_id", dashboardAuth.Middleware(), storedSessionsHandler.UnflagCDR)
+		web.GET("/domains/:domain", dashboardAuth.Middleware(), domainOverviewHandler.ShowDomainOverview)
+	}
+	r.GET("/spa", identify, prefsHandler.LoadPreferences(), handlers.ShowSPA)
 
 	// Web Responder Routes (NEW)
 	wr := r.Group("/wr")
 	{
-		// Weather IVR endpoint
-		wr.GET("/weather", wrHandler.HandleWeatherIVR)
-		wr.POST("/weather", wrHandler.HandleWeatherIVR)
+		// Dashboard sign-in
+		wr.GET("/login", dashboardAuth.ShowLogin)
+		wr.POST("/login", dashboardAuth.Login)
+		wr.POST("/logout", dashboardAuth.Logout)
 
 		// Dashboard routes
-		wr.GET("/dashboard", wrDashboard.ShowDashboard)
-		wr.GET("/active-calls", wrDashboard.GetActiveCalls)
-		wr.GET("/events", wrDashboard.GetRecentEvents)
-		wr.GET("/ws", wrDashboard.HandleWebSocket)
-		wr.POST("/test", wrDashboard.TestCall)
-		wr.POST("/simulate", wrDashboard.SimulateCall) // testing/simulation
+		dashboard := wr.Group("", dashboardAuth.Middleware())
+		{
+			dashboard.GET("/dashboard", wrDashboard.ShowDashboard)
+			dashboard.GET("/active-calls", wrDashboard.GetActiveCalls)
+			dashboard.GET("/events", wrDashboard.GetRecentEvents)
+			dashboard.GET("/ws", wrDashboard.HandleWebSocket)
+			dashboard.POST("/test", wrDashboard.SimulateCall)
+			dashboard.POST("/simulate", wrDashboard.SimulateCall) // scripted calls for demos and tests
+			dashboard.GET("/apps", wrHandler.ListIVRApps)
+			dashboard.GET("/recordings", recordingsHandler.GetRecordings)
+			dashboard.GET("/kpis", kpiHandler.ShowKPIReport)
+		}
 
-		// Future endpoints
+		// NetSapiens callbacks
+		wr.POST("/recordings/callback", recordingsHandler.RecordingCallback)
+
+		// IVR app endpoints (e.g. /wr/weather); static routes above take precedence
+		wr.GET("/:app", wrHandler.HandleIVRApp)
+		wr.POST("/:app", wrHandler.HandleIVRApp)
 	}
 
+	// NetSapiens call event notifications
+	r.POST(services.PBXEventsCallbackPath, pbxEventsHandler.Callback)
+
 	// API routes group
-	api := r.Group("/api/v1")
+	api := r.Group("/api/v1", identify)
 	{
 		api.GET("/health", handlers.HealthCheck)
+
+		// Per-user preferences
+		api.GET("/preferences", prefsHandler.GetPreferences)
+		api.PUT("/preferences", prefsHandler.UpdatePreferences)
+
+		// Area code lookups
+		areaCodes := api.Group("/areacodes")
+		{
+			areaCodes.GET("/nearest", handlers.GetNearestAreaCodes)
+			areaCodes.GET("/:code", handlers.GetAreaCode)
+			areaCodes.GET("/:code/nearby", handlers.GetNearbyAreaCodes)
+		}
+
+		// Web Responder analytics
+		wrAPI := api.Group("/wr", dashboardAuth.Middleware())
+		{
+			wrAPI.GET("/calls", wrAnalyticsHandler.GetCalls)
+			wrAPI.GET("/stats", wrAnalyticsHandler.GetStats)
+			wrAPI.GET("/analytics/volume", wrAnalyticsHandler.GetCallVolume)
+			wrAPI.GET("/analytics/options", wrAnalyticsHandler.GetMenuPopularity)
+		}
+
+		// Per-domain users, presence, devices, reports and search-form lists
+		domains := api.Group("/domains", dashboardAuth.Middleware())
+		{
+			domains.GET("", directoryHandler.GetDomains)
+			domains.GET("/:domain/users", directoryHandler.GetUsers)
+			domains.GET("/:domain/sites", directoryHandler.GetSites)
+			domains.GET("/:domain/presence", presenceHandler.GetDomainPresence)
+			domains.GET("/:domain/devices", deviceHandler.GetDevices)
+			domains.GET("/:domain/voicemail", voicemailReportHandler.GetVoicemailReport)
+			domains.GET("/:domain/spam", spamHandler.GetSpamSummary)
+			domains.GET("/:domain/spam/:number", spamHandler.GetSpamNumber)
+			domains.GET("/:domain/traffic", kpiHandler.GetTrafficReport)
+			domains.GET("/:domain/heatmap", kpiHandler.GetDomainHeatmap)
+			domains.GET("/:domain/overview", domainOverviewHandler.GetDomainOverview)
+		}
+		api.GET("/devices/endpoints", dashboardAuth.Middleware(), deviceHandler.GetEndpoints)
+
+		// Click-to-call and call control through the PBX
+		calls := api.Group("/calls", dashboardAuth.Middleware())
+		{
+			calls.POST("", callsHandler.PlaceCall)
+			calls.POST("/:call_id/hangup", callsHandler.HangUp)
+			calls.POST("/:call_id/hold", callsHandler.Hold)
+			calls.POST("/:call_id/resume", callsHandler.Resume)
+			calls.POST("/:call_id/transfer", callsHandler.Transfer)
+		}
+
+		// Searches kept in the database, for a sessions management screen; deleting one
+		// erases everything kept from it
+		api.GET("/sessions", dashboardAuth.Middleware(), storedSessionsHandler.ListSessions)
+		api.DELETE("/sessions/:id", handlers.AdminAuth(cfg.AdminToken, cfg.IsDevelopment()), storedSessionsHandler.DeleteSession)
+
+		// Analysts' notes on a session or one of its CDRs
+		api.GET("/sessions/:id/notes", dashboardAuth.Middleware(), storedSessionsHandler.ListNotes)
+		api.POST("/sessions/:id/notes", dashboardAuth.Middleware(), storedSessionsHandler.AddNote)
+		api.DELETE("/sessions/:id/notes/:note_id", dashboardAuth.Middleware(), storedSessionsHandler.DeleteNote)
+
+		// CDRs flagged for triage; ?flagged=true on previews and exports keeps only these
+		api.GET("/sessions/:id/flags", dashboardAuth.Middleware(), handlers.ResolveMasking(maskingPolicy), storedSessionsHandler.ListFlags)
+		api.PUT("/sessions/:id/flags/:cdr_id", dashboardAuth.Middleware(), storedSessionsHandler.FlagCDR)
+		api.DELETE("/sessions/:id/flags/:cdr_id", dashboardAuth.Middleware(), storedSessionsHandler.UnflagCDR)
+
+		// Statistics, pivots, time series, heatmaps, maps, reports and single CDRs of a search
+		// session, for exploring what a deployment returns and for external dashboards
+		api.GET("/sessions/:id/fields/:field/stats", dashboardAuth.Middleware(), handlers.ResolveMasking(maskingPolicy), handlers.GetFieldStats)
+		api.GET("/sessions/:id/pivot", dashboardAuth.Middleware(), handlers.ResolveMasking(maskingPolicy), handlers.GetPivot)
+		api.GET("/sessions/:id/timeseries", dashboardAuth.Middleware(), handlers.ResolveMasking(maskingPolicy), handlers.GetTimeSeries)
+		api.GET("/sessions/:id/heatmap", dashboardAuth.Middleware(), handlers.ResolveMasking(maskingPolicy), handlers.GetHeatmap)
+		api.GET("/sessions/:id/geo", dashboardAuth.Middleware(), handlers.GetGeo)
+		api.GET("/sessions/:id/reports/:type", dashboardAuth.Middleware(), handlers.ResolveMasking(maskingPolicy), handlers.GetSessionReport)
+		api.GET("/sessions/:id/cdrs/:cdr_id", dashboardAuth.Middleware(), handlers.ResolveMasking(maskingPolicy), storedSessionsHandler.GetCDRDetail)
+
+		// Telephony KPIs
+		api.GET("/kpis", dashboardAuth.Middleware(), kpiHandler.GetKPIs)
+
+		// Which endpoints and search parameters return data, and how reliably
+		api.GET("/analytics/discovery", dashboardAuth.Middleware(), discoveryAnalyticsHandler.GetTopCombinations)
+		api.GET("/analytics/discovery/endpoints", dashboardAuth.Middleware(), discoveryAnalyticsHandler.GetEndpointTrends)
+		api.GET("/analytics/discovery/suggestions", dashboardAuth.Middleware(), discoveryAnalyticsHandler.GetSuggestions)
+		api.DELETE("/analytics/discovery", handlers.AdminAuth(cfg.AdminToken, cfg.IsDevelopment()), discoveryAnalyticsHandler.ResetAnalytics)
+
+		// Search call recording transcripts
+		transcripts := api.Group("/transcripts", dashboardAuth.Middleware())
+		{
+			transcripts.GET("", transcriptsHandler.SearchTranscripts)
+			transcripts.GET("/:cdr_id", transcriptsHandler.GetTranscripts)
+		}
+
+		// Admin routes
+		admin := api.Group("/admin", handlers.AdminAuth(cfg.AdminToken, cfg.IsDevelopment()))
+		{
+			admin.GET("/config", adminHandler.GetConfig)
+			admin.POST("/reload", adminHandler.ReloadConfig)
+			admin.GET("/runtime", adminHandler.GetRuntime)
+			admin.GET("/results", adminHandler.GetResults)
+			admin.DELETE("/results", adminHandler.ClearResults)
+			admin.DELETE("/results/:session_id", adminHandler.DeleteResult)
+			admin.POST("/archive/:session_id", archiveHandler.ArchiveSession)
+			admin.GET("/archive/:session_id", archiveHandler.GetArchive)
+			admin.POST("/index/:session_id", searchIndexHandler.IndexSession)
+			admin.GET("/index/:session_id", searchIndexHandler.GetIndexRun)
+			admin.POST("/parquet", parquetHandler.ExportRange)
+			admin.POST("/parquet/:session_id", parquetHandler.ExportSession)
+			admin.GET("/star-schema", starSchemaHandler.ExportStarSchema)
+			admin.GET("/keyword-lists", keywordAlertsHandler.GetKeywordLists)
+			admin.POST("/keyword-lists", keywordAlertsHandler.CreateKeywordList)
+			admin.PUT("/keyword-lists/:id", keywordAlertsHandler.UpdateKeywordList)
+			admin.DELETE("/keyword-lists/:id", keywordAlertsHandler.DeleteKeywordList)
+			admin.GET("/keyword-alerts", keywordAlertsHandler.GetKeywordAlerts)
+			admin.POST("/fraud/scan/:session_id", fraudHandler.ScanSession)
+			admin.GET("/fraud-alerts", fraudHandler.GetFraudAlerts)
+			admin.GET("/fraud-alerts/:id", fraudHandler.GetFraudAlert)
+			admin.POST("/spam/score/:session_id", spamHandler.ScoreSession)
+			admin.POST("/kpis/record/:session_id", kpiHandler.RecordSession)
+			admin.GET("/billing/invoices", billingHandler.GetInvoices)
+			admin.POST("/billing/invoices", billingHandler.ImportInvoice)
+			admin.DELETE("/billing/invoices/:id", billingHandler.DeleteInvoice)
+			admin.POST("/billing/invoices/:id/reconcile/:session_id", billingHandler.Reconcile)
+			admin.GET("/discoveries", adminHandler.GetDiscoveries)
+			admin.GET("/events", adminHandler.GetEventStats)
+			admin.GET("/pbx-events", pbxEventsHandler.GetStats)
+			admin.GET("/caches", adminHandler.GetCacheStats)
+			admin.POST("/import", importHandler.ImportCDRs)
+			admin.GET("/database", databaseHandler.GetDatabaseStats)
+			admin.POST("/database/maintenance", databaseHandler.RunMaintenance)
+			admin.GET("/scheduled-searches", scheduledSearchesHandler.GetScheduledSearches)
+			admin.POST("/scheduled-searches", scheduledSearchesHandler.CreateScheduledSearch)
+			admin.PUT("/scheduled-searches/:id", scheduledSearchesHandler.UpdateScheduledSearch)
+			admin.DELETE("/scheduled-searches/:id", scheduledSearchesHandler.DeleteScheduledSearch)
+			admin.POST("/scheduled-searches/:id/run", scheduledSearchesHandler.RunScheduledSearch)
+			admin.GET("/scheduled-searches/:id/drift", scheduledSearchesHandler.GetSearchDrift)
+			admin.GET("/digest", digestHandler.GetDigest)
+			admin.POST("/digest/send", digestHandler.SendDigest)
+			admin.GET("/alert-rules", alertRulesHandler.GetAlertRules)
+			admin.POST("/alert-rules", alertRulesHandler.CreateAlertRule)
+			admin.PUT("/alert-rules/:id", alertRulesHandler.UpdateAlertRule)
+			admin.DELETE("/alert-rules/:id", alertRulesHandler.DeleteAlertRule)
+			admin.POST("/alert-rules/evaluate", alertRulesHandler.EvaluateAnalytics)
+			admin.GET("/alert-rule-events", alertRulesHandler.GetAlertRuleEvents)
+			admin.GET("/area-codes", adminHandler.GetAreaCodes)
+			admin.POST("/area-codes/reload", adminHandler.ReloadAreaCodes)
+			admin.GET("/schedules", scheduleHandler.GetSchedules)
+			admin.GET("/schedules/:did", scheduleHandler.GetSchedule)
+			admin.PUT("/schedules/:did", scheduleHandler.SaveSchedule)
+			admin.DELETE("/schedules/:did", scheduleHandler.DeleteSchedule)
+			admin.GET("/schedules/:did/status", scheduleHandler.GetScheduleStatus)
+			admin.GET("/holidays", scheduleHandler.GetHolidays)
+			admin.POST("/holidays", scheduleHandler.AddHoliday)
+			admin.DELETE("/holidays/:id", scheduleHandler.DeleteHoliday)
+			admin.POST("/load-test", wrDashboard.RunLoadTest)
+		}
 		// Future API endpoints
 		// api.GET("/cdrs", ...)
 		// api.GET("/wr/status", ...)
 	}
 
+	if loadTestMode {
+		runLoadTest(simulator, os.Args[2:])
+		return
+	}
+
 	// Start server
 	fmt.Printf("\n📡 Starting O Dan Go server on port %s\n", cfg.AppPort)
 	fmt.Printf("🌐 Web Interface: http://localhost:%s/web\n", cfg.AppPort)
@@ -156,7 +781,8 @@ func testCDREndpoints(cfg *config.Config) {
 
 	// Test with minimal criteria (discovers all endpoints)
 	criteria := services.CDRSearchCriteria{
-		Limit: 5, // Small limit for testing
+		Limit:             5,    // Small limit for testing
+		QueryAllEndpoints: true, // test every endpoint, whatever earlier searches found
 	}
 
 	startTime := time.Now()
@@ -234,6 +860,153 @@ func testCDREndpoints(cfg *config.Config) {
 	fmt.Println("\n🎉 CDR Discovery Service test completed!")
 }
 
+// runLoadTest runs the load-test command:
+//
+//	o-dan-go load-test -calls 500 -concurrency 50 -ramp 30s -app menu -digits 1,2
+func runLoadTest(simulator *services.CallSimulator, args []string) {
+	flags := flag.NewFlagSet("load-test", flag.ExitOnError)
+	calls := flags.Int("calls", 100, "number of calls to simulate")
+	concurrency := flags.Int("concurrency", 20, "maximum calls in progress at once")
+	ramp := flags.Duration("ramp", 10*time.Second, "time over which call starts are spread")
+	app := flags.String("app", "menu", "IVR app each call dials")
+	digits := flags.String("digits", "1,2", "comma-separated digits the caller presses, one per prompt")
+	delay := flags.Duration("delay", 0, "caller think time before each input")
+	caller := flags.String("caller", "", "caller number (default: rotate demo callers)")
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	flags.Parse(args)
+
+	config := services.LoadTestConfig{
+		Calls:       *calls,
+		Concurrency: *concurrency,
+		Ramp:        ramp.String(),
+		Scenario: services.SimulationScenario{
+			Name:         "load-test",
+			App:          *app,
+			CallerNumber: *caller,
+		},
+	}
+	for _, input := range strings.Split(*digits, ",") {
+		if input = strings.TrimSpace(input); input != "" {
+			config.Scenario.Steps = append(config.Scenario.Steps, services.SimulationStep{Digits: input, Delay: delay.String()})
+		}
+	}
+
+	if err := simulator.ValidateLoadTest(&config); err != nil {
+		log.Fatalf("Invalid load test: %v", err)
+	}
+
+	fmt.Printf("🚦 Simulating %d %s calls (%d concurrent, ramp %s)...\n", config.Calls, config.Scenario.App, config.Concurrency, *ramp)
+
+	// Per-call logging would drown out the report
+	log.SetOutput(io.Discard)
+	report := simulator.RunLoadTest(config)
+	log.SetOutput(os.Stderr)
+
+	if *asJSON {
+		output, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(output))
+		return
+	}
+
+	fmt.Printf("\n📊 %d calls in %s (%.1f calls/s): %d passed, %d failed\n",
+		report.Calls, report.Duration, report.CallsPerSecond, report.Passed, report.Failed)
+	fmt.Printf("   Requests: %d (%d errors)\n", report.Requests, report.RequestErrors)
+	fmt.Printf("   Request latency: p50 %.1fms, p90 %.1fms, p99 %.1fms, max %.1fms\n",
+		report.RequestLatency.P50, report.RequestLatency.P90, report.RequestLatency.P99, report.RequestLatency.Max)
+	fmt.Printf("   Event queue: peak %d of %d, %d events dropped, %d listener drops\n",
+		report.MaxQueuedEvents, report.EventQueueLength, report.EventsDropped, report.ListenerDrops)
+	for failure, count := range report.Failures {
+		fmt.Printf("   ❌ %s: %d\n", failure, count)
+	}
+}
+
+// runMockNetSapiens runs the mock-netsapiens command, serving the NetSapiens CDR endpoints
+// from generated or exported CDRs. Point a search's API URL (or NETSAPIENS_BASE_URL) at it:
+//
+//	o-dan-go mock-netsapiens -port 9090 -cdrs 2000 -latency 200ms -failure-rate 0.05
+func runMockNetSapiens(args []string) {
+	flags := flag.NewFlagSet("mock-netsapiens", flag.ExitOnError)
+	port := flags.String("port", "9090", "port to listen on")
+	dataset := flags.String("dataset", "", "CSV or JSON CDR export to serve (default: generated CDRs)")
+	cdrs := flags.Int("cdrs", 500, "number of CDRs to generate")
+	seed := flags.Int64("seed", 1, "seed for generated CDRs")
+	latency := flags.Duration("latency", 0, "delay added to every response")
+	jitter := flags.Duration("jitter", 0, "up to this much extra delay, at random")
+	failureRate := flags.Float64("failure-rate", 0, "share of requests answered with HTTP 503")
+	token := flags.String("token", "", "bearer token to require (default: accept any)")
+	flags.Parse(args)
+
+	mock, err := services.NewMockNetSapiens(services.MockNetSapiensSettings{
+		Dataset:     *dataset,
+		CDRs:        *cdrs,
+		Seed:        *seed,
+		Latency:     *latency,
+		Jitter:      *jitter,
+		FailureRate: *failureRate,
+		Token:       *token,
+	})
+	if err != nil {
+		log.Fatalf("Failed to start mock NetSapiens API: %v", err)
+	}
+
+	fmt.Printf("🧪 Mock NetSapiens API serving %d CDRs on http://localhost:%s/ns-api/v2\n", mock.CDRCount(), *port)
+	log.Fatal(http.ListenAndServe(":"+*port, mock))
+}
+
+// runImportCDRs runs the import-cdrs command, storing the session and its call outcomes
+// in the database and optionally writing it to a standalone SQLite file and ClickHouse:
+//
+//	o-dan-go import-cdrs -sqlite session.sqlite -clickhouse export1.csv export2.json
+func runImportCDRs(db *services.DatabaseService, kpis *services.KPIService, clickHouse *services.ClickHouseWriter, args []string) {
+	flags := flag.NewFlagSet("import-cdrs", flag.ExitOnError)
+	sqlitePath := flags.String("sqlite", "", "also write the session to this SQLite file")
+	toClickHouse := flags.Bool("clickhouse", false, "also write the CDRs to ClickHouse (CLICKHOUSE_URL)")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		log.Fatalf("Usage: o-dan-go import-cdrs [-sqlite file] [-clickhouse] export.csv|export.json ...")
+	}
+	if *toClickHouse && !clickHouse.Enabled() {
+		log.Fatalf("ClickHouse is not configured (CLICKHOUSE_URL)")
+	}
+
+	result, err := services.ImportCDRFiles(flags.Args())
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+	for _, message := range result.Errors {
+		fmt.Printf("⚠️  %s\n", message)
+	}
+
+	stats, err := services.NewSearchStorage(db, true).Store(result.SessionID, result.SearchCriteria, result.AllCDRs)
+	if err != nil {
+		log.Fatalf("Failed to store %s: %v", result.SessionID, err)
+	}
+	outcomes, err := kpis.RecordCDRs(result.AllCDRs)
+	if err != nil {
+		log.Fatalf("Failed to record call outcomes: %v", err)
+	}
+	if *sqlitePath != "" {
+		if err := services.WriteSessionDatabase(result, "", *sqlitePath); err != nil {
+			log.Fatalf("Failed to write %s: %v", *sqlitePath, err)
+		}
+	}
+	written := 0
+	if *toClickHouse {
+		if written, err = clickHouse.WriteCDRs(result.SessionID, result.AllCDRs, result.StartTime); err != nil {
+			log.Fatalf("Failed to write to ClickHouse: %v", err)
+		}
+	}
+
+	fmt.Printf("📥 Imported %d CDRs (%d unique) from %d files as %s\n", result.TotalCDRs, result.UniqueCDRs, flags.NArg(), result.SessionID)
+	fmt.Printf("   Stored %d summaries in %dms, recorded %d call outcomes\n", stats.Rows, stats.DurationMS, len(outcomes))
+	if *sqlitePath != "" {
+		fmt.Printf("   Wrote %s\n", *sqlitePath)
+	}
+	if *toClickHouse {
+		fmt.Printf("   Wrote %d CDRs to ClickHouse\n", written)
+	}
+}
+
 // Helper functions
 func min(a, b int) int {
 	if a < b {
@@ -36,8 +36,9 @@ func (f *FlexibleCDR) UnmarshalJSON(data []byte) error {
 }
 
 // MarshalJSON implements the json.Marshaler interface
-// This is called when converting FROM our struct to JSON
-func (f *FlexibleCDR) MarshalJSON() ([]byte, error) {
+// This is called when converting FROM our struct to JSON.
+// Value receiver so copies (e.g. masked CDRs) marshal the same as pointers.
+func (f FlexibleCDR) MarshalJSON() ([]byte, error) {
 	// When someone asks for JSON, give them the actual CDR data
 	// not the struct fields
 	return json.Marshal(f.RawData)
@@ -0,0 +1,155 @@
+// services/agent_performance.go
+// Agent performance: each user's calls from call-orig-user and call-term-user (calls
+// handled, talk time, average duration, inbound against outbound and missed calls), for
+// contact center supervisors
+
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"o-dan-go/models"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// agentReportDetailLimit caps how many agents an alert lists
+const agentReportDetailLimit = 10
+
+// AgentStats is one user's calls. Calls the user placed are outbound and calls they
+// received inbound, whichever way the call crossed the PBX.
+type AgentStats struct {
+	User               string  `json:"user"`
+	Calls              int     `json:"calls"`
+	Inbound            int     `json:"inbound"`
+	Outbound           int     `json:"outbound"`
+	Handled            int     `json:"handled"` // answered calls
+	Missed             int     `json:"missed"`  // inbound calls not answered
+	TalkSeconds        int     `json:"talk_seconds"`
+	AvgDurationSeconds float64 `json:"avg_duration_seconds"` // of handled calls
+	MissedPercent      float64 `json:"missed_percent"`       // of inbound calls
+}
+
+// AgentReport is every user's calls in a session, most calls handled first
+type AgentReport struct {
+	sessionReportBase
+	Agents      []AgentStats `json:"agents"`
+	Totals      AgentStats   `json:"totals"`     // sums the agents, so a call between two users counts for both
+	Unassigned  int          `json:"unassigned"` // CDRs with neither user
+	GeneratedAt time.Time    `json:"generated_at"`
+}
+
+// BuildAgentReport counts each user's calls among cdrs
+func BuildAgentReport(cdrs []models.FlexibleCDR) *AgentReport {
+	report := &AgentReport{Agents: []AgentStats{}, Totals: AgentStats{User: "total"}, GeneratedAt: time.Now().UTC()}
+	agents := make(map[string]*AgentStats)
+	agent := func(user string) *AgentStats {
+		if agents[user] == nil {
+			agents[user] = &AgentStats{User: user}
+		}
+		return agents[user]
+	}
+
+	for _, cdr := range cdrs {
+		orig, term := cdr.GetOrigUser(), cdr.GetTermUser()
+		if orig == "" && term == "" {
+			report.Unassigned++
+			continue
+		}
+		answered, talkSeconds := callAnswered(cdr)
+		if orig != "" {
+			agent(orig).count(false, answered, talkSeconds)
+		}
+		if term != "" && term != orig {
+			agent(term).count(true, answered, talkSeconds)
+		}
+	}
+
+	for _, stats := range agents {
+		stats.finish()
+		report.Agents = append(report.Agents, *stats)
+		report.Totals.Calls += stats.Calls
+		report.Totals.Inbound += stats.Inbound
+		report.Totals.Outbound += stats.Outbound
+		report.Totals.Handled += stats.Handled
+		report.Totals.Missed += stats.Missed
+		report.Totals.TalkSeconds += stats.TalkSeconds
+	}
+	report.Totals.finish()
+	sort.Slice(report.Agents, func(i, j int) bool {
+		if report.Agents[i].Handled != report.Agents[j].Handled {
+			return report.Agents[i].Handled > report.Agents[j].Handled
+		}
+		return report.Agents[i].User < report.Agents[j].User
+	})
+	return report
+}
+
+// count adds a call the user placed or received
+func (s *AgentStats) count(inbound, answered bool, talkSeconds int) {
+	s.Calls++
+	if inbound {
+		s.Inbound++
+	} else {
+		s.Outbound++
+	}
+	if answered {
+		s.Handled++
+		s.TalkSeconds += talkSeconds
+	} else if inbound {
+		s.Missed++
+	}
+}
+
+// finish computes the averages from the counts
+func (s *AgentStats) finish() {
+	if s.Handled > 0 {
+		s.AvgDurationSeconds = roundTo(float64(s.TalkSeconds)/float64(s.Handled), 1)
+	}
+	if s.Inbound > 0 {
+		s.MissedPercent = roundTo(100*float64(s.Missed)/float64(s.Inbound), 1)
+	}
+}
+
+// AlertSummary describes the report in one line
+func (r *AgentReport) AlertSummary() string {
+	return fmt.Sprintf("Agent performance: %d agents handled %d calls, %d missed",
+		len(r.Agents), r.Totals.Handled, r.Totals.Missed)
+}
+
+// AlertDetails lists the busiest agents
+func (r *AgentReport) AlertDetails() []string {
+	details := []string{"Session: " + r.SessionID}
+	for i, stats := range r.Agents {
+		if i == agentReportDetailLimit {
+			details = append(details, fmt.Sprintf("... and %d more", len(r.Agents)-i))
+			break
+		}
+		details = append(details, fmt.Sprintf("%s: %d handled, %d missed, %.0f min talk, %.0fs average",
+			stats.User, stats.Handled, stats.Missed, float64(stats.TalkSeconds)/60, stats.AvgDurationSeconds))
+	}
+	return details
+}
+
+// WriteCSV writes a row per agent, totals last
+func (r *AgentReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"user", "calls", "inbound", "outbound", "handled", "missed", "missed_percent", "talk_seconds", "avg_duration_seconds"})
+	for _, stats := range append(append([]AgentStats{}, r.Agents...), r.Totals) {
+		writer.Write([]string{
+			stats.User,
+			strconv.Itoa(stats.Calls),
+			strconv.Itoa(stats.Inbound),
+			strconv.Itoa(stats.Outbound),
+			strconv.Itoa(stats.Handled),
+			strconv.Itoa(stats.Missed),
+			strconv.FormatFloat(stats.MissedPercent, 'f', -1, 64),
+			strconv.Itoa(stats.TalkSeconds),
+			strconv.FormatFloat(stats.AvgDurationSeconds, 'f', -1, 64),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
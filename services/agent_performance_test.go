@@ -0,0 +1,61 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestBuildAgentReport(t *testing.T) {
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"call-orig-user": "101", "call-term-user": "102", "call-total-duration-seconds": float64(60)}},
+		{RawData: map[string]interface{}{"call-term-user": "102", "call-total-duration-seconds": float64(0)}},
+		{RawData: map[string]interface{}{"call-term-user": "102", "call-total-duration-seconds": float64(30)}},
+		{RawData: map[string]interface{}{"call-orig-user": "101", "call-total-duration-seconds": float64(0)}},
+		{RawData: map[string]interface{}{"call-total-duration-seconds": float64(10)}},
+	}
+
+	report := BuildAgentReport(cdrs)
+	if len(report.Agents) != 2 || report.Unassigned != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	agent := report.Agents[0]
+	if agent.User != "102" || agent.Calls != 3 || agent.Inbound != 3 || agent.Handled != 2 || agent.Missed != 1 ||
+		agent.TalkSeconds != 90 || agent.AvgDurationSeconds != 45 || agent.MissedPercent != 33.3 {
+		t.Errorf("agent 102 = %+v", agent)
+	}
+	agent = report.Agents[1]
+	if agent.User != "101" || agent.Outbound != 2 || agent.Handled != 1 || agent.Missed != 0 {
+		t.Errorf("unanswered outbound calls should not count as missed: %+v", agent)
+	}
+	if report.Totals.Calls != 5 || report.Totals.Handled != 3 {
+		t.Errorf("totals = %+v", report.Totals)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[1], "102,3,3,0,2,1,33.3,90,45") || !strings.HasPrefix(lines[3], "total,") {
+		t.Errorf("CSV = %q", buf.String())
+	}
+}
+
+func TestBuildSessionReport(t *testing.T) {
+	report, err := BuildSessionReport(ReportAgents, "abc", nil, "https://odango.example.com/")
+	if err != nil {
+		t.Fatalf("BuildSessionReport: %v", err)
+	}
+	if links := report.AlertLinks(); len(links) != 1 || links[0].URL != "https://odango.example.com/api/v1/sessions/abc/reports/agents?format=csv" {
+		t.Errorf("links = %+v", links)
+	}
+	if _, err := BuildSessionReport("nope", "abc", nil, ""); err == nil {
+		t.Error("an unknown report type should fail")
+	}
+	if err := ValidateSessionReports([]string{ReportAgents, "nope"}); err == nil {
+		t.Error("an unknown report type should not validate")
+	}
+}
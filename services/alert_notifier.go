@@ -0,0 +1,339 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// AlertNotifier delivers alerts to people
+type AlertNotifier interface {
+	Name() string
+	Notify(alert Alert) error
+}
+
+// Alert is anything sent through the notifiers. Webhooks receive the alert itself as
+// JSON; Slack and email are written from its summary, details and links.
+type Alert interface {
+	AlertSummary() string
+	AlertDetails() []string
+	AlertLinks() []AlertLink
+}
+
+// DedupedAlert is an alert that repeats under a stable key, so paging services can fold
+// repeats into one incident
+type DedupedAlert interface {
+	Alert
+	AlertDedupKey() string
+}
+
+// AlertLink is a labelled link included with an alert
+type AlertLink struct {
+	Label string
+	URL   string
+}
+
+// AlertSettings configures where alerts are sent; every configured channel is used
+type AlertSettings struct {
+	WebhookURL      string
+	SlackWebhookURL string // Slack incoming webhook
+	SlackBotToken   string // posts with chat.postMessage instead of a webhook
+	SlackChannel    string // channel for the bot token, or to override the webhook's
+	TeamsWebhookURL string // Microsoft Teams incoming webhook or workflow URL
+
+	EmailTo      string // comma-separated addresses
+	EmailFrom    string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// NewAlertNotifiers creates a notifier for each configured channel
+func NewAlertNotifiers(settings AlertSettings) []AlertNotifier {
+	client := &http.Client{Timeout: 10 * time.Second}
+	notifiers := []AlertNotifier{}
+
+	if settings.WebhookURL != "" {
+		notifiers = append(notifiers, &WebhookAlertNotifier{client: client, url: settings.WebhookURL})
+	}
+	if settings.SlackBotToken != "" {
+		if settings.SlackChannel == "" {
+			log.Printf("[Alerts] Slack bot token set without a channel, Slack disabled")
+		} else {
+			notifiers = append(notifiers, &SlackAlertNotifier{client: client, url: slackPostMessageURL, token: settings.SlackBotToken, channel: settings.SlackChannel})
+		}
+	} else if settings.SlackWebhookURL != "" {
+		notifiers = append(notifiers, &SlackAlertNotifier{client: client, url: settings.SlackWebhookURL, channel: settings.SlackChannel})
+	}
+	if settings.TeamsWebhookURL != "" {
+		notifiers = append(notifiers, &TeamsAlertNotifier{client: client, url: settings.TeamsWebhookURL})
+	}
+	to := []string{}
+	for _, address := range strings.Split(settings.EmailTo, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			to = append(to, address)
+		}
+	}
+	if len(to) > 0 {
+		if settings.SMTPHost == "" || settings.EmailFrom == "" {
+			log.Printf("[Alerts] Email alerts are missing an SMTP host or from address, email disabled")
+		} else {
+			notifiers = append(notifiers, &EmailAlertNotifier{
+				addr:     fmt.Sprintf("%s:%d", settings.SMTPHost, settings.SMTPPort),
+				host:     settings.SMTPHost,
+				username: settings.SMTPUsername,
+				password: settings.SMTPPassword,
+				from:     settings.EmailFrom,
+				to:       to,
+			})
+		}
+	}
+	return notifiers
+}
+
+// WebhookAlertNotifier posts each alert as JSON
+type WebhookAlertNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func (wn *WebhookAlertNotifier) Name() string { return "webhook" }
+
+// Notify posts the alert
+func (wn *WebhookAlertNotifier) Notify(alert Alert) error {
+	return postAlertJSON(wn.client, wn.url, alert)
+}
+
+// slackPostMessageURL is the Slack Web API method bot tokens post with
+var slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+// SlackAlertNotifier posts alerts to a Slack incoming webhook, or to a channel with a
+// bot token
+type SlackAlertNotifier struct {
+	client  *http.Client
+	url     string
+	token   string
+	channel string
+}
+
+func (sn *SlackAlertNotifier) Name() string { return "slack" }
+
+// Notify posts a message with the details quoted and the links below
+func (sn *SlackAlertNotifier) Notify(alert Alert) error {
+	lines := []string{"*" + alert.AlertSummary() + "*"}
+	for _, detail := range alert.AlertDetails() {
+		lines = append(lines, "> "+detail)
+	}
+	links := []string{}
+	for _, link := range alert.AlertLinks() {
+		links = append(links, fmt.Sprintf("<%s|%s>", link.URL, link.Label))
+	}
+	if len(links) > 0 {
+		lines = append(lines, strings.Join(links, " | "))
+	}
+
+	message := map[string]interface{}{"text": strings.Join(lines, "\n"), "unfurl_links": false}
+	if sn.channel != "" {
+		message["channel"] = sn.channel
+	}
+	if sn.token == "" {
+		return postAlertJSON(sn.client, sn.url, message)
+	}
+
+	// The Web API answers 200 with "ok": false when it rejects a message
+	body, _ := json.Marshal(message)
+	req, err := http.NewRequest(http.MethodPost, sn.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+sn.token)
+	resp, err := sn.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to Slack: %w", err)
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("Slack returned status %d", resp.StatusCode)
+	}
+	if !reply.OK {
+		return fmt.Errorf("Slack rejected the message: %s", reply.Error)
+	}
+	return nil
+}
+
+// NotifiersNamed picks the notifiers for the named channels
+func NotifiersNamed(notifiers []AlertNotifier, names ...string) []AlertNotifier {
+	picked := []AlertNotifier{}
+	for _, notifier := range notifiers {
+		for _, name := range names {
+			if notifier.Name() == name {
+				picked = append(picked, notifier)
+				break
+			}
+		}
+	}
+	return picked
+}
+
+// TeamsAlertNotifier posts alerts to a Microsoft Teams incoming webhook as adaptive cards
+type TeamsAlertNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func (tn *TeamsAlertNotifier) Name() string { return "teams" }
+
+// Notify posts a card with the summary as its title, the details below it and a button
+// for each link. Teams only opens absolute URLs, so relative links are listed as text.
+func (tn *TeamsAlertNotifier) Notify(alert Alert) error {
+	body := []map[string]interface{}{{
+		"type":   "TextBlock",
+		"text":   alert.AlertSummary(),
+		"weight": "Bolder",
+		"size":   "Medium",
+		"wrap":   true,
+	}}
+	for _, detail := range alert.AlertDetails() {
+		body = append(body, map[string]interface{}{"type": "TextBlock", "text": detail, "wrap": true, "spacing": "None"})
+	}
+	actions := []map[string]interface{}{}
+	for _, link := range alert.AlertLinks() {
+		if strings.HasPrefix(link.URL, "http://") || strings.HasPrefix(link.URL, "https://") {
+			actions = append(actions, map[string]interface{}{"type": "Action.OpenUrl", "title": link.Label, "url": link.URL})
+		} else {
+			body = append(body, map[string]interface{}{"type": "TextBlock", "text": link.Label + ": " + link.URL, "wrap": true, "isSubtle": true})
+		}
+	}
+
+	card := map[string]interface{}{
+		"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+		"type":    "AdaptiveCard",
+		"version": "1.4",
+		"body":    body,
+		"actions": actions,
+	}
+	return postAlertJSON(tn.client, tn.url, map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content":     card,
+		}},
+	})
+}
+
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint
+var pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDutyAlertNotifier triggers PagerDuty incidents through the Events API
+type PagerDutyAlertNotifier struct {
+	client     *http.Client
+	url        string
+	routingKey string
+}
+
+// NewPagerDutyNotifier creates a notifier for the service with the integration's routing key
+func NewPagerDutyNotifier(routingKey string) *PagerDutyAlertNotifier {
+	return &PagerDutyAlertNotifier{
+		client:     &http.Client{Timeout: 10 * time.Second},
+		url:        pagerDutyEventsURL,
+		routingKey: routingKey,
+	}
+}
+
+func (pn *PagerDutyAlertNotifier) Name() string { return "pagerduty" }
+
+// Notify triggers a critical event. Alerts with a dedup key update the open incident
+// for that key instead of opening another.
+func (pn *PagerDutyAlertNotifier) Notify(alert Alert) error {
+	event := map[string]interface{}{
+		"routing_key":  pn.routingKey,
+		"event_action": "trigger",
+		"payload": map[string]interface{}{
+			"summary":        alert.AlertSummary(),
+			"source":         "o-dan-go",
+			"severity":       "critical",
+			"custom_details": alert.AlertDetails(),
+		},
+	}
+	if deduped, ok := alert.(DedupedAlert); ok {
+		event["dedup_key"] = deduped.AlertDedupKey()
+	}
+	links := []map[string]string{}
+	for _, link := range alert.AlertLinks() {
+		if strings.HasPrefix(link.URL, "http://") || strings.HasPrefix(link.URL, "https://") {
+			links = append(links, map[string]string{"href": link.URL, "text": link.Label})
+		}
+	}
+	if len(links) > 0 {
+		event["links"] = links
+	}
+	return postAlertJSON(pn.client, pn.url, event)
+}
+
+// EmailAlertNotifier emails alerts through an SMTP server (STARTTLS when offered)
+type EmailAlertNotifier struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func (en *EmailAlertNotifier) Name() string { return "email" }
+
+// Notify sends a plain text email
+func (en *EmailAlertNotifier) Notify(alert Alert) error {
+	var body strings.Builder
+	fmt.Fprintf(&body, "From: %s\r\n", en.from)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(en.to, ", "))
+	fmt.Fprintf(&body, "Subject: %s\r\n", alert.AlertSummary())
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, detail := range alert.AlertDetails() {
+		fmt.Fprintf(&body, "%s\r\n", detail)
+	}
+	body.WriteString("\r\n")
+	for _, link := range alert.AlertLinks() {
+		fmt.Fprintf(&body, "%s: %s\r\n", link.Label, link.URL)
+	}
+
+	var auth smtp.Auth
+	if en.username != "" {
+		auth = smtp.PlainAuth("", en.username, en.password, en.host)
+	}
+	if err := smtp.SendMail(en.addr, auth, en.from, en.to, []byte(body.String())); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
+
+// postAlertJSON posts a JSON payload to a webhook
+func postAlertJSON(client *http.Client, url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post alert: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTeamsAlertNotifier(t *testing.T) {
+	var message struct {
+		Type        string `json:"type"`
+		Attachments []struct {
+			ContentType string `json:"contentType"`
+			Content     struct {
+				Type    string                   `json:"type"`
+				Body    []map[string]interface{} `json:"body"`
+				Actions []map[string]interface{} `json:"actions"`
+			} `json:"content"`
+		} `json:"attachments"`
+	}
+	teams := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+			t.Errorf("Teams message: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer teams.Close()
+
+	notifiers := NotifiersNamed(NewAlertNotifiers(AlertSettings{TeamsWebhookURL: teams.URL}), "teams")
+	if len(notifiers) != 1 {
+		t.Fatalf("NotifiersNamed picked %d notifiers, want Teams", len(notifiers))
+	}
+	summary := SearchRunSummary{SearchName: "acme", SessionID: "session-1", UniqueCDRs: 12, Endpoints: 3, FailedEndpoints: 1,
+		ResultsURL: "https://odango.example.com/web/results/session-1"}
+	if err := notifiers[0].Notify(summary); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if message.Type != "message" || len(message.Attachments) != 1 ||
+		message.Attachments[0].ContentType != "application/vnd.microsoft.card.adaptive" {
+		t.Fatalf("Teams received %+v", message)
+	}
+	card := message.Attachments[0].Content
+	if card.Type != "AdaptiveCard" || len(card.Body) != 3 || card.Body[0]["text"] != summary.AlertSummary() ||
+		card.Body[2]["text"] != "Endpoints: 3 queried, 1 failed" {
+		t.Errorf("card body = %+v", card.Body)
+	}
+	if len(card.Actions) != 1 || card.Actions[0]["type"] != "Action.OpenUrl" || card.Actions[0]["url"] != summary.ResultsURL {
+		t.Errorf("card actions = %+v", card.Actions)
+	}
+
+	// Relative links cannot be opened from Teams and are listed as text
+	summary.ResultsURL = "/web/results/session-1"
+	if err := notifiers[0].Notify(summary); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	card = message.Attachments[0].Content
+	if len(card.Actions) != 0 || card.Body[len(card.Body)-1]["text"] != "Search results: /web/results/session-1" {
+		t.Errorf("relative link card = %+v", card)
+	}
+
+	rule := AlertRule{Name: "teams only", Metric: RuleMetricFailedEndpoints, Operator: ">", Actions: []string{"Teams"}}
+	if err := rule.Validate(); err != nil || rule.Actions[0] != "teams" {
+		t.Errorf("Validate = %v, actions %v", err, rule.Actions)
+	}
+}
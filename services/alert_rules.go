@@ -0,0 +1,555 @@
+// services/alert_rules.go
+// Alert rules: admin-defined conditions on search sessions ("any endpoint failed", "no
+// CDRs for acme") and on call analytics ("ASR below 40% today"), each sent to chosen
+// alert channels. Session rules are checked as each search completes; analytics rules
+// are checked on an interval.
+
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session metrics, measured on each completed search
+const (
+	RuleMetricFailedEndpoints   = "failed_endpoints"    // endpoints that returned an error
+	RuleMetricEndpointErrorRate = "endpoint_error_rate" // percentage of endpoints that returned an error
+	RuleMetricUniqueCDRs        = "unique_cdrs"         // unique CDRs, of the rule's domain when set
+	RuleMetricUnansweredRate    = "unanswered_rate"     // percentage of those CDRs not answered
+)
+
+// Analytics metrics, measured on the recorded calls of the rule's window
+const (
+	RuleMetricCalls = "calls"
+	RuleMetricASR   = "asr"
+	RuleMetricNER   = "ner"
+)
+
+// defaultAlertRuleCooldown is how long an analytics rule stays quiet for a domain after alerting
+const defaultAlertRuleCooldown = 60
+
+// ErrAlertRuleNotFound is returned when updating a rule that doesn't exist
+var ErrAlertRuleNotFound = errors.New("alert rule not found")
+
+// alertRuleOperators compare a measured value with a rule's threshold
+var alertRuleOperators = map[string]func(value, threshold float64) bool{
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	"==": func(v, t float64) bool { return v == t },
+	"!=": func(v, t float64) bool { return v != t },
+}
+
+// AlertRule is a condition and where to send an alert when it holds
+type AlertRule struct {
+	ID              int64     `json:"id"`
+	Name            string    `json:"name"`
+	Metric          string    `json:"metric"`
+	Operator        string    `json:"operator"`
+	Threshold       float64   `json:"threshold"`
+	Domain          string    `json:"domain,omitempty"`           // "" for every domain
+	Filter          string    `json:"filter,omitempty"`           // CDR filter expression; unique_cdrs and unanswered_rate only count CDRs it matches
+	WindowDays      int       `json:"window_days,omitempty"`      // analytics rules: days up to today, default 1
+	CooldownMinutes int       `json:"cooldown_minutes,omitempty"` // analytics rules: quiet time per domain after alerting
+	Actions         []string  `json:"actions"`                    // webhook, slack, teams, email, pagerduty; empty for every channel but pagerduty
+	Disabled        bool      `json:"disabled"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// SessionRule reports whether the rule is checked on completed searches rather than
+// on a schedule
+func (r *AlertRule) SessionRule() bool {
+	switch r.Metric {
+	case RuleMetricFailedEndpoints, RuleMetricEndpointErrorRate, RuleMetricUniqueCDRs, RuleMetricUnansweredRate:
+		return true
+	}
+	return false
+}
+
+// Validate checks the rule and fills in defaults
+func (r *AlertRule) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Domain = strings.TrimSpace(r.Domain)
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch r.Metric {
+	case RuleMetricFailedEndpoints, RuleMetricEndpointErrorRate, RuleMetricUniqueCDRs, RuleMetricUnansweredRate,
+		RuleMetricCalls, RuleMetricASR, RuleMetricNER:
+	default:
+		return fmt.Errorf("unknown metric %q", r.Metric)
+	}
+	if _, ok := alertRuleOperators[r.Operator]; !ok {
+		return fmt.Errorf("unknown operator %q", r.Operator)
+	}
+	r.Filter = strings.TrimSpace(r.Filter)
+	if r.Filter != "" {
+		if r.Metric != RuleMetricUniqueCDRs && r.Metric != RuleMetricUnansweredRate {
+			return fmt.Errorf("filter only applies to the %s and %s metrics", RuleMetricUniqueCDRs, RuleMetricUnansweredRate)
+		}
+		if _, err := ParseCDRFilter(r.Filter); err != nil {
+			return err
+		}
+	}
+	if r.WindowDays < 0 || r.CooldownMinutes < 0 {
+		return fmt.Errorf("window_days and cooldown_minutes cannot be negative")
+	}
+	if r.WindowDays == 0 {
+		r.WindowDays = 1
+	}
+	if r.CooldownMinutes == 0 {
+		r.CooldownMinutes = defaultAlertRuleCooldown
+	}
+	actions := []string{}
+	for _, action := range r.Actions {
+		action = strings.ToLower(strings.TrimSpace(action))
+		if action != "webhook" && action != "slack" && action != "teams" && action != "email" && action != "pagerduty" {
+			return fmt.Errorf("unknown action %q", action)
+		}
+		actions = append(actions, action)
+	}
+	r.Actions = actions
+	return nil
+}
+
+// holds reports whether a measured value meets the rule's condition
+func (r *AlertRule) holds(value float64) bool {
+	return alertRuleOperators[r.Operator](value, r.Threshold)
+}
+
+// AlertRuleEvent is a rule that held, with the value that triggered it
+type AlertRuleEvent struct {
+	ID         int64     `json:"id"`
+	RuleID     int64     `json:"rule_id"`
+	RuleName   string    `json:"rule_name"`
+	Metric     string    `json:"metric"`
+	Operator   string    `json:"operator"`
+	Threshold  float64   `json:"threshold"`
+	Value      float64   `json:"value"`
+	Domain     string    `json:"domain,omitempty"`
+	SessionID  string    `json:"session_id,omitempty"`
+	ResultsURL string    `json:"results_url,omitempty"`
+	Notified   []string  `json:"notified"` // channels the alert was sent to
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AlertSummary describes the event in one line
+func (e AlertRuleEvent) AlertSummary() string {
+	subject := e.SessionID
+	if e.Domain != "" {
+		subject = e.Domain
+	}
+	return fmt.Sprintf("Alert rule %s: %s is %g (%s %g) for %s", e.RuleName, e.Metric, e.Value, e.Operator, e.Threshold, subject)
+}
+
+// AlertDetails lists the rule and what it was measured on
+func (e AlertRuleEvent) AlertDetails() []string {
+	details := []string{fmt.Sprintf("Condition: %s %s %g", e.Metric, e.Operator, e.Threshold), fmt.Sprintf("Value: %g", e.Value)}
+	if e.Domain != "" {
+		details = append(details, "Domain: "+e.Domain)
+	}
+	if e.SessionID != "" {
+		details = append(details, "Session: "+e.SessionID)
+	}
+	return details
+}
+
+// AlertLinks links the search results for session rules
+func (e AlertRuleEvent) AlertLinks() []AlertLink {
+	if e.ResultsURL == "" {
+		return nil
+	}
+	return []AlertLink{{Label: "Search results", URL: e.ResultsURL}}
+}
+
+// AlertDedupKey groups a rule's repeated alerts, per domain for analytics rules, so a
+// condition that keeps holding pages once
+func (e AlertRuleEvent) AlertDedupKey() string {
+	key := fmt.Sprintf("o-dan-go/alert-rule/%d", e.RuleID)
+	if e.Domain != "" {
+		key += "/" + strings.ToLower(e.Domain)
+	}
+	return key
+}
+
+// AlertRuleEngine checks the rules and sends their alerts
+type AlertRuleEngine struct {
+	db          *DatabaseService
+	notifiers   []AlertNotifier
+	pager       AlertNotifier // only used by rules that ask for it
+	linkBaseURL string
+	interval    time.Duration // how often analytics rules are checked
+
+	mu sync.Mutex // one analytics check at a time
+}
+
+// NewAlertRuleEngine creates an engine; links in alerts start with linkBaseURL
+func NewAlertRuleEngine(db *DatabaseService, notifiers []AlertNotifier, linkBaseURL string, interval time.Duration) *AlertRuleEngine {
+	return &AlertRuleEngine{
+		db:          db,
+		notifiers:   notifiers,
+		linkBaseURL: strings.TrimRight(linkBaseURL, "/"),
+		interval:    interval,
+	}
+}
+
+// PageWith has rules with the pagerduty action page through pager. Call it before Start.
+func (ae *AlertRuleEngine) PageWith(pager AlertNotifier) {
+	ae.pager = pager
+}
+
+// Start checks the analytics rules every interval in the background
+func (ae *AlertRuleEngine) Start() {
+	if ae.interval <= 0 {
+		return
+	}
+	log.Printf("[Alert Rules] Checking analytics rules every %s", ae.interval)
+
+	go func() {
+		for {
+			time.Sleep(ae.interval)
+			if _, err := ae.EvaluateAnalytics(time.Now()); err != nil {
+				log.Printf("[Alert Rules] %v", err)
+			}
+		}
+	}()
+}
+
+// EvaluateSession checks the session rules against a completed search
+func (ae *AlertRuleEngine) EvaluateSession(result *CDRDiscoveryResult) ([]AlertRuleEvent, error) {
+	if ae == nil {
+		return nil, nil
+	}
+	rules, err := ae.db.GetAlertRules()
+	if err != nil {
+		return nil, err
+	}
+
+	events := []AlertRuleEvent{}
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Disabled || !rule.SessionRule() {
+			continue
+		}
+		value, applies, err := sessionMetric(rule, result)
+		if err != nil {
+			log.Printf("[Alert Rules] Skipping %s: %v", rule.Name, err)
+			continue
+		}
+		if !applies || !rule.holds(value) {
+			continue
+		}
+		event := AlertRuleEvent{
+			RuleID: rule.ID, RuleName: rule.Name, Metric: rule.Metric, Operator: rule.Operator, Threshold: rule.Threshold,
+			Value: value, Domain: rule.Domain, SessionID: result.SessionID,
+			ResultsURL: ae.linkBaseURL + "/web/results/" + url.PathEscape(result.SessionID),
+		}
+		if err := ae.fire(rule, &event); err != nil {
+			return events, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// sessionMetric measures a session rule's metric on a search. Endpoint metrics only
+// apply to searches of the rule's domain, when it has one; CDR metrics only count the
+// CDRs of the rule's domain that pass its filter.
+func sessionMetric(rule *AlertRule, result *CDRDiscoveryResult) (float64, bool, error) {
+	switch rule.Metric {
+	case RuleMetricFailedEndpoints, RuleMetricEndpointErrorRate:
+		if rule.Domain != "" && !strings.EqualFold(rule.Domain, result.SearchCriteria.Domain) {
+			return 0, false, nil
+		}
+		failed := 0
+		for _, endpoint := range result.EndpointResults {
+			if !endpoint.Success {
+				failed++
+			}
+		}
+		if rule.Metric == RuleMetricFailedEndpoints {
+			return float64(failed), true, nil
+		}
+		if len(result.EndpointResults) == 0 {
+			return 0, true, nil
+		}
+		return roundTo(100*float64(failed)/float64(len(result.EndpointResults)), 1), true, nil
+	}
+
+	filter, err := ParseCDRFilter(rule.Filter)
+	if err != nil {
+		return 0, false, err
+	}
+	cdrs, unanswered := 0, 0
+	for i := range result.AllCDRs {
+		if rule.Domain != "" && !strings.EqualFold(rule.Domain, result.AllCDRs[i].GetDomain()) {
+			continue
+		}
+		if !filter.Match(&result.AllCDRs[i]) {
+			continue
+		}
+		cdrs++
+		if answered, _ := callAnswered(result.AllCDRs[i]); !answered {
+			unanswered++
+		}
+	}
+	if rule.Metric == RuleMetricUniqueCDRs {
+		return float64(cdrs), true, nil
+	}
+	if cdrs == 0 {
+		return 0, true, nil
+	}
+	return roundTo(100*float64(unanswered)/float64(cdrs), 1), true, nil
+}
+
+// EvaluateAnalytics checks the analytics rules against the recorded calls of each
+// rule's window, per domain, and returns the alerts sent
+func (ae *AlertRuleEngine) EvaluateAnalytics(now time.Time) ([]AlertRuleEvent, error) {
+	ae.mu.Lock()
+	defer ae.mu.Unlock()
+
+	rules, err := ae.db.GetAlertRules()
+	if err != nil {
+		return nil, err
+	}
+
+	events := []AlertRuleEvent{}
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Disabled || rule.SessionRule() {
+			continue
+		}
+		end := now.UTC().Truncate(24 * time.Hour)
+		rows, err := ae.db.GetTelephonyKPIs(KPIQuery{
+			Domain:  rule.Domain,
+			Start:   end.AddDate(0, 0, 1-rule.WindowDays),
+			End:     end,
+			GroupBy: []string{KPIGroupDomain},
+		})
+		if err != nil {
+			return events, err
+		}
+		// A domain with no recorded calls has no row; measure it as zero calls
+		if rule.Domain != "" && len(rows) == 0 {
+			rows = []TelephonyKPIs{{Domain: rule.Domain}}
+		}
+
+		for _, row := range rows {
+			value := float64(row.Seizures)
+			switch rule.Metric {
+			case RuleMetricASR:
+				value = row.ASR
+			case RuleMetricNER:
+				value = row.NER
+			}
+			if (rule.Metric != RuleMetricCalls && row.Seizures == 0) || !rule.holds(value) {
+				continue
+			}
+			last, err := ae.db.LastAlertRuleEvent(rule.ID, row.Domain)
+			if err != nil {
+				return events, err
+			}
+			if last != nil && now.Sub(*last) < time.Duration(rule.CooldownMinutes)*time.Minute {
+				continue
+			}
+			event := AlertRuleEvent{
+				RuleID: rule.ID, RuleName: rule.Name, Metric: rule.Metric, Operator: rule.Operator, Threshold: rule.Threshold,
+				Value: value, Domain: row.Domain, CreatedAt: now.UTC(),
+			}
+			if err := ae.fire(rule, &event); err != nil {
+				return events, err
+			}
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// fire sends an event to the rule's channels and stores it
+func (ae *AlertRuleEngine) fire(rule *AlertRule, event *AlertRuleEvent) error {
+	event.Notified = []string{}
+	notifiers := ae.notifiers
+	if ae.pager != nil {
+		notifiers = append(notifiers[:len(notifiers):len(notifiers)], ae.pager)
+	}
+	for _, notifier := range notifiers {
+		if !ruleSendsTo(rule, notifier.Name()) {
+			continue
+		}
+		if err := notifier.Notify(*event); err != nil {
+			log.Printf("[Alert Rules] Failed to send %s by %s: %v", rule.Name, notifier.Name(), err)
+			continue
+		}
+		event.Notified = append(event.Notified, notifier.Name())
+	}
+	return ae.db.SaveAlertRuleEvent(event)
+}
+
+// ruleSendsTo reports whether a rule's alerts go to a channel. Rules without actions
+// use every channel but PagerDuty, which has to be asked for.
+func ruleSendsTo(rule *AlertRule, channel string) bool {
+	if len(rule.Actions) == 0 {
+		return channel != "pagerduty"
+	}
+	for _, action := range rule.Actions {
+		if action == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// SaveAlertRule creates a rule (ID 0) or updates one
+func (ds *DatabaseService) SaveAlertRule(rule *AlertRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	actions, err := json.Marshal(rule.Actions)
+	if err != nil {
+		return fmt.Errorf("failed to encode actions: %w", err)
+	}
+	now := time.Now().UTC()
+
+	if rule.ID == 0 {
+		err = ds.db.QueryRow(`
+		INSERT INTO alert_rules (name, metric, operator, threshold, domain, filter, window_days, cooldown_minutes, actions, disabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id, created_at, updated_at`,
+			rule.Name, rule.Metric, rule.Operator, rule.Threshold, rule.Domain, rule.Filter, rule.WindowDays, rule.CooldownMinutes,
+			string(actions), rule.Disabled, now, now,
+		).Scan(&rule.ID, &rule.CreatedAt, &rule.UpdatedAt)
+	} else {
+		err = ds.db.QueryRow(`
+		UPDATE alert_rules SET name = ?, metric = ?, operator = ?, threshold = ?, domain = ?, filter = ?, window_days = ?,
+			cooldown_minutes = ?, actions = ?, disabled = ?, updated_at = ?
+		WHERE id = ?
+		RETURNING created_at, updated_at`,
+			rule.Name, rule.Metric, rule.Operator, rule.Threshold, rule.Domain, rule.Filter, rule.WindowDays, rule.CooldownMinutes,
+			string(actions), rule.Disabled, now, rule.ID,
+		).Scan(&rule.CreatedAt, &rule.UpdatedAt)
+		if err == sql.ErrNoRows {
+			return ErrAlertRuleNotFound
+		}
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return fmt.Errorf("an alert rule named %q already exists", rule.Name)
+		}
+		return fmt.Errorf("failed to save alert rule: %w", err)
+	}
+	return nil
+}
+
+// GetAlertRules returns every alert rule by name
+func (ds *DatabaseService) GetAlertRules() ([]AlertRule, error) {
+	rows, err := ds.db.Query(`
+	SELECT id, name, metric, operator, threshold, COALESCE(domain, ''), COALESCE(filter, ''), window_days, cooldown_minutes, actions,
+		disabled, created_at, updated_at
+	FROM alert_rules ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []AlertRule{}
+	for rows.Next() {
+		var rule AlertRule
+		var actions string
+		if err := rows.Scan(&rule.ID, &rule.Name, &rule.Metric, &rule.Operator, &rule.Threshold, &rule.Domain, &rule.Filter,
+			&rule.WindowDays, &rule.CooldownMinutes, &actions, &rule.Disabled, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(actions), &rule.Actions); err != nil {
+			return nil, fmt.Errorf("invalid actions in rule %s: %w", rule.Name, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// DeleteAlertRule removes a rule by ID; its events are kept
+func (ds *DatabaseService) DeleteAlertRule(id int64) (bool, error) {
+	result, err := ds.db.Exec(`DELETE FROM alert_rules WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// SaveAlertRuleEvent stores an event, as of now unless it has a time
+func (ds *DatabaseService) SaveAlertRuleEvent(event *AlertRuleEvent) error {
+	notified, _ := json.Marshal(event.Notified)
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+	result, err := ds.db.Exec(`
+	INSERT INTO alert_rule_events (rule_id, rule_name, metric, operator, threshold, value, domain, session_id, results_url, notified, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.RuleID, event.RuleName, event.Metric, event.Operator, event.Threshold, event.Value, event.Domain,
+		event.SessionID, event.ResultsURL, string(notified), event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save alert rule event: %w", err)
+	}
+	event.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// LastAlertRuleEvent returns when a rule last alerted for a domain, or nil
+func (ds *DatabaseService) LastAlertRuleEvent(ruleID int64, domain string) (*time.Time, error) {
+	var last time.Time
+	err := ds.db.QueryRow(`
+	SELECT created_at FROM alert_rule_events WHERE rule_id = ? AND domain = ? ORDER BY created_at DESC LIMIT 1`,
+		ruleID, domain).Scan(&last)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last alert rule event: %w", err)
+	}
+	return &last, nil
+}
+
+// GetAlertRuleEvents lists recent events, newest first, of one rule or (ruleID 0) all
+func (ds *DatabaseService) GetAlertRuleEvents(ruleID int64, limit int) ([]AlertRuleEvent, error) {
+	query := `
+	SELECT id, rule_id, rule_name, metric, operator, threshold, value, COALESCE(domain, ''), COALESCE(session_id, ''),
+		COALESCE(results_url, ''), notified, created_at
+	FROM alert_rule_events`
+	args := []interface{}{}
+	if ruleID != 0 {
+		query += " WHERE rule_id = ?"
+		args = append(args, ruleID)
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := ds.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get alert rule events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []AlertRuleEvent{}
+	for rows.Next() {
+		var event AlertRuleEvent
+		var notified string
+		if err := rows.Scan(&event.ID, &event.RuleID, &event.RuleName, &event.Metric, &event.Operator, &event.Threshold,
+			&event.Value, &event.Domain, &event.SessionID, &event.ResultsURL, &notified, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(notified), &event.Notified)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
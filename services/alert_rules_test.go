@@ -0,0 +1,132 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestAlertRules(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "rules.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	received := []AlertRuleEvent{}
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event AlertRuleEvent
+		json.NewDecoder(r.Body).Decode(&event)
+		received = append(received, event)
+	}))
+	defer webhook.Close()
+
+	for _, rule := range []AlertRule{
+		{Name: "endpoint failures", Metric: RuleMetricFailedEndpoints, Operator: ">", Threshold: 0},
+		{Name: "no globex calls", Metric: RuleMetricUniqueCDRs, Operator: "==", Threshold: 0, Domain: "globex"},
+		{Name: "slack only", Metric: RuleMetricUniqueCDRs, Operator: ">=", Threshold: 0, Actions: []string{"slack"}},
+		{Name: "low asr", Metric: RuleMetricASR, Operator: "<", Threshold: 50, Domain: "acme"},
+	} {
+		if err := db.SaveAlertRule(&rule); err != nil {
+			t.Fatalf("SaveAlertRule %s: %v", rule.Name, err)
+		}
+	}
+	if err := db.SaveAlertRule(&AlertRule{Name: "bad", Metric: "mos", Operator: ">"}); err == nil {
+		t.Error("saved a rule with an unknown metric")
+	}
+
+	engine := NewAlertRuleEngine(db, NewAlertNotifiers(AlertSettings{WebhookURL: webhook.URL}), "https://odango.example.com", 0)
+	result := &CDRDiscoveryResult{
+		SessionID:       "session-1",
+		EndpointResults: []EndpointResult{{EndpointName: "global_cdrs", Success: true}, {EndpointName: "domain_cdrs", Error: "HTTP 500"}},
+		AllCDRs:         []models.FlexibleCDR{{RawData: map[string]interface{}{"id": "cdr-1", "domain": "acme", "call-total-duration-seconds": 30}}},
+	}
+	events, err := engine.EvaluateSession(result)
+	if err != nil || len(events) != 3 {
+		t.Fatalf("EvaluateSession = %+v, %v; want failures, no globex calls and slack only", events, err)
+	}
+	if len(received) != 2 || received[0].RuleName != "endpoint failures" || received[0].Value != 1 ||
+		received[0].ResultsURL != "https://odango.example.com/web/results/session-1" || received[1].RuleName != "no globex calls" {
+		t.Fatalf("webhook received %+v", received)
+	}
+	if events[2].RuleName != "slack only" || len(events[2].Notified) != 0 {
+		t.Errorf("slack only rule notified %v", events[2].Notified)
+	}
+
+	// Analytics rules measure recorded calls and stay quiet during the cooldown
+	now := time.Now().UTC().Truncate(24 * time.Hour).Add(9 * time.Hour)
+	if err := db.SaveCallOutcomes([]CallOutcome{
+		{CDRID: "a", Domain: "acme", StartedAt: now, Answered: true, TalkSeconds: 60},
+		{CDRID: "b", Domain: "acme", StartedAt: now},
+		{CDRID: "c", Domain: "acme", StartedAt: now},
+	}); err != nil {
+		t.Fatalf("SaveCallOutcomes: %v", err)
+	}
+	events, err = engine.EvaluateAnalytics(now)
+	if err != nil || len(events) != 1 || events[0].Domain != "acme" || events[0].Value != 33.3 {
+		t.Fatalf("EvaluateAnalytics = %+v, %v; want acme at 33.3%% ASR", events, err)
+	}
+	if events, _ := engine.EvaluateAnalytics(now.Add(time.Minute)); len(events) != 0 {
+		t.Errorf("alerted again during the cooldown: %+v", events)
+	}
+	if events, _ := engine.EvaluateAnalytics(now.Add(2 * time.Hour)); len(events) != 1 {
+		t.Errorf("did not alert after the cooldown: %+v", events)
+	}
+
+	history, err := db.GetAlertRuleEvents(4, 10)
+	if err != nil || len(history) != 2 || history[0].CreatedAt.Before(history[1].CreatedAt) {
+		t.Fatalf("GetAlertRuleEvents = %+v, %v", history, err)
+	}
+}
+
+func TestAlertRulePaging(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "paging.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	pages := []map[string]interface{}{}
+	pagerDuty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&event)
+		pages = append(pages, event)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer pagerDuty.Close()
+	pager := NewPagerDutyNotifier("routing-key")
+	pager.url = pagerDuty.URL
+
+	paged := AlertRule{Name: "endpoint failures", Metric: RuleMetricFailedEndpoints, Operator: ">", Threshold: 0, Actions: []string{"pagerduty"}}
+	for _, rule := range []*AlertRule{&paged, {Name: "every channel", Metric: RuleMetricFailedEndpoints, Operator: ">", Threshold: 0}} {
+		if err := db.SaveAlertRule(rule); err != nil {
+			t.Fatalf("SaveAlertRule %s: %v", rule.Name, err)
+		}
+	}
+
+	engine := NewAlertRuleEngine(db, nil, "https://odango.example.com", 0)
+	engine.PageWith(pager)
+	for _, session := range []string{"session-1", "session-2"} {
+		result := &CDRDiscoveryResult{SessionID: session, EndpointResults: []EndpointResult{{EndpointName: "domain_cdrs", Error: "HTTP 500"}}}
+		if events, err := engine.EvaluateSession(result); err != nil || len(events) != 2 {
+			t.Fatalf("EvaluateSession %s = %+v, %v", session, events, err)
+		}
+	}
+
+	// Only the rule that asked to page did, and both failures share its dedup key
+	if len(pages) != 2 || pages[0]["dedup_key"] != pages[1]["dedup_key"] || pages[0]["dedup_key"] != "o-dan-go/alert-rule/1" {
+		t.Fatalf("PagerDuty received %+v", pages)
+	}
+	if pages[0]["routing_key"] != "routing-key" || pages[0]["event_action"] != "trigger" {
+		t.Errorf("page = %+v", pages[0])
+	}
+	history, err := db.GetAlertRuleEvents(paged.ID, 10)
+	if err != nil || len(history) != 2 || len(history[0].Notified) != 1 || history[0].Notified[0] != "pagerduty" {
+		t.Errorf("GetAlertRuleEvents = %+v, %v", history, err)
+	}
+}
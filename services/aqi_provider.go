@@ -0,0 +1,352 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AQICategory is the EPA AQI category (1 = Good ... 6 = Hazardous)
+type AQICategory int
+
+const (
+	AQICategoryUnknown AQICategory = iota
+	AQICategoryGood
+	AQICategoryModerate
+	AQICategoryUnhealthySensitive
+	AQICategoryUnhealthy
+	AQICategoryVeryUnhealthy
+	AQICategoryHazardous
+)
+
+// AQIReading is a normalized air quality observation from a provider
+type AQIReading struct {
+	AQI        int         `json:"aqi"`
+	Category   AQICategory `json:"category"`
+	Pollutant  string      `json:"pollutant"`
+	Source     string      `json:"source"`
+	ObservedAt time.Time   `json:"observed_at"`
+}
+
+// AQIProvider fetches current air quality for a coordinate
+type AQIProvider interface {
+	Name() string
+	GetAQI(lat, lon float64) (AQIReading, error)
+}
+
+// AQICategoryForValue maps an AQI value to its EPA category
+func AQICategoryForValue(aqi int) AQICategory {
+	switch {
+	case aqi < 0:
+		return AQICategoryUnknown
+	case aqi <= 50:
+		return AQICategoryGood
+	case aqi <= 100:
+		return AQICategoryModerate
+	case aqi <= 150:
+		return AQICategoryUnhealthySensitive
+	case aqi <= 200:
+		return AQICategoryUnhealthy
+	case aqi <= 300:
+		return AQICategoryVeryUnhealthy
+	default:
+		return AQICategoryHazardous
+	}
+}
+
+// ParseAQICategory maps a provider category name (e.g. AirNow's "Unhealthy for Sensitive Groups")
+// to a category, returning AQICategoryUnknown when it isn't recognized
+func ParseAQICategory(name string) AQICategory {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "good":
+		return AQICategoryGood
+	case "moderate":
+		return AQICategoryModerate
+	case "unhealthy for sensitive groups", "usg":
+		return AQICategoryUnhealthySensitive
+	case "unhealthy":
+		return AQICategoryUnhealthy
+	case "very unhealthy":
+		return AQICategoryVeryUnhealthy
+	case "hazardous":
+		return AQICategoryHazardous
+	default:
+		return AQICategoryUnknown
+	}
+}
+
+// NewAQIProvider builds the configured provider wrapped in a per-location cache.
+// Unknown names and providers without an API key fall back to simulated data.
+func NewAQIProvider(name, apiKey string, cacheTTL time.Duration, cachePrecision int) AQIProvider {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var provider AQIProvider
+	switch strings.ToLower(name) {
+	case "airnow":
+		provider = &AirNowProvider{client: client, apiKey: apiKey}
+	case "openaq":
+		provider = &OpenAQProvider{client: client, apiKey: apiKey}
+	case "", "simulated":
+		provider = &SimulatedAQIProvider{}
+	default:
+		log.Printf("[AQI] Unknown provider %q, using simulated data", name)
+		provider = &SimulatedAQIProvider{}
+	}
+
+	if apiKey == "" && provider.Name() != "simulated" {
+		log.Printf("[AQI] No API key for %s, using simulated data", provider.Name())
+		provider = &SimulatedAQIProvider{}
+	}
+
+	return NewCachedAQIProvider(provider, cacheTTL, cachePrecision)
+}
+
+// SimulatedAQIProvider returns random readings for development
+type SimulatedAQIProvider struct{}
+
+func (sp *SimulatedAQIProvider) Name() string { return "simulated" }
+
+// GetAQI returns a random AQI between 20 and 150
+func (sp *SimulatedAQIProvider) GetAQI(lat, lon float64) (AQIReading, error) {
+	aqi := rand.Intn(130) + 20
+	return AQIReading{
+		AQI:        aqi,
+		Category:   AQICategoryForValue(aqi),
+		Pollutant:  "PM2.5",
+		Source:     sp.Name(),
+		ObservedAt: time.Now(),
+	}, nil
+}
+
+// AirNowProvider reads current observations from the EPA AirNow API
+type AirNowProvider struct {
+	client *http.Client
+	apiKey string
+}
+
+func (ap *AirNowProvider) Name() string { return "airnow" }
+
+// GetAQI returns the worst pollutant reported by the nearest AirNow reporting area
+func (ap *AirNowProvider) GetAQI(lat, lon float64) (AQIReading, error) {
+	params := url.Values{}
+	params.Set("format", "application/json")
+	params.Set("latitude", fmt.Sprintf("%.4f", lat))
+	params.Set("longitude", fmt.Sprintf("%.4f", lon))
+	params.Set("distance", "50")
+	params.Set("API_KEY", ap.apiKey)
+
+	resp, err := ap.client.Get("https://www.airnowapi.org/aq/observation/latLong/current/?" + params.Encode())
+	if err != nil {
+		return AQIReading{}, fmt.Errorf("failed to query AirNow: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return AQIReading{}, fmt.Errorf("AirNow returned HTTP %d", resp.StatusCode)
+	}
+
+	var observations []struct {
+		DateObserved  string `json:"DateObserved"`
+		HourObserved  int    `json:"HourObserved"`
+		ParameterName string `json:"ParameterName"`
+		AQI           int    `json:"AQI"`
+		Category      struct {
+			Number int    `json:"Number"`
+			Name   string `json:"Name"`
+		} `json:"Category"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&observations); err != nil {
+		return AQIReading{}, fmt.Errorf("failed to decode AirNow response: %w", err)
+	}
+
+	if len(observations) == 0 {
+		return AQIReading{}, fmt.Errorf("no AirNow observations near %.4f,%.4f", lat, lon)
+	}
+
+	// The reported AQI for an area is the highest across its pollutants
+	worst := observations[0]
+	for _, observation := range observations[1:] {
+		if observation.AQI > worst.AQI {
+			worst = observation
+		}
+	}
+
+	category := ParseAQICategory(worst.Category.Name)
+	if category == AQICategoryUnknown && worst.Category.Number >= 1 && worst.Category.Number <= 6 {
+		category = AQICategory(worst.Category.Number)
+	}
+	if category == AQICategoryUnknown {
+		category = AQICategoryForValue(worst.AQI)
+	}
+
+	observedAt, err := time.Parse("2006-01-02", strings.TrimSpace(worst.DateObserved))
+	if err != nil {
+		observedAt = time.Now()
+	} else {
+		observedAt = observedAt.Add(time.Duration(worst.HourObserved) * time.Hour)
+	}
+
+	return AQIReading{
+		AQI:        worst.AQI,
+		Category:   category,
+		Pollutant:  worst.ParameterName,
+		Source:     ap.Name(),
+		ObservedAt: observedAt,
+	}, nil
+}
+
+// OpenAQProvider reads PM2.5 measurements from the OpenAQ v3 API and converts them to AQI
+type OpenAQProvider struct {
+	client *http.Client
+	apiKey string
+}
+
+func (op *OpenAQProvider) Name() string { return "openaq" }
+
+// GetAQI finds the nearest station with a PM2.5 sensor and converts its latest value
+func (op *OpenAQProvider) GetAQI(lat, lon float64) (AQIReading, error) {
+	params := url.Values{}
+	params.Set("coordinates", fmt.Sprintf("%.4f,%.4f", lat, lon))
+	params.Set("radius", "25000")
+	params.Set("parameters_id", "2") // PM2.5
+	params.Set("limit", "1")
+
+	var locations struct {
+		Results []struct {
+			ID      int `json:"id"`
+			Sensors []struct {
+				ID        int `json:"id"`
+				Parameter struct {
+					Name string `json:"name"`
+				} `json:"parameter"`
+			} `json:"sensors"`
+		} `json:"results"`
+	}
+	if err := op.get("/v3/locations?"+params.Encode(), &locations); err != nil {
+		return AQIReading{}, err
+	}
+	if len(locations.Results) == 0 {
+		return AQIReading{}, fmt.Errorf("no OpenAQ stations near %.4f,%.4f", lat, lon)
+	}
+
+	station := locations.Results[0]
+	pm25Sensors := make(map[int]bool)
+	for _, sensor := range station.Sensors {
+		if sensor.Parameter.Name == "pm25" {
+			pm25Sensors[sensor.ID] = true
+		}
+	}
+
+	var latest struct {
+		Results []struct {
+			SensorsID int     `json:"sensorsId"`
+			Value     float64 `json:"value"`
+			Datetime  struct {
+				UTC time.Time `json:"utc"`
+			} `json:"datetime"`
+		} `json:"results"`
+	}
+	if err := op.get(fmt.Sprintf("/v3/locations/%d/latest", station.ID), &latest); err != nil {
+		return AQIReading{}, err
+	}
+
+	for _, measurement := range latest.Results {
+		if !pm25Sensors[measurement.SensorsID] || measurement.Value < 0 {
+			continue
+		}
+
+		aqi := PM25ToAQI(measurement.Value)
+		return AQIReading{
+			AQI:        aqi,
+			Category:   AQICategoryForValue(aqi),
+			Pollutant:  "PM2.5",
+			Source:     op.Name(),
+			ObservedAt: measurement.Datetime.UTC,
+		}, nil
+	}
+
+	return AQIReading{}, fmt.Errorf("OpenAQ station %d has no recent PM2.5 measurement", station.ID)
+}
+
+func (op *OpenAQProvider) get(path string, target interface{}) error {
+	req, err := http.NewRequest("GET", "https://api.openaq.org"+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", op.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := op.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query OpenAQ: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("OpenAQ returned HTTP %d", resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return fmt.Errorf("failed to decode OpenAQ response: %w", err)
+	}
+	return nil
+}
+
+// pm25Breakpoints are the EPA PM2.5 (µg/m³, 24-hour) to AQI breakpoints
+var pm25Breakpoints = []struct {
+	concLow, concHigh float64
+	aqiLow, aqiHigh   int
+}{
+	{0.0, 9.0, 0, 50},
+	{9.1, 35.4, 51, 100},
+	{35.5, 55.4, 101, 150},
+	{55.5, 125.4, 151, 200},
+	{125.5, 225.4, 201, 300},
+	{225.5, 325.4, 301, 500},
+}
+
+// PM25ToAQI converts a PM2.5 concentration to an AQI value using EPA linear interpolation
+func PM25ToAQI(concentration float64) int {
+	// EPA truncates PM2.5 to one decimal place before looking up the breakpoint
+	concentration = math.Floor(concentration*10) / 10
+
+	for _, bp := range pm25Breakpoints {
+		if concentration <= bp.concHigh {
+			if concentration < bp.concLow {
+				concentration = bp.concLow
+			}
+			ratio := (concentration - bp.concLow) / (bp.concHigh - bp.concLow)
+			return int(math.Round(float64(bp.aqiLow) + ratio*float64(bp.aqiHigh-bp.aqiLow)))
+		}
+	}
+	return 500
+}
+
+// CachedAQIProvider caches readings per location so repeated calls don't hit provider rate limits
+type CachedAQIProvider struct {
+	provider AQIProvider
+	cache    *geoCache[AQIReading]
+}
+
+// NewCachedAQIProvider wraps a provider with a per-location cache, rounding coordinates to precision decimal places
+func NewCachedAQIProvider(provider AQIProvider, ttl time.Duration, precision int) *CachedAQIProvider {
+	return &CachedAQIProvider{
+		provider: provider,
+		cache:    newGeoCache[AQIReading]("aqi:"+provider.Name(), ttl, precision),
+	}
+}
+
+func (cp *CachedAQIProvider) Name() string { return cp.provider.Name() }
+
+// GetAQI returns a cached reading for the location or fetches a fresh one
+func (cp *CachedAQIProvider) GetAQI(lat, lon float64) (AQIReading, error) {
+	return cp.cache.Get(lat, lon, func() (AQIReading, error) {
+		return cp.provider.GetAQI(lat, lon)
+	})
+}
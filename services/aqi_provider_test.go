@@ -0,0 +1,35 @@
+package services
+
+import "testing"
+
+func TestPM25ToAQI(t *testing.T) {
+	cases := []struct {
+		concentration float64
+		want          int
+	}{
+		{0, 0},
+		{9.0, 50},
+		{12.0, 56},
+		{35.4, 100},
+		{55.5, 151},
+		{500, 500},
+	}
+
+	for _, tc := range cases {
+		if got := PM25ToAQI(tc.concentration); got != tc.want {
+			t.Errorf("PM25ToAQI(%v) = %d, want %d", tc.concentration, got, tc.want)
+		}
+	}
+}
+
+func TestParseAQICategory(t *testing.T) {
+	if got := ParseAQICategory("Unhealthy for Sensitive Groups"); got != AQICategoryUnhealthySensitive {
+		t.Errorf("expected USG category, got %d", got)
+	}
+	if got := ParseAQICategory("Very Unhealthy"); got != AQICategoryVeryUnhealthy {
+		t.Errorf("expected very unhealthy category, got %d", got)
+	}
+	if got := ParseAQICategory("Smoky"); got != AQICategoryUnknown {
+		t.Errorf("expected unknown category, got %d", got)
+	}
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ArchiveStorage stores archived recordings and manifests under slash-separated keys
+type ArchiveStorage interface {
+	Name() string
+	Put(key string, body []byte, contentType string) (location string, err error)
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+}
+
+// ArchiveSettings selects and configures where recordings are archived
+type ArchiveSettings struct {
+	Storage string // none, local, s3
+	Dir     string // local storage directory
+
+	S3Bucket       string
+	S3Prefix       string
+	S3Region       string
+	S3Endpoint     string // S3-compatible endpoint; "" uses AWS
+	S3AccessKey    string
+	S3SecretKey    string
+	S3SessionToken string
+}
+
+// NewArchiveStorage creates the configured storage, or nil if archiving is disabled
+func NewArchiveStorage(settings ArchiveSettings) ArchiveStorage {
+	switch strings.ToLower(settings.Storage) {
+	case "", "none":
+		return nil
+	case "local":
+		if settings.Dir == "" {
+			log.Printf("[Archive] No archive directory configured, archiving disabled")
+			return nil
+		}
+		return &LocalArchiveStorage{dir: settings.Dir}
+	case "s3":
+		if settings.S3Bucket == "" || settings.S3Region == "" || settings.S3AccessKey == "" || settings.S3SecretKey == "" {
+			log.Printf("[Archive] S3 archiving is missing a bucket, region or AWS credentials, archiving disabled")
+			return nil
+		}
+		endpoint := strings.TrimRight(settings.S3Endpoint, "/")
+		if endpoint == "" {
+			endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", settings.S3Region)
+		}
+		return &S3ArchiveStorage{
+			client:       &http.Client{Timeout: 60 * time.Second},
+			endpoint:     endpoint,
+			bucket:       settings.S3Bucket,
+			prefix:       strings.Trim(settings.S3Prefix, "/"),
+			region:       settings.S3Region,
+			accessKey:    settings.S3AccessKey,
+			secretKey:    settings.S3SecretKey,
+			sessionToken: settings.S3SessionToken,
+		}
+	default:
+		log.Printf("[Archive] Unknown storage %q, archiving disabled", settings.Storage)
+		return nil
+	}
+}
+
+// LocalArchiveStorage writes archived files under a directory
+type LocalArchiveStorage struct {
+	dir string
+}
+
+func (ls *LocalArchiveStorage) Name() string { return "local" }
+
+// Put writes the file, creating directories as needed
+func (ls *LocalArchiveStorage) Put(key string, body []byte, contentType string) (string, error) {
+	target, err := ls.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	if err := os.WriteFile(target, body, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	return target, nil
+}
+
+// Get reads the file
+func (ls *LocalArchiveStorage) Get(key string) ([]byte, error) {
+	target, err := ls.path(key)
+	if err != nil {
+		return nil, err
+	}
+	body, err := os.ReadFile(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", target, err)
+	}
+	return body, nil
+}
+
+// Delete removes the file; a file that is already gone is not an error
+func (ls *LocalArchiveStorage) Delete(key string) error {
+	target, err := ls.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete %s: %w", target, err)
+	}
+	return nil
+}
+
+// path maps a key to a file inside the archive directory
+func (ls *LocalArchiveStorage) path(key string) (string, error) {
+	cleaned := path.Clean("/" + key)
+	if cleaned == "/" {
+		return "", fmt.Errorf("invalid archive key %q", key)
+	}
+	return filepath.Join(ls.dir, filepath.FromSlash(cleaned)), nil
+}
+
+// S3ArchiveStorage uploads archived files to an S3 (or S3-compatible) bucket
+type S3ArchiveStorage struct {
+	client       *http.Client
+	endpoint     string
+	bucket       string
+	prefix       string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+func (ss *S3ArchiveStorage) Name() string { return "s3" }
+
+// Put uploads the object with PutObject
+func (ss *S3ArchiveStorage) Put(key string, body []byte, contentType string) (string, error) {
+	if _, err := ss.do("PUT", key, body, contentType); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("s3://%s/%s", ss.bucket, ss.objectKey(key)), nil
+}
+
+// Get downloads the object with GetObject
+func (ss *S3ArchiveStorage) Get(key string) ([]byte, error) {
+	return ss.do("GET", key, nil, "")
+}
+
+// Delete removes the object with DeleteObject
+func (ss *S3ArchiveStorage) Delete(key string) error {
+	_, err := ss.do("DELETE", key, nil, "")
+	return err
+}
+
+func (ss *S3ArchiveStorage) objectKey(key string) string {
+	if ss.prefix == "" {
+		return key
+	}
+	return ss.prefix + "/" + key
+}
+
+// do sends a signed path-style request for an object and returns the response body
+func (ss *S3ArchiveStorage) do(method, key string, body []byte, contentType string) ([]byte, error) {
+	objectPath := "/" + ss.bucket + "/" + ss.objectKey(key)
+	escapedPath := (&url.URL{Path: objectPath}).EscapedPath()
+
+	req, err := http.NewRequest(method, ss.endpoint+escapedPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	ss.sign(req, escapedPath, body, time.Now().UTC())
+
+	resp, err := ss.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s %s: %w", method, objectPath, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("S3 %s %s returned HTTP %d: %s", method, objectPath, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	if method != "GET" {
+		return nil, nil
+	}
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, archiveMaxRecordingSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", objectPath, err)
+	}
+	return respBody, nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to the request
+func (ss *S3ArchiveStorage) sign(req *http.Request, escapedPath string, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if ss.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", ss.sessionToken)
+	}
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", req.URL.Host, payloadHash, amzDate)
+	if ss.sessionToken != "" {
+		signedHeaders += ";x-amz-security-token"
+		canonicalHeaders += fmt.Sprintf("x-amz-security-token:%s\n", ss.sessionToken)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method, escapedPath, "", canonicalHeaders, signedHeaders, payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", dateStamp, ss.region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	signingKey := archiveHMAC([]byte("AWS4"+ss.secretKey), dateStamp)
+	signingKey = archiveHMAC(signingKey, ss.region)
+	signingKey = archiveHMAC(signingKey, "s3")
+	signingKey = archiveHMAC(signingKey, "aws4_request")
+	signature := hex.EncodeToString(archiveHMAC(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		ss.accessKey, scope, signedHeaders, signature))
+}
+
+func archiveHMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -1,383 +1,223 @@
+// services/area_codes_data.go
+// Area code database loaded from a CSV or JSON data file
+
 package services
 
-// CompleteAreaCodes contains all North American area codes with their primary city/region
-// Data current as of 2024 - includes US, Canada, and territories
-var CompleteAreaCodes = map[string]Location{
-	// United States - Eastern Time Zone
-	"201": {City: "Jersey City", State: "NJ", Lat: 40.7282, Lon: -74.0776, Timezone: "America/New_York"},
-	"202": {City: "Washington", State: "DC", Lat: 38.9072, Lon: -77.0369, Timezone: "America/New_York"},
-	"203": {City: "Bridgeport", State: "CT", Lat: 41.1865, Lon: -73.1952, Timezone: "America/New_York"},
-	"207": {City: "Portland", State: "ME", Lat: 43.6591, Lon: -70.2568, Timezone: "America/New_York"},
-	"212": {City: "New York", State: "NY", Lat: 40.7128, Lon: -74.0060, Timezone: "America/New_York"},
-	"215": {City: "Philadelphia", State: "PA", Lat: 39.9526, Lon: -75.1652, Timezone: "America/New_York"},
-	"216": {City: "Cleveland", State: "OH", Lat: 41.4993, Lon: -81.6944, Timezone: "America/New_York"},
-	"220": {City: "Newark", State: "OH", Lat: 40.0581, Lon: -82.4013, Timezone: "America/New_York"},
-	"223": {City: "Lancaster", State: "PA", Lat: 40.0379, Lon: -76.3055, Timezone: "America/New_York"},
-	"229": {City: "Albany", State: "GA", Lat: 31.5785, Lon: -84.1557, Timezone: "America/New_York"},
-	"231": {City: "Muskegon", State: "MI", Lat: 43.2342, Lon: -86.2484, Timezone: "America/New_York"},
-	"234": {City: "Youngstown", State: "OH", Lat: 41.0998, Lon: -80.6495, Timezone: "America/New_York"},
-	"239": {City: "Fort Myers", State: "FL", Lat: 26.6406, Lon: -81.8723, Timezone: "America/New_York"},
-	"240": {City: "Germantown", State: "MD", Lat: 39.1732, Lon: -77.2717, Timezone: "America/New_York"},
-	"248": {City: "Troy", State: "MI", Lat: 42.6056, Lon: -83.1499, Timezone: "America/New_York"},
-	"252": {City: "Greenville", State: "NC", Lat: 35.6127, Lon: -77.3664, Timezone: "America/New_York"},
-	"267": {City: "Philadelphia", State: "PA", Lat: 39.9526, Lon: -75.1652, Timezone: "America/New_York"},
-	"269": {City: "Kalamazoo", State: "MI", Lat: 42.2917, Lon: -85.5872, Timezone: "America/New_York"},
-	"272": {City: "Lake Ariel", State: "PA", Lat: 41.4548, Lon: -75.3841, Timezone: "America/New_York"},
-	"276": {City: "Bristol", State: "VA", Lat: 36.5951, Lon: -82.1887, Timezone: "America/New_York"},
-	"301": {City: "Rockville", State: "MD", Lat: 39.0840, Lon: -77.1528, Timezone: "America/New_York"},
-	"302": {City: "Wilmington", State: "DE", Lat: 39.7391, Lon: -75.5398, Timezone: "America/New_York"},
-	"304": {City: "Charleston", State: "WV", Lat: 38.3498, Lon: -81.6326, Timezone: "America/New_York"},
-	"305": {City: "Miami", State: "FL", Lat: 25.7617, Lon: -80.1918, Timezone: "America/New_York"},
-	"313": {City: "Detroit", State: "MI", Lat: 42.3314, Lon: -83.0458, Timezone: "America/New_York"},
-	"315": {City: "Syracuse", State: "NY", Lat: 43.0481, Lon: -76.1474, Timezone: "America/New_York"},
-	"321": {City: "Orlando", State: "FL", Lat: 28.5383, Lon: -81.3792, Timezone: "America/New_York"},
-	"330": {City: "Akron", State: "OH", Lat: 41.0814, Lon: -81.5190, Timezone: "America/New_York"},
-	"332": {City: "New York", State: "NY", Lat: 40.7128, Lon: -74.0060, Timezone: "America/New_York"},
-	"336": {City: "Greensboro", State: "NC", Lat: 36.0726, Lon: -79.7920, Timezone: "America/New_York"},
-	"339": {City: "Boston", State: "MA", Lat: 42.3601, Lon: -71.0589, Timezone: "America/New_York"},
-	"347": {City: "Brooklyn", State: "NY", Lat: 40.6782, Lon: -73.9442, Timezone: "America/New_York"},
-	"351": {City: "Lowell", State: "MA", Lat: 42.6334, Lon: -71.3162, Timezone: "America/New_York"},
-	"352": {City: "Gainesville", State: "FL", Lat: 29.6516, Lon: -82.3248, Timezone: "America/New_York"},
-	"380": {City: "Columbus", State: "OH", Lat: 39.9612, Lon: -82.9988, Timezone: "America/New_York"},
-	"386": {City: "Daytona Beach", State: "FL", Lat: 29.2108, Lon: -81.0228, Timezone: "America/New_York"},
-	"401": {City: "Providence", State: "RI", Lat: 41.8240, Lon: -71.4128, Timezone: "America/New_York"},
-	"404": {City: "Atlanta", State: "GA", Lat: 33.7490, Lon: -84.3880, Timezone: "America/New_York"},
-	"407": {City: "Orlando", State: "FL", Lat: 28.5383, Lon: -81.3792, Timezone: "America/New_York"},
-	"410": {City: "Baltimore", State: "MD", Lat: 39.2904, Lon: -76.6122, Timezone: "America/New_York"},
-	"412": {City: "Pittsburgh", State: "PA", Lat: 40.4406, Lon: -79.9959, Timezone: "America/New_York"},
-	"413": {City: "Springfield", State: "MA", Lat: 42.1015, Lon: -72.5898, Timezone: "America/New_York"},
-	"419": {City: "Toledo", State: "OH", Lat: 41.6528, Lon: -83.5379, Timezone: "America/New_York"},
-	"423": {City: "Chattanooga", State: "TN", Lat: 35.0456, Lon: -85.3097, Timezone: "America/New_York"},
-	"434": {City: "Charlottesville", State: "VA", Lat: 38.0293, Lon: -78.4767, Timezone: "America/New_York"},
-	"440": {City: "Parma", State: "OH", Lat: 41.4048, Lon: -81.7229, Timezone: "America/New_York"},
-	"443": {City: "Baltimore", State: "MD", Lat: 39.2904, Lon: -76.6122, Timezone: "America/New_York"},
-	"445": {City: "Philadelphia", State: "PA", Lat: 39.9526, Lon: -75.1652, Timezone: "America/New_York"},
-	"470": {City: "Atlanta", State: "GA", Lat: 33.7490, Lon: -84.3880, Timezone: "America/New_York"},
-	"475": {City: "Bridgeport", State: "CT", Lat: 41.1865, Lon: -73.1952, Timezone: "America/New_York"},
-	"478": {City: "Macon", State: "GA", Lat: 32.8407, Lon: -83.6324, Timezone: "America/New_York"},
-	"484": {City: "Reading", State: "PA", Lat: 40.3356, Lon: -75.9269, Timezone: "America/New_York"},
-	"502": {City: "Louisville", State: "KY", Lat: 38.2527, Lon: -85.7585, Timezone: "America/New_York"},
-	"508": {City: "Worcester", State: "MA", Lat: 42.2626, Lon: -71.8023, Timezone: "America/New_York"},
-	"513": {City: "Cincinnati", State: "OH", Lat: 39.1031, Lon: -84.5120, Timezone: "America/New_York"},
-	"516": {City: "Hempstead", State: "NY", Lat: 40.7062, Lon: -73.6187, Timezone: "America/New_York"},
-	"517": {City: "Lansing", State: "MI", Lat: 42.7325, Lon: -84.5555, Timezone: "America/New_York"},
-	"518": {City: "Albany", State: "NY", Lat: 42.6526, Lon: -73.7562, Timezone: "America/New_York"},
-	"540": {City: "Roanoke", State: "VA", Lat: 37.2710, Lon: -79.9414, Timezone: "America/New_York"},
-	"551": {City: "Jersey City", State: "NJ", Lat: 40.7282, Lon: -74.0776, Timezone: "America/New_York"},
-	"561": {City: "West Palm Beach", State: "FL", Lat: 26.7153, Lon: -80.0534, Timezone: "America/New_York"},
-	"567": {City: "Toledo", State: "OH", Lat: 41.6528, Lon: -83.5379, Timezone: "America/New_York"},
-	"570": {City: "Scranton", State: "PA", Lat: 41.4090, Lon: -75.6624, Timezone: "America/New_York"},
-	"571": {City: "Arlington", State: "VA", Lat: 38.8816, Lon: -77.0910, Timezone: "America/New_York"},
-	"574": {City: "South Bend", State: "IN", Lat: 41.6764, Lon: -86.2520, Timezone: "America/New_York"},
-	"585": {City: "Rochester", State: "NY", Lat: 43.1566, Lon: -77.6088, Timezone: "America/New_York"},
-	"586": {City: "Warren", State: "MI", Lat: 42.5145, Lon: -83.0147, Timezone: "America/New_York"},
-	"603": {City: "Manchester", State: "NH", Lat: 42.9956, Lon: -71.4548, Timezone: "America/New_York"},
-	"607": {City: "Binghamton", State: "NY", Lat: 42.0987, Lon: -75.9180, Timezone: "America/New_York"},
-	"609": {City: "Trenton", State: "NJ", Lat: 40.2171, Lon: -74.7429, Timezone: "America/New_York"},
-	"610": {City: "Reading", State: "PA", Lat: 40.3356, Lon: -75.9269, Timezone: "America/New_York"},
-	"614": {City: "Columbus", State: "OH", Lat: 39.9612, Lon: -82.9988, Timezone: "America/New_York"},
-	"617": {City: "Boston", State: "MA", Lat: 42.3601, Lon: -71.0589, Timezone: "America/New_York"},
-	"631": {City: "Suffolk", State: "NY", Lat: 40.9257, Lon: -72.6148, Timezone: "America/New_York"},
-	"640": {City: "Trenton", State: "NJ", Lat: 40.2171, Lon: -74.7429, Timezone: "America/New_York"},
-	"646": {City: "Manhattan", State: "NY", Lat: 40.7831, Lon: -73.9712, Timezone: "America/New_York"},
-	"651": {City: "Saint Paul", State: "MN", Lat: 44.9537, Lon: -93.0900, Timezone: "America/Chicago"},
-	"656": {City: "Tampa", State: "FL", Lat: 27.9506, Lon: -82.4572, Timezone: "America/New_York"},
-	"667": {City: "Baltimore", State: "MD", Lat: 39.2904, Lon: -76.6122, Timezone: "America/New_York"},
-	"678": {City: "Atlanta", State: "GA", Lat: 33.7490, Lon: -84.3880, Timezone: "America/New_York"},
-	"680": {City: "Syracuse", State: "NY", Lat: 43.0481, Lon: -76.1474, Timezone: "America/New_York"},
-	"681": {City: "Charleston", State: "WV", Lat: 38.3498, Lon: -81.6326, Timezone: "America/New_York"},
-	"703": {City: "Arlington", State: "VA", Lat: 38.8816, Lon: -77.0910, Timezone: "America/New_York"},
-	"704": {City: "Charlotte", State: "NC", Lat: 35.2271, Lon: -80.8431, Timezone: "America/New_York"},
-	"706": {City: "Augusta", State: "GA", Lat: 33.4735, Lon: -82.0105, Timezone: "America/New_York"},
-	"716": {City: "Buffalo", State: "NY", Lat: 42.8864, Lon: -78.8784, Timezone: "America/New_York"},
-	"717": {City: "Lancaster", State: "PA", Lat: 40.0379, Lon: -76.3055, Timezone: "America/New_York"},
-	"718": {City: "Brooklyn", State: "NY", Lat: 40.6782, Lon: -73.9442, Timezone: "America/New_York"},
-	"724": {City: "New Castle", State: "PA", Lat: 41.0036, Lon: -80.3451, Timezone: "America/New_York"},
-	"727": {City: "St. Petersburg", State: "FL", Lat: 27.7676, Lon: -82.6403, Timezone: "America/New_York"},
-	"732": {City: "New Brunswick", State: "NJ", Lat: 40.4862, Lon: -74.4518, Timezone: "America/New_York"},
-	"734": {City: "Ann Arbor", State: "MI", Lat: 42.2808, Lon: -83.7430, Timezone: "America/New_York"},
-	"740": {City: "Columbus", State: "OH", Lat: 39.9612, Lon: -82.9988, Timezone: "America/New_York"},
-	"743": {City: "Greensboro", State: "NC", Lat: 36.0726, Lon: -79.7920, Timezone: "America/New_York"},
-	"754": {City: "Fort Lauderdale", State: "FL", Lat: 26.1224, Lon: -80.1373, Timezone: "America/New_York"},
-	"757": {City: "Virginia Beach", State: "VA", Lat: 36.8529, Lon: -75.9780, Timezone: "America/New_York"},
-	"762": {City: "Augusta", State: "GA", Lat: 33.4735, Lon: -82.0105, Timezone: "America/New_York"},
-	"770": {City: "Atlanta", State: "GA", Lat: 33.7490, Lon: -84.3880, Timezone: "America/New_York"},
-	"772": {City: "Port St. Lucie", State: "FL", Lat: 27.2730, Lon: -80.3582, Timezone: "America/New_York"},
-	"774": {City: "Worcester", State: "MA", Lat: 42.2626, Lon: -71.8023, Timezone: "America/New_York"},
-	"781": {City: "Boston", State: "MA", Lat: 42.3601, Lon: -71.0589, Timezone: "America/New_York"},
-	"786": {City: "Miami", State: "FL", Lat: 25.7617, Lon: -80.1918, Timezone: "America/New_York"},
-	"802": {City: "Burlington", State: "VT", Lat: 44.4759, Lon: -73.2121, Timezone: "America/New_York"},
-	"803": {City: "Columbia", State: "SC", Lat: 34.0007, Lon: -81.0348, Timezone: "America/New_York"},
-	"804": {City: "Richmond", State: "VA", Lat: 37.5407, Lon: -77.4360, Timezone: "America/New_York"},
-	"810": {City: "Flint", State: "MI", Lat: 43.0125, Lon: -83.6875, Timezone: "America/New_York"},
-	"813": {City: "Tampa", State: "FL", Lat: 27.9506, Lon: -82.4572, Timezone: "America/New_York"},
-	"814": {City: "Erie", State: "PA", Lat: 42.1292, Lon: -80.0851, Timezone: "America/New_York"},
-	"828": {City: "Asheville", State: "NC", Lat: 35.5951, Lon: -82.5515, Timezone: "America/New_York"},
-	"838": {City: "Albany", State: "NY", Lat: 42.6526, Lon: -73.7562, Timezone: "America/New_York"},
-	"843": {City: "Charleston", State: "SC", Lat: 32.7765, Lon: -79.9311, Timezone: "America/New_York"},
-	"845": {City: "Poughkeepsie", State: "NY", Lat: 41.7004, Lon: -73.9209, Timezone: "America/New_York"},
-	"848": {City: "New Brunswick", State: "NJ", Lat: 40.4862, Lon: -74.4518, Timezone: "America/New_York"},
-	"854": {City: "Charleston", State: "SC", Lat: 32.7765, Lon: -79.9311, Timezone: "America/New_York"},
-	"856": {City: "Camden", State: "NJ", Lat: 39.9259, Lon: -75.1196, Timezone: "America/New_York"},
-	"857": {City: "Boston", State: "MA", Lat: 42.3601, Lon: -71.0589, Timezone: "America/New_York"},
-	"859": {City: "Lexington", State: "KY", Lat: 38.0406, Lon: -84.5037, Timezone: "America/New_York"},
-	"860": {City: "Hartford", State: "CT", Lat: 41.7658, Lon: -72.6734, Timezone: "America/New_York"},
-	"862": {City: "Newark", State: "NJ", Lat: 40.7357, Lon: -74.1724, Timezone: "America/New_York"},
-	"863": {City: "Lakeland", State: "FL", Lat: 28.0395, Lon: -81.9498, Timezone: "America/New_York"},
-	"864": {City: "Greenville", State: "SC", Lat: 34.8526, Lon: -82.3940, Timezone: "America/New_York"},
-	"865": {City: "Knoxville", State: "TN", Lat: 35.9606, Lon: -83.9207, Timezone: "America/New_York"},
-	"878": {City: "Pittsburgh", State: "PA", Lat: 40.4406, Lon: -79.9959, Timezone: "America/New_York"},
-	"901": {City: "Memphis", State: "TN", Lat: 35.1495, Lon: -90.0490, Timezone: "America/Chicago"},
-	"904": {City: "Jacksonville", State: "FL", Lat: 30.3322, Lon: -81.6557, Timezone: "America/New_York"},
-	"908": {City: "Elizabeth", State: "NJ", Lat: 40.6640, Lon: -74.2107, Timezone: "America/New_York"},
-	"910": {City: "Fayetteville", State: "NC", Lat: 35.0527, Lon: -78.8784, Timezone: "America/New_York"},
-	"912": {City: "Savannah", State: "GA", Lat: 32.0809, Lon: -81.0912, Timezone: "America/New_York"},
-	"914": {City: "White Plains", State: "NY", Lat: 41.0340, Lon: -73.7629, Timezone: "America/New_York"},
-	"917": {City: "New York", State: "NY", Lat: 40.7128, Lon: -74.0060, Timezone: "America/New_York"},
-	"919": {City: "Raleigh", State: "NC", Lat: 35.7796, Lon: -78.6382, Timezone: "America/New_York"},
-	"929": {City: "Queens", State: "NY", Lat: 40.7282, Lon: -73.7949, Timezone: "America/New_York"},
-	"931": {City: "Clarksville", State: "TN", Lat: 36.5298, Lon: -87.3595, Timezone: "America/Chicago"},
-	"934": {City: "Brentwood", State: "NY", Lat: 40.7812, Lon: -73.2462, Timezone: "America/New_York"},
-	"937": {City: "Dayton", State: "OH", Lat: 39.7589, Lon: -84.1916, Timezone: "America/New_York"},
-	"941": {City: "Sarasota", State: "FL", Lat: 27.3364, Lon: -82.5307, Timezone: "America/New_York"},
-	"947": {City: "Troy", State: "MI", Lat: 42.6056, Lon: -83.1499, Timezone: "America/New_York"},
-	"954": {City: "Fort Lauderdale", State: "FL", Lat: 26.1224, Lon: -80.1373, Timezone: "America/New_York"},
-	"959": {City: "Hartford", State: "CT", Lat: 41.7658, Lon: -72.6734, Timezone: "America/New_York"},
-	"973": {City: "Newark", State: "NJ", Lat: 40.7357, Lon: -74.1724, Timezone: "America/New_York"},
-	"978": {City: "Lowell", State: "MA", Lat: 42.6334, Lon: -71.3162, Timezone: "America/New_York"},
-	"980": {City: "Charlotte", State: "NC", Lat: 35.2271, Lon: -80.8431, Timezone: "America/New_York"},
-	"984": {City: "Raleigh", State: "NC", Lat: 35.7796, Lon: -78.6382, Timezone: "America/New_York"},
-	"989": {City: "Saginaw", State: "MI", Lat: 43.4195, Lon: -83.9508, Timezone: "America/New_York"},
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// embeddedAreaCodes is the area code file shipped with the binary, used when no
+// AREA_CODES_FILE is configured or it can't be loaded
+//
+//go:embed data/area_codes.csv
+var embeddedAreaCodes []byte
+
+// GlobalAreaCodes is the area code database shared by the IVR apps and dashboard events
+var GlobalAreaCodes = NewAreaCodeDatabase()
+
+// AreaCodeDatabase maps NANP area codes to their primary city/region.
+// It is safe for concurrent use and can be reloaded at runtime.
+type AreaCodeDatabase struct {
+	mu            sync.RWMutex
+	codes         map[string]Location
+	stateDefaults map[string]Location // state -> location of its principal city
+	source        string
+	loadedAt      time.Time
+
+	unknownMu sync.Mutex
+	unknown   map[string]*UnknownAreaCode
+}
+
+// AreaCodeRecord is one area code in a JSON data file
+type AreaCodeRecord struct {
+	AreaCode string  `json:"area_code"`
+	City     string  `json:"city"`
+	State    string  `json:"state"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+	Timezone string  `json:"timezone"`
+}
+
+// LocationDataInfo describes where area code or ZIP code data was loaded from
+type LocationDataInfo struct {
+	Source   string    `json:"source"`
+	Count    int       `json:"count"`
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// NewAreaCodeDatabase creates a database with the embedded area codes
+func NewAreaCodeDatabase() *AreaCodeDatabase {
+	db := &AreaCodeDatabase{unknown: make(map[string]*UnknownAreaCode)}
+	if err := db.Load(""); err != nil {
+		// The embedded file is compiled into the binary, so this is a programming error
+		panic(err)
+	}
+	return db
+}
+
+// Load replaces the area codes with the file at path (CSV, or JSON by extension; "" for the
+// embedded data). On error the current area codes are left untouched.
+func (db *AreaCodeDatabase) Load(path string) error {
+	var codes map[string]Location
+	var err error
+
+	source := "embedded"
+	if path == "" {
+		codes, err = parseAreaCodesCSV(bytes.NewReader(embeddedAreaCodes))
+	} else {
+		source = path
+		codes, err = readAreaCodesFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	if len(codes) == 0 {
+		return fmt.Errorf("no area codes in %s", source)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.codes = codes
+	db.stateDefaults = stateDefaults(codes)
+	db.source = source
+	db.loadedAt = time.Now()
+	return nil
+}
+
+// Lookup returns the location for an area code
+func (db *AreaCodeDatabase) Lookup(areaCode string) (Location, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
 
-	// Central Time Zone
-	"205": {City: "Birmingham", State: "AL", Lat: 33.5207, Lon: -86.8025, Timezone: "America/Chicago"},
-	"210": {City: "San Antonio", State: "TX", Lat: 29.4241, Lon: -98.4936, Timezone: "America/Chicago"},
-	"214": {City: "Dallas", State: "TX", Lat: 32.7767, Lon: -96.7970, Timezone: "America/Chicago"},
-	"217": {City: "Springfield", State: "IL", Lat: 39.7817, Lon: -89.6501, Timezone: "America/Chicago"},
-	"218": {City: "Duluth", State: "MN", Lat: 46.7867, Lon: -92.1005, Timezone: "America/Chicago"},
-	"219": {City: "Gary", State: "IN", Lat: 41.5934, Lon: -87.3464, Timezone: "America/Chicago"},
-	"224": {City: "Schaumburg", State: "IL", Lat: 42.0334, Lon: -88.0834, Timezone: "America/Chicago"},
-	"225": {City: "Baton Rouge", State: "LA", Lat: 30.4515, Lon: -91.1871, Timezone: "America/Chicago"},
-	"228": {City: "Gulfport", State: "MS", Lat: 30.3674, Lon: -89.0928, Timezone: "America/Chicago"},
-	"251": {City: "Mobile", State: "AL", Lat: 30.6954, Lon: -88.0399, Timezone: "America/Chicago"},
-	"254": {City: "Killeen", State: "TX", Lat: 31.1171, Lon: -97.7278, Timezone: "America/Chicago"},
-	"256": {City: "Huntsville", State: "AL", Lat: 34.7304, Lon: -86.5861, Timezone: "America/Chicago"},
-	"260": {City: "Fort Wayne", State: "IN", Lat: 41.0793, Lon: -85.1394, Timezone: "America/New_York"},
-	"262": {City: "Milwaukee", State: "WI", Lat: 43.0389, Lon: -87.9065, Timezone: "America/Chicago"},
-	"270": {City: "Bowling Green", State: "KY", Lat: 36.9685, Lon: -86.4808, Timezone: "America/Chicago"},
-	"281": {City: "Houston", State: "TX", Lat: 29.7604, Lon: -95.3698, Timezone: "America/Chicago"},
-	"309": {City: "Peoria", State: "IL", Lat: 40.6936, Lon: -89.5890, Timezone: "America/Chicago"},
-	"312": {City: "Chicago", State: "IL", Lat: 41.8781, Lon: -87.6298, Timezone: "America/Chicago"},
-	"314": {City: "St. Louis", State: "MO", Lat: 38.6270, Lon: -90.1994, Timezone: "America/Chicago"},
-	"316": {City: "Wichita", State: "KS", Lat: 37.6872, Lon: -97.3301, Timezone: "America/Chicago"},
-	"318": {City: "Shreveport", State: "LA", Lat: 32.5252, Lon: -93.7502, Timezone: "America/Chicago"},
-	"319": {City: "Cedar Rapids", State: "IA", Lat: 41.9779, Lon: -91.6656, Timezone: "America/Chicago"},
-	"320": {City: "St. Cloud", State: "MN", Lat: 45.5579, Lon: -94.1636, Timezone: "America/Chicago"},
-	"331": {City: "Aurora", State: "IL", Lat: 41.7606, Lon: -88.3201, Timezone: "America/Chicago"},
-	"334": {City: "Montgomery", State: "AL", Lat: 32.3668, Lon: -86.3000, Timezone: "America/Chicago"},
-	"337": {City: "Lafayette", State: "LA", Lat: 30.2241, Lon: -92.0198, Timezone: "America/Chicago"},
-	"346": {City: "Houston", State: "TX", Lat: 29.7604, Lon: -95.3698, Timezone: "America/Chicago"},
-	"361": {City: "Corpus Christi", State: "TX", Lat: 27.8006, Lon: -97.3964, Timezone: "America/Chicago"},
-	"364": {City: "Owensboro", State: "KY", Lat: 37.7719, Lon: -87.1112, Timezone: "America/Chicago"},
-	"402": {City: "Omaha", State: "NE", Lat: 41.2565, Lon: -95.9345, Timezone: "America/Chicago"},
-	"405": {City: "Oklahoma City", State: "OK", Lat: 35.4676, Lon: -97.5164, Timezone: "America/Chicago"},
-	"409": {City: "Beaumont", State: "TX", Lat: 30.0860, Lon: -94.1018, Timezone: "America/Chicago"},
-	"414": {City: "Milwaukee", State: "WI", Lat: 43.0389, Lon: -87.9065, Timezone: "America/Chicago"},
-	"417": {City: "Springfield", State: "MO", Lat: 37.2090, Lon: -93.2923, Timezone: "America/Chicago"},
-	"430": {City: "Tyler", State: "TX", Lat: 32.3513, Lon: -95.3011, Timezone: "America/Chicago"},
-	"432": {City: "Midland", State: "TX", Lat: 31.9973, Lon: -102.0779, Timezone: "America/Chicago"},
-	"447": {City: "Champaign", State: "IL", Lat: 40.1164, Lon: -88.2434, Timezone: "America/Chicago"},
-	"464": {City: "Cicero", State: "IL", Lat: 41.8456, Lon: -87.7539, Timezone: "America/Chicago"},
-	"469": {City: "Dallas", State: "TX", Lat: 32.7767, Lon: -96.7970, Timezone: "America/Chicago"},
-	"479": {City: "Fort Smith", State: "AR", Lat: 35.3859, Lon: -94.3985, Timezone: "America/Chicago"},
-	"501": {City: "Little Rock", State: "AR", Lat: 34.7465, Lon: -92.2896, Timezone: "America/Chicago"},
-	"504": {City: "New Orleans", State: "LA", Lat: 29.9511, Lon: -90.0715, Timezone: "America/Chicago"},
-	"507": {City: "Rochester", State: "MN", Lat: 44.0121, Lon: -92.4802, Timezone: "America/Chicago"},
-	"512": {City: "Austin", State: "TX", Lat: 30.2672, Lon: -97.7431, Timezone: "America/Chicago"},
-	"515": {City: "Des Moines", State: "IA", Lat: 41.5868, Lon: -93.6250, Timezone: "America/Chicago"},
-	"531": {City: "Omaha", State: "NE", Lat: 41.2565, Lon: -95.9345, Timezone: "America/Chicago"},
-	"534": {City: "Eau Claire", State: "WI", Lat: 44.8113, Lon: -91.4985, Timezone: "America/Chicago"},
-	"539": {City: "Tulsa", State: "OK", Lat: 36.1540, Lon: -95.9928, Timezone: "America/Chicago"},
-	"563": {City: "Davenport", State: "IA", Lat: 41.5236, Lon: -90.5776, Timezone: "America/Chicago"},
-	"573": {City: "Columbia", State: "MO", Lat: 38.9517, Lon: -92.3341, Timezone: "America/Chicago"},
-	"580": {City: "Lawton", State: "OK", Lat: 34.6036, Lon: -98.3959, Timezone: "America/Chicago"},
-	"601": {City: "Jackson", State: "MS", Lat: 32.2988, Lon: -90.1848, Timezone: "America/Chicago"},
-	"608": {City: "Madison", State: "WI", Lat: 43.0731, Lon: -89.4012, Timezone: "America/Chicago"},
-	"612": {City: "Minneapolis", State: "MN", Lat: 44.9778, Lon: -93.2650, Timezone: "America/Chicago"},
-	"615": {City: "Nashville", State: "TN", Lat: 36.1627, Lon: -86.7816, Timezone: "America/Chicago"},
-	"618": {City: "Belleville", State: "IL", Lat: 38.5201, Lon: -89.9840, Timezone: "America/Chicago"},
-	"620": {City: "Hutchinson", State: "KS", Lat: 38.0608, Lon: -97.9298, Timezone: "America/Chicago"},
-	"629": {City: "Nashville", State: "TN", Lat: 36.1627, Lon: -86.7816, Timezone: "America/Chicago"},
-	"630": {City: "Aurora", State: "IL", Lat: 41.7606, Lon: -88.3201, Timezone: "America/Chicago"},
-	"636": {City: "Chesterfield", State: "MO", Lat: 38.6631, Lon: -90.5771, Timezone: "America/Chicago"},
-	"641": {City: "Mason City", State: "IA", Lat: 43.1536, Lon: -93.2010, Timezone: "America/Chicago"},
-	"659": {City: "Birmingham", State: "AL", Lat: 33.5207, Lon: -86.8025, Timezone: "America/Chicago"},
-	"660": {City: "Sedalia", State: "MO", Lat: 38.7045, Lon: -93.2283, Timezone: "America/Chicago"},
-	"662": {City: "Tupelo", State: "MS", Lat: 34.2576, Lon: -88.7034, Timezone: "America/Chicago"},
-	"682": {City: "Fort Worth", State: "TX", Lat: 32.7555, Lon: -97.3308, Timezone: "America/Chicago"},
-	"708": {City: "Cicero", State: "IL", Lat: 41.8456, Lon: -87.7539, Timezone: "America/Chicago"},
-	"712": {City: "Sioux City", State: "IA", Lat: 42.4999, Lon: -96.4003, Timezone: "America/Chicago"},
-	"713": {City: "Houston", State: "TX", Lat: 29.7604, Lon: -95.3698, Timezone: "America/Chicago"},
-	"715": {City: "Eau Claire", State: "WI", Lat: 44.8113, Lon: -91.4985, Timezone: "America/Chicago"},
-	"726": {City: "San Antonio", State: "TX", Lat: 29.4241, Lon: -98.4936, Timezone: "America/Chicago"},
-	"731": {City: "Jackson", State: "TN", Lat: 35.6145, Lon: -88.8139, Timezone: "America/Chicago"},
-	"737": {City: "Austin", State: "TX", Lat: 30.2672, Lon: -97.7431, Timezone: "America/Chicago"},
-	"763": {City: "Brooklyn Park", State: "MN", Lat: 45.0941, Lon: -93.3563, Timezone: "America/Chicago"},
-	"769": {City: "Jackson", State: "MS", Lat: 32.2988, Lon: -90.1848, Timezone: "America/Chicago"},
-	"773": {City: "Chicago", State: "IL", Lat: 41.8781, Lon: -87.6298, Timezone: "America/Chicago"},
-	"779": {City: "Rockford", State: "IL", Lat: 42.2711, Lon: -89.0940, Timezone: "America/Chicago"},
-	"785": {City: "Topeka", State: "KS", Lat: 39.0558, Lon: -95.6890, Timezone: "America/Chicago"},
-	"812": {City: "Evansville", State: "IN", Lat: 37.9716, Lon: -87.5711, Timezone: "America/Chicago"},
-	"815": {City: "Rockford", State: "IL", Lat: 42.2711, Lon: -89.0940, Timezone: "America/Chicago"},
-	"816": {City: "Kansas City", State: "MO", Lat: 39.0997, Lon: -94.5786, Timezone: "America/Chicago"},
-	"817": {City: "Fort Worth", State: "TX", Lat: 32.7555, Lon: -97.3308, Timezone: "America/Chicago"},
-	"830": {City: "New Braunfels", State: "TX", Lat: 29.7030, Lon: -98.1245, Timezone: "America/Chicago"},
-	"832": {City: "Houston", State: "TX", Lat: 29.7604, Lon: -95.3698, Timezone: "America/Chicago"},
-	"847": {City: "Elgin", State: "IL", Lat: 42.0354, Lon: -88.2826, Timezone: "America/Chicago"},
-	"850": {City: "Tallahassee", State: "FL", Lat: 30.4383, Lon: -84.2807, Timezone: "America/New_York"},
-	"870": {City: "Jonesboro", State: "AR", Lat: 35.8423, Lon: -90.7043, Timezone: "America/Chicago"},
-	"872": {City: "Chicago", State: "IL", Lat: 41.8781, Lon: -87.6298, Timezone: "America/Chicago"},
-	"903": {City: "Tyler", State: "TX", Lat: 32.3513, Lon: -95.3011, Timezone: "America/Chicago"},
-	"906": {City: "Marquette", State: "MI", Lat: 46.5436, Lon: -87.3954, Timezone: "America/New_York"},
-	"913": {City: "Kansas City", State: "KS", Lat: 39.1141, Lon: -94.6275, Timezone: "America/Chicago"},
-	"915": {City: "El Paso", State: "TX", Lat: 31.7619, Lon: -106.4850, Timezone: "America/Denver"},
-	"918": {City: "Tulsa", State: "OK", Lat: 36.1540, Lon: -95.9928, Timezone: "America/Chicago"},
-	"920": {City: "Green Bay", State: "WI", Lat: 44.5133, Lon: -88.0133, Timezone: "America/Chicago"},
-	"930": {City: "Evansville", State: "IN", Lat: 37.9716, Lon: -87.5711, Timezone: "America/Chicago"},
-	"936": {City: "Huntsville", State: "TX", Lat: 30.7235, Lon: -95.5508, Timezone: "America/Chicago"},
-	"938": {City: "Huntsville", State: "AL", Lat: 34.7304, Lon: -86.5861, Timezone: "America/Chicago"},
-	"940": {City: "Denton", State: "TX", Lat: 33.2148, Lon: -97.1331, Timezone: "America/Chicago"},
-	"945": {City: "Dallas", State: "TX", Lat: 32.7767, Lon: -96.7970, Timezone: "America/Chicago"},
-	"952": {City: "Bloomington", State: "MN", Lat: 44.8408, Lon: -93.2983, Timezone: "America/Chicago"},
-	"956": {City: "Laredo", State: "TX", Lat: 27.5306, Lon: -99.4803, Timezone: "America/Chicago"},
-	"972": {City: "Dallas", State: "TX", Lat: 32.7767, Lon: -96.7970, Timezone: "America/Chicago"},
-	"979": {City: "College Station", State: "TX", Lat: 30.6280, Lon: -96.3344, Timezone: "America/Chicago"},
-	"985": {City: "Hammond", State: "LA", Lat: 30.5044, Lon: -90.4612, Timezone: "America/Chicago"},
+	location, exists := db.codes[areaCode]
+	return location, exists
+}
+
+// All returns every area code. The map is replaced rather than modified on reload,
+// so callers may range over it but must not change it.
+func (db *AreaCodeDatabase) All() map[string]Location {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.codes
+}
+
+// Info returns where the area codes were loaded from and how many there are
+func (db *AreaCodeDatabase) Info() LocationDataInfo {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return LocationDataInfo{Source: db.source, Count: len(db.codes), LoadedAt: db.loadedAt}
+}
+
+func readAreaCodesFile(path string) (map[string]Location, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read area codes file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return parseAreaCodesJSON(data)
+	}
+	return parseAreaCodesCSV(bytes.NewReader(data))
+}
+
+// parseAreaCodesCSV reads rows of area_code,city,state,lat,lon,timezone after a header row
+func parseAreaCodesCSV(r io.Reader) (map[string]Location, error) {
+	codes := make(map[string]Location)
+	err := readLocationCSV(r, "area_code", func(record AreaCodeRecord) error {
+		return addAreaCode(codes, record)
+	})
+	return codes, err
+}
+
+// readLocationCSV reads rows of <keyColumn>,city,state,lat,lon,timezone after a header row,
+// passing each to add with the key as AreaCode. Lines starting with # are comments.
+func readLocationCSV(r io.Reader, keyColumn string, add func(record AreaCodeRecord) error) error {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 6
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	if strings.TrimSpace(header[0]) != keyColumn {
+		return fmt.Errorf("file must start with the header %s,city,state,lat,lon,timezone", keyColumn)
+	}
 
-	// Mountain Time Zone
-	"303": {City: "Denver", State: "CO", Lat: 39.7392, Lon: -104.9903, Timezone: "America/Denver"},
-	"307": {City: "Cheyenne", State: "WY", Lat: 41.1400, Lon: -104.8202, Timezone: "America/Denver"},
-	"308": {City: "Grand Island", State: "NE", Lat: 40.9264, Lon: -98.3420, Timezone: "America/Chicago"},
-	"385": {City: "Salt Lake City", State: "UT", Lat: 40.7608, Lon: -111.8910, Timezone: "America/Denver"},
-	"406": {City: "Billings", State: "MT", Lat: 45.7833, Lon: -108.5007, Timezone: "America/Denver"},
-	"435": {City: "St. George", State: "UT", Lat: 37.0965, Lon: -113.5684, Timezone: "America/Denver"},
-	"480": {City: "Mesa", State: "AZ", Lat: 33.4152, Lon: -111.8315, Timezone: "America/Phoenix"},
-	"505": {City: "Albuquerque", State: "NM", Lat: 35.0853, Lon: -106.6056, Timezone: "America/Denver"},
-	"520": {City: "Tucson", State: "AZ", Lat: 32.2226, Lon: -110.9747, Timezone: "America/Phoenix"},
-	"575": {City: "Las Cruces", State: "NM", Lat: 32.3199, Lon: -106.7637, Timezone: "America/Denver"},
-	"602": {City: "Phoenix", State: "AZ", Lat: 33.4484, Lon: -112.0740, Timezone: "America/Phoenix"},
-	"605": {City: "Sioux Falls", State: "SD", Lat: 43.5446, Lon: -96.7311, Timezone: "America/Chicago"},
-	"623": {City: "Phoenix", State: "AZ", Lat: 33.4484, Lon: -112.0740, Timezone: "America/Phoenix"},
-	"701": {City: "Fargo", State: "ND", Lat: 46.8772, Lon: -96.7898, Timezone: "America/Chicago"},
-	"719": {City: "Colorado Springs", State: "CO", Lat: 38.8339, Lon: -104.8214, Timezone: "America/Denver"},
-	"720": {City: "Denver", State: "CO", Lat: 39.7392, Lon: -104.9903, Timezone: "America/Denver"},
-	"725": {City: "Las Vegas", State: "NV", Lat: 36.1699, Lon: -115.1398, Timezone: "America/Los_Angeles"},
-	"775": {City: "Reno", State: "NV", Lat: 39.5296, Lon: -119.8138, Timezone: "America/Los_Angeles"},
-	"801": {City: "Salt Lake City", State: "UT", Lat: 40.7608, Lon: -111.8910, Timezone: "America/Denver"},
-	"806": {City: "Lubbock", State: "TX", Lat: 33.5779, Lon: -101.8552, Timezone: "America/Chicago"},
-	"928": {City: "Flagstaff", State: "AZ", Lat: 35.1983, Lon: -111.6513, Timezone: "America/Phoenix"},
-	"970": {City: "Fort Collins", State: "CO", Lat: 40.5853, Lon: -105.0844, Timezone: "America/Denver"},
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse: %w", err)
+		}
 
-	// Pacific Time Zone
-	"206": {City: "Seattle", State: "WA", Lat: 47.6062, Lon: -122.3321, Timezone: "America/Los_Angeles"},
-	"208": {City: "Boise", State: "ID", Lat: 43.6150, Lon: -116.2023, Timezone: "America/Denver"},
-	"209": {City: "Stockton", State: "CA", Lat: 37.9577, Lon: -121.2908, Timezone: "America/Los_Angeles"},
-	"213": {City: "Los Angeles", State: "CA", Lat: 34.0522, Lon: -118.2437, Timezone: "America/Los_Angeles"},
-	"253": {City: "Tacoma", State: "WA", Lat: 47.2529, Lon: -122.4443, Timezone: "America/Los_Angeles"},
-	"310": {City: "Los Angeles", State: "CA", Lat: 34.0522, Lon: -118.2437, Timezone: "America/Los_Angeles"},
-	"323": {City: "Los Angeles", State: "CA", Lat: 34.0522, Lon: -118.2437, Timezone: "America/Los_Angeles"},
-	"341": {City: "Oakland", State: "CA", Lat: 37.8044, Lon: -122.2712, Timezone: "America/Los_Angeles"},
-	"360": {City: "Vancouver", State: "WA", Lat: 45.6387, Lon: -122.6615, Timezone: "America/Los_Angeles"},
-	"408": {City: "San Jose", State: "CA", Lat: 37.3382, Lon: -121.8863, Timezone: "America/Los_Angeles"},
-	"415": {City: "San Francisco", State: "CA", Lat: 37.7749, Lon: -122.4194, Timezone: "America/Los_Angeles"},
-	"424": {City: "Los Angeles", State: "CA", Lat: 34.0522, Lon: -118.2437, Timezone: "America/Los_Angeles"},
-	"425": {City: "Bellevue", State: "WA", Lat: 47.6101, Lon: -122.2015, Timezone: "America/Los_Angeles"},
-	"442": {City: "Oceanside", State: "CA", Lat: 33.1959, Lon: -117.3795, Timezone: "America/Los_Angeles"},
-	"458": {City: "Eugene", State: "OR", Lat: 44.0521, Lon: -123.0868, Timezone: "America/Los_Angeles"},
-	"503": {City: "Portland", State: "OR", Lat: 45.5152, Lon: -122.6784, Timezone: "America/Los_Angeles"},
-	"509": {City: "Spokane", State: "WA", Lat: 47.6588, Lon: -117.4260, Timezone: "America/Los_Angeles"},
-	"510": {City: "Oakland", State: "CA", Lat: 37.8044, Lon: -122.2712, Timezone: "America/Los_Angeles"},
-	"530": {City: "Redding", State: "CA", Lat: 40.5865, Lon: -122.3917, Timezone: "America/Los_Angeles"},
-	"541": {City: "Eugene", State: "OR", Lat: 44.0521, Lon: -123.0868, Timezone: "America/Los_Angeles"},
-	"559": {City: "Fresno", State: "CA", Lat: 36.7378, Lon: -119.7871, Timezone: "America/Los_Angeles"},
-	"562": {City: "Long Beach", State: "CA", Lat: 33.7701, Lon: -118.1937, Timezone: "America/Los_Angeles"},
-	"564": {City: "Seattle", State: "WA", Lat: 47.6062, Lon: -122.3321, Timezone: "America/Los_Angeles"},
-	"619": {City: "San Diego", State: "CA", Lat: 32.7157, Lon: -117.1611, Timezone: "America/Los_Angeles"},
-	"626": {City: "Pasadena", State: "CA", Lat: 34.1478, Lon: -118.1445, Timezone: "America/Los_Angeles"},
-	"628": {City: "San Francisco", State: "CA", Lat: 37.7749, Lon: -122.4194, Timezone: "America/Los_Angeles"},
-	"650": {City: "San Mateo", State: "CA", Lat: 37.5630, Lon: -122.3255, Timezone: "America/Los_Angeles"},
-	"657": {City: "Anaheim", State: "CA", Lat: 33.8366, Lon: -117.9143, Timezone: "America/Los_Angeles"},
-	"661": {City: "Bakersfield", State: "CA", Lat: 35.3733, Lon: -119.0187, Timezone: "America/Los_Angeles"},
-	"669": {City: "San Jose", State: "CA", Lat: 37.3382, Lon: -121.8863, Timezone: "America/Los_Angeles"},
-	"702": {City: "Las Vegas", State: "NV", Lat: 36.1699, Lon: -115.1398, Timezone: "America/Los_Angeles"},
-	"707": {City: "Santa Rosa", State: "CA", Lat: 38.4404, Lon: -122.7141, Timezone: "America/Los_Angeles"},
-	"714": {City: "Anaheim", State: "CA", Lat: 33.8366, Lon: -117.9143, Timezone: "America/Los_Angeles"},
-	"747": {City: "Los Angeles", State: "CA", Lat: 34.0522, Lon: -118.2437, Timezone: "America/Los_Angeles"},
-	"760": {City: "Oceanside", State: "CA", Lat: 33.1959, Lon: -117.3795, Timezone: "America/Los_Angeles"},
-	"805": {City: "Oxnard", State: "CA", Lat: 34.1975, Lon: -119.1771, Timezone: "America/Los_Angeles"},
-	"818": {City: "Los Angeles", State: "CA", Lat: 34.0522, Lon: -118.2437, Timezone: "America/Los_Angeles"},
-	"820": {City: "Chico", State: "CA", Lat: 39.7285, Lon: -121.8375, Timezone: "America/Los_Angeles"},
-	"831": {City: "Salinas", State: "CA", Lat: 36.6777, Lon: -121.6555, Timezone: "America/Los_Angeles"},
-	"840": {City: "San Bernardino", State: "CA", Lat: 34.1083, Lon: -117.2898, Timezone: "America/Los_Angeles"},
-	"858": {City: "San Diego", State: "CA", Lat: 32.7157, Lon: -117.1611, Timezone: "America/Los_Angeles"},
-	"909": {City: "San Bernardino", State: "CA", Lat: 34.1083, Lon: -117.2898, Timezone: "America/Los_Angeles"},
-	"916": {City: "Sacramento", State: "CA", Lat: 38.5816, Lon: -121.4944, Timezone: "America/Los_Angeles"},
-	"925": {City: "Concord", State: "CA", Lat: 37.9780, Lon: -122.0311, Timezone: "America/Los_Angeles"},
-	"949": {City: "Irvine", State: "CA", Lat: 33.6846, Lon: -117.8265, Timezone: "America/Los_Angeles"},
-	"951": {City: "Riverside", State: "CA", Lat: 33.9533, Lon: -117.3962, Timezone: "America/Los_Angeles"},
-	"971": {City: "Portland", State: "OR", Lat: 45.5152, Lon: -122.6784, Timezone: "America/Los_Angeles"},
+		line, _ := reader.FieldPos(0)
+		lat, latErr := strconv.ParseFloat(row[3], 64)
+		lon, lonErr := strconv.ParseFloat(row[4], 64)
+		if latErr != nil || lonErr != nil {
+			return fmt.Errorf("line %d: invalid coordinates %q, %q", line, row[3], row[4])
+		}
 
-	// Alaska & Hawaii
-	"907": {City: "Anchorage", State: "AK", Lat: 61.2181, Lon: -149.9003, Timezone: "America/Anchorage"},
-	"808": {City: "Honolulu", State: "HI", Lat: 21.3099, Lon: -157.8581, Timezone: "Pacific/Honolulu"},
+		record := AreaCodeRecord{AreaCode: row[0], City: row[1], State: row[2], Lat: lat, Lon: lon, Timezone: row[5]}
+		if err := add(record); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// parseAreaCodesJSON reads an array of AreaCodeRecord
+func parseAreaCodesJSON(data []byte) (map[string]Location, error) {
+	var records []AreaCodeRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse area codes: %w", err)
+	}
+
+	codes := make(map[string]Location, len(records))
+	for i, record := range records {
+		if err := addAreaCode(codes, record); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i, err)
+		}
+	}
+	return codes, nil
+}
 
-	// Canada
-	"204": {City: "Winnipeg", State: "MB", Lat: 49.8951, Lon: -97.1384, Timezone: "America/Winnipeg"},
-	"226": {City: "London", State: "ON", Lat: 42.9849, Lon: -81.2453, Timezone: "America/Toronto"},
-	"236": {City: "Vancouver", State: "BC", Lat: 49.2827, Lon: -123.1207, Timezone: "America/Vancouver"},
-	"249": {City: "Sudbury", State: "ON", Lat: 46.4917, Lon: -80.9930, Timezone: "America/Toronto"},
-	"250": {City: "Victoria", State: "BC", Lat: 48.4284, Lon: -123.3656, Timezone: "America/Vancouver"},
-	"289": {City: "Hamilton", State: "ON", Lat: 43.2557, Lon: -79.8711, Timezone: "America/Toronto"},
-	"306": {City: "Regina", State: "SK", Lat: 50.4452, Lon: -104.6189, Timezone: "America/Regina"},
-	"343": {City: "Ottawa", State: "ON", Lat: 45.4215, Lon: -75.6972, Timezone: "America/Toronto"},
-	"365": {City: "Hamilton", State: "ON", Lat: 43.2557, Lon: -79.8711, Timezone: "America/Toronto"},
-	"403": {City: "Calgary", State: "AB", Lat: 51.0447, Lon: -114.0719, Timezone: "America/Edmonton"},
-	"416": {City: "Toronto", State: "ON", Lat: 43.6532, Lon: -79.3832, Timezone: "America/Toronto"},
-	"418": {City: "Quebec City", State: "QC", Lat: 46.8139, Lon: -71.2080, Timezone: "America/Toronto"},
-	"431": {City: "Winnipeg", State: "MB", Lat: 49.8951, Lon: -97.1384, Timezone: "America/Winnipeg"},
-	"437": {City: "Toronto", State: "ON", Lat: 43.6532, Lon: -79.3832, Timezone: "America/Toronto"},
-	"438": {City: "Montreal", State: "QC", Lat: 45.5017, Lon: -73.5673, Timezone: "America/Toronto"},
-	"450": {City: "Laval", State: "QC", Lat: 45.5569, Lon: -73.7103, Timezone: "America/Toronto"},
-	"506": {City: "Fredericton", State: "NB", Lat: 45.9636, Lon: -66.6431, Timezone: "America/Halifax"},
-	"514": {City: "Montreal", State: "QC", Lat: 45.5017, Lon: -73.5673, Timezone: "America/Toronto"},
-	"519": {City: "London", State: "ON", Lat: 42.9849, Lon: -81.2453, Timezone: "America/Toronto"},
-	"548": {City: "London", State: "ON", Lat: 42.9849, Lon: -81.2453, Timezone: "America/Toronto"},
-	"579": {City: "Quebec City", State: "QC", Lat: 46.8139, Lon: -71.2080, Timezone: "America/Toronto"},
-	"581": {City: "Quebec City", State: "QC", Lat: 46.8139, Lon: -71.2080, Timezone: "America/Toronto"},
-	"587": {City: "Calgary", State: "AB", Lat: 51.0447, Lon: -114.0719, Timezone: "America/Edmonton"},
-	"604": {City: "Vancouver", State: "BC", Lat: 49.2827, Lon: -123.1207, Timezone: "America/Vancouver"},
-	"613": {City: "Ottawa", State: "ON", Lat: 45.4215, Lon: -75.6972, Timezone: "America/Toronto"},
-	"639": {City: "Regina", State: "SK", Lat: 50.4452, Lon: -104.6189, Timezone: "America/Regina"},
-	"647": {City: "Toronto", State: "ON", Lat: 43.6532, Lon: -79.3832, Timezone: "America/Toronto"},
-	"672": {City: "Vancouver", State: "BC", Lat: 49.2827, Lon: -123.1207, Timezone: "America/Vancouver"},
-	"705": {City: "Sudbury", State: "ON", Lat: 46.4917, Lon: -80.9930, Timezone: "America/Toronto"},
-	"709": {City: "St. John's", State: "NL", Lat: 47.5615, Lon: -52.7126, Timezone: "America/St_Johns"},
-	"778": {City: "Vancouver", State: "BC", Lat: 49.2827, Lon: -123.1207, Timezone: "America/Vancouver"},
-	"780": {City: "Edmonton", State: "AB", Lat: 53.5461, Lon: -113.4938, Timezone: "America/Edmonton"},
-	"782": {City: "Halifax", State: "NS", Lat: 44.6488, Lon: -63.5752, Timezone: "America/Halifax"},
-	"807": {City: "Thunder Bay", State: "ON", Lat: 48.3809, Lon: -89.2477, Timezone: "America/Toronto"},
-	"819": {City: "Sherbrooke", State: "QC", Lat: 45.4010, Lon: -71.8824, Timezone: "America/Toronto"},
-	"825": {City: "Calgary", State: "AB", Lat: 51.0447, Lon: -114.0719, Timezone: "America/Edmonton"},
-	"867": {City: "Yellowknife", State: "NT", Lat: 62.4540, Lon: -114.3718, Timezone: "America/Edmonton"},
-	"873": {City: "Sherbrooke", State: "QC", Lat: 45.4010, Lon: -71.8824, Timezone: "America/Toronto"},
-	"902": {City: "Halifax", State: "NS", Lat: 44.6488, Lon: -63.5752, Timezone: "America/Halifax"},
-	"905": {City: "Mississauga", State: "ON", Lat: 43.5890, Lon: -79.6441, Timezone: "America/Toronto"},
+// addAreaCode validates a record and adds it to codes
+func addAreaCode(codes map[string]Location, record AreaCodeRecord) error {
+	if len(record.AreaCode) != 3 || nonDigits.MatchString(record.AreaCode) {
+		return fmt.Errorf("invalid area code %q", record.AreaCode)
+	}
+	if _, exists := codes[record.AreaCode]; exists {
+		return fmt.Errorf("duplicate area code %s", record.AreaCode)
+	}
+	if record.City == "" || record.State == "" || record.Timezone == "" {
+		return fmt.Errorf("area code %s needs a city, state and timezone", record.AreaCode)
+	}
 
-	// US Territories
-	"787": {City: "San Juan", State: "PR", Lat: 18.4655, Lon: -66.1057, Timezone: "America/Puerto_Rico"},
-	"939": {City: "San Juan", State: "PR", Lat: 18.4655, Lon: -66.1057, Timezone: "America/Puerto_Rico"},
-	"340": {City: "St. Thomas", State: "VI", Lat: 18.3381, Lon: -64.8941, Timezone: "America/St_Thomas"},
-	"670": {City: "Saipan", State: "MP", Lat: 15.1784, Lon: 145.7509, Timezone: "Pacific/Saipan"},
-	"671": {City: "Guam", State: "GU", Lat: 13.4443, Lon: 144.7937, Timezone: "Pacific/Guam"},
-	"684": {City: "Pago Pago", State: "AS", Lat: -14.2756, Lon: -170.7020, Timezone: "Pacific/Pago_Pago"},
+	codes[record.AreaCode] = Location{
+		City:     record.City,
+		State:    strings.ToUpper(record.State),
+		Lat:      record.Lat,
+		Lon:      record.Lon,
+		Timezone: record.Timezone,
+	}
+	return nil
 }
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmbeddedAreaCodes(t *testing.T) {
+	db := NewAreaCodeDatabase()
+
+	location, exists := db.Lookup("212")
+	if !exists || location.City != "New York" || location.Timezone != "America/New_York" {
+		t.Errorf("Lookup(212) = %+v, %v", location, exists)
+	}
+	if info := db.Info(); info.Source != "embedded" || info.Count < 300 {
+		t.Errorf("Info() = %+v", info)
+	}
+}
+
+func TestAreaCodeDatabaseLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	db := NewAreaCodeDatabase()
+
+	csvPath := write("codes.csv", `# overlay codes
+area_code,city,state,lat,lon,timezone
+212,New York,NY,40.7128,-74.0060,America/New_York
+"332","New York, Manhattan",ny,40.7128,-74.0060,America/New_York
+`)
+	if err := db.Load(csvPath); err != nil {
+		t.Fatalf("Load(csv): %v", err)
+	}
+	if location, exists := db.Lookup("332"); !exists || location.City != "New York, Manhattan" || location.State != "NY" {
+		t.Errorf("Lookup(332) = %+v, %v", location, exists)
+	}
+	if db.Info().Count != 2 {
+		t.Errorf("Count = %d, want 2", db.Info().Count)
+	}
+
+	jsonPath := write("codes.json", `[{"area_code": "907", "city": "Anchorage", "state": "AK", "lat": 61.2181, "lon": -149.9003, "timezone": "America/Anchorage"}]`)
+	if err := db.Load(jsonPath); err != nil {
+		t.Fatalf("Load(json): %v", err)
+	}
+	if _, exists := db.Lookup("907"); !exists {
+		t.Error("907 missing after JSON load")
+	}
+
+	for name, content := range map[string]string{
+		"no_header.csv": "212,New York,NY,40.7,-74.0,America/New_York\n",
+		"bad_code.csv":  "area_code,city,state,lat,lon,timezone\n21,New York,NY,40.7,-74.0,America/New_York\n",
+		"bad_lat.csv":   "area_code,city,state,lat,lon,timezone\n212,New York,NY,north,-74.0,America/New_York\n",
+		"duplicate.csv": "area_code,city,state,lat,lon,timezone\n212,New York,NY,40.7,-74.0,America/New_York\n212,Manhattan,NY,40.7,-74.0,America/New_York\n",
+		"empty.csv":     "area_code,city,state,lat,lon,timezone\n",
+	} {
+		if err := db.Load(write(name, content)); err == nil {
+			t.Errorf("Load(%s) succeeded, want error", name)
+		}
+	}
+	if err := db.Load(filepath.Join(dir, "missing.csv")); err == nil {
+		t.Error("Load(missing.csv) succeeded, want error")
+	}
+
+	// Failed loads keep the last good data
+	if info := db.Info(); info.Source != jsonPath || info.Count != 1 {
+		t.Errorf("Info() after failed loads = %+v", info)
+	}
+}
+
+func TestAreaCodeResolveGuessesFromPrefix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "codes.csv")
+	content := `area_code,city,state,lat,lon,timezone
+212,New York,NY,40.7128,-74.0060,America/New_York
+646,New York,NY,40.7128,-74.0060,America/New_York
+315,Syracuse,NY,43.0481,-76.1474,America/New_York
+318,Shreveport,LA,32.5252,-93.7502,America/Chicago
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db := NewAreaCodeDatabase()
+	if err := db.Load(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		areaCode    string
+		match       AreaCodeMatch
+		city        string
+		guessedFrom string
+	}{
+		{"315", AreaCodeExact, "Syracuse", ""},
+		{"316", AreaCodeGuessed, "New York", "315"}, // ties go to the lower code, widened to NY's principal city
+		{"317", AreaCodeGuessed, "Shreveport", "318"},
+		{"310", AreaCodeGuessed, "New York", "315"},
+		{"320", AreaCodeUnknown, "", ""}, // no known code in 320-329
+		{"abc", AreaCodeUnknown, "", ""},
+	}
+	for _, tt := range tests {
+		location, match, guessedFrom := db.Resolve(tt.areaCode)
+		if match != tt.match || location.City != tt.city || guessedFrom != tt.guessedFrom {
+			t.Errorf("Resolve(%s) = %s, %q, %q; want %s, %q, %q",
+				tt.areaCode, location.City, match, guessedFrom, tt.city, tt.match, tt.guessedFrom)
+		}
+	}
+
+	db.RecordUnknown("316", "315")
+	db.RecordUnknown("316", "315")
+	db.RecordUnknown("320", "")
+	unknown := db.UnknownCodes()
+	if len(unknown) != 2 || unknown[0].AreaCode != "316" || unknown[0].Calls != 2 {
+		t.Errorf("UnknownCodes() = %+v", unknown)
+	}
+}
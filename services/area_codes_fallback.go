@@ -0,0 +1,149 @@
+// services/area_codes_fallback.go
+// Best-guess locations for area codes missing from the database
+
+package services
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// AreaCodeMatch says how a location was found for an area code
+type AreaCodeMatch string
+
+const (
+	AreaCodeExact   AreaCodeMatch = "exact"   // the area code is in the database
+	AreaCodeGuessed AreaCodeMatch = "guessed" // state default of the nearest known code with the same prefix
+	AreaCodeUnknown AreaCodeMatch = ""        // no guess could be made
+)
+
+// UnknownAreaCode records callers from an area code missing from the database
+type UnknownAreaCode struct {
+	AreaCode    string    `json:"area_code"`
+	Calls       int       `json:"calls"`
+	GuessedFrom string    `json:"guessed_from,omitempty"` // known code the location was guessed from
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// Resolve returns the location for an area code, falling back to a guess when it isn't in
+// the database. The guess is the nearest known code sharing the first two digits (overlays
+// and splits are often numbered close to their parent), widened to its state's principal city
+// since the neighbor's own city is likely wrong. guessedFrom is the neighbor's code.
+func (db *AreaCodeDatabase) Resolve(areaCode string) (location Location, match AreaCodeMatch, guessedFrom string) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if location, exists := db.codes[areaCode]; exists {
+		return location, AreaCodeExact, ""
+	}
+
+	code, err := strconv.Atoi(areaCode)
+	if err != nil || len(areaCode) != 3 {
+		return Location{}, AreaCodeUnknown, ""
+	}
+
+	// Search outward from the code within its prefix (e.g. 210-219 for 213), lower code first
+	base := code / 10 * 10
+	for distance := 1; distance < 10; distance++ {
+		for _, candidate := range []int{code - distance, code + distance} {
+			if candidate < base || candidate >= base+10 {
+				continue
+			}
+			neighbor := strconv.Itoa(candidate)
+			if location, exists := db.codes[neighbor]; exists {
+				if stateDefault, exists := db.stateDefaults[location.State]; exists {
+					location = stateDefault
+				}
+				return location, AreaCodeGuessed, neighbor
+			}
+		}
+	}
+
+	return Location{}, AreaCodeUnknown, ""
+}
+
+// RecordUnknown counts a call from an area code missing from the database, logging it
+// the first time it is seen so the data file can be updated
+func (db *AreaCodeDatabase) RecordUnknown(areaCode, guessedFrom string) {
+	db.unknownMu.Lock()
+	defer db.unknownMu.Unlock()
+
+	now := time.Now()
+	entry, exists := db.unknown[areaCode]
+	if !exists {
+		if guessedFrom != "" {
+			log.Printf("[WR] Unknown area code %s, guessing location from %s; add it to the area codes file", areaCode, guessedFrom)
+		} else {
+			log.Printf("[WR] Unknown area code %s with no nearby code to guess from; add it to the area codes file", areaCode)
+		}
+		entry = &UnknownAreaCode{AreaCode: areaCode, FirstSeen: now}
+		db.unknown[areaCode] = entry
+	}
+
+	entry.Calls++
+	entry.GuessedFrom = guessedFrom
+	entry.LastSeen = now
+}
+
+// UnknownCodes lists area codes callers have used that are still missing from the database,
+// most calls first
+func (db *AreaCodeDatabase) UnknownCodes() []UnknownAreaCode {
+	db.unknownMu.Lock()
+	defer db.unknownMu.Unlock()
+
+	unknown := make([]UnknownAreaCode, 0, len(db.unknown))
+	for code, entry := range db.unknown {
+		if _, known := db.Lookup(code); known {
+			continue
+		}
+		unknown = append(unknown, *entry)
+	}
+
+	sort.Slice(unknown, func(i, j int) bool {
+		if unknown[i].Calls != unknown[j].Calls {
+			return unknown[i].Calls > unknown[j].Calls
+		}
+		return unknown[i].AreaCode < unknown[j].AreaCode
+	})
+	return unknown
+}
+
+// stateDefaults picks each state's principal city: the one with the most area codes,
+// using the lowest code's location (ties go to the city with the lowest code)
+func stateDefaults(codes map[string]Location) map[string]Location {
+	type cityCodes struct {
+		count  int
+		lowest string
+	}
+
+	cities := make(map[string]map[string]*cityCodes) // state -> city -> codes
+	for code, location := range codes {
+		if cities[location.State] == nil {
+			cities[location.State] = make(map[string]*cityCodes)
+		}
+		city := cities[location.State][location.City]
+		if city == nil {
+			city = &cityCodes{lowest: code}
+			cities[location.State][location.City] = city
+		}
+		city.count++
+		if code < city.lowest {
+			city.lowest = code
+		}
+	}
+
+	defaults := make(map[string]Location, len(cities))
+	for state, stateCities := range cities {
+		var best *cityCodes
+		for _, city := range stateCities {
+			if best == nil || city.count > best.count || (city.count == best.count && city.lowest < best.lowest) {
+				best = city
+			}
+		}
+		defaults[state] = codes[best.lowest]
+	}
+	return defaults
+}
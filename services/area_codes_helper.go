@@ -2,6 +2,8 @@ package services
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 )
 
@@ -13,7 +15,8 @@ func GetAreaCodeStats() map[string]int {
 	canadaCount := 0
 	territoryCount := 0
 
-	for _, location := range CompleteAreaCodes {
+	codes := GlobalAreaCodes.All()
+	for _, location := range codes {
 		switch {
 		case location.State == "PR" || location.State == "VI" ||
 			location.State == "MP" || location.State == "GU" || location.State == "AS":
@@ -29,7 +32,7 @@ func GetAreaCodeStats() map[string]int {
 		}
 	}
 
-	stats["total"] = len(CompleteAreaCodes)
+	stats["total"] = len(codes)
 	stats["us"] = usCount
 	stats["canada"] = canadaCount
 	stats["territories"] = territoryCount
@@ -42,7 +45,7 @@ func GetAreaCodesByState(state string) []string {
 	var codes []string
 	upperState := strings.ToUpper(state)
 
-	for code, location := range CompleteAreaCodes {
+	for code, location := range GlobalAreaCodes.All() {
 		if strings.ToUpper(location.State) == upperState {
 			codes = append(codes, code)
 		}
@@ -56,7 +59,7 @@ func GetAreaCodesByCity(city string) []string {
 	var codes []string
 	lowerCity := strings.ToLower(city)
 
-	for code, location := range CompleteAreaCodes {
+	for code, location := range GlobalAreaCodes.All() {
 		if strings.ToLower(location.City) == lowerCity {
 			codes = append(codes, code)
 		}
@@ -67,41 +70,79 @@ func GetAreaCodesByCity(city string) []string {
 
 // IsValidAreaCode checks if an area code exists in our database
 func IsValidAreaCode(areaCode string) bool {
-	_, exists := CompleteAreaCodes[areaCode]
+	_, exists := GlobalAreaCodes.Lookup(areaCode)
 	return exists
 }
 
-// GetNearbyAreaCodes returns area codes within approximately 100 miles
-func GetNearbyAreaCodes(areaCode string, maxDistance float64) []string {
-	var nearby []string
+// earthRadiusMiles is the mean radius of the Earth
+const earthRadiusMiles = 3958.8
 
-	origin, exists := CompleteAreaCodes[areaCode]
-	if !exists {
-		return nearby
-	}
+// NearbyAreaCode is an area code with its distance from a point
+type NearbyAreaCode struct {
+	AreaCode      string  `json:"area_code"`
+	DistanceMiles float64 `json:"distance_miles"`
+	Location
+}
+
+// HaversineMiles returns the great-circle distance between two points
+func HaversineMiles(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(degrees float64) float64 { return degrees * math.Pi / 180 }
 
-	for code, location := range CompleteAreaCodes {
-		if code == areaCode {
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMiles * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// NearestAreaCodes returns up to limit area codes closest to lat/lon, nearest first.
+// maxDistance (miles) of 0 means no limit; limit of 0 returns every match.
+func NearestAreaCodes(lat, lon float64, limit int, maxDistance float64) []NearbyAreaCode {
+	nearest := []NearbyAreaCode{}
+	for code, location := range GlobalAreaCodes.All() {
+		distance := HaversineMiles(lat, lon, location.Lat, location.Lon)
+		if maxDistance > 0 && distance > maxDistance {
 			continue
 		}
+		nearest = append(nearest, NearbyAreaCode{
+			AreaCode:      code,
+			DistanceMiles: math.Round(distance*10) / 10,
+			Location:      location,
+		})
+	}
 
-		// Simple distance calculation (not perfectly accurate but good enough)
-		latDiff := origin.Lat - location.Lat
-		lonDiff := origin.Lon - location.Lon
-		distance := latDiff*latDiff + lonDiff*lonDiff
-
-		// Rough approximation: 1 degree ≈ 69 miles
-		if distance < (maxDistance/69)*(maxDistance/69) {
-			nearby = append(nearby, code)
+	sort.Slice(nearest, func(i, j int) bool {
+		if nearest[i].DistanceMiles != nearest[j].DistanceMiles {
+			return nearest[i].DistanceMiles < nearest[j].DistanceMiles
 		}
+		return nearest[i].AreaCode < nearest[j].AreaCode
+	})
+
+	if limit > 0 && len(nearest) > limit {
+		nearest = nearest[:limit]
 	}
+	return nearest
+}
 
+// GetNearbyAreaCodes returns the other area codes within maxDistance miles of an area code, nearest first
+func GetNearbyAreaCodes(areaCode string, maxDistance float64) []NearbyAreaCode {
+	origin, exists := GlobalAreaCodes.Lookup(areaCode)
+	if !exists {
+		return []NearbyAreaCode{}
+	}
+
+	nearby := []NearbyAreaCode{}
+	for _, candidate := range NearestAreaCodes(origin.Lat, origin.Lon, 0, maxDistance) {
+		if candidate.AreaCode != areaCode {
+			nearby = append(nearby, candidate)
+		}
+	}
 	return nearby
 }
 
 // GetTimeZoneForAreaCode returns the timezone for a given area code
 func GetTimeZoneForAreaCode(areaCode string) (string, error) {
-	location, exists := CompleteAreaCodes[areaCode]
+	location, exists := GlobalAreaCodes.Lookup(areaCode)
 	if !exists {
 		return "", fmt.Errorf("area code %s not found", areaCode)
 	}
@@ -110,7 +151,7 @@ func GetTimeZoneForAreaCode(areaCode string) (string, error) {
 
 // GetLocationString returns a formatted location string
 func GetLocationString(areaCode string) string {
-	location, exists := CompleteAreaCodes[areaCode]
+	location, exists := GlobalAreaCodes.Lookup(areaCode)
 	if !exists {
 		return "Unknown Location"
 	}
@@ -160,7 +201,7 @@ func GetLocationString(areaCode string) string {
 
 // GetCountryForAreaCode returns the country for a given area code
 func GetCountryForAreaCode(areaCode string) string {
-	location, exists := CompleteAreaCodes[areaCode]
+	location, exists := GlobalAreaCodes.Lookup(areaCode)
 	if !exists {
 		return "Unknown"
 	}
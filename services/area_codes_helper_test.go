@@ -0,0 +1,48 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHaversineMiles(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"same point", 40.7128, -74.0060, 40.7128, -74.0060, 0},
+		{"New York to Los Angeles", 40.7128, -74.0060, 34.0522, -118.2437, 2445},
+		{"Anchorage to Honolulu", 61.2181, -149.9003, 21.3069, -157.8583, 2780},
+	}
+	for _, tt := range tests {
+		got := HaversineMiles(tt.lat1, tt.lon1, tt.lat2, tt.lon2)
+		if math.Abs(got-tt.want) > tt.want*0.01+0.1 {
+			t.Errorf("%s: HaversineMiles = %.1f, want about %.0f", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNearestAreaCodes(t *testing.T) {
+	nearest := NearestAreaCodes(40.7128, -74.0060, 3, 0)
+	if len(nearest) != 3 {
+		t.Fatalf("got %d area codes, want 3", len(nearest))
+	}
+	if nearest[0].AreaCode != "212" || nearest[0].DistanceMiles != 0 {
+		t.Errorf("nearest = %+v, want 212 at 0 miles", nearest[0])
+	}
+	for i := 1; i < len(nearest); i++ {
+		if nearest[i].DistanceMiles < nearest[i-1].DistanceMiles {
+			t.Errorf("results not sorted by distance: %+v", nearest)
+		}
+	}
+
+	for _, nearby := range GetNearbyAreaCodes("212", 50) {
+		if nearby.AreaCode == "212" || nearby.DistanceMiles > 50 {
+			t.Errorf("unexpected nearby area code %+v", nearby)
+		}
+	}
+	if nearby := GetNearbyAreaCodes("999", 50); len(nearby) != 0 {
+		t.Errorf("unknown area code returned %d nearby codes", len(nearby))
+	}
+}
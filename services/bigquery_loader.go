@@ -0,0 +1,380 @@
+// services/bigquery_loader.go
+// BigQuery loader: loads a scheduled search's CDRs into BigQuery tables named by call
+// date with load jobs, authenticating as a service account, so teams on GCP get CDRs
+// without shuffling files through storage
+
+package services
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"o-dan-go/models"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBigQueryTable names a date-sharded table per call date
+const DefaultBigQueryTable = "cdrs_{yyyy}{MM}{dd}"
+
+// bigQueryScope is the OAuth scope load jobs need
+const bigQueryScope = "https://www.googleapis.com/auth/bigquery"
+
+// bigQueryTimeLayout is how TIMESTAMP columns are sent
+const bigQueryTimeLayout = "2006-01-02T15:04:05.000Z"
+
+// bigQueryJobTimeout bounds how long a load job is waited for
+const bigQueryJobTimeout = 5 * time.Minute
+
+// bigQueryName matches dataset and table IDs the loader accepts
+var bigQueryName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// bigQueryJobIDChars are replaced in job IDs
+var bigQueryJobIDChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// bigQuerySchema is the column layout of loaded tables, matching warehouseRow
+var bigQuerySchema = []map[string]string{
+	{"name": "session_id", "type": "STRING", "mode": "REQUIRED"},
+	{"name": "cdr_id", "type": "STRING", "mode": "REQUIRED"},
+	{"name": "domain", "type": "STRING"},
+	{"name": "direction", "type": "STRING"},
+	{"name": "start_time", "type": "TIMESTAMP", "mode": "REQUIRED"},
+	{"name": "answer_time", "type": "TIMESTAMP"},
+	{"name": "end_time", "type": "TIMESTAMP"},
+	{"name": "duration_seconds", "type": "INTEGER"},
+	{"name": "talk_seconds", "type": "INTEGER"},
+	{"name": "orig_number", "type": "STRING"},
+	{"name": "term_number", "type": "STRING"},
+	{"name": "orig_user", "type": "STRING"},
+	{"name": "term_user", "type": "STRING"},
+	{"name": "disposition", "type": "STRING"},
+	{"name": "call_class", "type": "STRING"},
+	{"name": "raw", "type": "STRING"},
+}
+
+// BigQuerySettings configures the loader; it is disabled without credentials
+type BigQuerySettings struct {
+	Credentials   string // service account key: a file path or the JSON itself
+	Project       string // defaults to the service account's project
+	Dataset       string
+	TableTemplate string // table ID with {yyyy}, {MM}, {dd} and {domain} placeholders
+	Location      string // dataset location, e.g. US or europe-west2
+	APIURL        string // defaults to https://bigquery.googleapis.com
+}
+
+// serviceAccountKey is the part of a service account key file the loader uses
+type serviceAccountKey struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// BigQueryLoader loads CDRs into BigQuery as a scheduled export destination
+type BigQueryLoader struct {
+	settings BigQuerySettings
+	account  serviceAccountKey
+	key      *rsa.PrivateKey
+	client   *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewBigQueryLoader creates the loader, reading the service account key; it returns a
+// disabled loader when no credentials are configured
+func NewBigQueryLoader(settings BigQuerySettings) (*BigQueryLoader, error) {
+	loader := &BigQueryLoader{settings: settings, client: &http.Client{Timeout: 2 * time.Minute}}
+	if settings.Credentials == "" {
+		return loader, nil
+	}
+
+	keyJSON := []byte(settings.Credentials)
+	if !strings.HasPrefix(strings.TrimSpace(settings.Credentials), "{") {
+		var err error
+		if keyJSON, err = os.ReadFile(settings.Credentials); err != nil {
+			return nil, fmt.Errorf("failed to read service account key: %w", err)
+		}
+	}
+	if err := json.Unmarshal(keyJSON, &loader.account); err != nil {
+		return nil, fmt.Errorf("invalid service account key: %w", err)
+	}
+	key, err := parseRSAPrivateKey(loader.account.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid service account key: %w", err)
+	}
+	loader.key = key
+	if loader.account.TokenURI == "" {
+		loader.account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	if loader.settings.Project == "" {
+		loader.settings.Project = loader.account.ProjectID
+	}
+	if loader.settings.TableTemplate == "" {
+		loader.settings.TableTemplate = DefaultBigQueryTable
+	}
+	if loader.settings.APIURL == "" {
+		loader.settings.APIURL = "https://bigquery.googleapis.com"
+	}
+	loader.settings.APIURL = strings.TrimRight(loader.settings.APIURL, "/")
+
+	if loader.settings.Project == "" {
+		return nil, fmt.Errorf("BigQuery project is required")
+	}
+	sample := models.FlexibleCDR{RawData: map[string]interface{}{"domain": "example.com"}}
+	for _, name := range []string{settings.Dataset, BigQueryTableName(loader.settings.TableTemplate, &sample, time.Now())} {
+		if !bigQueryName.MatchString(name) || len(name) > 1024 {
+			return nil, fmt.Errorf("invalid BigQuery dataset or table %q: use letters, digits and underscores", name)
+		}
+	}
+	return loader, nil
+}
+
+// BigQueryTableName fills a table template from a CDR like IndexName, with the domain's
+// dots and dashes made underscores
+func BigQueryTableName(template string, cdr *models.FlexibleCDR, fallback time.Time) string {
+	return strings.NewReplacer(".", "_", "-", "_").Replace(IndexName(template, cdr, fallback))
+}
+
+// Name is the destination scheduled searches name in their exports
+func (bl *BigQueryLoader) Name() string {
+	return ScheduledExportBigQuery
+}
+
+// Enabled reports whether a service account is configured
+func (bl *BigQueryLoader) Enabled() bool {
+	return bl != nil && bl.key != nil
+}
+
+// Export loads a search's CDRs, one load job per table, appending to tables that exist
+// and creating the rest
+func (bl *BigQueryLoader) Export(result *CDRDiscoveryResult) error {
+	tables := map[string]*bytes.Buffer{}
+	for i := range result.AllCDRs {
+		table := BigQueryTableName(bl.settings.TableTemplate, &result.AllCDRs[i], result.StartTime)
+		row, err := newWarehouseRow(result.SessionID, result.AllCDRs[i], result.StartTime, bigQueryTimeLayout)
+		if err != nil {
+			return fmt.Errorf("failed to encode CDR %s: %w", result.AllCDRs[i].GetID(), err)
+		}
+		if tables[table] == nil {
+			tables[table] = &bytes.Buffer{}
+		}
+		if err := json.NewEncoder(tables[table]).Encode(row); err != nil {
+			return fmt.Errorf("failed to encode CDR %s: %w", result.AllCDRs[i].GetID(), err)
+		}
+	}
+
+	names := make([]string, 0, len(tables))
+	for table := range tables {
+		names = append(names, table)
+	}
+	sort.Strings(names)
+	for _, table := range names {
+		if err := bl.load(result.SessionID, table, tables[table]); err != nil {
+			return fmt.Errorf("failed to load %s.%s: %w", bl.settings.Dataset, table, err)
+		}
+	}
+	log.Printf("[BigQuery] Loaded %d CDRs of %s into %d tables of %s", len(result.AllCDRs), result.SessionID, len(names), bl.settings.Dataset)
+	return nil
+}
+
+// load runs one load job of newline-delimited JSON rows and waits for it to finish
+func (bl *BigQueryLoader) load(sessionID, table string, rows *bytes.Buffer) error {
+	jobID := fmt.Sprintf("odango_%s_%s_%d", bigQueryJobIDChars.ReplaceAllString(sessionID, "_"), table, time.Now().UnixNano())
+	reference := map[string]string{"projectId": bl.settings.Project, "jobId": jobID}
+	if bl.settings.Location != "" {
+		reference["location"] = bl.settings.Location
+	}
+	metadata, err := json.Marshal(map[string]interface{}{
+		"jobReference": reference,
+		"configuration": map[string]interface{}{
+			"load": map[string]interface{}{
+				"destinationTable":  map[string]string{"projectId": bl.settings.Project, "datasetId": bl.settings.Dataset, "tableId": table},
+				"sourceFormat":      "NEWLINE_DELIMITED_JSON",
+				"writeDisposition":  "WRITE_APPEND",
+				"createDisposition": "CREATE_IF_NEEDED",
+				"schema":            map[string]interface{}{"fields": bigQuerySchema},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		data        []byte
+	}{{"application/json; charset=UTF-8", metadata}, {"application/octet-stream", rows.Bytes()}} {
+		w, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return err
+		}
+		w.Write(part.data)
+	}
+	writer.Close()
+
+	var job bigQueryJob
+	uploadURL := fmt.Sprintf("%s/upload/bigquery/v2/projects/%s/jobs?uploadType=multipart", bl.settings.APIURL, url.PathEscape(bl.settings.Project))
+	if err := bl.call("POST", uploadURL, "multipart/related; boundary="+writer.Boundary(), &body, &job); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(bigQueryJobTimeout)
+	for job.Status.State != "DONE" {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("load job %s didn't finish in %s", jobID, bigQueryJobTimeout)
+		}
+		time.Sleep(2 * time.Second)
+		jobURL := fmt.Sprintf("%s/bigquery/v2/projects/%s/jobs/%s?location=%s", bl.settings.APIURL,
+			url.PathEscape(bl.settings.Project), url.PathEscape(jobID), url.QueryEscape(bl.settings.Location))
+		if err := bl.call("GET", jobURL, "", nil, &job); err != nil {
+			return err
+		}
+	}
+	if job.Status.ErrorResult != nil {
+		return fmt.Errorf("load job %s failed: %s", jobID, job.Status.ErrorResult.Message)
+	}
+	return nil
+}
+
+// bigQueryJob is the part of a job resource the loader reads
+type bigQueryJob struct {
+	Status struct {
+		State       string `json:"state"`
+		ErrorResult *struct {
+			Message string `json:"message"`
+		} `json:"errorResult"`
+	} `json:"status"`
+}
+
+// call sends an authenticated API request and decodes the JSON response into out
+func (bl *BigQueryLoader) call(method, endpoint, contentType string, body io.Reader, out interface{}) error {
+	token, err := bl.accessToken()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := bl.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("BigQuery request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("BigQuery returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// accessToken returns an OAuth token for the service account, exchanging a signed JWT
+// for a new one when the last is about to expire
+func (bl *BigQueryLoader) accessToken() (string, error) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+
+	if bl.token != "" && time.Now().Before(bl.tokenExpiry.Add(-time.Minute)) {
+		return bl.token, nil
+	}
+
+	now := time.Now()
+	assertion, err := signJWT(bl.key, map[string]interface{}{
+		"iss":   bl.account.ClientEmail,
+		"scope": bigQueryScope,
+		"aud":   bl.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+
+	resp, err := bl.client.PostForm(bl.account.TokenURI, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("token request returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("invalid token response: %v", err)
+	}
+	bl.token = token.AccessToken
+	bl.tokenExpiry = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	return bl.token, nil
+}
+
+// signJWT makes an RS256 JSON web token of claims
+func signJWT(key *rsa.PrivateKey, claims map[string]interface{}) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parseRSAPrivateKey reads a PEM private key in PKCS #8 or PKCS #1 form
+func parseRSAPrivateKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, fmt.Errorf("private_key is not PEM")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private_key is not an RSA key")
+	}
+	return key, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestBigQueryLoader(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: mustPKCS8(t, key)})
+
+	tokens := 0
+	loads := map[string]int{} // table: rows
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		tokens++
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || strings.Count(r.FormValue("assertion"), ".") != 2 {
+			t.Errorf("token request = %v", r.Form)
+		}
+		w.Write([]byte(`{"access_token":"tok","expires_in":3600}`))
+	})
+	mux.HandleFunc("/upload/bigquery/v2/projects/acme-analytics/jobs", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("authorization = %q", r.Header.Get("Authorization"))
+		}
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		metadataPart, _ := reader.NextPart()
+		var metadata struct {
+			Configuration struct {
+				Load struct {
+					DestinationTable struct {
+						DatasetID string `json:"datasetId"`
+						TableID   string `json:"tableId"`
+					} `json:"destinationTable"`
+					SourceFormat string `json:"sourceFormat"`
+				} `json:"load"`
+			} `json:"configuration"`
+		}
+		json.NewDecoder(metadataPart).Decode(&metadata)
+		dataPart, _ := reader.NextPart()
+		data, _ := io.ReadAll(dataPart)
+		load := metadata.Configuration.Load
+		if load.DestinationTable.DatasetID != "calls" || load.SourceFormat != "NEWLINE_DELIMITED_JSON" {
+			t.Errorf("load = %+v", load)
+		}
+		loads[load.DestinationTable.TableID] += bytes.Count(data, []byte("\n"))
+		w.Write([]byte(`{"status":{"state":"DONE"}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	credentials, _ := json.Marshal(map[string]string{
+		"project_id": "acme-analytics", "client_email": "loader@acme-analytics.iam.gserviceaccount.com",
+		"private_key": string(keyPEM), "token_uri": server.URL + "/token",
+	})
+	loader, err := NewBigQueryLoader(BigQuerySettings{Credentials: string(credentials), Dataset: "calls", TableTemplate: "cdrs_{domain}_{yyyy}{MM}", APIURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewBigQueryLoader: %v", err)
+	}
+
+	result := &CDRDiscoveryResult{
+		SessionID: "s1",
+		StartTime: time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC),
+		AllCDRs: []models.FlexibleCDR{
+			{RawData: map[string]interface{}{"id": "a", "domain": "acme.example.com", "call-start-datetime": "2026-10-12T10:00:00Z"}},
+			{RawData: map[string]interface{}{"id": "b", "domain": "acme.example.com", "call-start-datetime": "2026-10-30T10:00:00Z"}},
+			{RawData: map[string]interface{}{"id": "c", "domain": "acme.example.com"}},
+		},
+	}
+	if err := loader.Export(result); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if err := loader.Export(result); err != nil {
+		t.Fatalf("second Export: %v", err)
+	}
+	if tokens != 1 {
+		t.Errorf("requested %d tokens, want the first reused", tokens)
+	}
+	if len(loads) != 2 || loads["cdrs_acme_example_com_202610"] != 4 || loads["cdrs_acme_example_com_202611"] != 2 {
+		t.Errorf("loaded %v, want October's and November's tables", loads)
+	}
+
+	if loader, err := NewBigQueryLoader(BigQuerySettings{}); err != nil || loader.Enabled() {
+		t.Errorf("NewBigQueryLoader without credentials = %v, %v; want a disabled loader", loader.Enabled(), err)
+	}
+}
+
+func mustPKCS8(t *testing.T, key *rsa.PrivateKey) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	return der
+}
@@ -0,0 +1,504 @@
+// services/billing_reconciliation.go
+// Billing reconciliation: imports carrier invoices from CSV and matches each invoice
+// line to a CDR by number, start time and duration, reporting calls the carrier didn't
+// bill and calls it billed without a CDR or for longer than they lasted
+
+package services
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"o-dan-go/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of billing discrepancy
+const (
+	BillingUnbilled    = "unbilled"           // a call in the CDRs with no invoice line
+	BillingWithoutCDR  = "billed_without_cdr" // an invoice line with no call in the CDRs
+	BillingOverBilled  = "over_billed"        // billed for longer than the call lasted
+	BillingUnderBilled = "under_billed"       // billed for less time than the call lasted
+)
+
+// maxInvoiceImportRows bounds how many lines one invoice import reads
+const maxInvoiceImportRows = 500000
+
+// invoiceColumnAliases are the header names carriers use for each invoice column
+var invoiceColumnAliases = map[string][]string{
+	"number":         {"number", "to", "destination", "called_number", "dialed_number", "dnis", "to_number"},
+	"calling_number": {"from", "calling_number", "caller", "ani", "source", "from_number", "origination"},
+	"start":          {"start", "start_time", "call_start", "date", "call_date", "datetime", "timestamp", "connect_time"},
+	"seconds":        {"seconds", "duration", "duration_seconds", "billed_seconds", "billable_seconds", "billed_duration"},
+	"minutes":        {"minutes", "billed_minutes", "billable_minutes", "duration_minutes"},
+	"amount":         {"amount", "cost", "charge", "charges", "price", "total"},
+}
+
+// invoiceTimeLayouts are the start time formats accepted in invoices
+var invoiceTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"01/02/2006 15:04:05",
+	"01/02/2006 15:04",
+	"1/2/2006 15:04:05",
+	"1/2/2006 15:04",
+}
+
+// BillingSettings configures how closely an invoice line must match a CDR
+type BillingSettings struct {
+	TimeTolerance     time.Duration // how far apart the billed and recorded start times may be
+	DurationTolerance time.Duration // billed time beyond the call's duration that isn't a discrepancy, e.g. rounding up to the minute
+	IncludeInbound    bool          // expect inbound calls on invoices too, e.g. for toll-free numbers
+}
+
+// Invoice is an imported carrier invoice
+type Invoice struct {
+	ID          int64     `json:"id"`
+	Carrier     string    `json:"carrier"`
+	Filename    string    `json:"filename,omitempty"`
+	PeriodStart time.Time `json:"period_start"` // first billed call
+	PeriodEnd   time.Time `json:"period_end"`   // last billed call
+	Lines       int       `json:"lines"`
+	TotalAmount float64   `json:"total_amount"`
+	ImportedAt  time.Time `json:"imported_at"`
+}
+
+// InvoiceLine is one billed call
+type InvoiceLine struct {
+	Line          int       `json:"line"` // row in the CSV, counting the header
+	Number        string    `json:"number"`
+	CallingNumber string    `json:"calling_number,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	BilledSeconds int       `json:"billed_seconds"`
+	Amount        float64   `json:"amount"`
+}
+
+// BillingDiscrepancy is one call the invoice and the CDRs disagree on
+type BillingDiscrepancy struct {
+	Type          string       `json:"type"`
+	Line          *InvoiceLine `json:"line,omitempty"`
+	CDRID         string       `json:"cdr_id,omitempty"`
+	CDRStart      *time.Time   `json:"cdr_start,omitempty"`
+	CDRSeconds    int          `json:"cdr_seconds"`
+	BilledSeconds int          `json:"billed_seconds"`
+	Difference    int          `json:"difference_seconds"` // billed minus recorded
+	Amount        float64      `json:"amount"`             // the amount in dispute
+}
+
+// ReconciliationReport compares an invoice with the CDRs of a search
+type ReconciliationReport struct {
+	Invoice        Invoice              `json:"invoice"`
+	SessionID      string               `json:"session_id"`
+	BillableCDRs   int                  `json:"billable_cdrs"` // answered calls with outside numbers in the invoice period
+	Matched        int                  `json:"matched"`
+	Counts         map[string]int       `json:"counts"`
+	DisputedAmount float64              `json:"disputed_amount"`
+	Discrepancies  []BillingDiscrepancy `json:"discrepancies"`
+	GeneratedAt    time.Time            `json:"generated_at"`
+}
+
+// BillingReconciler imports invoices and reconciles them with CDRs
+type BillingReconciler struct {
+	db       *DatabaseService
+	settings BillingSettings
+}
+
+// NewBillingReconciler creates a billing reconciler
+func NewBillingReconciler(db *DatabaseService, settings BillingSettings) *BillingReconciler {
+	return &BillingReconciler{
+		db:       db,
+		settings: settings,
+	}
+}
+
+// ImportInvoice reads an invoice CSV and stores it. The header row names the columns;
+// start times without a UTC offset are read in loc. Rows that can't be read are skipped
+// and described in the returned list.
+func (br *BillingReconciler) ImportInvoice(carrier, filename string, r io.Reader, loc *time.Location) (*Invoice, []string, error) {
+	lines, skipped, err := parseInvoiceCSV(r, loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(lines) == 0 {
+		return nil, skipped, fmt.Errorf("no invoice lines could be read")
+	}
+
+	invoice := &Invoice{Carrier: carrier, Filename: filename, Lines: len(lines), ImportedAt: time.Now().UTC()}
+	for i, line := range lines {
+		if i == 0 || line.StartedAt.Before(invoice.PeriodStart) {
+			invoice.PeriodStart = line.StartedAt
+		}
+		if line.StartedAt.After(invoice.PeriodEnd) {
+			invoice.PeriodEnd = line.StartedAt
+		}
+		invoice.TotalAmount += line.Amount
+	}
+	invoice.TotalAmount = roundTo(invoice.TotalAmount, 4)
+
+	if err := br.db.SaveInvoice(invoice, lines); err != nil {
+		return nil, nil, err
+	}
+	return invoice, skipped, nil
+}
+
+// Reconcile matches an invoice's lines with cdrs. Each line is matched to the unmatched
+// CDR with the same number that started closest to it, within the time tolerance.
+// Returns nil if the invoice doesn't exist.
+func (br *BillingReconciler) Reconcile(invoiceID int64, sessionID string, cdrs []models.FlexibleCDR) (*ReconciliationReport, error) {
+	invoice, err := br.db.GetInvoice(invoiceID)
+	if err != nil || invoice == nil {
+		return nil, err
+	}
+	lines, err := br.db.GetInvoiceLines(invoiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	type billableCDR struct {
+		id      string
+		started time.Time
+		seconds int
+		matched bool
+	}
+	from := invoice.PeriodStart.Add(-br.settings.TimeTolerance)
+	to := invoice.PeriodEnd.Add(br.settings.TimeTolerance)
+	billable := []*billableCDR{}
+	byNumber := map[string][]*billableCDR{}
+	for _, cdr := range cdrs {
+		started, err := cdr.GetCallStartTime()
+		answered, seconds := callAnswered(cdr)
+		if err != nil || !answered || started.Before(from) || started.After(to) {
+			continue
+		}
+		if cdr.GetCallDirection() == 1 && !br.settings.IncludeInbound {
+			continue
+		}
+		numbers := cdrBillingNumbers(&cdr)
+		if len(numbers) == 0 {
+			continue // internal call between extensions
+		}
+		call := &billableCDR{id: cdr.GetID(), started: started, seconds: seconds}
+		billable = append(billable, call)
+		for _, number := range numbers {
+			byNumber[number] = append(byNumber[number], call)
+		}
+	}
+
+	report := &ReconciliationReport{
+		Invoice:       *invoice,
+		SessionID:     sessionID,
+		BillableCDRs:  len(billable),
+		Counts:        map[string]int{},
+		Discrepancies: []BillingDiscrepancy{},
+		GeneratedAt:   time.Now().UTC(),
+	}
+	add := func(discrepancy BillingDiscrepancy) {
+		discrepancy.Amount = roundTo(discrepancy.Amount, 4)
+		report.Discrepancies = append(report.Discrepancies, discrepancy)
+		report.Counts[discrepancy.Type]++
+		if discrepancy.Type != BillingUnbilled && discrepancy.Type != BillingUnderBilled {
+			report.DisputedAmount += discrepancy.Amount
+		}
+	}
+
+	tolerance := int(br.settings.DurationTolerance.Seconds())
+	for i := range lines {
+		line := &lines[i]
+		var best *billableCDR
+		for _, number := range billingNumberKeys(line.Number, line.CallingNumber) {
+			for _, call := range byNumber[number] {
+				if call.matched || absDuration(call.started.Sub(line.StartedAt)) > br.settings.TimeTolerance {
+					continue
+				}
+				if best == nil || absDuration(call.started.Sub(line.StartedAt)) < absDuration(best.started.Sub(line.StartedAt)) {
+					best = call
+				}
+			}
+		}
+		if best == nil {
+			add(BillingDiscrepancy{Type: BillingWithoutCDR, Line: line, BilledSeconds: line.BilledSeconds,
+				Difference: line.BilledSeconds, Amount: line.Amount})
+			continue
+		}
+
+		best.matched = true
+		report.Matched++
+		difference := line.BilledSeconds - best.seconds
+		discrepancy := BillingDiscrepancy{Line: line, CDRID: best.id, CDRStart: &best.started,
+			CDRSeconds: best.seconds, BilledSeconds: line.BilledSeconds, Difference: difference}
+		switch {
+		case difference > tolerance:
+			discrepancy.Type = BillingOverBilled
+			if line.BilledSeconds > 0 {
+				discrepancy.Amount = line.Amount * float64(difference) / float64(line.BilledSeconds)
+			}
+			add(discrepancy)
+		case difference < -tolerance:
+			discrepancy.Type = BillingUnderBilled
+			add(discrepancy)
+		}
+	}
+
+	for _, call := range billable {
+		if !call.matched {
+			started := call.started
+			add(BillingDiscrepancy{Type: BillingUnbilled, CDRID: call.id, CDRStart: &started,
+				CDRSeconds: call.seconds, Difference: -call.seconds})
+		}
+	}
+	report.DisputedAmount = roundTo(report.DisputedAmount, 4)
+	return report, nil
+}
+
+// parseInvoiceCSV reads invoice lines from a CSV with a header row
+func parseInvoiceCSV(r io.Reader, loc *time.Location) ([]InvoiceLine, []string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read invoice header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+		for column, aliases := range invoiceColumnAliases {
+			for _, alias := range aliases {
+				if _, found := columns[column]; !found && name == alias {
+					columns[column] = i
+				}
+			}
+		}
+	}
+	if _, ok := columns["number"]; !ok {
+		return nil, nil, fmt.Errorf("invoice has no number column (e.g. %q)", "to")
+	}
+	if _, ok := columns["start"]; !ok {
+		return nil, nil, fmt.Errorf("invoice has no start time column (e.g. %q)", "start_time")
+	}
+	_, hasSeconds := columns["seconds"]
+	_, hasMinutes := columns["minutes"]
+	if !hasSeconds && !hasMinutes {
+		return nil, nil, fmt.Errorf("invoice has no duration column (e.g. %q or %q)", "seconds", "minutes")
+	}
+
+	lines, skipped := []InvoiceLine{}, []string{}
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if row > maxInvoiceImportRows+1 {
+			return nil, nil, fmt.Errorf("invoice has more than %d lines", maxInvoiceImportRows)
+		}
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("line %d: %v", row, err))
+			continue
+		}
+		field := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		line := InvoiceLine{Line: row, Number: field("number"), CallingNumber: field("calling_number")}
+		if line.Number == "" {
+			skipped = append(skipped, fmt.Sprintf("line %d: no number", row))
+			continue
+		}
+		if line.StartedAt, err = parseInvoiceTime(field("start"), loc); err != nil {
+			skipped = append(skipped, fmt.Sprintf("line %d: %v", row, err))
+			continue
+		}
+		if value := field("seconds"); value != "" {
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("line %d: invalid duration %q", row, value))
+				continue
+			}
+			line.BilledSeconds = int(math.Round(seconds))
+		} else {
+			minutes, err := strconv.ParseFloat(field("minutes"), 64)
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("line %d: invalid minutes %q", row, field("minutes")))
+				continue
+			}
+			line.BilledSeconds = int(math.Round(minutes * 60))
+		}
+		if value := strings.TrimPrefix(field("amount"), "$"); value != "" {
+			if line.Amount, err = strconv.ParseFloat(strings.ReplaceAll(value, ",", ""), 64); err != nil {
+				skipped = append(skipped, fmt.Sprintf("line %d: invalid amount %q", row, value))
+				continue
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].StartedAt.Before(lines[j].StartedAt)
+	})
+	return lines, skipped, nil
+}
+
+// parseInvoiceTime reads a start time in any of the accepted layouts
+func parseInvoiceTime(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range invoiceTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid start time %q", value)
+}
+
+// cdrBillingNumbers are the outside numbers on a CDR a carrier could bill it under
+func cdrBillingNumbers(cdr *models.FlexibleCDR) []string {
+	return billingNumberKeys(
+		cdrCallerNumber(cdr, "orig"),
+		cdrCallerNumber(cdr, "term"),
+		firstCDRString(*cdr, "call-orig-to-user", "call-dialed-number"),
+	)
+}
+
+// billingNumberKeys normalizes numbers for matching, dropping extensions and duplicates
+func billingNumberKeys(numbers ...string) []string {
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, number := range numbers {
+		if key, ok := normalizeE164(number); ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// absDuration is the magnitude of a duration
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// SaveInvoice stores an invoice and its lines, setting the invoice's ID
+func (ds *DatabaseService) SaveInvoice(invoice *Invoice, lines []InvoiceLine) error {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+	INSERT INTO billing_invoices (carrier, filename, period_start, period_end, lines, total_amount, imported_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		invoice.Carrier, invoice.Filename, invoice.PeriodStart.UTC(), invoice.PeriodEnd.UTC(),
+		invoice.Lines, invoice.TotalAmount, invoice.ImportedAt.UTC()).Scan(&invoice.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save invoice: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`
+	INSERT INTO invoice_lines (invoice_id, line, number, calling_number, call_start, billed_seconds, amount)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare invoice line insert: %w", err)
+	}
+	defer stmt.Close()
+	for _, line := range lines {
+		if _, err := stmt.Exec(invoice.ID, line.Line, line.Number, line.CallingNumber, line.StartedAt.UTC(),
+			line.BilledSeconds, line.Amount); err != nil {
+			return fmt.Errorf("failed to save invoice line: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetInvoices lists imported invoices, newest first
+func (ds *DatabaseService) GetInvoices(limit int) ([]Invoice, error) {
+	rows, err := ds.db.Query(`
+	SELECT id, carrier, COALESCE(filename, ''), period_start, period_end, lines, total_amount, imported_at
+	FROM billing_invoices ORDER BY imported_at DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invoices: %w", err)
+	}
+	defer rows.Close()
+
+	invoices := []Invoice{}
+	for rows.Next() {
+		var invoice Invoice
+		if err := rows.Scan(&invoice.ID, &invoice.Carrier, &invoice.Filename, &invoice.PeriodStart,
+			&invoice.PeriodEnd, &invoice.Lines, &invoice.TotalAmount, &invoice.ImportedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan invoice: %w", err)
+		}
+		invoices = append(invoices, invoice)
+	}
+	return invoices, rows.Err()
+}
+
+// GetInvoice returns an invoice, or nil if there is none with that ID
+func (ds *DatabaseService) GetInvoice(id int64) (*Invoice, error) {
+	var invoice Invoice
+	err := ds.db.QueryRow(`
+	SELECT id, carrier, COALESCE(filename, ''), period_start, period_end, lines, total_amount, imported_at
+	FROM billing_invoices WHERE id = ?`, id).Scan(&invoice.ID, &invoice.Carrier, &invoice.Filename,
+		&invoice.PeriodStart, &invoice.PeriodEnd, &invoice.Lines, &invoice.TotalAmount, &invoice.ImportedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+	return &invoice, nil
+}
+
+// GetInvoiceLines returns an invoice's lines in order of start time
+func (ds *DatabaseService) GetInvoiceLines(invoiceID int64) ([]InvoiceLine, error) {
+	rows, err := ds.db.Query(`
+	SELECT line, number, COALESCE(calling_number, ''), call_start, billed_seconds, amount
+	FROM invoice_lines WHERE invoice_id = ? ORDER BY call_start, line`, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invoice lines: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []InvoiceLine{}
+	for rows.Next() {
+		var line InvoiceLine
+		if err := rows.Scan(&line.Line, &line.Number, &line.CallingNumber, &line.StartedAt,
+			&line.BilledSeconds, &line.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan invoice line: %w", err)
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// DeleteInvoice removes an invoice and its lines, reporting whether it existed
+func (ds *DatabaseService) DeleteInvoice(id int64) (bool, error) {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM invoice_lines WHERE invoice_id = ?`, id); err != nil {
+		return false, fmt.Errorf("failed to delete invoice lines: %w", err)
+	}
+	result, err := tx.Exec(`DELETE FROM billing_invoices WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete invoice: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+	return deleted > 0, tx.Commit()
+}
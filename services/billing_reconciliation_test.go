@@ -0,0 +1,96 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestBillingReconciliation(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "billing.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	// Times are Eastern, four hours behind UTC in October
+	invoiceCSV := "\ufeffCall Date,From,To,Billed Minutes,Cost\n" +
+		"10/13/2026 06:00:30,4155550100,2125551234,2,$0.04\n" + // matches cdr-1
+		"10/13/2026 06:10:00,4155550100,(617) 555-2188,10,$0.20\n" + // the call lasted 3 minutes
+		"10/13/2026 06:20:00,4155550100,3125559876,1,$0.02\n" + // no such call
+		"10/13/2026 06:30:00,4155550100,,1,$0.02\n" +
+		"not a date,4155550100,3125559876,1,$0.02\n"
+	reconciler := NewBillingReconciler(db, BillingSettings{TimeTolerance: 2 * time.Minute, DurationTolerance: time.Minute})
+	eastern, _ := time.LoadLocation("America/New_York")
+	invoice, skipped, err := reconciler.ImportInvoice("acme-telecom", "october.csv", strings.NewReader(invoiceCSV), eastern)
+	if err != nil {
+		t.Fatalf("ImportInvoice: %v", err)
+	}
+	if invoice.Lines != 3 || len(skipped) != 2 || invoice.TotalAmount != 0.26 {
+		t.Fatalf("ImportInvoice = %+v, skipped %v", invoice, skipped)
+	}
+	if want := time.Date(2026, 10, 13, 10, 0, 30, 0, time.UTC); !invoice.PeriodStart.Equal(want) {
+		t.Errorf("period start = %v, want %v", invoice.PeriodStart, want)
+	}
+
+	cdr := func(id, to, start string, seconds int, direction int) models.FlexibleCDR {
+		return models.FlexibleCDR{RawData: map[string]interface{}{
+			"id":                          id,
+			"call-direction":              direction,
+			"call-orig-caller-id":         "4155550100",
+			"call-orig-to-user":           to,
+			"call-start-datetime":         start,
+			"call-total-duration-seconds": seconds,
+		}}
+	}
+	cdrs := []models.FlexibleCDR{
+		cdr("cdr-1", "12125551234", "2026-10-13T10:00:00Z", 95, 0),
+		cdr("cdr-2", "6175552188", "2026-10-13T10:10:05Z", 180, 0),
+		cdr("cdr-3", "7185550123", "2026-10-13T10:15:00Z", 60, 0), // not on the invoice
+		cdr("cdr-4", "7185550124", "2026-10-13T10:16:00Z", 0, 0),  // never answered
+		cdr("cdr-5", "101", "2026-10-13T10:17:00Z", 60, 1),        // inbound
+		cdr("cdr-6", "7185550125", "2026-10-14T10:00:00Z", 60, 0), // after the invoice period
+	}
+
+	report, err := reconciler.Reconcile(invoice.ID, "session-1", cdrs)
+	if err != nil || report == nil {
+		t.Fatalf("Reconcile = %+v, %v", report, err)
+	}
+	if report.BillableCDRs != 3 || report.Matched != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Counts[BillingOverBilled] != 1 || report.Counts[BillingWithoutCDR] != 1 || report.Counts[BillingUnbilled] != 1 {
+		t.Fatalf("unexpected discrepancies: %+v", report.Discrepancies)
+	}
+	for _, discrepancy := range report.Discrepancies {
+		switch discrepancy.Type {
+		case BillingOverBilled:
+			if discrepancy.CDRID != "cdr-2" || discrepancy.Difference != 420 || discrepancy.Amount != 0.14 {
+				t.Errorf("over-billed = %+v", discrepancy)
+			}
+		case BillingUnbilled:
+			if discrepancy.CDRID != "cdr-3" {
+				t.Errorf("unbilled = %+v", discrepancy)
+			}
+		}
+	}
+	if report.DisputedAmount != 0.16 {
+		t.Errorf("disputed amount = %v, want 0.16", report.DisputedAmount)
+	}
+
+	if missing, err := reconciler.Reconcile(invoice.ID+1, "session-1", cdrs); err != nil || missing != nil {
+		t.Fatalf("Reconcile of a missing invoice = %+v, %v", missing, err)
+	}
+	if _, _, err := reconciler.ImportInvoice("acme-telecom", "", strings.NewReader("a,b\n1,2\n"), time.UTC); err == nil {
+		t.Fatal("ImportInvoice accepted an invoice without a number column")
+	}
+	if deleted, err := db.DeleteInvoice(invoice.ID); err != nil || !deleted {
+		t.Fatalf("DeleteInvoice = %v, %v", deleted, err)
+	}
+	if invoices, err := db.GetInvoices(10); err != nil || len(invoices) != 0 {
+		t.Fatalf("GetInvoices after delete = %+v, %v", invoices, err)
+	}
+}
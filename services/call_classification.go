@@ -0,0 +1,110 @@
+// services/call_classification.go
+// Call classification: whether each CDR was answered, abandoned (the caller hung up
+// before anyone answered) or missed (it rang out, was busy or went to voicemail), from
+// its duration, disposition and disconnect reason. The rules are reloadable settings.
+
+package services
+
+import (
+	"o-dan-go/models"
+	"strings"
+	"sync/atomic"
+)
+
+// Call classes
+const (
+	CallAnswered  = "answered"
+	CallAbandoned = "abandoned"
+	CallMissed    = "missed"
+)
+
+// Default disconnect reasons of each class, matched case-insensitively anywhere in the
+// reason or disposition
+const (
+	DefaultAbandonedReasons = "originator cancel,cancel,request terminated,487,abandon,caller hangup"
+	DefaultMissedReasons    = "no answer,busy,timeout,unavailable,declined,480,486,408,603"
+)
+
+// CallClassificationSettings configures how calls are classified
+type CallClassificationSettings struct {
+	MinAnsweredSeconds int    // calls connected for less are abandoned, not answered
+	AbandonedReasons   string // comma-separated reasons meaning the caller hung up first
+	MissedReasons      string // comma-separated reasons meaning nobody answered
+}
+
+// callClassifier holds parsed CallClassificationSettings
+type callClassifier struct {
+	minAnsweredSeconds int
+	abandonedReasons   []string
+	missedReasons      []string
+}
+
+// defaultCallClassifier classifies calls until settings are applied
+var defaultCallClassifier = newCallClassifier(CallClassificationSettings{
+	AbandonedReasons: DefaultAbandonedReasons,
+	MissedReasons:    DefaultMissedReasons,
+})
+
+// activeCallClassifier is swapped whole when the settings are reloaded
+var activeCallClassifier atomic.Pointer[callClassifier]
+
+// SetCallClassification replaces the call classification rules (hot-reloadable)
+func SetCallClassification(settings CallClassificationSettings) {
+	activeCallClassifier.Store(newCallClassifier(settings))
+}
+
+// newCallClassifier parses classification settings
+func newCallClassifier(settings CallClassificationSettings) *callClassifier {
+	return &callClassifier{
+		minAnsweredSeconds: settings.MinAnsweredSeconds,
+		abandonedReasons:   classificationReasons(settings.AbandonedReasons),
+		missedReasons:      classificationReasons(settings.MissedReasons),
+	}
+}
+
+// classificationReasons splits a comma-separated reason list, lowercased
+func classificationReasons(list string) []string {
+	reasons := []string{}
+	for _, reason := range strings.Split(list, ",") {
+		if reason = strings.ToLower(strings.TrimSpace(reason)); reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	return reasons
+}
+
+// ClassifyCall reports whether a call was answered, abandoned or missed. Calls answered
+// by voicemail are missed. Unanswered calls are classed by their disconnect reason or
+// disposition, abandoned reasons first; those with neither kind of reason are missed.
+func ClassifyCall(cdr models.FlexibleCDR) string {
+	classifier := activeCallClassifier.Load()
+	if classifier == nil {
+		classifier = defaultCallClassifier
+	}
+	answered, talkSeconds := callAnswered(cdr)
+	if answered && VoicemailUser(cdr) != "" {
+		return CallMissed
+	}
+	if answered && talkSeconds >= classifier.minAnsweredSeconds {
+		return CallAnswered
+	}
+
+	reason := strings.ToLower(firstCDRString(cdr, "call-disconnect-reason-text", "disposition"))
+	if reason != "" {
+		for _, abandoned := range classifier.abandonedReasons {
+			if strings.Contains(reason, abandoned) {
+				return CallAbandoned
+			}
+		}
+		for _, missed := range classifier.missedReasons {
+			if strings.Contains(reason, missed) {
+				return CallMissed
+			}
+		}
+	}
+	if answered {
+		// Connected too briefly to count: the caller hung up as it was picked up
+		return CallAbandoned
+	}
+	return CallMissed
+}
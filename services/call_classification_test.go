@@ -0,0 +1,76 @@
+package services
+
+import (
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestClassifyCall(t *testing.T) {
+	defer SetCallClassification(CallClassificationSettings{AbandonedReasons: DefaultAbandonedReasons, MissedReasons: DefaultMissedReasons})
+
+	cdr := func(duration int, reason string) models.FlexibleCDR {
+		return models.FlexibleCDR{RawData: map[string]interface{}{
+			"call-total-duration-seconds": float64(duration), "call-disconnect-reason-text": reason,
+		}}
+	}
+	tests := []struct {
+		name string
+		cdr  models.FlexibleCDR
+		want string
+	}{
+		{"connected", cdr(60, "Normal Clearing"), CallAnswered},
+		{"caller hung up", cdr(0, "Originator Cancel"), CallAbandoned},
+		{"no answer", cdr(0, "No Answer"), CallMissed},
+		{"busy", cdr(0, "486 Busy Here"), CallMissed},
+		{"no reason", cdr(0, ""), CallMissed},
+		{"voicemail", models.FlexibleCDR{RawData: map[string]interface{}{
+			"call-total-duration-seconds": float64(40), "call-term-user": "vmail_101",
+		}}, CallMissed},
+		{"disposition", models.FlexibleCDR{RawData: map[string]interface{}{"disposition": "ABANDONED"}}, CallAbandoned},
+	}
+	for _, tt := range tests {
+		if got := ClassifyCall(tt.cdr); got != tt.want {
+			t.Errorf("%s: ClassifyCall = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	SetCallClassification(CallClassificationSettings{MinAnsweredSeconds: 5, MissedReasons: "no answer"})
+	if got := ClassifyCall(cdr(3, "Normal Clearing")); got != CallAbandoned {
+		t.Errorf("a call shorter than the minimum should be abandoned, got %q", got)
+	}
+	if got := ClassifyCall(cdr(0, "Originator Cancel")); got != CallMissed {
+		t.Errorf("reasons should come from the settings, got %q", got)
+	}
+}
+
+func TestBuildMissedCallReport(t *testing.T) {
+	call := func(id, from, to string, direction, duration int, reason, start string) models.FlexibleCDR {
+		return models.FlexibleCDR{RawData: map[string]interface{}{
+			"id": id, "call-orig-caller-id": from, "call-term-caller-id": to, "call-term-user": "101",
+			"call-direction": float64(direction), "call-total-duration-seconds": float64(duration),
+			"call-disconnect-reason-text": reason, "call-start-datetime": start,
+		}}
+	}
+	cdrs := []models.FlexibleCDR{
+		call("1", "15125550001", "14155550101", 1, 0, "No Answer", "2026-01-05T10:00:00Z"),
+		call("2", "15125550001", "14155550101", 1, 0, "Originator Cancel", "2026-01-05T10:05:00Z"),
+		call("3", "15125550002", "14155550101", 1, 0, "No Answer", "2026-01-05T09:00:00Z"),
+		call("4", "14155550101", "+1 (512) 555-0002", 0, 120, "Normal Clearing", "2026-01-05T11:00:00Z"),
+		call("5", "15125550003", "14155550101", 1, 0, "No Answer", "2026-01-05T12:00:00Z"),
+		call("6", "15125550003", "14155550101", 1, 90, "Normal Clearing", "2026-01-05T08:00:00Z"),
+		call("7", "14155550101", "15125550009", 0, 0, "No Answer", "2026-01-05T12:00:00Z"),
+	}
+
+	report := BuildMissedCallReport(cdrs)
+	if report.Calls != 7 || report.Answered != 2 || report.Abandoned != 1 || report.Missed != 4 || report.Reached != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if len(report.Callbacks) != 2 || report.Callbacks[0].Number != "15125550003" || report.Callbacks[1].Number != "15125550001" {
+		t.Fatalf("callbacks = %+v", report.Callbacks)
+	}
+	caller := report.Callbacks[1]
+	if caller.Missed != 1 || caller.Abandoned != 1 || len(caller.CDRIDs) != 2 || len(caller.Called) != 1 || caller.Called[0] != "101" {
+		t.Errorf("caller = %+v", caller)
+	}
+}
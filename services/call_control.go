@@ -0,0 +1,288 @@
+// services/call_control.go
+// Click-to-call and call control: places calls through the NetSapiens call origination
+// API, follows them on the dashboard until they end, and hangs up, holds or transfers
+// calls through the call management API
+
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"o-dan-go/events"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClickToCallApp is the app name originated calls are sent under on the dashboard
+const ClickToCallApp = "click-to-call"
+
+// callPollInterval is how often an originated call is checked to see whether it has ended
+const callPollInterval = 5 * time.Second
+
+// callPollFailures is how many checks in a row may fail before the call is given up on
+const callPollFailures = 12
+
+// ErrCallNotFound is returned for calls that can't be controlled: not live, or not a PBX
+// or click-to-call call
+var ErrCallNotFound = errors.New("call not found")
+
+// CallLocator finds the domain and user whose leg of a call can be controlled
+type CallLocator func(callID string) (domain, user string, ok bool)
+
+// OriginateRequest asks NetSapiens to connect two numbers: From rings first (the user's
+// own phones when empty), and once answered the call goes out to To
+type OriginateRequest struct {
+	Domain string `json:"domain"`
+	User   string `json:"user"` // user the call is placed as
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// Validate checks the request and cleans up its numbers for dialing
+func (req *OriginateRequest) Validate() error {
+	if req.Domain == "" || req.User == "" {
+		return fmt.Errorf("domain and user are required")
+	}
+	to, err := dialString(req.To)
+	if err != nil {
+		return fmt.Errorf("invalid to number: %w", err)
+	}
+	req.To = to
+	if req.From != "" {
+		from, err := dialString(req.From)
+		if err != nil {
+			return fmt.Errorf("invalid from number: %w", err)
+		}
+		req.From = from
+	}
+	return nil
+}
+
+// TransferRequest moves a call to another number or extension
+type TransferRequest struct {
+	To string `json:"to"`
+}
+
+// Validate checks the request and cleans up the number for dialing
+func (req *TransferRequest) Validate() error {
+	to, err := dialString(req.To)
+	if err != nil {
+		return fmt.Errorf("invalid to number: %w", err)
+	}
+	req.To = to
+	return nil
+}
+
+// OriginatedCall is a call placed through Originate
+type OriginatedCall struct {
+	CallID    string    `json:"call_id"`
+	Domain    string    `json:"domain"`
+	User      string    `json:"user"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// CallControlService places calls through the NetSapiens v2 call API
+type CallControlService struct {
+	client       *http.Client
+	baseURL      string
+	accessToken  string
+	pollInterval time.Duration
+
+	mu       sync.Mutex
+	calls    map[string]*OriginatedCall
+	locators []CallLocator
+}
+
+// NewCallControlService creates a call control client
+func NewCallControlService(baseURL, accessToken string) *CallControlService {
+	return &CallControlService{
+		client:       &http.Client{Timeout: 10 * time.Second},
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		accessToken:  accessToken,
+		pollInterval: callPollInterval,
+		calls:        make(map[string]*OriginatedCall),
+	}
+}
+
+// Originate places a call from req.From to req.To as req.User, sends call_started to the
+// dashboard and follows the call in the background until it ends
+func (cs *CallControlService) Originate(req OriginateRequest) (*OriginatedCall, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if cs.accessToken == "" {
+		return nil, fmt.Errorf("no NetSapiens access token configured")
+	}
+	from, to := req.From, req.To
+	if from == "" {
+		from = req.User
+	}
+
+	call := &OriginatedCall{
+		CallID:    fmt.Sprintf("c2c_%d", time.Now().UnixNano()),
+		Domain:    req.Domain,
+		User:      req.User,
+		From:      from,
+		To:        to,
+		StartedAt: time.Now(),
+	}
+	payload := map[string]string{
+		"call-id":     call.CallID,
+		"destination": to,
+	}
+	if req.From != "" {
+		payload["origination"] = req.From
+	}
+
+	var created map[string]interface{}
+	if err := netsapiensRequest(cs.client, cs.baseURL, cs.accessToken, "POST", cs.userCallsPath(call.Domain, call.User), payload, &created); err != nil {
+		return nil, err
+	}
+	if callID := recordString(created, "call-id", "callid", "id"); callID != "" {
+		call.CallID = callID
+	}
+
+	cs.mu.Lock()
+	cs.calls[call.CallID] = call
+	cs.mu.Unlock()
+
+	log.Printf("[Calls] Placed call %s from %s to %s as %s@%s", call.CallID, from, to, call.User, call.Domain)
+	sendCallEvent(ClickToCallApp, "c2c_"+call.CallID, call.CallID, from, "call_started", fmt.Sprintf("Calling %s from %s", to, from))
+	go cs.watch(call)
+
+	return call, nil
+}
+
+// watch polls an originated call until NetSapiens no longer has it, then ends it on the dashboard
+func (cs *CallControlService) watch(call *OriginatedCall) {
+	path := cs.userCallsPath(call.Domain, call.User) + "/" + url.PathEscape(call.CallID)
+	details := "Call ended"
+
+	failures := 0
+	for {
+		time.Sleep(cs.pollInterval)
+
+		var status map[string]interface{}
+		err := netsapiensGet(cs.client, cs.baseURL, cs.accessToken, path, &status)
+		if isNetsapiensNotFound(err) {
+			break
+		}
+		if err != nil {
+			failures++
+			if failures >= callPollFailures {
+				log.Printf("[Calls] Giving up on call %s: %v", call.CallID, err)
+				details = "Call status unavailable"
+				break
+			}
+			continue
+		}
+		failures = 0
+	}
+
+	cs.mu.Lock()
+	delete(cs.calls, call.CallID)
+	cs.mu.Unlock()
+	sendCallEvent(ClickToCallApp, "c2c_"+call.CallID, call.CallID, call.From, "call_ended", details)
+}
+
+// AddLocator lets calls found elsewhere (e.g. by PBX event ingestion) be controlled.
+// Call it before serving requests.
+func (cs *CallControlService) AddLocator(locate CallLocator) {
+	cs.locators = append(cs.locators, locate)
+}
+
+// HangUp ends a call
+func (cs *CallControlService) HangUp(callID string) error {
+	return cs.control(callID, "DELETE", "", nil, "Hang up requested")
+}
+
+// Hold puts a call on hold
+func (cs *CallControlService) Hold(callID string) error {
+	return cs.control(callID, "PATCH", "/hold", nil, "Put on hold")
+}
+
+// Resume takes a call off hold
+func (cs *CallControlService) Resume(callID string) error {
+	return cs.control(callID, "PATCH", "/unhold", nil, "Taken off hold")
+}
+
+// Transfer moves a call to req.To
+func (cs *CallControlService) Transfer(callID string, req TransferRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	return cs.control(callID, "PATCH", "/transfer", map[string]string{"destination": req.To}, "Transferring to "+req.To)
+}
+
+// control sends a call management request for a call and notes it on the dashboard.
+// The call's end still comes from whatever is following it.
+func (cs *CallControlService) control(callID, method, action string, payload interface{}, details string) error {
+	domain, user, ok := cs.locate(callID)
+	if !ok {
+		return ErrCallNotFound
+	}
+	if cs.accessToken == "" {
+		return fmt.Errorf("no NetSapiens access token configured")
+	}
+
+	path := cs.userCallsPath(domain, user) + "/" + url.PathEscape(callID) + action
+	if err := netsapiensRequest(cs.client, cs.baseURL, cs.accessToken, method, path, payload, nil); err != nil {
+		if isNetsapiensNotFound(err) {
+			return ErrCallNotFound
+		}
+		return err
+	}
+	log.Printf("[Calls] %s: %s (%s@%s)", callID, details, user, domain)
+
+	for _, call := range events.Manager.GetActiveCalls() {
+		if call.CallID == callID {
+			sendCallEvent(call.App, call.SessionID, callID, call.CallerNum, "response_sent", details)
+			break
+		}
+	}
+	return nil
+}
+
+// locate finds the domain and user of a call placed here or reported by a locator
+func (cs *CallControlService) locate(callID string) (string, string, bool) {
+	cs.mu.Lock()
+	call, exists := cs.calls[callID]
+	cs.mu.Unlock()
+	if exists {
+		return call.Domain, call.User, true
+	}
+
+	for _, locate := range cs.locators {
+		if domain, user, ok := locate(callID); ok {
+			return domain, user, true
+		}
+	}
+	return "", "", false
+}
+
+// userCallsPath is the NetSapiens collection of a user's calls
+func (cs *CallControlService) userCallsPath(domain, user string) string {
+	return fmt.Sprintf("/ns-api/v2/domains/%s/users/%s/calls", url.PathEscape(domain), url.PathEscape(user))
+}
+
+// dialString cleans up a phone number or extension for dialing, keeping a leading +
+func dialString(number string) (string, error) {
+	number = strings.TrimSpace(number)
+	if strings.Trim(number, "+0123456789-(). ") != "" {
+		return "", fmt.Errorf("%q is not a phone number or extension", number)
+	}
+	digits := nonDigits.ReplaceAllString(number, "")
+	if len(digits) < 2 || len(digits) > 15 {
+		return "", fmt.Errorf("%q is not a phone number or extension", number)
+	}
+	if strings.HasPrefix(number, "+") {
+		return "+" + digits, nil
+	}
+	return digits, nil
+}
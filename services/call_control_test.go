@@ -0,0 +1,129 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOriginateFollowsCallUntilItEnds(t *testing.T) {
+	var ended atomic.Bool
+	var payload map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/ns-api/v2/domains/acme/users/101/calls":
+			json.NewDecoder(r.Body).Decode(&payload)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]string{"call-id": "ns-42"})
+		case r.Method == "GET" && r.URL.Path == "/ns-api/v2/domains/acme/users/101/calls/ns-42" && !ended.Load():
+			json.NewEncoder(w).Encode(map[string]string{"call-id": "ns-42"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	cs := NewCallControlService(server.URL, "token")
+	cs.pollInterval = 10 * time.Millisecond
+
+	call, err := cs.Originate(OriginateRequest{Domain: "acme", User: "101", To: "(415) 555-1234"})
+	if err != nil {
+		t.Fatalf("Originate: %v", err)
+	}
+	if call.CallID != "ns-42" || call.From != "101" || payload["destination"] != "4155551234" {
+		t.Fatalf("unexpected call %+v with payload %v", call, payload)
+	}
+	if _, hasOrigination := payload["origination"]; hasOrigination {
+		t.Fatalf("origination should be left to the user's phones: %v", payload)
+	}
+
+	ended.Store(true)
+	deadline := time.Now().Add(time.Second)
+	for {
+		cs.mu.Lock()
+		_, following := cs.calls["ns-42"]
+		cs.mu.Unlock()
+		if !following {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("call was still followed after it ended")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestOriginateRequestValidate(t *testing.T) {
+	req := OriginateRequest{Domain: "acme", User: "101", From: "+1 415-555-0000", To: "102"}
+	if err := req.Validate(); err != nil || req.From != "+14155550000" || req.To != "102" {
+		t.Fatalf("Validate = %v, request %+v", err, req)
+	}
+
+	for _, bad := range []OriginateRequest{
+		{User: "101", To: "102"},
+		{Domain: "acme", User: "101", To: "sip:evil"},
+		{Domain: "acme", User: "101", To: "1"},
+		{Domain: "acme", User: "101", From: "abc", To: "102"},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", bad)
+		}
+	}
+}
+
+func TestCallControlActions(t *testing.T) {
+	var requests []string
+	var transfer map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/ns-api/v2/domains/acme/users/102/calls/gone/hold" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == "PATCH" && r.URL.Path == "/ns-api/v2/domains/acme/users/102/calls/pbx-1/transfer" {
+			json.NewDecoder(r.Body).Decode(&transfer)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	cs := NewCallControlService(server.URL, "token")
+	cs.AddLocator(func(callID string) (string, string, bool) {
+		return "acme", "102", callID == "pbx-1" || callID == "gone"
+	})
+
+	if err := cs.Hold("pbx-1"); err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	if err := cs.Transfer("pbx-1", TransferRequest{To: "1-415-555-1234"}); err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+	if err := cs.HangUp("pbx-1"); err != nil {
+		t.Fatalf("HangUp: %v", err)
+	}
+	want := []string{
+		"PATCH /ns-api/v2/domains/acme/users/102/calls/pbx-1/hold",
+		"PATCH /ns-api/v2/domains/acme/users/102/calls/pbx-1/transfer",
+		"DELETE /ns-api/v2/domains/acme/users/102/calls/pbx-1",
+	}
+	if len(requests) != len(want) || requests[0] != want[0] || requests[1] != want[1] || requests[2] != want[2] {
+		t.Fatalf("requests = %v, want %v", requests, want)
+	}
+	if transfer["destination"] != "14155551234" {
+		t.Fatalf("unexpected transfer payload: %v", transfer)
+	}
+
+	if err := cs.Transfer("pbx-1", TransferRequest{To: "sales"}); err == nil {
+		t.Error("expected an invalid transfer target to be rejected")
+	}
+	if err := cs.Hold("unknown"); !errors.Is(err, ErrCallNotFound) {
+		t.Errorf("Hold(unknown) = %v, want ErrCallNotFound", err)
+	}
+	if err := cs.Hold("gone"); !errors.Is(err, ErrCallNotFound) {
+		t.Errorf("Hold(gone) = %v, want ErrCallNotFound", err)
+	}
+}
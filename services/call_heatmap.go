@@ -0,0 +1,121 @@
+// services/call_heatmap.go
+// Busy-hour heatmaps: calls and connected minutes by hour of day and day of week, for a
+// domain's recorded calls or a search session's CDRs, so staffing teams can see when
+// calls arrive at a glance
+
+package services
+
+import (
+	"fmt"
+	"o-dan-go/models"
+	"time"
+)
+
+// heatmapDays names the heatmap's rows, in time.Weekday order
+var heatmapDays = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+
+// CallHeatmap is a 7x24 matrix of calls and minutes, rows by weekday from Sunday and
+// columns by hour from midnight, in a timezone
+type CallHeatmap struct {
+	Domain      string         `json:"domain,omitempty"`
+	SessionID   string         `json:"session_id,omitempty"`
+	Start       string         `json:"start,omitempty"`
+	End         string         `json:"end,omitempty"`
+	Timezone    string         `json:"timezone"`
+	Days        []string       `json:"days"`
+	Calls       [7][24]int     `json:"calls"`
+	Minutes     [7][24]float64 `json:"minutes"` // connected minutes of the calls started in the hour
+	TotalCalls  int            `json:"total_calls"`
+	BusiestDay  string         `json:"busiest_day,omitempty"`
+	BusiestHour *int           `json:"busiest_hour,omitempty"` // hour of the busiest cell
+	Skipped     int            `json:"skipped"`                // CDRs without a start time that parses
+	seconds     [7][24]int
+}
+
+// newCallHeatmap creates an empty heatmap in loc
+func newCallHeatmap(loc *time.Location) *CallHeatmap {
+	return &CallHeatmap{Timezone: loc.String(), Days: heatmapDays}
+}
+
+// add counts a call started at a time with its connected seconds
+func (h *CallHeatmap) add(started time.Time, talkSeconds int, loc *time.Location) {
+	local := started.In(loc)
+	h.Calls[local.Weekday()][local.Hour()]++
+	h.seconds[local.Weekday()][local.Hour()] += talkSeconds
+	h.TotalCalls++
+}
+
+// finish converts seconds to minutes and finds the busiest cell, the earliest in the
+// week when cells tie
+func (h *CallHeatmap) finish() {
+	busiest := 0
+	for day := range h.Calls {
+		for hour, calls := range h.Calls[day] {
+			h.Minutes[day][hour] = roundTo(float64(h.seconds[day][hour])/60, 1)
+			if calls > busiest {
+				busiest = calls
+				h.BusiestDay = heatmapDays[day]
+				busiestHour := hour
+				h.BusiestHour = &busiestHour
+			}
+		}
+	}
+}
+
+// HeatmapFromCDRs builds a heatmap from a session's CDRs by call start time in loc
+func HeatmapFromCDRs(cdrs []models.FlexibleCDR, loc *time.Location) *CallHeatmap {
+	heatmap := newCallHeatmap(loc)
+	for _, cdr := range cdrs {
+		started, err := cdr.GetCallStartTime()
+		if err != nil {
+			heatmap.Skipped++
+			continue
+		}
+		_, talkSeconds := callAnswered(cdr)
+		heatmap.add(started, talkSeconds, loc)
+	}
+	heatmap.finish()
+	return heatmap
+}
+
+// DomainHeatmap builds a heatmap from a domain's calls recorded between two days
+// (inclusive, UTC, as for KPI reports), by call start time in loc
+func (ks *KPIService) DomainHeatmap(domain string, start, end time.Time, loc *time.Location) (*CallHeatmap, error) {
+	calls, err := ks.db.GetCallOutcomes(domain, start, end)
+	if err != nil {
+		return nil, err
+	}
+	heatmap := newCallHeatmap(loc)
+	heatmap.Domain = domain
+	heatmap.Start = start.Format(kpiDayFormat)
+	heatmap.End = end.Format(kpiDayFormat)
+	for _, call := range calls {
+		heatmap.add(call.StartedAt, call.TalkSeconds, loc)
+	}
+	heatmap.finish()
+	return heatmap, nil
+}
+
+// GetCallOutcomes lists every call recorded for a domain between two days (inclusive)
+func (ds *DatabaseService) GetCallOutcomes(domain string, start, end time.Time) ([]CallOutcome, error) {
+	rows, err := ds.db.Query(`
+	SELECT cdr_id, COALESCE(domain, ''), COALESCE(trunk, ''), call_start, answered, network_failure, talk_seconds
+	FROM call_outcomes
+	WHERE domain = ? AND call_day >= ? AND call_day <= ?
+	ORDER BY call_start`, domain, start.Format(kpiDayFormat), end.Format(kpiDayFormat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query call outcomes: %w", err)
+	}
+	defer rows.Close()
+
+	calls := []CallOutcome{}
+	for rows.Next() {
+		var call CallOutcome
+		if err := rows.Scan(&call.CDRID, &call.Domain, &call.Trunk, &call.StartedAt, &call.Answered,
+			&call.NetworkFailure, &call.TalkSeconds); err != nil {
+			return nil, fmt.Errorf("failed to scan call outcome: %w", err)
+		}
+		calls = append(calls, call)
+	}
+	return calls, rows.Err()
+}
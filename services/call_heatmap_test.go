@@ -0,0 +1,40 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestHeatmapFromCDRs(t *testing.T) {
+	cdrs := []models.FlexibleCDR{
+		// Friday 2024-03-01
+		{RawData: map[string]interface{}{"call-start-datetime": "2024-03-01T15:05:00Z", "call-total-duration-seconds": float64(120)}},
+		{RawData: map[string]interface{}{"call-start-datetime": "2024-03-01T15:45:00Z", "call-total-duration-seconds": float64(60)}},
+		// Saturday 2024-03-02 01:00 UTC is Friday evening in Chicago
+		{RawData: map[string]interface{}{"call-start-datetime": "2024-03-02T01:00:00Z", "call-total-duration-seconds": float64(0)}},
+		{RawData: map[string]interface{}{"call-total-duration-seconds": float64(30)}},
+	}
+
+	heatmap := HeatmapFromCDRs(cdrs, time.UTC)
+	if heatmap.TotalCalls != 3 || heatmap.Skipped != 1 || heatmap.Calls[time.Friday][15] != 2 || heatmap.Calls[time.Saturday][1] != 1 {
+		t.Fatalf("heatmap = %+v", heatmap)
+	}
+	if heatmap.Minutes[time.Friday][15] != 3 || heatmap.BusiestDay != "Friday" || heatmap.BusiestHour == nil || *heatmap.BusiestHour != 15 {
+		t.Errorf("minutes and busiest cell = %v, %s %v", heatmap.Minutes[time.Friday][15], heatmap.BusiestDay, heatmap.BusiestHour)
+	}
+
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Skip("no timezone database")
+	}
+	local := HeatmapFromCDRs(cdrs, chicago)
+	if local.Calls[time.Friday][19] != 1 || local.Calls[time.Friday][9] != 2 {
+		t.Errorf("Chicago heatmap = %+v", local.Calls)
+	}
+
+	if empty := HeatmapFromCDRs(nil, time.UTC); empty.BusiestHour != nil || empty.TotalCalls != 0 {
+		t.Errorf("empty heatmap = %+v", empty)
+	}
+}
@@ -0,0 +1,121 @@
+// services/call_queues.go
+// Live call queue depth and agent availability from the NetSapiens call queue API
+
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultQueueHandleTime is the average time an agent spends on a queue call,
+// used to estimate the wait when the queue doesn't report one
+const DefaultQueueHandleTime = 4 * time.Minute
+
+// QueueStatus is a snapshot of one call queue
+type QueueStatus struct {
+	Domain          string        `json:"domain"`
+	Queue           string        `json:"queue"`
+	Name            string        `json:"name"`
+	Waiting         int           `json:"waiting"`          // callers in the queue not yet answered
+	LongestWait     time.Duration `json:"longest_wait"`     // how long the oldest waiting caller has waited
+	AgentsLoggedIn  int           `json:"agents_logged_in"` // agents taking queue calls, busy or not
+	AgentsAvailable int           `json:"agents_available"` // logged-in agents not on a call
+	EstimatedWait   time.Duration `json:"estimated_wait"`   // for a caller joining now
+	FetchedAt       time.Time     `json:"fetched_at"`
+}
+
+// CallQueueService reads call queue status from the NetSapiens v2 API
+type CallQueueService struct {
+	client      *http.Client
+	baseURL     string
+	accessToken string
+	handleTime  time.Duration
+}
+
+// NewCallQueueService creates a call queue client. handleTime is the average call
+// length used for wait estimates (DefaultQueueHandleTime if zero).
+func NewCallQueueService(baseURL, accessToken string, handleTime time.Duration) *CallQueueService {
+	if handleTime <= 0 {
+		handleTime = DefaultQueueHandleTime
+	}
+	return &CallQueueService{
+		client:      &http.Client{Timeout: 10 * time.Second},
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		accessToken: accessToken,
+		handleTime:  handleTime,
+	}
+}
+
+// GetQueueStatus fetches the queue's waiting calls and agents and estimates the wait
+func (cqs *CallQueueService) GetQueueStatus(domain, queue string) (QueueStatus, error) {
+	if cqs.accessToken == "" {
+		return QueueStatus{}, fmt.Errorf("no NetSapiens access token configured")
+	}
+
+	status := QueueStatus{Domain: domain, Queue: queue, Name: queue, FetchedAt: time.Now()}
+	queuePath := fmt.Sprintf("/ns-api/v2/domains/%s/callqueues/%s", url.PathEscape(domain), url.PathEscape(queue))
+
+	var info map[string]interface{}
+	if err := netsapiensGet(cqs.client, cqs.baseURL, cqs.accessToken, queuePath, &info); err != nil {
+		return QueueStatus{}, err
+	}
+	if description := recordString(info, "description", "callqueue-description"); description != "" {
+		status.Name = description
+	}
+
+	var calls []map[string]interface{}
+	if err := netsapiensGet(cqs.client, cqs.baseURL, cqs.accessToken, queuePath+"/calls", &calls); err != nil {
+		return QueueStatus{}, err
+	}
+	for _, call := range calls {
+		// Calls already connected to an agent are listed until they end
+		if answered := recordString(call, "call-answered", "answered", "agent"); answered != "" && answered != "no" && answered != "0" {
+			continue
+		}
+		status.Waiting++
+		if started, ok := recordTime(call, "time-queued", "time-start", "time_start"); ok {
+			if wait := status.FetchedAt.Sub(started); wait > status.LongestWait {
+				status.LongestWait = wait
+			}
+		}
+	}
+
+	var agents []map[string]interface{}
+	if err := netsapiensGet(cqs.client, cqs.baseURL, cqs.accessToken, queuePath+"/agents", &agents); err != nil {
+		return QueueStatus{}, err
+	}
+	for _, agent := range agents {
+		switch strings.ToLower(recordString(agent, "callqueue-agent-status", "agent-status", "entry_status", "status")) {
+		case "available", "online", "idle":
+			status.AgentsLoggedIn++
+			if active, _ := strconv.Atoi(recordString(agent, "active-calls", "session_count")); active == 0 {
+				status.AgentsAvailable++
+			}
+		case "busy", "on-call", "oncall", "in-call":
+			status.AgentsLoggedIn++
+		}
+	}
+
+	status.EstimatedWait = EstimateQueueWait(status.Waiting, status.AgentsLoggedIn, status.AgentsAvailable, cqs.handleTime)
+	return status, nil
+}
+
+// EstimateQueueWait estimates how long a caller joining the queue now will wait:
+// free agents take the callers ahead first, then each round of handleTime serves
+// one caller per logged-in agent. Returns -1 when no agents are logged in.
+func EstimateQueueWait(waiting, loggedIn, available int, handleTime time.Duration) time.Duration {
+	if loggedIn == 0 {
+		return -1
+	}
+	ahead := waiting - available
+	if ahead < 0 {
+		return 0
+	}
+	rounds := ahead/loggedIn + 1
+	return time.Duration(rounds) * handleTime
+}
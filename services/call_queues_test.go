@@ -0,0 +1,61 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEstimateQueueWait(t *testing.T) {
+	tests := []struct {
+		waiting, loggedIn, available int
+		want                         time.Duration
+	}{
+		{0, 0, 0, -1},
+		{2, 3, 3, 0},
+		{3, 3, 3, 4 * time.Minute},
+		{5, 2, 0, 12 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := EstimateQueueWait(tt.waiting, tt.loggedIn, tt.available, 4*time.Minute); got != tt.want {
+			t.Errorf("EstimateQueueWait(%d, %d, %d) = %s, want %s", tt.waiting, tt.loggedIn, tt.available, got, tt.want)
+		}
+	}
+}
+
+func TestGetQueueStatus(t *testing.T) {
+	queued := time.Now().Add(-90 * time.Second).UTC().Format(time.RFC3339)
+	responses := map[string]interface{}{
+		"/ns-api/v2/domains/acme/callqueues/support": map[string]interface{}{"description": "Support"},
+		"/ns-api/v2/domains/acme/callqueues/support/calls": []map[string]interface{}{
+			{"time-queued": queued},
+			{"time-queued": queued, "agent": "101"},
+		},
+		"/ns-api/v2/domains/acme/callqueues/support/agents": []map[string]interface{}{
+			{"callqueue-agent-status": "available"},
+			{"callqueue-agent-status": "busy"},
+			{"callqueue-agent-status": "offline"},
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(responses[r.URL.Path])
+	}))
+	defer server.Close()
+
+	status, err := NewCallQueueService(server.URL, "token", 4*time.Minute).GetQueueStatus("acme", "support")
+	if err != nil {
+		t.Fatalf("GetQueueStatus: %v", err)
+	}
+	if status.Name != "Support" || status.Waiting != 1 || status.AgentsLoggedIn != 2 || status.AgentsAvailable != 1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if status.LongestWait < 80*time.Second || status.EstimatedWait != 4*time.Minute {
+		t.Fatalf("unexpected waits: longest %s, estimated %s", status.LongestWait, status.EstimatedWait)
+	}
+}
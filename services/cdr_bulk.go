@@ -0,0 +1,66 @@
+// services/cdr_bulk.go
+// Elasticsearch bulk export: writes CDRs as newline-delimited bulk actions, an index
+// action line followed by the CDR's document, ready to POST to a cluster's _bulk API
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"o-dan-go/models"
+	"strings"
+)
+
+// DefaultBulkIndex is the index bulk actions name when none is given
+const DefaultBulkIndex = "cdrs"
+
+// bulkIndexAction is the metadata line of one document
+type bulkIndexAction struct {
+	Index struct {
+		Index string `json:"_index"`
+		ID    string `json:"_id,omitempty"`
+	} `json:"index"`
+}
+
+// ValidateBulkIndex checks an index name against Elasticsearch's rules: lowercase, not
+// starting with -, _ or +, and none of \ / * ? " < > | , # : or spaces
+func ValidateBulkIndex(index string) error {
+	switch {
+	case index == "":
+		return fmt.Errorf("index name is required")
+	case len(index) > 255:
+		return fmt.Errorf("index name %q is longer than 255 bytes", index)
+	case index != strings.ToLower(index):
+		return fmt.Errorf("index name %q must be lowercase", index)
+	case strings.ContainsAny(index[:1], "-_+"), index == ".", index == "..":
+		return fmt.Errorf("index name %q can't start with -, _ or + or be . or ..", index)
+	case strings.ContainsAny(index, "\\/*?\"<>|,#: "):
+		return fmt.Errorf("index name %q can't contain \\ / * ? \" < > | , # : or spaces", index)
+	}
+	return nil
+}
+
+// WriteBulkActions writes an index action and document for each CDR into index. A CDR's
+// ID becomes its document ID so loading a session twice updates rather than duplicates
+// it. Documents are the raw CDRs, or the normalized ones for CDRViewNormalized.
+func WriteBulkActions(w io.Writer, cdrs []models.FlexibleCDR, index, view string) error {
+	encoder := json.NewEncoder(w)
+	for i := range cdrs {
+		var action bulkIndexAction
+		action.Index.Index = index
+		action.Index.ID = cdrs[i].GetID()
+		if err := encoder.Encode(action); err != nil {
+			return fmt.Errorf("failed to write bulk action %d: %w", i+1, err)
+		}
+
+		var document interface{} = cdrs[i].RawData
+		if view == CDRViewNormalized {
+			document = NormalizeCDR(cdrs[i])
+		}
+		if err := encoder.Encode(document); err != nil {
+			return fmt.Errorf("failed to write bulk document %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
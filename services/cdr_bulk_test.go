@@ -0,0 +1,44 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestWriteBulkActions(t *testing.T) {
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"id": "a", "call-direction": 1}},
+		{RawData: map[string]interface{}{"id": "b", "call-direction": 0}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteBulkActions(&buf, cdrs, "cdrs-acme", CDRViewNormalized); err != nil {
+		t.Fatalf("WriteBulkActions: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 4 || !strings.HasSuffix(buf.String(), "\n") {
+		t.Fatalf("got %d lines, want 4 ending in a newline:\n%s", len(lines), buf.String())
+	}
+
+	var action bulkIndexAction
+	if err := json.Unmarshal([]byte(lines[2]), &action); err != nil || action.Index.Index != "cdrs-acme" || action.Index.ID != "b" {
+		t.Errorf("second action = %s (%v)", lines[2], err)
+	}
+	var document NormalizedCDR
+	if err := json.Unmarshal([]byte(lines[3]), &document); err != nil || document.Direction != DirectionOutbound {
+		t.Errorf("second document = %s (%v)", lines[3], err)
+	}
+
+	for _, index := range []string{"", "CDRs", "_cdrs", "cdrs/2026", "cdrs acme"} {
+		if ValidateBulkIndex(index) == nil {
+			t.Errorf("ValidateBulkIndex(%q) accepted an invalid name", index)
+		}
+	}
+	if err := ValidateBulkIndex("cdrs-2026.10.16"); err != nil {
+		t.Errorf("ValidateBulkIndex rejected a valid name: %v", err)
+	}
+}
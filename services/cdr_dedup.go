@@ -0,0 +1,119 @@
+// services/cdr_dedup.go
+// Deduplication: when several endpoints (or one endpoint twice) return the same CDR,
+// which record is kept. By default the first to arrive wins; a search can instead keep
+// the record with the most fields or the one from the endpoint it trusts most.
+
+package services
+
+import (
+	"fmt"
+	"o-dan-go/models"
+	"strings"
+)
+
+// Deduplication strategies
+const (
+	DedupFirst    = "first"    // the record from the first endpoint queried
+	DedupRichest  = "richest"  // the record with the most non-null fields
+	DedupPriority = "priority" // the record from the endpoint earliest in the priority list
+)
+
+// ValidateDedup checks the criteria's deduplication strategy
+func (c *CDRSearchCriteria) ValidateDedup() error {
+	switch c.Dedup {
+	case "", DedupFirst, DedupRichest:
+	case DedupPriority:
+		if len(c.DedupPriority) == 0 {
+			return fmt.Errorf("the %s dedup strategy needs a dedup_priority list of endpoints", DedupPriority)
+		}
+	default:
+		return fmt.Errorf("unknown dedup strategy %q: use %s, %s or %s", c.Dedup, DedupFirst, DedupRichest, DedupPriority)
+	}
+	return nil
+}
+
+// deduplicateResult sets a result's CDRs to one record per CDR ID from the records
+// each endpoint returned, chosen by the criteria's strategy, and records which endpoint
+// won each CDR more than one record was found for. CDRs without an ID are dropped.
+func deduplicateResult(result *CDRDiscoveryResult) {
+	strategy := result.SearchCriteria.Dedup
+	if strategy == "" {
+		strategy = DedupFirst
+	}
+	result.DedupStrategy = strategy
+
+	// Endpoints in the order they were queried, then by priority when asked
+	order := make([]string, 0, len(result.EndpointResults))
+	for _, endpoint := range result.EndpointResults {
+		if _, ok := result.CDRsByEndpoint[endpoint.EndpointName]; ok {
+			order = append(order, endpoint.EndpointName)
+		}
+	}
+	rank := make(map[string]int, len(order))
+	for i, endpoint := range order {
+		rank[endpoint] = len(result.SearchCriteria.DedupPriority) + i
+	}
+	if strategy == DedupPriority {
+		for i, endpoint := range result.SearchCriteria.DedupPriority {
+			if _, ok := rank[strings.TrimSpace(endpoint)]; ok {
+				rank[strings.TrimSpace(endpoint)] = i
+			}
+		}
+	}
+
+	type candidate struct {
+		cdr      models.FlexibleCDR
+		endpoint string
+		fields   int
+		records  int
+	}
+	chosen := make(map[string]*candidate)
+	ids := []string{}
+	for _, endpoint := range order {
+		for _, cdr := range result.CDRsByEndpoint[endpoint] {
+			id := cdr.GetID()
+			if id == "" {
+				continue
+			}
+			fields := dedupFieldCount(&cdr)
+			current, ok := chosen[id]
+			if !ok {
+				chosen[id] = &candidate{cdr: cdr, endpoint: endpoint, fields: fields, records: 1}
+				ids = append(ids, id)
+				continue
+			}
+			current.records++
+			replace := false
+			switch strategy {
+			case DedupRichest:
+				replace = fields > current.fields
+			case DedupPriority:
+				replace = rank[endpoint] < rank[current.endpoint]
+			}
+			if replace {
+				current.cdr, current.endpoint, current.fields = cdr, endpoint, fields
+			}
+		}
+	}
+
+	result.AllCDRs = make([]models.FlexibleCDR, 0, len(ids))
+	result.DedupWinners = make(map[string]string)
+	for _, id := range ids {
+		result.AllCDRs = append(result.AllCDRs, chosen[id].cdr)
+		if chosen[id].records > 1 {
+			result.DedupWinners[id] = chosen[id].endpoint
+		}
+	}
+	result.UniqueCDRs = len(result.AllCDRs)
+}
+
+// dedupFieldCount counts a CDR's fields that have a value
+func dedupFieldCount(cdr *models.FlexibleCDR) int {
+	count := 0
+	for _, value := range cdr.RawData {
+		if value != nil && value != "" {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestDeduplicationStrategies(t *testing.T) {
+	cdr := func(fields map[string]interface{}) models.FlexibleCDR { return models.FlexibleCDR{RawData: fields} }
+	newResult := func(criteria CDRSearchCriteria) *CDRDiscoveryResult {
+		return &CDRDiscoveryResult{
+			SearchCriteria:  criteria,
+			EndpointResults: []EndpointResult{{EndpointName: "global_cdrs"}, {EndpointName: "domain_cdrs"}, {EndpointName: "user_cdrs"}},
+			CDRsByEndpoint: map[string][]models.FlexibleCDR{
+				"global_cdrs": {cdr(map[string]interface{}{"id": "a"}), cdr(map[string]interface{}{"id": "b"}), cdr(map[string]interface{}{"domain": "no id"})},
+				"domain_cdrs": {cdr(map[string]interface{}{"id": "a", "domain": "acme", "call-orig-user": "100"})},
+				"user_cdrs":   {cdr(map[string]interface{}{"id": "a", "domain": "acme", "call-term-user": nil})},
+			},
+		}
+	}
+
+	for _, test := range []struct {
+		criteria CDRSearchCriteria
+		winner   string
+		fields   int
+	}{
+		{CDRSearchCriteria{}, "global_cdrs", 1},
+		{CDRSearchCriteria{Dedup: DedupRichest}, "domain_cdrs", 3},
+		{CDRSearchCriteria{Dedup: DedupPriority, DedupPriority: []string{"user_cdrs", "domain_cdrs"}}, "user_cdrs", 3},
+	} {
+		result := newResult(test.criteria)
+		deduplicateResult(result)
+		if result.UniqueCDRs != 2 || result.AllCDRs[0].GetID() != "a" || result.AllCDRs[1].GetID() != "b" {
+			t.Fatalf("%+v kept %+v", test.criteria, result.AllCDRs)
+		}
+		if len(result.DedupWinners) != 1 || result.DedupWinners["a"] != test.winner || len(result.AllCDRs[0].RawData) != test.fields {
+			t.Errorf("%+v: winners %v, kept %v", test.criteria, result.DedupWinners, result.AllCDRs[0].RawData)
+		}
+	}
+
+	for _, criteria := range []CDRSearchCriteria{{Dedup: "last"}, {Dedup: DedupPriority}} {
+		if err := criteria.ValidateDedup(); err == nil {
+			t.Errorf("ValidateDedup accepted %+v", criteria)
+		}
+	}
+}
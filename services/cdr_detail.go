@@ -0,0 +1,160 @@
+// services/cdr_detail.go
+// CDR detail: everything known about one CDR of a session for the results page's detail
+// drawer - every raw field, which endpoints returned it, the other legs of its call and
+// what enrichment added to it
+
+package services
+
+import (
+	"o-dan-go/models"
+	"sort"
+	"time"
+)
+
+// CDRDetail is one CDR of a session with its provenance, correlations and enrichment
+type CDRDetail struct {
+	SessionID     string                 `json:"session_id"`
+	CDRID         string                 `json:"cdr_id"`
+	Fields        [][]string             `json:"fields,omitempty"` // field and value pairs, in the order the CDR had them
+	Raw           map[string]interface{} `json:"raw,omitempty"`
+	Normalized    *NormalizedCDR         `json:"normalized,omitempty"` // ?view=normalized, in place of fields and raw
+	CallClass     string                 `json:"call_class"`
+	Endpoints     []CDRProvenance        `json:"endpoints"`               // the endpoints that returned it, in query order
+	FieldSources  map[string]string      `json:"field_sources,omitempty"` // merged results: the endpoint of each field
+	CorrelationID string                 `json:"correlation_id,omitempty"`
+	Correlated    []CorrelatedCDR        `json:"correlated"` // the other legs of the call, in start order
+	Enrichment    CDREnrichment          `json:"enrichment"`
+	Notes         []SessionNote          `json:"notes"`
+	Flag          *CDRFlag               `json:"flag,omitempty"`
+}
+
+// CDRProvenance is one endpoint's record of a CDR
+type CDRProvenance struct {
+	Endpoint string `json:"endpoint"`
+	URL      string `json:"url"`
+	Fields   int    `json:"fields"`
+	Kept     bool   `json:"kept"` // this record is the one shown when several endpoints returned the CDR
+}
+
+// CorrelatedCDR is another leg of the same call
+type CorrelatedCDR struct {
+	CDRID     string     `json:"cdr_id"`
+	StartTime *time.Time `json:"start_time,omitempty"`
+	Party     string     `json:"party"` // who the leg rang
+	Duration  int        `json:"duration"`
+	CallClass string     `json:"call_class"`
+}
+
+// CDREnrichment is what lookups added to a CDR
+type CDREnrichment struct {
+	Fields       map[string]interface{} `json:"fields"` // carrier, line type, LRN, ported and caller name of each number
+	OrigLocation *NumberLocation        `json:"orig_location,omitempty"`
+	TermLocation *NumberLocation        `json:"term_location,omitempty"`
+	SpamScore    *SpamScore             `json:"spam_score,omitempty"`
+}
+
+// NumberLocation is where a number's area code is
+type NumberLocation struct {
+	AreaCode    string `json:"area_code"`
+	City        string `json:"city"`
+	State       string `json:"state"`
+	Approximate bool   `json:"approximate,omitempty"` // placed at the state's principal city
+}
+
+// enrichedFieldSuffixes are the fields enrichment adds after "orig" or "term"
+var enrichedFieldSuffixes = []string{
+	enrichedCarrierField, enrichedLineTypeField, enrichedLRNField, enrichedPortedField, enrichedCallerNameField,
+}
+
+// BuildCDRDetail describes a CDR of a result, with phone numbers masked; false if the
+// result has no CDR with the ID. Locations come from the unmasked numbers' area codes.
+func BuildCDRDetail(result *CDRDiscoveryResult, cdrID string, policy *MaskingPolicy, mode MaskingMode) (*CDRDetail, bool) {
+	var cdr *models.FlexibleCDR
+	for i := range result.AllCDRs {
+		if result.AllCDRs[i].GetID() == cdrID {
+			cdr = &result.AllCDRs[i]
+			break
+		}
+	}
+	if cdr == nil {
+		return nil, false
+	}
+
+	masked := policy.MaskCDR(*cdr, mode)
+	detail := &CDRDetail{
+		SessionID:    result.SessionID,
+		CDRID:        cdrID,
+		Fields:       masked.ToKeyValuePairs(),
+		Raw:          masked.RawData,
+		CallClass:    ClassifyCall(*cdr),
+		Endpoints:    []CDRProvenance{},
+		FieldSources: result.FieldSources[cdrID],
+		Correlated:   []CorrelatedCDR{},
+		Enrichment:   CDREnrichment{Fields: map[string]interface{}{}},
+		Notes:        []SessionNote{},
+	}
+
+	winner, deduplicated := result.DedupWinners[cdrID]
+	for _, endpoint := range result.EndpointResults {
+		for i := range result.CDRsByEndpoint[endpoint.EndpointName] {
+			record := &result.CDRsByEndpoint[endpoint.EndpointName][i]
+			if record.GetID() != cdrID {
+				continue
+			}
+			detail.Endpoints = append(detail.Endpoints, CDRProvenance{
+				Endpoint: endpoint.EndpointName,
+				URL:      policy.MaskURL(endpoint.URL, mode),
+				Fields:   len(record.RawData),
+				Kept:     !deduplicated || winner == endpoint.EndpointName,
+			})
+			break
+		}
+	}
+
+	if detail.CorrelationID = firstCDRString(*cdr, transferCorrelationFields...); detail.CorrelationID != "" {
+		for i := range result.AllCDRs {
+			leg := &result.AllCDRs[i]
+			if leg.GetID() == cdrID || firstCDRString(*leg, transferCorrelationFields...) != detail.CorrelationID {
+				continue
+			}
+			correlated := CorrelatedCDR{
+				CDRID:     leg.GetID(),
+				Party:     transferParty(policy.MaskCDR(*leg, mode)),
+				Duration:  leg.GetCallDuration(),
+				CallClass: ClassifyCall(*leg),
+			}
+			if started, err := leg.GetCallStartTime(); err == nil {
+				correlated.StartTime = &started
+			}
+			detail.Correlated = append(detail.Correlated, correlated)
+		}
+		sort.SliceStable(detail.Correlated, func(i, j int) bool {
+			a, b := detail.Correlated[i].StartTime, detail.Correlated[j].StartTime
+			return a != nil && (b == nil || a.Before(*b))
+		})
+	}
+
+	for _, side := range []string{"orig", "term"} {
+		for _, suffix := range enrichedFieldSuffixes {
+			if value, ok := masked.RawData[side+suffix]; ok {
+				detail.Enrichment.Fields[side+suffix] = value
+			}
+		}
+		number, _ := filterFieldValue(cdr, side+"_number")
+		areaCode := ExtractAreaCode(number)
+		if areaCode == "" {
+			continue
+		}
+		location, match, _ := GlobalAreaCodes.Resolve(areaCode)
+		if match == AreaCodeUnknown {
+			continue
+		}
+		numberLocation := &NumberLocation{AreaCode: areaCode, City: location.City, State: location.State, Approximate: match == AreaCodeGuessed}
+		if side == "orig" {
+			detail.Enrichment.OrigLocation = numberLocation
+		} else {
+			detail.Enrichment.TermLocation = numberLocation
+		}
+	}
+	return detail, true
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestBuildCDRDetail(t *testing.T) {
+	leg := func(id, endpoint string, fields map[string]interface{}) models.FlexibleCDR {
+		raw := map[string]interface{}{"id": id, "call-correlation-id": "corr-1", "call-term-user": endpoint}
+		for field, value := range fields {
+			raw[field] = value
+		}
+		return models.FlexibleCDR{RawData: raw, DetectedFields: []string{"id", "call-correlation-id"}}
+	}
+	kept := leg("a", "100", map[string]interface{}{"call-orig-caller-id": "2125551234", "orig-carrier": "Verizon"})
+	result := &CDRDiscoveryResult{
+		SessionID: "s1",
+		AllCDRs: []models.FlexibleCDR{
+			kept,
+			leg("b", "200", map[string]interface{}{"call-start-datetime": "2026-10-12T10:00:30Z"}),
+			{RawData: map[string]interface{}{"id": "c"}},
+		},
+		EndpointResults: []EndpointResult{{EndpointName: "primary", URL: "https://pbx/cdrs"}, {EndpointName: "backup", URL: "https://backup/cdrs"}},
+		CDRsByEndpoint: map[string][]models.FlexibleCDR{
+			"primary": {kept},
+			"backup":  {{RawData: map[string]interface{}{"id": "a"}}},
+		},
+		DedupWinners: map[string]string{"a": "primary"},
+	}
+
+	if _, found := BuildCDRDetail(result, "missing", &MaskingPolicy{}, MaskingNone); found {
+		t.Error("found a CDR the session doesn't have")
+	}
+
+	detail, found := BuildCDRDetail(result, "a", &MaskingPolicy{}, MaskingTruncate)
+	if !found {
+		t.Fatal("CDR a not found")
+	}
+	if len(detail.Fields) != 2 || detail.Raw["call-orig-caller-id"] != "******1234" {
+		t.Errorf("fields = %v, caller ID %v; want 2 fields and a masked caller ID", detail.Fields, detail.Raw["call-orig-caller-id"])
+	}
+	if len(detail.Endpoints) != 2 || !detail.Endpoints[0].Kept || detail.Endpoints[1].Kept {
+		t.Errorf("endpoints = %+v, want primary kept and backup not", detail.Endpoints)
+	}
+	if detail.CorrelationID != "corr-1" || len(detail.Correlated) != 1 || detail.Correlated[0].CDRID != "b" || detail.Correlated[0].Party != "200" {
+		t.Errorf("correlated = %q %+v, want leg b to 200", detail.CorrelationID, detail.Correlated)
+	}
+	if detail.Enrichment.Fields["orig-carrier"] != "Verizon" {
+		t.Errorf("enrichment fields = %v", detail.Enrichment.Fields)
+	}
+	if location := detail.Enrichment.OrigLocation; location == nil || location.AreaCode != "212" {
+		t.Errorf("orig location = %+v, want area code 212", location)
+	}
+}
@@ -10,10 +10,28 @@ import (
 	"net/http"
 	"net/url"
 	"o-dan-go/models"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"time" // add for console logging
 )
 
+// Endpoints that answered every one of at least endpointDeniedMinQueries queries on a base
+// URL with 403 or 404 are skipped there, and tried again once endpointDeniedRetryAfter
+// has passed since they were last queried
+const (
+	endpointDeniedMinQueries = 3
+	endpointDeniedRetryAfter = 24 * time.Hour
+)
+
+// debugLoggingDisabled turns off console logging for every discovery service at runtime
+var debugLoggingDisabled atomic.Bool
+
+// SetDebugLogging enables or disables discovery console logging (hot-reloadable)
+func SetDebugLogging(enabled bool) {
+	debugLoggingDisabled.Store(!enabled)
+}
+
 // CDRDiscoveryService handles comprehensive CDR discovery across multiple endpoints
 type CDRDiscoveryService struct {
 	client      *http.Client
@@ -37,6 +55,11 @@ type CDRSearchCriteria struct {
 	OriginatingNumber string     `json:"originating_number"`
 	TerminatingNumber string     `json:"terminating_number"`
 	AnyPhoneNumber    string     `json:"any_phone_number"`
+	Dedup             string     `json:"dedup,omitempty"`               // first (default), richest or priority
+	DedupPriority     []string   `json:"dedup_priority,omitempty"`      // endpoints, most trusted first, for priority
+	QueryAllEndpoints bool       `json:"query_all_endpoints,omitempty"` // ignore endpoint history: query every endpoint, in the default order
+	CountFirst        bool       `json:"count_first,omitempty"`         // count what each endpoint matches before fetching, to plan the pull
+	AllowLargePull    bool       `json:"allow_large_pull,omitempty"`    // let a count-first search fetch a million CDRs or more
 }
 
 // CDRDiscoveryResult - comprehensive result from all endpoints
@@ -51,6 +74,19 @@ type CDRDiscoveryResult struct {
 	AllCDRs         []models.FlexibleCDR            `json:"all_cdrs"`
 	CDRsByEndpoint  map[string][]models.FlexibleCDR `json:"cdrs_by_endpoint"`
 	Errors          []string                        `json:"errors,omitempty"`
+	RerunOf         string                          `json:"rerun_of,omitempty"` // session whose criteria were repeated
+	Quality         *DataQuality                    `json:"quality,omitempty"`
+	DedupStrategy   string                          `json:"dedup_strategy,omitempty"`
+	DedupWinners    map[string]string               `json:"dedup_winners,omitempty"` // CDR ID to the endpoint whose record was kept, for CDRs found more than once
+	FieldSources    map[string]map[string]string    `json:"field_sources,omitempty"` // merged results: CDR ID to the endpoint of each field
+	Skipped         []SkippedEndpoint               `json:"skipped_endpoints,omitempty"`
+	Plan            *QueryPlan                      `json:"plan,omitempty"` // count-first searches
+}
+
+// SkippedEndpoint is an endpoint a search didn't query because of its history
+type SkippedEndpoint struct {
+	EndpointName string `json:"endpoint_name"`
+	Reason       string `json:"reason"`
 }
 
 // EndpointResult - result from individual endpoint query
@@ -65,6 +101,7 @@ type EndpointResult struct {
 	CDRs           []models.FlexibleCDR `json:"cdrs,omitempty"`
 	RawDataUsed    bool                 `json:"raw_data_used"`   // Indicates if raw=yes was used
 	DiscoveredData bool                 `json:"discovered_data"` //
+	Pages          int                  `json:"pages,omitempty"` // requests a planned pull was split into
 }
 
 // CDREndpointConfig - configuration for each CDR endpoint
@@ -89,7 +126,7 @@ func NewCDRDiscoveryService(baseURL, accessToken string) *CDRDiscoveryService {
 
 // console logging helper method
 func (cds *CDRDiscoveryService) logDebug(format string, args ...interface{}) {
-	if cds.debug {
+	if cds.debug && !debugLoggingDisabled.Load() {
 		log.Printf("[CDR Discovery] "+format, args...)
 	}
 }
@@ -186,19 +223,56 @@ func (cds *CDRDiscoveryService) GetComprehensiveCDRs(criteria CDRSearchCriteria)
 	}
 
 	// Determine which endpoints to query based on available criteria
-	endpointsToQuery := cds.selectEndpointsToQuery(criteria)
+	endpointsToQuery, skipped := cds.selectEndpointsToQuery(criteria)
+	result.Skipped = skipped
 	// logging:
 	cds.logDebug("Endpoints selected for query: %d", len(endpointsToQuery))
 	for _, ep := range endpointsToQuery {
 		cds.logDebug("  - %s: %s", ep.Name, ep.Description)
 	}
+	for _, skip := range skipped {
+		cds.logDebug("  - skipped %s: %s", skip.EndpointName, skip.Reason)
+	}
+
+	// Count first: leave out endpoints with nothing to return and plan the pages of the rest
+	if criteria.CountFirst {
+		result.Plan = cds.planQueries(endpointsToQuery, criteria)
+		planned := []CDREndpointConfig{}
+		for _, endpoint := range endpointsToQuery {
+			estimate := result.Plan.estimate(endpoint.Name)
+			cds.logDebug("Estimate for %s: %d CDRs (counted: %t), %d pages of %d", endpoint.Name, estimate.Count, estimate.Counted, estimate.Pages, estimate.PageSize)
+			if estimate.Counted && estimate.Count == 0 {
+				result.Skipped = append(result.Skipped, SkippedEndpoint{EndpointName: endpoint.Name, Reason: "counted no matching CDRs"})
+				continue
+			}
+			planned = append(planned, endpoint)
+		}
+		endpointsToQuery = planned
+
+		if result.Plan.PlannedCDRs >= planLargePull && !criteria.AllowLargePull {
+			result.EndTime = time.Now()
+			return result, fmt.Errorf("%w: this search would fetch about %d CDRs; narrow it, lower the limit or allow a large pull",
+				ErrLargePull, result.Plan.PlannedCDRs)
+		}
+	}
+
+	// Make the session visible to admin introspection while it runs
+	GlobalDiscoveryTracker.Start(sessionID, cds.baseURL, criteria, len(endpointsToQuery))
+	defer GlobalDiscoveryTracker.Finish(sessionID)
 
 	// Query each relevant endpoint
 	for _, endpointConfig := range endpointsToQuery {
 		cds.logDebug("\n--- Querying endpoint: %s ---", endpointConfig.Name) // logging to console
 
-		endpointResult := cds.queryEndpoint(endpointConfig, criteria)
+		GlobalDiscoveryTracker.BeginEndpoint(sessionID, endpointConfig.Name)
+		var endpointResult EndpointResult
+		if estimate := result.Plan.estimate(endpointConfig.Name); estimate != nil && estimate.Pages > 1 {
+			endpointResult = cds.queryEndpointPages(endpointConfig, criteria, estimate)
+		} else {
+			endpointResult = cds.queryEndpoint(endpointConfig, criteria)
+		}
 		result.EndpointResults = append(result.EndpointResults, endpointResult)
+		GlobalDiscoveryTracker.CompleteEndpoint(sessionID, endpointResult.RecordCount)
 
 		// logging block:
 		if endpointResult.Success {
@@ -232,14 +306,17 @@ func (cds *CDRDiscoveryService) GetComprehensiveCDRs(criteria CDRSearchCriteria)
 		}
 	}
 
+	// Remember how each endpoint answered these parameters
+	GlobalDiscoveryAnalytics.Record(cds.baseURL, criteria, result.EndpointResults)
+
 	// logging duplication:
 	cds.logDebug("\n--- Deduplication ---")
 	cds.logDebug("Total CDRs before deduplication: %d", len(result.AllCDRs))
 
-	// Deduplicate CDRs by ID
-	result.AllCDRs = cds.deduplicateCDRs(result.AllCDRs)
-	result.UniqueCDRs = len(result.AllCDRs)
+	// Deduplicate CDRs by ID, keeping the record the search's strategy prefers
+	deduplicateResult(result)
 	result.TotalCDRs = cds.countTotalCDRs(result.CDRsByEndpoint)
+	result.Quality = AssessDataQuality(result)
 	result.EndTime = time.Now()
 
 	// console logging:
@@ -264,8 +341,10 @@ func (cds *CDRDiscoveryService) GetComprehensiveCDRs(criteria CDRSearchCriteria)
 	return result, nil
 }
 
-// selectEndpointsToQuery determines which endpoints to query based on criteria
-func (cds *CDRDiscoveryService) selectEndpointsToQuery(criteria CDRSearchCriteria) []CDREndpointConfig {
+// selectEndpointsToQuery determines which endpoints to query based on criteria, then
+// adapts them to how they have answered earlier searches on this base URL unless the
+// criteria ask for every endpoint
+func (cds *CDRDiscoveryService) selectEndpointsToQuery(criteria CDRSearchCriteria) ([]CDREndpointConfig, []SkippedEndpoint) {
 	endpoints := cds.GetSupportedEndpoints()
 	var selected []CDREndpointConfig
 
@@ -291,7 +370,48 @@ func (cds *CDRDiscoveryService) selectEndpointsToQuery(criteria CDRSearchCriteri
 		}
 	}
 
-	return selected
+	if criteria.QueryAllEndpoints {
+		return selected, nil
+	}
+	histories, err := GlobalDiscoveryAnalytics.EndpointHistories(cds.baseURL)
+	if err != nil {
+		log.Printf("[CDR Discovery] Querying every endpoint, endpoint history unavailable: %v", err)
+		return selected, nil
+	}
+	return adaptEndpoints(selected, histories, time.Now())
+}
+
+// adaptEndpoints leaves out the endpoints that have been denied on every recent query and
+// orders the rest by the CDRs they have returned per query, most first; endpoints without
+// history keep their place among those that have returned nothing. If every endpoint would
+// be skipped, they are all queried so the search still reports why it found nothing.
+func adaptEndpoints(selected []CDREndpointConfig, histories map[string]EndpointHistory, now time.Time) ([]CDREndpointConfig, []SkippedEndpoint) {
+	if len(histories) == 0 {
+		return selected, nil
+	}
+
+	kept := []CDREndpointConfig{}
+	var skipped []SkippedEndpoint
+	for _, endpoint := range selected {
+		history := histories[endpoint.Name]
+		if history.Queries >= endpointDeniedMinQueries && history.Denied == history.Queries &&
+			now.Sub(history.LastQueriedAt) < endpointDeniedRetryAfter {
+			skipped = append(skipped, SkippedEndpoint{
+				EndpointName: endpoint.Name,
+				Reason:       fmt.Sprintf("answered 403 or 404 to all %d queries on this PBX", history.Queries),
+			})
+			continue
+		}
+		kept = append(kept, endpoint)
+	}
+	if len(kept) == 0 {
+		kept, skipped = append(kept, selected...), nil
+	}
+
+	sort.SliceStable(kept, func(i, j int) bool {
+		return histories[kept[i].Name].Yield() > histories[kept[j].Name].Yield()
+	})
+	return kept, skipped
 }
 
 // hasRequiredParams checks if criteria contains required parameters for endpoint
@@ -509,22 +629,6 @@ func (cds *CDRDiscoveryService) convertMapToFlexibleCDR(data map[string]interfac
 	return cdr, err
 }
 
-// deduplicateCDRs removes duplicate CDRs based on ID
-func (cds *CDRDiscoveryService) deduplicateCDRs(cdrs []models.FlexibleCDR) []models.FlexibleCDR {
-	seen := make(map[string]bool)
-	var unique []models.FlexibleCDR
-
-	for _, cdr := range cdrs {
-		id := cdr.GetID()
-		if id != "" && !seen[id] {
-			seen[id] = true
-			unique = append(unique, cdr)
-		}
-	}
-
-	return unique
-}
-
 // countTotalCDRs counts total CDRs across all endpoints
 func (cds *CDRDiscoveryService) countTotalCDRs(cdrsByEndpoint map[string][]models.FlexibleCDR) int {
 	total := 0
@@ -0,0 +1,492 @@
+// services/cdr_filter.go
+// CDR filter expressions: a small language for picking CDRs by their fields, e.g.
+// `domain == "acme.com" && duration > 60 && direction == 1`. Filters narrow the
+// results API and exports, reports and the CDRs an alert rule measures.
+
+package services
+
+import (
+	"fmt"
+	"o-dan-go/models"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// maxCDRFilterLength keeps filters from query strings to a sensible size
+const maxCDRFilterLength = 1000
+
+// cdrFilterFields are the names filters use for common CDR fields, matching the export
+// columns. Any other name is read from the CDR as is, first with underscores turned
+// into hyphens (orig_lrn reads orig-lrn) and then exactly as written.
+var cdrFilterFields = map[string]func(cdr *models.FlexibleCDR) string{
+	"id":          func(cdr *models.FlexibleCDR) string { return cdr.GetID() },
+	"call_id":     func(cdr *models.FlexibleCDR) string { return cdr.GetID() },
+	"domain":      func(cdr *models.FlexibleCDR) string { return cdr.GetDomain() },
+	"direction":   func(cdr *models.FlexibleCDR) string { return cdrFilterFirst(cdr, "call-direction", "direction") },
+	"duration":    func(cdr *models.FlexibleCDR) string { return strconv.Itoa(cdr.GetCallDuration()) },
+	"start_time":  func(cdr *models.FlexibleCDR) string { return cdrFilterFirst(cdr, "call-start-datetime", "start-time") },
+	"end_time":    func(cdr *models.FlexibleCDR) string { return cdrFilterFirst(cdr, "call-end-datetime", "end-time") },
+	"user":        func(cdr *models.FlexibleCDR) string { return cdrFilterFirst(cdr, "user", "call-orig-user") },
+	"orig_user":   func(cdr *models.FlexibleCDR) string { return cdr.GetOrigUser() },
+	"term_user":   func(cdr *models.FlexibleCDR) string { return cdr.GetTermUser() },
+	"orig_number": func(cdr *models.FlexibleCDR) string { return cdrFilterFirst(cdr, "call-orig-caller-id", "orig-number") },
+	"term_number": func(cdr *models.FlexibleCDR) string { return cdrFilterFirst(cdr, "call-term-caller-id", "term-number") },
+	"call_type":   func(cdr *models.FlexibleCDR) string { return cdr.GetString("call-type") },
+	"disposition": func(cdr *models.FlexibleCDR) string {
+		return cdrFilterFirst(cdr, "call-disconnect-reason-text", "disposition")
+	},
+	"call_class": func(cdr *models.FlexibleCDR) string {
+		if class := cdr.GetString("call_class"); class != "" {
+			return class // as stored with the CDR's summary
+		}
+		return ClassifyCall(*cdr)
+	},
+}
+
+// cdrFilterFirst returns the first of fields the CDR has
+func cdrFilterFirst(cdr *models.FlexibleCDR, fields ...string) string {
+	for _, field := range fields {
+		if value := cdrFilterString(cdr, field); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// cdrFilterString reads a field as text, writing JSON numbers out in full so phone
+// numbers compare as the digits they are
+func cdrFilterString(cdr *models.FlexibleCDR, field string) string {
+	if number, ok := cdr.GetRaw(field).(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return cdr.GetString(field)
+}
+
+// CDRFilter is a parsed filter expression
+type CDRFilter struct {
+	expr string
+	root cdrFilterNode
+}
+
+// ParseCDRFilter parses a filter expression. Comparisons are field OP value, where OP is
+// ==, !=, <, <=, >, >= or contains, or field in [value, ...]. Values are "strings",
+// numbers, true or false. Comparisons combine with && (and), || (or), ! (not) and
+// parentheses. An empty expression is a nil filter, which matches every CDR.
+func ParseCDRFilter(expr string) (*CDRFilter, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, nil
+	}
+	if len(expr) > maxCDRFilterLength {
+		return nil, fmt.Errorf("filter is longer than %d characters", maxCDRFilterLength)
+	}
+	tokens, err := lexCDRFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &cdrFilterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != filterTokenEnd {
+		return nil, fmt.Errorf("filter: unexpected %q at position %d", next.text, next.pos+1)
+	}
+	return &CDRFilter{expr: expr, root: root}, nil
+}
+
+// String returns the expression the filter was parsed from
+func (f *CDRFilter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expr
+}
+
+// Match reports whether a CDR passes the filter; a nil filter passes every CDR
+func (f *CDRFilter) Match(cdr *models.FlexibleCDR) bool {
+	return f == nil || f.root.match(cdr)
+}
+
+// Apply returns the CDRs that pass the filter, or cdrs itself for a nil filter
+func (f *CDRFilter) Apply(cdrs []models.FlexibleCDR) []models.FlexibleCDR {
+	if f == nil {
+		return cdrs
+	}
+	matched := []models.FlexibleCDR{}
+	for i := range cdrs {
+		if f.root.match(&cdrs[i]) {
+			matched = append(matched, cdrs[i])
+		}
+	}
+	return matched
+}
+
+// cdrFilterNode is a node of a parsed filter
+type cdrFilterNode interface {
+	match(cdr *models.FlexibleCDR) bool
+}
+
+type filterAnd struct{ left, right cdrFilterNode }
+
+func (n filterAnd) match(cdr *models.FlexibleCDR) bool {
+	return n.left.match(cdr) && n.right.match(cdr)
+}
+
+type filterOr struct{ left, right cdrFilterNode }
+
+func (n filterOr) match(cdr *models.FlexibleCDR) bool { return n.left.match(cdr) || n.right.match(cdr) }
+
+type filterNot struct{ operand cdrFilterNode }
+
+func (n filterNot) match(cdr *models.FlexibleCDR) bool { return !n.operand.match(cdr) }
+
+// filterValue is a literal in a filter
+type filterValue struct {
+	text   string
+	number *float64
+	flag   *bool
+}
+
+// filterComparison compares a field with one value, or with a list for in
+type filterComparison struct {
+	field  string
+	op     string
+	values []filterValue
+}
+
+func (n filterComparison) match(cdr *models.FlexibleCDR) bool {
+	actual, present := filterFieldValue(cdr, n.field)
+	switch n.op {
+	case "in":
+		for _, value := range n.values {
+			if cmp, ok := compareFilterValue(actual, present, value); ok && cmp == 0 {
+				return true
+			}
+		}
+		return false
+	case "contains":
+		return present && strings.Contains(strings.ToLower(actual), strings.ToLower(n.values[0].text))
+	}
+
+	cmp, ok := compareFilterValue(actual, present, n.values[0])
+	if !ok {
+		return n.op == "!=" // a missing or incomparable field differs from everything
+	}
+	switch n.op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default: // >=
+		return cmp >= 0
+	}
+}
+
+// filterFieldValue reads a field for comparison
+func filterFieldValue(cdr *models.FlexibleCDR, field string) (string, bool) {
+	if read, ok := cdrFilterFields[field]; ok {
+		value := read(cdr)
+		return value, value != ""
+	}
+	for _, name := range []string{strings.ReplaceAll(field, "_", "-"), field} {
+		if cdr.HasField(name) && cdr.GetRaw(name) != nil {
+			return cdrFilterString(cdr, name), true
+		}
+	}
+	return "", false
+}
+
+// compareFilterValue orders a field's value against a literal: numerically for numbers,
+// as times when both sides are dates or times, otherwise as text ignoring case. ok is
+// false when the field is missing or the two can't be compared.
+func compareFilterValue(actual string, present bool, value filterValue) (int, bool) {
+	if !present {
+		return 0, false
+	}
+	switch {
+	case value.number != nil:
+		number, err := strconv.ParseFloat(strings.TrimSpace(actual), 64)
+		if err != nil {
+			return 0, false
+		}
+		return compareFloats(number, *value.number), true
+	case value.flag != nil:
+		truthy := actual == "true" || actual == "1" || actual == "yes"
+		if truthy == *value.flag {
+			return 0, true
+		}
+		return 1, true
+	}
+	if actualTime, ok := parseFilterTime(actual); ok {
+		if valueTime, ok := parseFilterTime(value.text); ok {
+			return actualTime.Compare(valueTime), true
+		}
+	}
+	return strings.Compare(strings.ToLower(actual), strings.ToLower(value.text)), true
+}
+
+// compareFloats orders two numbers
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// filterTimeLayouts are the CDR time formats plus plain dates for filter values
+var filterTimeLayouts = []string{"2006-01-02T15:04:05Z[MST]", time.RFC3339, "2006-01-02T15:04:05Z", "2006-01-02 15:04:05", "2006-01-02"}
+
+// parseFilterTime parses a date or time, in UTC when it has no zone
+func parseFilterTime(value string) (time.Time, bool) {
+	for _, layout := range filterTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Filter tokens
+const (
+	filterTokenEnd = iota
+	filterTokenIdent
+	filterTokenString
+	filterTokenNumber
+	filterTokenOp // comparison and boolean operators, parentheses, brackets and commas
+)
+
+type filterToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+// filterWordOps are the operators that can be written as words
+var filterWordOps = map[string]string{"and": "&&", "or": "||", "not": "!", "contains": "contains", "in": "in"}
+
+// lexCDRFilter splits an expression into tokens
+func lexCDRFilter(expr string) ([]filterToken, error) {
+	tokens := []filterToken{}
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			start := i
+			var text strings.Builder
+			for i++; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				text.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("filter: unterminated string at position %d", start+1)
+			}
+			i++
+			tokens = append(tokens, filterToken{kind: filterTokenString, text: text.String(), pos: start})
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i++; i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.'); i++ {
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenNumber, text: string(runes[start:i]), pos: start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i++; i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || strings.ContainsRune("_-.", runes[i])); i++ {
+			}
+			word := string(runes[start:i])
+			if op, ok := filterWordOps[strings.ToLower(word)]; ok {
+				tokens = append(tokens, filterToken{kind: filterTokenOp, text: op, pos: start})
+			} else {
+				tokens = append(tokens, filterToken{kind: filterTokenIdent, text: word, pos: start})
+			}
+		default:
+			start := i
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch {
+			case two == "==" || two == "!=" || two == "<=" || two == ">=" || two == "&&" || two == "||":
+				tokens = append(tokens, filterToken{kind: filterTokenOp, text: two, pos: start})
+				i += 2
+			case strings.ContainsRune("<>!()[],", r):
+				tokens = append(tokens, filterToken{kind: filterTokenOp, text: string(r), pos: start})
+				i++
+			case r == '=':
+				return nil, fmt.Errorf("filter: use == to compare at position %d", start+1)
+			default:
+				return nil, fmt.Errorf("filter: unexpected %q at position %d", string(r), start+1)
+			}
+		}
+	}
+	return append(tokens, filterToken{kind: filterTokenEnd, text: "end of filter", pos: len(runes)}), nil
+}
+
+// cdrFilterParser parses tokens by recursive descent: || binds loosest, then &&, then !
+type cdrFilterParser struct {
+	tokens []filterToken
+	next   int
+}
+
+func (p *cdrFilterParser) peek() filterToken { return p.tokens[p.next] }
+
+func (p *cdrFilterParser) take() filterToken {
+	token := p.tokens[p.next]
+	if token.kind != filterTokenEnd {
+		p.next++
+	}
+	return token
+}
+
+// isOp reports whether the next token is the operator op
+func (p *cdrFilterParser) isOp(op string) bool {
+	token := p.peek()
+	return token.kind == filterTokenOp && token.text == op
+}
+
+func (p *cdrFilterParser) parseOr() (cdrFilterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.take()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *cdrFilterParser) parseAnd() (cdrFilterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.take()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *cdrFilterParser) parseUnary() (cdrFilterNode, error) {
+	if p.isOp("!") {
+		p.take()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{operand}, nil
+	}
+	if p.isOp("(") {
+		p.take()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, p.expected(")")
+		}
+		p.take()
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *cdrFilterParser) parseComparison() (cdrFilterNode, error) {
+	field := p.peek()
+	if field.kind != filterTokenIdent {
+		return nil, p.expected("a field name")
+	}
+	p.take()
+
+	op := p.peek()
+	if op.kind != filterTokenOp {
+		return nil, p.expected("a comparison after " + field.text)
+	}
+	switch op.text {
+	case "==", "!=", "<", "<=", ">", ">=", "contains":
+		p.take()
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return filterComparison{field: field.text, op: op.text, values: []filterValue{value}}, nil
+	case "in":
+		p.take()
+		if !p.isOp("[") {
+			return nil, p.expected("[ after in")
+		}
+		p.take()
+		values := []filterValue{}
+		for {
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			if !p.isOp(",") {
+				break
+			}
+			p.take()
+		}
+		if !p.isOp("]") {
+			return nil, p.expected("]")
+		}
+		p.take()
+		return filterComparison{field: field.text, op: "in", values: values}, nil
+	}
+	return nil, p.expected("a comparison after " + field.text)
+}
+
+func (p *cdrFilterParser) parseValue() (filterValue, error) {
+	token := p.peek()
+	switch token.kind {
+	case filterTokenString:
+		p.take()
+		return filterValue{text: token.text}, nil
+	case filterTokenNumber:
+		number, err := strconv.ParseFloat(token.text, 64)
+		if err != nil {
+			return filterValue{}, fmt.Errorf("filter: invalid number %q at position %d", token.text, token.pos+1)
+		}
+		p.take()
+		return filterValue{text: token.text, number: &number}, nil
+	case filterTokenIdent:
+		if lower := strings.ToLower(token.text); lower == "true" || lower == "false" {
+			flag := lower == "true"
+			p.take()
+			return filterValue{text: lower, flag: &flag}, nil
+		}
+		return filterValue{}, fmt.Errorf("filter: %s at position %d needs quotes to be compared as text", token.text, token.pos+1)
+	}
+	return filterValue{}, p.expected("a value")
+}
+
+// expected describes what the parser wanted instead of the next token
+func (p *cdrFilterParser) expected(what string) error {
+	token := p.peek()
+	return fmt.Errorf("filter: expected %s at position %d, found %q", what, token.pos+1, token.text)
+}
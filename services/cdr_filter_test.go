@@ -0,0 +1,78 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestCDRFilter(t *testing.T) {
+	cdr := models.FlexibleCDR{RawData: map[string]interface{}{
+		"id":                          "cdr-1",
+		"domain":                      "Acme.com",
+		"call-direction":              float64(1),
+		"call-total-duration-seconds": float64(95),
+		"call-start-datetime":         "2024-03-14T10:30:00Z",
+		"call-orig-caller-id":         float64(15555551234),
+		"call-disconnect-reason-text": "Normal Clearing",
+		"orig-lrn":                    "15555550000",
+		"recorded":                    true,
+	}}
+
+	for expr, want := range map[string]bool{
+		`domain == "acme.com" && duration > 60 && direction == 1`: true,
+		`domain == "acme.com" and duration > 100`:                 false,
+		`duration > 100 || direction == 1`:                        true,
+		`!(direction == 2)`:                                       true,
+		`not direction in [0, 2]`:                                 true,
+		`orig_number == "15555551234"`:                            true,
+		`disposition contains 'normal'`:                           true,
+		`start_time >= "2024-03-14" && start_time < "2024-03-15"`: true,
+		`orig_lrn == "15555550000"`:                               true,
+		`recorded == true`:                                        true,
+		`missing_field == "x"`:                                    false,
+		`missing_field != "x"`:                                    true,
+		`call-direction == 1`:                                     true,
+	} {
+		filter, err := ParseCDRFilter(expr)
+		if err != nil {
+			t.Errorf("ParseCDRFilter(%s): %v", expr, err)
+			continue
+		}
+		if got := filter.Match(&cdr); got != want {
+			t.Errorf("%s matched %v, want %v", expr, got, want)
+		}
+	}
+
+	for expr, message := range map[string]string{
+		`domain = "acme.com"`:     "use ==",
+		`domain == acme`:          "needs quotes",
+		`(duration > 60`:          "expected )",
+		`duration > 60 direction`: "unexpected",
+		`domain == "acme`:         "unterminated string",
+		`direction in 1`:          "expected [",
+	} {
+		if _, err := ParseCDRFilter(expr); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("ParseCDRFilter(%s) = %v, want an error mentioning %q", expr, err, message)
+		}
+	}
+
+	// Alert rules count only the CDRs their filter matches
+	rule := AlertRule{Name: "long acme calls", Metric: RuleMetricUniqueCDRs, Operator: ">", Filter: "duration > 60"}
+	if err := rule.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	short := models.FlexibleCDR{RawData: map[string]interface{}{"id": "cdr-2", "call-total-duration-seconds": float64(5)}}
+	if value, _, err := sessionMetric(&rule, &CDRDiscoveryResult{AllCDRs: []models.FlexibleCDR{cdr, short}}); err != nil || value != 1 {
+		t.Errorf("filtered unique_cdrs = %v, %v; want 1", value, err)
+	}
+	rule.Metric = RuleMetricFailedEndpoints
+	if err := rule.Validate(); err == nil {
+		t.Error("validated a filter on an endpoint metric")
+	}
+
+	if filter, err := ParseCDRFilter("  "); filter != nil || err != nil || !filter.Match(&cdr) {
+		t.Errorf("an empty filter = %v, %v; want nil matching everything", filter, err)
+	}
+}
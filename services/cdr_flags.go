@@ -0,0 +1,114 @@
+// services/cdr_flags.go
+// CDR flags: CDRs of a session an analyst has marked as being of interest, so triage of
+// thousands of records for a dispute can come back to just those
+
+package services
+
+import (
+	"fmt"
+	"o-dan-go/models"
+	"strings"
+	"time"
+)
+
+// CDRFlag marks one CDR of a session
+type CDRFlag struct {
+	SessionID string    `json:"session_id"`
+	CDRID     string    `json:"cdr_id"`
+	FlaggedBy string    `json:"flagged_by"`
+	Reason    string    `json:"reason,omitempty"`
+	FlaggedAt time.Time `json:"flagged_at"`
+}
+
+// FlagCDR flags a CDR, or updates who flagged it and why if it already is
+func (ds *DatabaseService) FlagCDR(flag *CDRFlag) error {
+	flag.Reason = strings.TrimSpace(flag.Reason)
+	if len([]rune(flag.Reason)) > MaxNoteLength {
+		return fmt.Errorf("reason is longer than %d characters", MaxNoteLength)
+	}
+	if flag.FlaggedBy = strings.TrimSpace(flag.FlaggedBy); flag.FlaggedBy == "" {
+		flag.FlaggedBy = "anonymous"
+	}
+	flag.FlaggedAt = time.Now().UTC()
+
+	if _, err := ds.db.Exec(`
+	INSERT INTO cdr_flags (session_id, cdr_id, flagged_by, reason, flagged_at) VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT(session_id, cdr_id) DO UPDATE SET
+		flagged_by = excluded.flagged_by, reason = excluded.reason, flagged_at = excluded.flagged_at`,
+		flag.SessionID, flag.CDRID, flag.FlaggedBy, flag.Reason, flag.FlaggedAt); err != nil {
+		return fmt.Errorf("failed to flag CDR: %w", err)
+	}
+	return nil
+}
+
+// UnflagCDR clears a CDR's flag, reporting whether it was flagged
+func (ds *DatabaseService) UnflagCDR(sessionID, cdrID string) (bool, error) {
+	result, err := ds.db.Exec(`DELETE FROM cdr_flags WHERE session_id = ? AND cdr_id = ?`, sessionID, cdrID)
+	if err != nil {
+		return false, fmt.Errorf("failed to unflag CDR: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// GetCDRFlags returns a session's flags in the order they were made
+func (ds *DatabaseService) GetCDRFlags(sessionID string) ([]CDRFlag, error) {
+	rows, err := ds.db.Query(`
+	SELECT session_id, cdr_id, flagged_by, reason, flagged_at FROM cdr_flags
+	WHERE session_id = ? ORDER BY flagged_at, cdr_id`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query CDR flags: %w", err)
+	}
+	defer rows.Close()
+
+	flags := []CDRFlag{}
+	for rows.Next() {
+		var flag CDRFlag
+		if err := rows.Scan(&flag.SessionID, &flag.CDRID, &flag.FlaggedBy, &flag.Reason, &flag.FlaggedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan CDR flag: %w", err)
+		}
+		flags = append(flags, flag)
+	}
+	return flags, rows.Err()
+}
+
+// FlaggedCDRIDs returns the IDs of a session's flagged CDRs
+func (ds *DatabaseService) FlaggedCDRIDs(sessionID string) (map[string]bool, error) {
+	flags, err := ds.GetCDRFlags(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(flags))
+	for _, flag := range flags {
+		ids[flag.CDRID] = true
+	}
+	return ids, nil
+}
+
+// FlaggedOnly returns a copy of the result holding only the flagged CDRs, in every
+// endpoint's records as well as the combined ones
+func (r *CDRDiscoveryResult) FlaggedOnly(flagged map[string]bool) *CDRDiscoveryResult {
+	keep := func(cdrs []models.FlexibleCDR) []models.FlexibleCDR {
+		kept := []models.FlexibleCDR{}
+		for i := range cdrs {
+			if flagged[cdrs[i].GetID()] {
+				kept = append(kept, cdrs[i])
+			}
+		}
+		return kept
+	}
+
+	restricted := *r
+	restricted.AllCDRs = keep(r.AllCDRs)
+	restricted.UniqueCDRs = len(restricted.AllCDRs)
+	restricted.TotalCDRs = restricted.UniqueCDRs
+	if r.CDRsByEndpoint != nil {
+		restricted.TotalCDRs = 0
+		restricted.CDRsByEndpoint = make(map[string][]models.FlexibleCDR, len(r.CDRsByEndpoint))
+		for endpoint, cdrs := range r.CDRsByEndpoint {
+			restricted.CDRsByEndpoint[endpoint] = keep(cdrs)
+			restricted.TotalCDRs += len(restricted.CDRsByEndpoint[endpoint])
+		}
+	}
+	return &restricted
+}
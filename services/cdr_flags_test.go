@@ -0,0 +1,61 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestCDRFlags(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "flags.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	for _, flag := range []*CDRFlag{
+		{SessionID: "s1", CDRID: "a", FlaggedBy: "dana"},
+		{SessionID: "s1", CDRID: "b", Reason: "disputed"},
+		{SessionID: "s1", CDRID: "a", FlaggedBy: "sam", Reason: "billed twice"},
+		{SessionID: "s2", CDRID: "c"},
+	} {
+		if err := db.FlagCDR(flag); err != nil {
+			t.Fatalf("FlagCDR: %v", err)
+		}
+	}
+
+	flags, err := db.GetCDRFlags("s1")
+	if err != nil || len(flags) != 2 {
+		t.Fatalf("GetCDRFlags = %d flags, %v; want 2", len(flags), err)
+	}
+	// Flagging again updates the flag rather than adding one
+	if flags[1].CDRID != "a" || flags[1].FlaggedBy != "sam" || flags[1].Reason != "billed twice" {
+		t.Errorf("updated flag = %+v", flags[1])
+	}
+
+	result := &CDRDiscoveryResult{
+		AllCDRs: []models.FlexibleCDR{{RawData: map[string]interface{}{"id": "a"}}, {RawData: map[string]interface{}{"id": "c"}}},
+		CDRsByEndpoint: map[string][]models.FlexibleCDR{
+			"api": {{RawData: map[string]interface{}{"id": "a"}}, {RawData: map[string]interface{}{"id": "c"}}},
+		},
+	}
+	ids, err := db.FlaggedCDRIDs("s1")
+	if err != nil {
+		t.Fatalf("FlaggedCDRIDs: %v", err)
+	}
+	flagged := result.FlaggedOnly(ids)
+	if len(flagged.AllCDRs) != 1 || flagged.UniqueCDRs != 1 || flagged.TotalCDRs != 1 || len(flagged.CDRsByEndpoint["api"]) != 1 {
+		t.Errorf("FlaggedOnly kept %d CDRs (%d unique, %d total), want a alone", len(flagged.AllCDRs), flagged.UniqueCDRs, flagged.TotalCDRs)
+	}
+	if len(result.AllCDRs) != 2 {
+		t.Error("FlaggedOnly changed the original result")
+	}
+
+	if unflagged, err := db.UnflagCDR("s1", "a"); err != nil || !unflagged {
+		t.Errorf("UnflagCDR: %v, %v", unflagged, err)
+	}
+	if unflagged, _ := db.UnflagCDR("s1", "a"); unflagged {
+		t.Error("unflagging twice should report it wasn't flagged")
+	}
+}
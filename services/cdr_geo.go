@@ -0,0 +1,121 @@
+// services/cdr_geo.go
+// Call maps: a session's calls placed at the centroid of their number's area code, one
+// point per area code with its calls and minutes, for a Leaflet or Mapbox layer
+
+package services
+
+import (
+	"o-dan-go/models"
+	"sort"
+)
+
+// GeoPoint is the calls from (or to) one area code
+type GeoPoint struct {
+	AreaCode    string  `json:"area_code"`
+	City        string  `json:"city"`
+	State       string  `json:"state"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	Calls       int     `json:"calls"`
+	Minutes     float64 `json:"minutes"`
+	Approximate bool    `json:"approximate"` // the area code isn't known; placed at a neighbor's state
+}
+
+// CallGeo is a session's calls by area code
+type CallGeo struct {
+	Number    string     `json:"number"` // the field the area code was read from
+	Points    []GeoPoint `json:"points"` // most calls first
+	Located   int        `json:"located"`
+	Unlocated int        `json:"unlocated"` // no NANP number, or an area code with no location
+}
+
+// GeoJSONFeatureCollection is a CallGeo as GeoJSON, for map libraries
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// GeoJSONFeature is one point of a GeoJSON collection
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   GeoJSONPoint           `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSONPoint is a GeoJSON point geometry, longitude first
+type GeoJSONPoint struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// LocateCDRs places CDRs at the area code of the number in a field, named as filters
+// name fields (orig_number when empty)
+func LocateCDRs(cdrs []models.FlexibleCDR, number string) *CallGeo {
+	if number == "" {
+		number = "orig_number"
+	}
+	geo := &CallGeo{Number: number, Points: []GeoPoint{}}
+
+	points := make(map[string]*GeoPoint)
+	seconds := make(map[string]int)
+	for i := range cdrs {
+		value, _ := filterFieldValue(&cdrs[i], number)
+		areaCode := ExtractAreaCode(value)
+		point, ok := points[areaCode]
+		if !ok {
+			if areaCode == "" {
+				geo.Unlocated++
+				continue
+			}
+			location, match, _ := GlobalAreaCodes.Resolve(areaCode)
+			if match == AreaCodeUnknown {
+				geo.Unlocated++
+				continue
+			}
+			point = &GeoPoint{
+				AreaCode:    areaCode,
+				City:        location.City,
+				State:       location.State,
+				Lat:         location.Lat,
+				Lon:         location.Lon,
+				Approximate: match == AreaCodeGuessed,
+			}
+			points[areaCode] = point
+		}
+		point.Calls++
+		seconds[areaCode] += cdrs[i].GetCallDuration()
+		geo.Located++
+	}
+
+	for areaCode, point := range points {
+		point.Minutes = roundTo(float64(seconds[areaCode])/60, 1)
+		geo.Points = append(geo.Points, *point)
+	}
+	sort.Slice(geo.Points, func(i, j int) bool {
+		if geo.Points[i].Calls != geo.Points[j].Calls {
+			return geo.Points[i].Calls > geo.Points[j].Calls
+		}
+		return geo.Points[i].AreaCode < geo.Points[j].AreaCode
+	})
+	return geo
+}
+
+// GeoJSON writes the points as a GeoJSON feature collection
+func (g *CallGeo) GeoJSON() GeoJSONFeatureCollection {
+	collection := GeoJSONFeatureCollection{Type: "FeatureCollection", Features: []GeoJSONFeature{}}
+	for _, point := range g.Points {
+		collection.Features = append(collection.Features, GeoJSONFeature{
+			Type:     "Feature",
+			Geometry: GeoJSONPoint{Type: "Point", Coordinates: [2]float64{point.Lon, point.Lat}},
+			Properties: map[string]interface{}{
+				"area_code":   point.AreaCode,
+				"city":        point.City,
+				"state":       point.State,
+				"calls":       point.Calls,
+				"minutes":     point.Minutes,
+				"approximate": point.Approximate,
+			},
+		})
+	}
+	return collection
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestLocateCDRs(t *testing.T) {
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"call-orig-caller-id": float64(12125551234), "call-term-caller-id": "3125550000", "call-total-duration-seconds": float64(90)}},
+		{RawData: map[string]interface{}{"call-orig-caller-id": "(212) 555-9876", "call-total-duration-seconds": float64(30)}},
+		{RawData: map[string]interface{}{"call-orig-caller-id": "3125551111"}},
+		{RawData: map[string]interface{}{"call-orig-caller-id": "101"}},
+	}
+
+	geo := LocateCDRs(cdrs, "")
+	if geo.Number != "orig_number" || geo.Located != 3 || geo.Unlocated != 1 || len(geo.Points) != 2 {
+		t.Fatalf("geo = %+v", geo)
+	}
+	newYork := geo.Points[0]
+	if newYork.AreaCode != "212" || newYork.City != "New York" || newYork.Calls != 2 || newYork.Minutes != 2 || newYork.Approximate {
+		t.Errorf("212 point = %+v", newYork)
+	}
+
+	term := LocateCDRs(cdrs, "term_number")
+	if term.Located != 1 || term.Points[0].AreaCode != "312" {
+		t.Errorf("term geo = %+v", term)
+	}
+
+	collection := geo.GeoJSON()
+	if len(collection.Features) != 2 || collection.Features[0].Geometry.Coordinates != [2]float64{newYork.Lon, newYork.Lat} ||
+		collection.Features[0].Properties["calls"] != 2 {
+		t.Errorf("GeoJSON = %+v", collection)
+	}
+}
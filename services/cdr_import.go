@@ -0,0 +1,186 @@
+// services/cdr_import.go
+// Offline CDR import: loads CDRs from NetSapiens CSV or JSON exports into a discovery
+// result, so results, reports, analytics and exports work without live API access
+
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"o-dan-go/models"
+)
+
+// Import file formats
+const (
+	ImportFormatCSV  = "csv"
+	ImportFormatJSON = "json"
+)
+
+// importEndpointPrefix names the endpoint result each imported file becomes
+const importEndpointPrefix = "import:"
+
+// CDRImportFile is one export to import
+type CDRImportFile struct {
+	Name   string // shown as the endpoint name
+	Format string // csv or json; detected from the content when empty
+	Reader io.Reader
+}
+
+// ImportCDRFiles reads CDRs from files on disk; the format comes from each extension
+func ImportCDRFiles(paths []string) (*CDRDiscoveryResult, error) {
+	files := make([]CDRImportFile, 0, len(paths))
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		defer file.Close()
+		files = append(files, CDRImportFile{
+			Name:   filepath.Base(path),
+			Format: strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), "."),
+			Reader: file,
+		})
+	}
+	return ImportCDRs(files)
+}
+
+// ImportCDRs builds a discovery result from exported CDRs. Each file is shown as an
+// endpoint; CDRs found in several files are kept once.
+func ImportCDRs(files []CDRImportFile) (*CDRDiscoveryResult, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files to import")
+	}
+
+	startTime := time.Now()
+	result := &CDRDiscoveryResult{
+		SessionID:       fmt.Sprintf("cdr_import_%d", startTime.UnixNano()),
+		StartTime:       startTime,
+		EndpointResults: []EndpointResult{},
+		CDRsByEndpoint:  make(map[string][]models.FlexibleCDR),
+		Errors:          []string{},
+	}
+
+	for _, file := range files {
+		endpoint := EndpointResult{EndpointName: importEndpointPrefix + file.Name, URL: "file://" + file.Name, RawDataUsed: true}
+		began := time.Now()
+		cdrs, err := readCDRExport(file)
+		endpoint.QueryTime = time.Since(began)
+		if err != nil {
+			endpoint.Error = err.Error()
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", endpoint.EndpointName, endpoint.Error))
+			result.EndpointResults = append(result.EndpointResults, endpoint)
+			continue
+		}
+		endpoint.Success = true
+		endpoint.RecordCount = len(cdrs)
+		endpoint.DiscoveredData = len(cdrs) > 0
+		result.EndpointResults = append(result.EndpointResults, endpoint)
+		if len(cdrs) > 0 {
+			result.CDRsByEndpoint[endpoint.EndpointName] = cdrs
+			result.AllCDRs = append(result.AllCDRs, cdrs...)
+		}
+	}
+
+	discovery := &CDRDiscoveryService{}
+	deduplicateResult(result)
+	result.TotalCDRs = discovery.countTotalCDRs(result.CDRsByEndpoint)
+	result.Quality = AssessDataQuality(result)
+	result.EndTime = time.Now()
+
+	if result.UniqueCDRs == 0 && len(result.Errors) == len(files) {
+		return nil, fmt.Errorf("no CDRs imported: %s", strings.Join(result.Errors, "; "))
+	}
+	return result, nil
+}
+
+// readCDRExport parses one CSV or JSON export
+func readCDRExport(file CDRImportFile) ([]models.FlexibleCDR, error) {
+	data, err := io.ReadAll(file.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read: %w", err)
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	format := file.Format
+	if format != ImportFormatCSV && format != ImportFormatJSON {
+		format = ImportFormatCSV
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+			format = ImportFormatJSON
+		}
+	}
+	if format == ImportFormatJSON {
+		return readCDRJSON(data)
+	}
+	return readCDRCSV(data)
+}
+
+// readCDRJSON reads an API response saved to a file: an array of CDRs, a {"data": [...]}
+// wrapper, a single CDR, or one CDR per line
+func readCDRJSON(data []byte) ([]models.FlexibleCDR, error) {
+	discovery := &CDRDiscoveryService{}
+	var response interface{}
+	if err := json.Unmarshal(data, &response); err == nil {
+		return discovery.convertAPIResponseToCDRs(response)
+	}
+
+	cdrs := []models.FlexibleCDR{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var cdr models.FlexibleCDR
+		if err := decoder.Decode(&cdr); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		cdrs = append(cdrs, cdr)
+	}
+	return cdrs, nil
+}
+
+// readCDRCSV reads a CSV export whose header row holds the CDR field names. Empty cells
+// are left out so the CDR doesn't claim fields it doesn't have.
+func readCDRCSV(data []byte) ([]models.FlexibleCDR, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return []models.FlexibleCDR{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	cdrs := []models.FlexibleCDR{}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		cdr := models.FlexibleCDR{RawData: map[string]interface{}{}}
+		for i, value := range row {
+			if i >= len(header) || header[i] == "" || value == "" {
+				continue
+			}
+			cdr.RawData[header[i]] = value
+			cdr.DetectedFields = append(cdr.DetectedFields, header[i])
+		}
+		if len(cdr.RawData) > 0 {
+			cdrs = append(cdrs, cdr)
+		}
+	}
+	return cdrs, nil
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImportCDRs(t *testing.T) {
+	csvExport := "\ufeffid,domain,call-start-datetime,call-total-duration-seconds,call-orig-user\n" +
+		"cdr-1,acme,2026-10-13T10:00:00Z,60,1001\n" +
+		"cdr-2,acme,2026-10-13T11:00:00Z,,1002\n"
+	jsonExport := `{"data": [{"id": "cdr-2", "domain": "acme"}, {"id": "cdr-3", "domain": "acme", "call-total-duration-seconds": 30}]}`
+	lines := `{"id": "cdr-4"}
+{"id": "cdr-5"}`
+
+	result, err := ImportCDRs([]CDRImportFile{
+		{Name: "calls.csv", Format: "csv", Reader: strings.NewReader(csvExport)},
+		{Name: "calls.json", Reader: strings.NewReader(jsonExport)},
+		{Name: "calls.ndjson", Reader: strings.NewReader(lines)},
+		{Name: "broken.json", Format: "json", Reader: strings.NewReader(`[{"id": `)},
+	})
+	if err != nil {
+		t.Fatalf("ImportCDRs: %v", err)
+	}
+	if result.TotalCDRs != 6 || result.UniqueCDRs != 5 {
+		t.Errorf("total %d, unique %d; want 6 and 5", result.TotalCDRs, result.UniqueCDRs)
+	}
+	if len(result.EndpointResults) != 4 || result.EndpointResults[3].Success || len(result.Errors) != 1 {
+		t.Errorf("endpoint results = %+v, errors = %v", result.EndpointResults, result.Errors)
+	}
+
+	first := result.AllCDRs[0]
+	if first.GetCallDuration() != 60 || first.GetString("call-orig-user") != "1001" {
+		t.Errorf("first CDR = %v", first.RawData)
+	}
+	if second := result.AllCDRs[1]; second.HasField("call-total-duration-seconds") {
+		t.Errorf("empty CSV cell imported as a field: %v", second.RawData)
+	}
+
+	if _, err := ImportCDRs([]CDRImportFile{{Name: "broken.json", Format: "json", Reader: strings.NewReader("{")}}); err == nil {
+		t.Error("import with nothing readable succeeded")
+	}
+}
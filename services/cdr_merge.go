@@ -0,0 +1,68 @@
+// services/cdr_merge.go
+// Merged CDRs: rather than keeping one endpoint's record of a CDR and dropping the
+// rest, combine the fields every endpoint returned for it into one record, noting which
+// endpoint each field came from
+
+package services
+
+import "o-dan-go/models"
+
+// Merged returns a copy of the result whose CDRs combine the fields of every record
+// found for them. Each CDR starts from the record deduplication kept; fields it lacks
+// (or has as null or empty) are filled from the other endpoints in query order.
+// FieldSources maps each CDR ID to the endpoint each of its fields came from.
+func (r *CDRDiscoveryResult) Merged() *CDRDiscoveryResult {
+	merged := *r
+	merged.AllCDRs = make([]models.FlexibleCDR, 0, len(r.AllCDRs))
+	merged.FieldSources = make(map[string]map[string]string, len(r.AllCDRs))
+
+	// Every endpoint's records of each CDR, in query order
+	type record struct {
+		endpoint string
+		cdr      models.FlexibleCDR
+	}
+	records := make(map[string][]record)
+	for _, endpoint := range r.EndpointResults {
+		for _, cdr := range r.CDRsByEndpoint[endpoint.EndpointName] {
+			if id := cdr.GetID(); id != "" {
+				records[id] = append(records[id], record{endpoint: endpoint.EndpointName, cdr: cdr})
+			}
+		}
+	}
+
+	for _, kept := range r.AllCDRs {
+		id := kept.GetID()
+		found := records[id]
+		winner := r.DedupWinners[id]
+		if winner == "" && len(found) > 0 {
+			winner = found[0].endpoint
+		}
+
+		// The kept record may carry fields added after the search, such as enrichment
+		cdr := models.FlexibleCDR{RawData: make(map[string]interface{}, len(kept.RawData))}
+		sources := make(map[string]string, len(kept.RawData))
+		for field, value := range kept.RawData {
+			cdr.RawData[field] = value
+			sources[field] = winner
+		}
+		for _, other := range found {
+			for field, value := range other.cdr.RawData {
+				if mergeFieldEmpty(cdr.RawData[field]) && !mergeFieldEmpty(value) {
+					cdr.RawData[field] = value
+					sources[field] = other.endpoint
+				}
+			}
+		}
+		for field := range cdr.RawData {
+			cdr.DetectedFields = append(cdr.DetectedFields, field)
+		}
+		merged.AllCDRs = append(merged.AllCDRs, cdr)
+		merged.FieldSources[id] = sources
+	}
+	return &merged
+}
+
+// mergeFieldEmpty reports whether a field has no value to keep
+func mergeFieldEmpty(value interface{}) bool {
+	return value == nil || value == ""
+}
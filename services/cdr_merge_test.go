@@ -0,0 +1,43 @@
+package services
+
+import (
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestMergedCDRs(t *testing.T) {
+	result := &CDRDiscoveryResult{
+		SearchCriteria:  CDRSearchCriteria{Dedup: DedupRichest},
+		EndpointResults: []EndpointResult{{EndpointName: "global_cdrs"}, {EndpointName: "domain_cdrs"}},
+		CDRsByEndpoint: map[string][]models.FlexibleCDR{
+			"global_cdrs": {{RawData: map[string]interface{}{"id": "a", "call-orig-user": "100", "call-term-user": ""}}},
+			"domain_cdrs": {
+				{RawData: map[string]interface{}{"id": "a", "domain": "acme", "call-term-user": "200", "call-disconnect-reason-text": nil}},
+				{RawData: map[string]interface{}{"id": "b", "domain": "acme"}},
+			},
+		},
+	}
+	deduplicateResult(result)
+	result.AllCDRs[0].RawData["orig-carrier"] = "Acme Tel" // added by enrichment after the search
+
+	merged := result.Merged()
+	if len(merged.AllCDRs) != 2 || result.FieldSources != nil {
+		t.Fatalf("Merged = %+v", merged.AllCDRs)
+	}
+	a := merged.AllCDRs[0]
+	if a.GetDomain() != "acme" || a.GetOrigUser() != "100" || a.GetTermUser() != "200" || a.GetString("orig-carrier") != "Acme Tel" {
+		t.Errorf("merged CDR a = %v", a.RawData)
+	}
+	sources := merged.FieldSources["a"]
+	if sources["call-orig-user"] != "global_cdrs" || sources["call-term-user"] != "domain_cdrs" || sources["domain"] != "domain_cdrs" ||
+		sources["orig-carrier"] != "domain_cdrs" {
+		t.Errorf("field sources of a = %v", sources)
+	}
+	if _, ok := result.AllCDRs[0].RawData["call-orig-user"]; ok {
+		t.Error("merging changed the kept record")
+	}
+	if merged.FieldSources["b"]["domain"] != "domain_cdrs" {
+		t.Errorf("field sources of b = %v", merged.FieldSources["b"])
+	}
+}
@@ -0,0 +1,195 @@
+// services/cdr_normalize.go
+// Normalized CDRs: a stable JSON shape (id, direction, times, numbers, duration) read
+// from whichever field names a NetSapiens version uses, so integrators can ask for the
+// same keys everywhere with ?view=normalized instead of the raw fields
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"o-dan-go/models"
+	"strconv"
+	"time"
+)
+
+// CDR views APIs and exports can return
+const (
+	CDRViewRaw        = "raw"        // the fields as the endpoint returned them
+	CDRViewNormalized = "normalized" // NormalizedCDR
+)
+
+// Directions of a normalized CDR
+const (
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
+	DirectionUnknown  = "unknown"
+)
+
+// normalizedFields are the names each normalized value is read from, newest API first
+var normalizedFields = struct {
+	id, domain, direction, start, answer, end, duration, origNumber, termNumber, origUser, termUser, disposition []string
+}{
+	id:          []string{"id", "cdr_id", "cdr-id"},
+	domain:      []string{"domain", "call-domain", "orig_domain"},
+	direction:   []string{"call-direction", "direction", "type"},
+	start:       []string{"call-start-datetime", "start-time", "time-start", "time_start"},
+	answer:      []string{"call-answer-datetime", "call-answered-datetime", "time-answer", "time_answer"},
+	end:         []string{"call-end-datetime", "end-time", "time-release", "time_release"},
+	duration:    []string{"call-total-duration-seconds", "call-duration", "duration"},
+	origNumber:  []string{"call-orig-caller-id", "orig-number", "orig-from-user", "orig_from_user"},
+	termNumber:  []string{"call-term-caller-id", "term-number", "call-dialed-number", "orig-to-user", "orig_to_user"},
+	origUser:    []string{"call-orig-user", "orig-sub", "orig_sub"},
+	termUser:    []string{"call-term-user", "term-sub", "term_sub"},
+	disposition: []string{"call-disconnect-reason-text", "disposition", "release-text", "release_text"},
+}
+
+// NormalizedCDR is a CDR in the documented shape. Values a CDR doesn't have are empty,
+// zero or omitted; times are UTC.
+type NormalizedCDR struct {
+	ID              string     `json:"id"`
+	Domain          string     `json:"domain"`
+	Direction       string     `json:"direction"` // inbound, outbound or unknown
+	StartTime       *time.Time `json:"start_time,omitempty"`
+	AnswerTime      *time.Time `json:"answer_time,omitempty"`
+	EndTime         *time.Time `json:"end_time,omitempty"`
+	DurationSeconds int        `json:"duration_seconds"`
+	TalkSeconds     int        `json:"talk_seconds"`
+	OrigNumber      string     `json:"orig_number"`
+	TermNumber      string     `json:"term_number"`
+	OrigUser        string     `json:"orig_user"`
+	TermUser        string     `json:"term_user"`
+	Disposition     string     `json:"disposition"`
+	CallClass       string     `json:"call_class"` // answered, abandoned or missed
+}
+
+// ParseCDRView checks a ?view= value; empty means raw
+func ParseCDRView(view string) (string, error) {
+	switch view {
+	case "", CDRViewRaw:
+		return CDRViewRaw, nil
+	case CDRViewNormalized:
+		return CDRViewNormalized, nil
+	}
+	return "", fmt.Errorf("unknown view %q: use %s or %s", view, CDRViewRaw, CDRViewNormalized)
+}
+
+// NormalizeCDR reads a CDR into the normalized shape
+func NormalizeCDR(cdr models.FlexibleCDR) NormalizedCDR {
+	normalized := NormalizedCDR{
+		ID:          cdrFilterFirst(&cdr, normalizedFields.id...),
+		Domain:      cdrFilterFirst(&cdr, normalizedFields.domain...),
+		Direction:   DirectionUnknown,
+		StartTime:   normalizedTime(&cdr, normalizedFields.start),
+		AnswerTime:  normalizedTime(&cdr, normalizedFields.answer),
+		EndTime:     normalizedTime(&cdr, normalizedFields.end),
+		OrigNumber:  cdrFilterFirst(&cdr, normalizedFields.origNumber...),
+		TermNumber:  cdrFilterFirst(&cdr, normalizedFields.termNumber...),
+		OrigUser:    cdrFilterFirst(&cdr, normalizedFields.origUser...),
+		TermUser:    cdrFilterFirst(&cdr, normalizedFields.termUser...),
+		Disposition: cdrFilterFirst(&cdr, normalizedFields.disposition...),
+		CallClass:   ClassifyCall(cdr),
+	}
+
+	switch cdrFilterFirst(&cdr, normalizedFields.direction...) {
+	case "1":
+		normalized.Direction = DirectionInbound
+	case "0":
+		normalized.Direction = DirectionOutbound
+	}
+
+	for _, field := range normalizedFields.duration {
+		if seconds, err := strconv.ParseFloat(cdrFilterString(&cdr, field), 64); err == nil && seconds > 0 {
+			normalized.DurationSeconds = int(seconds)
+			break
+		}
+	}
+	if normalized.DurationSeconds == 0 && normalized.StartTime != nil && normalized.EndTime != nil && normalized.EndTime.After(*normalized.StartTime) {
+		normalized.DurationSeconds = int(normalized.EndTime.Sub(*normalized.StartTime).Seconds())
+	}
+	if normalized.AnswerTime != nil && normalized.EndTime != nil && normalized.EndTime.After(*normalized.AnswerTime) {
+		normalized.TalkSeconds = int(normalized.EndTime.Sub(*normalized.AnswerTime).Seconds())
+	} else if answered, talk := callAnswered(cdr); answered {
+		normalized.TalkSeconds = talk
+	}
+	return normalized
+}
+
+// NormalizeCDRs normalizes a list of CDRs
+func NormalizeCDRs(cdrs []models.FlexibleCDR) []NormalizedCDR {
+	normalized := make([]NormalizedCDR, len(cdrs))
+	for i := range cdrs {
+		normalized[i] = NormalizeCDR(cdrs[i])
+	}
+	return normalized
+}
+
+// normalizedTime reads the first of fields that holds a time, in UTC
+func normalizedTime(cdr *models.FlexibleCDR, fields []string) *time.Time {
+	for _, field := range fields {
+		if t, err := cdr.GetTime(field); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
+
+// warehouseRow is a CDR as written to analytics stores: its normalized values with the
+// session it came from and the raw CDR as JSON
+type warehouseRow struct {
+	SessionID       string  `json:"session_id"`
+	CDRID           string  `json:"cdr_id"`
+	Domain          string  `json:"domain"`
+	Direction       string  `json:"direction"`
+	StartTime       string  `json:"start_time"`
+	AnswerTime      *string `json:"answer_time"`
+	EndTime         *string `json:"end_time"`
+	DurationSeconds int     `json:"duration_seconds"`
+	TalkSeconds     int     `json:"talk_seconds"`
+	OrigNumber      string  `json:"orig_number"`
+	TermNumber      string  `json:"term_number"`
+	OrigUser        string  `json:"orig_user"`
+	TermUser        string  `json:"term_user"`
+	Disposition     string  `json:"disposition"`
+	CallClass       string  `json:"call_class"`
+	Raw             string  `json:"raw"`
+}
+
+// newWarehouseRow builds a CDR's row with times in layout. CDRs without a start time
+// are filed under started.
+func newWarehouseRow(sessionID string, cdr models.FlexibleCDR, started time.Time, layout string) (warehouseRow, error) {
+	raw, err := json.Marshal(cdr.RawData)
+	if err != nil {
+		return warehouseRow{}, err
+	}
+	normalized := NormalizeCDR(cdr)
+	if normalized.StartTime == nil {
+		normalized.StartTime = &started
+	}
+	formatTime := func(t *time.Time) *string {
+		if t == nil {
+			return nil
+		}
+		formatted := t.UTC().Format(layout)
+		return &formatted
+	}
+	return warehouseRow{
+		SessionID:       sessionID,
+		CDRID:           normalized.ID,
+		Domain:          normalized.Domain,
+		Direction:       normalized.Direction,
+		StartTime:       *formatTime(normalized.StartTime),
+		AnswerTime:      formatTime(normalized.AnswerTime),
+		EndTime:         formatTime(normalized.EndTime),
+		DurationSeconds: normalized.DurationSeconds,
+		TalkSeconds:     normalized.TalkSeconds,
+		OrigNumber:      normalized.OrigNumber,
+		TermNumber:      normalized.TermNumber,
+		OrigUser:        normalized.OrigUser,
+		TermUser:        normalized.TermUser,
+		Disposition:     normalized.Disposition,
+		CallClass:       normalized.CallClass,
+		Raw:             string(raw),
+	}, nil
+}
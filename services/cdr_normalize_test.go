@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestNormalizeCDR(t *testing.T) {
+	v2 := NormalizeCDR(models.FlexibleCDR{RawData: map[string]interface{}{
+		"id":                     "a",
+		"domain":                 "acme",
+		"call-direction":         1,
+		"call-start-datetime":    "2026-10-12T10:00:00Z",
+		"call-answered-datetime": "2026-10-12T10:00:10Z",
+		"call-end-datetime":      "2026-10-12T10:01:10Z",
+		"call-orig-caller-id":    "2125551234",
+		"call-term-user":         "101",
+	}})
+	if v2.ID != "a" || v2.Direction != DirectionInbound || v2.OrigNumber != "2125551234" || v2.TermUser != "101" {
+		t.Errorf("v2 CDR = %+v", v2)
+	}
+	if v2.DurationSeconds != 70 || v2.TalkSeconds != 60 {
+		t.Errorf("v2 durations = %d total, %d talking; want 70 and 60", v2.DurationSeconds, v2.TalkSeconds)
+	}
+
+	v1 := NormalizeCDR(models.FlexibleCDR{RawData: map[string]interface{}{
+		"cdr_id":         "b",
+		"type":           "0",
+		"time_start":     "2026-10-12 10:00:00",
+		"time_release":   "2026-10-12 10:00:30",
+		"orig_from_user": "3125550000",
+		"duration":       "30",
+	}})
+	if v1.ID != "b" || v1.Direction != DirectionOutbound || v1.OrigNumber != "3125550000" || v1.DurationSeconds != 30 {
+		t.Errorf("v1 CDR = %+v", v1)
+	}
+	if v1.StartTime == nil || v1.StartTime.Hour() != 10 || v1.AnswerTime != nil {
+		t.Errorf("v1 times = %v, %v; want a start and no answer", v1.StartTime, v1.AnswerTime)
+	}
+
+	if view, err := ParseCDRView(""); err != nil || view != CDRViewRaw {
+		t.Errorf("ParseCDRView(\"\") = %q, %v; want raw", view, err)
+	}
+	if _, err := ParseCDRView("flat"); err == nil {
+		t.Error("ParseCDRView accepted an unknown view")
+	}
+}
@@ -0,0 +1,108 @@
+// services/cdr_pivot.go
+// Pivots: a session's CDRs grouped by one or more fields (domain, user, disconnect
+// reason...) with the count, total and average duration of each group, for ad-hoc
+// tables without downloading the raw data
+
+package services
+
+import (
+	"fmt"
+	"o-dan-go/models"
+	"sort"
+	"strings"
+)
+
+// maxPivotFields limits how many fields CDRs can be grouped by at once
+const maxPivotFields = 3
+
+// PivotGroup is the CDRs sharing one combination of values
+type PivotGroup struct {
+	Values        map[string]string `json:"values"` // field to value; "" when a CDR lacks the field
+	Count         int               `json:"count"`
+	Percent       float64           `json:"percent"` // of the CDRs grouped
+	TotalDuration int               `json:"total_duration"`
+	AvgDuration   float64           `json:"avg_duration"`
+	MaxDuration   int               `json:"max_duration"`
+	AnsweredCalls int               `json:"answered_calls"`
+	AnsweredRatio float64           `json:"answered_ratio"` // percentage of the group answered
+}
+
+// Pivot is a session's CDRs grouped by fields
+type Pivot struct {
+	GroupBy []string     `json:"group_by"`
+	CDRs    int          `json:"cdrs"`
+	Groups  []PivotGroup `json:"groups"`  // largest first
+	Omitted int          `json:"omitted"` // groups beyond the limit
+}
+
+// PivotCDRs groups CDRs by the named fields, which are read as filters read them (the
+// export column names, or any raw CDR field), and returns the limit largest groups
+func PivotCDRs(cdrs []models.FlexibleCDR, groupBy []string, limit int) (*Pivot, error) {
+	fields := []string{}
+	for _, field := range groupBy {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("group_by needs at least one field")
+	}
+	if len(fields) > maxPivotFields {
+		return nil, fmt.Errorf("CDRs can be grouped by at most %d fields", maxPivotFields)
+	}
+
+	pivot := &Pivot{GroupBy: fields, CDRs: len(cdrs), Groups: []PivotGroup{}}
+	groups := make(map[string]*PivotGroup)
+	for i := range cdrs {
+		values := make(map[string]string, len(fields))
+		key := make([]string, len(fields))
+		for j, field := range fields {
+			value, _ := filterFieldValue(&cdrs[i], field)
+			values[field] = value
+			key[j] = value
+		}
+		joined := strings.Join(key, "\x00")
+		group, ok := groups[joined]
+		if !ok {
+			group = &PivotGroup{Values: values}
+			groups[joined] = group
+		}
+
+		duration := cdrs[i].GetCallDuration()
+		group.Count++
+		group.TotalDuration += duration
+		if duration > group.MaxDuration {
+			group.MaxDuration = duration
+		}
+		if answered, _ := callAnswered(cdrs[i]); answered {
+			group.AnsweredCalls++
+		}
+	}
+
+	for _, group := range groups {
+		group.Percent = roundTo(100*float64(group.Count)/float64(len(cdrs)), 1)
+		group.AvgDuration = roundTo(float64(group.TotalDuration)/float64(group.Count), 1)
+		group.AnsweredRatio = roundTo(100*float64(group.AnsweredCalls)/float64(group.Count), 1)
+		pivot.Groups = append(pivot.Groups, *group)
+	}
+	sort.Slice(pivot.Groups, func(i, j int) bool {
+		if pivot.Groups[i].Count != pivot.Groups[j].Count {
+			return pivot.Groups[i].Count > pivot.Groups[j].Count
+		}
+		return pivotKey(pivot.Groups[i], fields) < pivotKey(pivot.Groups[j], fields)
+	})
+	if limit > 0 && len(pivot.Groups) > limit {
+		pivot.Omitted = len(pivot.Groups) - limit
+		pivot.Groups = pivot.Groups[:limit]
+	}
+	return pivot, nil
+}
+
+// pivotKey orders groups with the same count by their values
+func pivotKey(group PivotGroup, fields []string) string {
+	values := make([]string, len(fields))
+	for i, field := range fields {
+		values[i] = group.Values[field]
+	}
+	return strings.Join(values, "\x00")
+}
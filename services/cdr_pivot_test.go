@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestPivotCDRs(t *testing.T) {
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"domain": "acme", "call-disconnect-reason-text": "Normal", "call-total-duration-seconds": float64(60)}},
+		{RawData: map[string]interface{}{"domain": "acme", "call-disconnect-reason-text": "Normal", "call-total-duration-seconds": float64(30)}},
+		{RawData: map[string]interface{}{"domain": "acme", "call-disconnect-reason-text": "Busy", "call-total-duration-seconds": float64(0)}},
+		{RawData: map[string]interface{}{"domain": "globex", "call-total-duration-seconds": float64(10)}},
+	}
+
+	pivot, err := PivotCDRs(cdrs, []string{"domain"}, 0)
+	if err != nil {
+		t.Fatalf("PivotCDRs: %v", err)
+	}
+	if len(pivot.Groups) != 2 || pivot.Groups[0].Values["domain"] != "acme" {
+		t.Fatalf("groups = %+v", pivot.Groups)
+	}
+	acme := pivot.Groups[0]
+	if acme.Count != 3 || acme.Percent != 75 || acme.TotalDuration != 90 || acme.AvgDuration != 30 ||
+		acme.MaxDuration != 60 || acme.AnsweredCalls != 2 || acme.AnsweredRatio != 66.7 {
+		t.Errorf("acme group = %+v", acme)
+	}
+
+	pivot, err = PivotCDRs(cdrs, []string{"domain", " disposition "}, 2)
+	if err != nil {
+		t.Fatalf("PivotCDRs: %v", err)
+	}
+	if len(pivot.Groups) != 2 || pivot.Omitted != 1 || pivot.Groups[0].Values["disposition"] != "Normal" {
+		t.Errorf("two-field pivot = %+v", pivot)
+	}
+	if pivot.Groups[1].Values["domain"] != "acme" || pivot.Groups[1].Values["disposition"] != "Busy" {
+		t.Errorf("tied groups should be ordered by value: %+v", pivot.Groups)
+	}
+
+	if _, err := PivotCDRs(cdrs, []string{""}, 0); err == nil {
+		t.Error("expected an error without a field")
+	}
+	if _, err := PivotCDRs(cdrs, []string{"a", "b", "c", "d"}, 0); err == nil {
+		t.Error("expected an error for too many fields")
+	}
+}
@@ -0,0 +1,124 @@
+// services/cdr_timeseries.go
+// Time series: a session's calls or minutes counted in fixed buckets of call start
+// time, for the results page chart and external dashboards
+
+package services
+
+import (
+	"fmt"
+	"o-dan-go/models"
+	"time"
+)
+
+// Time series metrics
+const (
+	TimeSeriesCalls   = "calls"   // calls started in the bucket
+	TimeSeriesMinutes = "minutes" // minutes of the calls started in the bucket
+)
+
+// maxTimeSeriesBuckets caps how many buckets a series can span
+const maxTimeSeriesBuckets = 5000
+
+// TimeSeriesBucket is one interval of a series
+type TimeSeriesBucket struct {
+	Start time.Time `json:"start"`
+	Value float64   `json:"value"`
+}
+
+// TimeSeries is a session's calls or minutes per interval
+type TimeSeries struct {
+	Interval string             `json:"interval"`
+	Metric   string             `json:"metric"`
+	Timezone string             `json:"timezone"`
+	Buckets  []TimeSeriesBucket `json:"buckets"` // every interval from the first call to the last, empty ones included
+	Total    float64            `json:"total"`
+	Skipped  int                `json:"skipped"` // CDRs without a start time that parses
+}
+
+// ParseTimeSeriesInterval reads an interval such as 5m, 1h or 1d. Intervals are whole
+// minutes, from one to a day, and must divide a day evenly so buckets start at local
+// midnight.
+func ParseTimeSeriesInterval(value string) (time.Duration, error) {
+	if value == "1d" {
+		return 24 * time.Hour, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %q (expected e.g. 5m, 1h or 1d)", value)
+	}
+	if interval < time.Minute || interval > 24*time.Hour || interval%time.Minute != 0 || (24*time.Hour)%interval != 0 {
+		return 0, fmt.Errorf("interval %s must be whole minutes between 1m and 1d that divide a day evenly", value)
+	}
+	return interval, nil
+}
+
+// BuildTimeSeries buckets CDRs by call start time in loc
+func BuildTimeSeries(cdrs []models.FlexibleCDR, interval time.Duration, metric string, loc *time.Location) (*TimeSeries, error) {
+	if metric == "" {
+		metric = TimeSeriesCalls
+	}
+	if metric != TimeSeriesCalls && metric != TimeSeriesMinutes {
+		return nil, fmt.Errorf("unknown metric %q: use %s or %s", metric, TimeSeriesCalls, TimeSeriesMinutes)
+	}
+
+	series := &TimeSeries{Interval: timeSeriesIntervalName(interval), Metric: metric, Timezone: loc.String(), Buckets: []TimeSeriesBucket{}}
+	values := make(map[int64]float64) // by bucket start
+	var first, last time.Time
+	for i := range cdrs {
+		started, err := cdrs[i].GetCallStartTime()
+		if err != nil {
+			series.Skipped++
+			continue
+		}
+		start := timeSeriesBucket(started, interval, loc)
+		value := 1.0
+		if metric == TimeSeriesMinutes {
+			value = float64(cdrs[i].GetCallDuration()) / 60
+		}
+		values[start.Unix()] += value
+		series.Total += value
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if start.After(last) {
+			last = start
+		}
+	}
+	series.Total = roundTo(series.Total, 1)
+	if first.IsZero() {
+		return series, nil
+	}
+
+	if buckets := int(last.Sub(first)/interval) + 1; buckets > maxTimeSeriesBuckets {
+		return nil, fmt.Errorf("the calls span %d buckets of %s, more than %d: use a longer interval", buckets, timeSeriesIntervalName(interval), maxTimeSeriesBuckets)
+	}
+	for start := first; !start.After(last); {
+		series.Buckets = append(series.Buckets, TimeSeriesBucket{Start: start, Value: roundTo(values[start.Unix()], 1)})
+		next := timeSeriesBucket(start.Add(interval), interval, loc)
+		if !next.After(start) {
+			// A day an hour longer for daylight saving ends an hour later
+			next = timeSeriesBucket(start.Add(interval+time.Hour), interval, loc)
+		}
+		start = next
+	}
+	return series, nil
+}
+
+// timeSeriesBucket finds the start of the bucket a time falls in, counting buckets from
+// local midnight
+func timeSeriesBucket(t time.Time, interval time.Duration, loc *time.Location) time.Time {
+	local := t.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return midnight.Add(local.Sub(midnight) / interval * interval)
+}
+
+// timeSeriesIntervalName writes an interval as it is given: 5m, 1h or 1d
+func timeSeriesIntervalName(interval time.Duration) string {
+	switch {
+	case interval%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", interval/(24*time.Hour))
+	case interval%time.Hour == 0:
+		return fmt.Sprintf("%dh", interval/time.Hour)
+	}
+	return fmt.Sprintf("%dm", interval/time.Minute)
+}
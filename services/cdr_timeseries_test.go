@@ -0,0 +1,61 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestParseTimeSeriesInterval(t *testing.T) {
+	for value, want := range map[string]time.Duration{"5m": 5 * time.Minute, "1h": time.Hour, "1d": 24 * time.Hour} {
+		if got, err := ParseTimeSeriesInterval(value); err != nil || got != want {
+			t.Errorf("ParseTimeSeriesInterval(%q) = %v, %v", value, got, err)
+		}
+	}
+	for _, value := range []string{"30s", "7m", "90s", "2d", "soon"} {
+		if _, err := ParseTimeSeriesInterval(value); err == nil {
+			t.Errorf("ParseTimeSeriesInterval(%q) should fail", value)
+		}
+	}
+}
+
+func TestBuildTimeSeries(t *testing.T) {
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"call-start-datetime": "2024-03-01T10:02:00Z", "call-total-duration-seconds": float64(120)}},
+		{RawData: map[string]interface{}{"call-start-datetime": "2024-03-01T10:04:59Z", "call-total-duration-seconds": float64(60)}},
+		{RawData: map[string]interface{}{"call-start-datetime": "2024-03-01T10:16:00Z", "call-total-duration-seconds": float64(30)}},
+		{RawData: map[string]interface{}{"call-total-duration-seconds": float64(30)}},
+	}
+
+	calls, err := BuildTimeSeries(cdrs, 5*time.Minute, "", time.UTC)
+	if err != nil {
+		t.Fatalf("BuildTimeSeries: %v", err)
+	}
+	if calls.Interval != "5m" || calls.Total != 3 || calls.Skipped != 1 || len(calls.Buckets) != 4 {
+		t.Fatalf("series = %+v", calls)
+	}
+	if calls.Buckets[0].Value != 2 || calls.Buckets[1].Value != 0 || calls.Buckets[3].Value != 1 ||
+		!calls.Buckets[3].Start.Equal(time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC)) {
+		t.Errorf("buckets = %+v", calls.Buckets)
+	}
+
+	minutes, err := BuildTimeSeries(cdrs, time.Hour, TimeSeriesMinutes, time.UTC)
+	if err != nil || len(minutes.Buckets) != 1 || minutes.Buckets[0].Value != 3.5 {
+		t.Errorf("minutes = %+v, %v", minutes, err)
+	}
+
+	// Days start at local midnight
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no timezone database")
+	}
+	days, err := BuildTimeSeries(cdrs, 24*time.Hour, "", newYork)
+	if err != nil || len(days.Buckets) != 1 || days.Buckets[0].Start.Hour() != 0 || days.Buckets[0].Start.Day() != 1 {
+		t.Errorf("days = %+v, %v", days, err)
+	}
+
+	if _, err := BuildTimeSeries(cdrs, time.Hour, "seconds", time.UTC); err == nil {
+		t.Error("expected an error for an unknown metric")
+	}
+}
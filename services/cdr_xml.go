@@ -0,0 +1,110 @@
+// services/cdr_xml.go
+// XML export: writes a session's CDRs as an IPDR-style document (an IPDRDoc header, one
+// IPDR element per call and an IPDRDoc.End trailer) for mediation systems that only take XML
+
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// IPDRNamespace is the namespace of exported documents
+const IPDRNamespace = "http://www.ipdr.org/namespaces/ipdr"
+
+// ipdrVersion is the IPDR document version the layout follows
+const ipdrVersion = "3.5.1"
+
+// ipdrRecord is one call. Elements follow the IPDR VoIP service names where one exists.
+type ipdrRecord struct {
+	XMLName            xml.Name `xml:"IPDR"`
+	SeqNum             int      `xml:"seqNum,attr"`
+	Time               string   `xml:"time,attr,omitempty"`
+	UniqueCallID       string   `xml:"uniqueCallId"`
+	SubscriberID       string   `xml:"subscriberId"`
+	CallDirection      string   `xml:"callDirection"`
+	ANI                string   `xml:"ani"`
+	DestinationID      string   `xml:"destinationId"`
+	OriginatingUser    string   `xml:"originatingUser,omitempty"`
+	TerminatingUser    string   `xml:"terminatingUser,omitempty"`
+	StartTime          string   `xml:"startTime,omitempty"`
+	AnswerTime         string   `xml:"answerTime,omitempty"`
+	EndTime            string   `xml:"endTime,omitempty"`
+	CallDuration       int      `xml:"callDuration"`
+	TalkDuration       int      `xml:"talkDuration"`
+	CallCompletionCode string   `xml:"callCompletionCode"`
+	DisconnectReason   string   `xml:"disconnectReason,omitempty"`
+}
+
+// ipdrDocEnd is the trailer with the record count
+type ipdrDocEnd struct {
+	XMLName xml.Name `xml:"IPDRDoc.End"`
+	Count   int      `xml:"count,attr"`
+	EndTime string   `xml:"endTime,attr"`
+}
+
+// WriteIPDRDocument writes a result's CDRs as an IPDR-style XML document, one IPDR
+// element per CDR in the result's order with the values of its normalized view
+func WriteIPDRDocument(w io.Writer, result *CDRDiscoveryResult) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	// The document element is written open so records can stream after it
+	start := xml.StartElement{Name: xml.Name{Local: "IPDRDoc"}, Attr: []xml.Attr{
+		{Name: xml.Name{Local: "xmlns"}, Value: IPDRNamespace},
+		{Name: xml.Name{Local: "version"}, Value: ipdrVersion},
+		{Name: xml.Name{Local: "docId"}, Value: result.SessionID},
+		{Name: xml.Name{Local: "creationTime"}, Value: time.Now().UTC().Format(time.RFC3339)},
+		{Name: xml.Name{Local: "IPDRRecorderInfo"}, Value: "o-dan-go"},
+	}}
+	if err := encoder.EncodeToken(start); err != nil {
+		return fmt.Errorf("failed to write IPDR header: %w", err)
+	}
+
+	for i := range result.AllCDRs {
+		normalized := NormalizeCDR(result.AllCDRs[i])
+		record := ipdrRecord{
+			SeqNum:             i + 1,
+			Time:               ipdrTime(normalized.StartTime),
+			UniqueCallID:       normalized.ID,
+			SubscriberID:       normalized.Domain,
+			CallDirection:      normalized.Direction,
+			ANI:                normalized.OrigNumber,
+			DestinationID:      normalized.TermNumber,
+			OriginatingUser:    normalized.OrigUser,
+			TerminatingUser:    normalized.TermUser,
+			StartTime:          ipdrTime(normalized.StartTime),
+			AnswerTime:         ipdrTime(normalized.AnswerTime),
+			EndTime:            ipdrTime(normalized.EndTime),
+			CallDuration:       normalized.DurationSeconds,
+			TalkDuration:       normalized.TalkSeconds,
+			CallCompletionCode: normalized.CallClass,
+			DisconnectReason:   normalized.Disposition,
+		}
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("failed to write IPDR record %d: %w", i+1, err)
+		}
+	}
+
+	end := ipdrDocEnd{Count: len(result.AllCDRs), EndTime: time.Now().UTC().Format(time.RFC3339)}
+	if err := encoder.Encode(end); err != nil {
+		return fmt.Errorf("failed to write IPDR trailer: %w", err)
+	}
+	if err := encoder.EncodeToken(start.End()); err != nil {
+		return fmt.Errorf("failed to write IPDR trailer: %w", err)
+	}
+	return encoder.Flush()
+}
+
+// ipdrTime formats a time for a record, or "" when the CDR has none
+func ipdrTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
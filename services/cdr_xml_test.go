@@ -0,0 +1,50 @@
+package services
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestWriteIPDRDocument(t *testing.T) {
+	result := &CDRDiscoveryResult{
+		SessionID: "s1",
+		AllCDRs: []models.FlexibleCDR{
+			{RawData: map[string]interface{}{"id": "a", "domain": "acme", "call-orig-caller-id": "2125551234", "call-start-datetime": "2026-10-12T10:00:00Z"}},
+			{RawData: map[string]interface{}{"id": "b<&>"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteIPDRDocument(&buf, result); err != nil {
+		t.Fatalf("WriteIPDRDocument: %v", err)
+	}
+
+	var doc struct {
+		XMLName xml.Name `xml:"IPDRDoc"`
+		DocID   string   `xml:"docId,attr"`
+		Records []struct {
+			SeqNum    int    `xml:"seqNum,attr"`
+			CallID    string `xml:"uniqueCallId"`
+			ANI       string `xml:"ani"`
+			StartTime string `xml:"startTime"`
+		} `xml:"IPDR"`
+		End struct {
+			Count int `xml:"count,attr"`
+		} `xml:"IPDRDoc.End"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("document isn't valid XML: %v\n%s", err, buf.String())
+	}
+	if doc.DocID != "s1" || len(doc.Records) != 2 || doc.End.Count != 2 {
+		t.Fatalf("document = %+v, want session s1 with 2 records", doc)
+	}
+	if first := doc.Records[0]; first.SeqNum != 1 || first.ANI != "2125551234" || first.StartTime != "2026-10-12T10:00:00Z" {
+		t.Errorf("first record = %+v", first)
+	}
+	if doc.Records[1].CallID != "b<&>" {
+		t.Errorf("second record ID = %q, want it escaped and read back", doc.Records[1].CallID)
+	}
+}
@@ -0,0 +1,197 @@
+// services/clickhouse_writer.go
+// ClickHouse writer: keeps searched and imported CDRs in a ClickHouse table over its
+// HTTP interface, creating the table on first use and inserting in batches, so months of
+// calls stay queryable at interactive speed
+
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"o-dan-go/models"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clickHouseIdentifier matches the database and table names the writer accepts
+var clickHouseIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// clickHouseTimeLayout is how times are sent in JSONEachRow rows
+const clickHouseTimeLayout = "2006-01-02 15:04:05.000"
+
+// ClickHouseSettings configures the writer; it is disabled without a URL
+type ClickHouseSettings struct {
+	URL           string // HTTP interface, e.g. http://clickhouse:8123
+	Database      string
+	Table         string
+	Username      string
+	Password      string
+	BatchSize     int // rows per INSERT
+	RetentionDays int // rows older than this are dropped by a table TTL; 0 keeps them
+	WriteSearches bool
+}
+
+// ClickHouseWriter inserts CDRs into a ClickHouse table
+type ClickHouseWriter struct {
+	settings ClickHouseSettings
+	client   *http.Client
+
+	mu    sync.Mutex
+	ready bool // the table exists
+}
+
+// NewClickHouseWriter creates the writer, checking the database and table names
+func NewClickHouseWriter(settings ClickHouseSettings) (*ClickHouseWriter, error) {
+	settings.URL = strings.TrimRight(settings.URL, "/")
+	if settings.Database == "" {
+		settings.Database = "default"
+	}
+	if settings.Table == "" {
+		settings.Table = "cdrs"
+	}
+	if settings.BatchSize <= 0 {
+		settings.BatchSize = 10000
+	}
+	for _, name := range []string{settings.Database, settings.Table} {
+		if !clickHouseIdentifier.MatchString(name) {
+			return nil, fmt.Errorf("invalid ClickHouse name %q: use letters, digits and underscores", name)
+		}
+	}
+	if settings.RetentionDays < 0 {
+		return nil, fmt.Errorf("ClickHouse retention can't be negative")
+	}
+	return &ClickHouseWriter{
+		settings: settings,
+		client:   &http.Client{Timeout: 2 * time.Minute},
+	}, nil
+}
+
+// Enabled reports whether ClickHouse is configured
+func (cw *ClickHouseWriter) Enabled() bool {
+	return cw != nil && cw.settings.URL != ""
+}
+
+// WritesSearches reports whether searches are written as they complete
+func (cw *ClickHouseWriter) WritesSearches() bool {
+	return cw.Enabled() && cw.settings.WriteSearches
+}
+
+// TableDDL is the statement that creates the CDR table. ReplacingMergeTree keeps the
+// latest copy of a CDR written more than once; partitions are months of call starts.
+func (cw *ClickHouseWriter) TableDDL() string {
+	ttl := ""
+	if cw.settings.RetentionDays > 0 {
+		ttl = fmt.Sprintf("\nTTL toDateTime(start_time) + INTERVAL %d DAY", cw.settings.RetentionDays)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s.%s (
+	session_id String,
+	cdr_id String,
+	domain LowCardinality(String),
+	direction LowCardinality(String),
+	start_time DateTime64(3, 'UTC'),
+	answer_time Nullable(DateTime64(3, 'UTC')),
+	end_time Nullable(DateTime64(3, 'UTC')),
+	duration_seconds UInt32,
+	talk_seconds UInt32,
+	orig_number String,
+	term_number String,
+	orig_user String,
+	term_user String,
+	disposition LowCardinality(String),
+	call_class LowCardinality(String),
+	raw String,
+	ingested_at DateTime DEFAULT now()
+)
+ENGINE = ReplacingMergeTree(ingested_at)
+PARTITION BY toYYYYMM(start_time)
+ORDER BY (domain, start_time, cdr_id)%s`, cw.settings.Database, cw.settings.Table, ttl)
+}
+
+// WriteCDRs inserts a session's CDRs, creating the database and table first if needed.
+// CDRs without a start time are filed under started. It returns the rows written.
+func (cw *ClickHouseWriter) WriteCDRs(sessionID string, cdrs []models.FlexibleCDR, started time.Time) (int, error) {
+	if err := cw.bootstrap(); err != nil {
+		return 0, err
+	}
+
+	insert := fmt.Sprintf("INSERT INTO %s.%s FORMAT JSONEachRow", cw.settings.Database, cw.settings.Table)
+	written := 0
+	for start := 0; start < len(cdrs); start += cw.settings.BatchSize {
+		end := start + cw.settings.BatchSize
+		if end > len(cdrs) {
+			end = len(cdrs)
+		}
+
+		var body bytes.Buffer
+		encoder := json.NewEncoder(&body)
+		for i := start; i < end; i++ {
+			row, err := newWarehouseRow(sessionID, cdrs[i], started, clickHouseTimeLayout)
+			if err != nil {
+				return written, fmt.Errorf("failed to encode CDR %s: %w", cdrs[i].GetID(), err)
+			}
+			if err := encoder.Encode(row); err != nil {
+				return written, fmt.Errorf("failed to encode CDR %s: %w", cdrs[i].GetID(), err)
+			}
+		}
+		if err := cw.exec(insert, &body); err != nil {
+			return written, fmt.Errorf("failed to insert CDRs %d-%d: %w", start+1, end, err)
+		}
+		written += end - start
+	}
+	log.Printf("[ClickHouse] Wrote %d CDRs of %s to %s.%s", written, sessionID, cw.settings.Database, cw.settings.Table)
+	return written, nil
+}
+
+// bootstrap creates the database and table once per process
+func (cw *ClickHouseWriter) bootstrap() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.ready {
+		return nil
+	}
+	if err := cw.exec("CREATE DATABASE IF NOT EXISTS "+cw.settings.Database, nil); err != nil {
+		return fmt.Errorf("failed to create database %s: %w", cw.settings.Database, err)
+	}
+	if err := cw.exec(cw.TableDDL(), nil); err != nil {
+		return fmt.Errorf("failed to create table %s.%s: %w", cw.settings.Database, cw.settings.Table, err)
+	}
+	cw.ready = true
+	return nil
+}
+
+// exec runs a statement, sending body as its data when there is one
+func (cw *ClickHouseWriter) exec(query string, body io.Reader) error {
+	endpoint := cw.settings.URL + "/?query=" + url.QueryEscape(query)
+	if body == nil {
+		endpoint, body = cw.settings.URL+"/", strings.NewReader(query)
+	}
+	req, err := http.NewRequest("POST", endpoint, body)
+	if err != nil {
+		return fmt.Errorf("invalid ClickHouse URL: %w", err)
+	}
+	if cw.settings.Username != "" {
+		req.Header.Set("X-ClickHouse-User", cw.settings.Username)
+		req.Header.Set("X-ClickHouse-Key", cw.settings.Password)
+	}
+
+	resp, err := cw.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("ClickHouse returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestClickHouseWriter(t *testing.T) {
+	var statements []string
+	var rows []warehouseRow
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-ClickHouse-User") != "odango" || r.Header.Get("X-ClickHouse-Key") != "secret" {
+			t.Errorf("credentials = %q/%q", r.Header.Get("X-ClickHouse-User"), r.Header.Get("X-ClickHouse-Key"))
+		}
+		query := r.URL.Query().Get("query")
+		if query == "" {
+			body, _ := io.ReadAll(r.Body)
+			statements = append(statements, string(body))
+			return
+		}
+		statements = append(statements, query)
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var row warehouseRow
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				t.Errorf("bad row %s: %v", scanner.Text(), err)
+			}
+			rows = append(rows, row)
+		}
+	}))
+	defer server.Close()
+
+	writer, err := NewClickHouseWriter(ClickHouseSettings{URL: server.URL, Database: "calls", Username: "odango", Password: "secret", BatchSize: 2, RetentionDays: 180})
+	if err != nil {
+		t.Fatalf("NewClickHouseWriter: %v", err)
+	}
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"id": "a", "call-start-datetime": "2026-10-12T10:00:00Z", "call-answered-datetime": "2026-10-12T10:00:05Z"}},
+		{RawData: map[string]interface{}{"id": "b"}},
+		{RawData: map[string]interface{}{"id": "c"}},
+	}
+	started := time.Date(2026, 10, 13, 8, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if written, err := writer.WriteCDRs("s1", cdrs, started); err != nil || written != 3 {
+			t.Fatalf("WriteCDRs = %d, %v; want 3 rows", written, err)
+		}
+	}
+
+	// The database and table are created once, then each call inserts two batches
+	if len(statements) != 6 || !strings.HasPrefix(statements[0], "CREATE DATABASE IF NOT EXISTS calls") ||
+		!strings.Contains(statements[1], "CREATE TABLE IF NOT EXISTS calls.cdrs") || !strings.Contains(statements[1], "INTERVAL 180 DAY") {
+		t.Fatalf("statements = %q", statements)
+	}
+	if statements[2] != "INSERT INTO calls.cdrs FORMAT JSONEachRow" {
+		t.Errorf("insert = %q", statements[2])
+	}
+	if len(rows) != 6 || rows[0].StartTime != "2026-10-12 10:00:00.000" || rows[0].AnswerTime == nil || rows[0].Raw == "" {
+		t.Fatalf("rows = %+v", rows)
+	}
+	if rows[1].StartTime != "2026-10-13 08:00:00.000" || rows[1].AnswerTime != nil || rows[1].SessionID != "s1" {
+		t.Errorf("row without a start = %+v, want the session's start", rows[1])
+	}
+
+	if _, err := NewClickHouseWriter(ClickHouseSettings{Table: "cdrs; DROP TABLE x"}); err == nil {
+		t.Error("NewClickHouseWriter accepted an invalid table name")
+	}
+}
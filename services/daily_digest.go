@@ -0,0 +1,301 @@
+// services/daily_digest.go
+// Daily digest: once a day, after a configured hour (UTC), emails the previous day's
+// calls per domain from the recorded call outcomes (calls, minutes, failure rates) with
+// the domains whose numbers moved notably from the week before
+
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// digestCheckInterval is how often the digester looks for a digest that is due
+const digestCheckInterval = 15 * time.Minute
+
+// Anomaly thresholds, measured against the daily average of the 7 days before
+const (
+	digestBaselineDays       = 7
+	digestMinBaselineCalls   = 10   // daily average calls below which call volume isn't compared
+	digestCallChangePercent  = 50.0 // call volume up or down by at least this much
+	digestFailureRiseMinimum = 10.0 // failure rate up by at least this many points
+)
+
+// DigestDomain is one domain's calls for the day, with its average day of the week before
+type DigestDomain struct {
+	Domain          string  `json:"domain"`
+	Calls           int     `json:"calls"`
+	Answered        int     `json:"answered"`
+	Minutes         float64 `json:"minutes"`
+	FailureRate     float64 `json:"failure_rate"`      // percentage of calls not answered
+	NetworkFailRate float64 `json:"network_fail_rate"` // percentage of calls that failed in the network (100 - NER)
+	BaselineCalls   float64 `json:"baseline_calls"`    // daily average of the 7 days before
+	BaselineFailure float64 `json:"baseline_failure_rate"`
+}
+
+// DailyDigest is the summary of one day's calls
+type DailyDigest struct {
+	Day         string         `json:"day"`
+	Domains     []DigestDomain `json:"domains"`
+	Totals      DigestDomain   `json:"totals"`
+	Anomalies   []string       `json:"anomalies"`
+	GeneratedAt time.Time      `json:"generated_at"`
+}
+
+// AlertSummary is the email subject
+func (d DailyDigest) AlertSummary() string {
+	return fmt.Sprintf("CDR digest for %s: %d calls, %.0f minutes, %d anomalies",
+		d.Day, d.Totals.Calls, d.Totals.Minutes, len(d.Anomalies))
+}
+
+// AlertDetails lists the anomalies, then a line per domain busiest first
+func (d DailyDigest) AlertDetails() []string {
+	details := []string{}
+	if len(d.Anomalies) > 0 {
+		details = append(details, "Notable changes from the previous 7 days:")
+		for _, anomaly := range d.Anomalies {
+			details = append(details, "  - "+anomaly)
+		}
+		details = append(details, "")
+	}
+	if len(d.Domains) == 0 {
+		return append(details, "No calls were recorded.")
+	}
+	details = append(details, "Calls per domain:")
+	for _, domain := range d.Domains {
+		details = append(details, "  "+domain.line())
+	}
+	return append(details, "", "  "+d.Totals.line())
+}
+
+// AlertLinks links the KPI report of the day
+func (d DailyDigest) AlertLinks() []AlertLink {
+	return []AlertLink{{Label: "KPI report", URL: "/wr/kpis?group_by=domain&start=" + d.Day + "&end=" + d.Day}}
+}
+
+// line formats a domain's day for the digest
+func (dd DigestDomain) line() string {
+	return fmt.Sprintf("%s: %d calls, %.1f minutes, %.1f%% not answered, %.1f%% network failures",
+		dd.Domain, dd.Calls, dd.Minutes, dd.FailureRate, dd.NetworkFailRate)
+}
+
+// digestDomain converts a KPI row
+func digestDomain(row TelephonyKPIs) DigestDomain {
+	domain := DigestDomain{
+		Domain:   row.Domain,
+		Calls:    row.Seizures,
+		Answered: row.Answered,
+		Minutes:  roundTo(float64(row.TalkSeconds)/60, 1),
+	}
+	if row.Seizures > 0 {
+		domain.FailureRate = roundTo(100*float64(row.Seizures-row.Answered)/float64(row.Seizures), 1)
+		domain.NetworkFailRate = roundTo(100-row.NER, 1)
+	}
+	return domain
+}
+
+// Digester builds the daily digest and emails it
+type Digester struct {
+	db        *DatabaseService
+	notifiers []AlertNotifier
+	sendHour  int // UTC hour after which the previous day's digest is sent
+	linkBase  string
+}
+
+// NewDigester creates a digester that sends through notifiers after sendHour UTC; the
+// KPI report link starts with linkBaseURL
+func NewDigester(db *DatabaseService, notifiers []AlertNotifier, sendHour int, linkBaseURL string) *Digester {
+	return &Digester{
+		db:        db,
+		notifiers: notifiers,
+		sendHour:  sendHour,
+		linkBase:  strings.TrimRight(linkBaseURL, "/"),
+	}
+}
+
+// Start sends each day's digest in the background once it is due
+func (dg *Digester) Start() {
+	if len(dg.notifiers) == 0 {
+		return
+	}
+	if dg.sendHour < 0 || dg.sendHour > 23 {
+		log.Printf("[Digest] Send hour %d is not between 0 and 23, digest disabled", dg.sendHour)
+		return
+	}
+	log.Printf("[Digest] Sending the daily digest after %02d:00 UTC", dg.sendHour)
+
+	go func() {
+		for {
+			if err := dg.SendDue(time.Now()); err != nil {
+				log.Printf("[Digest] %v", err)
+			}
+			time.Sleep(digestCheckInterval)
+		}
+	}()
+}
+
+// SendDue sends the previous day's digest if the send hour has passed and it hasn't
+// been sent yet
+func (dg *Digester) SendDue(now time.Time) error {
+	now = now.UTC()
+	if now.Hour() < dg.sendHour {
+		return nil
+	}
+	day := now.Truncate(24*time.Hour).AddDate(0, 0, -1)
+	sent, err := dg.db.DigestSent(day.Format(kpiDayFormat))
+	if err != nil || sent {
+		return err
+	}
+	_, err = dg.Send(day)
+	return err
+}
+
+// Build summarizes a day's recorded calls without sending anything
+func (dg *Digester) Build(day time.Time) (*DailyDigest, error) {
+	day = day.UTC().Truncate(24 * time.Hour)
+	rows, err := dg.db.GetTelephonyKPIs(KPIQuery{Start: day, End: day, GroupBy: []string{KPIGroupDomain}})
+	if err != nil {
+		return nil, err
+	}
+	baseline, err := dg.db.GetTelephonyKPIs(KPIQuery{
+		Start:   day.AddDate(0, 0, -digestBaselineDays),
+		End:     day.AddDate(0, 0, -1),
+		GroupBy: []string{KPIGroupDomain},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	digest := &DailyDigest{Day: day.Format(kpiDayFormat), Domains: []DigestDomain{}, Anomalies: []string{}, GeneratedAt: time.Now().UTC()}
+	totals := TelephonyKPIs{Domain: "Total"}
+	seen := make(map[string]bool)
+	before := make(map[string]TelephonyKPIs)
+	for _, row := range baseline {
+		before[row.Domain] = row
+	}
+	for _, row := range rows {
+		domain := digestDomain(row)
+		if previous, ok := before[row.Domain]; ok {
+			domain.BaselineCalls = roundTo(float64(previous.Seizures)/digestBaselineDays, 1)
+			domain.BaselineFailure = digestDomain(previous).FailureRate
+		}
+		digest.Domains = append(digest.Domains, domain)
+		digest.Anomalies = append(digest.Anomalies, domain.anomalies()...)
+		seen[row.Domain] = true
+
+		totals.Seizures += row.Seizures
+		totals.Answered += row.Answered
+		totals.NetworkFailures += row.NetworkFailures
+		totals.TalkSeconds += row.TalkSeconds
+	}
+	// Domains that called every other day of the week and went quiet
+	for _, row := range baseline {
+		if !seen[row.Domain] && float64(row.Seizures)/digestBaselineDays >= digestMinBaselineCalls {
+			digest.Anomalies = append(digest.Anomalies, fmt.Sprintf("%s: no calls (%.1f a day the week before)",
+				row.Domain, float64(row.Seizures)/digestBaselineDays))
+		}
+	}
+	totals.computeRatios()
+	digest.Totals = digestDomain(totals)
+
+	sort.SliceStable(digest.Domains, func(i, j int) bool { return digest.Domains[i].Calls > digest.Domains[j].Calls })
+	return digest, nil
+}
+
+// anomalies describes how a domain's day differs notably from its week before
+func (dd DigestDomain) anomalies() []string {
+	found := []string{}
+	if dd.BaselineCalls >= digestMinBaselineCalls {
+		change := 100 * (float64(dd.Calls) - dd.BaselineCalls) / dd.BaselineCalls
+		if math.Abs(change) >= digestCallChangePercent {
+			found = append(found, fmt.Sprintf("%s: %d calls, %+.0f%% from %.1f a day", dd.Domain, dd.Calls, change, dd.BaselineCalls))
+		}
+	}
+	if dd.BaselineCalls > 0 && dd.FailureRate-dd.BaselineFailure >= digestFailureRiseMinimum {
+		found = append(found, fmt.Sprintf("%s: %.1f%% of calls not answered, up from %.1f%%", dd.Domain, dd.FailureRate, dd.BaselineFailure))
+	}
+	return found
+}
+
+// Send builds a day's digest, sends it and records that it was sent. It is recorded
+// when at least one notifier delivered it.
+func (dg *Digester) Send(day time.Time) (*DailyDigest, error) {
+	digest, err := dg.Build(day)
+	if err != nil {
+		return nil, err
+	}
+	if len(dg.notifiers) == 0 {
+		return digest, fmt.Errorf("no digest recipients are configured")
+	}
+
+	sent := []string{}
+	for _, notifier := range dg.notifiers {
+		if err := notifier.Notify(dg.withLinkBase(*digest)); err != nil {
+			log.Printf("[Digest] Failed to send the %s digest by %s: %v", digest.Day, notifier.Name(), err)
+			continue
+		}
+		sent = append(sent, notifier.Name())
+	}
+	if len(sent) == 0 {
+		return digest, fmt.Errorf("the %s digest was not delivered", digest.Day)
+	}
+	if err := dg.db.RecordDigest(digest, sent); err != nil {
+		return digest, err
+	}
+	log.Printf("[Digest] Sent the %s digest: %d calls across %d domains", digest.Day, digest.Totals.Calls, len(digest.Domains))
+	return digest, nil
+}
+
+// withLinkBase makes the digest's links absolute for email
+func (dg *Digester) withLinkBase(digest DailyDigest) Alert {
+	return linkedDigest{DailyDigest: digest, linkBase: dg.linkBase}
+}
+
+// linkedDigest is a digest whose links start with the server's public URL
+type linkedDigest struct {
+	DailyDigest
+	linkBase string
+}
+
+// AlertLinks prefixes the digest's links
+func (ld linkedDigest) AlertLinks() []AlertLink {
+	links := ld.DailyDigest.AlertLinks()
+	for i := range links {
+		links[i].URL = ld.linkBase + links[i].URL
+	}
+	return links
+}
+
+// DigestSent reports whether a day's digest has been sent
+func (ds *DatabaseService) DigestSent(day string) (bool, error) {
+	var found int
+	err := ds.db.QueryRow(`SELECT 1 FROM daily_digests WHERE day = ?`, day).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check digest: %w", err)
+	}
+	return true, nil
+}
+
+// RecordDigest stores a sent digest with the channels it went to
+func (ds *DatabaseService) RecordDigest(digest *DailyDigest, sentTo []string) error {
+	report, err := json.Marshal(digest)
+	if err != nil {
+		return fmt.Errorf("failed to encode digest: %w", err)
+	}
+	if _, err := ds.db.Exec(`
+	INSERT INTO daily_digests (day, report, sent_to, sent_at) VALUES (?, ?, ?, ?)
+	ON CONFLICT(day) DO UPDATE SET report = excluded.report, sent_to = excluded.sent_to, sent_at = excluded.sent_at`,
+		digest.Day, string(report), strings.Join(sentTo, ","), time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to record digest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// capturingNotifier keeps the alerts it is sent
+type capturingNotifier struct {
+	alerts []Alert
+}
+
+func (cn *capturingNotifier) Name() string { return "email" }
+
+func (cn *capturingNotifier) Notify(alert Alert) error {
+	cn.alerts = append(cn.alerts, alert)
+	return nil
+}
+
+func TestDailyDigest(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "digest.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	today := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	yesterday := today.AddDate(0, 0, -1)
+	outcomes := []CallOutcome{}
+	// The week before: acme and globex make 20 calls a day, all answered
+	for day := 2; day <= 8; day++ {
+		for i := 0; i < 20; i++ {
+			for _, domain := range []string{"acme", "globex"} {
+				outcomes = append(outcomes, CallOutcome{CDRID: fmt.Sprintf("%s-%d-%d", domain, day, i), Domain: domain,
+					StartedAt: today.AddDate(0, 0, -day).Add(10 * time.Hour), Answered: true, TalkSeconds: 60})
+			}
+		}
+	}
+	// Yesterday: acme makes 10 calls and half aren't answered; globex makes none
+	for i := 0; i < 10; i++ {
+		outcomes = append(outcomes, CallOutcome{CDRID: fmt.Sprintf("acme-1-%d", i), Domain: "acme",
+			StartedAt: yesterday.Add(10 * time.Hour), Answered: i%2 == 0, NetworkFailure: i == 1, TalkSeconds: 120})
+	}
+	if err := db.SaveCallOutcomes(outcomes); err != nil {
+		t.Fatalf("SaveCallOutcomes: %v", err)
+	}
+
+	email := &capturingNotifier{}
+	digester := NewDigester(db, []AlertNotifier{email}, 7, "https://odango.example.com")
+	digest, err := digester.Build(yesterday)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if len(digest.Domains) != 1 || digest.Domains[0].Calls != 10 || digest.Domains[0].Minutes != 10 ||
+		digest.Domains[0].FailureRate != 50 || digest.Domains[0].NetworkFailRate != 10 || digest.Domains[0].BaselineCalls != 20 {
+		t.Fatalf("digest domains = %+v", digest.Domains)
+	}
+	if len(digest.Anomalies) != 3 || !strings.HasPrefix(digest.Anomalies[0], "acme: 10 calls, -50%") ||
+		!strings.HasPrefix(digest.Anomalies[2], "globex: no calls") {
+		t.Errorf("anomalies = %q", digest.Anomalies)
+	}
+
+	// Not sent before the send hour, sent once after it
+	if err := digester.SendDue(today.Add(6 * time.Hour)); err != nil || len(email.alerts) != 0 {
+		t.Fatalf("SendDue before the hour = %v, sent %d", err, len(email.alerts))
+	}
+	for _, at := range []time.Duration{7 * time.Hour, 8 * time.Hour} {
+		if err := digester.SendDue(today.Add(at)); err != nil {
+			t.Fatalf("SendDue: %v", err)
+		}
+	}
+	if len(email.alerts) != 1 {
+		t.Fatalf("sent %d digests, want 1", len(email.alerts))
+	}
+	sent := email.alerts[0]
+	if !strings.HasPrefix(sent.AlertSummary(), "CDR digest for 2024-03-14: 10 calls") ||
+		sent.AlertLinks()[0].URL != "https://odango.example.com/wr/kpis?group_by=domain&start=2024-03-14&end=2024-03-14" {
+		t.Errorf("sent %q with links %+v", sent.AlertSummary(), sent.AlertLinks())
+	}
+	if sentAlready, err := db.DigestSent("2024-03-14"); err != nil || !sentAlready {
+		t.Errorf("DigestSent = %v, %v", sentAlready, err)
+	}
+}
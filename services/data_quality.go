@@ -0,0 +1,191 @@
+// services/data_quality.go
+// Data quality scoring: how far a search session's CDRs can be trusted before they are
+// exported to billing, from how complete the fields billing needs are, how many times
+// fail to parse, how often endpoints repeat a CDR and how many endpoints failed
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"o-dan-go/models"
+	"sort"
+)
+
+// Weights of each measure in the score, out of 100
+const (
+	qualityWeightCompleteness = 40.0
+	qualityWeightTimes        = 20.0
+	qualityWeightDuplicates   = 20.0
+	qualityWeightEndpoints    = 20.0
+)
+
+// Grades by score
+const (
+	QualityGood = "good" // 90 and above
+	QualityFair = "fair" // 70 and above
+	QualityPoor = "poor"
+)
+
+// qualityRequiredFields are the fields billing needs, each with the names deployments
+// use for it
+var qualityRequiredFields = []struct {
+	name   string
+	fields []string
+}{
+	{"id", []string{"id", "cdr_id"}},
+	{"domain", []string{"domain"}},
+	{"start_time", []string{"call-start-datetime", "start-time"}},
+	{"duration", []string{"call-total-duration-seconds", "call-duration", "duration"}},
+	{"direction", []string{"call-direction", "direction"}},
+	{"orig_number", []string{"call-orig-caller-id", "orig-number"}},
+	{"term_number", []string{"call-term-caller-id", "term-number"}},
+}
+
+// qualityTimeFields are the time fields checked for values GetTime can't parse
+var qualityTimeFields = []string{"call-start-datetime", "call-answer-datetime", "call-end-datetime", "start-time", "end-time"}
+
+// DataQuality scores a session's CDRs out of 100
+type DataQuality struct {
+	Score             float64        `json:"score"`
+	Grade             string         `json:"grade"`
+	Rows              int            `json:"rows"`         // CDRs returned by all endpoints, before deduplication
+	Completeness      float64        `json:"completeness"` // percentage of required fields present
+	MissingFields     map[string]int `json:"missing_fields"`
+	TimeValues        int            `json:"time_values"`
+	TimeParseFailures int            `json:"time_parse_failures"`
+	Duplicates        int            `json:"duplicates"`      // CDRs an endpoint returned more than once
+	DuplicateRatio    float64        `json:"duplicate_ratio"` // percentage of rows
+	EndpointErrorRate float64        `json:"endpoint_error_rate"`
+	Issues            []string       `json:"issues"`
+}
+
+// AssessDataQuality scores a completed search. CDRs found by several endpoints are
+// expected and not counted as duplicates; one endpoint returning a CDR twice is.
+func AssessDataQuality(result *CDRDiscoveryResult) *DataQuality {
+	quality := &DataQuality{MissingFields: map[string]int{}, Issues: []string{}}
+
+	// Measure every row the endpoints returned, as deduplication drops CDRs without an ID
+	endpoints := make([]string, 0, len(result.CDRsByEndpoint))
+	for endpoint := range result.CDRsByEndpoint {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	batches := make([][]models.FlexibleCDR, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		batches = append(batches, result.CDRsByEndpoint[endpoint])
+	}
+	if len(batches) == 0 {
+		batches = append(batches, result.AllCDRs)
+	}
+
+	present := 0
+	for _, cdrs := range batches {
+		seen := make(map[string]bool, len(cdrs))
+		for i := range cdrs {
+			cdr := &cdrs[i]
+			quality.Rows++
+			for _, required := range qualityRequiredFields {
+				if qualityHasField(cdr, required.fields) {
+					present++
+				} else {
+					quality.MissingFields[required.name]++
+				}
+			}
+			for _, field := range qualityTimeFields {
+				if cdr.GetString(field) == "" {
+					continue
+				}
+				quality.TimeValues++
+				if _, err := cdr.GetTime(field); err != nil {
+					quality.TimeParseFailures++
+				}
+			}
+			if id := cdr.GetID(); id != "" {
+				if seen[id] {
+					quality.Duplicates++
+				}
+				seen[id] = true
+			}
+		}
+	}
+
+	failed := 0
+	for _, endpoint := range result.EndpointResults {
+		if !endpoint.Success {
+			failed++
+		}
+	}
+
+	completeness, timesParsed, unique, endpointsUp := 1.0, 1.0, 1.0, 1.0
+	if quality.Rows > 0 {
+		completeness = float64(present) / float64(quality.Rows*len(qualityRequiredFields))
+		unique = 1 - float64(quality.Duplicates)/float64(quality.Rows)
+	}
+	if quality.TimeValues > 0 {
+		timesParsed = 1 - float64(quality.TimeParseFailures)/float64(quality.TimeValues)
+	}
+	if len(result.EndpointResults) > 0 {
+		endpointsUp = 1 - float64(failed)/float64(len(result.EndpointResults))
+	}
+	quality.Completeness = roundTo(100*completeness, 1)
+	quality.DuplicateRatio = roundTo(100*(1-unique), 1)
+	quality.EndpointErrorRate = roundTo(100*(1-endpointsUp), 1)
+	quality.Score = roundTo(qualityWeightCompleteness*completeness+qualityWeightTimes*timesParsed+
+		qualityWeightDuplicates*unique+qualityWeightEndpoints*endpointsUp, 1)
+	switch {
+	case quality.Score >= 90:
+		quality.Grade = QualityGood
+	case quality.Score >= 70:
+		quality.Grade = QualityFair
+	default:
+		quality.Grade = QualityPoor
+	}
+
+	for _, required := range qualityRequiredFields {
+		if missing := quality.MissingFields[required.name]; missing > 0 {
+			quality.Issues = append(quality.Issues, fmt.Sprintf("%d of %d CDRs have no %s", missing, quality.Rows, required.name))
+		}
+	}
+	if quality.TimeParseFailures > 0 {
+		quality.Issues = append(quality.Issues, fmt.Sprintf("%d of %d times could not be parsed", quality.TimeParseFailures, quality.TimeValues))
+	}
+	if quality.Duplicates > 0 {
+		quality.Issues = append(quality.Issues, fmt.Sprintf("%d CDRs were returned more than once by the same endpoint", quality.Duplicates))
+	}
+	if failed > 0 {
+		quality.Issues = append(quality.Issues, fmt.Sprintf("%d of %d endpoints failed", failed, len(result.EndpointResults)))
+	}
+	if quality.Rows == 0 {
+		quality.Issues = append(quality.Issues, "No CDRs were found")
+	}
+	return quality
+}
+
+// qualityHasField reports whether a CDR has a non-empty value for any of the names
+func qualityHasField(cdr *models.FlexibleCDR, fields []string) bool {
+	for _, field := range fields {
+		if cdr.GetString(field) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordQuality stores a search's quality score
+func (ss *SearchStorage) RecordQuality(sessionID string, quality *DataQuality) error {
+	return ss.db.RecordSessionQuality(sessionID, quality)
+}
+
+// RecordSessionQuality stores a session's quality score on the search session
+func (ds *DatabaseService) RecordSessionQuality(sessionID string, quality *DataQuality) error {
+	report, err := json.Marshal(quality)
+	if err != nil {
+		return fmt.Errorf("failed to encode data quality: %w", err)
+	}
+	if _, err := ds.db.Exec(`UPDATE search_sessions SET quality_score = ?, quality = ? WHERE session_id = ?`,
+		quality.Score, string(report), sessionID); err != nil {
+		return fmt.Errorf("failed to record data quality: %w", err)
+	}
+	return nil
+}
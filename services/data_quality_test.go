@@ -0,0 +1,63 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestAssessDataQuality(t *testing.T) {
+	complete := func(id string) models.FlexibleCDR {
+		return models.FlexibleCDR{RawData: map[string]interface{}{
+			"id": id, "domain": "acme", "call-start-datetime": "2024-03-01T10:00:00Z", "call-total-duration-seconds": float64(60),
+			"call-direction": float64(1), "call-orig-caller-id": float64(15555551234), "call-term-caller-id": float64(15555559876),
+		}}
+	}
+
+	clean := &CDRDiscoveryResult{
+		EndpointResults: []EndpointResult{{EndpointName: "domain_cdrs", Success: true}, {EndpointName: "global_cdrs", Success: true}},
+		CDRsByEndpoint: map[string][]models.FlexibleCDR{
+			"domain_cdrs": {complete("a"), complete("b")},
+			"global_cdrs": {complete("a")}, // the same CDR from another endpoint isn't a duplicate
+		},
+	}
+	if quality := AssessDataQuality(clean); quality.Score != 100 || quality.Grade != QualityGood || len(quality.Issues) != 0 {
+		t.Errorf("clean session quality = %+v", quality)
+	}
+
+	badTime := complete("c")
+	badTime.RawData["call-start-datetime"] = "yesterday"
+	noNumbers := complete("d")
+	delete(noNumbers.RawData, "call-orig-caller-id")
+	delete(noNumbers.RawData, "call-term-caller-id")
+	messy := &CDRDiscoveryResult{
+		EndpointResults: []EndpointResult{{EndpointName: "domain_cdrs", Success: true}, {EndpointName: "user_cdrs", Error: "HTTP 500"}},
+		CDRsByEndpoint:  map[string][]models.FlexibleCDR{"domain_cdrs": {badTime, noNumbers, complete("e"), complete("e")}},
+	}
+	quality := AssessDataQuality(messy)
+	if quality.Rows != 4 || quality.MissingFields["orig_number"] != 1 || quality.TimeParseFailures != 1 || quality.Duplicates != 1 ||
+		quality.EndpointErrorRate != 50 || quality.Grade != QualityFair || len(quality.Issues) != 5 {
+		t.Fatalf("messy session quality = %+v", quality)
+	}
+	// 40 * 26/28 + 20 * 3/4 + 20 * 3/4 + 20 * 1/2
+	if quality.Score != 77.1 {
+		t.Errorf("score = %v, want 77.1", quality.Score)
+	}
+
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "quality.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+	if err := db.StoreSearchSession("session-1", CDRSearchCriteria{}, 4); err != nil {
+		t.Fatalf("StoreSearchSession: %v", err)
+	}
+	if err := db.RecordSessionQuality("session-1", quality); err != nil {
+		t.Fatalf("RecordSessionQuality: %v", err)
+	}
+	var score float64
+	if err := db.db.QueryRow(`SELECT quality_score FROM search_sessions WHERE session_id = 'session-1'`).Scan(&score); err != nil || score != 77.1 {
+		t.Errorf("stored score = %v, %v", score, err)
+	}
+}
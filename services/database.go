@@ -7,6 +7,9 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"o-dan-go/models"
@@ -16,21 +19,83 @@ import (
 
 type DatabaseService struct {
 	db *sql.DB
+
+	// Statements run on every search or report are prepared once and kept until Close
+	stmtMu sync.Mutex
+	stmts  map[string]*sql.Stmt
+}
+
+// DatabaseSettings tunes the SQLite connection. Zero values keep the driver's defaults.
+type DatabaseSettings struct {
+	JournalMode     string        // DELETE, TRUNCATE, PERSIST, MEMORY, WAL or OFF
+	BusyTimeout     time.Duration // how long a statement waits on a locked database before failing
+	Synchronous     string        // OFF, NORMAL, FULL or EXTRA
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
 }
 
+var (
+	sqliteJournalModes = map[string]bool{"DELETE": true, "TRUNCATE": true, "PERSIST": true, "MEMORY": true, "WAL": true, "OFF": true}
+	sqliteSynchronous  = map[string]bool{"OFF": true, "NORMAL": true, "FULL": true, "EXTRA": true}
+)
+
 // NewDatabaseService creates a new database service instance
 func NewDatabaseService(dbPath string) (*DatabaseService, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	return NewDatabaseServiceWithSettings(dbPath, DatabaseSettings{})
+}
+
+// NewDatabaseServiceWithSettings creates a database service with tuned pragmas and pool
+// settings. The pragmas are passed in the DSN so every pooled connection gets them.
+func NewDatabaseServiceWithSettings(dbPath string, settings DatabaseSettings) (*DatabaseService, error) {
+	params := url.Values{}
+	if mode := strings.ToUpper(settings.JournalMode); mode != "" {
+		if !sqliteJournalModes[mode] {
+			return nil, fmt.Errorf("invalid journal mode %q", settings.JournalMode)
+		}
+		params.Set("_journal_mode", mode)
+	}
+	if settings.BusyTimeout > 0 {
+		params.Set("_busy_timeout", fmt.Sprint(settings.BusyTimeout.Milliseconds()))
+	}
+	if level := strings.ToUpper(settings.Synchronous); level != "" {
+		if !sqliteSynchronous[level] {
+			return nil, fmt.Errorf("invalid synchronous level %q", settings.Synchronous)
+		}
+		params.Set("_synchronous", level)
+	}
+	dsn := dbPath
+	if len(params) > 0 {
+		separator := "?"
+		if strings.Contains(dbPath, "?") {
+			separator = "&"
+		}
+		dsn += separator + params.Encode()
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	if settings.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(settings.MaxOpenConns)
+	}
+	if settings.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(settings.MaxIdleConns)
+	}
+	if settings.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(settings.ConnMaxLifetime)
+	}
 
 	// Test the connection
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	service := &DatabaseService{db: db}
+	service := &DatabaseService{
+		db:    db,
+		stmts: map[string]*sql.Stmt{},
+	}
 
 	// Create tables if they don't exist
 	if err := service.createTables(); err != nil {
@@ -40,11 +105,33 @@ func NewDatabaseService(dbPath string) (*DatabaseService, error) {
 	return service, nil
 }
 
-// Close closes the database connection
+// Close closes the cached statements and the database connection
 func (ds *DatabaseService) Close() error {
+	ds.stmtMu.Lock()
+	for query, stmt := range ds.stmts {
+		stmt.Close()
+		delete(ds.stmts, query)
+	}
+	ds.stmtMu.Unlock()
 	return ds.db.Close()
 }
 
+// prepared returns the cached prepared statement for query, preparing it on first use.
+// Queries built from optional filters are cached per variant, so there are only a few.
+func (ds *DatabaseService) prepared(query string) (*sql.Stmt, error) {
+	ds.stmtMu.Lock()
+	defer ds.stmtMu.Unlock()
+	if stmt, ok := ds.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := ds.db.Prepare(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	ds.stmts[query] = stmt
+	return stmt, nil
+}
+
 // createTables creates the simplified MVP-focused tables
 func (ds *DatabaseService) createTables() error {
 	// CDR Summaries - core processed CDR data
@@ -64,6 +151,7 @@ func (ds *DatabaseService) createTables() error {
 		field_count INTEGER,
 		has_transcription BOOLEAN DEFAULT 0,
 		has_sentiment BOOLEAN DEFAULT 0,
+		call_class TEXT,                -- answered, abandoned or missed
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);`
 
@@ -75,9 +163,45 @@ func (ds *DatabaseService) createTables() error {
 		total_cdrs INTEGER DEFAULT 0,
 		start_time DATETIME NOT NULL,
 		end_time DATETIME,
+		stored_cdrs INTEGER DEFAULT 0,  -- CDR summaries written for the session
+		store_duration_ms INTEGER DEFAULT 0,
+		store_rows_per_second REAL DEFAULT 0,
+		rerun_of TEXT,                  -- session whose criteria this search repeated
+		quality_score REAL,             -- data quality score out of 100
+		quality TEXT,                   -- JSON of the data quality assessment
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 	);`
 
+	// Session CDRs - which stored CDR summaries each search session found
+	createSessionCDRsTable := `
+	CREATE TABLE IF NOT EXISTS session_cdrs (
+		session_id TEXT NOT NULL,
+		cdr_id TEXT NOT NULL,
+		PRIMARY KEY (session_id, cdr_id)
+	);`
+
+	// Session Notes - analysts' comments on a session or one of its CDRs
+	createSessionNotesTable := `
+	CREATE TABLE IF NOT EXISTS session_notes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id TEXT NOT NULL,
+		cdr_id TEXT NOT NULL DEFAULT '',  -- empty for a note on the whole session
+		author TEXT NOT NULL,
+		text TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);`
+
+	// CDR Flags - CDRs of a session marked as being of interest
+	createCDRFlagsTable := `
+	CREATE TABLE IF NOT EXISTS cdr_flags (
+		session_id TEXT NOT NULL,
+		cdr_id TEXT NOT NULL,
+		flagged_by TEXT NOT NULL,
+		reason TEXT NOT NULL DEFAULT '',
+		flagged_at DATETIME NOT NULL,
+		PRIMARY KEY (session_id, cdr_id)
+	);`
+
 	// Generated Reports - stores user-generated reports
 	createReportsTable := `
 	CREATE TABLE IF NOT EXISTS reports (
@@ -92,11 +216,476 @@ func (ds *DatabaseService) createTables() error {
 		FOREIGN KEY (session_id) REFERENCES search_sessions(session_id)
 	);`
 
+	// User Preferences - per-user defaults for search, results and exports
+	createUserPreferencesTable := `
+	CREATE TABLE IF NOT EXISTS user_preferences (
+		user_id TEXT PRIMARY KEY,
+		default_limit INTEGER NOT NULL DEFAULT 100,
+		default_export_format TEXT NOT NULL DEFAULT 'csv',
+		visible_columns TEXT NOT NULL,  -- JSON array of column names
+		export_fields TEXT,             -- JSON array of computed columns (name, JMESPath expression)
+		timezone TEXT NOT NULL DEFAULT 'UTC',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// IVR Recordings - voicemails and callback requests captured by IVR apps
+	createRecordingsTable := `
+	CREATE TABLE IF NOT EXISTS ivr_recordings (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		recording_id TEXT UNIQUE NOT NULL,  -- RecordingSid, or the URL if none was sent
+		call_id TEXT,
+		session_id TEXT,
+		app TEXT,
+		kind TEXT,                          -- voicemail, callback
+		caller_number TEXT,
+		recording_url TEXT,
+		duration_seconds INTEGER DEFAULT 0,
+		status TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// SMS Consent - callers who opted in to (or out of) text message follow-ups
+	createSMSConsentTable := `
+	CREATE TABLE IF NOT EXISTS sms_consent (
+		phone_number TEXT PRIMARY KEY,  -- 10 digit NANP number
+		opted_in BOOLEAN NOT NULL,
+		source TEXT,                    -- where consent was given, e.g. ivr:weather
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// IVR Sessions - server-side Web Responder sessions keyed by call ID
+	createIVRSessionsTable := `
+	CREATE TABLE IF NOT EXISTS ivr_sessions (
+		session_key TEXT PRIMARY KEY,  -- <session name>:<call ID>
+		data BLOB NOT NULL,            -- gob-encoded session values
+		expires_at DATETIME NOT NULL
+	);`
+
+	// Web Responder Calls - one row per IVR call, updated from call events
+	createWRCallsTable := `
+	CREATE TABLE IF NOT EXISTS wr_calls (
+		call_id TEXT PRIMARY KEY,
+		session_id TEXT,
+		caller_number TEXT,
+		area_code TEXT,
+		location TEXT,
+		entry_app TEXT,                 -- app the caller dialed into
+		selections INTEGER DEFAULT 0,
+		invalid_selections INTEGER DEFAULT 0,
+		errors INTEGER DEFAULT 0,
+		last_action TEXT,
+		outcome TEXT NOT NULL,          -- in_progress, completed, transferred
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME,
+		duration_seconds INTEGER DEFAULT 0
+	);`
+
+	// Web Responder Selections - every digit (or spoken choice) pressed in an IVR menu
+	createWRCallSelectionsTable := `
+	CREATE TABLE IF NOT EXISTS wr_call_selections (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		call_id TEXT NOT NULL,
+		app TEXT,
+		digits TEXT,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY (call_id) REFERENCES wr_calls(call_id)
+	);`
+
+	createWREventsTable := `
+	CREATE TABLE IF NOT EXISTS wr_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		app TEXT,
+		session_id TEXT,
+		call_id TEXT,
+		caller_number TEXT,
+		area_code TEXT,
+		location TEXT,
+		event_type TEXT NOT NULL,
+		details TEXT,
+		created_at DATETIME NOT NULL
+	);`
+
+	createBusinessSchedulesTable := `
+	CREATE TABLE IF NOT EXISTS business_schedules (
+		did TEXT PRIMARY KEY,
+		timezone TEXT NOT NULL,
+		on_call TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	createBusinessHoursTable := `
+	CREATE TABLE IF NOT EXISTS business_hours (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		did TEXT NOT NULL,
+		weekday INTEGER NOT NULL,
+		open_time TEXT NOT NULL,
+		close_time TEXT NOT NULL,
+		FOREIGN KEY (did) REFERENCES business_schedules(did)
+	);`
+
+	createHolidaysTable := `
+	CREATE TABLE IF NOT EXISTS holidays (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		did TEXT NOT NULL,
+		date TEXT NOT NULL,
+		name TEXT NOT NULL,
+		UNIQUE(did, date)
+	);`
+
+	// Archived Recordings - manifest of call recordings copied out of NetSapiens
+	createArchivedRecordingsTable := `
+	CREATE TABLE IF NOT EXISTS archived_recordings (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		cdr_id TEXT NOT NULL,
+		recording_id TEXT NOT NULL,
+		session_id TEXT,                -- search session the CDR was archived from
+		call_id TEXT,
+		domain TEXT,
+		storage TEXT NOT NULL,          -- local, s3
+		storage_key TEXT NOT NULL,
+		location TEXT,                  -- file path or s3:// URL
+		content_type TEXT,
+		size_bytes INTEGER DEFAULT 0,
+		archived_at DATETIME NOT NULL,
+		UNIQUE(cdr_id, recording_id)
+	);`
+
+	// Recording Transcripts - speech-to-text of archived recordings, one per recording
+	createRecordingTranscriptsTable := `
+	CREATE TABLE IF NOT EXISTS recording_transcripts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		archived_recording_id INTEGER NOT NULL UNIQUE,
+		cdr_id TEXT NOT NULL,
+		recording_id TEXT,
+		domain TEXT,
+		backend TEXT NOT NULL,          -- whisper, deepgram
+		language TEXT,
+		text TEXT,
+		status TEXT NOT NULL,           -- completed, failed
+		error TEXT,
+		attempts INTEGER DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`
+
+	// Transcript full-text index; docid is the recording_transcripts id
+	createTranscriptsFTSTable := `
+	CREATE VIRTUAL TABLE IF NOT EXISTS transcripts_fts USING fts4(text, tokenize=porter);`
+
+	// Transcript Sentiments - scores for transcribed calls without NetSapiens call intelligence
+	createTranscriptSentimentsTable := `
+	CREATE TABLE IF NOT EXISTS transcript_sentiments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		transcript_id INTEGER NOT NULL UNIQUE,
+		cdr_id TEXT NOT NULL,
+		analyzer TEXT NOT NULL,         -- lexicon, http
+		score REAL NOT NULL,            -- -1 (negative) to 1 (positive)
+		label TEXT NOT NULL,
+		percent_positive REAL DEFAULT 0,
+		percent_neutral REAL DEFAULT 0,
+		percent_negative REAL DEFAULT 0,
+		created_at DATETIME NOT NULL
+	);`
+
+	// Keyword Lists - phrases transcripts are scanned for
+	createKeywordListsTable := `
+	CREATE TABLE IF NOT EXISTS keyword_lists (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		keywords TEXT NOT NULL,         -- JSON array of lowercase phrases
+		domain TEXT,                    -- empty scans every domain
+		disabled BOOLEAN DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`
+
+	// Keyword Alerts - transcripts that matched a keyword list
+	createKeywordAlertsTable := `
+	CREATE TABLE IF NOT EXISTS keyword_alerts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		list_id INTEGER NOT NULL,
+		list_name TEXT NOT NULL,
+		transcript_id INTEGER NOT NULL,
+		cdr_id TEXT NOT NULL,
+		session_id TEXT,
+		domain TEXT,
+		keywords TEXT NOT NULL,         -- JSON array of the phrases found
+		excerpt TEXT NOT NULL,
+		transcript_url TEXT NOT NULL,
+		cdr_url TEXT,
+		created_at DATETIME NOT NULL,
+		UNIQUE(list_id, transcript_id)
+	);`
+
+	// Number Enrichments - cached carrier and LRN lookups
+	createNumberEnrichmentsTable := `
+	CREATE TABLE IF NOT EXISTS number_enrichments (
+		number TEXT PRIMARY KEY,        -- E.164
+		provider TEXT NOT NULL,
+		carrier TEXT,
+		line_type TEXT,
+		lrn TEXT,
+		ported BOOLEAN DEFAULT 0,
+		looked_up_at DATETIME NOT NULL
+	);`
+
+	// Caller Names - cached CNAM lookups ('' when a number has no name)
+	createCallerNamesTable := `
+	CREATE TABLE IF NOT EXISTS caller_names (
+		number TEXT PRIMARY KEY,        -- E.164
+		caller_name TEXT,
+		provider TEXT NOT NULL,
+		looked_up_at DATETIME NOT NULL
+	);`
+
+	// Fraud Alerts - suspected toll fraud found in search results
+	createFraudAlertsTable := `
+	CREATE TABLE IF NOT EXISTS fraud_alerts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		rule TEXT NOT NULL,
+		domain TEXT,
+		user TEXT NOT NULL,             -- user@domain
+		session_id TEXT NOT NULL,
+		description TEXT NOT NULL,
+		evidence TEXT NOT NULL,         -- JSON evidence report
+		cdr_url TEXT,
+		created_at DATETIME NOT NULL,
+		UNIQUE(session_id, rule, user)
+	);`
+
+	// Spam Scores - robocall/spam likelihood of inbound calls
+	createSpamScoresTable := `
+	CREATE TABLE IF NOT EXISTS spam_scores (
+		cdr_id TEXT PRIMARY KEY,
+		session_id TEXT,
+		domain TEXT,
+		caller TEXT NOT NULL,           -- E.164 when it can be normalized
+		called TEXT,
+		score INTEGER NOT NULL,         -- 0-100
+		reasons TEXT NOT NULL,          -- JSON array
+		call_start DATETIME,
+		duration INTEGER,
+		scored_at DATETIME NOT NULL
+	);`
+
+	// Call Outcomes - how each call ended, for the telephony KPIs
+	createCallOutcomesTable := `
+	CREATE TABLE IF NOT EXISTS call_outcomes (
+		cdr_id TEXT PRIMARY KEY,
+		domain TEXT,
+		trunk TEXT,
+		call_day TEXT NOT NULL,         -- YYYY-MM-DD in UTC
+		call_start DATETIME NOT NULL,
+		answered BOOLEAN NOT NULL,
+		network_failure BOOLEAN NOT NULL,
+		talk_seconds INTEGER NOT NULL DEFAULT 0,
+		recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	// Billing Invoices - carrier invoices imported for reconciliation with the CDRs
+	createBillingInvoicesTable := `
+	CREATE TABLE IF NOT EXISTS billing_invoices (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		carrier TEXT NOT NULL,
+		filename TEXT,
+		period_start DATETIME NOT NULL, -- first and last billed calls
+		period_end DATETIME NOT NULL,
+		lines INTEGER NOT NULL,
+		total_amount REAL NOT NULL DEFAULT 0,
+		imported_at DATETIME NOT NULL
+	);`
+
+	// Invoice Lines - one billed call each
+	createInvoiceLinesTable := `
+	CREATE TABLE IF NOT EXISTS invoice_lines (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		invoice_id INTEGER NOT NULL,
+		line INTEGER NOT NULL,          -- row in the imported CSV
+		number TEXT NOT NULL,
+		calling_number TEXT,
+		call_start DATETIME NOT NULL,
+		billed_seconds INTEGER NOT NULL,
+		amount REAL NOT NULL DEFAULT 0,
+		FOREIGN KEY (invoice_id) REFERENCES billing_invoices(id)
+	);`
+
+	// Database Snapshots - size and row counts recorded by maintenance, for growth trends
+	createDatabaseSnapshotsTable := `
+	CREATE TABLE IF NOT EXISTS database_snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		taken_at DATETIME NOT NULL,
+		size_bytes INTEGER NOT NULL,
+		free_bytes INTEGER NOT NULL DEFAULT 0,
+		table_rows TEXT NOT NULL        -- JSON object of table name to row count
+	);`
+
+	// Scheduled Searches - saved searches run on an interval
+	createScheduledSearchesTable := `
+	CREATE TABLE IF NOT EXISTS scheduled_searches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		criteria TEXT NOT NULL,         -- JSON of search parameters
+		lookback_days INTEGER NOT NULL DEFAULT 0,
+		interval_minutes INTEGER NOT NULL,
+		drift_alert_percent REAL NOT NULL DEFAULT 0,
+		drift_alert_missing INTEGER NOT NULL DEFAULT 0,
+		reports TEXT,                   -- JSON list of session report types
+		exports TEXT,                   -- JSON list of export destinations
+		disabled BOOLEAN DEFAULT 0,
+		last_run_at DATETIME,
+		last_session_id TEXT,           -- last successful run
+		last_error TEXT,
+		last_endpoints TEXT,            -- JSON endpoint results of the last successful run
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`
+
+	// Scheduled Search CDRs - the CDR IDs the last run of each search found
+	createScheduledSearchCDRsTable := `
+	CREATE TABLE IF NOT EXISTS scheduled_search_cdrs (
+		search_id INTEGER NOT NULL,
+		cdr_id TEXT NOT NULL,
+		PRIMARY KEY (search_id, cdr_id)
+	);`
+
+	// Search Drift - how each scheduled run differed from the one before it
+	createSearchDriftTable := `
+	CREATE TABLE IF NOT EXISTS search_drift (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		search_id INTEGER NOT NULL,
+		session_id TEXT NOT NULL,
+		previous_session_id TEXT NOT NULL,
+		previous_cdrs INTEGER NOT NULL,
+		current_cdrs INTEGER NOT NULL,
+		change_percent REAL NOT NULL,
+		new_count INTEGER NOT NULL,
+		missing_count INTEGER NOT NULL,
+		new_cdr_ids TEXT NOT NULL,      -- JSON array, first 100
+		missing_cdr_ids TEXT NOT NULL,  -- JSON array, first 100
+		endpoints TEXT NOT NULL,        -- JSON array of endpoint count changes
+		alerted BOOLEAN DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY (search_id) REFERENCES scheduled_searches(id)
+	);`
+
+	// Alert Rules - conditions on searches and call analytics that send alerts
+	createAlertRulesTable := `
+	CREATE TABLE IF NOT EXISTS alert_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		metric TEXT NOT NULL,
+		operator TEXT NOT NULL,
+		threshold REAL NOT NULL,
+		domain TEXT,                    -- empty for every domain
+		filter TEXT,                    -- CDR filter expression for CDR metrics
+		window_days INTEGER NOT NULL DEFAULT 1,
+		cooldown_minutes INTEGER NOT NULL DEFAULT 60,
+		actions TEXT NOT NULL,          -- JSON array of channels, empty for all
+		disabled BOOLEAN DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`
+
+	// Alert Rule Events - each time a rule held
+	createAlertRuleEventsTable := `
+	CREATE TABLE IF NOT EXISTS alert_rule_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		rule_id INTEGER NOT NULL,
+		rule_name TEXT NOT NULL,
+		metric TEXT NOT NULL,
+		operator TEXT NOT NULL,
+		threshold REAL NOT NULL,
+		value REAL NOT NULL,
+		domain TEXT,
+		session_id TEXT,
+		results_url TEXT,
+		notified TEXT NOT NULL,         -- JSON array of channels the alert went to
+		created_at DATETIME NOT NULL
+	);`
+
+	// Daily Digests - the digest of each day that was sent
+	createDailyDigestsTable := `
+	CREATE TABLE IF NOT EXISTS daily_digests (
+		day TEXT PRIMARY KEY,           -- YYYY-MM-DD, UTC
+		report TEXT NOT NULL,           -- JSON digest as sent
+		sent_to TEXT NOT NULL,          -- comma-separated channels
+		sent_at DATETIME NOT NULL
+	);`
+
+	// Discovery Analytics - how each endpoint answered each combination of search
+	// parameters, per NetSapiens base URL and domain, with a row per day for trends
+	createDiscoveryAnalyticsTable := `
+	CREATE TABLE IF NOT EXISTS discovery_analytics (
+		base_url TEXT NOT NULL,
+		endpoint_name TEXT NOT NULL,
+		param_combination TEXT NOT NULL, -- sorted criteria fields, e.g. "domain,start_date"
+		domain TEXT NOT NULL DEFAULT '',
+		query_count INTEGER NOT NULL DEFAULT 0,
+		success_count INTEGER NOT NULL DEFAULT 0,
+		data_count INTEGER NOT NULL DEFAULT 0, -- successful queries that returned CDRs
+		record_count INTEGER NOT NULL DEFAULT 0,
+		total_query_ms INTEGER NOT NULL DEFAULT 0,
+		denied_count INTEGER NOT NULL DEFAULT 0, -- queries answered 403 or 404
+		last_status INTEGER,
+		last_error TEXT,
+		last_queried_at DATETIME NOT NULL,
+		last_success_at DATETIME,
+		PRIMARY KEY (base_url, endpoint_name, param_combination, domain)
+	);`
+
+	createDiscoveryAnalyticsDailyTable := `
+	CREATE TABLE IF NOT EXISTS discovery_analytics_daily (
+		base_url TEXT NOT NULL,
+		endpoint_name TEXT NOT NULL,
+		domain TEXT NOT NULL DEFAULT '',
+		day TEXT NOT NULL,              -- YYYY-MM-DD, UTC
+		query_count INTEGER NOT NULL DEFAULT 0,
+		success_count INTEGER NOT NULL DEFAULT 0,
+		record_count INTEGER NOT NULL DEFAULT 0,
+		total_query_ms INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (base_url, endpoint_name, domain, day)
+	);`
+
 	// Execute table creation
 	queries := []string{
 		createCDRSummaryTable,
 		createSearchSessionsTable,
+		createSessionCDRsTable,
+		createSessionNotesTable,
+		createCDRFlagsTable,
 		createReportsTable,
+		createUserPreferencesTable,
+		createRecordingsTable,
+		createSMSConsentTable,
+		createIVRSessionsTable,
+		createWRCallsTable,
+		createWRCallSelectionsTable,
+		createWREventsTable,
+		createBusinessSchedulesTable,
+		createBusinessHoursTable,
+		createHolidaysTable,
+		createArchivedRecordingsTable,
+		createRecordingTranscriptsTable,
+		createTranscriptsFTSTable,
+		createTranscriptSentimentsTable,
+		createKeywordListsTable,
+		createKeywordAlertsTable,
+		createNumberEnrichmentsTable,
+		createCallerNamesTable,
+		createFraudAlertsTable,
+		createSpamScoresTable,
+		createCallOutcomesTable,
+		createBillingInvoicesTable,
+		createInvoiceLinesTable,
+		createDatabaseSnapshotsTable,
+		createScheduledSearchesTable,
+		createScheduledSearchCDRsTable,
+		createSearchDriftTable,
+		createAlertRulesTable,
+		createAlertRuleEventsTable,
+		createDailyDigestsTable,
+		createDiscoveryAnalyticsTable,
+		createDiscoveryAnalyticsDailyTable,
 	}
 
 	for _, query := range queries {
@@ -105,17 +694,105 @@ func (ds *DatabaseService) createTables() error {
 		}
 	}
 
+	// Databases created before these columns existed get them added
+	if err := ds.addMissingColumns("cdr_summaries", [][2]string{
+		{"call_class", "TEXT"},
+	}); err != nil {
+		return err
+	}
+	if err := ds.addMissingColumns("search_sessions", [][2]string{
+		{"stored_cdrs", "INTEGER DEFAULT 0"},
+		{"store_duration_ms", "INTEGER DEFAULT 0"},
+		{"store_rows_per_second", "REAL DEFAULT 0"},
+		{"rerun_of", "TEXT"},
+		{"quality_score", "REAL"},
+		{"quality", "TEXT"},
+	}); err != nil {
+		return err
+	}
+	if err := ds.addMissingColumns("alert_rules", [][2]string{
+		{"filter", "TEXT"},
+	}); err != nil {
+		return err
+	}
+	if err := ds.addMissingColumns("user_preferences", [][2]string{
+		{"export_fields", "TEXT"},
+	}); err != nil {
+		return err
+	}
+	if err := ds.addMissingColumns("discovery_analytics", [][2]string{
+		{"denied_count", "INTEGER NOT NULL DEFAULT 0"},
+	}); err != nil {
+		return err
+	}
+	if err := ds.addMissingColumns("scheduled_searches", [][2]string{
+		{"reports", "TEXT"},
+		{"exports", "TEXT"},
+	}); err != nil {
+		return err
+	}
+
 	// Create basic indexes for performance
 	return ds.createIndexes()
 }
 
+// addMissingColumns adds the named columns (name, definition) a table doesn't have yet
+func (ds *DatabaseService) addMissingColumns(table string, columns [][2]string) error {
+	rows, err := ds.db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	existing := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read columns of %s: %w", table, err)
+		}
+		existing[name] = true
+	}
+	rows.Close()
+
+	for _, column := range columns {
+		if existing[column[0]] {
+			continue
+		}
+		if _, err := ds.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column[0], column[1])); err != nil {
+			return fmt.Errorf("failed to add %s.%s: %w", table, column[0], err)
+		}
+	}
+	return nil
+}
+
 // createIndexes creates minimal indexes for MVP performance
 func (ds *DatabaseService) createIndexes() error {
 	indexes := []string{
 		`CREATE INDEX IF NOT EXISTS idx_cdr_summaries_domain ON cdr_summaries(domain)`,
 		`CREATE INDEX IF NOT EXISTS idx_cdr_summaries_start_time ON cdr_summaries(call_start_time)`,
 		`CREATE INDEX IF NOT EXISTS idx_search_sessions_start_time ON search_sessions(start_time)`,
+		`CREATE INDEX IF NOT EXISTS idx_search_sessions_rerun_of ON search_sessions(rerun_of)`,
+		`CREATE INDEX IF NOT EXISTS idx_session_cdrs_cdr_id ON session_cdrs(cdr_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_session_notes_session_id ON session_notes(session_id, cdr_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_search_drift_search_id ON search_drift(search_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_alert_rule_events_rule_id ON alert_rule_events(rule_id, domain, created_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_reports_session_id ON reports(session_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_ivr_recordings_created_at ON ivr_recordings(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_wr_calls_started_at ON wr_calls(started_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_wr_call_selections_created_at ON wr_call_selections(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_wr_events_created_at ON wr_events(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_business_hours_did ON business_hours(did)`,
+		`CREATE INDEX IF NOT EXISTS idx_archived_recordings_session_id ON archived_recordings(session_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_archived_recordings_archived_at ON archived_recordings(archived_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_recording_transcripts_cdr_id ON recording_transcripts(cdr_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_transcript_sentiments_cdr_id ON transcript_sentiments(cdr_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_keyword_alerts_created_at ON keyword_alerts(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_fraud_alerts_created_at ON fraud_alerts(created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_spam_scores_domain_start ON spam_scores(domain, call_start)`,
+		`CREATE INDEX IF NOT EXISTS idx_spam_scores_caller ON spam_scores(caller)`,
+		`CREATE INDEX IF NOT EXISTS idx_call_outcomes_day ON call_outcomes(call_day, domain)`,
+		`CREATE INDEX IF NOT EXISTS idx_invoice_lines_invoice_id ON invoice_lines(invoice_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_database_snapshots_taken_at ON database_snapshots(taken_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_discovery_analytics_daily_day ON discovery_analytics_daily(day)`,
 	}
 
 	for _, index := range indexes {
@@ -135,10 +812,14 @@ func (ds *DatabaseService) StoreCDRSummary(cdr *models.FlexibleCDR) error {
 	INSERT OR REPLACE INTO cdr_summaries (
 		cdr_id, domain, call_direction, call_start_time, call_duration_seconds,
 		orig_user, term_user, orig_caller_id, term_caller_id, disconnect_reason,
-		field_count, has_transcription, has_sentiment
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		field_count, has_transcription, has_sentiment, call_class
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
-	_, err := ds.db.Exec(query,
+	stmt, err := ds.prepared(query)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(
 		cdr.GetID(),
 		cdr.GetDomain(),
 		cdr.GetCallDirection(),
@@ -152,6 +833,7 @@ func (ds *DatabaseService) StoreCDRSummary(cdr *models.FlexibleCDR) error {
 		len(cdr.GetFieldNames()),
 		cdr.HasTranscriptionData(),
 		cdr.HasSentimentData(),
+		ClassifyCall(*cdr),
 	)
 
 	return err
@@ -166,7 +848,11 @@ func (ds *DatabaseService) StoreSearchSession(sessionID string, criteria CDRSear
 		session_id, search_criteria, total_cdrs, start_time, end_time
 	) VALUES (?, ?, ?, ?, ?)`
 
-	_, err := ds.db.Exec(query,
+	stmt, err := ds.prepared(query)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(
 		sessionID,
 		string(criteriaJSON),
 		totalCDRs,
@@ -182,7 +868,7 @@ func (ds *DatabaseService) GetCDRSummaries(domain string, limit int) ([]CDRSumma
 	query := `
 	SELECT cdr_id, domain, call_direction, call_start_time, call_duration_seconds,
 		   orig_user, term_user, orig_caller_id, term_caller_id, disconnect_reason,
-		   field_count, has_transcription, has_sentiment, created_at
+		   field_count, has_transcription, has_sentiment, COALESCE(call_class, ''), created_at
 	FROM cdr_summaries`
 
 	args := []interface{}{}
@@ -199,7 +885,11 @@ func (ds *DatabaseService) GetCDRSummaries(domain string, limit int) ([]CDRSumma
 		args = append(args, limit)
 	}
 
-	rows, err := ds.db.Query(query, args...)
+	stmt, err := ds.prepared(query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.Query(args...)
 	if err != nil {
 		return nil, err
 	}
@@ -214,7 +904,7 @@ func (ds *DatabaseService) GetCDRSummaries(domain string, limit int) ([]CDRSumma
 			&summary.OrigUser, &summary.TermUser, &summary.OrigCallerID,
 			&summary.TermCallerID, &summary.DisconnectReason,
 			&summary.FieldCount, &summary.HasTranscription,
-			&summary.HasSentiment, &summary.CreatedAt,
+			&summary.HasSentiment, &summary.CallClass, &summary.CreatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -227,10 +917,20 @@ func (ds *DatabaseService) GetCDRSummaries(domain string, limit int) ([]CDRSumma
 
 // GenerateSimpleReport creates a comprehensive but simple report from stored CDRs
 func (ds *DatabaseService) GenerateSimpleReport(sessionID, reportName string, criteria ReportCriteria) (*SimpleReport, error) {
+	filter, err := ParseCDRFilter(criteria.Filter)
+	if err != nil {
+		return nil, err
+	}
+
 	// Build query based on criteria
+	// Calls transcribed and scored here count alongside NetSapiens call intelligence
 	query := `
 	SELECT cdr_id, domain, call_direction, call_start_time, call_duration_seconds,
-		   orig_user, term_user, disconnect_reason, has_transcription, has_sentiment
+		   orig_user, term_user, disconnect_reason, COALESCE(call_class, ''),
+		   has_transcription OR EXISTS (SELECT 1 FROM recording_transcripts t
+			   WHERE t.cdr_id = cdr_summaries.cdr_id AND t.status = 'completed'),
+		   has_sentiment OR EXISTS (SELECT 1 FROM transcript_sentiments s
+			   WHERE s.cdr_id = cdr_summaries.cdr_id)
 	FROM cdr_summaries WHERE 1=1`
 
 	args := []interface{}{}
@@ -252,12 +952,25 @@ func (ds *DatabaseService) GenerateSimpleReport(sessionID, reportName string, cr
 
 	query += " ORDER BY call_start_time DESC"
 
-	if criteria.Limit > 0 {
+	// A filtered report applies its limit to the records that pass the filter
+	if criteria.Limit > 0 && filter == nil {
 		query += " LIMIT ?"
 		args = append(args, criteria.Limit)
 	}
 
-	rows, err := ds.db.Query(query, args...)
+	// Analysts' notes on the session go with its report
+	notes := []SessionNote{}
+	if sessionID != "" {
+		if notes, err = ds.GetSessionNotes(sessionID, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	stmt, err := ds.prepared(query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.Query(args...)
 	if err != nil {
 		return nil, err
 	}
@@ -270,11 +983,13 @@ func (ds *DatabaseService) GenerateSimpleReport(sessionID, reportName string, cr
 		GeneratedAt: time.Now(),
 		Totals:      ReportTotals{},
 		Records:     []ReportRecord{},
+		Notes:       notes,
 	}
 
 	var totalDuration int
 	var inboundCount, outboundCount int
 	var transcriptionCount, sentimentCount int
+	classes := make(map[string]int)
 
 	for rows.Next() {
 		var record ReportRecord
@@ -283,12 +998,21 @@ func (ds *DatabaseService) GenerateSimpleReport(sessionID, reportName string, cr
 		err := rows.Scan(
 			&record.CdrID, &record.Domain, &record.CallDirection,
 			&record.CallStartTime, &record.CallDurationSeconds,
-			&record.OrigUser, &record.TermUser, &record.DisconnectReason,
+			&record.OrigUser, &record.TermUser, &record.DisconnectReason, &record.CallClass,
 			&hasTranscription, &hasSentiment,
 		)
 		if err != nil {
 			return nil, err
 		}
+		if filter != nil {
+			if criteria.Limit > 0 && len(report.Records) >= criteria.Limit {
+				break
+			}
+			cdr := record.filterCDR(hasTranscription, hasSentiment)
+			if !filter.Match(&cdr) {
+				continue
+			}
+		}
 
 		// Calculate totals
 		totalDuration += record.CallDurationSeconds
@@ -303,6 +1027,7 @@ func (ds *DatabaseService) GenerateSimpleReport(sessionID, reportName string, cr
 		if hasSentiment {
 			sentimentCount++
 		}
+		classes[record.CallClass]++
 
 		report.Records = append(report.Records, record)
 	}
@@ -315,6 +1040,9 @@ func (ds *DatabaseService) GenerateSimpleReport(sessionID, reportName string, cr
 		OutboundCalls:          outboundCount,
 		CallsWithTranscription: transcriptionCount,
 		CallsWithSentiment:     sentimentCount,
+		AnsweredCalls:          classes[CallAnswered],
+		AbandonedCalls:         classes[CallAbandoned],
+		MissedCalls:            classes[CallMissed],
 		AverageDurationSeconds: 0,
 	}
 
@@ -351,7 +1079,11 @@ func (ds *DatabaseService) StoreReport(report *SimpleReport, format string) erro
 	INSERT INTO reports (session_id, report_name, report_type, report_data, record_count, file_size_bytes)
 	VALUES (?, ?, ?, ?, ?, ?)`
 
-	_, err = ds.db.Exec(query,
+	stmt, err := ds.prepared(query)
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(
 		report.SessionID,
 		report.Name,
 		format,
@@ -383,7 +1115,11 @@ func (ds *DatabaseService) GetStoredReports(sessionID string, limit int) ([]Stor
 		args = append(args, limit)
 	}
 
-	rows, err := ds.db.Query(query, args...)
+	stmt, err := ds.prepared(query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.Query(args...)
 	if err != nil {
 		return nil, err
 	}
@@ -441,6 +1177,7 @@ type CDRSummary struct {
 	FieldCount          int       `json:"field_count"`
 	HasTranscription    bool      `json:"has_transcription"`
 	HasSentiment        bool      `json:"has_sentiment"`
+	CallClass           string    `json:"call_class"` // answered, abandoned or missed; empty if stored before classification
 	CreatedAt           time.Time `json:"created_at"`
 }
 
@@ -449,6 +1186,7 @@ type ReportCriteria struct {
 	StartDate time.Time `json:"start_date"`
 	EndDate   time.Time `json:"end_date"`
 	Limit     int       `json:"limit"`
+	Filter    string    `json:"filter,omitempty"` // CDR filter expression over the stored summary fields
 }
 
 type SimpleReport struct {
@@ -457,6 +1195,7 @@ type SimpleReport struct {
 	GeneratedAt time.Time      `json:"generated_at"`
 	Totals      ReportTotals   `json:"totals"`
 	Records     []ReportRecord `json:"records"`
+	Notes       []SessionNote  `json:"notes"`
 }
 
 type ReportTotals struct {
@@ -466,6 +1205,9 @@ type ReportTotals struct {
 	OutboundCalls          int `json:"outbound_calls"`
 	CallsWithTranscription int `json:"calls_with_transcription"`
 	CallsWithSentiment     int `json:"calls_with_sentiment"`
+	AnsweredCalls          int `json:"answered_calls"`
+	AbandonedCalls         int `json:"abandoned_calls"`
+	MissedCalls            int `json:"missed_calls"`
 	AverageDurationSeconds int `json:"average_duration_seconds"`
 }
 
@@ -478,6 +1220,24 @@ type ReportRecord struct {
 	OrigUser            string    `json:"orig_user"`
 	TermUser            string    `json:"term_user"`
 	DisconnectReason    string    `json:"disconnect_reason"`
+	CallClass           string    `json:"call_class"`
+}
+
+// filterCDR presents a stored record to CDR filters with the fields of the CDR it came from
+func (r *ReportRecord) filterCDR(hasTranscription, hasSentiment bool) models.FlexibleCDR {
+	return models.FlexibleCDR{RawData: map[string]interface{}{
+		"id":                          r.CdrID,
+		"domain":                      r.Domain,
+		"call-direction":              float64(r.CallDirection),
+		"call-start-datetime":         r.CallStartTime.UTC().Format(time.RFC3339),
+		"call-total-duration-seconds": float64(r.CallDurationSeconds),
+		"call-orig-user":              r.OrigUser,
+		"call-term-user":              r.TermUser,
+		"call-disconnect-reason-text": r.DisconnectReason,
+		"call_class":                  r.CallClass,
+		"has_transcription":           hasTranscription,
+		"has_sentiment":               hasSentiment,
+	}}
 }
 
 type StoredReport struct {
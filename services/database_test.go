@@ -0,0 +1,68 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDatabaseSettings(t *testing.T) {
+	db, err := NewDatabaseServiceWithSettings(filepath.Join(t.TempDir(), "tuned.db"), DatabaseSettings{
+		JournalMode:  "wal",
+		BusyTimeout:  2 * time.Second,
+		Synchronous:  "normal",
+		MaxOpenConns: 4,
+	})
+	if err != nil {
+		t.Fatalf("NewDatabaseServiceWithSettings: %v", err)
+	}
+	defer db.Close()
+
+	var journalMode string
+	var busyTimeout, synchronous int
+	if err := db.db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil || journalMode != "wal" {
+		t.Errorf("journal_mode = %q, %v; want wal", journalMode, err)
+	}
+	if err := db.db.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout); err != nil || busyTimeout != 2000 {
+		t.Errorf("busy_timeout = %d, %v; want 2000", busyTimeout, err)
+	}
+	if err := db.db.QueryRow("PRAGMA synchronous").Scan(&synchronous); err != nil || synchronous != 1 {
+		t.Errorf("synchronous = %d, %v; want 1 (NORMAL)", synchronous, err)
+	}
+	if stats := db.db.Stats(); stats.MaxOpenConnections != 4 {
+		t.Errorf("MaxOpenConnections = %d, want 4", stats.MaxOpenConnections)
+	}
+
+	if _, err := NewDatabaseServiceWithSettings(filepath.Join(t.TempDir(), "bad.db"), DatabaseSettings{JournalMode: "fast"}); err == nil {
+		t.Error("accepted an unknown journal mode")
+	}
+	if _, err := NewDatabaseServiceWithSettings(filepath.Join(t.TempDir(), "bad.db"), DatabaseSettings{Synchronous: "sometimes"}); err == nil {
+		t.Error("accepted an unknown synchronous level")
+	}
+}
+
+func TestPreparedStatementCache(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "stmts.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := db.GetCDRSummaries("acme", 10); err != nil {
+			t.Fatalf("GetCDRSummaries: %v", err)
+		}
+	}
+	if _, err := db.GetCDRSummaries("", 0); err != nil {
+		t.Fatalf("GetCDRSummaries: %v", err)
+	}
+	if len(db.stmts) != 2 {
+		t.Errorf("cached %d statements, want one per query variant (2)", len(db.stmts))
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if len(db.stmts) != 0 {
+		t.Errorf("%d statements left open after Close", len(db.stmts))
+	}
+}
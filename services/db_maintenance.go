@@ -0,0 +1,313 @@
+// services/db_maintenance.go
+// Database maintenance: a background task prunes old Web Responder events, refreshes the
+// query planner's statistics (ANALYZE), optionally reclaims free pages (VACUUM), and
+// snapshots the database size and table row counts so admins can see how fast it grows
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maintenanceHistoryLimit bounds how many snapshots a growth report reads
+const maintenanceHistoryLimit = 1000
+
+// MaintenanceRun describes one maintenance pass
+type MaintenanceRun struct {
+	StartedAt       time.Time `json:"started_at"`
+	DurationMS      int64     `json:"duration_ms"`
+	Vacuumed        bool      `json:"vacuumed"`
+	EventsPruned    int64     `json:"events_pruned"`
+	SizeBeforeBytes int64     `json:"size_before_bytes"`
+	SizeAfterBytes  int64     `json:"size_after_bytes"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// DatabaseSnapshot is the database size and row counts at one point in time
+type DatabaseSnapshot struct {
+	TakenAt   time.Time        `json:"taken_at"`
+	SizeBytes int64            `json:"size_bytes"`
+	FreeBytes int64            `json:"free_bytes"` // unused pages VACUUM would reclaim
+	TableRows map[string]int64 `json:"table_rows"`
+}
+
+// TableGrowth is how a table's row count changed over a report's period
+type TableGrowth struct {
+	Table      string  `json:"table"`
+	Rows       int64   `json:"rows"`
+	Added      int64   `json:"added"`
+	RowsPerDay float64 `json:"rows_per_day"`
+}
+
+// DatabaseReport is the current database size with its growth over recent snapshots
+type DatabaseReport struct {
+	Current         DatabaseSnapshot   `json:"current"`
+	Since           *time.Time         `json:"since"` // oldest snapshot compared against
+	BytesPerDay     float64            `json:"bytes_per_day"`
+	Tables          []TableGrowth      `json:"tables"` // largest first
+	History         []DatabaseSnapshot `json:"history"`
+	LastMaintenance *MaintenanceRun    `json:"last_maintenance"`
+	NextMaintenance *time.Time         `json:"next_maintenance"`
+}
+
+// DatabaseMaintainer runs maintenance on a schedule and reports database growth
+type DatabaseMaintainer struct {
+	db             *DatabaseService
+	interval       time.Duration
+	vacuum         bool
+	eventRetention time.Duration
+
+	mu      sync.Mutex
+	running bool
+	last    *MaintenanceRun
+	next    *time.Time
+}
+
+// NewDatabaseMaintainer creates a maintainer; with no interval it only runs when asked.
+// Web Responder events older than eventRetention are pruned on each run; 0 keeps them.
+func NewDatabaseMaintainer(db *DatabaseService, interval time.Duration, vacuum bool, eventRetention time.Duration) *DatabaseMaintainer {
+	return &DatabaseMaintainer{
+		db:             db,
+		interval:       interval,
+		vacuum:         vacuum,
+		eventRetention: eventRetention,
+	}
+}
+
+// Start runs maintenance every interval in the background
+func (dm *DatabaseMaintainer) Start() {
+	if dm.interval <= 0 {
+		return
+	}
+	log.Printf("[Database] Running maintenance every %s", dm.interval)
+
+	go func() {
+		for {
+			next := time.Now().Add(dm.interval)
+			dm.mu.Lock()
+			dm.next = &next
+			dm.mu.Unlock()
+
+			time.Sleep(dm.interval)
+			if run, err := dm.Run(); err != nil {
+				log.Printf("[Database] Maintenance failed: %v", err)
+			} else {
+				log.Printf("[Database] Maintenance took %dms, size %d -> %d bytes", run.DurationMS, run.SizeBeforeBytes, run.SizeAfterBytes)
+			}
+		}
+	}()
+}
+
+// Run prunes old events, analyzes, and vacuums when configured, then records a snapshot. Only one run
+// happens at a time.
+func (dm *DatabaseMaintainer) Run() (*MaintenanceRun, error) {
+	dm.mu.Lock()
+	if dm.running {
+		dm.mu.Unlock()
+		return nil, fmt.Errorf("maintenance is already running")
+	}
+	dm.running = true
+	dm.mu.Unlock()
+
+	run := &MaintenanceRun{StartedAt: time.Now().UTC()}
+	err := dm.maintain(run)
+	if err != nil {
+		run.Error = err.Error()
+	}
+	run.DurationMS = time.Since(run.StartedAt).Milliseconds()
+
+	dm.mu.Lock()
+	dm.running = false
+	dm.last = run
+	dm.mu.Unlock()
+	return run, err
+}
+
+// maintain does the work of a run
+func (dm *DatabaseMaintainer) maintain(run *MaintenanceRun) error {
+	size, _, err := dm.db.DatabaseSize()
+	if err != nil {
+		return err
+	}
+	run.SizeBeforeBytes = size
+
+	if dm.eventRetention > 0 {
+		pruned, err := dm.db.PruneWREvents(time.Now().Add(-dm.eventRetention))
+		if err != nil {
+			return err
+		}
+		run.EventsPruned = pruned
+	}
+	if _, err := dm.db.db.Exec("ANALYZE"); err != nil {
+		return fmt.Errorf("failed to analyze database: %w", err)
+	}
+	if dm.vacuum {
+		if _, err := dm.db.db.Exec("VACUUM"); err != nil {
+			return fmt.Errorf("failed to vacuum database: %w", err)
+		}
+		run.Vacuumed = true
+	}
+
+	snapshot, err := dm.db.TakeDatabaseSnapshot()
+	if err != nil {
+		return err
+	}
+	run.SizeAfterBytes = snapshot.SizeBytes
+	return dm.db.SaveDatabaseSnapshot(snapshot)
+}
+
+// Report compares the database now with the snapshots taken over the last days
+func (dm *DatabaseMaintainer) Report(days int) (*DatabaseReport, error) {
+	current, err := dm.db.TakeDatabaseSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	history, err := dm.db.GetDatabaseSnapshots(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DatabaseReport{
+		Current: *current,
+		Tables:  []TableGrowth{},
+		History: history,
+	}
+	dm.mu.Lock()
+	report.LastMaintenance, report.NextMaintenance = dm.last, dm.next
+	dm.mu.Unlock()
+
+	var oldest *DatabaseSnapshot
+	elapsedDays := 0.0
+	if len(history) > 0 {
+		oldest = &history[0]
+		report.Since = &oldest.TakenAt
+		elapsedDays = current.TakenAt.Sub(oldest.TakenAt).Hours() / 24
+	}
+	if elapsedDays > 0 {
+		report.BytesPerDay = roundTo(float64(current.SizeBytes-oldest.SizeBytes)/elapsedDays, 1)
+	}
+	for table, rows := range current.TableRows {
+		growth := TableGrowth{Table: table, Rows: rows}
+		if oldest != nil {
+			growth.Added = rows - oldest.TableRows[table]
+		}
+		if elapsedDays > 0 {
+			growth.RowsPerDay = roundTo(float64(growth.Added)/elapsedDays, 1)
+		}
+		report.Tables = append(report.Tables, growth)
+	}
+	sort.Slice(report.Tables, func(i, j int) bool {
+		if report.Tables[i].Rows != report.Tables[j].Rows {
+			return report.Tables[i].Rows > report.Tables[j].Rows
+		}
+		return report.Tables[i].Table < report.Tables[j].Table
+	})
+	return report, nil
+}
+
+// DatabaseSize returns the size of the database and of its free pages, in bytes
+func (ds *DatabaseService) DatabaseSize() (int64, int64, error) {
+	var pageSize, pageCount, freePages int64
+	if err := ds.db.QueryRow("SELECT page_size, page_count, freelist_count FROM pragma_page_size, pragma_page_count, pragma_freelist_count").
+		Scan(&pageSize, &pageCount, &freePages); err != nil {
+		return 0, 0, fmt.Errorf("failed to read database size: %w", err)
+	}
+	return pageSize * pageCount, pageSize * freePages, nil
+}
+
+// TakeDatabaseSnapshot measures the database size and counts the rows of every table
+func (ds *DatabaseService) TakeDatabaseSnapshot() (*DatabaseSnapshot, error) {
+	size, free, err := ds.DatabaseSize()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &DatabaseSnapshot{
+		TakenAt:   time.Now().UTC(),
+		SizeBytes: size,
+		FreeBytes: free,
+		TableRows: map[string]int64{},
+	}
+
+	rows, err := ds.db.Query(`
+	SELECT name, sql LIKE 'CREATE VIRTUAL TABLE%' FROM sqlite_master
+	WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	tables, virtual := []string{}, []string{}
+	for rows.Next() {
+		var table string
+		var isVirtual bool
+		if err := rows.Scan(&table, &isVirtual); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		tables = append(tables, table)
+		if isVirtual {
+			virtual = append(virtual, table+"_")
+		}
+	}
+	rows.Close()
+
+	for _, table := range tables {
+		// A full-text index's internal tables are counted by the index itself
+		internal := false
+		for _, prefix := range virtual {
+			internal = internal || strings.HasPrefix(table, prefix)
+		}
+		if internal {
+			continue
+		}
+		var count int64
+		if err := ds.db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM "%s"`, table)).Scan(&count); err != nil {
+			return nil, fmt.Errorf("failed to count rows of %s: %w", table, err)
+		}
+		snapshot.TableRows[table] = count
+	}
+	return snapshot, nil
+}
+
+// SaveDatabaseSnapshot stores a snapshot for growth reports
+func (ds *DatabaseService) SaveDatabaseSnapshot(snapshot *DatabaseSnapshot) error {
+	tableRows, err := json.Marshal(snapshot.TableRows)
+	if err != nil {
+		return fmt.Errorf("failed to encode row counts: %w", err)
+	}
+	if _, err := ds.db.Exec(`
+	INSERT INTO database_snapshots (taken_at, size_bytes, free_bytes, table_rows) VALUES (?, ?, ?, ?)`,
+		snapshot.TakenAt, snapshot.SizeBytes, snapshot.FreeBytes, string(tableRows)); err != nil {
+		return fmt.Errorf("failed to save database snapshot: %w", err)
+	}
+	return nil
+}
+
+// GetDatabaseSnapshots lists the snapshots taken since a time, oldest first
+func (ds *DatabaseService) GetDatabaseSnapshots(since time.Time) ([]DatabaseSnapshot, error) {
+	rows, err := ds.db.Query(`
+	SELECT taken_at, size_bytes, free_bytes, table_rows FROM database_snapshots
+	WHERE taken_at >= ? ORDER BY taken_at LIMIT ?`, since.UTC(), maintenanceHistoryLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query database snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []DatabaseSnapshot{}
+	for rows.Next() {
+		var snapshot DatabaseSnapshot
+		var tableRows string
+		if err := rows.Scan(&snapshot.TakenAt, &snapshot.SizeBytes, &snapshot.FreeBytes, &tableRows); err != nil {
+			return nil, fmt.Errorf("failed to scan database snapshot: %w", err)
+		}
+		if err := json.Unmarshal([]byte(tableRows), &snapshot.TableRows); err != nil {
+			return nil, fmt.Errorf("failed to decode row counts: %w", err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"o-dan-go/events"
+)
+
+func TestDatabaseMaintainer(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "maintenance.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	// An older snapshot with fewer rows to measure growth against
+	if err := db.SaveDatabaseSnapshot(&DatabaseSnapshot{
+		TakenAt:   time.Now().UTC().AddDate(0, 0, -2),
+		SizeBytes: 4096,
+		TableRows: map[string]int64{"search_sessions": 0},
+	}); err != nil {
+		t.Fatalf("SaveDatabaseSnapshot: %v", err)
+	}
+	for _, sessionID := range []string{"session-1", "session-2"} {
+		if err := db.StoreSearchSession(sessionID, CDRSearchCriteria{}, 0); err != nil {
+			t.Fatalf("StoreSearchSession: %v", err)
+		}
+	}
+
+	// One event past the retention and one within it
+	analytics := NewWRAnalyticsService(db)
+	for _, age := range []time.Duration{48 * time.Hour, time.Hour} {
+		if err := analytics.SaveEvent(events.CallEvent{EventType: "call_started", Timestamp: time.Now().Add(-age)}); err != nil {
+			t.Fatalf("SaveEvent: %v", err)
+		}
+	}
+
+	maintainer := NewDatabaseMaintainer(db, 0, true, 24*time.Hour)
+	run, err := maintainer.Run()
+	if err != nil || !run.Vacuumed || run.SizeAfterBytes == 0 || run.EventsPruned != 1 {
+		t.Fatalf("Run = %+v, %v", run, err)
+	}
+	if _, total, err := analytics.Events(EventQuery{Limit: 10}); err != nil || total != 1 {
+		t.Errorf("events kept = %d, %v, want 1", total, err)
+	}
+
+	report, err := maintainer.Report(30)
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	if len(report.History) != 2 || report.LastMaintenance == nil || report.NextMaintenance != nil {
+		t.Errorf("report = %+v", report)
+	}
+	for _, table := range report.Tables {
+		if table.Table == "transcripts_fts_data" {
+			t.Error("full-text index internals were counted")
+		}
+		if table.Table == "search_sessions" && (table.Rows != 2 || table.Added != 2 || table.RowsPerDay != 1) {
+			t.Errorf("search_sessions growth = %+v", table)
+		}
+	}
+}
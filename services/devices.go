@@ -0,0 +1,250 @@
+// services/devices.go
+// Device registration status per domain/user, correlated with recent failed calls
+// to troubleshoot "calls not ringing" tickets
+
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"o-dan-go/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Device diagnoses, from most to least urgent
+const (
+	DeviceNotRegistered = "not_registered" // has devices, none registered
+	DeviceNoDevices     = "no_devices"     // no devices provisioned
+	DeviceFailing       = "failing"        // registered, but recent calls failed
+	DeviceOK            = "ok"
+)
+
+// DeviceEndpointConfig describes a NetSapiens device endpoint, like CDREndpointConfig
+type DeviceEndpointConfig struct {
+	Name           string   `json:"name"`
+	URLTemplate    string   `json:"url_template"`
+	RequiredParams []string `json:"required_params"`
+	Description    string   `json:"description"`
+}
+
+// DeviceStatus is one device and its SIP registration
+type DeviceStatus struct {
+	Device       string     `json:"device"`
+	User         string     `json:"user"`
+	Model        string     `json:"model,omitempty"`
+	Registered   bool       `json:"registered"`
+	UserAgent    string     `json:"user_agent,omitempty"`
+	Contact      string     `json:"contact,omitempty"` // registered address
+	RegisteredAt *time.Time `json:"registered_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+// UserDeviceReport is a user's devices and recent failed calls to them
+type UserDeviceReport struct {
+	User              string         `json:"user"`
+	Devices           []DeviceStatus `json:"devices"`
+	Registered        int            `json:"registered"`
+	RecentFailures    int            `json:"recent_failures"`
+	LastFailureAt     *time.Time     `json:"last_failure_at,omitempty"`
+	LastFailureReason string         `json:"last_failure_reason,omitempty"`
+	Diagnosis         string         `json:"diagnosis"`
+}
+
+// DeviceReport is the registration report for a domain (or one user in it)
+type DeviceReport struct {
+	Domain      string             `json:"domain"`
+	Since       time.Time          `json:"since"` // start of the failed call window
+	Users       []UserDeviceReport `json:"users"`
+	CDRErrors   []string           `json:"cdr_errors,omitempty"` // failures were not checked where these occurred
+	GeneratedAt time.Time          `json:"generated_at"`
+}
+
+// DeviceService reads device registrations from the NetSapiens v2 API
+type DeviceService struct {
+	client      *http.Client
+	baseURL     string
+	accessToken string
+	cdrService  *CDRDiscoveryService
+}
+
+// NewDeviceService creates a device client; failed calls are looked up through cdrService
+func NewDeviceService(baseURL, accessToken string, cdrService *CDRDiscoveryService) *DeviceService {
+	return &DeviceService{
+		client:      &http.Client{Timeout: 15 * time.Second},
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		accessToken: accessToken,
+		cdrService:  cdrService,
+	}
+}
+
+// GetSupportedEndpoints returns the device endpoints the report can query
+func (ds *DeviceService) GetSupportedEndpoints() []DeviceEndpointConfig {
+	return []DeviceEndpointConfig{
+		{
+			Name:           "domain_devices",
+			URLTemplate:    "/ns-api/v2/domains/{domain}/devices",
+			RequiredParams: []string{"domain"},
+			Description:    "Every device in a domain with its registration",
+		},
+		{
+			Name:           "user_devices",
+			URLTemplate:    "/ns-api/v2/domains/{domain}/users/{user}/devices",
+			RequiredParams: []string{"domain", "user"},
+			Description:    "A user's devices with their registrations",
+		},
+	}
+}
+
+// GetDeviceReport lists devices for a domain, or one user when user is set, with the
+// failed calls each user received in the lookback window
+func (ds *DeviceService) GetDeviceReport(domain, user string, lookback time.Duration) (*DeviceReport, error) {
+	if ds.accessToken == "" {
+		return nil, fmt.Errorf("no NetSapiens access token configured")
+	}
+
+	endpoint := ds.GetSupportedEndpoints()[0]
+	if user != "" {
+		endpoint = ds.GetSupportedEndpoints()[1]
+	}
+	path := strings.ReplaceAll(endpoint.URLTemplate, "{domain}", url.PathEscape(domain))
+	path = strings.ReplaceAll(path, "{user}", url.PathEscape(user))
+
+	var records []map[string]interface{}
+	if err := netsapiensGet(ds.client, ds.baseURL, ds.accessToken, path, &records); err != nil {
+		return nil, fmt.Errorf("%s: %w", endpoint.Name, err)
+	}
+
+	now := time.Now()
+	report := &DeviceReport{
+		Domain:      domain,
+		Since:       now.Add(-lookback),
+		GeneratedAt: now,
+	}
+
+	users := make(map[string]*UserDeviceReport)
+	if user != "" {
+		users[user] = &UserDeviceReport{User: user, Devices: []DeviceStatus{}}
+	}
+	for _, record := range records {
+		device := parseDevice(record, now)
+		if device.User == "" {
+			device.User = user
+		}
+		entry, exists := users[device.User]
+		if !exists {
+			entry = &UserDeviceReport{User: device.User, Devices: []DeviceStatus{}}
+			users[device.User] = entry
+		}
+		entry.Devices = append(entry.Devices, device)
+		if device.Registered {
+			entry.Registered++
+		}
+	}
+
+	if ds.cdrService != nil {
+		since := report.Since
+		result, err := ds.cdrService.GetComprehensiveCDRs(CDRSearchCriteria{Domain: domain, User: user, StartDate: &since, Limit: 500})
+		if err != nil {
+			report.CDRErrors = append(report.CDRErrors, err.Error())
+		} else {
+			report.CDRErrors = result.Errors
+			for _, cdr := range result.AllCDRs {
+				if entry, exists := users[cdr.GetTermUser()]; exists && IsFailedCall(cdr) {
+					entry.addFailure(cdr, since)
+				}
+			}
+		}
+	}
+
+	for _, entry := range users {
+		entry.Diagnosis = diagnoseDevices(entry)
+		report.Users = append(report.Users, *entry)
+	}
+	sort.Slice(report.Users, func(i, j int) bool {
+		return report.Users[i].User < report.Users[j].User
+	})
+
+	return report, nil
+}
+
+// addFailure counts a failed call in the window and tracks the most recent one
+func (ur *UserDeviceReport) addFailure(cdr models.FlexibleCDR, since time.Time) {
+	started, err := cdr.GetCallStartTime()
+	if err == nil && started.Before(since) {
+		return
+	}
+
+	ur.RecentFailures++
+	if err == nil && (ur.LastFailureAt == nil || started.After(*ur.LastFailureAt)) {
+		ur.LastFailureAt = &started
+		ur.LastFailureReason = cdr.GetDisconnectReason()
+	}
+	if ur.LastFailureReason == "" {
+		ur.LastFailureReason = cdr.GetDisconnectReason()
+	}
+}
+
+// IsFailedCall reports whether a CDR is an unanswered call that ended for a reason other
+// than the caller hanging up or nobody picking up, e.g. a timeout or unreachable device
+func IsFailedCall(cdr models.FlexibleCDR) bool {
+	if cdr.GetCallDuration() > 0 {
+		return false
+	}
+	reason := strings.ToLower(cdr.GetDisconnectReason())
+	if reason == "" {
+		return false
+	}
+	for _, normal := range []string{"normal", "no answer", "cancel", "busy", "originator"} {
+		if strings.Contains(reason, normal) {
+			return false
+		}
+	}
+	return true
+}
+
+// diagnoseDevices sums up what is most likely keeping a user's phones from ringing
+func diagnoseDevices(report *UserDeviceReport) string {
+	switch {
+	case len(report.Devices) == 0:
+		return DeviceNoDevices
+	case report.Registered == 0:
+		return DeviceNotRegistered
+	case report.RecentFailures > 0:
+		return DeviceFailing
+	default:
+		return DeviceOK
+	}
+}
+
+// parseDevice reads one device record, whichever field names the API version uses
+func parseDevice(record map[string]interface{}, now time.Time) DeviceStatus {
+	device := DeviceStatus{
+		Device:    recordString(record, "device", "aor", "device-sip-registration-username"),
+		User:      recordString(record, "user", "subscriber_login"),
+		Model:     recordString(record, "device-model", "model", "mac-model"),
+		UserAgent: recordString(record, "registration-user-agent", "device-registration-user-agent", "user_agent"),
+		Contact:   recordString(record, "registration-contact", "device-registration-contact", "contact", "received_from"),
+	}
+	if user, _, found := strings.Cut(device.Device, "@"); found {
+		device.Device = strings.TrimPrefix(user, "sip:")
+	}
+
+	if registeredAt, ok := recordTime(record, "registration-time", "device-registration-datetime", "registration_time"); ok {
+		device.RegisteredAt = &registeredAt
+	}
+	if expiresAt, ok := recordTime(record, "registration-expires-time", "device-registration-expires-datetime", "registration_expires_time"); ok {
+		device.ExpiresAt = &expiresAt
+	}
+
+	switch strings.ToLower(recordString(record, "registered", "device-registered", "registration-status")) {
+	case "yes", "true", "registered", "1":
+		device.Registered = true
+	case "":
+		// No explicit flag: registered while an unexpired registration exists
+		device.Registered = device.RegisteredAt != nil && (device.ExpiresAt == nil || device.ExpiresAt.After(now))
+	}
+	return device
+}
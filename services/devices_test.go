@@ -0,0 +1,54 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetDeviceReport(t *testing.T) {
+	recent := time.Now().Add(-time.Hour).UTC().Format("2006-01-02 15:04:05")
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/ns-api/v2/domains/acme/devices":
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"device": "sip:101@acme", "user": "101", "registration-time": recent, "registration-expires-time": expires},
+				{"device": "101wp", "user": "101", "registered": "no"},
+				{"device": "102", "user": "102"},
+			})
+		case strings.HasSuffix(r.URL.Path, "/cdrs"):
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"id": "1", "call-term-user": "102", "call-start-datetime": recent, "call-disconnect-reason-text": "Request Timeout"},
+				{"id": "2", "call-term-user": "101", "call-start-datetime": recent, "call-disconnect-reason-text": "No Answer"},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ds := NewDeviceService(server.URL, "token", NewCDRDiscoveryService(server.URL, "token"))
+	report, err := ds.GetDeviceReport("acme", "", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("GetDeviceReport: %v", err)
+	}
+	if len(report.Users) != 2 {
+		t.Fatalf("expected 2 users, got %+v", report.Users)
+	}
+
+	first, second := report.Users[0], report.Users[1]
+	if first.User != "101" || first.Registered != 1 || first.RecentFailures != 0 || first.Diagnosis != DeviceOK {
+		t.Errorf("unexpected report for 101: %+v", first)
+	}
+	if first.Devices[0].Device != "101" {
+		t.Errorf("expected SIP URI trimmed to 101, got %q", first.Devices[0].Device)
+	}
+	if second.User != "102" || second.RecentFailures != 1 || second.LastFailureReason != "Request Timeout" || second.Diagnosis != DeviceNotRegistered {
+		t.Errorf("unexpected report for 102: %+v", second)
+	}
+}
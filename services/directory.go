@@ -0,0 +1,115 @@
+// services/directory.go
+// Domains, users and sites on the PBX, listed with the server's NetSapiens credentials
+// so forms can offer them instead of asking for exact names
+
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// directoryCacheTTL keeps the search form's dropdowns from hitting the API on every page load;
+// domains and users change rarely
+const directoryCacheTTL = 5 * time.Minute
+
+// DirectoryEntry is a domain, user or site: its name as used in searches and a description
+type DirectoryEntry struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// directoryList is a cached listing
+type directoryList struct {
+	entries   []DirectoryEntry
+	fetchedAt time.Time
+}
+
+// DirectoryService lists domains, users and sites from the NetSapiens v2 API
+type DirectoryService struct {
+	client      *http.Client
+	baseURL     string
+	accessToken string
+
+	mu    sync.Mutex
+	cache map[string]directoryList
+}
+
+// NewDirectoryService creates a directory client
+func NewDirectoryService(baseURL, accessToken string) *DirectoryService {
+	return &DirectoryService{
+		client:      &http.Client{Timeout: 10 * time.Second},
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		accessToken: accessToken,
+		cache:       make(map[string]directoryList),
+	}
+}
+
+// GetDomains lists the domains the token can see
+func (ds *DirectoryService) GetDomains() ([]DirectoryEntry, error) {
+	return ds.list("/ns-api/v2/domains",
+		[]string{"domain", "name"},
+		[]string{"description", "domain-description"})
+}
+
+// GetUsers lists a domain's users, described by their full name
+func (ds *DirectoryService) GetUsers(domain string) ([]DirectoryEntry, error) {
+	return ds.list(fmt.Sprintf("/ns-api/v2/domains/%s/users", url.PathEscape(domain)),
+		[]string{"user", "login-username", "subscriber_login"},
+		[]string{"name-full-name", "name", "description"})
+}
+
+// GetSites lists a domain's sites
+func (ds *DirectoryService) GetSites(domain string) ([]DirectoryEntry, error) {
+	return ds.list(fmt.Sprintf("/ns-api/v2/domains/%s/sites", url.PathEscape(domain)),
+		[]string{"site", "name"},
+		[]string{"description", "site-description"})
+}
+
+// list fetches path (or returns the cached listing) and reads each record's name and
+// description from the first matching keys, sorted by name
+func (ds *DirectoryService) list(path string, nameKeys, descriptionKeys []string) ([]DirectoryEntry, error) {
+	ds.mu.Lock()
+	cached, exists := ds.cache[path]
+	ds.mu.Unlock()
+	if exists && time.Since(cached.fetchedAt) < directoryCacheTTL {
+		return cached.entries, nil
+	}
+
+	if ds.accessToken == "" {
+		return nil, fmt.Errorf("no NetSapiens access token configured")
+	}
+
+	var records []map[string]interface{}
+	if err := netsapiensGet(ds.client, ds.baseURL, ds.accessToken, path, &records); err != nil {
+		return nil, err
+	}
+
+	entries := make([]DirectoryEntry, 0, len(records))
+	seen := make(map[string]bool)
+	for _, record := range records {
+		name := recordString(record, nameKeys...)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		entry := DirectoryEntry{Name: name}
+		if description := recordString(record, descriptionKeys...); description != name {
+			entry.Description = description
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	ds.mu.Lock()
+	ds.cache[path] = directoryList{entries: entries, fetchedAt: time.Now()}
+	ds.mu.Unlock()
+	return entries, nil
+}
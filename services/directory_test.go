@@ -0,0 +1,56 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDirectoryListings(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/ns-api/v2/domains":
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"domain": "zeta", "description": "Zeta Corp"},
+				{"domain": "acme", "description": "acme"},
+				{"description": "no name"},
+			})
+		case "/ns-api/v2/domains/acme/users":
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"user": "101", "name-full-name": "Ada Lovelace"},
+				{"user": "101", "name-full-name": "Duplicate"},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ds := NewDirectoryService(server.URL, "token")
+	domains, err := ds.GetDomains()
+	if err != nil {
+		t.Fatalf("GetDomains: %v", err)
+	}
+	if len(domains) != 2 || domains[0] != (DirectoryEntry{Name: "acme"}) || domains[1].Description != "Zeta Corp" {
+		t.Fatalf("unexpected domains: %+v", domains)
+	}
+
+	users, err := ds.GetUsers("acme")
+	if err != nil {
+		t.Fatalf("GetUsers: %v", err)
+	}
+	if len(users) != 1 || users[0].Description != "Ada Lovelace" {
+		t.Fatalf("unexpected users: %+v", users)
+	}
+
+	if _, err := ds.GetSites("acme"); err == nil {
+		t.Fatal("expected an error for a failed listing")
+	}
+
+	if _, err := ds.GetDomains(); err != nil || requests != 3 {
+		t.Fatalf("expected cached domains, got %d requests (%v)", requests, err)
+	}
+}
@@ -0,0 +1,507 @@
+// services/discovery_analytics.go
+// Discovery analytics: records how every endpoint answered every search (whether it
+// succeeded, how many CDRs it returned and how long it took) keyed by the combination of
+// search parameters used, so the combinations that return data quickly can be found and
+// each endpoint's success rate followed day by day
+
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DiscoveryParamsNone is the combination of a search that named no parameters
+const DiscoveryParamsNone = "none"
+
+// GlobalDiscoveryAnalytics records every discovery session's endpoint results; nil until
+// the database is open, which records nothing
+var GlobalDiscoveryAnalytics *DiscoveryAnalytics
+
+// DiscoveryAnalytics keeps per-endpoint, per-parameter-combination search statistics
+type DiscoveryAnalytics struct {
+	db *DatabaseService
+}
+
+// DiscoveryCombination is how one endpoint has answered one combination of parameters
+type DiscoveryCombination struct {
+	BaseURL          string     `json:"base_url"`
+	EndpointName     string     `json:"endpoint_name"`
+	ParamCombination string     `json:"param_combination"`
+	Queries          int        `json:"queries"`
+	Successes        int        `json:"successes"`
+	WithData         int        `json:"with_data"` // successful queries that returned CDRs
+	Records          int        `json:"records"`
+	SuccessRate      float64    `json:"success_rate"` // percent
+	DataRate         float64    `json:"data_rate"`    // percent of queries that returned CDRs
+	AvgRecords       float64    `json:"avg_records"`
+	AvgQueryMS       float64    `json:"avg_query_ms"`
+	LastStatus       int        `json:"last_status,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+	LastQueriedAt    time.Time  `json:"last_queried_at"`
+	LastSuccessAt    *time.Time `json:"last_success_at,omitempty"`
+}
+
+// SearchSuggestion is a combination of criteria fields that has returned CDRs for a
+// domain, summed over the endpoints each search queried
+type SearchSuggestion struct {
+	Domain           string   `json:"domain"`
+	ParamCombination string   `json:"param_combination"`
+	Params           []string `json:"params"`    // criteria fields to fill in
+	Searches         int      `json:"searches"`  // searches made with these fields
+	DataRate         float64  `json:"data_rate"` // percent of searches whose best endpoint returned CDRs
+	AvgRecords       float64  `json:"avg_records"`
+	AvgSearchMS      float64  `json:"avg_search_ms"` // endpoints are queried one after another, so their times add up
+	Endpoints        []string `json:"endpoints"`     // endpoints that have returned CDRs
+}
+
+// EndpointHistory is how an endpoint has answered every search on a base URL
+type EndpointHistory struct {
+	EndpointName  string
+	Queries       int
+	Successes     int
+	Denied        int // queries answered 403 or 404
+	Records       int
+	LastQueriedAt time.Time
+}
+
+// Yield is the CDRs the endpoint has returned per query
+func (h EndpointHistory) Yield() float64 {
+	if h.Queries == 0 {
+		return 0
+	}
+	return float64(h.Records) / float64(h.Queries)
+}
+
+// DiscoveryAnalyticsFilter narrows analytics to a base URL, domain or endpoint; empty
+// fields match everything
+type DiscoveryAnalyticsFilter struct {
+	BaseURL  string
+	Domain   string
+	Endpoint string
+}
+
+// EndpointDay is one endpoint's queries on one day
+type EndpointDay struct {
+	Day         string  `json:"day"`
+	Queries     int     `json:"queries"`
+	Successes   int     `json:"successes"`
+	SuccessRate float64 `json:"success_rate"`
+	Records     int     `json:"records"`
+	AvgQueryMS  float64 `json:"avg_query_ms"`
+}
+
+// EndpointTrend is an endpoint's daily success over a period
+type EndpointTrend struct {
+	BaseURL      string        `json:"base_url"`
+	EndpointName string        `json:"endpoint_name"`
+	Queries      int           `json:"queries"`
+	SuccessRate  float64       `json:"success_rate"`
+	Days         []EndpointDay `json:"days"`
+}
+
+// NewDiscoveryAnalytics creates analytics kept in db
+func NewDiscoveryAnalytics(db *DatabaseService) *DiscoveryAnalytics {
+	return &DiscoveryAnalytics{
+		db: db,
+	}
+}
+
+// DiscoveryParams names the criteria fields a search used, sorted and comma-separated,
+// or DiscoveryParamsNone
+func DiscoveryParams(criteria CDRSearchCriteria) string {
+	used := []string{}
+	for _, param := range []struct {
+		name string
+		set  bool
+	}{
+		{"any_phone_number", criteria.AnyPhoneNumber != ""},
+		{"call_id", criteria.CallID != ""},
+		{"domain", criteria.Domain != ""},
+		{"end_date", criteria.EndDate != nil},
+		{"originating_number", criteria.OriginatingNumber != ""},
+		{"site", criteria.Site != ""},
+		{"start_date", criteria.StartDate != nil},
+		{"terminating_number", criteria.TerminatingNumber != ""},
+		{"user", criteria.User != ""},
+	} {
+		if param.set {
+			used = append(used, param.name)
+		}
+	}
+	if len(used) == 0 {
+		return DiscoveryParamsNone
+	}
+	return strings.Join(used, ",")
+}
+
+// Record adds a search's endpoint results to the statistics. Failures are logged rather
+// than returned so analytics never fail a search.
+func (da *DiscoveryAnalytics) Record(baseURL string, criteria CDRSearchCriteria, results []EndpointResult) {
+	if da == nil || len(results) == 0 {
+		return
+	}
+	if err := da.record(baseURL, criteria, results, time.Now().UTC()); err != nil {
+		log.Printf("[Discovery Analytics] Failed to record search: %v", err)
+	}
+}
+
+func (da *DiscoveryAnalytics) record(baseURL string, criteria CDRSearchCriteria, results []EndpointResult, now time.Time) error {
+	tx, err := da.db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	params := DiscoveryParams(criteria)
+	domain := strings.ToLower(criteria.Domain)
+	day := now.Format("2006-01-02")
+	for _, result := range results {
+		success, withData, denied := 0, 0, 0
+		var lastSuccess interface{}
+		if result.Success {
+			success, lastSuccess = 1, now
+			if result.RecordCount > 0 {
+				withData = 1
+			}
+		}
+		if result.HTTPStatus == http.StatusForbidden || result.HTTPStatus == http.StatusNotFound {
+			denied = 1
+		}
+		queryMS := result.QueryTime.Milliseconds()
+
+		// Counts add to what is there, so every query is counted once
+		if _, err := tx.Exec(`
+		INSERT INTO discovery_analytics (
+			base_url, endpoint_name, param_combination, domain, query_count, success_count, data_count,
+			record_count, total_query_ms, denied_count, last_status, last_error, last_queried_at, last_success_at
+		) VALUES (?, ?, ?, ?, 1, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(base_url, endpoint_name, param_combination, domain) DO UPDATE SET
+			query_count = query_count + 1,
+			success_count = success_count + excluded.success_count,
+			data_count = data_count + excluded.data_count,
+			record_count = record_count + excluded.record_count,
+			total_query_ms = total_query_ms + excluded.total_query_ms,
+			denied_count = denied_count + excluded.denied_count,
+			last_status = excluded.last_status,
+			last_error = excluded.last_error,
+			last_queried_at = excluded.last_queried_at,
+			last_success_at = COALESCE(excluded.last_success_at, last_success_at)`,
+			baseURL, result.EndpointName, params, domain, success, withData,
+			result.RecordCount, queryMS, denied, result.HTTPStatus, result.Error, now, lastSuccess); err != nil {
+			return fmt.Errorf("failed to record %s: %w", result.EndpointName, err)
+		}
+
+		if _, err := tx.Exec(`
+		INSERT INTO discovery_analytics_daily (
+			base_url, endpoint_name, domain, day, query_count, success_count, record_count, total_query_ms
+		) VALUES (?, ?, ?, ?, 1, ?, ?, ?)
+		ON CONFLICT(base_url, endpoint_name, domain, day) DO UPDATE SET
+			query_count = query_count + 1,
+			success_count = success_count + excluded.success_count,
+			record_count = record_count + excluded.record_count,
+			total_query_ms = total_query_ms + excluded.total_query_ms`,
+			baseURL, result.EndpointName, domain, day, success, result.RecordCount, queryMS); err != nil {
+			return fmt.Errorf("failed to record %s for %s: %w", result.EndpointName, day, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// where builds the WHERE clause of a filter
+func (f DiscoveryAnalyticsFilter) where() (string, []interface{}) {
+	clauses, args := []string{"1=1"}, []interface{}{}
+	if f.BaseURL != "" {
+		clauses = append(clauses, "base_url = ?")
+		args = append(args, f.BaseURL)
+	}
+	if f.Domain != "" {
+		clauses = append(clauses, "domain = ?")
+		args = append(args, strings.ToLower(f.Domain))
+	}
+	if f.Endpoint != "" {
+		clauses = append(clauses, "endpoint_name = ?")
+		args = append(args, f.Endpoint)
+	}
+	return " WHERE " + strings.Join(clauses, " AND "), args
+}
+
+// TopCombinations returns the endpoint and parameter combinations with at least
+// minQueries queries, best first: most often successful, then most often returning
+// CDRs, then fastest. Domains are added together unless the filter names one.
+func (da *DiscoveryAnalytics) TopCombinations(filter DiscoveryAnalyticsFilter, minQueries, limit int) ([]DiscoveryCombination, error) {
+	where, args := filter.where()
+	query := `
+	SELECT base_url, endpoint_name, param_combination, SUM(query_count), SUM(success_count),
+		SUM(data_count), SUM(record_count), SUM(total_query_ms)
+	FROM discovery_analytics` + where + `
+	GROUP BY base_url, endpoint_name, param_combination
+	HAVING SUM(query_count) >= ?`
+	args = append(args, minQueries)
+
+	rows, err := da.db.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query discovery analytics: %w", err)
+	}
+	defer rows.Close()
+
+	combinations := []DiscoveryCombination{}
+	for rows.Next() {
+		var combination DiscoveryCombination
+		var totalMS int64
+		if err := rows.Scan(&combination.BaseURL, &combination.EndpointName, &combination.ParamCombination,
+			&combination.Queries, &combination.Successes, &combination.WithData, &combination.Records,
+			&totalMS); err != nil {
+			return nil, fmt.Errorf("failed to read discovery analytics: %w", err)
+		}
+		if combination.Queries > 0 {
+			queries := float64(combination.Queries)
+			combination.SuccessRate = roundTo(float64(combination.Successes)*100/queries, 1)
+			combination.DataRate = roundTo(float64(combination.WithData)*100/queries, 1)
+			combination.AvgRecords = roundTo(float64(combination.Records)/queries, 1)
+			combination.AvgQueryMS = roundTo(float64(totalMS)/queries, 1)
+		}
+		combinations = append(combinations, combination)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := da.lastOutcomes(filter, combinations); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(combinations, func(i, j int) bool {
+		a, b := combinations[i], combinations[j]
+		if a.SuccessRate != b.SuccessRate {
+			return a.SuccessRate > b.SuccessRate
+		}
+		if a.DataRate != b.DataRate {
+			return a.DataRate > b.DataRate
+		}
+		return a.AvgQueryMS < b.AvgQueryMS
+	})
+	if limit > 0 && len(combinations) > limit {
+		combinations = combinations[:limit]
+	}
+	return combinations, nil
+}
+
+// lastOutcomes fills in when each combination was last queried and last succeeded, and
+// the status and error of its most recent query
+func (da *DiscoveryAnalytics) lastOutcomes(filter DiscoveryAnalyticsFilter, combinations []DiscoveryCombination) error {
+	where, args := filter.where()
+	rows, err := da.db.db.Query(`
+	SELECT base_url, endpoint_name, param_combination, COALESCE(last_status, 0), COALESCE(last_error, ''),
+		last_queried_at, last_success_at
+	FROM discovery_analytics`+where+`
+	ORDER BY last_queried_at`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query discovery analytics: %w", err)
+	}
+	defer rows.Close()
+
+	index := map[[3]string]*DiscoveryCombination{}
+	for i := range combinations {
+		c := &combinations[i]
+		index[[3]string{c.BaseURL, c.EndpointName, c.ParamCombination}] = c
+	}
+	for rows.Next() {
+		var key [3]string
+		var status int
+		var lastError string
+		var queried time.Time
+		var succeeded sql.NullTime
+		if err := rows.Scan(&key[0], &key[1], &key[2], &status, &lastError, &queried, &succeeded); err != nil {
+			return fmt.Errorf("failed to read discovery analytics: %w", err)
+		}
+		c, exists := index[key]
+		if !exists {
+			continue
+		}
+		// Rows come oldest first, so the newest query's outcome is kept
+		c.LastStatus, c.LastError, c.LastQueriedAt = status, lastError, queried
+		if succeeded.Valid && (c.LastSuccessAt == nil || succeeded.Time.After(*c.LastSuccessAt)) {
+			last := succeeded.Time
+			c.LastSuccessAt = &last
+		}
+	}
+	return rows.Err()
+}
+
+// Suggestions returns the criteria combinations that have returned CDRs for domains
+// starting with domainPrefix, optionally searched on one base URL: the ones most likely to
+// return data first, then the fastest. Every search queries each of its endpoints once,
+// so the busiest endpoint's query count is the number of searches.
+func (da *DiscoveryAnalytics) Suggestions(baseURL, domainPrefix string, limit int) ([]SearchSuggestion, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(domainPrefix))
+	query := `
+	SELECT domain, param_combination, MAX(query_count), MAX(data_count), SUM(record_count), SUM(total_query_ms),
+		COALESCE(GROUP_CONCAT(CASE WHEN data_count > 0 THEN endpoint_name END), '')
+	FROM discovery_analytics
+	WHERE domain != '' AND domain LIKE ? ESCAPE '\'`
+	args := []interface{}{escaped + "%"}
+	if baseURL != "" {
+		query += " AND base_url = ?"
+		args = append(args, baseURL)
+	}
+	query += `
+	GROUP BY domain, param_combination
+	HAVING MAX(data_count) > 0`
+
+	rows, err := da.db.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query search suggestions: %w", err)
+	}
+	defer rows.Close()
+
+	suggestions := []SearchSuggestion{}
+	for rows.Next() {
+		var suggestion SearchSuggestion
+		var withData, records int
+		var totalMS int64
+		var endpoints string
+		if err := rows.Scan(&suggestion.Domain, &suggestion.ParamCombination, &suggestion.Searches, &withData,
+			&records, &totalMS, &endpoints); err != nil {
+			return nil, fmt.Errorf("failed to read search suggestions: %w", err)
+		}
+		suggestion.Params = strings.Split(suggestion.ParamCombination, ",")
+		if suggestion.ParamCombination == DiscoveryParamsNone {
+			suggestion.Params = []string{}
+		}
+		suggestion.Endpoints = strings.Split(endpoints, ",")
+		sort.Strings(suggestion.Endpoints)
+		searches := float64(suggestion.Searches)
+		suggestion.DataRate = roundTo(float64(withData)*100/searches, 1)
+		suggestion.AvgRecords = roundTo(float64(records)/searches, 1)
+		suggestion.AvgSearchMS = roundTo(float64(totalMS)/searches, 1)
+		suggestions = append(suggestions, suggestion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.DataRate != b.DataRate {
+			return a.DataRate > b.DataRate
+		}
+		if a.AvgSearchMS != b.AvgSearchMS {
+			return a.AvgSearchMS < b.AvgSearchMS
+		}
+		return a.Domain+a.ParamCombination < b.Domain+b.ParamCombination
+	})
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+// EndpointHistories sums each endpoint's queries on a base URL over every domain and
+// criteria combination; nil analytics have no history
+func (da *DiscoveryAnalytics) EndpointHistories(baseURL string) (map[string]EndpointHistory, error) {
+	if da == nil {
+		return nil, nil
+	}
+	// With MAX() in the select, SQLite takes the bare last_queried_at from the newest row,
+	// keeping its column type
+	rows, err := da.db.db.Query(`
+	SELECT endpoint_name, SUM(query_count), SUM(success_count), SUM(denied_count), SUM(record_count),
+		last_queried_at, MAX(last_queried_at)
+	FROM discovery_analytics
+	WHERE base_url = ?
+	GROUP BY endpoint_name`, baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query endpoint history: %w", err)
+	}
+	defer rows.Close()
+
+	histories := map[string]EndpointHistory{}
+	for rows.Next() {
+		var history EndpointHistory
+		var newest interface{}
+		if err := rows.Scan(&history.EndpointName, &history.Queries, &history.Successes, &history.Denied,
+			&history.Records, &history.LastQueriedAt, &newest); err != nil {
+			return nil, fmt.Errorf("failed to read endpoint history: %w", err)
+		}
+		histories[history.EndpointName] = history
+	}
+	return histories, rows.Err()
+}
+
+// EndpointTrends returns each endpoint's queries per day over the last days days,
+// including today
+func (da *DiscoveryAnalytics) EndpointTrends(filter DiscoveryAnalyticsFilter, days int) ([]EndpointTrend, error) {
+	since := time.Now().UTC().AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+	where, args := filter.where()
+	rows, err := da.db.db.Query(`
+	SELECT base_url, endpoint_name, day, SUM(query_count), SUM(success_count), SUM(record_count), SUM(total_query_ms)
+	FROM discovery_analytics_daily`+where+` AND day >= ?
+	GROUP BY base_url, endpoint_name, day
+	ORDER BY base_url, endpoint_name, day`, append(args, since)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query endpoint trends: %w", err)
+	}
+	defer rows.Close()
+
+	trends := []EndpointTrend{}
+	var successes int
+	finish := func() {
+		if len(trends) > 0 && trends[len(trends)-1].Queries > 0 {
+			trend := &trends[len(trends)-1]
+			trend.SuccessRate = roundTo(float64(successes)*100/float64(trend.Queries), 1)
+		}
+	}
+	for rows.Next() {
+		var baseURL, endpoint string
+		var day EndpointDay
+		var totalMS int64
+		if err := rows.Scan(&baseURL, &endpoint, &day.Day, &day.Queries, &day.Successes, &day.Records, &totalMS); err != nil {
+			return nil, fmt.Errorf("failed to read endpoint trends: %w", err)
+		}
+		if day.Queries > 0 {
+			day.SuccessRate = roundTo(float64(day.Successes)*100/float64(day.Queries), 1)
+			day.AvgQueryMS = roundTo(float64(totalMS)/float64(day.Queries), 1)
+		}
+
+		if len(trends) == 0 || trends[len(trends)-1].BaseURL != baseURL || trends[len(trends)-1].EndpointName != endpoint {
+			finish()
+			trends = append(trends, EndpointTrend{BaseURL: baseURL, EndpointName: endpoint, Days: []EndpointDay{}})
+			successes = 0
+		}
+		trend := &trends[len(trends)-1]
+		trend.Queries += day.Queries
+		successes += day.Successes
+		trend.Days = append(trend.Days, day)
+	}
+	finish()
+	return trends, rows.Err()
+}
+
+// Reset deletes the statistics the filter matches, returning how many combinations
+// were removed
+func (da *DiscoveryAnalytics) Reset(filter DiscoveryAnalyticsFilter) (int64, error) {
+	tx, err := da.db.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	where, args := filter.where()
+	result, err := tx.Exec("DELETE FROM discovery_analytics"+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset discovery analytics: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM discovery_analytics_daily"+where, args...); err != nil {
+		return 0, fmt.Errorf("failed to reset endpoint trends: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	removed, _ := result.RowsAffected()
+	return removed, nil
+}
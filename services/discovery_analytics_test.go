@@ -0,0 +1,149 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiscoveryAnalytics(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "analytics.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+	analytics := NewDiscoveryAnalytics(db)
+
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	domainSearch := CDRSearchCriteria{Domain: "Acme", StartDate: &day}
+	userSearch := CDRSearchCriteria{Domain: "acme", User: "100"}
+	if params := DiscoveryParams(domainSearch); params != "domain,start_date" {
+		t.Errorf("DiscoveryParams = %q", params)
+	}
+	if params := DiscoveryParams(CDRSearchCriteria{}); params != DiscoveryParamsNone {
+		t.Errorf("DiscoveryParams of no criteria = %q", params)
+	}
+
+	// Three searches by domain: the domain endpoint always answers, the global one is forbidden
+	for i := 0; i < 3; i++ {
+		analytics.Record("https://pbx", domainSearch, []EndpointResult{
+			{EndpointName: "global_cdrs", HTTPStatus: 403, Error: "HTTP 403: 403 Forbidden", QueryTime: 10 * time.Millisecond},
+			{EndpointName: "domain_cdrs", Success: true, HTTPStatus: 200, RecordCount: 10 * (i + 1), QueryTime: 200 * time.Millisecond},
+		})
+	}
+	// A user search answered once with data, once empty
+	analytics.Record("https://pbx", userSearch, []EndpointResult{{EndpointName: "user_cdrs", Success: true, HTTPStatus: 200, RecordCount: 4, QueryTime: 50 * time.Millisecond}})
+	analytics.Record("https://pbx", userSearch, []EndpointResult{{EndpointName: "user_cdrs", Success: true, HTTPStatus: 200, QueryTime: 30 * time.Millisecond}})
+
+	top, err := analytics.TopCombinations(DiscoveryAnalyticsFilter{Domain: "ACME"}, 1, 10)
+	if err != nil {
+		t.Fatalf("TopCombinations: %v", err)
+	}
+	if len(top) != 3 {
+		t.Fatalf("TopCombinations = %+v, want 3 combinations", top)
+	}
+	best := top[0]
+	if best.EndpointName != "domain_cdrs" || best.ParamCombination != "domain,start_date" || best.Queries != 3 || best.Successes != 3 ||
+		best.Records != 60 || best.AvgRecords != 20 || best.AvgQueryMS != 200 || best.DataRate != 100 || best.LastSuccessAt == nil {
+		t.Errorf("best = %+v, want domain_cdrs with every success counted", best)
+	}
+	if top[1].EndpointName != "user_cdrs" || top[1].DataRate != 50 || top[1].Records != 4 {
+		t.Errorf("second = %+v, want user_cdrs returning data half the time", top[1])
+	}
+	if worst := top[2]; worst.EndpointName != "global_cdrs" || worst.SuccessRate != 0 || worst.LastStatus != 403 || worst.LastSuccessAt != nil {
+		t.Errorf("worst = %+v, want the forbidden global endpoint", worst)
+	}
+	if top, _ := analytics.TopCombinations(DiscoveryAnalyticsFilter{}, 3, 10); len(top) != 2 {
+		t.Errorf("min_queries 3 kept %d combinations, want 2", len(top))
+	}
+
+	trends, err := analytics.EndpointTrends(DiscoveryAnalyticsFilter{}, 7)
+	if err != nil {
+		t.Fatalf("EndpointTrends: %v", err)
+	}
+	if len(trends) != 3 || trends[0].EndpointName != "domain_cdrs" || trends[0].Queries != 3 || trends[0].SuccessRate != 100 ||
+		len(trends[0].Days) != 1 || trends[1].EndpointName != "global_cdrs" || trends[1].SuccessRate != 0 {
+		t.Errorf("EndpointTrends = %+v", trends)
+	}
+
+	histories, err := analytics.EndpointHistories("https://pbx")
+	if err != nil {
+		t.Fatalf("EndpointHistories: %v", err)
+	}
+	if global := histories["global_cdrs"]; global.Queries != 3 || global.Denied != 3 || global.LastQueriedAt.IsZero() {
+		t.Errorf("global_cdrs history = %+v, want 3 denied queries", global)
+	}
+	if user := histories["user_cdrs"]; user.Queries != 2 || user.Denied != 0 || user.Yield() != 2 {
+		t.Errorf("user_cdrs history = %+v, want 4 CDRs over 2 queries", user)
+	}
+
+	suggestions, err := analytics.Suggestions("", "AC", 0)
+	if err != nil {
+		t.Fatalf("Suggestions: %v", err)
+	}
+	if len(suggestions) != 2 {
+		t.Fatalf("Suggestions = %+v, want the two combinations that returned CDRs", suggestions)
+	}
+	if first := suggestions[0]; first.Domain != "acme" || first.ParamCombination != "domain,start_date" || first.Searches != 3 ||
+		first.DataRate != 100 || first.AvgRecords != 20 || first.AvgSearchMS != 210 || len(first.Endpoints) != 1 || first.Endpoints[0] != "domain_cdrs" {
+		t.Errorf("first suggestion = %+v, want the domain search with both endpoints' time added", first)
+	}
+	if second := suggestions[1]; second.ParamCombination != "domain,user" || second.Searches != 2 || second.DataRate != 50 || second.AvgSearchMS != 40 {
+		t.Errorf("second suggestion = %+v", second)
+	}
+	if suggestions, _ := analytics.Suggestions("", "a%", 0); len(suggestions) != 0 {
+		t.Errorf("a%% matched %+v, want wildcards taken literally", suggestions)
+	}
+	if suggestions, _ := analytics.Suggestions("https://other", "acme", 0); len(suggestions) != 0 {
+		t.Errorf("another base URL matched %+v", suggestions)
+	}
+
+	removed, err := analytics.Reset(DiscoveryAnalyticsFilter{Endpoint: "global_cdrs"})
+	if err != nil || removed != 1 {
+		t.Errorf("Reset = %d, %v; want 1 combination removed", removed, err)
+	}
+	if trends, _ := analytics.EndpointTrends(DiscoveryAnalyticsFilter{}, 7); len(trends) != 2 {
+		t.Errorf("trends after reset = %+v, want the global endpoint gone", trends)
+	}
+}
+
+func TestAdaptEndpoints(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	endpoints := []CDREndpointConfig{{Name: "global_cdrs"}, {Name: "domain_cdrs"}, {Name: "user_cdrs"}, {Name: "site_cdrs"}}
+	names := func(endpoints []CDREndpointConfig) string {
+		list := []string{}
+		for _, endpoint := range endpoints {
+			list = append(list, endpoint.Name)
+		}
+		return strings.Join(list, ",")
+	}
+
+	histories := map[string]EndpointHistory{
+		"global_cdrs": {Queries: 5, Denied: 5, LastQueriedAt: now.Add(-time.Hour)},
+		"domain_cdrs": {Queries: 4, Successes: 4, Records: 40, LastQueriedAt: now},
+		"user_cdrs":   {Queries: 2, Successes: 2, Records: 200, LastQueriedAt: now},
+	}
+	kept, skipped := adaptEndpoints(endpoints, histories, now)
+	if got := names(kept); got != "user_cdrs,domain_cdrs,site_cdrs" {
+		t.Errorf("kept %s, want the denied endpoint left out and the rest by CDRs per query", got)
+	}
+	if len(skipped) != 1 || skipped[0].EndpointName != "global_cdrs" {
+		t.Errorf("skipped = %+v", skipped)
+	}
+
+	// A day after it was last denied, the endpoint is tried again
+	if kept, skipped := adaptEndpoints(endpoints, histories, now.Add(endpointDeniedRetryAfter)); len(kept) != 4 || skipped != nil {
+		t.Errorf("after the retry period kept %s, skipped %+v", names(kept), skipped)
+	}
+	// Too few queries to tell
+	histories["global_cdrs"] = EndpointHistory{Queries: 2, Denied: 2, LastQueriedAt: now}
+	if kept, _ := adaptEndpoints(endpoints, histories, now); len(kept) != 4 {
+		t.Errorf("kept %s, want an endpoint denied twice still queried", names(kept))
+	}
+	// Every endpoint denied: query them all anyway
+	denied := map[string]EndpointHistory{"global_cdrs": {Queries: 3, Denied: 3, LastQueriedAt: now}}
+	if kept, skipped := adaptEndpoints(endpoints[:1], denied, now); len(kept) != 1 || skipped != nil {
+		t.Errorf("kept %s, skipped %+v; want the only endpoint queried", names(kept), skipped)
+	}
+}
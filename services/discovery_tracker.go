@@ -0,0 +1,102 @@
+// services/discovery_tracker.go
+// Tracks CDR discovery sessions that are still querying endpoints
+
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ActiveDiscovery describes a discovery session in progress
+type ActiveDiscovery struct {
+	SessionID          string            `json:"session_id"`
+	BaseURL            string            `json:"base_url"`
+	Criteria           CDRSearchCriteria `json:"search_criteria"`
+	StartTime          time.Time         `json:"start_time"`
+	EndpointsTotal     int               `json:"endpoints_total"`
+	EndpointsCompleted int               `json:"endpoints_completed"`
+	CurrentEndpoint    string            `json:"current_endpoint"`
+	CDRsFound          int               `json:"cdrs_found"`
+	Elapsed            string            `json:"elapsed"`
+}
+
+// DiscoveryTracker keeps a thread-safe registry of running discovery sessions
+type DiscoveryTracker struct {
+	mu       sync.RWMutex
+	sessions map[string]*ActiveDiscovery
+}
+
+// GlobalDiscoveryTracker is the tracker shared by every CDRDiscoveryService
+var GlobalDiscoveryTracker = NewDiscoveryTracker()
+
+// NewDiscoveryTracker creates an empty tracker
+func NewDiscoveryTracker() *DiscoveryTracker {
+	return &DiscoveryTracker{
+		sessions: make(map[string]*ActiveDiscovery),
+	}
+}
+
+// Start registers a new discovery session
+func (dt *DiscoveryTracker) Start(sessionID, baseURL string, criteria CDRSearchCriteria, endpointsTotal int) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	dt.sessions[sessionID] = &ActiveDiscovery{
+		SessionID:      sessionID,
+		BaseURL:        baseURL,
+		Criteria:       criteria,
+		StartTime:      time.Now(),
+		EndpointsTotal: endpointsTotal,
+	}
+}
+
+// BeginEndpoint records which endpoint a session is currently querying
+func (dt *DiscoveryTracker) BeginEndpoint(sessionID, endpointName string) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if session, exists := dt.sessions[sessionID]; exists {
+		session.CurrentEndpoint = endpointName
+	}
+}
+
+// CompleteEndpoint records a finished endpoint query
+func (dt *DiscoveryTracker) CompleteEndpoint(sessionID string, recordCount int) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	if session, exists := dt.sessions[sessionID]; exists {
+		session.EndpointsCompleted++
+		session.CDRsFound += recordCount
+		session.CurrentEndpoint = ""
+	}
+}
+
+// Finish removes a session from the registry
+func (dt *DiscoveryTracker) Finish(sessionID string) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	delete(dt.sessions, sessionID)
+}
+
+// GetActive returns a snapshot of running sessions, oldest first
+func (dt *DiscoveryTracker) GetActive() []ActiveDiscovery {
+	dt.mu.RLock()
+	defer dt.mu.RUnlock()
+
+	active := make([]ActiveDiscovery, 0, len(dt.sessions))
+	for _, session := range dt.sessions {
+		snapshot := *session
+		snapshot.Elapsed = time.Since(session.StartTime).Round(time.Millisecond).String()
+		active = append(active, snapshot)
+	}
+
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].StartTime.Before(active[j].StartTime)
+	})
+
+	return active
+}
@@ -0,0 +1,172 @@
+// services/domain_overview.go
+// Domain overview: one customer's recent searches, daily call volume, busiest users,
+// why unanswered calls ended and telephony KPIs, the view an MSP account manager wants
+// for a single domain
+
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// domainOverviewTop is how many users and failure reasons an overview lists
+const domainOverviewTop = 10
+
+// domainOverviewSessions is how many recent searches an overview lists
+const domainOverviewSessions = 10
+
+// DomainSession is a search run for the domain
+type DomainSession struct {
+	SessionID    string    `json:"session_id"`
+	StartTime    time.Time `json:"start_time"`
+	TotalCDRs    int       `json:"total_cdrs"`
+	QualityScore float64   `json:"quality_score,omitempty"` // 0 when not scored
+	RerunOf      string    `json:"rerun_of,omitempty"`
+}
+
+// DomainVolume is one day of the domain's stored CDRs
+type DomainVolume struct {
+	Day      string  `json:"day"`
+	Calls    int     `json:"calls"`
+	Answered int     `json:"answered"`
+	Minutes  float64 `json:"minutes"`
+}
+
+// DomainUser is one of the domain's busiest users, counting calls they made or received
+type DomainUser struct {
+	User    string  `json:"user"`
+	Calls   int     `json:"calls"`
+	Minutes float64 `json:"minutes"`
+}
+
+// DomainOverview is a domain's activity between two days
+type DomainOverview struct {
+	Domain         string            `json:"domain"`
+	Start          string            `json:"start"`
+	End            string            `json:"end"`
+	Sessions       []DomainSession   `json:"sessions"` // most recent first, whatever their dates
+	Volume         []DomainVolume    `json:"volume"`   // by day, from stored CDR summaries
+	Calls          int               `json:"calls"`
+	TopUsers       []DomainUser      `json:"top_users"`
+	FailureReasons []FieldValueCount `json:"failure_reasons"` // why unanswered calls ended
+	KPIs           TelephonyKPIs     `json:"kpis"`            // over the recorded call outcomes
+	GeneratedAt    time.Time         `json:"generated_at"`
+}
+
+// GetDomainOverview gathers a domain's activity between two days (inclusive, UTC)
+func (ds *DatabaseService) GetDomainOverview(domain string, start, end time.Time) (*DomainOverview, error) {
+	overview := &DomainOverview{
+		Domain:         domain,
+		Start:          start.Format(kpiDayFormat),
+		End:            end.Format(kpiDayFormat),
+		Sessions:       []DomainSession{},
+		Volume:         []DomainVolume{},
+		TopUsers:       []DomainUser{},
+		FailureReasons: []FieldValueCount{},
+		GeneratedAt:    time.Now().UTC(),
+	}
+	// Stored start times are compared as timestamps, so the last day runs to midnight
+	from, to := start.UTC(), end.UTC().AddDate(0, 0, 1)
+
+	rows, err := ds.db.Query(`
+	SELECT session_id, start_time, total_cdrs, quality_score, COALESCE(rerun_of, '')
+	FROM search_sessions
+	WHERE json_extract(search_criteria, '$.domain') = ?
+	ORDER BY start_time DESC LIMIT ?`, domain, domainOverviewSessions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query domain sessions: %w", err)
+	}
+	for rows.Next() {
+		var session DomainSession
+		var quality sql.NullFloat64
+		if err := rows.Scan(&session.SessionID, &session.StartTime, &session.TotalCDRs, &quality, &session.RerunOf); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan domain session: %w", err)
+		}
+		session.QualityScore = quality.Float64
+		overview.Sessions = append(overview.Sessions, session)
+	}
+	rows.Close()
+
+	rows, err = ds.db.Query(`
+	SELECT date(call_start_time) AS day, COUNT(*), SUM(call_duration_seconds > 0),
+		COALESCE(SUM(call_duration_seconds), 0)
+	FROM cdr_summaries
+	WHERE domain = ? AND call_start_time >= ? AND call_start_time < ?
+	GROUP BY day ORDER BY day`, domain, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query domain volume: %w", err)
+	}
+	for rows.Next() {
+		var volume DomainVolume
+		var seconds int
+		if err := rows.Scan(&volume.Day, &volume.Calls, &volume.Answered, &seconds); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan domain volume: %w", err)
+		}
+		volume.Minutes = roundTo(float64(seconds)/60, 1)
+		overview.Calls += volume.Calls
+		overview.Volume = append(overview.Volume, volume)
+	}
+	rows.Close()
+
+	rows, err = ds.db.Query(`
+	SELECT user, COUNT(*) AS calls, COALESCE(SUM(seconds), 0)
+	FROM (
+		SELECT orig_user AS user, call_duration_seconds AS seconds FROM cdr_summaries
+		WHERE domain = ? AND call_start_time >= ? AND call_start_time < ? AND orig_user != ''
+		UNION ALL
+		SELECT term_user, call_duration_seconds FROM cdr_summaries
+		WHERE domain = ? AND call_start_time >= ? AND call_start_time < ? AND term_user != '' AND term_user IS NOT orig_user
+	)
+	GROUP BY user ORDER BY calls DESC, user LIMIT ?`, domain, from, to, domain, from, to, domainOverviewTop)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query domain users: %w", err)
+	}
+	for rows.Next() {
+		var user DomainUser
+		var seconds int
+		if err := rows.Scan(&user.User, &user.Calls, &seconds); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan domain user: %w", err)
+		}
+		user.Minutes = roundTo(float64(seconds)/60, 1)
+		overview.TopUsers = append(overview.TopUsers, user)
+	}
+	rows.Close()
+
+	rows, err = ds.db.Query(`
+	SELECT disconnect_reason, COUNT(*) AS calls
+	FROM cdr_summaries
+	WHERE domain = ? AND call_start_time >= ? AND call_start_time < ?
+		AND call_duration_seconds = 0 AND disconnect_reason != ''
+	GROUP BY disconnect_reason ORDER BY calls DESC, disconnect_reason LIMIT ?`, domain, from, to, domainOverviewTop)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query domain failure reasons: %w", err)
+	}
+	for rows.Next() {
+		var reason FieldValueCount
+		if err := rows.Scan(&reason.Value, &reason.Count); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan failure reason: %w", err)
+		}
+		overview.FailureReasons = append(overview.FailureReasons, reason)
+	}
+	rows.Close()
+
+	kpis, err := ds.GetTelephonyKPIs(KPIQuery{Domain: domain, Start: start, End: end})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range kpis {
+		overview.KPIs.Seizures += row.Seizures
+		overview.KPIs.Answered += row.Answered
+		overview.KPIs.NetworkFailures += row.NetworkFailures
+		overview.KPIs.TalkSeconds += row.TalkSeconds
+	}
+	overview.KPIs.computeRatios()
+	overview.KPIs.Domain = domain
+	return overview, nil
+}
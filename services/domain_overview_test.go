@@ -0,0 +1,55 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestGetDomainOverview(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "overview.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.StoreSearchSession("s1", CDRSearchCriteria{Domain: "acme"}, 3); err != nil {
+		t.Fatalf("StoreSearchSession: %v", err)
+	}
+	if err := db.StoreSearchSession("s2", CDRSearchCriteria{Domain: "globex"}, 1); err != nil {
+		t.Fatalf("StoreSearchSession: %v", err)
+	}
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"id": "a", "domain": "acme", "call-start-datetime": "2026-10-12T10:00:00Z",
+			"call-total-duration-seconds": 120, "call-orig-user": "1001", "call-term-user": "1002"}},
+		{RawData: map[string]interface{}{"id": "b", "domain": "acme", "call-start-datetime": "2026-10-13T23:30:00Z",
+			"call-total-duration-seconds": 0, "call-orig-user": "1001", "call-disconnect-reason-text": "Request Timeout"}},
+		{RawData: map[string]interface{}{"id": "c", "domain": "acme", "call-start-datetime": "2026-10-20T10:00:00Z",
+			"call-total-duration-seconds": 60, "call-orig-user": "1003"}},
+		{RawData: map[string]interface{}{"id": "d", "domain": "globex", "call-start-datetime": "2026-10-12T10:00:00Z",
+			"call-total-duration-seconds": 60, "call-orig-user": "2001"}},
+	}
+	if _, err := db.StoreCDRSummaries(cdrs); err != nil {
+		t.Fatalf("StoreCDRSummaries: %v", err)
+	}
+
+	start, end := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC)
+	overview, err := db.GetDomainOverview("acme", start, end)
+	if err != nil {
+		t.Fatalf("GetDomainOverview: %v", err)
+	}
+	if len(overview.Sessions) != 1 || overview.Sessions[0].SessionID != "s1" {
+		t.Errorf("sessions = %+v", overview.Sessions)
+	}
+	if overview.Calls != 2 || len(overview.Volume) != 2 || overview.Volume[0] != (DomainVolume{Day: "2026-10-12", Calls: 1, Answered: 1, Minutes: 2}) {
+		t.Errorf("volume = %+v", overview.Volume)
+	}
+	if len(overview.TopUsers) != 2 || overview.TopUsers[0] != (DomainUser{User: "1001", Calls: 2, Minutes: 2}) {
+		t.Errorf("top users = %+v", overview.TopUsers)
+	}
+	if len(overview.FailureReasons) != 1 || overview.FailureReasons[0] != (FieldValueCount{Value: "Request Timeout", Count: 1}) {
+		t.Errorf("failure reasons = %+v", overview.FailureReasons)
+	}
+}
@@ -0,0 +1,278 @@
+// services/elasticsearch_sink.go
+// Elasticsearch/OpenSearch sink: indexes a search session's CDRs straight into a cluster
+// with the _bulk API, into indices named from a template by each call's date, and keeps
+// the progress of each session's indexing
+
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"o-dan-go/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultIndexTemplate names a daily index per call date
+const DefaultIndexTemplate = "cdrs-{yyyy}.{MM}.{dd}"
+
+// elasticsearchMaxErrors caps the errors kept per run
+const elasticsearchMaxErrors = 50
+
+// ElasticsearchSettings configures the sink; it is disabled without a URL
+type ElasticsearchSettings struct {
+	URL           string // cluster URL, e.g. https://es.example.com:9200
+	IndexTemplate string // index name with {yyyy}, {MM}, {dd} and {domain} placeholders
+	Username      string // basic auth
+	Password      string
+	APIKey        string // sent as "ApiKey <key>" instead of basic auth
+	View          string // CDRViewRaw or CDRViewNormalized documents
+	Masking       MaskingMode
+	BatchSize     int  // CDRs per _bulk request
+	IndexSearches bool // index every web and scheduled search as it completes
+}
+
+// IndexRun is the progress of indexing one search session
+type IndexRun struct {
+	SessionID  string     `json:"session_id"`
+	CDRs       int        `json:"cdrs"`
+	Indexed    int        `json:"indexed"`
+	Failed     int        `json:"failed"`
+	Indices    []string   `json:"indices"` // the indices the CDRs were sent to
+	Errors     []string   `json:"errors,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ElasticsearchSink indexes session CDRs into Elasticsearch or OpenSearch
+type ElasticsearchSink struct {
+	settings ElasticsearchSettings
+	policy   *MaskingPolicy
+	client   *http.Client
+
+	mu   sync.Mutex
+	runs map[string]*IndexRun
+}
+
+// bulkResponse is the part of a _bulk response that reports each document
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		ID     string `json:"_id"`
+		Status int    `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// NewElasticsearchSink creates the sink; documents are masked with policy when the
+// settings ask for masking
+func NewElasticsearchSink(settings ElasticsearchSettings, policy *MaskingPolicy) *ElasticsearchSink {
+	settings.URL = strings.TrimRight(settings.URL, "/")
+	if settings.IndexTemplate == "" {
+		settings.IndexTemplate = DefaultIndexTemplate
+	}
+	if settings.BatchSize <= 0 {
+		settings.BatchSize = 500
+	}
+	return &ElasticsearchSink{
+		settings: settings,
+		policy:   policy,
+		client:   &http.Client{Timeout: 2 * time.Minute},
+		runs:     make(map[string]*IndexRun),
+	}
+}
+
+// ValidateIndexTemplate checks that a template makes valid index names
+func ValidateIndexTemplate(template string) error {
+	sample := models.FlexibleCDR{RawData: map[string]interface{}{"domain": "example.com"}}
+	return ValidateBulkIndex(IndexName(template, &sample, time.Now()))
+}
+
+// IndexName fills a template's placeholders from a CDR: {yyyy}, {MM} and {dd} from its
+// start time in UTC, or fallback when it has none, and {domain} from its domain
+func IndexName(template string, cdr *models.FlexibleCDR, fallback time.Time) string {
+	started := fallback
+	if start := normalizedTime(cdr, normalizedFields.start); start != nil {
+		started = *start
+	}
+	started = started.UTC()
+
+	domain := strings.Map(func(r rune) rune {
+		if strings.ContainsRune("\\/*?\"<>|,#: ", r) {
+			return '-'
+		}
+		return r
+	}, strings.ToLower(cdrFilterFirst(cdr, normalizedFields.domain...)))
+	if domain == "" {
+		domain = "unknown"
+	}
+
+	return strings.NewReplacer(
+		"{yyyy}", started.Format("2006"),
+		"{MM}", started.Format("01"),
+		"{dd}", started.Format("02"),
+		"{domain}", domain,
+	).Replace(template)
+}
+
+// Enabled reports whether a cluster is configured
+func (es *ElasticsearchSink) Enabled() bool {
+	return es != nil && es.settings.URL != ""
+}
+
+// IndexesSearches reports whether searches are indexed as they complete
+func (es *ElasticsearchSink) IndexesSearches() bool {
+	return es.Enabled() && es.settings.IndexSearches
+}
+
+// Begin records that a session is being indexed, refusing one already in progress
+func (es *ElasticsearchSink) Begin(sessionID string, cdrs int) (IndexRun, error) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if run, exists := es.runs[sessionID]; exists && run.FinishedAt == nil {
+		return IndexRun{}, fmt.Errorf("session %s is already being indexed", sessionID)
+	}
+	run := &IndexRun{SessionID: sessionID, CDRs: cdrs, Indices: []string{}, StartedAt: time.Now()}
+	es.runs[sessionID] = run
+	return *run, nil
+}
+
+// Run returns the latest indexing run for a session
+func (es *ElasticsearchSink) Run(sessionID string) (IndexRun, bool) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	run, exists := es.runs[sessionID]
+	if !exists {
+		return IndexRun{}, false
+	}
+	copied := *run
+	copied.Indices = append([]string{}, run.Indices...)
+	copied.Errors = append([]string(nil), run.Errors...)
+	return copied, true
+}
+
+// IndexSession indexes a session's CDRs in batches. CDRs without a start time are
+// filed under the session's start. Begin must have been called for the session.
+func (es *ElasticsearchSink) IndexSession(sessionID string, cdrs []models.FlexibleCDR, started time.Time) {
+	es.mu.Lock()
+	run := es.runs[sessionID]
+	es.mu.Unlock()
+
+	if es.settings.Masking != MaskingNone && es.policy != nil {
+		cdrs = es.policy.MaskCDRs(cdrs, es.settings.Masking)
+	}
+
+	indices := map[string]bool{}
+	for start := 0; start < len(cdrs); start += es.settings.BatchSize {
+		end := start + es.settings.BatchSize
+		if end > len(cdrs) {
+			end = len(cdrs)
+		}
+
+		var body, actions bytes.Buffer
+		documents := 0
+		for i := start; i < end; i++ {
+			index := IndexName(es.settings.IndexTemplate, &cdrs[i], started)
+			actions.Reset()
+			if err := WriteBulkActions(&actions, cdrs[i:i+1], index, es.settings.View); err != nil {
+				es.record(run, 0, 1, fmt.Sprintf("CDR %s: %v", cdrs[i].GetID(), err))
+				continue
+			}
+			indices[index] = true
+			body.Write(actions.Bytes())
+			documents++
+		}
+		if documents == 0 {
+			continue
+		}
+
+		indexed, failures, err := es.bulk(&body)
+		if err != nil {
+			es.record(run, 0, documents, fmt.Sprintf("CDRs %d-%d: %v", start+1, end, err))
+			continue
+		}
+		es.record(run, indexed, len(failures), failures...)
+	}
+
+	es.mu.Lock()
+	for index := range indices {
+		run.Indices = append(run.Indices, index)
+	}
+	sort.Strings(run.Indices)
+	finished := time.Now()
+	run.FinishedAt = &finished
+	log.Printf("[Elasticsearch] Session %s: %d indexed into %d indices, %d failed",
+		sessionID, run.Indexed, len(run.Indices), run.Failed)
+	es.mu.Unlock()
+}
+
+// record adds a batch's outcome to a run
+func (es *ElasticsearchSink) record(run *IndexRun, indexed, failed int, errs ...string) {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	run.Indexed += indexed
+	run.Failed += failed
+	for _, err := range errs {
+		if len(run.Errors) < elasticsearchMaxErrors {
+			run.Errors = append(run.Errors, err)
+		}
+	}
+}
+
+// bulk sends one _bulk request, returning how many documents were indexed and an error
+// for each that wasn't
+func (es *ElasticsearchSink) bulk(body io.Reader) (int, []string, error) {
+	req, err := http.NewRequest("POST", es.settings.URL+"/_bulk", body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid cluster URL: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if es.settings.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+es.settings.APIKey)
+	} else if es.settings.Username != "" {
+		req.SetBasicAuth(es.settings.Username, es.settings.Password)
+	}
+
+	resp, err := es.client.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("bulk request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, nil, fmt.Errorf("bulk request returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	var result bulkResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, nil, fmt.Errorf("failed to decode bulk response: %w", err)
+	}
+
+	indexed, failures := 0, []string{}
+	for _, item := range result.Items {
+		for _, outcome := range item {
+			if outcome.Error == nil && outcome.Status < 300 {
+				indexed++
+				continue
+			}
+			reason := fmt.Sprintf("status %d", outcome.Status)
+			if outcome.Error != nil {
+				reason = outcome.Error.Type + ": " + outcome.Error.Reason
+			}
+			failures = append(failures, fmt.Sprintf("CDR %s: %s", outcome.ID, reason))
+		}
+	}
+	return indexed, failures, nil
+}
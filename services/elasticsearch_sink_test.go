@@ -0,0 +1,74 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestElasticsearchSink(t *testing.T) {
+	requests := 0
+	cluster := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/_bulk" || r.Header.Get("Authorization") != "ApiKey secret" {
+			t.Errorf("request to %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		// Reject the CDR with ID bad, index the rest
+		var items []string
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var action bulkIndexAction
+			if err := json.Unmarshal(scanner.Bytes(), &action); err != nil {
+				t.Fatalf("bad action line %s: %v", scanner.Text(), err)
+			}
+			scanner.Scan()
+			if action.Index.ID == "bad" {
+				items = append(items, `{"index":{"_id":"bad","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse"}}}`)
+			} else {
+				items = append(items, fmt.Sprintf(`{"index":{"_id":%q,"_index":%q,"status":201}}`, action.Index.ID, action.Index.Index))
+			}
+		}
+		fmt.Fprintf(w, `{"errors":true,"items":[%s]}`, strings.Join(items, ","))
+	}))
+	defer cluster.Close()
+
+	sink := NewElasticsearchSink(ElasticsearchSettings{URL: cluster.URL + "/", APIKey: "secret", BatchSize: 2}, nil)
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"id": "a", "call-start-datetime": "2026-10-11T23:59:00Z"}},
+		{RawData: map[string]interface{}{"id": "bad", "call-start-datetime": "2026-10-12T00:01:00Z"}},
+		{RawData: map[string]interface{}{"id": "c"}},
+	}
+	if _, err := sink.Begin("s1", len(cdrs)); err != nil {
+		t.Fatalf("Begin: %v", err)
+	}
+	if _, err := sink.Begin("s1", len(cdrs)); err == nil {
+		t.Error("Begin allowed a second run of a session in progress")
+	}
+	sink.IndexSession("s1", cdrs, time.Date(2026, 10, 13, 8, 0, 0, 0, time.UTC))
+
+	run, ok := sink.Run("s1")
+	if !ok || run.FinishedAt == nil {
+		t.Fatalf("run = %+v, want a finished run", run)
+	}
+	if requests != 2 || run.Indexed != 2 || run.Failed != 1 || len(run.Errors) != 1 {
+		t.Errorf("%d requests, run = %+v; want 2 requests, 2 indexed and bad failed", requests, run)
+	}
+	want := []string{"cdrs-2026.10.11", "cdrs-2026.10.12", "cdrs-2026.10.13"}
+	if fmt.Sprint(run.Indices) != fmt.Sprint(want) {
+		t.Errorf("indices = %v, want %v", run.Indices, want)
+	}
+
+	if err := ValidateIndexTemplate("cdrs-{domain}-{yyyy}.{MM}"); err != nil {
+		t.Errorf("ValidateIndexTemplate rejected a valid template: %v", err)
+	}
+	if ValidateIndexTemplate("CDRs-{yyyy}") == nil {
+		t.Error("ValidateIndexTemplate accepted an uppercase template")
+	}
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// telnyxLookupURL is Telnyx's number lookup API
+const telnyxLookupURL = "https://api.telnyx.com/v2/number_lookup/"
+
+// twilioLookupURL is Twilio's Lookup v2 API
+const twilioLookupURL = "https://lookups.twilio.com/v2/PhoneNumbers/"
+
+// EnrichmentProvider looks up carrier and porting data for an E.164 phone number
+type EnrichmentProvider interface {
+	Name() string
+	Lookup(number string) (*NumberInfo, error)
+}
+
+// CallerNameProvider looks up the CNAM (caller ID name) registered for an E.164 phone number
+type CallerNameProvider interface {
+	Name() string
+	LookupCallerName(number string) (string, error)
+}
+
+// NumberInfo is what is known about a phone number's carrier and porting
+type NumberInfo struct {
+	Number     string    `json:"number"`
+	Carrier    string    `json:"carrier,omitempty"`
+	LineType   string    `json:"line_type,omitempty"` // mobile, landline, voip, ...
+	LRN        string    `json:"lrn,omitempty"`       // location routing number of the serving switch
+	Ported     bool      `json:"ported"`
+	Provider   string    `json:"provider"`
+	LookedUpAt time.Time `json:"looked_up_at"`
+}
+
+// EnrichmentSettings selects and configures the number enrichment provider
+type EnrichmentSettings struct {
+	Provider string // none, telnyx, twilio
+
+	TelnyxAPIKey string
+
+	TwilioAccountSID string
+	TwilioAuthToken  string
+}
+
+// NewEnrichmentProvider creates the configured provider, or nil if enrichment is disabled
+func NewEnrichmentProvider(settings EnrichmentSettings) EnrichmentProvider {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	switch strings.ToLower(settings.Provider) {
+	case "", "none":
+		return nil
+	case "telnyx":
+		if settings.TelnyxAPIKey == "" {
+			log.Printf("[Enrichment] Telnyx is missing an API key, lookups disabled")
+			return nil
+		}
+		return &TelnyxEnrichmentProvider{client: client, baseURL: telnyxLookupURL, apiKey: settings.TelnyxAPIKey}
+	case "twilio":
+		if settings.TwilioAccountSID == "" || settings.TwilioAuthToken == "" {
+			log.Printf("[Enrichment] Twilio is missing an account SID or auth token, lookups disabled")
+			return nil
+		}
+		return &TwilioEnrichmentProvider{
+			client:     client,
+			baseURL:    twilioLookupURL,
+			accountSID: settings.TwilioAccountSID,
+			authToken:  settings.TwilioAuthToken,
+		}
+	default:
+		log.Printf("[Enrichment] Unknown provider %q, lookups disabled", settings.Provider)
+		return nil
+	}
+}
+
+// NewCallerNameProvider creates the configured CNAM provider, or nil if CNAM lookups are disabled
+func NewCallerNameProvider(settings EnrichmentSettings) CallerNameProvider {
+	if provider, ok := NewEnrichmentProvider(settings).(CallerNameProvider); ok {
+		return provider
+	}
+	return nil
+}
+
+// TelnyxEnrichmentProvider uses Telnyx number lookup, which has carrier and LRN/porting data
+type TelnyxEnrichmentProvider struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+func (tp *TelnyxEnrichmentProvider) Name() string { return "telnyx" }
+
+// Lookup requests the carrier and portability of a number
+func (tp *TelnyxEnrichmentProvider) Lookup(number string) (*NumberInfo, error) {
+	req, err := http.NewRequest("GET", tp.baseURL+url.PathEscape(number)+"?type=carrier", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+tp.apiKey)
+
+	var result struct {
+		Data struct {
+			Carrier struct {
+				Name string `json:"name"`
+				Type string `json:"type"`
+			} `json:"carrier"`
+			Portability struct {
+				LRN             string `json:"lrn"`
+				PortedStatus    string `json:"ported_status"` // Y or N
+				SPIDCarrierName string `json:"spid_carrier_name"`
+				LineType        string `json:"line_type"`
+			} `json:"portability"`
+		} `json:"data"`
+	}
+	if err := doEnrichmentRequest(tp.client, req, &result); err != nil {
+		return nil, err
+	}
+
+	data := result.Data
+	return &NumberInfo{
+		Number:   number,
+		Carrier:  firstNonEmptyString(data.Portability.SPIDCarrierName, data.Carrier.Name),
+		LineType: strings.ToLower(firstNonEmptyString(data.Carrier.Type, data.Portability.LineType)),
+		LRN:      data.Portability.LRN,
+		Ported:   strings.EqualFold(data.Portability.PortedStatus, "Y"),
+		Provider: tp.Name(),
+	}, nil
+}
+
+// LookupCallerName requests the CNAM of a number; "" means none is registered
+func (tp *TelnyxEnrichmentProvider) LookupCallerName(number string) (string, error) {
+	req, err := http.NewRequest("GET", tp.baseURL+url.PathEscape(number)+"?type=caller-name", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+tp.apiKey)
+
+	var result struct {
+		Data struct {
+			CallerName struct {
+				CallerName string `json:"caller_name"`
+			} `json:"caller_name"`
+		} `json:"data"`
+	}
+	if err := doEnrichmentRequest(tp.client, req, &result); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Data.CallerName.CallerName), nil
+}
+
+// TwilioEnrichmentProvider uses Twilio Lookup line type intelligence. Twilio doesn't
+// return LRNs, so numbers are only known to be ported when the carrier says so.
+type TwilioEnrichmentProvider struct {
+	client     *http.Client
+	baseURL    string
+	accountSID string
+	authToken  string
+}
+
+func (tp *TwilioEnrichmentProvider) Name() string { return "twilio" }
+
+// Lookup requests the carrier and line type of a number
+func (tp *TwilioEnrichmentProvider) Lookup(number string) (*NumberInfo, error) {
+	req, err := http.NewRequest("GET", tp.baseURL+url.PathEscape(number)+"?Fields=line_type_intelligence", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(tp.accountSID, tp.authToken)
+
+	var result struct {
+		LineTypeIntelligence struct {
+			CarrierName string `json:"carrier_name"`
+			Type        string `json:"type"`
+		} `json:"line_type_intelligence"`
+	}
+	if err := doEnrichmentRequest(tp.client, req, &result); err != nil {
+		return nil, err
+	}
+
+	return &NumberInfo{
+		Number:   number,
+		Carrier:  result.LineTypeIntelligence.CarrierName,
+		LineType: strings.ToLower(result.LineTypeIntelligence.Type),
+		Provider: tp.Name(),
+	}, nil
+}
+
+// LookupCallerName requests the CNAM of a number; "" means none is registered
+func (tp *TwilioEnrichmentProvider) LookupCallerName(number string) (string, error) {
+	req, err := http.NewRequest("GET", tp.baseURL+url.PathEscape(number)+"?Fields=caller_name", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(tp.accountSID, tp.authToken)
+
+	var result struct {
+		CallerName struct {
+			CallerName string `json:"caller_name"`
+		} `json:"caller_name"`
+	}
+	if err := doEnrichmentRequest(tp.client, req, &result); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.CallerName.CallerName), nil
+}
+
+// doEnrichmentRequest sends a lookup request and decodes its JSON response
+func doEnrichmentRequest(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned HTTP %d: %s", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode lookup: %w", err)
+	}
+	return nil
+}
+
+// firstNonEmptyString returns the first non-empty string
+func firstNonEmptyString(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,154 @@
+// services/field_stats.go
+// Field statistics: what one field of a session's CDRs holds (how often it is present,
+// null or empty, its distinct values, range and most common values), for exploring
+// what a NetSapiens deployment returns
+
+package services
+
+import (
+	"encoding/json"
+	"o-dan-go/models"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxFieldStatsTop caps how many of the most common values are returned
+const maxFieldStatsTop = 100
+
+// FieldValueCount is a value and how many CDRs have it
+type FieldValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// FieldStats describes one field across a session's CDRs
+type FieldStats struct {
+	Field     string            `json:"field"`
+	CDRs      int               `json:"cdrs"`
+	Present   int               `json:"present"` // CDRs with a non-null value
+	Missing   int               `json:"missing"` // CDRs without the field
+	Nulls     int               `json:"nulls"`
+	Empty     int               `json:"empty"` // empty strings, counted in present
+	Distinct  int               `json:"distinct"`
+	Types     map[string]int    `json:"types"` // JSON type of each non-null value
+	Kind      string            `json:"kind"`  // how min and max compare: number, time or text
+	Min       string            `json:"min,omitempty"`
+	Max       string            `json:"max,omitempty"`
+	TopValues []FieldValueCount `json:"top_values"`
+}
+
+// ComputeFieldStats gathers statistics for a raw CDR field, with the top most common
+// values (at most 100)
+func ComputeFieldStats(cdrs []models.FlexibleCDR, field string, top int) *FieldStats {
+	if top <= 0 || top > maxFieldStatsTop {
+		top = maxFieldStatsTop
+	}
+	stats := &FieldStats{Field: field, CDRs: len(cdrs), Types: map[string]int{}, TopValues: []FieldValueCount{}}
+
+	counts := make(map[string]int)
+	for i := range cdrs {
+		if !cdrs[i].HasField(field) {
+			stats.Missing++
+			continue
+		}
+		raw := cdrs[i].GetRaw(field)
+		if raw == nil {
+			stats.Nulls++
+			continue
+		}
+		stats.Present++
+		stats.Types[fieldValueType(raw)]++
+		if value := fieldStatValue(raw); value != "" {
+			counts[value]++
+		} else {
+			stats.Empty++
+		}
+	}
+	stats.Distinct = len(counts)
+	stats.Kind, stats.Min, stats.Max = fieldRange(counts)
+
+	for value, count := range counts {
+		stats.TopValues = append(stats.TopValues, FieldValueCount{Value: value, Count: count})
+	}
+	sort.Slice(stats.TopValues, func(i, j int) bool {
+		if stats.TopValues[i].Count != stats.TopValues[j].Count {
+			return stats.TopValues[i].Count > stats.TopValues[j].Count
+		}
+		return stats.TopValues[i].Value < stats.TopValues[j].Value
+	})
+	if len(stats.TopValues) > top {
+		stats.TopValues = stats.TopValues[:top]
+	}
+	return stats
+}
+
+// fieldRange finds the smallest and largest values: as numbers when they all are, as
+// times when they all are, otherwise as text
+func fieldRange(counts map[string]int) (kind, min, max string) {
+	if len(counts) == 0 {
+		return "", "", ""
+	}
+	values := make([]string, 0, len(counts))
+	for value := range counts {
+		values = append(values, value)
+	}
+
+	numbers := make([]float64, 0, len(values))
+	for _, value := range values {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			break
+		}
+		numbers = append(numbers, number)
+	}
+	if len(numbers) == len(values) {
+		sort.Float64s(numbers)
+		return "number", strconv.FormatFloat(numbers[0], 'f', -1, 64), strconv.FormatFloat(numbers[len(numbers)-1], 'f', -1, 64)
+	}
+
+	times := make([]time.Time, 0, len(values))
+	for _, value := range values {
+		t, ok := parseFilterTime(value)
+		if !ok {
+			break
+		}
+		times = append(times, t)
+	}
+	if len(times) == len(values) {
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+		return "time", times[0].Format(time.RFC3339), times[len(times)-1].Format(time.RFC3339)
+	}
+
+	sort.Strings(values)
+	return "text", values[0], values[len(values)-1]
+}
+
+// fieldStatValue writes a value as text: numbers in full, objects and arrays as JSON
+func fieldStatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+// fieldValueType names the JSON type of a value
+func fieldValueType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "number"
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestComputeFieldStats(t *testing.T) {
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"duration": float64(30), "reason": "Normal", "start": "2024-03-01T10:00:00Z"}},
+		{RawData: map[string]interface{}{"duration": float64(120), "reason": "Normal", "start": "2024-03-02T10:00:00Z"}},
+		{RawData: map[string]interface{}{"duration": float64(5), "reason": "Busy", "start": nil}},
+		{RawData: map[string]interface{}{"duration": float64(30), "reason": ""}},
+	}
+
+	duration := ComputeFieldStats(cdrs, "duration", 1)
+	if duration.Present != 4 || duration.Distinct != 3 || duration.Kind != "number" || duration.Min != "5" || duration.Max != "120" {
+		t.Errorf("duration stats = %+v", duration)
+	}
+	if len(duration.TopValues) != 1 || duration.TopValues[0] != (FieldValueCount{Value: "30", Count: 2}) {
+		t.Errorf("duration top values = %+v", duration.TopValues)
+	}
+
+	reason := ComputeFieldStats(cdrs, "reason", 10)
+	if reason.Empty != 1 || reason.Distinct != 2 || reason.Kind != "text" || reason.Min != "Busy" || reason.Types["string"] != 4 {
+		t.Errorf("reason stats = %+v", reason)
+	}
+
+	start := ComputeFieldStats(cdrs, "start", 10)
+	if start.Nulls != 1 || start.Missing != 1 || start.Kind != "time" || start.Max != "2024-03-02T10:00:00Z" {
+		t.Errorf("start stats = %+v", start)
+	}
+}
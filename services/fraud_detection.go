@@ -0,0 +1,508 @@
+// services/fraud_detection.go
+// Toll-fraud detection: scans the CDRs of a search for bursts of off-hours international
+// calls, dialing through sequential numbers and users with abnormal call minutes, and
+// raises an alert with the calls behind each finding as evidence
+
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"o-dan-go/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fraud rules, as stored on alerts
+const (
+	FraudRuleInternationalBurst = "international_burst"
+	FraudRuleSequentialDialing  = "sequential_dialing"
+	FraudRuleExcessiveMinutes   = "excessive_minutes"
+)
+
+// sequentialMaxGap is how far apart neighbouring numbers may be in a sequential run,
+// so a run still counts when a few numbers in it weren't dialed
+const sequentialMaxGap = 3
+
+// fraudAlertCallsShown is how many evidence calls are listed in notifications
+const fraudAlertCallsShown = 5
+
+// highRiskNANPAreaCodes are Caribbean and Atlantic area codes that are dialed like
+// domestic numbers but billed as international, a favorite of toll fraud
+var highRiskNANPAreaCodes = map[string]bool{
+	"242": true, "246": true, "264": true, "268": true, "284": true, "345": true,
+	"441": true, "473": true, "649": true, "658": true, "664": true, "721": true,
+	"758": true, "767": true, "784": true, "809": true, "829": true, "849": true,
+	"868": true, "869": true, "876": true,
+}
+
+// FraudSettings configures the detection rules
+type FraudSettings struct {
+	ScanSearches bool // scan every web search as it completes
+
+	BurstCalls  int           // off-hours international calls by one user that make a burst
+	BurstWindow time.Duration // ... within this long
+
+	SequentialCalls int // calls to sequential numbers by one user
+
+	MinutesFactor float64 // a user's outbound minutes over this multiple of the other users' median
+	MinMinutes    int     // ... and at least this many minutes
+}
+
+// FraudCall is one call given as evidence
+type FraudCall struct {
+	CDRID       string    `json:"cdr_id"`
+	Destination string    `json:"destination"`
+	StartedAt   time.Time `json:"started_at"`
+	Duration    int       `json:"duration"` // seconds
+}
+
+// FraudEvidence is the report behind an alert
+type FraudEvidence struct {
+	Calls           []FraudCall `json:"calls"`
+	TotalCalls      int         `json:"total_calls"`
+	TotalMinutes    float64     `json:"total_minutes"`
+	FirstCall       time.Time   `json:"first_call"`
+	LastCall        time.Time   `json:"last_call"`
+	BaselineMinutes float64     `json:"baseline_minutes,omitempty"` // other users' median, for excessive_minutes
+}
+
+// FraudAlert is a suspected toll-fraud pattern for one user in a search
+type FraudAlert struct {
+	ID          int64         `json:"id"`
+	Rule        string        `json:"rule"`
+	Domain      string        `json:"domain"`
+	User        string        `json:"user"`
+	SessionID   string        `json:"session_id"`
+	Description string        `json:"description"`
+	Evidence    FraudEvidence `json:"evidence"`
+	ReportURL   string        `json:"report_url,omitempty"`
+	CDRURL      string        `json:"cdr_url,omitempty"` // the search results scanned
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// AlertSummary describes the alert in one line
+func (fa FraudAlert) AlertSummary() string {
+	return fmt.Sprintf("Fraud alert (%s): %s", fa.Rule, fa.Description)
+}
+
+// AlertDetails lists the user and the first few calls
+func (fa FraudAlert) AlertDetails() []string {
+	details := []string{
+		"Domain: " + fa.Domain,
+		"User: " + fa.User,
+		fmt.Sprintf("Calls: %d (%.1f minutes)", fa.Evidence.TotalCalls, fa.Evidence.TotalMinutes),
+	}
+	for i, call := range fa.Evidence.Calls {
+		if i == fraudAlertCallsShown {
+			details = append(details, fmt.Sprintf("... and %d more", len(fa.Evidence.Calls)-i))
+			break
+		}
+		details = append(details, fmt.Sprintf("%s to %s (%ds)",
+			call.StartedAt.UTC().Format("2006-01-02 15:04 MST"), call.Destination, call.Duration))
+	}
+	return details
+}
+
+// AlertLinks links the evidence report and the search results
+func (fa FraudAlert) AlertLinks() []AlertLink {
+	links := []AlertLink{{Label: "Evidence report", URL: fa.ReportURL}}
+	if fa.CDRURL != "" {
+		links = append(links, AlertLink{Label: "Search results", URL: fa.CDRURL})
+	}
+	return links
+}
+
+// outboundCall is a call placed by a user, with what the rules need to know about it
+type outboundCall struct {
+	FraudCall
+	domain        string
+	user          string
+	digits        string
+	international bool
+}
+
+// FraudDetector runs the fraud rules over CDRs and sends alerts
+type FraudDetector struct {
+	db          *DatabaseService
+	schedules   *ScheduleService
+	notifiers   []AlertNotifier
+	settings    FraudSettings
+	linkBaseURL string
+}
+
+// NewFraudDetector creates a detector. Off-hours follow the default business hours
+// schedule; links in alerts start with linkBaseURL ("" leaves them relative).
+func NewFraudDetector(db *DatabaseService, schedules *ScheduleService, notifiers []AlertNotifier, settings FraudSettings, linkBaseURL string) *FraudDetector {
+	return &FraudDetector{
+		db:          db,
+		schedules:   schedules,
+		notifiers:   notifiers,
+		settings:    settings,
+		linkBaseURL: strings.TrimRight(linkBaseURL, "/"),
+	}
+}
+
+// ScansSearches reports whether searches are scanned as they complete
+func (fd *FraudDetector) ScansSearches() bool {
+	return fd != nil && fd.settings.ScanSearches
+}
+
+// Scan runs every rule over a search's CDRs, stores an alert for each finding and sends
+// it. A session raises each rule at most once per user, so it can be scanned again safely.
+func (fd *FraudDetector) Scan(sessionID string, cdrs []models.FlexibleCDR) ([]FraudAlert, error) {
+	calls := map[string][]outboundCall{}
+	for i := range cdrs {
+		if call, ok := newOutboundCall(&cdrs[i]); ok {
+			key := call.user + "@" + call.domain
+			calls[key] = append(calls[key], call)
+		}
+	}
+
+	findings := []FraudAlert{}
+	for _, userCalls := range calls {
+		sort.Slice(userCalls, func(i, j int) bool { return userCalls[i].StartedAt.Before(userCalls[j].StartedAt) })
+		if alert, ok := fd.internationalBurst(userCalls); ok {
+			findings = append(findings, alert)
+		}
+		if alert, ok := fd.sequentialDialing(userCalls); ok {
+			findings = append(findings, alert)
+		}
+	}
+	findings = append(findings, fd.excessiveMinutes(calls)...)
+
+	alerts := []FraudAlert{}
+	for _, alert := range findings {
+		alert.SessionID = sessionID
+		alert.CDRURL = fd.linkBaseURL + "/web/results/" + url.PathEscape(sessionID)
+		created, err := fd.db.SaveFraudAlert(&alert)
+		if err != nil {
+			return alerts, err
+		}
+		if !created {
+			continue
+		}
+		alert.ReportURL = fd.linkBaseURL + "/api/v1/admin/fraud-alerts/" + strconv.FormatInt(alert.ID, 10)
+		log.Printf("[Fraud] %s: %s", alert.Rule, alert.Description)
+
+		for _, notifier := range fd.notifiers {
+			if err := notifier.Notify(alert); err != nil {
+				log.Printf("[Fraud] Failed to send alert %d by %s: %v", alert.ID, notifier.Name(), err)
+			}
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// internationalBurst finds the busiest window of off-hours international calls
+func (fd *FraudDetector) internationalBurst(calls []outboundCall) (FraudAlert, bool) {
+	if fd.settings.BurstCalls <= 0 {
+		return FraudAlert{}, false
+	}
+	offHours := []outboundCall{}
+	for _, call := range calls {
+		if call.international && fd.offHours(call.StartedAt) {
+			offHours = append(offHours, call)
+		}
+	}
+
+	best := []outboundCall{}
+	start := 0
+	for end := range offHours {
+		for offHours[end].StartedAt.Sub(offHours[start].StartedAt) > fd.settings.BurstWindow {
+			start++
+		}
+		if end-start+1 > len(best) {
+			best = offHours[start : end+1]
+		}
+	}
+	if len(best) < fd.settings.BurstCalls {
+		return FraudAlert{}, false
+	}
+
+	alert := newFraudAlert(FraudRuleInternationalBurst, best)
+	alert.Description = fmt.Sprintf("%s placed %d international calls outside business hours within %s",
+		alert.User, len(best), fd.settings.BurstWindow)
+	return alert, true
+}
+
+// sequentialDialing finds the longest run of calls to neighbouring numbers
+func (fd *FraudDetector) sequentialDialing(calls []outboundCall) (FraudAlert, bool) {
+	if fd.settings.SequentialCalls <= 0 {
+		return FraudAlert{}, false
+	}
+
+	// Numbers only run in sequence with others of the same length
+	byLength := map[int]map[uint64][]outboundCall{}
+	for _, call := range calls {
+		if len(call.digits) < 7 || len(call.digits) > 19 {
+			continue
+		}
+		number, err := strconv.ParseUint(call.digits, 10, 64)
+		if err != nil {
+			continue
+		}
+		if byLength[len(call.digits)] == nil {
+			byLength[len(call.digits)] = map[uint64][]outboundCall{}
+		}
+		byLength[len(call.digits)][number] = append(byLength[len(call.digits)][number], call)
+	}
+
+	var best []uint64
+	var bestCalls map[uint64][]outboundCall
+	for _, numbers := range byLength {
+		sorted := make([]uint64, 0, len(numbers))
+		for number := range numbers {
+			sorted = append(sorted, number)
+		}
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		start := 0
+		for end := 1; end <= len(sorted); end++ {
+			if end < len(sorted) && sorted[end]-sorted[end-1] <= sequentialMaxGap {
+				continue
+			}
+			if end-start > len(best) {
+				best, bestCalls = sorted[start:end], numbers
+			}
+			start = end
+		}
+	}
+	if len(best) < fd.settings.SequentialCalls {
+		return FraudAlert{}, false
+	}
+
+	run := []outboundCall{}
+	for _, number := range best {
+		run = append(run, bestCalls[number]...)
+	}
+	sort.Slice(run, func(i, j int) bool { return run[i].StartedAt.Before(run[j].StartedAt) })
+
+	alert := newFraudAlert(FraudRuleSequentialDialing, run)
+	alert.Description = fmt.Sprintf("%s called %d sequential numbers from %s to %s",
+		alert.User, len(best), bestCalls[best[0]][0].Destination, bestCalls[best[len(best)-1]][0].Destination)
+	return alert, true
+}
+
+// excessiveMinutes finds users whose outbound minutes are far above the median of the
+// other users in the search
+func (fd *FraudDetector) excessiveMinutes(calls map[string][]outboundCall) []FraudAlert {
+	if fd.settings.MinutesFactor <= 0 || len(calls) < 2 {
+		return nil
+	}
+
+	minutes := map[string]float64{}
+	for key, userCalls := range calls {
+		for _, call := range userCalls {
+			minutes[key] += float64(call.Duration) / 60
+		}
+	}
+
+	alerts := []FraudAlert{}
+	for key, total := range minutes {
+		if total < float64(fd.settings.MinMinutes) {
+			continue
+		}
+		others := make([]float64, 0, len(minutes)-1)
+		for other, otherTotal := range minutes {
+			if other != key {
+				others = append(others, otherTotal)
+			}
+		}
+		baseline := medianOf(others)
+		if total <= baseline*fd.settings.MinutesFactor {
+			continue
+		}
+
+		alert := newFraudAlert(FraudRuleExcessiveMinutes, calls[key])
+		alert.Evidence.BaselineMinutes = math.Round(baseline*10) / 10
+		alert.Description = fmt.Sprintf("%s placed %.0f minutes of calls against a median of %.1f for other users",
+			alert.User, total, baseline)
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// medianOf returns the median of values, which must not be empty
+func medianOf(values []float64) float64 {
+	sort.Float64s(values)
+	middle := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[middle-1] + values[middle]) / 2
+	}
+	return values[middle]
+}
+
+// offHours reports whether a call was placed while the default schedule is closed,
+// or outside 08:00-18:00 on weekdays in server time when there is no schedule
+func (fd *FraudDetector) offHours(at time.Time) bool {
+	if fd.schedules != nil {
+		status, err := fd.schedules.Status(DefaultScheduleDID, at)
+		if err != nil {
+			log.Printf("[Fraud] Failed to check business hours: %v", err)
+		} else if status.Reason != ScheduleNoSchedule {
+			return !status.Open
+		}
+	}
+	local := at.In(time.Local)
+	if local.Weekday() == time.Saturday || local.Weekday() == time.Sunday {
+		return true
+	}
+	return local.Hour() < 8 || local.Hour() >= 18
+}
+
+// newFraudAlert builds an alert for one user's calls
+func newFraudAlert(rule string, calls []outboundCall) FraudAlert {
+	evidence := FraudEvidence{
+		Calls:      make([]FraudCall, 0, len(calls)),
+		TotalCalls: len(calls),
+		FirstCall:  calls[0].StartedAt,
+		LastCall:   calls[len(calls)-1].StartedAt,
+	}
+	seconds := 0
+	for _, call := range calls {
+		evidence.Calls = append(evidence.Calls, call.FraudCall)
+		seconds += call.Duration
+	}
+	evidence.TotalMinutes = math.Round(float64(seconds)/6) / 10
+
+	return FraudAlert{
+		Rule:     rule,
+		Domain:   calls[0].domain,
+		User:     calls[0].user + "@" + calls[0].domain,
+		Evidence: evidence,
+	}
+}
+
+// newOutboundCall reads a call placed by a user; ok is false for inbound calls and
+// calls without a dialed number
+func newOutboundCall(cdr *models.FlexibleCDR) (outboundCall, bool) {
+	user := cdr.GetOrigUser()
+	if user == "" || cdr.GetCallDirection() == 1 {
+		return outboundCall{}, false
+	}
+	destination := firstCDRString(*cdr, "call-orig-to-user", "call-dialed-number")
+	if len(nonDigits.ReplaceAllString(destination, "")) < 7 {
+		destination = cdrCallerNumber(cdr, "term")
+	}
+	digits := nonDigits.ReplaceAllString(destination, "")
+	if len(digits) < 7 {
+		return outboundCall{}, false
+	}
+	started, _ := cdr.GetCallStartTime()
+
+	return outboundCall{
+		FraudCall: FraudCall{
+			CDRID:       cdr.GetID(),
+			Destination: destination,
+			StartedAt:   started,
+			Duration:    cdr.GetCallDuration(),
+		},
+		domain:        cdr.GetDomain(),
+		user:          user,
+		digits:        digits,
+		international: isInternationalNumber(destination),
+	}, true
+}
+
+// isInternationalNumber reports whether a dialed number leaves North America, or goes to
+// a Caribbean or Atlantic area code billed at international rates
+func isInternationalNumber(number string) bool {
+	number = strings.TrimSpace(number)
+	digits := nonDigits.ReplaceAllString(number, "")
+	switch {
+	case strings.HasPrefix(digits, "011"), strings.HasPrefix(digits, "00"):
+		return true
+	case strings.HasPrefix(number, "+") && !strings.HasPrefix(digits, "1"):
+		return true
+	}
+	if len(digits) == 11 && digits[0] == '1' {
+		digits = digits[1:]
+	}
+	return len(digits) == 10 && highRiskNANPAreaCodes[digits[:3]]
+}
+
+// SaveFraudAlert stores an alert, returning false if the session already raised the
+// rule for the user
+func (ds *DatabaseService) SaveFraudAlert(alert *FraudAlert) (bool, error) {
+	evidence, err := json.Marshal(alert.Evidence)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode evidence: %w", err)
+	}
+	alert.CreatedAt = time.Now().UTC()
+
+	err = ds.db.QueryRow(`
+	INSERT INTO fraud_alerts (rule, domain, user, session_id, description, evidence, cdr_url, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(session_id, rule, user) DO NOTHING
+	RETURNING id`,
+		alert.Rule, alert.Domain, alert.User, alert.SessionID, alert.Description, string(evidence),
+		alert.CDRURL, alert.CreatedAt,
+	).Scan(&alert.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to save fraud alert: %w", err)
+	}
+	return true, nil
+}
+
+// GetFraudAlerts returns the most recent alerts, optionally for one rule, without their evidence
+func (ds *DatabaseService) GetFraudAlerts(rule string, limit int) ([]FraudAlert, error) {
+	query := `
+	SELECT id, rule, domain, user, session_id, description, COALESCE(cdr_url, ''), created_at
+	FROM fraud_alerts`
+	args := []interface{}{}
+	if rule != "" {
+		query += " WHERE rule = ?"
+		args = append(args, rule)
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := ds.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fraud alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []FraudAlert{}
+	for rows.Next() {
+		var alert FraudAlert
+		if err := rows.Scan(&alert.ID, &alert.Rule, &alert.Domain, &alert.User, &alert.SessionID,
+			&alert.Description, &alert.CDRURL, &alert.CreatedAt); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// GetFraudAlert returns an alert with its evidence, or nil if there is none with the ID
+func (ds *DatabaseService) GetFraudAlert(id int64) (*FraudAlert, error) {
+	var alert FraudAlert
+	var evidence string
+	err := ds.db.QueryRow(`
+	SELECT id, rule, domain, user, session_id, description, evidence, COALESCE(cdr_url, ''), created_at
+	FROM fraud_alerts WHERE id = ?`, id,
+	).Scan(&alert.ID, &alert.Rule, &alert.Domain, &alert.User, &alert.SessionID,
+		&alert.Description, &evidence, &alert.CDRURL, &alert.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fraud alert: %w", err)
+	}
+	if err := json.Unmarshal([]byte(evidence), &alert.Evidence); err != nil {
+		return nil, fmt.Errorf("failed to decode evidence: %w", err)
+	}
+	return &alert, nil
+}
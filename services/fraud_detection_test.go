@@ -0,0 +1,138 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestIsInternationalNumber(t *testing.T) {
+	tests := map[string]bool{
+		"011442079460958":   true,
+		"+44 20 7946 0958":  true,
+		"0044207946095":     true,
+		"18765551234":       true, // Jamaica
+		"+1 (876) 555-1234": true,
+		"4155551234":        false,
+		"+14155551234":      false,
+		"1001":              false,
+	}
+	for number, want := range tests {
+		if got := isInternationalNumber(number); got != want {
+			t.Errorf("isInternationalNumber(%q) = %v, want %v", number, got, want)
+		}
+	}
+}
+
+func TestFraudDetectorScan(t *testing.T) {
+	var mu sync.Mutex
+	received := []FraudAlert{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert FraudAlert
+		json.NewDecoder(r.Body).Decode(&alert)
+		mu.Lock()
+		received = append(received, alert)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "fraud.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	// Open 09:00-17:00 UTC on weekdays
+	schedule := &BusinessSchedule{DID: DefaultScheduleDID, Timezone: "UTC"}
+	for weekday := 1; weekday <= 5; weekday++ {
+		schedule.Hours = append(schedule.Hours, BusinessHours{Weekday: weekday, Open: "09:00", Close: "17:00"})
+	}
+	if err := db.SaveBusinessSchedule(schedule); err != nil {
+		t.Fatalf("SaveBusinessSchedule: %v", err)
+	}
+
+	cdrs := []models.FlexibleCDR{}
+	call := func(user, dialed, start string, seconds int) {
+		cdrs = append(cdrs, models.FlexibleCDR{RawData: map[string]interface{}{
+			"id":                          fmt.Sprintf("cdr-%d", len(cdrs)+1),
+			"domain":                      "acme",
+			"call-direction":              0,
+			"call-orig-user":              user,
+			"call-orig-to-user":           dialed,
+			"call-start-datetime":         start,
+			"call-total-duration-seconds": seconds,
+		}})
+	}
+	// 101: six calls abroad overnight on a Tuesday, plus two during the day
+	for i := 0; i < 6; i++ {
+		call("101", "011442079460958", fmt.Sprintf("2026-10-13T02:%02d:00Z", i*8), 300)
+	}
+	call("101", "011442079460958", "2026-10-13T10:00:00Z", 60)
+	call("101", "011442079460958", "2026-10-13T11:00:00Z", 60)
+	// 102: dials through a block of numbers during business hours
+	for _, suffix := range []int{100, 101, 102, 104, 105} {
+		call("102", fmt.Sprintf("1415555%04d", suffix), "2026-10-13T10:00:00Z", 5)
+	}
+	// 103: hours on the phone next to colleagues with a few minutes each
+	call("103", "4155550199", "2026-10-13T10:00:00Z", 4*3600)
+	call("104", "4155550198", "2026-10-13T10:00:00Z", 600)
+	call("105", "4155550197", "2026-10-13T10:00:00Z", 900)
+	// Inbound calls aren't scanned
+	cdrs = append(cdrs, models.FlexibleCDR{RawData: map[string]interface{}{
+		"id": "inbound", "domain": "acme", "call-direction": 1, "call-orig-user": "101",
+		"call-orig-to-user": "011442079460958", "call-start-datetime": "2026-10-13T03:00:00Z",
+	}})
+
+	detector := NewFraudDetector(db, NewScheduleService(db), NewAlertNotifiers(AlertSettings{WebhookURL: server.URL}), FraudSettings{
+		BurstCalls: 5, BurstWindow: time.Hour, SequentialCalls: 5, MinutesFactor: 5, MinMinutes: 120,
+	}, "https://odango.example.com")
+
+	alerts, err := detector.Scan("session-1", cdrs)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	found := map[string]FraudAlert{}
+	for _, alert := range alerts {
+		found[alert.Rule+" "+alert.User] = alert
+	}
+	if len(alerts) != 3 {
+		t.Fatalf("Scan raised %d alerts, want 3: %+v", len(alerts), found)
+	}
+
+	burst, ok := found[FraudRuleInternationalBurst+" 101@acme"]
+	if !ok || burst.Evidence.TotalCalls != 6 || burst.Evidence.TotalMinutes != 30 {
+		t.Errorf("international burst = %+v", burst)
+	}
+	if sequential, ok := found[FraudRuleSequentialDialing+" 102@acme"]; !ok || sequential.Evidence.TotalCalls != 5 {
+		t.Errorf("sequential dialing = %+v", sequential)
+	}
+	if minutes, ok := found[FraudRuleExcessiveMinutes+" 103@acme"]; !ok || minutes.Evidence.BaselineMinutes != 12.5 {
+		t.Errorf("excessive minutes = %+v", minutes)
+	}
+
+	mu.Lock()
+	if len(received) != 3 || received[0].ReportURL == "" {
+		t.Errorf("webhook received %+v", received)
+	}
+	mu.Unlock()
+
+	// Scanning the session again raises nothing new
+	if again, err := detector.Scan("session-1", cdrs); err != nil || len(again) != 0 {
+		t.Fatalf("rescan = %+v, %v", again, err)
+	}
+
+	stored, err := db.GetFraudAlert(burst.ID)
+	if err != nil || stored == nil || len(stored.Evidence.Calls) != 6 || stored.Evidence.Calls[0].CDRID != "cdr-1" {
+		t.Fatalf("GetFraudAlert = %+v, %v", stored, err)
+	}
+	if list, err := db.GetFraudAlerts(FraudRuleSequentialDialing, 10); err != nil || len(list) != 1 {
+		t.Fatalf("GetFraudAlerts = %+v, %v", list, err)
+	}
+}
@@ -0,0 +1,168 @@
+// services/geo_cache.go
+// Per-location cache for upstream weather and air quality lookups
+
+package services
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultGeoCachePrecision rounds coordinates to 2 decimal places (about 1km)
+const DefaultGeoCachePrecision = 2
+
+// GeoCacheStats reports how well a location cache is sparing its upstream provider
+type GeoCacheStats struct {
+	Name        string  `json:"name"`
+	Entries     int     `json:"entries"`
+	TTL         string  `json:"ttl"`
+	Precision   int     `json:"precision"` // decimal places coordinates are rounded to
+	Hits        uint64  `json:"hits"`
+	Misses      uint64  `json:"misses"`    // upstream lookups
+	Coalesced   uint64  `json:"coalesced"` // callers that waited on another caller's lookup
+	StaleServed uint64  `json:"stale_served"`
+	Errors      uint64  `json:"errors"`
+	HitRate     float64 `json:"hit_rate"` // hits and coalesced lookups as a fraction of all lookups
+}
+
+// geoCaches lists every location cache for admin introspection
+var (
+	geoCachesMu sync.Mutex
+	geoCaches   []interface{ Stats() GeoCacheStats }
+)
+
+// GeoCacheStatsAll returns the stats of every location cache, by name
+func GeoCacheStatsAll() []GeoCacheStats {
+	geoCachesMu.Lock()
+	defer geoCachesMu.Unlock()
+
+	stats := make([]GeoCacheStats, 0, len(geoCaches))
+	for _, cache := range geoCaches {
+		stats = append(stats, cache.Stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// geoCache caches values per rounded lat/lon. Concurrent misses for the same location share one
+// upstream lookup, so a burst of calls from one metro costs a single request.
+type geoCache[T any] struct {
+	name      string
+	ttl       time.Duration
+	precision int
+
+	mu       sync.Mutex
+	entries  map[string]geoCacheEntry[T]
+	inflight map[string]*geoLookup[T]
+
+	hits, misses, coalesced, staleServed, errors atomic.Uint64
+}
+
+type geoCacheEntry[T any] struct {
+	value     T
+	fetchedAt time.Time
+}
+
+// geoLookup is an upstream lookup other callers can wait on
+type geoLookup[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+func newGeoCache[T any](name string, ttl time.Duration, precision int) *geoCache[T] {
+	if precision < 0 {
+		precision = DefaultGeoCachePrecision
+	}
+	cache := &geoCache[T]{
+		name:      name,
+		ttl:       ttl,
+		precision: precision,
+		entries:   make(map[string]geoCacheEntry[T]),
+		inflight:  make(map[string]*geoLookup[T]),
+	}
+
+	geoCachesMu.Lock()
+	geoCaches = append(geoCaches, cache)
+	geoCachesMu.Unlock()
+
+	return cache
+}
+
+// Get returns a cached value for the location or calls fetch. If fetch fails,
+// a stale value is preferred over no value at all.
+func (gc *geoCache[T]) Get(lat, lon float64, fetch func() (T, error)) (T, error) {
+	key := fmt.Sprintf("%.*f,%.*f", gc.precision, lat, gc.precision, lon)
+
+	gc.mu.Lock()
+	cached, exists := gc.entries[key]
+	if exists && time.Since(cached.fetchedAt) < gc.ttl {
+		gc.mu.Unlock()
+		gc.hits.Add(1)
+		return cached.value, nil
+	}
+
+	lookup, waiting := gc.inflight[key]
+	if !waiting {
+		lookup = &geoLookup[T]{done: make(chan struct{})}
+		gc.inflight[key] = lookup
+	}
+	gc.mu.Unlock()
+
+	if waiting {
+		gc.coalesced.Add(1)
+		<-lookup.done
+	} else {
+		gc.misses.Add(1)
+		lookup.value, lookup.err = fetch()
+
+		gc.mu.Lock()
+		if lookup.err == nil {
+			gc.entries[key] = geoCacheEntry[T]{value: lookup.value, fetchedAt: time.Now()}
+		}
+		delete(gc.inflight, key)
+		gc.mu.Unlock()
+		close(lookup.done)
+	}
+
+	if lookup.err != nil {
+		if !waiting {
+			gc.errors.Add(1)
+		}
+		if exists {
+			gc.staleServed.Add(1)
+			log.Printf("[Cache] %s lookup failed for %s, using cached value: %v", gc.name, key, lookup.err)
+			return cached.value, nil
+		}
+		var zero T
+		return zero, lookup.err
+	}
+	return lookup.value, nil
+}
+
+// Stats returns the cache's counters
+func (gc *geoCache[T]) Stats() GeoCacheStats {
+	gc.mu.Lock()
+	entries := len(gc.entries)
+	gc.mu.Unlock()
+
+	stats := GeoCacheStats{
+		Name:        gc.name,
+		Entries:     entries,
+		TTL:         gc.ttl.String(),
+		Precision:   gc.precision,
+		Hits:        gc.hits.Load(),
+		Misses:      gc.misses.Load(),
+		Coalesced:   gc.coalesced.Load(),
+		StaleServed: gc.staleServed.Load(),
+		Errors:      gc.errors.Load(),
+	}
+	if total := stats.Hits + stats.Misses + stats.Coalesced; total > 0 {
+		stats.HitRate = float64(stats.Hits+stats.Coalesced) / float64(total)
+	}
+	return stats
+}
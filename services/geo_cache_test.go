@@ -0,0 +1,88 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGeoCacheRoundsLocations(t *testing.T) {
+	cache := newGeoCache[int]("test:rounding", time.Minute, 2)
+
+	var fetches int
+	fetch := func() (int, error) {
+		fetches++
+		return fetches, nil
+	}
+
+	cache.Get(40.7128, -74.0060, fetch)
+	if value, _ := cache.Get(40.7131, -74.0058, fetch); value != 1 {
+		t.Errorf("nearby location got value %d, want cached 1", value)
+	}
+	if value, _ := cache.Get(40.75, -74.0060, fetch); value != 2 {
+		t.Errorf("distant location got value %d, want fresh 2", value)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Entries != 2 {
+		t.Errorf("stats = %+v, want 1 hit, 2 misses, 2 entries", stats)
+	}
+}
+
+func TestGeoCacheCoalescesConcurrentMisses(t *testing.T) {
+	cache := newGeoCache[int]("test:coalesce", time.Minute, 2)
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	fetch := func() (int, error) {
+		fetches.Add(1)
+		<-release
+		return 72, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := cache.Get(33.45, -112.07, fetch); err != nil || value != 72 {
+				t.Errorf("Get = %d, %v; want 72", value, err)
+			}
+		}()
+	}
+
+	// Let every caller reach the cache before the lookup finishes
+	for cache.Stats().Coalesced < 9 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("upstream called %d times, want 1", n)
+	}
+}
+
+func TestGeoCacheServesStaleOnError(t *testing.T) {
+	cache := newGeoCache[int]("test:stale", time.Millisecond, 2)
+
+	cache.Get(41.88, -87.63, func() (int, error) { return 55, nil })
+	time.Sleep(2 * time.Millisecond)
+
+	value, err := cache.Get(41.88, -87.63, func() (int, error) { return 0, errors.New("upstream down") })
+	if err != nil || value != 55 {
+		t.Errorf("Get = %d, %v; want stale 55", value, err)
+	}
+
+	_, err = cache.Get(0, 0, func() (int, error) { return 0, errors.New("upstream down") })
+	if err == nil {
+		t.Error("expected error for uncached location")
+	}
+
+	stats := cache.Stats()
+	if stats.StaleServed != 1 || stats.Errors != 2 {
+		t.Errorf("stats = %+v, want 1 stale served, 2 errors", stats)
+	}
+}
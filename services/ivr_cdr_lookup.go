@@ -0,0 +1,306 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"o-dan-go/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// cdrLookupDays is how far back the IVR searches for a caller's calls
+const cdrLookupDays = 7
+
+// CDRLookupApp is the CDR lookup IVR: it reads back the most recent call for
+// the caller's own number (matched by ANI) or a number they enter
+type CDRLookupApp struct {
+	cdrService *CDRDiscoveryService
+	sms        *SMSFollowUp
+	prompts    *Prompts
+}
+
+// NewCDRLookupApp creates the CDR lookup IVR app
+func NewCDRLookupApp(cdrService *CDRDiscoveryService, sms *SMSFollowUp, prompts *Prompts) *CDRLookupApp {
+	return &CDRLookupApp{
+		cdrService: cdrService,
+		sms:        sms,
+		prompts:    prompts,
+	}
+}
+
+// Name returns the route the app is mounted under (/wr/cdr-lookup)
+func (ca *CDRLookupApp) Name() string {
+	return "cdr-lookup"
+}
+
+// NormalizePhoneNumber reduces a phone number to 10 digits, or "" if it isn't a NANP number
+func NormalizePhoneNumber(phoneNumber string) string {
+	cleaned := nonDigits.ReplaceAllString(phoneNumber, "")
+	if len(cleaned) == 11 && strings.HasPrefix(cleaned, "1") {
+		cleaned = cleaned[1:]
+	}
+	if len(cleaned) != 10 {
+		return ""
+	}
+	return cleaned
+}
+
+// Handle walks the caller through choosing a number, then reads back its latest call.
+// Session key cdr_stage is "menu" while choosing ANI vs. entry, "number" while entering digits.
+func (ca *CDRLookupApp) Handle(session *sessions.Session, params IVRParams) (Response, error) {
+	stage, _ := session.Values["cdr_stage"].(string)
+	ani := NormalizePhoneNumber(params.CallerNumber)
+
+	// Answer to "press 9 to receive this by text"
+	if params.Digits != "" && ca.sms.Pending(session) {
+		actions := ca.sms.Respond(session, params, "ivr:"+ca.Name())
+		ca.sendEvent(session, params, "call_ended", "Call completed successfully")
+		return Response{Actions: append(actions, ca.prompts.TimedPrompt("common.goodbye", params, nil), Hangup{})}, nil
+	}
+
+	// First call - no digits pressed
+	if params.Digits == "" || stage == "" {
+		log.Printf("[WR] New CDR lookup call from: %s", params.CallerNumber)
+		delete(session.Values, "sms_text")
+
+		if params.CallID != "" {
+			session.Values["session_id"] = params.SessionID
+			session.Values["call_id"] = params.CallID
+		} else {
+			session.Values["session_id"] = fmt.Sprintf("cdr_lookup_%d", time.Now().Unix())
+			session.Values["call_id"] = NewCallID(params.Values)
+			ca.sendEvent(session, params, "call_started", "New incoming call")
+		}
+
+		if ani == "" {
+			session.Values["cdr_stage"] = "number"
+			return ca.prompts.Greet(params, ca.numberPrompt("cdr.welcome")), nil
+		}
+
+		session.Values["cdr_stage"] = "menu"
+		return ca.prompts.Greet(params, ca.menuPrompt("cdr.welcome")), nil
+	}
+
+	log.Printf("[WR] DTMF received: %s", params.Digits)
+	ca.sendEvent(session, params, "dtmf_received", fmt.Sprintf("Pressed %s", params.Digits))
+
+	var number string
+	switch stage {
+	case "menu":
+		switch params.Digits {
+		case "1":
+			number = ani
+		case "2":
+			session.Values["cdr_stage"] = "number"
+			return ca.numberPrompt(""), nil
+		default:
+			ca.sendEvent(session, params, "invalid_selection", fmt.Sprintf("Invalid digit: %s", params.Digits))
+			return ca.menuPrompt("common.invalid"), nil
+		}
+
+	case "number":
+		number = NormalizePhoneNumber(params.Digits)
+		if number == "" {
+			ca.sendEvent(session, params, "invalid_selection", fmt.Sprintf("Invalid number: %s", params.Digits))
+			return ca.numberPrompt("cdr.invalid_number"), nil
+		}
+	}
+
+	delete(session.Values, "cdr_stage")
+	return ca.lookup(session, params, number), nil
+}
+
+// lookup queries recent CDRs for number and builds the read-back response
+func (ca *CDRLookupApp) lookup(session *sessions.Session, params IVRParams, number string) Response {
+	cdrs, err := ca.RecentCalls(number)
+	if err != nil {
+		log.Printf("[WR] CDR lookup failed for %s: %v", number, err)
+		ca.sendEvent(session, params, "error", fmt.Sprintf("CDR lookup failed: %v", err))
+		return ca.finalResponse(session, params, ca.prompts.Text("cdr.unavailable", nil), false)
+	}
+
+	vars := map[string]string{
+		"number": SpeakDigits(number),
+		"days":   strconv.Itoa(cdrLookupDays),
+		"count":  strconv.Itoa(len(cdrs)),
+		"calls":  "calls",
+	}
+	if len(cdrs) == 0 {
+		ca.sendEvent(session, params, "response_sent", fmt.Sprintf("No calls for %s", number))
+		return ca.finalResponse(session, params, ca.prompts.Text("cdr.no_calls", vars), false)
+	}
+	if len(cdrs) == 1 {
+		vars["calls"] = "call"
+	}
+
+	latest := cdrs[0]
+	vars["duration"] = SpeakDuration(latest.GetCallDuration())
+	startTime, err := latest.GetCallStartTime()
+	if err != nil {
+		ca.sendEvent(session, params, "response_sent", fmt.Sprintf("%d calls for %s", len(cdrs), number))
+		return ca.finalResponse(session, params, ca.prompts.Text("cdr.summary_no_time", vars), true)
+	}
+	if location, exists := GlobalAreaCodes.Lookup(number[:3]); exists {
+		if loc, err := time.LoadLocation(location.Timezone); err == nil {
+			startTime = startTime.In(loc)
+		}
+	}
+
+	vars["date"] = startTime.Format("Monday, January 2")
+	vars["time"] = startTime.Format("3:04 PM")
+	text := ca.prompts.Text("cdr.summary", vars)
+
+	ca.sendEvent(session, params, "response_sent", fmt.Sprintf("%d calls for %s, latest %s", len(cdrs), number, startTime.Format(time.RFC3339)))
+	return ca.finalResponse(session, params, text, true)
+}
+
+// RecentCalls returns calls to or from number in the lookback window, newest first
+func (ca *CDRLookupApp) RecentCalls(number string) ([]models.FlexibleCDR, error) {
+	since := time.Now().AddDate(0, 0, -cdrLookupDays)
+
+	var all []models.FlexibleCDR
+	var errors []string
+	for _, criteria := range []CDRSearchCriteria{
+		{OriginatingNumber: number, StartDate: &since, Limit: 25},
+		{TerminatingNumber: number, StartDate: &since, Limit: 25},
+	} {
+		result, err := ca.cdrService.GetComprehensiveCDRs(criteria)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, result.AllCDRs...)
+		errors = append(errors, result.Errors...)
+	}
+
+	if len(all) == 0 && len(errors) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errors, "; "))
+	}
+
+	// Each direction was deduplicated separately; a call can appear in both
+	seen := make(map[string]bool)
+	var recent []models.FlexibleCDR
+	for _, cdr := range all {
+		if seen[cdr.GetID()] {
+			continue
+		}
+		seen[cdr.GetID()] = true
+
+		if startTime, err := cdr.GetCallStartTime(); err == nil && startTime.Before(since) {
+			continue
+		}
+		recent = append(recent, cdr)
+	}
+
+	sort.SliceStable(recent, func(i, j int) bool {
+		ti, _ := recent[i].GetCallStartTime()
+		tj, _ := recent[j].GetCallStartTime()
+		return ti.After(tj)
+	})
+
+	return recent, nil
+}
+
+// SpeakDigits spaces out a number so TTS reads it digit by digit
+func SpeakDigits(number string) string {
+	return strings.Join(strings.Split(number, ""), " ")
+}
+
+// SpeakDuration formats seconds as "2 minutes and 5 seconds"
+func SpeakDuration(seconds int) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+
+	minutes := seconds / 60
+	seconds = seconds % 60
+	switch {
+	case minutes == 0:
+		return plural(seconds, "second")
+	case seconds == 0:
+		return plural(minutes, "minute")
+	default:
+		return plural(minutes, "minute") + " and " + plural(seconds, "second")
+	}
+}
+
+func (ca *CDRLookupApp) menuPrompt(introKey string) Response {
+	actions := []interface{}{}
+	if introKey != "" {
+		actions = append(actions, ca.prompts.Prompt(introKey, nil))
+	}
+
+	actions = append(actions,
+		Gather{
+			NumDigits: "1",
+			Action:    "/wr/cdr-lookup",
+			Timeout:   "10",
+			Actions: []interface{}{
+				ca.prompts.Prompt("cdr.menu", nil),
+			},
+			SpeechChoices: map[string][]string{
+				"1": {"my calls", "my number", "this number", "mine"},
+				"2": {"different number", "another number", "other number", "different", "another"},
+			},
+		},
+		ca.prompts.Prompt("common.no_input", nil),
+		Hangup{},
+	)
+
+	return Response{Actions: actions}
+}
+
+func (ca *CDRLookupApp) numberPrompt(introKey string) Response {
+	actions := []interface{}{}
+	if introKey != "" {
+		actions = append(actions, ca.prompts.Prompt(introKey, nil))
+	}
+
+	actions = append(actions,
+		Gather{
+			NumDigits: "10",
+			Action:    "/wr/cdr-lookup",
+			Timeout:   "15",
+			Actions: []interface{}{
+				ca.prompts.Prompt("cdr.enter_number", nil),
+			},
+		},
+		ca.prompts.Prompt("cdr.no_number", nil),
+		Hangup{},
+	)
+
+	return Response{Actions: actions}
+}
+
+// finalResponse reads back text, offering it by text message when offerSMS is set and possible
+func (ca *CDRLookupApp) finalResponse(session *sessions.Session, params IVRParams, text string, offerSMS bool) Response {
+	log.Printf("[WR] Sending response: %s", text)
+
+	actions := []interface{}{ca.prompts.SayText(text)}
+	if offerSMS && ca.sms.Available(params.CallerNumber) {
+		actions = append(actions, ca.sms.Offer(session, "/wr/"+ca.Name(), text))
+	} else {
+		ca.sendEvent(session, params, "call_ended", "Call completed successfully")
+	}
+
+	return Response{
+		Actions: append(actions,
+			Wait{Timeout: "1"},
+			ca.prompts.TimedPrompt("common.goodbye", params, nil),
+			Hangup{},
+		),
+	}
+}
+
+func (ca *CDRLookupApp) sendEvent(session *sessions.Session, params IVRParams, eventType, details string) {
+	sessionID, _ := session.Values["session_id"].(string)
+	callID, _ := session.Values["call_id"].(string)
+
+	sendCallEvent(ca.Name(), sessionID, callID, params.CallerNumber, eventType, details)
+}
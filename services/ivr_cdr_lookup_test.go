@@ -0,0 +1,33 @@
+package services
+
+import "testing"
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	cases := map[string]string{
+		"+1 (415) 555-1234": "4155551234",
+		"4155551234":        "4155551234",
+		"555-1234":          "",
+		"":                  "",
+	}
+
+	for input, want := range cases {
+		if got := NormalizePhoneNumber(input); got != want {
+			t.Errorf("NormalizePhoneNumber(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestSpeakDuration(t *testing.T) {
+	cases := map[int]string{
+		0:   "0 seconds",
+		1:   "1 second",
+		60:  "1 minute",
+		125: "2 minutes and 5 seconds",
+	}
+
+	for seconds, want := range cases {
+		if got := SpeakDuration(seconds); got != want {
+			t.Errorf("SpeakDuration(%d) = %q, want %q", seconds, got, want)
+		}
+	}
+}
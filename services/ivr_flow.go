@@ -0,0 +1,465 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"o-dan-go/events"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"gopkg.in/yaml.v3"
+)
+
+// maxFlowSteps guards against next: loops that never reach a gather or hangup
+const maxFlowSteps = 25
+
+// ErrFlowCheckFailed is returned by check actions (e.g. check_hours) whose condition
+// doesn't hold. The flow follows on_error without reporting an error to the dashboard.
+var ErrFlowCheckFailed = errors.New("flow check failed")
+
+// FlowDefinition is a declarative IVR menu tree loaded from YAML or JSON
+type FlowDefinition struct {
+	Name     string               `yaml:"name" json:"name"`
+	Start    string               `yaml:"start" json:"start"`
+	Voice    string               `yaml:"voice" json:"voice,omitempty"`
+	Language string               `yaml:"language" json:"language,omitempty"`
+	Nodes    map[string]*FlowNode `yaml:"nodes" json:"nodes"`
+	Source   string               `yaml:"-" json:"source,omitempty"`
+}
+
+// FlowNode is one step of a flow. A node runs its action, speaks its prompt, then
+// either gathers digits, hangs up, transfers the caller, or continues to the next node.
+type FlowNode struct {
+	Action   string            `yaml:"action" json:"action,omitempty"`     // built-in action run before speaking
+	OnError  string            `yaml:"on_error" json:"on_error,omitempty"` // node to go to if the action fails
+	Pause    int               `yaml:"pause" json:"pause,omitempty"`       // seconds to wait before speaking
+	Play     string            `yaml:"play" json:"play,omitempty"`         // recording under /static/audio (or URL), played before say
+	Say      string            `yaml:"say" json:"say,omitempty"`           // prompt template, e.g. "It is {{.local_time}}"
+	Event    string            `yaml:"event" json:"event,omitempty"`       // dashboard response_sent detail template
+	Set      map[string]string `yaml:"set" json:"set,omitempty"`           // variables set from templates after the action
+	Gather   *FlowGather       `yaml:"gather" json:"gather,omitempty"`
+	Next     string            `yaml:"next" json:"next,omitempty"`
+	Hangup   bool              `yaml:"hangup" json:"hangup,omitempty"`
+	Transfer string            `yaml:"transfer" json:"transfer,omitempty"` // handoff target template, e.g. "queue:support" or "{{.on_call}}"
+}
+
+// FlowGather collects DTMF digits (or speech mapped to digits) and branches on them
+type FlowGather struct {
+	NumDigits int                 `yaml:"num_digits" json:"num_digits"`
+	Timeout   int                 `yaml:"timeout" json:"timeout"`
+	Prompt    string              `yaml:"prompt" json:"prompt"`
+	Branches  map[string]string   `yaml:"branches" json:"branches"`
+	Speech    map[string][]string `yaml:"speech" json:"speech,omitempty"`     // words a caller can say for each branch's digits
+	Invalid   string              `yaml:"invalid" json:"invalid,omitempty"`   // node for digits with no branch
+	Input     string              `yaml:"input" json:"input,omitempty"`       // node for free-form entry (e.g. a ZIP code) with no branch
+	NoInput   string              `yaml:"no_input" json:"no_input,omitempty"` // spoken before hanging up on timeout
+}
+
+// FlowContext is the state an action can read and update during a call
+type FlowContext struct {
+	Session *sessions.Session
+	Params  IVRParams
+	Vars    map[string]string
+}
+
+// FlowAction is a built-in step a flow can reference by name
+type FlowAction func(ctx *FlowContext) error
+
+// FlowApp runs a FlowDefinition as an IVR app
+type FlowApp struct {
+	definition *FlowDefinition
+	actions    map[string]FlowAction
+	prompts    *Prompts
+	templates  map[string]*template.Template
+}
+
+// LoadFlowDefinition parses a flow file (.yaml, .yml or .json)
+func LoadFlowDefinition(path string) (*FlowDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flow %s: %w", path, err)
+	}
+
+	var definition FlowDefinition
+	// JSON is a subset of YAML, so one decoder handles both formats
+	if err := yaml.Unmarshal(data, &definition); err != nil {
+		return nil, fmt.Errorf("failed to parse flow %s: %w", path, err)
+	}
+	definition.Source = path
+
+	if definition.Name == "" {
+		definition.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return &definition, nil
+}
+
+// LoadFlowApps loads every flow file in dir and builds an app for each.
+// A missing directory is not an error; an invalid flow is.
+func LoadFlowApps(dir string, actions map[string]FlowAction, prompts *Prompts) ([]*FlowApp, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read flows directory: %w", err)
+	}
+
+	var apps []*FlowApp
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		definition, err := LoadFlowDefinition(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		app, err := NewFlowApp(definition, actions, prompts)
+		if err != nil {
+			return nil, err
+		}
+		apps = append(apps, app)
+	}
+
+	return apps, nil
+}
+
+// NewFlowApp validates a definition and compiles its prompt templates.
+// The flow's voice and language, when set, override the deployment defaults in prompts.
+func NewFlowApp(definition *FlowDefinition, actions map[string]FlowAction, prompts *Prompts) (*FlowApp, error) {
+	if err := definition.Validate(actions); err != nil {
+		return nil, err
+	}
+
+	app := &FlowApp{
+		definition: definition,
+		actions:    actions,
+		prompts:    prompts,
+		templates:  make(map[string]*template.Template),
+	}
+
+	for nodeID, node := range definition.Nodes {
+		texts := map[string]string{"say": node.Say, "event": node.Event, "transfer": node.Transfer}
+		for name, text := range node.Set {
+			texts["set."+name] = text
+		}
+		if node.Gather != nil {
+			texts["prompt"] = node.Gather.Prompt
+			texts["no_input"] = node.Gather.NoInput
+		}
+		for field, text := range texts {
+			if text == "" {
+				continue
+			}
+			key := nodeID + "." + field
+			tmpl, err := template.New(key).Option("missingkey=zero").Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("flow %s: node %s: invalid %s template: %w", definition.Name, nodeID, field, err)
+			}
+			app.templates[key] = tmpl
+		}
+	}
+
+	return app, nil
+}
+
+// Validate checks that the start node, every referenced node and every action exist
+func (fd *FlowDefinition) Validate(actions map[string]FlowAction) error {
+	if fd.Name == "" {
+		return fmt.Errorf("flow name is required")
+	}
+	if len(fd.Nodes) == 0 {
+		return fmt.Errorf("flow %s has no nodes", fd.Name)
+	}
+	if _, exists := fd.Nodes[fd.Start]; !exists {
+		return fmt.Errorf("flow %s: start node %q does not exist", fd.Name, fd.Start)
+	}
+
+	nodeIDs := make([]string, 0, len(fd.Nodes))
+	for nodeID := range fd.Nodes {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Strings(nodeIDs)
+
+	for _, nodeID := range nodeIDs {
+		node := fd.Nodes[nodeID]
+		if node == nil {
+			return fmt.Errorf("flow %s: node %s is empty", fd.Name, nodeID)
+		}
+
+		if node.Action != "" {
+			if _, exists := actions[node.Action]; !exists {
+				return fmt.Errorf("flow %s: node %s: unknown action %q", fd.Name, nodeID, node.Action)
+			}
+		}
+
+		targets := map[string]string{"next": node.Next, "on_error": node.OnError}
+		if node.Gather != nil {
+			if len(node.Gather.Branches) == 0 && node.Gather.Input == "" {
+				return fmt.Errorf("flow %s: node %s: gather has no branches or input", fd.Name, nodeID)
+			}
+			for digits, target := range node.Gather.Branches {
+				targets["branch "+digits] = target
+			}
+			for digits := range node.Gather.Speech {
+				if _, exists := node.Gather.Branches[digits]; !exists {
+					return fmt.Errorf("flow %s: node %s: speech choices for %q have no branch", fd.Name, nodeID, digits)
+				}
+			}
+			targets["invalid"] = node.Gather.Invalid
+			targets["input"] = node.Gather.Input
+		}
+		for field, target := range targets {
+			if target == "" {
+				continue
+			}
+			if _, exists := fd.Nodes[target]; !exists {
+				return fmt.Errorf("flow %s: node %s: %s target %q does not exist", fd.Name, nodeID, field, target)
+			}
+		}
+
+		// Templated targets are parsed when the call reaches the node
+		if node.Transfer != "" && !strings.Contains(node.Transfer, "{{") {
+			if _, err := ParseHandoffTarget(node.Transfer); err != nil {
+				return fmt.Errorf("flow %s: node %s: %w", fd.Name, nodeID, err)
+			}
+		}
+
+		if node.Gather == nil && node.Next == "" && !node.Hangup && node.Transfer == "" {
+			return fmt.Errorf("flow %s: node %s must gather, hang up, transfer, or set next", fd.Name, nodeID)
+		}
+	}
+
+	return nil
+}
+
+// Name returns the route the flow is mounted under (/wr/<name>)
+func (fa *FlowApp) Name() string {
+	return fa.definition.Name
+}
+
+// Definition returns the flow definition the app runs
+func (fa *FlowApp) Definition() *FlowDefinition {
+	return fa.definition
+}
+
+// Handle advances the flow by one request: from the start node on a new call,
+// or from the branch matching the digits gathered by the previous response
+func (fa *FlowApp) Handle(session *sessions.Session, params IVRParams) (Response, error) {
+	ctx := &FlowContext{
+		Session: session,
+		Params:  params,
+		Vars:    make(map[string]string),
+	}
+
+	pendingNode, _ := session.Values["flow_node"].(string)
+	if varsJSON, ok := session.Values["flow_vars"].(string); ok && pendingNode != "" {
+		json.Unmarshal([]byte(varsJSON), &ctx.Vars)
+	}
+
+	var nodeID string
+	newCall := params.Digits == "" || fa.definition.Nodes[pendingNode] == nil || fa.definition.Nodes[pendingNode].Gather == nil
+	if newCall {
+		log.Printf("[WR] New %s call from: %s", fa.definition.Name, params.CallerNumber)
+		ctx.Vars = map[string]string{
+			"caller":      params.CallerNumber,
+			"session_id":  fmt.Sprintf("%s_%d", fa.definition.Name, time.Now().Unix()),
+			"call_id":     NewCallID(params.Values),
+			"time_of_day": params.TimeOfDay(),
+			"goodbye":     fa.prompts.Text(fa.prompts.TimedKey("common.goodbye", params), nil),
+		}
+		if params.CallID != "" {
+			ctx.Vars["session_id"] = params.SessionID
+			ctx.Vars["call_id"] = params.CallID
+		} else {
+			// Only the first app of a call greets the caller
+			ctx.Vars["greeting"] = fa.prompts.Text(fa.prompts.TimedKey("greeting", params), nil)
+		}
+		nodeID = fa.definition.Start
+	} else {
+		log.Printf("[WR] DTMF received: %s", params.Digits)
+		ctx.Vars["digits"] = params.Digits
+		fa.sendEvent(ctx, "dtmf_received", fmt.Sprintf("Pressed %s", params.Digits))
+
+		gather := fa.definition.Nodes[pendingNode].Gather
+		target, matched := gather.Branches[params.Digits]
+		if !matched && gather.Input != "" {
+			target, matched = gather.Input, true
+		}
+		if !matched {
+			fa.sendEvent(ctx, "invalid_selection", fmt.Sprintf("Invalid digit: %s", params.Digits))
+			target = gather.Invalid
+			if target == "" {
+				target = pendingNode
+			}
+		}
+		nodeID = target
+	}
+
+	response := Response{}
+	for step := 0; ; step++ {
+		if step >= maxFlowSteps {
+			return Response{}, fmt.Errorf("flow %s exceeded %d steps without waiting for input", fa.definition.Name, maxFlowSteps)
+		}
+
+		node := fa.definition.Nodes[nodeID]
+
+		if node.Action != "" {
+			if err := fa.actions[node.Action](ctx); err != nil {
+				if !errors.Is(err, ErrFlowCheckFailed) || node.OnError == "" {
+					log.Printf("[WR] Flow %s action %s failed: %v", fa.definition.Name, node.Action, err)
+					fa.sendEvent(ctx, "error", err.Error())
+				}
+				if node.OnError == "" {
+					return Response{}, fmt.Errorf("flow %s: action %s failed: %w", fa.definition.Name, node.Action, err)
+				}
+				nodeID = node.OnError
+				continue
+			}
+		}
+
+		// Announced after the first action so its variables (e.g. area_code) are on the event
+		if newCall && params.CallID == "" {
+			fa.sendEvent(ctx, "call_started", "New incoming call")
+			newCall = false
+		}
+
+		if node.Pause > 0 {
+			response.Actions = append(response.Actions, Wait{Timeout: strconv.Itoa(node.Pause)})
+		}
+		for name := range node.Set {
+			ctx.Vars[name] = fa.render(nodeID, "set."+name, ctx.Vars)
+		}
+
+		if node.Play != "" {
+			response.Actions = append(response.Actions, fa.prompts.Play(node.Play))
+		}
+		if text := fa.render(nodeID, "say", ctx.Vars); text != "" {
+			response.Actions = append(response.Actions, fa.say(text))
+		}
+		if detail := fa.render(nodeID, "event", ctx.Vars); detail != "" {
+			fa.sendEvent(ctx, "response_sent", detail)
+		}
+
+		if node.Gather != nil {
+			response.Actions = append(response.Actions, fa.gather(nodeID, node.Gather, ctx.Vars))
+			if text := fa.render(nodeID, "no_input", ctx.Vars); text != "" {
+				response.Actions = append(response.Actions, fa.say(text))
+			}
+			response.Actions = append(response.Actions, Hangup{})
+
+			varsJSON, _ := json.Marshal(ctx.Vars)
+			session.Values["flow_node"] = nodeID
+			session.Values["flow_vars"] = string(varsJSON)
+			return response, nil
+		}
+
+		if node.Transfer != "" {
+			target, err := ParseHandoffTarget(fa.render(nodeID, "transfer", ctx.Vars))
+			if err != nil {
+				log.Printf("[WR] Flow %s node %s: invalid transfer target: %v", fa.definition.Name, nodeID, err)
+				fa.sendEvent(ctx, "error", fmt.Sprintf("Transfer failed: %v", err))
+				response.Actions = append(response.Actions, fa.say(fa.prompts.Text("common.unavailable", nil)), Hangup{})
+			} else {
+				response.Actions = append(response.Actions, target.Element(), fa.say(fa.prompts.Text("common.no_answer", nil)), Hangup{})
+				fa.sendEvent(ctx, "call_transferred", fmt.Sprintf("Transferred to %s", target))
+			}
+			delete(session.Values, "flow_node")
+			delete(session.Values, "flow_vars")
+			return response, nil
+		}
+
+		if node.Hangup {
+			response.Actions = append(response.Actions, Hangup{})
+			fa.sendEvent(ctx, "call_ended", "Call completed successfully")
+			delete(session.Values, "flow_node")
+			delete(session.Values, "flow_vars")
+			return response, nil
+		}
+
+		nodeID = node.Next
+	}
+}
+
+func (fa *FlowApp) gather(nodeID string, gather *FlowGather, vars map[string]string) Gather {
+	numDigits := gather.NumDigits
+	if numDigits <= 0 {
+		numDigits = 1
+	}
+	timeout := gather.Timeout
+	if timeout <= 0 {
+		timeout = 10
+	}
+
+	element := Gather{
+		NumDigits: strconv.Itoa(numDigits),
+		Action:    "/wr/" + fa.definition.Name,
+		Timeout:   strconv.Itoa(timeout),
+
+		SpeechChoices: gather.Speech,
+	}
+	if text := fa.render(nodeID, "prompt", vars); text != "" {
+		element.Actions = append(element.Actions, fa.say(text))
+	}
+	return element
+}
+
+func (fa *FlowApp) say(text string) Say {
+	say := fa.prompts.SayText(text)
+	if fa.definition.Voice != "" {
+		say.Voice = fa.definition.Voice
+	}
+	if fa.definition.Language != "" {
+		say.Language = fa.definition.Language
+	}
+	return say
+}
+
+// render executes one of a node's templates against the call variables
+func (fa *FlowApp) render(nodeID, field string, vars map[string]string) string {
+	tmpl, exists := fa.templates[nodeID+"."+field]
+	if !exists {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		log.Printf("[WR] Flow %s node %s: failed to render %s: %v", fa.definition.Name, nodeID, field, err)
+		return ""
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+func (fa *FlowApp) sendEvent(ctx *FlowContext, eventType, details string) {
+	areaCode := ctx.Vars["area_code"]
+	if areaCode == "" {
+		areaCode = "Unknown"
+	}
+	location := ctx.Vars["location"]
+	if location == "" {
+		location = "Unknown"
+	}
+
+	events.SendEvent(events.CallEvent{
+		App:       fa.definition.Name,
+		SessionID: ctx.Vars["session_id"],
+		CallID:    ctx.Vars["call_id"],
+		CallerNum: ctx.Params.CallerNumber,
+		AreaCode:  areaCode,
+		Location:  location,
+		EventType: eventType,
+		Details:   details,
+		Timestamp: time.Now(),
+	})
+}
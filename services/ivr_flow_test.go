@@ -0,0 +1,90 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gorilla/sessions"
+)
+
+func TestFlowValidateRejectsMissingTarget(t *testing.T) {
+	definition := &FlowDefinition{
+		Name:  "broken",
+		Start: "menu",
+		Nodes: map[string]*FlowNode{
+			"menu": {Gather: &FlowGather{Branches: map[string]string{"1": "nowhere"}}},
+		},
+	}
+
+	err := definition.Validate(nil)
+	if err == nil || !strings.Contains(err.Error(), "nowhere") {
+		t.Fatalf("expected missing target error, got %v", err)
+	}
+}
+
+func TestFlowAppBranchesOnDigits(t *testing.T) {
+	definition := &FlowDefinition{
+		Name:  "menu",
+		Start: "main",
+		Nodes: map[string]*FlowNode{
+			"main":  {Gather: &FlowGather{Prompt: "Press 1 for sales", Branches: map[string]string{"1": "sales"}}},
+			"sales": {Say: "Connecting {{.caller}} to sales", Hangup: true},
+		},
+	}
+
+	app, err := NewFlowApp(definition, nil, NewPrompts())
+	if err != nil {
+		t.Fatalf("NewFlowApp: %v", err)
+	}
+
+	session := sessions.NewSession(nil, "menu-ivr-session")
+	first, err := app.Handle(session, IVRParams{CallerNumber: "4155551234"})
+	if err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	if _, ok := first.Actions[0].(Gather); !ok {
+		t.Fatalf("expected gather first, got %#v", first.Actions[0])
+	}
+
+	second, err := app.Handle(session, IVRParams{CallerNumber: "4155551234", Digits: "1"})
+	if err != nil {
+		t.Fatalf("second request: %v", err)
+	}
+	say, ok := second.Actions[0].(Say)
+	if !ok || say.Text != "Connecting 4155551234 to sales" {
+		t.Fatalf("unexpected response: %#v", second.Actions)
+	}
+}
+
+func TestFlowAppTemplatedTransferAfterCheck(t *testing.T) {
+	definition := &FlowDefinition{
+		Name:  "hours",
+		Start: "check",
+		Nodes: map[string]*FlowNode{
+			"check":  {Action: "closed", OnError: "closed", Say: "We're open", Hangup: true},
+			"closed": {Say: "We're closed", Transfer: "{{.on_call}}"},
+		},
+	}
+	actions := map[string]FlowAction{
+		"closed": func(ctx *FlowContext) error {
+			ctx.Vars["on_call"] = "ext:200"
+			return ErrFlowCheckFailed
+		},
+	}
+
+	app, err := NewFlowApp(definition, actions, NewPrompts())
+	if err != nil {
+		t.Fatalf("NewFlowApp: %v", err)
+	}
+
+	response, err := app.Handle(sessions.NewSession(nil, "hours-ivr-session"), IVRParams{CallerNumber: "4155551234"})
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if len(response.Actions) < 2 {
+		t.Fatalf("unexpected response: %#v", response.Actions)
+	}
+	if transfer, ok := response.Actions[1].(Transfer); !ok || transfer.Destination != "200" {
+		t.Fatalf("expected transfer to extension 200, got %#v", response.Actions[1])
+	}
+}
@@ -0,0 +1,78 @@
+// services/ivr_greeting.go
+// Time-of-day aware prompts using the caller's local time
+
+package services
+
+import (
+	"log"
+	"time"
+)
+
+// TimeOfDay names the part of the day at t: "morning" (5am-noon), "afternoon" (noon-5pm) or "evening"
+func TimeOfDay(t time.Time) string {
+	switch hour := t.Hour(); {
+	case hour >= 5 && hour < 12:
+		return "morning"
+	case hour >= 12 && hour < 17:
+		return "afternoon"
+	default:
+		return "evening"
+	}
+}
+
+// CallerTimezone resolves the caller's timezone from their area code (guessed for unknown codes), or ""
+func CallerTimezone(callerNumber string) string {
+	location, match, _ := GlobalAreaCodes.Resolve(ExtractAreaCode(callerNumber))
+	if match == AreaCodeUnknown {
+		return ""
+	}
+	return location.Timezone
+}
+
+// LocalTime returns the current time in the caller's timezone, or server time if it is unknown
+func (p IVRParams) LocalTime() time.Time {
+	now := time.Now()
+	if p.Timezone == "" {
+		return now
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		log.Printf("Error loading timezone %s: %v", p.Timezone, err)
+		return now
+	}
+	return now.In(loc)
+}
+
+// TimeOfDay names the part of the caller's day
+func (p IVRParams) TimeOfDay() string {
+	return TimeOfDay(p.LocalTime())
+}
+
+// TimedKey returns the variant of a prompt key for the caller's part of the day
+// (e.g. common.goodbye.evening), or key itself when the catalog has no variant
+func (p *Prompts) TimedKey(key string, params IVRParams) string {
+	timed := key + "." + params.TimeOfDay()
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if _, exists := p.texts[timed]; exists {
+		return timed
+	}
+	return key
+}
+
+// TimedPrompt returns the prompt for key worded for the caller's part of the day
+func (p *Prompts) TimedPrompt(key string, params IVRParams, vars map[string]string) interface{} {
+	return p.Prompt(p.TimedKey(key, params), vars)
+}
+
+// Greet starts a response with "Good morning", "Good afternoon" or "Good evening" in the
+// caller's timezone, unless another app (e.g. the main menu) already greeted them
+func (p *Prompts) Greet(params IVRParams, response Response) Response {
+	if params.CallID != "" {
+		return response
+	}
+	response.Actions = append([]interface{}{p.TimedPrompt("greeting", params, nil)}, response.Actions...)
+	return response
+}
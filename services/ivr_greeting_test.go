@@ -0,0 +1,59 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeOfDay(t *testing.T) {
+	tests := map[int]string{
+		0:  "evening",
+		4:  "evening",
+		5:  "morning",
+		11: "morning",
+		12: "afternoon",
+		16: "afternoon",
+		17: "evening",
+		23: "evening",
+	}
+	for hour, want := range tests {
+		if got := TimeOfDay(time.Date(2024, 6, 1, hour, 30, 0, 0, time.UTC)); got != want {
+			t.Errorf("TimeOfDay(%d:30) = %q, want %q", hour, got, want)
+		}
+	}
+}
+
+func TestGreetUsesCallerTimezone(t *testing.T) {
+	prompts := NewPrompts()
+	params := IVRParams{CallerNumber: "2125551234", Timezone: CallerTimezone("2125551234")}
+	if params.Timezone != "America/New_York" {
+		t.Fatalf("CallerTimezone = %q, want America/New_York", params.Timezone)
+	}
+
+	want := "greeting." + TimeOfDay(time.Now().In(mustLoadLocation(t, "America/New_York")))
+	if key := prompts.TimedKey("greeting", params); key != want {
+		t.Errorf("TimedKey = %q, want %q", key, want)
+	}
+	if key := prompts.TimedKey("menu.welcome", params); key != "menu.welcome" {
+		t.Errorf("TimedKey without variants = %q, want menu.welcome", key)
+	}
+
+	greeted := prompts.Greet(params, Response{Actions: []interface{}{Hangup{}}})
+	if len(greeted.Actions) != 2 {
+		t.Errorf("Greet added %d actions, want 1", len(greeted.Actions)-1)
+	}
+
+	params.CallID = "call_1" // handed off from the main menu, which already greeted the caller
+	if handedOff := prompts.Greet(params, Response{Actions: []interface{}{Hangup{}}}); len(handedOff.Actions) != 1 {
+		t.Error("Greet greeted a caller handed off from another app")
+	}
+}
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	return loc
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Handoff kinds, chosen by the prefix of a handoff spec
+const (
+	HandoffNumber    = "number"    // external number, dialed ("+14155551234" or "number:...")
+	HandoffQueue     = "queue"     // call queue, dialed ("queue:support")
+	HandoffExtension = "extension" // user on the domain, transferred ("ext:100")
+	HandoffForward   = "forward"   // raw dial plan destination, forwarded ("forward:...")
+)
+
+// defaultDialTimeout is how long a Dial rings before the IVR continues
+const defaultDialTimeout = 30
+
+// HandoffTarget is where an IVR app sends a caller it's done with, e.g. an operator
+type HandoffTarget struct {
+	Kind        string `json:"kind"`
+	Destination string `json:"destination"`
+}
+
+// ParseHandoffTarget parses "queue:support", "ext:100", "forward:..." or a bare phone number
+func ParseHandoffTarget(spec string) (HandoffTarget, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return HandoffTarget{}, fmt.Errorf("handoff target cannot be empty")
+	}
+
+	kind, destination, found := strings.Cut(spec, ":")
+	if !found {
+		kind, destination = HandoffNumber, spec
+	}
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	destination = strings.TrimSpace(destination)
+
+	switch kind {
+	case "ext":
+		kind = HandoffExtension
+	case "tel":
+		kind = HandoffNumber
+	}
+
+	switch kind {
+	case HandoffNumber:
+		if strings.Trim(destination, "+0123456789-() ") != "" {
+			return HandoffTarget{}, fmt.Errorf("invalid handoff number %q", destination)
+		}
+		international := strings.HasPrefix(destination, "+")
+		destination = nonDigits.ReplaceAllString(destination, "")
+		if international {
+			destination = "+" + destination
+		}
+	case HandoffQueue, HandoffExtension, HandoffForward:
+	default:
+		return HandoffTarget{}, fmt.Errorf("unknown handoff kind %q in %q (expected number, queue, ext or forward)", kind, spec)
+	}
+
+	if strings.Trim(destination, "+") == "" {
+		return HandoffTarget{}, fmt.Errorf("handoff target %q has no destination", spec)
+	}
+
+	return HandoffTarget{Kind: kind, Destination: destination}, nil
+}
+
+// String returns the spec the target was parsed from, in canonical form
+func (ht HandoffTarget) String() string {
+	if ht.Kind == HandoffExtension {
+		return "ext:" + ht.Destination
+	}
+	return ht.Kind + ":" + ht.Destination
+}
+
+// Element returns the XML verb that hands the caller off: Dial for numbers and queues,
+// Transfer for extensions, Forward for raw destinations. Actions after a Dial run only
+// if nobody answers, so apps can follow it with an apology and a Hangup.
+func (ht HandoffTarget) Element() interface{} {
+	switch ht.Kind {
+	case HandoffExtension:
+		return Transfer{Destination: ht.Destination}
+	case HandoffForward:
+		return Forward{Destination: ht.Destination}
+	case HandoffQueue:
+		return Dial{
+			Timeout: strconv.Itoa(defaultDialTimeout),
+			Targets: []interface{}{DialQueue{Queue: ht.Destination}},
+		}
+	default:
+		return Dial{
+			Timeout: strconv.Itoa(defaultDialTimeout),
+			Targets: []interface{}{DialNumber{Number: ht.Destination}},
+		}
+	}
+}
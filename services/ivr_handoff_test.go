@@ -0,0 +1,30 @@
+package services
+
+import "testing"
+
+func TestParseHandoffTarget(t *testing.T) {
+	cases := map[string]HandoffTarget{
+		"queue:support":       {Kind: HandoffQueue, Destination: "support"},
+		"ext:100":             {Kind: HandoffExtension, Destination: "100"},
+		"+1 (415) 555-1234":   {Kind: HandoffNumber, Destination: "+14155551234"},
+		"number:4155551234":   {Kind: HandoffNumber, Destination: "4155551234"},
+		"forward:sip:100@pbx": {Kind: HandoffForward, Destination: "sip:100@pbx"},
+	}
+
+	for spec, want := range cases {
+		got, err := ParseHandoffTarget(spec)
+		if err != nil {
+			t.Errorf("ParseHandoffTarget(%q) failed: %v", spec, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseHandoffTarget(%q) = %+v, want %+v", spec, got, want)
+		}
+	}
+
+	for _, spec := range []string{"", "queue:", "pager:123", "call me"} {
+		if _, err := ParseHandoffTarget(spec); err == nil {
+			t.Errorf("ParseHandoffTarget(%q) should fail", spec)
+		}
+	}
+}
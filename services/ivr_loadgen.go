@@ -0,0 +1,241 @@
+// services/ivr_loadgen.go
+// Load generation: many simulated calls ramped up over time, with latency percentiles
+
+package services
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"o-dan-go/events"
+)
+
+// Limits keep a load test from taking the server down by accident
+const (
+	maxLoadTestCalls       = 10000
+	maxLoadTestConcurrency = 500
+	maxLoadTestRamp        = 10 * time.Minute
+)
+
+// LoadTestConfig describes a load test: Calls runs of Scenario, started evenly over Ramp,
+// with at most Concurrency calls in progress
+type LoadTestConfig struct {
+	Calls       int                `json:"calls"`
+	Concurrency int                `json:"concurrency"`
+	Ramp        string             `json:"ramp,omitempty"` // e.g. "30s"; "" starts every call at once
+	Scenario    SimulationScenario `json:"scenario"`       // caller_number may be empty to rotate demo callers
+}
+
+// LatencyStats summarizes latencies in milliseconds
+type LatencyStats struct {
+	Count int     `json:"count"`
+	Mean  float64 `json:"mean_ms"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+// LoadTestReport is the outcome of a load test
+type LoadTestReport struct {
+	Calls            int            `json:"calls"`
+	Passed           int            `json:"passed"`
+	Failed           int            `json:"failed"`
+	Requests         int            `json:"requests"`
+	RequestErrors    int            `json:"request_errors"` // non-200 responses
+	Duration         string         `json:"duration"`
+	CallsPerSecond   float64        `json:"calls_per_second"`
+	RequestLatency   LatencyStats   `json:"request_latency"`
+	CallLatency      LatencyStats   `json:"call_latency"` // time spent waiting on the IVR per call, excluding delays
+	Failures         map[string]int `json:"failures,omitempty"`
+	EventsDropped    uint64         `json:"events_dropped"` // call events the event manager's queue rejected
+	ListenerDrops    uint64         `json:"listener_drops"` // deliveries skipped to slow listeners
+	MaxQueuedEvents  int            `json:"max_queued_events"`
+	EventQueueLength int            `json:"event_queue_capacity"`
+}
+
+// ValidateLoadTest checks a load test and its scenario before it is run, filling in defaults
+func (cs *CallSimulator) ValidateLoadTest(config *LoadTestConfig) error {
+	if config.Calls <= 0 || config.Calls > maxLoadTestCalls {
+		return fmt.Errorf("calls must be between 1 and %d", maxLoadTestCalls)
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = config.Calls
+	}
+	if config.Concurrency > maxLoadTestConcurrency {
+		return fmt.Errorf("concurrency can be at most %d", maxLoadTestConcurrency)
+	}
+	if config.Ramp != "" {
+		ramp, err := time.ParseDuration(config.Ramp)
+		if err != nil || ramp < 0 || ramp > maxLoadTestRamp {
+			return fmt.Errorf("ramp must be a duration up to %s", maxLoadTestRamp)
+		}
+	}
+
+	// Validate the scenario once with a placeholder caller; each call gets its own
+	scenario := config.Scenario
+	if scenario.CallerNumber == "" {
+		scenario.CallerNumber = simulationNumbers[0]
+	}
+	if err := cs.Validate(&scenario); err != nil {
+		return fmt.Errorf("scenario: %w", err)
+	}
+	config.Scenario.App = scenario.App
+	if config.Scenario.CallerNumber != "" {
+		config.Scenario.CallerNumber = scenario.CallerNumber
+	}
+	return nil
+}
+
+// RunLoadTest runs a validated load test and reports latency percentiles and failures
+func (cs *CallSimulator) RunLoadTest(config LoadTestConfig) LoadTestReport {
+	ramp, _ := time.ParseDuration(config.Ramp)
+	runID := time.Now().UnixNano()
+	log.Printf("[WR] Load test: %d %s calls, %d concurrent, ramp %s", config.Calls, config.Scenario.App, config.Concurrency, ramp)
+
+	before := events.Manager.Stats()
+	report := LoadTestReport{
+		Calls:            config.Calls,
+		Failures:         make(map[string]int),
+		EventQueueLength: before.QueueCapacity,
+	}
+
+	// Sample the event queue while calls run, to see how close it gets to dropping events
+	stopSampling := make(chan struct{})
+	samplingDone := make(chan struct{})
+	go func() {
+		defer close(samplingDone)
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if queued := events.Manager.Stats().QueuedEvents; queued > report.MaxQueuedEvents {
+					report.MaxQueuedEvents = queued
+				}
+			case <-stopSampling:
+				return
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	var requestLatencies, callLatencies []float64
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, config.Concurrency)
+	started := time.Now()
+
+	for i := 0; i < config.Calls; i++ {
+		// Spread call starts evenly over the ramp
+		if ramp > 0 {
+			if wait := time.Until(started.Add(ramp * time.Duration(i) / time.Duration(config.Calls))); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		slots <- struct{}{}
+
+		scenario := config.Scenario
+		scenario.Steps = append([]SimulationStep(nil), config.Scenario.Steps...)
+		scenario.CallID = fmt.Sprintf("%sload_%d_%d", SimulatedCallPrefix, runID, i)
+		if scenario.CallerNumber == "" {
+			scenario.CallerNumber = simulationNumbers[i%len(simulationNumbers)]
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result := cs.Run(scenario)
+
+			mu.Lock()
+			defer mu.Unlock()
+			callLatency := 0.0
+			for _, step := range result.Steps {
+				requestLatencies = append(requestLatencies, step.Latency)
+				callLatency += step.Latency
+				if step.Status != 200 {
+					report.RequestErrors++
+				}
+			}
+			callLatencies = append(callLatencies, callLatency)
+
+			if result.Passed {
+				report.Passed++
+				return
+			}
+			report.Failed++
+			report.Failures[loadTestFailure(result)]++
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(started)
+
+	close(stopSampling)
+	<-samplingDone
+
+	after := events.Manager.Stats()
+	report.Requests = len(requestLatencies)
+	report.Duration = elapsed.Round(time.Millisecond).String()
+	report.CallsPerSecond = float64(config.Calls) / elapsed.Seconds()
+	report.RequestLatency = latencyStats(requestLatencies)
+	report.CallLatency = latencyStats(callLatencies)
+	report.EventsDropped = after.EventsDropped - before.EventsDropped
+	report.ListenerDrops = after.ListenerDrops - before.ListenerDrops
+
+	log.Printf("[WR] Load test finished in %s: %d passed, %d failed, p99 %.1fms",
+		report.Duration, report.Passed, report.Failed, report.RequestLatency.P99)
+	return report
+}
+
+// loadTestFailure describes why a call failed, so failures can be grouped
+func loadTestFailure(result SimulationResult) string {
+	for i, step := range result.Steps {
+		switch {
+		case step.Status != 200:
+			return fmt.Sprintf("step %d: HTTP %d", i, step.Status)
+		case len(step.Missing) > 0:
+			return fmt.Sprintf("step %d: missing %s", i, strings.Join(step.Missing, ", "))
+		}
+	}
+	return "call ended before the last step"
+}
+
+// latencyStats computes nearest-rank percentiles
+func latencyStats(latencies []float64) LatencyStats {
+	stats := LatencyStats{Count: len(latencies)}
+	if len(latencies) == 0 {
+		return stats
+	}
+
+	sorted := append([]float64(nil), latencies...)
+	sort.Float64s(sorted)
+
+	total := 0.0
+	for _, latency := range sorted {
+		total += latency
+	}
+	percentile := func(p float64) float64 {
+		index := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if index < 0 {
+			index = 0
+		}
+		return sorted[index]
+	}
+
+	round := func(ms float64) float64 {
+		return math.Round(ms*1000) / 1000
+	}
+
+	stats.Mean = round(total / float64(len(sorted)))
+	stats.P50 = round(percentile(0.50))
+	stats.P90 = round(percentile(0.90))
+	stats.P99 = round(percentile(0.99))
+	stats.Max = round(sorted[len(sorted)-1])
+	return stats
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// MenuOption maps a DTMF digit to a registered IVR app
+type MenuOption struct {
+	Digit string `json:"digit"`
+	App   string `json:"app"`
+	Label string `json:"label"`
+}
+
+// MenuApp is the top-level IVR at /wr/menu. After the caller picks an app, later
+// requests for the call are forwarded to it, with its Gather actions pointed back
+// at /wr/menu so the whole call shares one session.
+type MenuApp struct {
+	wr       *WebResponderService
+	options  []MenuOption
+	operator *HandoffTarget // "press 0 for an operator", nil if not configured
+	prompts  *Prompts
+}
+
+// ParseMenuOptions parses "1:weather,2:cdr-lookup:call lookup" into menu options.
+// The label is optional and defaults to the app name.
+func ParseMenuOptions(spec string) ([]MenuOption, error) {
+	var options []MenuOption
+	seen := make(map[string]bool)
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid menu option %q: expected digit:app[:label]", entry)
+		}
+
+		digit := strings.TrimSpace(parts[0])
+		if len(digit) != 1 || !strings.Contains("0123456789*#", digit) {
+			return nil, fmt.Errorf("invalid menu option %q: digit must be 0-9, * or #", entry)
+		}
+		if seen[digit] {
+			return nil, fmt.Errorf("menu digit %s is assigned more than once", digit)
+		}
+		seen[digit] = true
+
+		option := MenuOption{
+			Digit: digit,
+			App:   strings.TrimSpace(parts[1]),
+		}
+		if len(parts) == 3 {
+			option.Label = strings.TrimSpace(parts[2])
+		}
+		if option.Label == "" {
+			option.Label = strings.ReplaceAll(option.App, "-", " ")
+		}
+		options = append(options, option)
+	}
+
+	return options, nil
+}
+
+// NewMenuApp creates the main menu IVR over the apps registered with wr.
+// operator is where 0 sends the caller (nil to disable); an app option on 0 takes precedence.
+func NewMenuApp(wr *WebResponderService, options []MenuOption, operator *HandoffTarget, prompts *Prompts) *MenuApp {
+	return &MenuApp{
+		wr:       wr,
+		options:  options,
+		operator: operator,
+		prompts:  prompts,
+	}
+}
+
+// Name returns the route the app is mounted under (/wr/menu)
+func (ma *MenuApp) Name() string {
+	return "menu"
+}
+
+// Handle presents the menu, routes a selection, or forwards to the selected app
+func (ma *MenuApp) Handle(session *sessions.Session, params IVRParams) (Response, error) {
+	selected, _ := session.Values["menu_app"].(string)
+
+	// Mid-call in a selected app: forward the request
+	if selected != "" && params.HasInput() {
+		return ma.forward(session, params, selected, false)
+	}
+
+	// Waiting for a menu selection
+	if params.Digits != "" && session.Values["menu_call_id"] != nil {
+		ma.sendEvent(session, params, "dtmf_received", fmt.Sprintf("Pressed %s", params.Digits))
+
+		for _, option := range ma.availableOptions() {
+			if option.Digit == params.Digits {
+				log.Printf("[WR] Menu selection %s: routing to %s", params.Digits, option.App)
+				session.Values["menu_app"] = option.App
+				ma.sendEvent(session, params, "app_selected", fmt.Sprintf("Routed to %s", option.Label))
+				return ma.forward(session, params, option.App, true)
+			}
+		}
+
+		if params.Digits == "0" && ma.operatorAvailable() {
+			return ma.transferToOperator(session, params), nil
+		}
+
+		ma.sendEvent(session, params, "invalid_selection", fmt.Sprintf("Invalid digit: %s", params.Digits))
+		return ma.menuResponse("common.invalid"), nil
+	}
+
+	// New call
+	log.Printf("[WR] New menu call from: %s", params.CallerNumber)
+	delete(session.Values, "menu_app")
+	session.Values["menu_session_id"] = fmt.Sprintf("menu_%d", time.Now().Unix())
+	session.Values["menu_call_id"] = NewCallID(params.Values)
+	ma.sendEvent(session, params, "call_started", "New incoming call")
+
+	return ma.prompts.Greet(params, ma.menuResponse("menu.welcome")), nil
+}
+
+// forward hands the request to an app, starting it fresh when it was just selected
+func (ma *MenuApp) forward(session *sessions.Session, params IVRParams, appName string, start bool) (Response, error) {
+	app, exists := ma.wr.GetApp(appName)
+	if !exists || app.Name() == ma.Name() {
+		delete(session.Values, "menu_app")
+		ma.sendEvent(session, params, "error", fmt.Sprintf("IVR app %s is not available", appName))
+		return ma.menuResponse("common.unavailable"), nil
+	}
+
+	if start {
+		params.Digits = ""
+	}
+	params.App = appName
+	params.SessionID, _ = session.Values["menu_session_id"].(string)
+	params.CallID, _ = session.Values["menu_call_id"].(string)
+
+	response, err := app.Handle(session, params)
+	if err != nil {
+		return Response{}, err
+	}
+
+	// Keep routing through the menu while the app is still gathering input
+	if !pointInputsAt(response.Actions, "/wr/"+ma.Name()) {
+		delete(session.Values, "menu_app")
+	}
+	return response, nil
+}
+
+// pointInputsAt rewrites Gather and Record actions to url, reporting whether any were found
+func pointInputsAt(actions []interface{}, url string) bool {
+	found := false
+	for i, action := range actions {
+		switch input := action.(type) {
+		case Gather:
+			input.Action = url
+			actions[i] = input
+			found = true
+		case Record:
+			input.Action = url
+			actions[i] = input
+			found = true
+		}
+	}
+	return found
+}
+
+// operatorAvailable reports whether 0 reaches the operator rather than an app option
+func (ma *MenuApp) operatorAvailable() bool {
+	if ma.operator == nil {
+		return false
+	}
+	for _, option := range ma.availableOptions() {
+		if option.Digit == "0" {
+			return false
+		}
+	}
+	return true
+}
+
+// transferToOperator hands the caller to the operator, apologizing if nobody answers
+func (ma *MenuApp) transferToOperator(session *sessions.Session, params IVRParams) Response {
+	log.Printf("[WR] Menu transferring %s to operator %s", params.CallerNumber, ma.operator)
+	ma.sendEvent(session, params, "call_transferred", fmt.Sprintf("Transferred to operator (%s)", ma.operator))
+	delete(session.Values, "menu_call_id")
+
+	return Response{
+		Actions: []interface{}{
+			ma.prompts.Prompt("common.transfer", nil),
+			ma.operator.Element(),
+			ma.prompts.Prompt("common.no_answer", nil),
+			Hangup{},
+		},
+	}
+}
+
+// availableOptions skips options whose app isn't registered (e.g. a flow that failed to load)
+func (ma *MenuApp) availableOptions() []MenuOption {
+	var available []MenuOption
+	for _, option := range ma.options {
+		if _, exists := ma.wr.GetApp(option.App); exists && option.App != ma.Name() {
+			available = append(available, option)
+		}
+	}
+	return available
+}
+
+func (ma *MenuApp) menuResponse(introKey string) Response {
+	options := ma.availableOptions()
+	if len(options) == 0 {
+		return Response{
+			Actions: []interface{}{
+				ma.prompts.Prompt("common.no_services", nil),
+				Hangup{},
+			},
+		}
+	}
+
+	prompts := make([]string, 0, len(options))
+	speech := make(map[string][]string, len(options))
+	for _, option := range options {
+		prompts = append(prompts, ma.prompts.Text("menu.option", map[string]string{"label": option.Label, "digit": option.Digit}))
+		speech[option.Digit] = []string{option.Label, strings.ReplaceAll(option.App, "-", " ")}
+	}
+	if ma.operatorAvailable() {
+		prompts = append(prompts, ma.prompts.Text("menu.operator", nil))
+		speech["0"] = []string{"operator", "agent", "representative", "a person"}
+	}
+
+	return Response{
+		Actions: []interface{}{
+			ma.prompts.Prompt(introKey, nil),
+			Gather{
+				NumDigits: "1",
+				Action:    "/wr/" + ma.Name(),
+				Timeout:   "10",
+				Actions: []interface{}{
+					ma.prompts.SayText(strings.Join(prompts, " ")),
+				},
+				SpeechChoices: speech,
+			},
+			ma.prompts.Prompt("common.no_input", nil),
+			Hangup{},
+		},
+	}
+}
+
+func (ma *MenuApp) sendEvent(session *sessions.Session, params IVRParams, eventType, details string) {
+	sessionID, _ := session.Values["menu_session_id"].(string)
+	callID, _ := session.Values["menu_call_id"].(string)
+
+	sendCallEvent(ma.Name(), sessionID, callID, params.CallerNumber, eventType, details)
+}
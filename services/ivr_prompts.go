@@ -0,0 +1,289 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// VoiceSettings are the TTS attributes applied to every Say
+type VoiceSettings struct {
+	Voice    string `json:"voice"`
+	Language string `json:"language"`
+	Rate     string `json:"rate,omitempty"` // speaking rate, for platforms that support it
+}
+
+// DefaultPromptCatalog holds the built-in text for every prompt the coded IVR apps speak.
+// Prompts are Go templates; a prompts file can override any of them by key.
+var DefaultPromptCatalog = map[string]string{
+	"common.goodbye":           "Thank you for calling. Goodbye!",
+	"common.goodbye.morning":   "Thank you for calling. Have a good day!",
+	"common.goodbye.afternoon": "Thank you for calling. Have a good afternoon!",
+	"common.goodbye.evening":   "Thank you for calling. Have a good evening!",
+	"greeting":                 "Hello.",
+	"greeting.morning":         "Good morning.",
+	"greeting.afternoon":       "Good afternoon.",
+	"greeting.evening":         "Good evening.",
+	"common.no_input":          "I didn't receive your selection. Goodbye!",
+	"common.invalid":           "Invalid selection. Let me repeat the options.",
+	"common.error":             "I'm sorry, there was an error processing your request. Please try again.",
+	"common.unavailable":       "I'm sorry, that service is not available right now.",
+	"common.no_services":       "I'm sorry, no services are available right now. Goodbye!",
+	"common.transfer":          "Please hold while I transfer your call.",
+	"common.no_answer":         "I'm sorry, no one is available to take your call right now. Please try again later. Goodbye!",
+	"menu.welcome":             "Welcome!",
+	"menu.option":              "For {{.label}}, press {{.digit}}.",
+	"menu.operator":            "To speak with an operator, press 0.",
+	"weather.no_area":          "I'm sorry, I couldn't identify your area code.",
+	"weather.unknown":          "I'm sorry, I couldn't identify the location for area code {{.area_code}}.",
+	"weather.welcome":          "Welcome! I've detected you're calling from area code {{.area_code}}, which covers {{.location}}.",
+	"weather.menu":             "For the current local time in {{.city}}, press 1. For the current temperature, press 2. For the air quality index, press 3. To use a ZIP code instead, press 4.",
+	"weather.welcome_guess":    "Welcome! I don't know area code {{.area_code}} yet, so I'll give you information for {{.location}}.",
+	"weather.zip_enter":        "Please enter your 5 digit ZIP code.",
+	"weather.zip_invalid":      "I couldn't find the ZIP code {{.zip}}.",
+	"weather.zip_failed":       "I'm sorry, I couldn't find that ZIP code. Goodbye!",
+	"weather.zip_found":        "Thanks. I'll give you information for ZIP code {{.zip}}, in {{.location}}.",
+	"weather.local_time":       "The current time in {{.location}} is {{.local_time}}.",
+	"weather.temperature":      "The current temperature in {{.location}} is {{.temperature}} degrees Fahrenheit.",
+	"weather.temp_missing":     "I'm sorry, the temperature for {{.location}} is not available right now.",
+	"weather.aqi":              "The current Air Quality Index in {{.location}} is {{.aqi}}. This is considered {{.aqi_description}}",
+	"weather.aqi_missing":      "I'm sorry, air quality information for {{.location}} is not available right now.",
+	"aqi.good":                 "Good. Air quality is satisfactory.",
+	"aqi.moderate":             "Moderate. Air quality is acceptable for most people.",
+	"aqi.sensitive":            "Unhealthy for sensitive groups. People with heart or lung conditions should limit time outdoors.",
+	"aqi.unhealthy":            "Unhealthy. Everyone may experience health effects.",
+	"aqi.very_unhealthy":       "Very unhealthy. This is a health alert, everyone may experience serious effects.",
+	"aqi.hazardous":            "Hazardous. This is an emergency health warning, everyone should stay indoors.",
+	"aqi.unknown":              "Unknown.",
+	"sms.offer":                "To receive this by text message, press 9.",
+	"sms.sent":                 "I've sent that to you by text message.",
+	"sms.failed":               "I'm sorry, I couldn't send a text message right now.",
+	"voicemail.welcome":        "You've reached our message line.",
+	"voicemail.menu":           "To leave a message, press 1. To request a call back, press 2.",
+	"voicemail.record":         "Please leave your message after the tone. Press pound when you're finished.",
+	"voicemail.callback":       "After the tone, please say your name and the best time to reach you. We'll call you back at the number you're calling from.",
+	"voicemail.saved":          "Thank you, your message has been saved.",
+	"voicemail.callback_saved": "Thank you, we'll call you back as soon as we can.",
+	"voicemail.no_recording":   "I didn't receive a recording. Goodbye!",
+	"cdr.welcome":              "Welcome to call lookup.",
+	"cdr.menu":                 "To hear recent calls for the number you're calling from, press 1. To look up a different number, press 2.",
+	"cdr.enter_number":         "Please enter the 10 digit phone number, including area code.",
+	"cdr.invalid_number":       "That wasn't a valid 10 digit number.",
+	"cdr.no_number":            "I didn't receive a number. Goodbye!",
+	"cdr.unavailable":          "I'm sorry, call records are not available right now. Please try again later.",
+	"cdr.no_calls":             "I didn't find any calls involving {{.number}} in the last {{.days}} days.",
+	"cdr.summary":              "I found {{.count}} {{.calls}} involving {{.number}} in the last {{.days}} days. The most recent was on {{.date}} at {{.time}} and lasted {{.duration}}.",
+	"queue.status":             "There {{.are}} {{.count}} {{.callers}} waiting in the {{.queue}} queue.",
+	"queue.longest":            "The longest wait so far is {{.longest}}.",
+	"queue.agents":             "{{.available}} of {{.agents}} logged in agents are available.",
+	"queue.no_agents":          "No agents are logged in to this queue.",
+	"queue.wait":               "A new caller would wait about {{.wait}}.",
+	"queue.no_wait":            "A new caller would be answered right away.",
+	"queue.menu":               "To hear the status again, press 1.",
+	"queue.unavailable":        "I'm sorry, queue status is not available right now.",
+	"cdr.summary_no_time":      "I found {{.count}} {{.calls}} involving {{.number}} in the last {{.days}} days. The most recent lasted {{.duration}}.",
+}
+
+// AudioDir is where recorded prompts live; it is served at /static/audio
+const AudioDir = "./static/audio"
+
+// Prompts renders spoken prompts from the catalog with the deployment's voice settings,
+// substituting professionally recorded audio where a recording exists.
+// It is safe for concurrent use and can be reloaded at runtime.
+type Prompts struct {
+	mu           sync.RWMutex
+	voice        VoiceSettings
+	templates    map[string]*template.Template
+	texts        map[string]string
+	audioBaseURL string
+	recordings   map[string]string // prompt key -> audio URL
+}
+
+// NewPrompts creates prompts with the default catalog and voice
+func NewPrompts() *Prompts {
+	prompts := &Prompts{}
+	if err := prompts.Load(VoiceSettings{}, ""); err != nil {
+		// The default catalog is compiled into the binary, so this is a programming error
+		panic(err)
+	}
+	return prompts
+}
+
+// Load applies voice settings and the default catalog overlaid with the prompts file
+// at catalogPath (YAML or JSON map of key to text; "" for defaults only).
+// On error the current prompts are left untouched.
+func (p *Prompts) Load(voice VoiceSettings, catalogPath string) error {
+	if voice.Voice == "" {
+		voice.Voice = "female"
+	}
+	if voice.Language == "" {
+		voice.Language = "en-US"
+	}
+
+	catalog := make(map[string]string, len(DefaultPromptCatalog))
+	for key, text := range DefaultPromptCatalog {
+		catalog[key] = text
+	}
+
+	if catalogPath != "" {
+		data, err := os.ReadFile(catalogPath)
+		if err != nil {
+			return fmt.Errorf("failed to read prompts file: %w", err)
+		}
+
+		var overrides map[string]string
+		if err := yaml.Unmarshal(data, &overrides); err != nil {
+			return fmt.Errorf("failed to parse prompts file: %w", err)
+		}
+		for key, text := range overrides {
+			if _, exists := DefaultPromptCatalog[key]; !exists {
+				log.Printf("[WR] Prompts file %s: unknown prompt %q ignored", catalogPath, key)
+				continue
+			}
+			catalog[key] = text
+		}
+	}
+
+	templates := make(map[string]*template.Template, len(catalog))
+	for key, text := range catalog {
+		tmpl, err := template.New(key).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return fmt.Errorf("invalid prompt %s: %w", key, err)
+		}
+		templates[key] = tmpl
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.voice = voice
+	p.templates = templates
+	p.texts = catalog
+	return nil
+}
+
+// LoadAudio scans dir for recordings named after prompt keys (e.g. menu.welcome.wav)
+// and plays them in place of TTS. baseURL is the server's public URL, which NetSapiens
+// needs to fetch the files ("" for URLs relative to the responder).
+// Prompts with template variables are always spoken, since a recording can't fill them in.
+func (p *Prompts) LoadAudio(dir, baseURL string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read audio directory: %w", err)
+	}
+
+	p.mu.RLock()
+	texts := p.texts
+	p.mu.RUnlock()
+
+	recordings := make(map[string]string)
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".wav" && ext != ".mp3") {
+			continue
+		}
+
+		key := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		text, exists := texts[key]
+		switch {
+		case !exists:
+			continue // other audio, e.g. files played by flows
+		case strings.Contains(text, "{{"):
+			log.Printf("[WR] Recording %s ignored: prompt %s has variables", entry.Name(), key)
+			continue
+		}
+		recordings[key] = AudioURL(baseURL, entry.Name())
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.audioBaseURL = baseURL
+	p.recordings = recordings
+	if len(recordings) > 0 {
+		log.Printf("[WR] Using %d recorded prompts from %s", len(recordings), dir)
+	}
+	return nil
+}
+
+// AudioURL resolves an audio file under /static/audio against baseURL.
+// Full URLs (e.g. on a CDN) are returned unchanged.
+func AudioURL(baseURL, file string) string {
+	if strings.Contains(file, "://") {
+		return file
+	}
+	return strings.TrimRight(baseURL, "/") + "/static/audio/" + url.PathEscape(file)
+}
+
+// Voice returns the current voice settings
+func (p *Prompts) Voice() VoiceSettings {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.voice
+}
+
+// Text renders the prompt for key with vars
+func (p *Prompts) Text(key string, vars map[string]string) string {
+	p.mu.RLock()
+	tmpl, exists := p.templates[key]
+	p.mu.RUnlock()
+
+	if !exists {
+		log.Printf("[WR] Unknown prompt %q", key)
+		return ""
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		log.Printf("[WR] Failed to render prompt %s: %v", key, err)
+		return ""
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// Say renders the prompt for key as a Say element
+func (p *Prompts) Say(key string, vars map[string]string) Say {
+	return p.SayText(p.Text(key, vars))
+}
+
+// Prompt returns the recording for key as a Play element if there is one,
+// otherwise the prompt spoken with Say
+func (p *Prompts) Prompt(key string, vars map[string]string) interface{} {
+	p.mu.RLock()
+	recording, recorded := p.recordings[key]
+	p.mu.RUnlock()
+
+	if recorded {
+		return Play{URL: recording}
+	}
+	return p.Say(key, vars)
+}
+
+// Play returns a Play element for an audio file under /static/audio (or a full URL)
+func (p *Prompts) Play(file string) Play {
+	p.mu.RLock()
+	baseURL := p.audioBaseURL
+	p.mu.RUnlock()
+
+	return Play{URL: AudioURL(baseURL, file)}
+}
+
+// SayText wraps already-rendered text in a Say element with the current voice
+func (p *Prompts) SayText(text string) Say {
+	voice := p.Voice()
+	return Say{
+		Voice:    voice.Voice,
+		Language: voice.Language,
+		Rate:     voice.Rate,
+		Text:     text,
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// QueueStatusApp is the status line at /wr/queue-status: agents and supervisors hear
+// how many callers are waiting in the configured call queue and the estimated wait
+type QueueStatusApp struct {
+	queues  *CallQueueService
+	domain  string
+	queue   string
+	prompts *Prompts
+}
+
+// NewQueueStatusApp creates the queue status IVR app for one queue
+func NewQueueStatusApp(queues *CallQueueService, domain, queue string, prompts *Prompts) *QueueStatusApp {
+	return &QueueStatusApp{
+		queues:  queues,
+		domain:  domain,
+		queue:   queue,
+		prompts: prompts,
+	}
+}
+
+// Name returns the route the app is mounted under (/wr/queue-status)
+func (qa *QueueStatusApp) Name() string {
+	return "queue-status"
+}
+
+// Handle reads back the queue status, repeating it (with fresh numbers) when the caller presses 1
+func (qa *QueueStatusApp) Handle(session *sessions.Session, params IVRParams) (Response, error) {
+	// First call - no digits pressed
+	if params.Digits == "" || session.Values["call_id"] == nil {
+		log.Printf("[WR] New queue status call from: %s", params.CallerNumber)
+
+		if params.CallID != "" {
+			session.Values["session_id"] = params.SessionID
+			session.Values["call_id"] = params.CallID
+		} else {
+			session.Values["session_id"] = fmt.Sprintf("queue_status_%d", time.Now().Unix())
+			session.Values["call_id"] = NewCallID(params.Values)
+			qa.sendEvent(session, params, "call_started", "New incoming call")
+		}
+
+		return qa.prompts.Greet(params, qa.readout(session, params)), nil
+	}
+
+	log.Printf("[WR] DTMF received: %s", params.Digits)
+	qa.sendEvent(session, params, "dtmf_received", fmt.Sprintf("Pressed %s", params.Digits))
+
+	if params.Digits == "1" {
+		return qa.readout(session, params), nil
+	}
+
+	qa.sendEvent(session, params, "call_ended", "Call completed successfully")
+	return Response{
+		Actions: []interface{}{
+			qa.prompts.TimedPrompt("common.goodbye", params, nil),
+			Hangup{},
+		},
+	}, nil
+}
+
+// readout fetches the queue status and speaks it, offering to repeat
+func (qa *QueueStatusApp) readout(session *sessions.Session, params IVRParams) Response {
+	if qa.domain == "" || qa.queue == "" {
+		qa.sendEvent(session, params, "error", "No call queue configured")
+		return qa.unavailable(session, params)
+	}
+
+	status, err := qa.queues.GetQueueStatus(qa.domain, qa.queue)
+	if err != nil {
+		log.Printf("[WR] Queue status failed for %s@%s: %v", qa.queue, qa.domain, err)
+		qa.sendEvent(session, params, "error", fmt.Sprintf("Queue status failed: %v", err))
+		return qa.unavailable(session, params)
+	}
+
+	vars := map[string]string{
+		"queue":     status.Name,
+		"count":     strconv.Itoa(status.Waiting),
+		"callers":   "callers",
+		"are":       "are",
+		"available": strconv.Itoa(status.AgentsAvailable),
+		"agents":    strconv.Itoa(status.AgentsLoggedIn),
+		"longest":   SpeakDuration(int(status.LongestWait.Seconds())),
+		"wait":      SpeakDuration(int(status.EstimatedWait.Round(time.Minute).Seconds())),
+	}
+	if status.Waiting == 1 {
+		vars["callers"], vars["are"] = "caller", "is"
+	}
+
+	actions := []interface{}{qa.prompts.Prompt("queue.status", vars)}
+	if status.Waiting > 0 && status.LongestWait > 0 {
+		actions = append(actions, qa.prompts.Prompt("queue.longest", vars))
+	}
+	switch {
+	case status.AgentsLoggedIn == 0:
+		actions = append(actions, qa.prompts.Prompt("queue.no_agents", vars))
+	case status.EstimatedWait == 0:
+		actions = append(actions, qa.prompts.Prompt("queue.agents", vars), qa.prompts.Prompt("queue.no_wait", vars))
+	default:
+		actions = append(actions, qa.prompts.Prompt("queue.agents", vars), qa.prompts.Prompt("queue.wait", vars))
+	}
+
+	qa.sendEvent(session, params, "response_sent", fmt.Sprintf("%s: %d waiting, %d/%d agents available, ~%s wait",
+		status.Name, status.Waiting, status.AgentsAvailable, status.AgentsLoggedIn, status.EstimatedWait.Round(time.Minute)))
+
+	return Response{
+		Actions: append(actions,
+			Gather{
+				NumDigits: "1",
+				Action:    "/wr/" + qa.Name(),
+				Timeout:   "10",
+				Actions: []interface{}{
+					qa.prompts.Prompt("queue.menu", nil),
+				},
+				SpeechChoices: map[string][]string{
+					"1": {"again", "repeat", "refresh"},
+				},
+			},
+			qa.prompts.TimedPrompt("common.goodbye", params, nil),
+			Hangup{},
+		),
+	}
+}
+
+func (qa *QueueStatusApp) unavailable(session *sessions.Session, params IVRParams) Response {
+	qa.sendEvent(session, params, "call_ended", "Call completed successfully")
+	return Response{
+		Actions: []interface{}{
+			qa.prompts.Prompt("queue.unavailable", nil),
+			qa.prompts.TimedPrompt("common.goodbye", params, nil),
+			Hangup{},
+		},
+	}
+}
+
+func (qa *QueueStatusApp) sendEvent(session *sessions.Session, params IVRParams, eventType, details string) {
+	sessionID, _ := session.Values["session_id"].(string)
+	callID, _ := session.Values["call_id"].(string)
+
+	sendCallEvent(qa.Name(), sessionID, callID, params.CallerNumber, eventType, details)
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/gob"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// ivrCallIDParams are the request parameters that identify a call, in order of preference.
+// NetSapiens sends OrigCallID; the others cover webphones and Twilio-style callers.
+var ivrCallIDParams = []string{"OrigCallID", "CallID", "CallSid", "SessionID"}
+
+// IVRSessionBackend persists IVR session values server-side
+type IVRSessionBackend interface {
+	Load(key string) (map[interface{}]interface{}, bool, error)
+	Save(key string, values map[interface{}]interface{}, ttl time.Duration) error
+}
+
+// IVRSessionStore is a gorilla sessions.Store that keeps IVR sessions on the server, keyed
+// by the call ID NetSapiens sends with every request, so calls work even when the caller's
+// platform doesn't keep cookies. Requests without a call ID fall back to cookie sessions.
+type IVRSessionStore struct {
+	backend IVRSessionBackend
+	cookies *sessions.CookieStore
+	ttl     time.Duration
+}
+
+// NewIVRSessionStore creates the session store for IVR apps: "memory" (default), "sqlite"
+// (survives restarts, shared by instances on one database) or "cookie" (the old behavior)
+func NewIVRSessionStore(kind, sessionSecret string, db *DatabaseService, ttl time.Duration) (sessions.Store, error) {
+	cookies := sessions.NewCookieStore([]byte(sessionSecret))
+
+	var backend IVRSessionBackend
+	switch strings.ToLower(kind) {
+	case "cookie":
+		return cookies, nil
+	case "", "memory":
+		backend = NewMemorySessionBackend()
+	case "sqlite":
+		if db == nil {
+			return nil, fmt.Errorf("sqlite IVR sessions need a database")
+		}
+		backend = &SQLiteSessionBackend{db: db}
+	default:
+		return nil, fmt.Errorf("unknown IVR session store %q (expected memory, sqlite or cookie)", kind)
+	}
+
+	return &IVRSessionStore{
+		backend: backend,
+		cookies: cookies,
+		ttl:     ttl,
+	}, nil
+}
+
+// Get returns the session for the request's call, cached for the life of the request
+func (ss *IVRSessionStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(ss, name)
+}
+
+// New loads the session for the request's call, or starts an empty one
+func (ss *IVRSessionStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	key := ivrSessionKey(r, name)
+	if key == "" {
+		return ss.cookies.New(r, name)
+	}
+
+	session := sessions.NewSession(ss, name)
+	session.ID = key
+	session.IsNew = true
+
+	values, found, err := ss.backend.Load(key)
+	if err != nil {
+		return session, fmt.Errorf("failed to load IVR session: %w", err)
+	}
+	if found {
+		session.Values = values
+		session.IsNew = false
+	}
+	return session, nil
+}
+
+// Save stores the session server-side; nothing is written to the response
+func (ss *IVRSessionStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.ID == "" {
+		return ss.cookies.Save(r, w, session)
+	}
+	return ss.backend.Save(session.ID, session.Values, ss.ttl)
+}
+
+// ivrSessionKey builds the server-side key from the session name and the request's call ID.
+// The request form must already be parsed.
+func ivrSessionKey(r *http.Request, name string) string {
+	for _, param := range ivrCallIDParams {
+		if callID := strings.TrimSpace(r.Form.Get(param)); callID != "" {
+			return name + ":" + callID
+		}
+	}
+	return ""
+}
+
+// RequestCallID returns the platform call ID from request parameters, or "" if none was sent
+func RequestCallID(values map[string]string) string {
+	for _, param := range ivrCallIDParams {
+		if callID := strings.TrimSpace(values[param]); callID != "" {
+			return callID
+		}
+	}
+	return ""
+}
+
+// NewCallID returns the platform call ID from request parameters, or a new unique one for
+// callers that don't send any, so calls starting in the same second aren't merged
+func NewCallID(values map[string]string) string {
+	if callID := RequestCallID(values); callID != "" {
+		return callID
+	}
+	var random [4]byte
+	rand.Read(random[:])
+	return fmt.Sprintf("call_%d_%x", time.Now().UnixNano(), random)
+}
+
+// MemorySessionBackend keeps IVR sessions in memory until they expire
+type MemorySessionBackend struct {
+	sessions *TTLStore[string, map[interface{}]interface{}]
+}
+
+// NewMemorySessionBackend creates an empty in-memory session backend
+func NewMemorySessionBackend() *MemorySessionBackend {
+	return &MemorySessionBackend{sessions: NewTTLStore[string, map[interface{}]interface{}](0, 0)}
+}
+
+// Load returns a copy of the session's values, if it exists and hasn't expired
+func (mb *MemorySessionBackend) Load(key string) (map[interface{}]interface{}, bool, error) {
+	values, exists := mb.sessions.Get(key)
+	if !exists {
+		return nil, false, nil
+	}
+	return copySessionValues(values), true, nil
+}
+
+// Save stores a copy of the session's values until ttl passes
+func (mb *MemorySessionBackend) Save(key string, values map[interface{}]interface{}, ttl time.Duration) error {
+	mb.sessions.SetWithTTL(key, copySessionValues(values), ttl)
+	return nil
+}
+
+func copySessionValues(values map[interface{}]interface{}) map[interface{}]interface{} {
+	copied := make(map[interface{}]interface{}, len(values))
+	for k, v := range values {
+		copied[k] = v
+	}
+	return copied
+}
+
+// SQLiteSessionBackend keeps IVR sessions in the ivr_sessions table
+type SQLiteSessionBackend struct {
+	db *DatabaseService
+}
+
+// Load decodes the session's values, if it exists and hasn't expired
+func (sb *SQLiteSessionBackend) Load(key string) (map[interface{}]interface{}, bool, error) {
+	var data []byte
+	err := sb.db.db.QueryRow(`SELECT data FROM ivr_sessions WHERE session_key = ? AND expires_at > ?`, key, time.Now()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	values := make(map[interface{}]interface{})
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&values); err != nil {
+		return nil, false, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return values, true, nil
+}
+
+// Save encodes and stores the session's values, deleting expired sessions
+func (sb *SQLiteSessionBackend) Save(key string, values map[interface{}]interface{}, ttl time.Duration) error {
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(values); err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	now := time.Now()
+	query := `INSERT OR REPLACE INTO ivr_sessions (session_key, data, expires_at) VALUES (?, ?, ?)`
+	if _, err := sb.db.db.Exec(query, key, data.Bytes(), now.Add(ttl)); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+
+	_, err := sb.db.db.Exec(`DELETE FROM ivr_sessions WHERE expires_at <= ?`, now)
+	return err
+}
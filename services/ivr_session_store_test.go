@@ -0,0 +1,62 @@
+package services
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIVRSessionStoreKeysByCallID(t *testing.T) {
+	store, err := NewIVRSessionStore("memory", "secret", nil, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := httptest.NewRequest("GET", "/wr/menu?OrigCallID=call-1", nil)
+	first.ParseForm()
+	session, err := store.Get(first, "menu-ivr-session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.Values["menu_app"] = "weather"
+	recorder := httptest.NewRecorder()
+	if err := session.Save(first, recorder); err != nil {
+		t.Fatal(err)
+	}
+	if cookie := recorder.Header().Get("Set-Cookie"); cookie != "" {
+		t.Errorf("server-side session set a cookie: %s", cookie)
+	}
+
+	// A later request for the same call, without cookies, sees the saved values
+	second := httptest.NewRequest("GET", "/wr/menu?OrigCallID=call-1&Digits=1", nil)
+	second.ParseForm()
+	session, _ = store.Get(second, "menu-ivr-session")
+	if got := session.Values["menu_app"]; got != "weather" {
+		t.Errorf("menu_app = %v, want weather", got)
+	}
+
+	// Other calls get their own session
+	other := httptest.NewRequest("GET", "/wr/menu?OrigCallID=call-2", nil)
+	other.ParseForm()
+	session, _ = store.Get(other, "menu-ivr-session")
+	if !session.IsNew || len(session.Values) != 0 {
+		t.Errorf("call-2 should start with an empty session, got %v", session.Values)
+	}
+}
+
+func TestNewCallID(t *testing.T) {
+	if callID := NewCallID(map[string]string{"CallSid": "CA123", "SessionID": "s-1"}); callID != "CA123" {
+		t.Errorf("NewCallID = %q, want the platform's call ID", callID)
+	}
+
+	// Calls without one get distinct IDs even when they start together
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		callID := NewCallID(nil)
+		if seen[callID] || !strings.HasPrefix(callID, "call_") {
+			t.Fatalf("NewCallID() = %q after %d calls, want a new call_ ID", callID, i)
+		}
+		seen[callID] = true
+	}
+}
@@ -0,0 +1,286 @@
+// services/ivr_simulator.go
+// Scenario-driven call simulator: plays a scripted caller against the real IVR endpoints
+
+package services
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Limits keep a scenario from tying up the server
+const (
+	maxSimulationSteps = 50
+	maxSimulationDelay = 30 * time.Second
+)
+
+// SimulationScenario scripts one call: who is calling, which app they dial,
+// and what they press or say at each prompt
+type SimulationScenario struct {
+	Name           string           `json:"name,omitempty"`
+	App            string           `json:"app,omitempty"`     // defaults to menu
+	CallID         string           `json:"call_id,omitempty"` // sent as OrigCallID; always starts with sim_
+	CallerNumber   string           `json:"caller_number"`
+	ExpectGreeting []string         `json:"expect_greeting,omitempty"` // text the first response must contain
+	Steps          []SimulationStep `json:"steps"`
+}
+
+// SimulationStep is one caller input after the call is answered
+type SimulationStep struct {
+	Digits string   `json:"digits,omitempty"`
+	Speech string   `json:"speech,omitempty"`
+	Delay  string   `json:"delay,omitempty"`  // wait before the input, e.g. "2s"
+	Expect []string `json:"expect,omitempty"` // text the response must contain (case-insensitive)
+}
+
+// SimulationResult reports what the IVR said at each step and whether expectations held
+type SimulationResult struct {
+	Name         string                 `json:"name,omitempty"`
+	App          string                 `json:"app"`
+	CallID       string                 `json:"call_id"`
+	CallerNumber string                 `json:"caller_number"`
+	Passed       bool                   `json:"passed"`
+	Steps        []SimulationStepResult `json:"steps"`
+	StartedAt    time.Time              `json:"started_at"`
+	Duration     string                 `json:"duration"`
+}
+
+// SimulationStepResult is the IVR's response to one request
+type SimulationStepResult struct {
+	Input   string   `json:"input"`
+	Status  int      `json:"status"`
+	Said    []string `json:"said"` // spoken text and played audio URLs
+	Latency float64  `json:"latency_ms"`
+	Ended   bool     `json:"ended"` // the response doesn't wait for more input
+	Passed  bool     `json:"passed"`
+	Missing []string `json:"missing,omitempty"`
+}
+
+// CallSimulator sends scenario requests through the same HTTP handler NetSapiens calls,
+// so sessions, speech resolution and events all behave as on a real call
+type CallSimulator struct {
+	wr      *WebResponderService
+	handler http.Handler
+}
+
+// NewCallSimulator creates a simulator that drives handler's /wr/<app> routes
+func NewCallSimulator(wr *WebResponderService, handler http.Handler) *CallSimulator {
+	return &CallSimulator{
+		wr:      wr,
+		handler: handler,
+	}
+}
+
+// simulationNumbers are demo callers from different cities
+var simulationNumbers = []string{
+	"4155551234", // San Francisco
+	"2125551234", // New York
+	"3125551234", // Chicago
+	"5125551234", // Austin
+	"7025551234", // Las Vegas
+	"3055551234", // Miami
+	"2065551234", // Seattle
+	"6175551234", // Boston
+}
+
+// DefaultSimulationScenario is the dashboard's demo call: a random caller picks the weather app
+// from the main menu and asks for one of its readings
+func DefaultSimulationScenario() SimulationScenario {
+	return SimulationScenario{
+		Name:         "demo",
+		App:          "menu",
+		CallerNumber: simulationNumbers[rand.Intn(len(simulationNumbers))],
+		Steps: []SimulationStep{
+			{Digits: "1", Delay: "2s"},
+			{Digits: fmt.Sprintf("%d", rand.Intn(3)+1), Delay: "2s"},
+		},
+	}
+}
+
+// Validate checks a scenario before it is run, filling in defaults
+func (cs *CallSimulator) Validate(scenario *SimulationScenario) error {
+	if scenario.App == "" {
+		scenario.App = "menu"
+	}
+	// Simulated calls are shown live but kept out of call analytics
+	if scenario.CallID == "" {
+		scenario.CallID = fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	if !strings.HasPrefix(scenario.CallID, SimulatedCallPrefix) {
+		scenario.CallID = SimulatedCallPrefix + scenario.CallID
+	}
+	if _, exists := cs.wr.GetApp(scenario.App); !exists {
+		return fmt.Errorf("unknown IVR app %q", scenario.App)
+	}
+
+	callerNumber := NormalizePhoneNumber(scenario.CallerNumber)
+	if callerNumber == "" {
+		return fmt.Errorf("caller_number must be a 10-digit number")
+	}
+	scenario.CallerNumber = callerNumber
+
+	if len(scenario.Steps) > maxSimulationSteps {
+		return fmt.Errorf("a scenario can have at most %d steps", maxSimulationSteps)
+	}
+	for i, step := range scenario.Steps {
+		if strings.Trim(step.Digits, "0123456789*#") != "" {
+			return fmt.Errorf("step %d: digits may only contain 0-9, * and #", i+1)
+		}
+		if step.Delay != "" {
+			delay, err := time.ParseDuration(step.Delay)
+			if err != nil || delay < 0 || delay > maxSimulationDelay {
+				return fmt.Errorf("step %d: delay must be a duration up to %s", i+1, maxSimulationDelay)
+			}
+		}
+	}
+	return nil
+}
+
+// Run plays a validated scenario and returns what happened. The call stops early if the IVR
+// stops asking for input (hangs up or hands the call off).
+func (cs *CallSimulator) Run(scenario SimulationScenario) SimulationResult {
+	result := SimulationResult{
+		Name:         scenario.Name,
+		App:          scenario.App,
+		CallID:       scenario.CallID,
+		CallerNumber: scenario.CallerNumber,
+		Passed:       true,
+		Steps:        []SimulationStepResult{},
+		StartedAt:    time.Now(),
+	}
+	log.Printf("[WR] Simulating %s call %s from %s", scenario.App, result.CallID, scenario.CallerNumber)
+
+	// Answer the call, then send each input
+	steps := append([]SimulationStep{{Expect: scenario.ExpectGreeting}}, scenario.Steps...)
+	var cookies []*http.Cookie
+	for i, step := range steps {
+		if delay, _ := time.ParseDuration(step.Delay); delay > 0 {
+			time.Sleep(delay)
+		}
+
+		stepResult, responseCookies := cs.request(scenario, step, cookies)
+		cookies = mergeCookies(cookies, responseCookies)
+
+		result.Passed = result.Passed && stepResult.Passed
+		result.Steps = append(result.Steps, stepResult)
+		if stepResult.Ended || stepResult.Status != http.StatusOK {
+			if i < len(steps)-1 {
+				// Inputs the caller never got to make can't meet their expectations
+				result.Passed = false
+			}
+			break
+		}
+	}
+
+	result.Duration = time.Since(result.StartedAt).Round(time.Millisecond).String()
+	log.Printf("[WR] Simulated call %s finished (passed: %v)", result.CallID, result.Passed)
+	return result
+}
+
+// request sends one step to the app and checks its expectations
+func (cs *CallSimulator) request(scenario SimulationScenario, step SimulationStep, cookies []*http.Cookie) (SimulationStepResult, []*http.Cookie) {
+	query := url.Values{
+		"NmsAni":     {scenario.CallerNumber},
+		"OrigCallID": {scenario.CallID},
+	}
+	input := "(answer)"
+	if step.Digits != "" {
+		query.Set("Digits", step.Digits)
+		input = step.Digits
+	}
+	if step.Speech != "" {
+		query.Set("SpeechResult", step.Speech)
+		input = fmt.Sprintf("%q", step.Speech)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/wr/"+url.PathEscape(scenario.App)+"?"+query.Encode(), nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	started := time.Now()
+	cs.handler.ServeHTTP(recorder, req)
+	latency := time.Since(started)
+
+	response := recorder.Result()
+	body, _ := io.ReadAll(response.Body)
+	said, ended := spokenText(body)
+
+	stepResult := SimulationStepResult{
+		Input:   input,
+		Status:  response.StatusCode,
+		Said:    said,
+		Latency: float64(latency.Microseconds()) / 1000,
+		Ended:   ended,
+		Passed:  response.StatusCode == http.StatusOK,
+	}
+
+	text := strings.ToLower(strings.Join(said, " ") + " " + string(body))
+	for _, expected := range step.Expect {
+		if !strings.Contains(text, strings.ToLower(expected)) {
+			stepResult.Missing = append(stepResult.Missing, expected)
+			stepResult.Passed = false
+		}
+	}
+
+	return stepResult, response.Cookies()
+}
+
+// spokenText pulls the Say text and Play URLs out of an IVR response, and whether the call ends there.
+// Responses that Gather or Record wait for the caller, even if a Hangup follows for when they don't respond.
+func spokenText(body []byte) ([]string, bool) {
+	said := []string{}
+	waitsForInput := false
+
+	decoder := xml.NewDecoder(strings.NewReader(string(body)))
+	inPrompt := false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "Say", "Play":
+				inPrompt = true
+			case "Gather", "Record":
+				waitsForInput = true
+			}
+		case xml.EndElement:
+			if t.Name.Local == "Say" || t.Name.Local == "Play" {
+				inPrompt = false
+			}
+		case xml.CharData:
+			if text := strings.TrimSpace(string(t)); inPrompt && text != "" {
+				said = append(said, text)
+			}
+		}
+	}
+	return said, !waitsForInput
+}
+
+// mergeCookies carries cookies between requests like a browser would, for cookie-backed sessions
+func mergeCookies(current, updates []*http.Cookie) []*http.Cookie {
+	byName := make(map[string]*http.Cookie)
+	for _, cookie := range current {
+		byName[cookie.Name] = cookie
+	}
+	for _, cookie := range updates {
+		byName[cookie.Name] = &http.Cookie{Name: cookie.Name, Value: cookie.Value}
+	}
+
+	merged := make([]*http.Cookie, 0, len(byName))
+	for _, cookie := range byName {
+		merged = append(merged, cookie)
+	}
+	return merged
+}
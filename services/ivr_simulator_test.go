@@ -0,0 +1,182 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"o-dan-go/events"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+type echoApp struct{}
+
+func (echoApp) Name() string { return "echo" }
+
+func (echoApp) Handle(session *sessions.Session, params IVRParams) (Response, error) {
+	return Response{}, nil
+}
+
+func TestCallSimulatorRunsScenario(t *testing.T) {
+	wr := NewWebResponderService(sessions.NewCookieStore([]byte("test")))
+	if err := wr.RegisterApp(echoApp{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stands in for the router: says what was pressed, and hangs up on 9
+	var callIDs []string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		callIDs = append(callIDs, r.URL.Query().Get("OrigCallID"))
+		digits := r.URL.Query().Get("Digits")
+		if digits == "9" {
+			fmt.Fprint(w, `<Response><Say>Goodbye</Say><Hangup/></Response>`)
+			return
+		}
+		fmt.Fprintf(w, `<Response><Gather><Say>You pressed %q</Say></Gather><Say>No input</Say><Hangup/></Response>`, digits)
+	})
+	simulator := NewCallSimulator(wr, handler)
+
+	scenario := SimulationScenario{
+		App:            "echo",
+		CallID:         "demo",
+		CallerNumber:   "1-415-555-1234",
+		ExpectGreeting: []string{`pressed ""`},
+		Steps: []SimulationStep{
+			{Digits: "4", Expect: []string{`PRESSED "4"`}},
+			{Digits: "9", Expect: []string{"goodbye"}},
+			{Digits: "1"},
+		},
+	}
+	if err := simulator.Validate(&scenario); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if scenario.CallerNumber != "4155551234" || scenario.CallID != "sim_demo" {
+		t.Errorf("defaults not applied: %+v", scenario)
+	}
+
+	result := simulator.Run(scenario)
+	if len(result.Steps) != 3 {
+		t.Fatalf("expected the call to stop after the hangup, got %d steps", len(result.Steps))
+	}
+	for i, step := range result.Steps {
+		if !step.Passed {
+			t.Errorf("step %d failed: missing %v", i, step.Missing)
+		}
+	}
+	if !result.Steps[2].Ended || result.Steps[1].Ended {
+		t.Errorf("only the last response should end the call: %+v", result.Steps)
+	}
+	// The unreached "1" step fails the scenario
+	if result.Passed {
+		t.Error("expected the scenario to fail")
+	}
+	for _, callID := range callIDs {
+		if callID != "sim_demo" {
+			t.Errorf("request sent call ID %q", callID)
+		}
+	}
+}
+
+func TestCallSimulatorValidate(t *testing.T) {
+	wr := NewWebResponderService(sessions.NewCookieStore([]byte("test")))
+	wr.RegisterApp(echoApp{})
+	simulator := NewCallSimulator(wr, http.NotFoundHandler())
+
+	invalid := []SimulationScenario{
+		{App: "missing", CallerNumber: "4155551234"},
+		{App: "echo", CallerNumber: "555"},
+		{App: "echo", CallerNumber: "4155551234", Steps: []SimulationStep{{Digits: "1a"}}},
+		{App: "echo", CallerNumber: "4155551234", Steps: []SimulationStep{{Delay: "5m"}}},
+	}
+	for i, scenario := range invalid {
+		if err := simulator.Validate(&scenario); err == nil {
+			t.Errorf("scenario %d: expected an error", i)
+		}
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	latencies := make([]float64, 0, 100)
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, float64(i))
+	}
+
+	stats := latencyStats(latencies)
+	if stats.Count != 100 || stats.P50 != 50 || stats.P90 != 90 || stats.P99 != 99 || stats.Max != 100 || stats.Mean != 50.5 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	if empty := latencyStats(nil); empty.Count != 0 || empty.Max != 0 {
+		t.Errorf("unexpected stats for no latencies %+v", empty)
+	}
+}
+
+func TestSimulatedAppCallsStayOutOfAnalytics(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "simulator.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+	analytics := NewWRAnalyticsService(db)
+
+	wr := NewWebResponderService(sessions.NewCookieStore([]byte("test")))
+	if err := wr.RegisterApp(NewVoicemailApp(db, NewPrompts())); err != nil {
+		t.Fatal(err)
+	}
+	// Dispatches /wr/:app as the router does
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app, _ := wr.GetApp(strings.TrimPrefix(r.URL.Path, "/wr/"))
+		r.ParseForm()
+		values := map[string]string{}
+		for key := range r.Form {
+			values[key] = r.Form.Get(key)
+		}
+		session, _ := wr.GetSession(r, app.Name()+"-ivr-session")
+		response, err := app.Handle(session, IVRParams{App: app.Name(), CallerNumber: values["NmsAni"], Digits: values["Digits"], Values: values})
+		if err != nil {
+			t.Errorf("Handle: %v", err)
+		}
+		session.Save(r, w)
+		fmt.Fprint(w, wr.GenerateXMLResponse(response))
+	})
+
+	events.Manager.Start()
+	listener := events.Manager.Subscribe()
+	defer events.Manager.Unsubscribe(listener)
+
+	simulator := NewCallSimulator(wr, handler)
+	scenario := SimulationScenario{App: "voicemail", CallID: "vm-1", CallerNumber: "4155551234", Steps: []SimulationStep{{Digits: "1"}}}
+	if err := simulator.Validate(&scenario); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if result := simulator.Run(scenario); !result.Passed {
+		t.Fatalf("scenario failed: %+v", result.Steps)
+	}
+
+	// call_started and the caller's choice
+	for seen := 0; seen < 2; {
+		select {
+		case event := <-listener:
+			if event.App != "voicemail" {
+				continue
+			}
+			seen++
+			if event.CallID != "sim_vm-1" {
+				t.Errorf("%s event has call ID %q, want the simulated call's", event.EventType, event.CallID)
+			}
+			if err := analytics.RecordEvent(event); err != nil {
+				t.Fatalf("RecordEvent: %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("saw %d voicemail events, want 2", seen)
+		}
+	}
+
+	var calls int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM wr_calls`).Scan(&calls); err != nil || calls != 0 {
+		t.Errorf("analytics recorded %d calls (%v), want the simulated call ignored", calls, err)
+	}
+}
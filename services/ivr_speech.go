@@ -0,0 +1,165 @@
+package services
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+// unrecognizedSpeech is passed to apps as Digits when speech can't be mapped to a choice,
+// so they take their invalid-selection path and re-prompt (callers can fall back to DTMF)
+const unrecognizedSpeech = "?"
+
+// SpeechSettings controls speech input on Gather elements
+type SpeechSettings struct {
+	Enabled             bool
+	ConfidenceThreshold float64
+}
+
+// SetSpeechSettings updates speech input settings for every IVR app
+func (wr *WebResponderService) SetSpeechSettings(settings SpeechSettings) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	wr.speech = settings
+}
+
+func (wr *WebResponderService) speechSettings() SpeechSettings {
+	wr.mu.RLock()
+	defer wr.mu.RUnlock()
+
+	return wr.speech
+}
+
+// spokenDigits maps number words to DTMF digits
+var spokenDigits = map[string]string{
+	"zero": "0", "oh": "0", "one": "1", "two": "2", "to": "2", "too": "2", "three": "3",
+	"four": "4", "for": "4", "five": "5", "six": "6", "seven": "7", "eight": "8", "nine": "9",
+	"star": "*", "pound": "#", "hash": "#",
+}
+
+// PrepareSpeech enables speech input on the response's Gather elements and remembers
+// their choices in the session, so the next request's SpeechResult can be mapped to digits
+func (wr *WebResponderService) PrepareSpeech(session *sessions.Session, response *Response) {
+	settings := wr.speechSettings()
+	delete(session.Values, "speech_choices")
+	if !settings.Enabled {
+		return
+	}
+
+	for i, action := range response.Actions {
+		gather, ok := action.(Gather)
+		if !ok {
+			continue
+		}
+
+		gather.Input = "dtmf speech"
+		seen := make(map[string]bool)
+		var hints []string
+		for _, keywords := range gather.SpeechChoices {
+			for _, keyword := range keywords {
+				if !seen[keyword] {
+					seen[keyword] = true
+					hints = append(hints, keyword)
+				}
+			}
+		}
+		sort.Strings(hints)
+		gather.Hints = strings.Join(hints, ", ")
+		response.Actions[i] = gather
+
+		choicesJSON, _ := json.Marshal(gather.SpeechChoices)
+		session.Values["speech_choices"] = string(choicesJSON)
+	}
+}
+
+// ResolveSpeech fills in Digits from a speech result when the caller spoke instead of pressing keys.
+// Keyword choices from the previous Gather are tried first, then spoken numbers ("four one five").
+// Results below the confidence threshold are treated as unrecognized.
+func (wr *WebResponderService) ResolveSpeech(session *sessions.Session, params IVRParams) IVRParams {
+	if params.Digits != "" || params.Speech == "" {
+		return params
+	}
+
+	settings := wr.speechSettings()
+	if !settings.Enabled {
+		return params
+	}
+
+	if params.Confidence < settings.ConfidenceThreshold {
+		log.Printf("[WR] Speech %q below confidence threshold (%.2f < %.2f)", params.Speech, params.Confidence, settings.ConfidenceThreshold)
+		params.Digits = unrecognizedSpeech
+		return params
+	}
+
+	var choices map[string][]string
+	if choicesJSON, ok := session.Values["speech_choices"].(string); ok {
+		json.Unmarshal([]byte(choicesJSON), &choices)
+	}
+
+	if digits := MatchSpeechChoice(params.Speech, choices); digits != "" {
+		params.Digits = digits
+	} else if digits := SpeechToDigits(params.Speech); digits != "" {
+		params.Digits = digits
+	} else {
+		params.Digits = unrecognizedSpeech
+	}
+
+	log.Printf("[WR] Speech %q (confidence %.2f) resolved to %q", params.Speech, params.Confidence, params.Digits)
+	return params
+}
+
+// MatchSpeechChoice returns the digits whose keywords appear in speech, preferring the longest
+// keyword so "air quality" wins over "air"
+func MatchSpeechChoice(speech string, choices map[string][]string) string {
+	normalized := " " + normalizeSpeech(speech) + " "
+
+	best, bestLength := "", 0
+	for digits, keywords := range choices {
+		for _, keyword := range keywords {
+			keyword = normalizeSpeech(keyword)
+			if keyword == "" || len(keyword) <= bestLength {
+				continue
+			}
+			if strings.Contains(normalized, " "+keyword+" ") {
+				best, bestLength = digits, len(keyword)
+			}
+		}
+	}
+	return best
+}
+
+// SpeechToDigits converts spoken numbers ("four one five", "4155551234") to digits.
+// It returns "" if anything other than numbers was said.
+func SpeechToDigits(speech string) string {
+	var digits strings.Builder
+	for _, word := range strings.Fields(normalizeSpeech(speech)) {
+		if digit, ok := spokenDigits[word]; ok {
+			digits.WriteString(digit)
+			continue
+		}
+		if strings.Trim(word, "0123456789") != "" {
+			return ""
+		}
+		digits.WriteString(word)
+	}
+	return digits.String()
+}
+
+// normalizeSpeech lowercases speech and strips punctuation
+func normalizeSpeech(speech string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '*', r == '#':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return ' '
+		}
+	}, speech)
+	return strings.Join(strings.Fields(cleaned), " ")
+}
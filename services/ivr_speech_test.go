@@ -0,0 +1,33 @@
+package services
+
+import "testing"
+
+func TestMatchSpeechChoice(t *testing.T) {
+	cases := map[string]string{
+		"Weather":                   "2",
+		"what's the time please":    "1",
+		"air quality":               "3",
+		"tell me about the airport": "",
+		"":                          "",
+	}
+
+	for speech, want := range cases {
+		if got := MatchSpeechChoice(speech, weatherSpeechChoices); got != want {
+			t.Errorf("MatchSpeechChoice(%q) = %q, want %q", speech, got, want)
+		}
+	}
+}
+
+func TestSpeechToDigits(t *testing.T) {
+	cases := map[string]string{
+		"four one five, 555 1234": "4155551234",
+		"One.":                    "1",
+		"weather":                 "",
+	}
+
+	for speech, want := range cases {
+		if got := SpeechToDigits(speech); got != want {
+			t.Errorf("SpeechToDigits(%q) = %q, want %q", speech, got, want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// RecordingCallbackPath receives NetSapiens recording status callbacks
+const RecordingCallbackPath = "/wr/recordings/callback"
+
+// Maximum recording lengths, in seconds
+const (
+	voicemailMaxLength = 120
+	callbackMaxLength  = 30
+)
+
+// VoicemailApp is the message line at /wr/voicemail: callers leave a voicemail or
+// a callback request, and the recording's metadata is stored for the dashboard
+type VoicemailApp struct {
+	db      *DatabaseService
+	prompts *Prompts
+}
+
+// NewVoicemailApp creates the voicemail IVR app
+func NewVoicemailApp(db *DatabaseService, prompts *Prompts) *VoicemailApp {
+	return &VoicemailApp{
+		db:      db,
+		prompts: prompts,
+	}
+}
+
+// Name returns the route the app is mounted under (/wr/voicemail)
+func (va *VoicemailApp) Name() string {
+	return "voicemail"
+}
+
+// Handle offers voicemail or callback, records the caller, then stores the recording.
+// Session key voicemail_kind is set once the caller has chosen.
+func (va *VoicemailApp) Handle(session *sessions.Session, params IVRParams) (Response, error) {
+	kind, _ := session.Values["voicemail_kind"].(string)
+
+	// Recording finished
+	if recordingURL := params.Values["RecordingUrl"]; recordingURL != "" && kind != "" {
+		return va.saveRecording(session, params, kind, recordingURL), nil
+	}
+
+	// First call - no digits pressed
+	if params.Digits == "" || session.Values["call_id"] == nil {
+		log.Printf("[WR] New voicemail call from: %s", params.CallerNumber)
+		delete(session.Values, "voicemail_kind")
+
+		if params.CallID != "" {
+			session.Values["session_id"] = params.SessionID
+			session.Values["call_id"] = params.CallID
+		} else {
+			session.Values["session_id"] = fmt.Sprintf("voicemail_%d", time.Now().Unix())
+			session.Values["call_id"] = NewCallID(params.Values)
+			va.sendEvent(session, params, "call_started", "New incoming call")
+		}
+
+		return va.prompts.Greet(params, va.menuPrompt("voicemail.welcome")), nil
+	}
+
+	log.Printf("[WR] DTMF received: %s", params.Digits)
+	va.sendEvent(session, params, "dtmf_received", fmt.Sprintf("Pressed %s", params.Digits))
+
+	switch params.Digits {
+	case "1":
+		session.Values["voicemail_kind"] = RecordingVoicemail
+		return va.recordPrompt("voicemail.record", voicemailMaxLength), nil
+	case "2":
+		session.Values["voicemail_kind"] = RecordingCallback
+		return va.recordPrompt("voicemail.callback", callbackMaxLength), nil
+	default:
+		va.sendEvent(session, params, "invalid_selection", fmt.Sprintf("Invalid digit: %s", params.Digits))
+		return va.menuPrompt("common.invalid"), nil
+	}
+}
+
+// saveRecording stores the metadata NetSapiens sent after the Record and ends the call
+func (va *VoicemailApp) saveRecording(session *sessions.Session, params IVRParams, kind, recordingURL string) Response {
+	duration, _ := strconv.Atoi(params.Values["RecordingDuration"])
+	sessionID, _ := session.Values["session_id"].(string)
+	callID, _ := session.Values["call_id"].(string)
+
+	recording := &Recording{
+		RecordingID:     params.Values["RecordingSid"],
+		CallID:          callID,
+		SessionID:       sessionID,
+		App:             va.Name(),
+		Kind:            kind,
+		CallerNumber:    params.CallerNumber,
+		URL:             recordingURL,
+		DurationSeconds: duration,
+		Status:          "completed",
+	}
+	if err := va.db.SaveRecording(recording); err != nil {
+		log.Printf("[WR] Failed to save %s recording: %v", kind, err)
+		va.sendEvent(session, params, "error", fmt.Sprintf("Failed to save recording: %v", err))
+	} else {
+		log.Printf("[WR] Saved %s recording from %s (%ds)", kind, params.CallerNumber, duration)
+		va.sendEvent(session, params, "response_sent", fmt.Sprintf("Recorded %s (%ds)", kind, duration))
+	}
+
+	savedKey := "voicemail.saved"
+	if kind == RecordingCallback {
+		savedKey = "voicemail.callback_saved"
+	}
+
+	delete(session.Values, "voicemail_kind")
+	va.sendEvent(session, params, "call_ended", "Call completed successfully")
+
+	return Response{
+		Actions: []interface{}{
+			va.prompts.Prompt(savedKey, nil),
+			va.prompts.TimedPrompt("common.goodbye", params, nil),
+			Hangup{},
+		},
+	}
+}
+
+func (va *VoicemailApp) menuPrompt(introKey string) Response {
+	return Response{
+		Actions: []interface{}{
+			va.prompts.Prompt(introKey, nil),
+			Gather{
+				NumDigits: "1",
+				Action:    "/wr/" + va.Name(),
+				Timeout:   "10",
+				Actions: []interface{}{
+					va.prompts.Prompt("voicemail.menu", nil),
+				},
+				SpeechChoices: map[string][]string{
+					"1": {"message", "voicemail", "leave a message"},
+					"2": {"call back", "callback", "call me back"},
+				},
+			},
+			va.prompts.Prompt("common.no_input", nil),
+			Hangup{},
+		},
+	}
+}
+
+func (va *VoicemailApp) recordPrompt(promptKey string, maxLength int) Response {
+	return Response{
+		Actions: []interface{}{
+			va.prompts.Prompt(promptKey, nil),
+			Record{
+				Action:                  "/wr/" + va.Name(),
+				MaxLength:               strconv.Itoa(maxLength),
+				Timeout:                 "5",
+				FinishOnKey:             "#",
+				PlayBeep:                "true",
+				RecordingStatusCallback: RecordingCallbackPath,
+			},
+			va.prompts.Prompt("voicemail.no_recording", nil),
+			Hangup{},
+		},
+	}
+}
+
+func (va *VoicemailApp) sendEvent(session *sessions.Session, params IVRParams, eventType, details string) {
+	sessionID, _ := session.Values["session_id"].(string)
+	callID, _ := session.Values["call_id"].(string)
+
+	sendCallEvent(va.Name(), sessionID, callID, params.CallerNumber, eventType, details)
+}
@@ -0,0 +1,543 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"o-dan-go/events"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// weatherSpeechChoices are the words a caller can say instead of pressing a menu digit
+var weatherSpeechChoices = map[string][]string{
+	"1": {"time", "local time", "clock"},
+	"2": {"temperature", "weather", "temp"},
+	"3": {"air quality", "air", "aqi", "pollution"},
+	"4": {"zip code", "zip", "different location", "somewhere else"},
+}
+
+// WeatherApp is the weather IVR: local time, temperature and air quality for the caller's area code
+type WeatherApp struct {
+	weather WeatherProvider
+	aqi     AQIProvider
+	sms     *SMSFollowUp
+	prompts *Prompts
+}
+
+// NewWeatherApp creates the weather IVR app
+func NewWeatherApp(weatherProvider WeatherProvider, aqiProvider AQIProvider, sms *SMSFollowUp, prompts *Prompts) *WeatherApp {
+	return &WeatherApp{
+		weather: weatherProvider,
+		aqi:     aqiProvider,
+		sms:     sms,
+		prompts: prompts,
+	}
+}
+
+// Name returns the route the app is mounted under (/wr/weather)
+func (wa *WeatherApp) Name() string {
+	return "weather"
+}
+
+// GetLocationFromAreaCode looks up location by area code, guessing one for codes missing
+// from the database and recording them so the data file can be updated
+func (wa *WeatherApp) GetLocationFromAreaCode(areaCode string) (Location, AreaCodeMatch) {
+	location, match, guessedFrom := GlobalAreaCodes.Resolve(areaCode)
+	if match != AreaCodeExact {
+		GlobalAreaCodes.RecordUnknown(areaCode, guessedFrom)
+	}
+	return location, match
+}
+
+// GetWeatherData fetches current conditions for location from the configured provider
+func (wa *WeatherApp) GetWeatherData(lat, lon float64) (WeatherData, error) {
+	return wa.weather.GetWeather(lat, lon)
+}
+
+// GetAirQuality fetches the current AQI for location from the configured provider
+func (wa *WeatherApp) GetAirQuality(lat, lon float64) (AQIReading, error) {
+	return wa.aqi.GetAQI(lat, lon)
+}
+
+// GetLocalTime returns local time for timezone
+func (wa *WeatherApp) GetLocalTime(timezone string) string {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		log.Printf("Error loading timezone %s: %v", timezone, err)
+		return "unknown"
+	}
+
+	now := time.Now().In(loc)
+	return now.Format("3:04 PM")
+}
+
+// GetAQIDescription returns human-readable AQI description
+func (wa *WeatherApp) GetAQIDescription(aqi int) string {
+	return wa.GetAQICategoryDescription(AQICategoryForValue(aqi))
+}
+
+// GetAQICategoryDescription returns the spoken description for an AQI category
+func (wa *WeatherApp) GetAQICategoryDescription(category AQICategory) string {
+	switch category {
+	case AQICategoryGood:
+		return wa.prompts.Text("aqi.good", nil)
+	case AQICategoryModerate:
+		return wa.prompts.Text("aqi.moderate", nil)
+	case AQICategoryUnhealthySensitive:
+		return wa.prompts.Text("aqi.sensitive", nil)
+	case AQICategoryUnhealthy:
+		return wa.prompts.Text("aqi.unhealthy", nil)
+	case AQICategoryVeryUnhealthy:
+		return wa.prompts.Text("aqi.very_unhealthy", nil)
+	case AQICategoryHazardous:
+		return wa.prompts.Text("aqi.hazardous", nil)
+	default:
+		return wa.prompts.Text("aqi.unknown", nil)
+	}
+}
+
+// Handle processes the main weather IVR logic with event logging
+func (wa *WeatherApp) Handle(session *sessions.Session, params IVRParams) (Response, error) {
+	callerNumber := params.CallerNumber
+	digits := params.Digits
+
+	// First call - no digits pressed
+	if digits == "" {
+		log.Printf("[WR] New call from: %s", callerNumber)
+		delete(session.Values, "sms_text")
+		delete(session.Values, "weather_stage")
+
+		areaCode := ExtractAreaCode(callerNumber)
+		location, match := Location{}, AreaCodeUnknown
+		if areaCode == "" {
+			log.Printf("[WR] Could not extract area code from: %s", callerNumber)
+		} else {
+			location, match = wa.GetLocationFromAreaCode(areaCode)
+		}
+
+		// Generate session ID and call ID
+		sessionID := fmt.Sprintf("wr_%s_%d", areaCode, time.Now().Unix())
+		callID := NewCallID(params.Values)
+		if params.CallID != "" {
+			sessionID, callID = params.SessionID, params.CallID
+		}
+
+		// Store in session
+		session.Values["session_id"] = sessionID
+		session.Values["call_id"] = callID
+		session.Values["area_code"] = areaCode
+
+		locationName := "Unknown"
+		if match != AreaCodeUnknown {
+			log.Printf("[WR] Location identified: %s, %s (%s)", location.City, location.State, match)
+			locationName = fmt.Sprintf("%s, %s", location.City, location.State)
+		}
+
+		// Send call started event, unless another app already started the call
+		if params.CallID == "" {
+			events.SendEvent(events.CallEvent{
+				App:       wa.Name(),
+				SessionID: sessionID,
+				CallID:    callID,
+				CallerNum: callerNumber,
+				AreaCode:  areaCode,
+				Location:  eventLocation(locationName, match),
+				EventType: "call_started",
+				Details:   "New incoming call",
+				Timestamp: time.Now(),
+			})
+		}
+
+		// Callers we can't place (no area code, or one we can't guess) can still enter a ZIP code
+		if match == AreaCodeUnknown {
+			introKey := "weather.unknown"
+			if areaCode == "" {
+				introKey = "weather.no_area"
+			}
+			return wa.prompts.Greet(params, wa.zipPrompt(session, introKey, map[string]string{"area_code": areaCode})), nil
+		}
+
+		welcomeKey := "weather.welcome"
+		if match == AreaCodeGuessed {
+			welcomeKey = "weather.welcome_guess"
+		}
+		return wa.prompts.Greet(params, wa.setLocation(session, location, welcomeKey, map[string]string{"area_code": areaCode})), nil
+	}
+
+	// Answer to "press 9 to receive this by text"
+	if wa.sms.Pending(session) {
+		sessionID, _ := session.Values["session_id"].(string)
+		callID, _ := session.Values["call_id"].(string)
+
+		actions := wa.sms.Respond(session, params, "ivr:"+wa.Name())
+		sendCallEvent(wa.Name(), sessionID, callID, callerNumber, "call_ended", "Call completed successfully")
+		return Response{Actions: append(actions, wa.prompts.TimedPrompt("common.goodbye", params, nil), Hangup{})}, nil
+	}
+
+	// Handle menu selection
+	log.Printf("[WR] DTMF received: %s", digits)
+
+	// Get session data
+	callID, _ := session.Values["call_id"].(string)
+	sessionID, _ := session.Values["session_id"].(string)
+	areaCode, _ := session.Values["area_code"].(string)
+
+	// Send DTMF event
+	events.SendEvent(events.CallEvent{
+		App:       wa.Name(),
+		SessionID: sessionID,
+		CallID:    callID,
+		CallerNum: callerNumber,
+		AreaCode:  areaCode,
+		Location:  "", // Will be filled from session
+		EventType: "dtmf_received",
+		Details:   fmt.Sprintf("Pressed %s", digits),
+		Timestamp: time.Now(),
+	})
+
+	// ZIP code entry
+	if stage, _ := session.Values["weather_stage"].(string); stage == "zip" {
+		return wa.handleZIP(session, params), nil
+	}
+
+	locationJSON, ok := session.Values["location_json"].(string)
+	if !ok {
+		log.Printf("[WR] No location in session")
+
+		// Send error event
+		events.SendEvent(events.CallEvent{
+			App:       wa.Name(),
+			SessionID: sessionID,
+			CallID:    callID,
+			CallerNum: callerNumber,
+			AreaCode:  areaCode,
+			Location:  "Unknown",
+			EventType: "error",
+			Details:   "Session expired",
+			Timestamp: time.Now(),
+		})
+
+		response := Response{
+			Actions: []interface{}{
+				wa.prompts.Prompt("common.error", nil),
+				Hangup{},
+			},
+		}
+		return response, nil
+	}
+
+	var location Location
+	json.Unmarshal([]byte(locationJSON), &location)
+
+	vars := map[string]string{
+		"area_code": areaCode,
+		"city":      location.City,
+		"location":  fmt.Sprintf("%s, %s", location.City, location.State),
+	}
+
+	var responseText string
+	var actionDetail string
+
+	switch digits {
+	case "1":
+		log.Printf("[WR] User selected: Local Time")
+		localTime := wa.GetLocalTime(location.Timezone)
+		vars["local_time"] = localTime
+		responseText = wa.prompts.Text("weather.local_time", vars)
+		actionDetail = fmt.Sprintf("Local time: %s", localTime)
+
+	case "2":
+		log.Printf("[WR] User selected: Temperature")
+		weather, err := wa.GetWeatherData(location.Lat, location.Lon)
+		if err != nil {
+			log.Printf("[WR] Weather lookup failed for %s, %s: %v", location.City, location.State, err)
+			responseText = wa.prompts.Text("weather.temp_missing", vars)
+			actionDetail = "Temperature unavailable"
+			break
+		}
+		vars["temperature"] = strconv.Itoa(weather.Temperature)
+		responseText = wa.prompts.Text("weather.temperature", vars)
+		actionDetail = fmt.Sprintf("Temperature: %d°F", weather.Temperature)
+
+	case "3":
+		log.Printf("[WR] User selected: Air Quality")
+		reading, err := wa.GetAirQuality(location.Lat, location.Lon)
+		if err != nil {
+			log.Printf("[WR] AQI lookup failed for %s, %s: %v", location.City, location.State, err)
+			responseText = wa.prompts.Text("weather.aqi_missing", vars)
+			actionDetail = "AQI unavailable"
+			break
+		}
+		aqiDescription := wa.GetAQICategoryDescription(reading.Category)
+		vars["aqi"] = strconv.Itoa(reading.AQI)
+		vars["aqi_description"] = aqiDescription
+		responseText = wa.prompts.Text("weather.aqi", vars)
+		actionDetail = fmt.Sprintf("AQI: %d (%s, %s)", reading.AQI, aqiDescription, reading.Source)
+
+	case "4":
+		log.Printf("[WR] User selected: ZIP Code")
+		return wa.zipPrompt(session, "", nil), nil
+
+	default:
+		log.Printf("[WR] Invalid selection: %s", digits)
+		actionDetail = "Invalid selection"
+
+		// Send invalid selection event
+		events.SendEvent(events.CallEvent{
+			App:       wa.Name(),
+			SessionID: sessionID,
+			CallID:    callID,
+			CallerNum: callerNumber,
+			AreaCode:  areaCode,
+			Location:  fmt.Sprintf("%s, %s", location.City, location.State),
+			EventType: "invalid_selection",
+			Details:   fmt.Sprintf("Invalid digit: %s", digits),
+			Timestamp: time.Now(),
+		})
+
+		// Re-present menu
+		return wa.menu("common.invalid", vars), nil
+	}
+
+	// Send response event for valid selections
+	events.SendEvent(events.CallEvent{
+		App:       wa.Name(),
+		SessionID: sessionID,
+		CallID:    callID,
+		CallerNum: callerNumber,
+		AreaCode:  areaCode,
+		Location:  fmt.Sprintf("%s, %s", location.City, location.State),
+		EventType: "response_sent",
+		Details:   actionDetail,
+		Timestamp: time.Now(),
+	})
+
+	// Send response for valid selections, offering it by text when possible
+	actions := []interface{}{wa.prompts.SayText(responseText)}
+	if wa.sms.Available(callerNumber) {
+		actions = append(actions, wa.sms.Offer(session, "/wr/"+wa.Name(), responseText))
+	} else {
+		// Send call ending event
+		events.SendEvent(events.CallEvent{
+			App:       wa.Name(),
+			SessionID: sessionID,
+			CallID:    callID,
+			CallerNum: callerNumber,
+			AreaCode:  areaCode,
+			Location:  fmt.Sprintf("%s, %s", location.City, location.State),
+			EventType: "call_ended",
+			Details:   "Call completed successfully",
+			Timestamp: time.Now(),
+		})
+	}
+	response := Response{
+		Actions: append(actions,
+			Wait{Timeout: "1"},
+			wa.prompts.TimedPrompt("common.goodbye", params, nil),
+			Hangup{},
+		),
+	}
+
+	log.Printf("[WR] Sending response: %s", responseText)
+	return response, nil
+}
+
+// weatherZIPAttempts is how many ZIP codes a caller can try before the call ends
+const weatherZIPAttempts = 3
+
+// menu speaks the intro prompt, then offers the weather options
+func (wa *WeatherApp) menu(introKey string, vars map[string]string) Response {
+	return Response{
+		Actions: []interface{}{
+			wa.prompts.Prompt(introKey, vars),
+			Gather{
+				NumDigits: "1",
+				Action:    "/wr/weather",
+				Timeout:   "10",
+				Actions: []interface{}{
+					wa.prompts.Prompt("weather.menu", vars),
+				},
+				SpeechChoices: weatherSpeechChoices,
+			},
+			wa.prompts.Prompt("common.no_input", nil),
+			Hangup{},
+		},
+	}
+}
+
+// setLocation stores the caller's location and welcomes them to the menu
+func (wa *WeatherApp) setLocation(session *sessions.Session, location Location, introKey string, vars map[string]string) Response {
+	locationJSON, _ := json.Marshal(location)
+	session.Values["location_json"] = string(locationJSON)
+
+	vars["city"] = location.City
+	vars["location"] = fmt.Sprintf("%s, %s", location.City, location.State)
+	return wa.menu(introKey, vars)
+}
+
+// zipPrompt asks for a 5-digit ZIP code, after an optional intro prompt
+func (wa *WeatherApp) zipPrompt(session *sessions.Session, introKey string, vars map[string]string) Response {
+	session.Values["weather_stage"] = "zip"
+
+	actions := []interface{}{}
+	if introKey != "" {
+		actions = append(actions, wa.prompts.Prompt(introKey, vars))
+	}
+	return Response{
+		Actions: append(actions,
+			Gather{
+				NumDigits: "5",
+				Action:    "/wr/weather",
+				Timeout:   "15",
+				Actions: []interface{}{
+					wa.prompts.Prompt("weather.zip_enter", nil),
+				},
+			},
+			wa.prompts.Prompt("common.no_input", nil),
+			Hangup{},
+		),
+	}
+}
+
+// handleZIP looks up an entered ZIP code, asking again up to weatherZIPAttempts times
+func (wa *WeatherApp) handleZIP(session *sessions.Session, params IVRParams) Response {
+	sessionID, _ := session.Values["session_id"].(string)
+	callID, _ := session.Values["call_id"].(string)
+	zip := params.Digits
+
+	location, exists := Location{}, false
+	if IsZIPCode(zip) {
+		location, exists = GlobalZIPCodes.Lookup(zip)
+	}
+	if !exists {
+		attempts, _ := session.Values["zip_attempts"].(int)
+		attempts++
+		session.Values["zip_attempts"] = attempts
+		log.Printf("[WR] Unknown ZIP code %q (attempt %d)", zip, attempts)
+		sendCallEvent(wa.Name(), sessionID, callID, params.CallerNumber, "invalid_selection", fmt.Sprintf("Unknown ZIP code: %s", zip))
+
+		if attempts >= weatherZIPAttempts {
+			delete(session.Values, "weather_stage")
+			sendCallEvent(wa.Name(), sessionID, callID, params.CallerNumber, "call_ended", "No valid ZIP code entered")
+			return Response{Actions: []interface{}{wa.prompts.Prompt("weather.zip_failed", nil), Hangup{}}}
+		}
+		return wa.zipPrompt(session, "weather.zip_invalid", map[string]string{"zip": SpeakDigits(zip)})
+	}
+
+	delete(session.Values, "weather_stage")
+	delete(session.Values, "zip_attempts")
+	session.Values["zip"] = zip
+	log.Printf("[WR] ZIP code %s: %s, %s", zip, location.City, location.State)
+
+	areaCode, _ := session.Values["area_code"].(string)
+	vars := map[string]string{"area_code": areaCode, "zip": SpeakDigits(zip)}
+	return wa.setLocation(session, location, "weather.zip_found", vars)
+}
+
+// FlowActions exposes the weather lookups as actions for declarative flows.
+// lookup_location sets area_code, city, state, location, timezone and location_guessed;
+// lookup_zip sets zip and the same location variables from the digits the caller entered;
+// local_time, temperature and air_quality set the variable of the same name.
+func (wa *WeatherApp) FlowActions() map[string]FlowAction {
+	return map[string]FlowAction{
+		"lookup_location": func(ctx *FlowContext) error {
+			areaCode := ExtractAreaCode(ctx.Params.CallerNumber)
+			if areaCode == "" {
+				return fmt.Errorf("could not extract area code from %s", ctx.Params.CallerNumber)
+			}
+			ctx.Vars["area_code"] = areaCode
+
+			location, match := wa.GetLocationFromAreaCode(areaCode)
+			if match == AreaCodeUnknown {
+				return fmt.Errorf("area code %s not in database", areaCode)
+			}
+			ctx.Vars["location_guessed"] = ""
+			if match == AreaCodeGuessed {
+				ctx.Vars["location_guessed"] = "true"
+			}
+
+			setFlowLocation(ctx, location)
+			return nil
+		},
+		"lookup_zip": func(ctx *FlowContext) error {
+			zip := ctx.Vars["digits"]
+			ctx.Vars["zip"] = SpeakDigits(zip)
+			location, exists := Location{}, false
+			if IsZIPCode(zip) {
+				location, exists = GlobalZIPCodes.Lookup(zip)
+			}
+			if !exists {
+				return fmt.Errorf("unknown ZIP code %q", zip)
+			}
+
+			ctx.Vars["location_guessed"] = ""
+			setFlowLocation(ctx, location)
+			return nil
+		},
+		"local_time": func(ctx *FlowContext) error {
+			localTime := wa.GetLocalTime(ctx.Vars["timezone"])
+			if localTime == "unknown" {
+				return fmt.Errorf("unknown timezone %q", ctx.Vars["timezone"])
+			}
+			ctx.Vars["local_time"] = localTime
+			return nil
+		},
+		"temperature": func(ctx *FlowContext) error {
+			lat, lon, err := flowCoordinates(ctx)
+			if err != nil {
+				return err
+			}
+			weather, err := wa.GetWeatherData(lat, lon)
+			if err != nil {
+				return err
+			}
+			ctx.Vars["temperature"] = strconv.Itoa(weather.Temperature)
+			return nil
+		},
+		"air_quality": func(ctx *FlowContext) error {
+			lat, lon, err := flowCoordinates(ctx)
+			if err != nil {
+				return err
+			}
+			reading, err := wa.GetAirQuality(lat, lon)
+			if err != nil {
+				return err
+			}
+			ctx.Vars["aqi"] = strconv.Itoa(reading.AQI)
+			ctx.Vars["aqi_description"] = wa.GetAQICategoryDescription(reading.Category)
+			ctx.Vars["aqi_source"] = reading.Source
+			return nil
+		},
+	}
+}
+
+// setFlowLocation sets the location variables flows speak and the weather actions read
+func setFlowLocation(ctx *FlowContext, location Location) {
+	ctx.Vars["city"] = location.City
+	ctx.Vars["state"] = location.State
+	ctx.Vars["location"] = fmt.Sprintf("%s, %s", location.City, location.State)
+	ctx.Vars["timezone"] = location.Timezone
+	ctx.Vars["lat"] = strconv.FormatFloat(location.Lat, 'f', 4, 64)
+	ctx.Vars["lon"] = strconv.FormatFloat(location.Lon, 'f', 4, 64)
+}
+
+// eventLocation labels guessed locations on the dashboard
+func eventLocation(name string, match AreaCodeMatch) string {
+	if match == AreaCodeGuessed {
+		return name + " (guessed)"
+	}
+	return name
+}
+
+// flowCoordinates reads the lat/lon set by lookup_location
+func flowCoordinates(ctx *FlowContext) (float64, float64, error) {
+	lat, latErr := strconv.ParseFloat(ctx.Vars["lat"], 64)
+	lon, lonErr := strconv.ParseFloat(ctx.Vars["lon"], 64)
+	if latErr != nil || lonErr != nil {
+		return 0, 0, fmt.Errorf("location has not been looked up")
+	}
+	return lat, lon, nil
+}
@@ -0,0 +1,915 @@
+// services/jmespath.go
+// A JMESPath evaluator for reading values out of raw CDR JSON, including the nested
+// objects and arrays some deployments return. It covers the commonly used parts of the
+// language: fields, "quoted-fields", indexes, [*] and * projections, [] flattening,
+// [?filter] projections, pipes, comparisons, ||, && and !, 'raw strings', `json`
+// literals and a set of the built-in functions. Multi-select lists and hashes, slices
+// and expression references are not supported.
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxJMESPathLength keeps expressions to a sensible size
+const maxJMESPathLength = 500
+
+// JMESPath is a compiled JMESPath expression
+type JMESPath struct {
+	expr string
+	root jmesNode
+}
+
+// CompileJMESPath parses a JMESPath expression
+func CompileJMESPath(expr string) (*JMESPath, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("jmespath: expression is empty")
+	}
+	if len(expr) > maxJMESPathLength {
+		return nil, fmt.Errorf("jmespath: expression is longer than %d characters", maxJMESPathLength)
+	}
+	tokens, err := lexJMESPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &jmesParser{tokens: tokens}
+	root, err := p.parse(0)
+	if err != nil {
+		return nil, err
+	}
+	if next := p.peek(); next.kind != jmesTokenEnd {
+		return nil, fmt.Errorf("jmespath: unexpected %q at position %d", next.text, next.pos+1)
+	}
+	return &JMESPath{expr: expr, root: root}, nil
+}
+
+// String returns the expression the path was compiled from
+func (j *JMESPath) String() string {
+	return j.expr
+}
+
+// Search evaluates the expression against decoded JSON (maps, slices, strings,
+// numbers, booleans and nil). Values of the wrong type give nil, as does anything
+// that isn't there.
+func (j *JMESPath) Search(data interface{}) interface{} {
+	return j.root.eval(data)
+}
+
+// JMESPathString formats a search result for a table cell: text as is, numbers in
+// full, nil as empty and objects and arrays as JSON
+func JMESPathString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	if number, ok := jmesNumber(value); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// jmesNode is a node of a compiled expression
+type jmesNode interface {
+	eval(data interface{}) interface{}
+}
+
+type jmesCurrent struct{}
+
+func (jmesCurrent) eval(data interface{}) interface{} { return data }
+
+type jmesLiteral struct{ value interface{} }
+
+func (n jmesLiteral) eval(interface{}) interface{} { return n.value }
+
+type jmesField struct{ name string }
+
+func (n jmesField) eval(data interface{}) interface{} {
+	if object, ok := data.(map[string]interface{}); ok {
+		return object[n.name]
+	}
+	return nil
+}
+
+type jmesIndex struct{ index int }
+
+func (n jmesIndex) eval(data interface{}) interface{} {
+	list, ok := data.([]interface{})
+	if !ok {
+		return nil
+	}
+	index := n.index
+	if index < 0 {
+		index += len(list)
+	}
+	if index < 0 || index >= len(list) {
+		return nil
+	}
+	return list[index]
+}
+
+// jmesSubexpression evaluates right on the result of left
+type jmesSubexpression struct{ left, right jmesNode }
+
+func (n jmesSubexpression) eval(data interface{}) interface{} {
+	left := n.left.eval(data)
+	if left == nil {
+		return nil
+	}
+	return n.right.eval(left)
+}
+
+type jmesPipe struct{ left, right jmesNode }
+
+func (n jmesPipe) eval(data interface{}) interface{} { return n.right.eval(n.left.eval(data)) }
+
+// Projection kinds
+const (
+	jmesProjectList    = iota // list[*]
+	jmesProjectObject         // object.*
+	jmesProjectFlatten        // list[]
+	jmesProjectFilter         // list[?condition]
+)
+
+// jmesProjection evaluates right on each element of left, dropping nil results
+type jmesProjection struct {
+	kind      int
+	left      jmesNode
+	condition jmesNode // filter projections only
+	right     jmesNode
+}
+
+func (n jmesProjection) eval(data interface{}) interface{} {
+	left := n.left.eval(data)
+	var elements []interface{}
+	switch n.kind {
+	case jmesProjectObject:
+		object, ok := left.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			elements = append(elements, object[key])
+		}
+	case jmesProjectFlatten:
+		list, ok := left.([]interface{})
+		if !ok {
+			return nil
+		}
+		for _, element := range list {
+			if inner, ok := element.([]interface{}); ok {
+				elements = append(elements, inner...)
+			} else {
+				elements = append(elements, element)
+			}
+		}
+	default:
+		list, ok := left.([]interface{})
+		if !ok {
+			return nil
+		}
+		elements = list
+	}
+
+	projected := []interface{}{}
+	for _, element := range elements {
+		if n.kind == jmesProjectFilter && !jmesTruthy(n.condition.eval(element)) {
+			continue
+		}
+		if value := n.right.eval(element); value != nil {
+			projected = append(projected, value)
+		}
+	}
+	return projected
+}
+
+type jmesOr struct{ left, right jmesNode }
+
+func (n jmesOr) eval(data interface{}) interface{} {
+	if left := n.left.eval(data); jmesTruthy(left) {
+		return left
+	}
+	return n.right.eval(data)
+}
+
+type jmesAnd struct{ left, right jmesNode }
+
+func (n jmesAnd) eval(data interface{}) interface{} {
+	if left := n.left.eval(data); !jmesTruthy(left) {
+		return left
+	}
+	return n.right.eval(data)
+}
+
+type jmesNot struct{ operand jmesNode }
+
+func (n jmesNot) eval(data interface{}) interface{} { return !jmesTruthy(n.operand.eval(data)) }
+
+type jmesComparison struct {
+	op          string
+	left, right jmesNode
+}
+
+func (n jmesComparison) eval(data interface{}) interface{} {
+	left, right := n.left.eval(data), n.right.eval(data)
+	switch n.op {
+	case "==":
+		return jmesEqual(left, right)
+	case "!=":
+		return !jmesEqual(left, right)
+	}
+	a, aOK := jmesNumber(left)
+	b, bOK := jmesNumber(right)
+	if !aOK || !bOK {
+		return nil // ordering only applies to numbers
+	}
+	switch n.op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	default: // >=
+		return a >= b
+	}
+}
+
+type jmesCall struct {
+	name string
+	args []jmesNode
+}
+
+func (n jmesCall) eval(data interface{}) interface{} {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		args[i] = arg.eval(data)
+	}
+	return jmesFunctions[n.name].call(args)
+}
+
+// jmesTruthy follows JMESPath: false, null, "" and empty arrays and objects are false
+func jmesTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// jmesNumber reads a number, whichever Go type it was decoded or built as
+func jmesNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		number, err := v.Float64()
+		return number, err == nil
+	}
+	return 0, false
+}
+
+// jmesEqual compares two values, treating numbers of any Go type alike
+func jmesEqual(a, b interface{}) bool {
+	if x, ok := jmesNumber(a); ok {
+		y, ok := jmesNumber(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// jmesFunction is a built-in function; arity -1 takes one or more arguments
+type jmesFunction struct {
+	arity int
+	call  func(args []interface{}) interface{}
+}
+
+// jmesFunctions are the built-in functions. Arguments of the wrong type give nil.
+var jmesFunctions = map[string]jmesFunction{
+	"length": {1, func(args []interface{}) interface{} {
+		switch v := args[0].(type) {
+		case string:
+			return float64(len([]rune(v)))
+		case []interface{}:
+			return float64(len(v))
+		case map[string]interface{}:
+			return float64(len(v))
+		}
+		return nil
+	}},
+	"join": {2, func(args []interface{}) interface{} {
+		separator, ok := args[0].(string)
+		list, isList := args[1].([]interface{})
+		if !ok || !isList {
+			return nil
+		}
+		parts := make([]string, 0, len(list))
+		for _, element := range list {
+			part, ok := element.(string)
+			if !ok {
+				return nil
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, separator)
+	}},
+	"sum": {1, func(args []interface{}) interface{} {
+		numbers, ok := jmesNumbers(args[0])
+		if !ok {
+			return nil
+		}
+		total := 0.0
+		for _, number := range numbers {
+			total += number
+		}
+		return total
+	}},
+	"avg": {1, func(args []interface{}) interface{} {
+		numbers, ok := jmesNumbers(args[0])
+		if !ok || len(numbers) == 0 {
+			return nil
+		}
+		total := 0.0
+		for _, number := range numbers {
+			total += number
+		}
+		return total / float64(len(numbers))
+	}},
+	"min": {1, func(args []interface{}) interface{} { return jmesExtreme(args[0], -1) }},
+	"max": {1, func(args []interface{}) interface{} { return jmesExtreme(args[0], 1) }},
+	"contains": {2, func(args []interface{}) interface{} {
+		switch subject := args[0].(type) {
+		case string:
+			search, ok := args[1].(string)
+			return ok && strings.Contains(subject, search)
+		case []interface{}:
+			for _, element := range subject {
+				if jmesEqual(element, args[1]) {
+					return true
+				}
+			}
+			return false
+		}
+		return nil
+	}},
+	"starts_with": {2, func(args []interface{}) interface{} {
+		subject, ok := args[0].(string)
+		prefix, isString := args[1].(string)
+		if !ok || !isString {
+			return nil
+		}
+		return strings.HasPrefix(subject, prefix)
+	}},
+	"ends_with": {2, func(args []interface{}) interface{} {
+		subject, ok := args[0].(string)
+		suffix, isString := args[1].(string)
+		if !ok || !isString {
+			return nil
+		}
+		return strings.HasSuffix(subject, suffix)
+	}},
+	"keys": {1, func(args []interface{}) interface{} {
+		object, ok := args[0].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		list := make([]interface{}, len(keys))
+		for i, key := range keys {
+			list[i] = key
+		}
+		return list
+	}},
+	"values": {1, func(args []interface{}) interface{} {
+		object, ok := args[0].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		return jmesProjection{kind: jmesProjectObject, left: jmesCurrent{}, right: jmesCurrent{}}.eval(object)
+	}},
+	"not_null": {-1, func(args []interface{}) interface{} {
+		for _, arg := range args {
+			if arg != nil {
+				return arg
+			}
+		}
+		return nil
+	}},
+	"to_string": {1, func(args []interface{}) interface{} { return JMESPathString(args[0]) }},
+	"to_number": {1, func(args []interface{}) interface{} {
+		if number, ok := jmesNumber(args[0]); ok {
+			return number
+		}
+		if text, ok := args[0].(string); ok {
+			if number, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+				return number
+			}
+		}
+		return nil
+	}},
+	"type": {1, func(args []interface{}) interface{} {
+		switch args[0].(type) {
+		case nil:
+			return "null"
+		case string:
+			return "string"
+		case bool:
+			return "boolean"
+		case []interface{}:
+			return "array"
+		case map[string]interface{}:
+			return "object"
+		}
+		if _, ok := jmesNumber(args[0]); ok {
+			return "number"
+		}
+		return nil
+	}},
+}
+
+// jmesNumbers reads an array of numbers
+func jmesNumbers(value interface{}) ([]float64, bool) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	numbers := make([]float64, 0, len(list))
+	for _, element := range list {
+		number, ok := jmesNumber(element)
+		if !ok {
+			return nil, false
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, true
+}
+
+// jmesExtreme returns the smallest (sign -1) or largest (sign 1) of an array of
+// numbers or of strings
+func jmesExtreme(value interface{}, sign int) interface{} {
+	if numbers, ok := jmesNumbers(value); ok {
+		if len(numbers) == 0 {
+			return nil
+		}
+		best := numbers[0]
+		for _, number := range numbers[1:] {
+			if (sign < 0 && number < best) || (sign > 0 && number > best) {
+				best = number
+			}
+		}
+		return best
+	}
+	list, ok := value.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil
+	}
+	best, ok := list[0].(string)
+	if !ok {
+		return nil
+	}
+	for _, element := range list[1:] {
+		text, ok := element.(string)
+		if !ok {
+			return nil
+		}
+		if cmp := strings.Compare(text, best); cmp*sign > 0 {
+			best = text
+		}
+	}
+	return best
+}
+
+// JMESPath tokens
+const (
+	jmesTokenEnd = iota
+	jmesTokenIdent
+	jmesTokenQuoted  // "quoted identifier"
+	jmesTokenLiteral // 'raw string' or `json`
+	jmesTokenNumber
+	jmesTokenSymbol // operators, brackets and punctuation
+)
+
+type jmesToken struct {
+	kind  int
+	text  string
+	value interface{} // literal value
+	pos   int
+}
+
+// jmesSymbols are the operators and punctuation, longest first
+var jmesSymbols = []string{"[?", "[]", "||", "&&", "==", "!=", "<=", ">=", ".", "*", "[", "]", "(", ")", ",", "|", "!", "<", ">", "@"}
+
+// lexJMESPath splits an expression into tokens
+func lexJMESPath(expr string) ([]jmesToken, error) {
+	tokens := []jmesToken{}
+	for i := 0; i < len(expr); {
+		r := rune(expr[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'' || r == '`':
+			end := jmesStringEnd(expr, i)
+			if end < 0 {
+				return nil, fmt.Errorf("jmespath: unterminated %c at position %d", r, i+1)
+			}
+			token, err := jmesQuotedToken(expr[i:end+1], i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token)
+			i = end + 1
+		case r == '-' || (r >= '0' && r <= '9'):
+			start := i
+			for i++; i < len(expr) && expr[i] >= '0' && expr[i] <= '9'; i++ {
+			}
+			if expr[start:i] == "-" {
+				return nil, fmt.Errorf("jmespath: unexpected \"-\" at position %d; quote field names with hyphens, e.g. \"call-direction\"", start+1)
+			}
+			tokens = append(tokens, jmesToken{kind: jmesTokenNumber, text: expr[start:i], pos: start})
+		case jmesIdentChar(expr[i]) && !(r >= '0' && r <= '9'):
+			start := i
+			for i++; i < len(expr) && jmesIdentChar(expr[i]); i++ {
+			}
+			tokens = append(tokens, jmesToken{kind: jmesTokenIdent, text: expr[start:i], pos: start})
+		default:
+			matched := ""
+			for _, symbol := range jmesSymbols {
+				if strings.HasPrefix(expr[i:], symbol) {
+					matched = symbol
+					break
+				}
+			}
+			if matched == "" {
+				return nil, fmt.Errorf("jmespath: unexpected %q at position %d", string(r), i+1)
+			}
+			tokens = append(tokens, jmesToken{kind: jmesTokenSymbol, text: matched, pos: i})
+			i += len(matched)
+		}
+	}
+	return append(tokens, jmesToken{kind: jmesTokenEnd, text: "end of expression", pos: len(expr)}), nil
+}
+
+// jmesIdentChar reports whether c can be part of an unquoted identifier
+func jmesIdentChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// jmesStringEnd finds the closing quote of the string starting at start, or -1
+func jmesStringEnd(expr string, start int) int {
+	quote := expr[start]
+	for i := start + 1; i < len(expr); i++ {
+		switch expr[i] {
+		case '\\':
+			i++
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+// jmesQuotedToken decodes a "quoted identifier", 'raw string' or `json literal`
+func jmesQuotedToken(quoted string, pos int) (jmesToken, error) {
+	body := quoted[1 : len(quoted)-1]
+	switch quoted[0] {
+	case '"':
+		var name string
+		if err := json.Unmarshal([]byte(quoted), &name); err != nil {
+			return jmesToken{}, fmt.Errorf("jmespath: invalid quoted identifier at position %d: %v", pos+1, err)
+		}
+		return jmesToken{kind: jmesTokenQuoted, text: name, pos: pos}, nil
+	case '\'':
+		text := strings.ReplaceAll(body, `\'`, `'`)
+		return jmesToken{kind: jmesTokenLiteral, text: text, value: text, pos: pos}, nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(strings.ReplaceAll(body, "\\`", "`")), &value); err != nil {
+		return jmesToken{}, fmt.Errorf("jmespath: invalid JSON literal at position %d: %v", pos+1, err)
+	}
+	return jmesToken{kind: jmesTokenLiteral, text: body, value: value, pos: pos}, nil
+}
+
+// jmesBindingPowers order the operators, loosest first
+var jmesBindingPowers = map[string]int{
+	"|": 1, "||": 2, "&&": 3,
+	"==": 5, "!=": 5, "<": 5, "<=": 5, ">": 5, ">=": 5,
+	"[]": 9, "*": 20, "[?": 21, ".": 40, "[": 55, "(": 60,
+}
+
+// jmesNotPower is how tightly ! binds its operand
+const jmesNotPower = 45
+
+// jmesParser parses tokens by precedence climbing, as the JMESPath reference parser does
+type jmesParser struct {
+	tokens []jmesToken
+	next   int
+}
+
+func (p *jmesParser) peek() jmesToken { return p.tokens[p.next] }
+
+func (p *jmesParser) take() jmesToken {
+	token := p.tokens[p.next]
+	if token.kind != jmesTokenEnd {
+		p.next++
+	}
+	return token
+}
+
+// isSymbol reports whether the next token is the symbol s
+func (p *jmesParser) isSymbol(s string) bool {
+	token := p.peek()
+	return token.kind == jmesTokenSymbol && token.text == s
+}
+
+// power is the binding power of the next token
+func (p *jmesParser) power() int {
+	token := p.peek()
+	if token.kind != jmesTokenSymbol {
+		return 0
+	}
+	return jmesBindingPowers[token.text]
+}
+
+// expect takes the symbol s or fails
+func (p *jmesParser) expect(s string) error {
+	if !p.isSymbol(s) {
+		return p.expected(s)
+	}
+	p.take()
+	return nil
+}
+
+// expected describes what the parser wanted instead of the next token
+func (p *jmesParser) expected(what string) error {
+	token := p.peek()
+	return fmt.Errorf("jmespath: expected %s at position %d, found %q", what, token.pos+1, token.text)
+}
+
+func (p *jmesParser) parse(power int) (jmesNode, error) {
+	left, err := p.prefix()
+	if err != nil {
+		return nil, err
+	}
+	for power < p.power() {
+		if left, err = p.infix(left); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+// prefix parses an expression that starts with the next token
+func (p *jmesParser) prefix() (jmesNode, error) {
+	token := p.take()
+	switch token.kind {
+	case jmesTokenIdent:
+		return jmesField{name: token.text}, nil
+	case jmesTokenQuoted:
+		if p.isSymbol("(") {
+			return nil, fmt.Errorf("jmespath: function names can't be quoted at position %d", token.pos+1)
+		}
+		return jmesField{name: token.text}, nil
+	case jmesTokenLiteral:
+		return jmesLiteral{value: token.value}, nil
+	case jmesTokenNumber:
+		return nil, fmt.Errorf("jmespath: number %s at position %d needs backticks to be a literal, e.g. `%s`", token.text, token.pos+1, token.text)
+	case jmesTokenEnd:
+		return nil, fmt.Errorf("jmespath: unexpected end of expression")
+	}
+
+	switch token.text {
+	case "@":
+		return jmesCurrent{}, nil
+	case "*":
+		right, err := p.projectionRHS(jmesBindingPowers["*"])
+		if err != nil {
+			return nil, err
+		}
+		return jmesProjection{kind: jmesProjectObject, left: jmesCurrent{}, right: right}, nil
+	case "[":
+		return p.bracket(jmesCurrent{})
+	case "[]":
+		right, err := p.projectionRHS(jmesBindingPowers["[]"])
+		if err != nil {
+			return nil, err
+		}
+		return jmesProjection{kind: jmesProjectFlatten, left: jmesCurrent{}, right: right}, nil
+	case "[?":
+		return p.filter(jmesCurrent{})
+	case "!":
+		operand, err := p.parse(jmesNotPower)
+		if err != nil {
+			return nil, err
+		}
+		return jmesNot{operand: operand}, nil
+	case "(":
+		inner, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+	return nil, fmt.Errorf("jmespath: unexpected %q at position %d", token.text, token.pos+1)
+}
+
+// infix parses the operator after left
+func (p *jmesParser) infix(left jmesNode) (jmesNode, error) {
+	token := p.take()
+	power := jmesBindingPowers[token.text]
+	switch token.text {
+	case ".":
+		if p.isSymbol("*") {
+			p.take()
+			right, err := p.projectionRHS(jmesBindingPowers["*"])
+			if err != nil {
+				return nil, err
+			}
+			return jmesProjection{kind: jmesProjectObject, left: left, right: right}, nil
+		}
+		right, err := p.dotRHS(power)
+		if err != nil {
+			return nil, err
+		}
+		return jmesSubexpression{left: left, right: right}, nil
+	case "[":
+		return p.bracket(left)
+	case "[]":
+		right, err := p.projectionRHS(power)
+		if err != nil {
+			return nil, err
+		}
+		return jmesProjection{kind: jmesProjectFlatten, left: left, right: right}, nil
+	case "[?":
+		return p.filter(left)
+	case "(":
+		field, ok := left.(jmesField)
+		if !ok {
+			return nil, fmt.Errorf("jmespath: unexpected \"(\" at position %d", token.pos+1)
+		}
+		return p.call(field.name, token.pos)
+	}
+
+	if power < 1 || power > 5 {
+		return nil, fmt.Errorf("jmespath: unexpected %q at position %d", token.text, token.pos+1)
+	}
+	right, err := p.parse(power)
+	if err != nil {
+		return nil, err
+	}
+	switch token.text {
+	case "|":
+		return jmesPipe{left: left, right: right}, nil
+	case "||":
+		return jmesOr{left: left, right: right}, nil
+	case "&&":
+		return jmesAnd{left: left, right: right}, nil
+	}
+	return jmesComparison{op: token.text, left: left, right: right}, nil
+}
+
+// bracket parses [n] or [*] after left; the [ has been taken
+func (p *jmesParser) bracket(left jmesNode) (jmesNode, error) {
+	if token := p.peek(); token.kind == jmesTokenNumber {
+		p.take()
+		index, err := strconv.Atoi(token.text)
+		if err != nil || index > math.MaxInt32 || index < math.MinInt32 {
+			return nil, fmt.Errorf("jmespath: invalid index %s at position %d", token.text, token.pos+1)
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		return jmesSubexpression{left: left, right: jmesIndex{index: index}}, nil
+	}
+	if p.isSymbol("*") {
+		p.take()
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		right, err := p.projectionRHS(jmesBindingPowers["*"])
+		if err != nil {
+			return nil, err
+		}
+		return jmesProjection{kind: jmesProjectList, left: left, right: right}, nil
+	}
+	return nil, p.expected("an index or * in [ ]")
+}
+
+// filter parses [?condition] after left; the [? has been taken
+func (p *jmesParser) filter(left jmesNode) (jmesNode, error) {
+	condition, err := p.parse(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	right, err := p.projectionRHS(jmesBindingPowers["[?"])
+	if err != nil {
+		return nil, err
+	}
+	return jmesProjection{kind: jmesProjectFilter, left: left, condition: condition, right: right}, nil
+}
+
+// dotRHS parses the field after a dot
+func (p *jmesParser) dotRHS(power int) (jmesNode, error) {
+	if token := p.peek(); token.kind != jmesTokenIdent && token.kind != jmesTokenQuoted {
+		return nil, p.expected("a field name after .")
+	}
+	return p.parse(power)
+}
+
+// projectionRHS parses what a projection applies to each element, which is the
+// element itself when the projection ends the expression or is followed by an operator
+func (p *jmesParser) projectionRHS(power int) (jmesNode, error) {
+	switch {
+	case p.power() < 10:
+		return jmesCurrent{}, nil
+	case p.isSymbol("["), p.isSymbol("[?"):
+		return p.parse(power)
+	case p.isSymbol("."):
+		p.take()
+		return p.dotRHS(power)
+	}
+	return nil, p.expected("., [ or an operator after a projection")
+}
+
+// call parses a function's arguments; the ( has been taken
+func (p *jmesParser) call(name string, pos int) (jmesNode, error) {
+	function, ok := jmesFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("jmespath: unknown function %s at position %d", name, pos+1)
+	}
+	args := []jmesNode{}
+	for !p.isSymbol(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parse(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.take()
+	if (function.arity >= 0 && len(args) != function.arity) || len(args) == 0 {
+		return nil, fmt.Errorf("jmespath: %s takes %s, got %d", name, jmesArity(function.arity), len(args))
+	}
+	return jmesCall{name: name, args: args}, nil
+}
+
+// jmesArity describes how many arguments a function takes
+func jmesArity(arity int) string {
+	switch arity {
+	case -1:
+		return "at least 1 argument"
+	case 1:
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", arity)
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestJMESPath(t *testing.T) {
+	var cdr models.FlexibleCDR
+	if err := json.Unmarshal([]byte(`{
+		"id": "cdr-1",
+		"call-orig-caller-id": 15555551234,
+		"legs": [
+			{"type": "orig", "number": "15555551234", "duration": 12, "carrier": {"name": "Acme Tel"}},
+			{"type": "term", "number": "15555559876", "duration": 95, "carrier": {"name": "Globex"}}
+		],
+		"tags": ["vip", "billing"],
+		"sip": {"headers": {"x-account": "A-17", "x-region": "us-east"}},
+		"nested": [[1, 2], [3]]
+	}`), &cdr); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	for expr, want := range map[string]string{
+		`id`:                    "cdr-1",
+		`"call-orig-caller-id"`: "15555551234",
+		`legs[0].carrier.name`:  "Acme Tel",
+		`legs[-1].number`:       "15555559876",
+		`legs[*].type`:          `["orig","term"]`,
+		`legs[?type == 'term'].carrier.name | [0]`:         "Globex",
+		`join(',', tags)`:                                  "vip,billing",
+		`sum(legs[*].duration)`:                            "107",
+		`max(legs[*].duration) > ` + "`60`":                "true",
+		`sip.headers."x-account"`:                          "A-17",
+		`sip.headers.* | length(@)`:                        "2",
+		`nested[]`:                                         "[1,2,3]",
+		`length(legs[?duration > ` + "`30`" + `])`:         "1",
+		`missing.field`:                                    "",
+		`not_null(missing, tags[0])`:                       "vip",
+		`contains(tags, 'vip') && !contains(tags, 'spam')`: "true",
+		`to_number(legs[0].number)`:                        "15555551234",
+	} {
+		path, err := CompileJMESPath(expr)
+		if err != nil {
+			t.Errorf("CompileJMESPath(%s): %v", expr, err)
+			continue
+		}
+		if got := JMESPathString(path.Search(cdr.RawData)); got != want {
+			t.Errorf("%s = %q, want %q", expr, got, want)
+		}
+	}
+
+	for expr, message := range map[string]string{
+		`call-direction`: "quote field names with hyphens",
+		`legs[`:          "expected an index",
+		`length(a, b)`:   "takes 1 argument",
+		`upper(id)`:      "unknown function",
+		`legs[0].`:       "expected a field name",
+		`duration > 60`:  "needs backticks",
+		`'unterminated`:  "unterminated",
+		`id id`:          "unexpected",
+	} {
+		if _, err := CompileJMESPath(expr); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("CompileJMESPath(%s) = %v, want an error mentioning %q", expr, err, message)
+		}
+	}
+}
+
+func TestExportFieldPreferences(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "prefs.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	prefs := DefaultUserPreferences("user-1")
+	prefs.ExportFields = []ExportField{{Name: "term_carrier_name", Expression: "legs[-1].carrier.name"}}
+	if err := db.SaveUserPreferences(prefs); err != nil {
+		t.Fatalf("SaveUserPreferences: %v", err)
+	}
+
+	stored, err := db.GetUserPreferences("user-1")
+	if err != nil || len(stored.ExportFields) != 1 {
+		t.Fatalf("GetUserPreferences = %+v, %v", stored, err)
+	}
+	if columns := stored.Columns(); columns[len(columns)-1] != "term_carrier_name" {
+		t.Errorf("Columns() = %v, want the export field last", columns)
+	}
+	if defaults := DefaultUserPreferences("user-1").VisibleColumns; !reflect.DeepEqual(stored.VisibleColumns, defaults) {
+		t.Errorf("VisibleColumns = %v, want the defaults %v", stored.VisibleColumns, defaults)
+	}
+	// Saving what was loaded again keeps the defaults rather than failing validation
+	if err := db.SaveUserPreferences(stored); err != nil {
+		t.Fatalf("re-saving loaded preferences: %v", err)
+	}
+	if stored, err = db.GetUserPreferences("user-1"); err != nil || stored.ColumnsChosen || len(stored.VisibleColumns) == 0 {
+		t.Fatalf("preferences after re-saving = %+v, %v; want the default columns", stored, err)
+	}
+	// Without chosen columns, CSV exports keep the full standard set
+	if columns := stored.CSVColumns(); stored.ColumnsChosen || len(columns) != len(DefaultCSVColumns)+1 {
+		t.Errorf("CSVColumns() = %v, want the %d default CSV columns and the export field", columns, len(DefaultCSVColumns))
+	}
+	chosen := *stored
+	chosen.VisibleColumns, chosen.ColumnsChosen = []string{"domain", "call_id"}, true
+	if err := db.SaveUserPreferences(&chosen); err != nil {
+		t.Fatalf("SaveUserPreferences: %v", err)
+	}
+	if stored, _ := db.GetUserPreferences("user-1"); !stored.ColumnsChosen || len(stored.CSVColumns()) != 3 {
+		t.Errorf("chosen CSV columns = %v, want the two chosen and the export field", stored.CSVColumns())
+	}
+	cdr := models.FlexibleCDR{RawData: map[string]interface{}{
+		"legs": []interface{}{map[string]interface{}{"carrier": map[string]interface{}{"name": "Globex"}}},
+	}}
+	if value := stored.ExportField("term_carrier_name").Value(&cdr); value != "Globex" {
+		t.Errorf("export field value = %q", value)
+	}
+
+	for _, field := range []ExportField{
+		{Name: "domain", Expression: "legs[0]"},
+		{Name: "Bad Name", Expression: "legs[0]"},
+		{Name: "broken", Expression: "legs[0"},
+	} {
+		prefs.ExportFields = []ExportField{field}
+		if err := db.SaveUserPreferences(prefs); err == nil {
+			t.Errorf("saved export field %+v", field)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+// services/keyword_alerts.go
+// Keyword spotting on call content: admins keep lists of phrases ("cancel my account",
+// "lawsuit"), new transcripts are scanned for them, and matches raise alerts with a
+// link to the CDR and an excerpt of the transcript
+
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// keywordExcerptContext is how much transcript is kept either side of a match
+const keywordExcerptContext = 80
+
+// ErrKeywordListNotFound is returned when updating a list that doesn't exist
+var ErrKeywordListNotFound = errors.New("keyword list not found")
+
+// KeywordList is a named set of phrases to alert on
+type KeywordList struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Keywords  []string  `json:"keywords"`
+	Domain    string    `json:"domain,omitempty"` // "" scans every domain
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks the list and normalizes its keywords
+func (kl *KeywordList) Validate() error {
+	kl.Name = strings.TrimSpace(kl.Name)
+	if kl.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+
+	seen := make(map[string]bool)
+	keywords := []string{}
+	for _, keyword := range kl.Keywords {
+		keyword = strings.ToLower(strings.Join(strings.Fields(keyword), " "))
+		if keyword == "" || seen[keyword] {
+			continue
+		}
+		seen[keyword] = true
+		keywords = append(keywords, keyword)
+	}
+	if len(keywords) == 0 {
+		return fmt.Errorf("at least one keyword is required")
+	}
+	kl.Keywords = keywords
+	kl.Domain = strings.TrimSpace(kl.Domain)
+	return nil
+}
+
+// KeywordAlert is a transcript that matched a keyword list
+type KeywordAlert struct {
+	ID            int64     `json:"id"`
+	ListID        int64     `json:"list_id"`
+	ListName      string    `json:"list_name"`
+	TranscriptID  int64     `json:"transcript_id"`
+	CDRID         string    `json:"cdr_id"`
+	SessionID     string    `json:"session_id,omitempty"`
+	Domain        string    `json:"domain"`
+	Keywords      []string  `json:"keywords"`
+	Excerpt       string    `json:"excerpt"`
+	TranscriptURL string    `json:"transcript_url"`
+	CDRURL        string    `json:"cdr_url,omitempty"` // search results the CDR was archived from
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// AlertSummary describes the alert in one line
+func (ka KeywordAlert) AlertSummary() string {
+	return fmt.Sprintf("Keyword alert (%s): %s on CDR %s", ka.ListName, strings.Join(ka.Keywords, ", "), ka.CDRID)
+}
+
+// AlertDetails lists what matched, ending with the excerpt
+func (ka KeywordAlert) AlertDetails() []string {
+	return []string{
+		"Keywords: " + strings.Join(ka.Keywords, ", "),
+		"Domain: " + ka.Domain,
+		"CDR: " + ka.CDRID,
+		`"` + ka.Excerpt + `"`,
+	}
+}
+
+// AlertLinks links the transcript and the search results
+func (ka KeywordAlert) AlertLinks() []AlertLink {
+	links := []AlertLink{{Label: "Transcript", URL: ka.TranscriptURL}}
+	if ka.CDRURL != "" {
+		links = append(links, AlertLink{Label: "Search results", URL: ka.CDRURL})
+	}
+	return links
+}
+
+// KeywordSpotter scans transcripts against the keyword lists and sends alerts
+type KeywordSpotter struct {
+	db          *DatabaseService
+	notifiers   []AlertNotifier
+	linkBaseURL string
+}
+
+// NewKeywordSpotter creates a spotter; links in alerts start with linkBaseURL, the
+// public URL of this server ("" leaves them relative)
+func NewKeywordSpotter(db *DatabaseService, notifiers []AlertNotifier, linkBaseURL string) *KeywordSpotter {
+	return &KeywordSpotter{
+		db:          db,
+		notifiers:   notifiers,
+		linkBaseURL: strings.TrimRight(linkBaseURL, "/"),
+	}
+}
+
+// SpotKeywords has the worker scan new transcripts with spotter. Call it before Start.
+func (tw *TranscriptionWorker) SpotKeywords(spotter *KeywordSpotter) {
+	tw.spotter = spotter
+}
+
+// Scan checks a transcript against every enabled list, stores an alert for each list
+// that matches and sends it. A transcript alerts at most once per list.
+func (ks *KeywordSpotter) Scan(transcript Transcript) ([]KeywordAlert, error) {
+	if transcript.Text == "" {
+		return nil, nil
+	}
+	lists, err := ks.db.GetKeywordLists()
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := []KeywordAlert{}
+	for _, list := range lists {
+		if list.Disabled || (list.Domain != "" && !strings.EqualFold(list.Domain, transcript.Domain)) {
+			continue
+		}
+		keywords, excerpt := matchKeywords(transcript.Text, list.Keywords)
+		if len(keywords) == 0 {
+			continue
+		}
+
+		alert := KeywordAlert{
+			ListID:        list.ID,
+			ListName:      list.Name,
+			TranscriptID:  transcript.ID,
+			CDRID:         transcript.CDRID,
+			Domain:        transcript.Domain,
+			Keywords:      keywords,
+			Excerpt:       excerpt,
+			TranscriptURL: ks.linkBaseURL + "/api/v1/transcripts/" + url.PathEscape(transcript.CDRID),
+		}
+		created, err := ks.db.SaveKeywordAlert(&alert, transcript.ArchivedRecordingID, ks.linkBaseURL)
+		if err != nil {
+			return alerts, err
+		}
+		if !created {
+			continue
+		}
+		log.Printf("[Alerts] %s matched %s on CDR %s", list.Name, strings.Join(keywords, ", "), alert.CDRID)
+
+		for _, notifier := range ks.notifiers {
+			if err := notifier.Notify(alert); err != nil {
+				log.Printf("[Alerts] Failed to send alert %d by %s: %v", alert.ID, notifier.Name(), err)
+			}
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// matchKeywords returns the keywords found in text, as whole words in any case and
+// with any punctuation between them, and an excerpt around the first match
+func matchKeywords(text string, keywords []string) ([]string, string) {
+	matched := []string{}
+	first := []int(nil)
+	for _, keyword := range keywords {
+		words := strings.Fields(keyword)
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		pattern, err := regexp.Compile(`(?i)\b` + strings.Join(words, `[\s,.;:!?"-]+`) + `\b`)
+		if err != nil {
+			continue
+		}
+		loc := pattern.FindStringIndex(text)
+		if loc == nil {
+			continue
+		}
+		matched = append(matched, keyword)
+		if first == nil || loc[0] < first[0] {
+			first = loc
+		}
+	}
+	if first == nil {
+		return nil, ""
+	}
+	return matched, excerptAround(text, first[0], first[1])
+}
+
+// excerptAround cuts the text around [start, end) at word boundaries
+func excerptAround(text string, start, end int) string {
+	from, prefix := 0, ""
+	if start > keywordExcerptContext {
+		from, prefix = start-keywordExcerptContext, "..."
+		if space := strings.IndexByte(text[from:start], ' '); space >= 0 {
+			from += space + 1
+		}
+		for from < start && !utf8.RuneStart(text[from]) {
+			from++
+		}
+	}
+	to, suffix := len(text), ""
+	if len(text)-end > keywordExcerptContext {
+		to, suffix = end+keywordExcerptContext, "..."
+		if space := strings.LastIndexByte(text[end:to], ' '); space >= 0 {
+			to = end + space
+		}
+		for to > end && !utf8.RuneStart(text[to]) {
+			to--
+		}
+	}
+	return prefix + strings.TrimSpace(text[from:to]) + suffix
+}
+
+// SaveKeywordList creates a list, or replaces the one with its ID
+func (ds *DatabaseService) SaveKeywordList(list *KeywordList) error {
+	if err := list.Validate(); err != nil {
+		return err
+	}
+	keywords, err := json.Marshal(list.Keywords)
+	if err != nil {
+		return fmt.Errorf("failed to encode keywords: %w", err)
+	}
+	now := time.Now().UTC()
+
+	if list.ID == 0 {
+		err = ds.db.QueryRow(`
+		INSERT INTO keyword_lists (name, keywords, domain, disabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id, created_at, updated_at`,
+			list.Name, string(keywords), list.Domain, list.Disabled, now, now,
+		).Scan(&list.ID, &list.CreatedAt, &list.UpdatedAt)
+	} else {
+		err = ds.db.QueryRow(`
+		UPDATE keyword_lists SET name = ?, keywords = ?, domain = ?, disabled = ?, updated_at = ?
+		WHERE id = ?
+		RETURNING created_at, updated_at`,
+			list.Name, string(keywords), list.Domain, list.Disabled, now, list.ID,
+		).Scan(&list.CreatedAt, &list.UpdatedAt)
+		if err == sql.ErrNoRows {
+			return ErrKeywordListNotFound
+		}
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return fmt.Errorf("a keyword list named %q already exists", list.Name)
+		}
+		return fmt.Errorf("failed to save keyword list: %w", err)
+	}
+	return nil
+}
+
+// GetKeywordLists returns every keyword list by name
+func (ds *DatabaseService) GetKeywordLists() ([]KeywordList, error) {
+	rows, err := ds.db.Query(`
+	SELECT id, name, keywords, COALESCE(domain, ''), disabled, created_at, updated_at
+	FROM keyword_lists ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get keyword lists: %w", err)
+	}
+	defer rows.Close()
+
+	lists := []KeywordList{}
+	for rows.Next() {
+		var list KeywordList
+		var keywords string
+		if err := rows.Scan(&list.ID, &list.Name, &keywords, &list.Domain, &list.Disabled, &list.CreatedAt, &list.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(keywords), &list.Keywords); err != nil {
+			return nil, fmt.Errorf("invalid keywords in list %s: %w", list.Name, err)
+		}
+		lists = append(lists, list)
+	}
+	return lists, rows.Err()
+}
+
+// DeleteKeywordList removes a list by ID, reporting whether it existed. Its alerts are kept.
+func (ds *DatabaseService) DeleteKeywordList(id int64) (bool, error) {
+	result, err := ds.db.Exec(`DELETE FROM keyword_lists WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete keyword list: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// SaveKeywordAlert stores an alert, filling in the search session the recording was
+// archived from, and reports false if the transcript already alerted for the list
+func (ds *DatabaseService) SaveKeywordAlert(alert *KeywordAlert, archivedRecordingID int64, linkBaseURL string) (bool, error) {
+	var sessionID sql.NullString
+	err := ds.db.QueryRow(`SELECT session_id FROM archived_recordings WHERE id = ?`, archivedRecordingID).Scan(&sessionID)
+	if err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("failed to look up archived recording: %w", err)
+	}
+	if sessionID.String != "" {
+		alert.SessionID = sessionID.String
+		alert.CDRURL = linkBaseURL + "/web/results/" + url.PathEscape(sessionID.String)
+	}
+
+	keywords, err := json.Marshal(alert.Keywords)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode keywords: %w", err)
+	}
+	alert.CreatedAt = time.Now().UTC()
+
+	err = ds.db.QueryRow(`
+	INSERT INTO keyword_alerts (
+		list_id, list_name, transcript_id, cdr_id, session_id, domain, keywords, excerpt,
+		transcript_url, cdr_url, created_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(list_id, transcript_id) DO NOTHING
+	RETURNING id`,
+		alert.ListID, alert.ListName, alert.TranscriptID, alert.CDRID, alert.SessionID, alert.Domain,
+		string(keywords), alert.Excerpt, alert.TranscriptURL, alert.CDRURL, alert.CreatedAt,
+	).Scan(&alert.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to save keyword alert: %w", err)
+	}
+	return true, nil
+}
+
+// GetKeywordAlerts returns the most recent alerts, optionally for one CDR
+func (ds *DatabaseService) GetKeywordAlerts(cdrID string, limit int) ([]KeywordAlert, error) {
+	query := `
+	SELECT id, list_id, list_name, transcript_id, cdr_id, COALESCE(session_id, ''), COALESCE(domain, ''),
+		keywords, excerpt, transcript_url, COALESCE(cdr_url, ''), created_at
+	FROM keyword_alerts`
+	args := []interface{}{}
+	if cdrID != "" {
+		query += " WHERE cdr_id = ?"
+		args = append(args, cdrID)
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := ds.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get keyword alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []KeywordAlert{}
+	for rows.Next() {
+		var alert KeywordAlert
+		var keywords string
+		if err := rows.Scan(&alert.ID, &alert.ListID, &alert.ListName, &alert.TranscriptID, &alert.CDRID,
+			&alert.SessionID, &alert.Domain, &keywords, &alert.Excerpt, &alert.TranscriptURL, &alert.CDRURL,
+			&alert.CreatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(keywords), &alert.Keywords)
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
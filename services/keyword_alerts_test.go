@@ -0,0 +1,83 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchKeywords(t *testing.T) {
+	text := strings.Repeat("filler words here ", 10) + "Honestly, I want to CANCEL, my account today. " + strings.Repeat("more words after ", 10)
+	keywords, excerpt := matchKeywords(text, []string{"lawsuit", "cancel my account", "account"})
+	if len(keywords) != 2 || keywords[0] != "cancel my account" || keywords[1] != "account" {
+		t.Fatalf("keywords = %v", keywords)
+	}
+	if !strings.HasPrefix(excerpt, "...") || !strings.HasSuffix(excerpt, "...") || !strings.Contains(excerpt, "CANCEL, my account") {
+		t.Fatalf("excerpt = %q", excerpt)
+	}
+	if keywords, _ := matchKeywords("we discussed the accountant", []string{"account"}); len(keywords) != 0 {
+		t.Fatalf("matched part of a word: %v", keywords)
+	}
+}
+
+func TestKeywordSpotterAlerts(t *testing.T) {
+	var received []KeywordAlert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert KeywordAlert
+		json.NewDecoder(r.Body).Decode(&alert)
+		received = append(received, alert)
+	}))
+	defer server.Close()
+
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "alerts.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.SaveKeywordList(&KeywordList{Name: "Empty", Keywords: []string{" "}}); err == nil {
+		t.Fatal("expected an error for a list without keywords")
+	}
+	for _, list := range []*KeywordList{
+		{Name: "Churn", Keywords: []string{"Cancel  my account", "cancel my account"}},
+		{Name: "Legal", Keywords: []string{"lawsuit"}, Domain: "other"},
+	} {
+		if err := db.SaveKeywordList(list); err != nil {
+			t.Fatalf("SaveKeywordList: %v", err)
+		}
+	}
+
+	recording := &ArchivedRecording{CDRID: "cdr-1", RecordingID: "rec", SessionID: "session-1", Domain: "acme", Storage: "local", Key: "k", ArchivedAt: time.Now()}
+	if err := db.SaveArchivedRecording(recording); err != nil {
+		t.Fatalf("SaveArchivedRecording: %v", err)
+	}
+	transcript := &Transcript{
+		ArchivedRecordingID: recording.ID, CDRID: "cdr-1", Domain: "acme", Backend: "whisper",
+		Text: "Please cancel my account or I will file a lawsuit.", Status: TranscriptCompleted,
+	}
+	if err := db.SaveTranscript(transcript); err != nil {
+		t.Fatalf("SaveTranscript: %v", err)
+	}
+
+	spotter := NewKeywordSpotter(db, NewAlertNotifiers(AlertSettings{WebhookURL: server.URL}), "https://odango.example.com/")
+	alerts, err := spotter.Scan(*transcript)
+	if err != nil || len(alerts) != 1 {
+		t.Fatalf("Scan = %+v, %v; want only the Churn list (Legal is for another domain)", alerts, err)
+	}
+	if len(received) != 1 || received[0].ListName != "Churn" ||
+		received[0].TranscriptURL != "https://odango.example.com/api/v1/transcripts/cdr-1" ||
+		received[0].CDRURL != "https://odango.example.com/web/results/session-1" {
+		t.Fatalf("webhook received %+v", received)
+	}
+
+	if alerts, _ := spotter.Scan(*transcript); len(alerts) != 0 || len(received) != 1 {
+		t.Fatalf("transcript alerted twice for the same list")
+	}
+	if stored, err := db.GetKeywordAlerts("cdr-1", 10); err != nil || len(stored) != 1 || stored[0].Keywords[0] != "cancel my account" {
+		t.Fatalf("GetKeywordAlerts = %+v, %v", stored, err)
+	}
+}
@@ -0,0 +1,276 @@
+// services/masking.go
+// PII masking for phone numbers and caller IDs in exports and the results UI
+
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"o-dan-go/models"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MaskingMode controls how phone numbers and caller IDs are presented
+type MaskingMode string
+
+const (
+	MaskingNone     MaskingMode = "none"     // Show values unchanged
+	MaskingTruncate MaskingMode = "truncate" // Keep only the last 4 digits
+	MaskingHash     MaskingMode = "hash"     // Replace with a keyed hash (stable for joins)
+)
+
+// maskingStrictness orders modes so policies can only ever tighten masking
+var maskingStrictness = map[MaskingMode]int{
+	MaskingNone:     0,
+	MaskingTruncate: 1,
+	MaskingHash:     2,
+}
+
+// piiFields are CDR fields known to carry subscriber phone numbers or caller IDs
+var piiFields = []string{
+	"call-orig-caller-id",
+	"call-term-caller-id",
+	"call-orig-to-user",
+	"call-orig-from-user",
+	"call-orig-request-user",
+	"call-term-to-user",
+	"call-orig-from-uri",
+	"call-orig-to-uri",
+	"call-orig-request-uri",
+	"call-term-to-uri",
+	"orig-number",
+	"term-number",
+	"ani",
+	"dnis",
+}
+
+// piiFieldPatterns catch deployment-specific fields that follow the same naming
+var piiFieldPatterns = []string{"caller-id", "-number", "-uri"}
+
+// piiQueryParams are URL parameters that carry phone numbers
+var piiQueryParams = []string{"orig_number", "term_number"}
+
+var nonDigits = regexp.MustCompile(`[^0-9]`)
+
+// ParseMaskingMode converts a string to a MaskingMode
+func ParseMaskingMode(value string) (MaskingMode, error) {
+	mode := MaskingMode(strings.ToLower(strings.TrimSpace(value)))
+	if mode == "" {
+		return MaskingNone, nil
+	}
+	if _, ok := maskingStrictness[mode]; !ok {
+		return MaskingNone, fmt.Errorf("unknown masking mode: %s", value)
+	}
+	return mode, nil
+}
+
+// StricterMaskingMode returns whichever of the two modes hides more
+func StricterMaskingMode(a, b MaskingMode) MaskingMode {
+	if maskingStrictness[b] > maskingStrictness[a] {
+		return b
+	}
+	return a
+}
+
+// MaskingPolicy maps roles to masking modes for a deployment. A request only has a role
+// when it presents that role's token.
+type MaskingPolicy struct {
+	DefaultMode MaskingMode
+	RoleModes   map[string]MaskingMode
+	RoleTokens  map[string]string // token to role
+	Salt        string
+}
+
+// NewMaskingPolicy builds a policy from config values.
+// roleSpec has the form "viewer:hash,analyst:truncate,admin:none".
+func NewMaskingPolicy(defaultMode, roleSpec, salt string) (*MaskingPolicy, error) {
+	mode, err := ParseMaskingMode(defaultMode)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &MaskingPolicy{
+		DefaultMode: mode,
+		RoleModes:   make(map[string]MaskingMode),
+		Salt:        salt,
+	}
+
+	for _, entry := range strings.Split(roleSpec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid masking role entry: %s", entry)
+		}
+		roleMode, err := ParseMaskingMode(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		policy.RoleModes[strings.TrimSpace(parts[0])] = roleMode
+	}
+
+	return policy, nil
+}
+
+// BindRoleTokens sets the tokens that grant roles.
+// tokenSpec has the form "analyst:<token>,admin:<token>".
+func (mp *MaskingPolicy) BindRoleTokens(tokenSpec string) error {
+	mp.RoleTokens = make(map[string]string)
+	for _, entry := range strings.Split(tokenSpec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		role, token := strings.TrimSpace(parts[0]), ""
+		if len(parts) == 2 {
+			token = strings.TrimSpace(parts[1])
+		}
+		if role == "" || token == "" {
+			return fmt.Errorf("invalid masking role token entry for role %q", role)
+		}
+		mp.RoleTokens[token] = role
+	}
+	return nil
+}
+
+// RoleForToken returns the role a token grants, or "" for none
+func (mp *MaskingPolicy) RoleForToken(token string) string {
+	role := ""
+	if token == "" {
+		return role
+	}
+	// Compare against every token so timing doesn't reveal which one matched
+	for candidate, candidateRole := range mp.RoleTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+			role = candidateRole
+		}
+	}
+	return role
+}
+
+// ModeForRole returns the masking mode enforced for a role
+func (mp *MaskingPolicy) ModeForRole(role string) MaskingMode {
+	if mode, ok := mp.RoleModes[role]; ok && role != "" {
+		return mode
+	}
+	return mp.DefaultMode
+}
+
+// MaskValue masks a single phone number or caller ID
+func (mp *MaskingPolicy) MaskValue(value string, mode MaskingMode) string {
+	if value == "" || mode == MaskingNone {
+		return value
+	}
+
+	switch mode {
+	case MaskingTruncate:
+		digits := nonDigits.ReplaceAllString(value, "")
+		if len(digits) <= 4 {
+			return strings.Repeat("*", len(digits))
+		}
+		return strings.Repeat("*", len(digits)-4) + digits[len(digits)-4:]
+	case MaskingHash:
+		mac := hmac.New(sha256.New, []byte(mp.Salt))
+		mac.Write([]byte(nonDigits.ReplaceAllString(value, "")))
+		return "h:" + hex.EncodeToString(mac.Sum(nil))[:16]
+	}
+
+	return value
+}
+
+// MaskCDR returns a copy of the CDR with PII fields masked
+func (mp *MaskingPolicy) MaskCDR(cdr models.FlexibleCDR, mode MaskingMode) models.FlexibleCDR {
+	if mode == MaskingNone || cdr.RawData == nil {
+		return cdr
+	}
+
+	masked := models.FlexibleCDR{
+		RawData:        make(map[string]interface{}, len(cdr.RawData)),
+		DetectedFields: cdr.DetectedFields,
+	}
+
+	for field, value := range cdr.RawData {
+		if value != nil && isPIIField(field) {
+			masked.RawData[field] = mp.MaskValue(piiValueString(value), mode)
+		} else {
+			masked.RawData[field] = value
+		}
+	}
+
+	return masked
+}
+
+// MaskCDRs returns masked copies of a slice of CDRs
+func (mp *MaskingPolicy) MaskCDRs(cdrs []models.FlexibleCDR, mode MaskingMode) []models.FlexibleCDR {
+	if mode == MaskingNone {
+		return cdrs
+	}
+
+	masked := make([]models.FlexibleCDR, len(cdrs))
+	for i, cdr := range cdrs {
+		masked[i] = mp.MaskCDR(cdr, mode)
+	}
+	return masked
+}
+
+// MaskCriteria returns a copy of the search criteria with phone numbers masked
+func (mp *MaskingPolicy) MaskCriteria(criteria CDRSearchCriteria, mode MaskingMode) CDRSearchCriteria {
+	criteria.OriginatingNumber = mp.MaskValue(criteria.OriginatingNumber, mode)
+	criteria.TerminatingNumber = mp.MaskValue(criteria.TerminatingNumber, mode)
+	criteria.AnyPhoneNumber = mp.MaskValue(criteria.AnyPhoneNumber, mode)
+	return criteria
+}
+
+// MaskURL masks phone number query parameters in an endpoint URL
+func (mp *MaskingPolicy) MaskURL(rawURL string, mode MaskingMode) string {
+	if mode == MaskingNone {
+		return rawURL
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	query := parsed.Query()
+	for _, param := range piiQueryParams {
+		if value := query.Get(param); value != "" {
+			query.Set(param, mp.MaskValue(value, mode))
+		}
+	}
+	parsed.RawQuery = query.Encode()
+
+	return parsed.String()
+}
+
+// piiValueString renders a raw value as digits, avoiding exponent notation for JSON numbers
+func piiValueString(value interface{}) string {
+	if number, ok := value.(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// isPIIField checks whether a CDR field holds a phone number or caller ID
+func isPIIField(field string) bool {
+	for _, name := range piiFields {
+		if field == name {
+			return true
+		}
+	}
+	for _, pattern := range piiFieldPatterns {
+		if strings.Contains(field, pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestMaskingPolicy_MaskValue(t *testing.T) {
+	policy := &MaskingPolicy{Salt: "test-salt"}
+
+	if got := policy.MaskValue("+1 (555) 123-4567", MaskingTruncate); got != "*******4567" {
+		t.Errorf("Expected '*******4567', got '%s'", got)
+	}
+
+	hashed := policy.MaskValue("5551234567", MaskingHash)
+	if !strings.HasPrefix(hashed, "h:") || strings.Contains(hashed, "4567") {
+		t.Errorf("Expected keyed hash without digits, got '%s'", hashed)
+	}
+
+	// Same number in different formats must hash identically so reports can still be joined
+	if policy.MaskValue("(555) 123-4567", MaskingHash) != hashed {
+		t.Error("Expected formatting-independent hashes")
+	}
+
+	if got := policy.MaskValue("5551234567", MaskingNone); got != "5551234567" {
+		t.Errorf("Expected unmasked value, got '%s'", got)
+	}
+}
+
+func TestMaskingPolicy_MaskCDR(t *testing.T) {
+	policy := &MaskingPolicy{Salt: "test-salt"}
+	cdr := models.FlexibleCDR{
+		RawData: map[string]interface{}{
+			"id":                  "cdr-1",
+			"call-orig-caller-id": float64(5551234567),
+			"call-term-caller-id": "5559876543",
+		},
+	}
+
+	masked := policy.MaskCDR(cdr, MaskingTruncate)
+
+	if masked.GetID() != "cdr-1" {
+		t.Errorf("Expected non-PII fields unchanged, got '%s'", masked.GetID())
+	}
+	if masked.GetString("call-orig-caller-id") != "******4567" {
+		t.Errorf("Expected masked orig caller ID, got '%s'", masked.GetString("call-orig-caller-id"))
+	}
+	if cdr.GetString("call-term-caller-id") != "5559876543" {
+		t.Error("Expected original CDR to be left untouched")
+	}
+}
+
+func TestNewMaskingPolicy_Roles(t *testing.T) {
+	policy, err := NewMaskingPolicy("truncate", "viewer:hash, admin:none", "salt")
+	if err != nil {
+		t.Fatalf("Failed to build policy: %v", err)
+	}
+
+	if policy.ModeForRole("viewer") != MaskingHash {
+		t.Error("Expected hash for viewer")
+	}
+	if policy.ModeForRole("admin") != MaskingNone {
+		t.Error("Expected none for admin")
+	}
+	if policy.ModeForRole("someone") != MaskingTruncate {
+		t.Error("Expected default mode for unknown role")
+	}
+
+	if StricterMaskingMode(MaskingHash, MaskingNone) != MaskingHash {
+		t.Error("Requested mode must not loosen role masking")
+	}
+
+	if _, err := NewMaskingPolicy("scramble", "", ""); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
+
+func TestMaskingPolicy_RoleTokens(t *testing.T) {
+	policy, err := NewMaskingPolicy("hash", "admin:none", "salt")
+	if err != nil {
+		t.Fatalf("Failed to build policy: %v", err)
+	}
+	if err := policy.BindRoleTokens("admin: s3cret"); err != nil {
+		t.Fatalf("Failed to bind role tokens: %v", err)
+	}
+
+	if role := policy.RoleForToken("s3cret"); role != "admin" {
+		t.Errorf("Expected the admin token to grant admin, got %q", role)
+	}
+	if role := policy.RoleForToken("admin"); role != "" {
+		t.Errorf("Expected a role name not to be a token, got %q", role)
+	}
+	if policy.ModeForRole(policy.RoleForToken("")) != MaskingHash {
+		t.Error("Expected the default mode without a token")
+	}
+
+	for _, spec := range []string{"admin", "admin:", ":s3cret"} {
+		if err := policy.BindRoleTokens(spec); err == nil {
+			t.Errorf("Expected error for role token entry %q", spec)
+		}
+	}
+}
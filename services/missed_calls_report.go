@@ -0,0 +1,206 @@
+// services/missed_calls_report.go
+// Missed calls: how many of a session's calls were answered, abandoned or missed, and
+// the callers whose inbound calls went unanswered and who haven't been reached since,
+// as a list to call back
+
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"o-dan-go/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// missedCallDetailLimit caps how many callers an alert lists
+const missedCallDetailLimit = 10
+
+// MissedCaller is a caller whose inbound calls went unanswered
+type MissedCaller struct {
+	Number      string     `json:"number"`
+	Missed      int        `json:"missed"`
+	Abandoned   int        `json:"abandoned"`
+	FirstCallAt *time.Time `json:"first_call_at,omitempty"`
+	LastCallAt  *time.Time `json:"last_call_at,omitempty"`
+	Called      []string   `json:"called"` // the users or numbers they tried
+	CDRIDs      []string   `json:"cdr_ids"`
+}
+
+// MissedCallReport is a session's calls by class with the callers to call back
+type MissedCallReport struct {
+	sessionReportBase
+	Calls          int            `json:"calls"`
+	Answered       int            `json:"answered"`
+	Abandoned      int            `json:"abandoned"`
+	Missed         int            `json:"missed"`
+	AbandonPercent float64        `json:"abandon_percent"`
+	MissedPercent  float64        `json:"missed_percent"`
+	Callbacks      []MissedCaller `json:"callbacks"`    // not reached since, most recent first
+	Reached        int            `json:"reached"`      // callers who got through or were called back later
+	Unidentified   int            `json:"unidentified"` // unanswered inbound calls with no caller number
+	GeneratedAt    time.Time      `json:"generated_at"`
+}
+
+// callbackNumberKey compares numbers by their last ten digits, ignoring formatting
+func callbackNumberKey(number string) string {
+	digits := nonDigits.ReplaceAllString(number, "")
+	if len(digits) > 10 {
+		digits = digits[len(digits)-10:]
+	}
+	return digits
+}
+
+// BuildMissedCallReport classifies cdrs and lists the inbound callers nobody answered.
+// A caller counts as reached when a later answered call in the session is from or to
+// their number, or when the times of the calls aren't known.
+func BuildMissedCallReport(cdrs []models.FlexibleCDR) *MissedCallReport {
+	report := &MissedCallReport{Callbacks: []MissedCaller{}, GeneratedAt: time.Now().UTC()}
+	callers := make(map[string]*MissedCaller)
+	called := make(map[string]map[string]bool)
+	answered := []models.FlexibleCDR{}
+
+	for i := range cdrs {
+		cdr := &cdrs[i]
+		report.Calls++
+		class := ClassifyCall(*cdr)
+		switch class {
+		case CallAnswered:
+			report.Answered++
+			answered = append(answered, *cdr)
+			continue
+		case CallAbandoned:
+			report.Abandoned++
+		default:
+			report.Missed++
+		}
+		if cdr.GetCallDirection() != 1 {
+			continue
+		}
+
+		number, _ := filterFieldValue(cdr, "orig_number")
+		key := callbackNumberKey(number)
+		if key == "" {
+			report.Unidentified++
+			continue
+		}
+		caller := callers[key]
+		if caller == nil {
+			caller = &MissedCaller{Number: number, Called: []string{}, CDRIDs: []string{}}
+			callers[key] = caller
+			called[key] = make(map[string]bool)
+		}
+		if class == CallAbandoned {
+			caller.Abandoned++
+		} else {
+			caller.Missed++
+		}
+		caller.CDRIDs = append(caller.CDRIDs, cdr.GetID())
+		if started, err := cdr.GetCallStartTime(); err == nil {
+			if caller.FirstCallAt == nil || started.Before(*caller.FirstCallAt) {
+				caller.FirstCallAt = &started
+			}
+			if caller.LastCallAt == nil || started.After(*caller.LastCallAt) {
+				caller.LastCallAt = &started
+			}
+		}
+		if target := firstNonEmptyString(cdr.GetTermUser(), firstCDRString(*cdr, "call-orig-to-user", "call-dialed-number")); target != "" && !called[key][target] {
+			called[key][target] = true
+			caller.Called = append(caller.Called, target)
+		}
+	}
+
+	reached := make(map[string]bool)
+	for i := range answered {
+		started, err := answered[i].GetCallStartTime()
+		for _, field := range []string{"orig_number", "term_number"} {
+			number, _ := filterFieldValue(&answered[i], field)
+			key := callbackNumberKey(number)
+			caller := callers[key]
+			if caller == nil {
+				continue
+			}
+			if err != nil || caller.LastCallAt == nil || !started.Before(*caller.LastCallAt) {
+				reached[key] = true
+			}
+		}
+	}
+
+	for key, caller := range callers {
+		if reached[key] {
+			report.Reached++
+			continue
+		}
+		report.Callbacks = append(report.Callbacks, *caller)
+	}
+	sort.Slice(report.Callbacks, func(i, j int) bool {
+		a, b := report.Callbacks[i].LastCallAt, report.Callbacks[j].LastCallAt
+		if (a == nil) != (b == nil) {
+			return a != nil
+		}
+		if a != nil && !a.Equal(*b) {
+			return a.After(*b)
+		}
+		return report.Callbacks[i].Number < report.Callbacks[j].Number
+	})
+
+	if report.Calls > 0 {
+		report.AbandonPercent = roundTo(100*float64(report.Abandoned)/float64(report.Calls), 1)
+		report.MissedPercent = roundTo(100*float64(report.Missed)/float64(report.Calls), 1)
+	}
+	return report
+}
+
+// AlertSummary describes the report in one line
+func (r *MissedCallReport) AlertSummary() string {
+	return fmt.Sprintf("Missed calls: %d of %d calls missed, %d abandoned, %d callers to call back",
+		r.Missed, r.Calls, r.Abandoned, len(r.Callbacks))
+}
+
+// AlertDetails lists the most recent callers to call back
+func (r *MissedCallReport) AlertDetails() []string {
+	details := []string{"Session: " + r.SessionID}
+	for i, caller := range r.Callbacks {
+		if i == missedCallDetailLimit {
+			details = append(details, fmt.Sprintf("... and %d more", len(r.Callbacks)-i))
+			break
+		}
+		line := fmt.Sprintf("%s: %d unanswered", caller.Number, caller.Missed+caller.Abandoned)
+		if caller.LastCallAt != nil {
+			line += ", last " + caller.LastCallAt.UTC().Format("2006-01-02 15:04 MST")
+		}
+		if len(caller.Called) > 0 {
+			line += ", called " + strings.Join(caller.Called, ", ")
+		}
+		details = append(details, line)
+	}
+	return details
+}
+
+// WriteCSV writes the callback list, a row per caller
+func (r *MissedCallReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"number", "missed", "abandoned", "first_call_at", "last_call_at", "called", "cdr_ids"})
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	for _, caller := range r.Callbacks {
+		writer.Write([]string{
+			caller.Number,
+			strconv.Itoa(caller.Missed),
+			strconv.Itoa(caller.Abandoned),
+			formatTime(caller.FirstCallAt),
+			formatTime(caller.LastCallAt),
+			strings.Join(caller.Called, " "),
+			strings.Join(caller.CDRIDs, " "),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
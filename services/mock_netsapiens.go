@@ -0,0 +1,330 @@
+// services/mock_netsapiens.go
+// Mock NetSapiens API: serves the /ns-api/v2 CDR endpoints the discovery service queries
+// from a generated or file-based dataset, with configurable latency and failures, so the
+// whole discovery pipeline runs in CI and demos without credentials
+
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"o-dan-go/models"
+)
+
+// mockDefaultLimit is how many CDRs a mock endpoint returns without a limit
+const mockDefaultLimit = 100
+
+// MockNetSapiensSettings configures the mock API
+type MockNetSapiensSettings struct {
+	Dataset     string        // CSV or JSON export to serve; generated when empty
+	CDRs        int           // how many CDRs to generate
+	Seed        int64         // generated data is the same for the same seed
+	Latency     time.Duration // added to every response
+	Jitter      time.Duration // up to this much more, at random
+	FailureRate float64       // share of requests answered with a 503
+	Token       string        // bearer token required; any token is accepted when empty
+}
+
+// MockNetSapiens serves CDRs like the NetSapiens v2 API
+type MockNetSapiens struct {
+	settings MockNetSapiensSettings
+	cdrs     []models.FlexibleCDR
+
+	mu       sync.Mutex
+	random   *rand.Rand
+	requests int
+}
+
+// NewMockNetSapiens loads or generates the dataset
+func NewMockNetSapiens(settings MockNetSapiensSettings) (*MockNetSapiens, error) {
+	if settings.FailureRate < 0 || settings.FailureRate > 1 {
+		return nil, fmt.Errorf("failure rate must be between 0 and 1")
+	}
+	mock := &MockNetSapiens{
+		settings: settings,
+		random:   rand.New(rand.NewSource(settings.Seed)),
+	}
+
+	if settings.Dataset == "" {
+		mock.cdrs = GenerateMockCDRs(settings.CDRs, settings.Seed, time.Now().UTC())
+		return mock, nil
+	}
+	file, err := os.Open(settings.Dataset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+	defer file.Close()
+	cdrs, err := readCDRExport(CDRImportFile{
+		Name:   filepath.Base(settings.Dataset),
+		Format: strings.TrimPrefix(strings.ToLower(filepath.Ext(settings.Dataset)), "."),
+		Reader: file,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+	mock.cdrs = cdrs
+	return mock, nil
+}
+
+// CDRCount is the size of the dataset
+func (m *MockNetSapiens) CDRCount() int {
+	return len(m.cdrs)
+}
+
+// Requests is how many requests have been served
+func (m *MockNetSapiens) Requests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.requests
+}
+
+// ServeHTTP answers the CDR and CDR count endpoints:
+//
+//	/ns-api/v2/cdrs[/count]
+//	/ns-api/v2/domains/{domain}/cdrs[/count]
+//	/ns-api/v2/domains/{domain}/users/{user}/cdrs[/count]
+//	/ns-api/v2/domains/{domain}/sites/{site}/cdrs
+func (m *MockNetSapiens) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	m.requests++
+	delay := m.settings.Latency
+	if m.settings.Jitter > 0 {
+		delay += time.Duration(m.random.Int63n(int64(m.settings.Jitter)))
+	}
+	fail := m.settings.FailureRate > 0 && m.random.Float64() < m.settings.FailureRate
+	m.mu.Unlock()
+	time.Sleep(delay)
+
+	if r.Method != http.MethodGet {
+		mockError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || (m.settings.Token != "" && token != m.settings.Token) {
+		mockError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+		return
+	}
+	if fail {
+		mockError(w, http.StatusServiceUnavailable, "simulated failure")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/ns-api/v2"), "/"), "/")
+	count := parts[len(parts)-1] == "count"
+	if count {
+		parts = parts[:len(parts)-1]
+	}
+	filter := mockFilter{}
+	switch {
+	case len(parts) == 1 && parts[0] == "cdrs":
+	case len(parts) == 3 && parts[0] == "domains" && parts[2] == "cdrs":
+		filter.domain = parts[1]
+	case len(parts) == 5 && parts[0] == "domains" && parts[2] == "users" && parts[4] == "cdrs":
+		filter.domain, filter.user = parts[1], parts[3]
+	case len(parts) == 5 && parts[0] == "domains" && parts[2] == "sites" && parts[4] == "cdrs" && !count:
+		filter.domain, filter.site = parts[1], parts[3]
+	default:
+		mockError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if err := filter.parseQuery(r); err != nil {
+		mockError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	matched := []models.FlexibleCDR{}
+	for i := range m.cdrs {
+		if filter.matches(&m.cdrs[i]) {
+			matched = append(matched, m.cdrs[i])
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if count {
+		seconds := 0
+		for i := range matched {
+			seconds += matched[i].GetCallDuration()
+		}
+		json.NewEncoder(w).Encode(map[string]int{"count": len(matched), "sum": seconds})
+		return
+	}
+	if filter.offset >= len(matched) {
+		matched = nil
+	} else {
+		matched = matched[filter.offset:]
+	}
+	if len(matched) > filter.limit {
+		matched = matched[:filter.limit]
+	}
+	if matched == nil {
+		matched = []models.FlexibleCDR{}
+	}
+	json.NewEncoder(w).Encode(matched)
+}
+
+// mockFilter is what a request asks for
+type mockFilter struct {
+	domain, user, site string
+	callID             string
+	origNumber         string
+	termNumber         string
+	from, to           time.Time
+	offset, limit      int
+}
+
+// parseQuery reads the query parameters. start is a day (YYYY-MM-DD) or an offset, as
+// the discovery service sends both.
+func (f *mockFilter) parseQuery(r *http.Request) error {
+	query := r.URL.Query()
+	f.limit = mockDefaultLimit
+	if limit := query.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 {
+			return fmt.Errorf("invalid limit")
+		}
+		f.limit = parsed
+	}
+	for _, start := range query["start"] {
+		if day, err := time.Parse("2006-01-02", start); err == nil {
+			f.from = day
+		} else if offset, err := strconv.Atoi(start); err == nil && offset >= 0 {
+			f.offset = offset
+		} else {
+			return fmt.Errorf("invalid start")
+		}
+	}
+	if end := query.Get("end"); end != "" {
+		day, err := time.Parse("2006-01-02", end)
+		if err != nil {
+			return fmt.Errorf("invalid end")
+		}
+		f.to = day.AddDate(0, 0, 1)
+	}
+	f.callID = query.Get("call_id")
+	f.origNumber = query.Get("orig_number")
+	f.termNumber = query.Get("term_number")
+	return nil
+}
+
+// matches reports whether a CDR passes the filter
+func (f *mockFilter) matches(cdr *models.FlexibleCDR) bool {
+	if f.domain != "" && !strings.EqualFold(cdr.GetDomain(), f.domain) {
+		return false
+	}
+	if f.user != "" && cdr.GetOrigUser() != f.user && cdr.GetTermUser() != f.user {
+		return false
+	}
+	if f.site != "" && cdr.GetString("call-orig-site") != f.site && cdr.GetString("call-term-site") != f.site {
+		return false
+	}
+	if f.callID != "" && cdr.GetString("call-orig-call-id") != f.callID && cdr.GetID() != f.callID {
+		return false
+	}
+	if f.origNumber != "" && !strings.Contains(cdrCallerNumber(cdr, "orig"), nonDigits.ReplaceAllString(f.origNumber, "")) {
+		return false
+	}
+	if f.termNumber != "" && !strings.Contains(cdrCallerNumber(cdr, "term"), nonDigits.ReplaceAllString(f.termNumber, "")) {
+		return false
+	}
+	if !f.from.IsZero() || !f.to.IsZero() {
+		started, err := cdr.GetCallStartTime()
+		if err != nil || (!f.from.IsZero() && started.Before(f.from)) || (!f.to.IsZero() && !started.Before(f.to)) {
+			return false
+		}
+	}
+	return true
+}
+
+// mockError writes an error the way the API does
+func mockError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"code": status, "message": message})
+}
+
+// GenerateMockCDRs makes count plausible CDRs over the week before now: two domains with
+// a few users and sites, inbound and outbound calls, and some busy, unanswered and failed
+// calls. The same seed gives the same CDRs.
+func GenerateMockCDRs(count int, seed int64, now time.Time) []models.FlexibleCDR {
+	random := rand.New(rand.NewSource(seed))
+	domains := []string{"acme.example.com", "globex.example.com"}
+	sites := []string{"hq", "branch"}
+	outcomes := []struct {
+		reason   string
+		answered bool
+		weight   int
+	}{
+		{"Normal Clearing", true, 80},
+		{"User Busy", false, 8},
+		{"No Answer", false, 7},
+		{"Service Unavailable", false, 3},
+		{"Request Timeout", false, 2},
+	}
+
+	cdrs := make([]models.FlexibleCDR, 0, count)
+	for i := 0; i < count; i++ {
+		domain := domains[random.Intn(len(domains))]
+		user := strconv.Itoa(1001 + random.Intn(10))
+		site := sites[random.Intn(len(sites))]
+		external := fmt.Sprintf("1%03d555%04d", 200+random.Intn(700), random.Intn(10000))
+		// Calls cluster in business hours
+		start := now.Add(-time.Duration(random.Intn(7*24)) * time.Hour).Truncate(24 * time.Hour).
+			Add(time.Duration(8+random.Intn(10))*time.Hour + time.Duration(random.Intn(3600))*time.Second)
+		direction := random.Intn(2)
+
+		pick := random.Intn(100)
+		outcome := outcomes[0]
+		for _, candidate := range outcomes {
+			if pick < candidate.weight {
+				outcome = candidate
+				break
+			}
+			pick -= candidate.weight
+		}
+
+		raw := map[string]interface{}{
+			"id":                          fmt.Sprintf("mock-%d-%06d", seed, i+1),
+			"domain":                      domain,
+			"call-direction":              direction,
+			"call-start-datetime":         start.Format(time.RFC3339),
+			"call-orig-call-id":           fmt.Sprintf("%08x@mock", random.Uint32()),
+			"call-disconnect-reason-text": outcome.reason,
+			"call-total-duration-seconds": 0,
+		}
+		ring := 3 + random.Intn(20)
+		if outcome.answered {
+			talk := 10 + random.Intn(600)
+			raw["call-answer-datetime"] = start.Add(time.Duration(ring) * time.Second).Format(time.RFC3339)
+			raw["call-total-duration-seconds"] = ring + talk
+		}
+		if direction == 1 {
+			raw["call-orig-caller-id"] = external
+			raw["call-term-user"] = user
+			raw["call-term-caller-id"] = "1415555" + user
+			raw["call-term-site"] = site
+		} else {
+			raw["call-orig-user"] = user
+			raw["call-orig-caller-id"] = "1415555" + user
+			raw["call-orig-site"] = site
+			raw["call-orig-to-user"] = external
+			raw["call-term-caller-id"] = external
+			raw["call-route"] = fmt.Sprintf("carrier-%c", 'a'+rune(random.Intn(2)))
+		}
+		cdrs = append(cdrs, models.FlexibleCDR{RawData: raw})
+	}
+
+	sort.Slice(cdrs, func(i, j int) bool {
+		return cdrs[i].GetString("call-start-datetime") < cdrs[j].GetString("call-start-datetime")
+	})
+	return cdrs
+}
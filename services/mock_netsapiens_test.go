@@ -0,0 +1,63 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMockNetSapiensDiscovery(t *testing.T) {
+	mock, err := NewMockNetSapiens(MockNetSapiensSettings{CDRs: 200, Seed: 7, Token: "secret"})
+	if err != nil {
+		t.Fatalf("NewMockNetSapiens: %v", err)
+	}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	discovery := NewCDRDiscoveryService(server.URL, "secret")
+	discovery.debug = false
+
+	all, err := discovery.GetComprehensiveCDRs(CDRSearchCriteria{Limit: 500})
+	if err != nil || all.UniqueCDRs != 200 || len(all.Errors) != 0 {
+		t.Fatalf("global search = %d unique CDRs, errors %v, %v; want 200", all.UniqueCDRs, all.Errors, err)
+	}
+
+	// The global endpoint is always queried alongside the domain and user endpoints
+	user, err := discovery.GetComprehensiveCDRs(CDRSearchCriteria{Domain: "acme.example.com", User: "1001", Limit: 500})
+	if err != nil || len(user.EndpointResults) != 3 {
+		t.Fatalf("user search queried %d endpoints, %v; want 3", len(user.EndpointResults), err)
+	}
+	global, domain, byUser := user.EndpointResults[0], user.EndpointResults[1], user.EndpointResults[2]
+	if !(global.RecordCount > domain.RecordCount && domain.RecordCount > byUser.RecordCount && byUser.RecordCount > 0) {
+		t.Errorf("global, domain and user endpoints found %d, %d and %d CDRs", global.RecordCount, domain.RecordCount, byUser.RecordCount)
+	}
+	for _, cdr := range user.CDRsByEndpoint["user_cdrs"] {
+		if cdr.GetDomain() != "acme.example.com" || (cdr.GetOrigUser() != "1001" && cdr.GetTermUser() != "1001") {
+			t.Fatalf("user endpoint returned %v", cdr.RawData)
+		}
+	}
+
+	// Date filters, pagination limits and authentication
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	recent, _ := discovery.GetComprehensiveCDRs(CDRSearchCriteria{StartDate: &today, Limit: 500})
+	if recent.UniqueCDRs >= all.UniqueCDRs {
+		t.Errorf("searching from today found %d of %d CDRs", recent.UniqueCDRs, all.UniqueCDRs)
+	}
+	limited, _ := discovery.GetComprehensiveCDRs(CDRSearchCriteria{Limit: 10})
+	if limited.UniqueCDRs != 10 {
+		t.Errorf("limit 10 returned %d CDRs", limited.UniqueCDRs)
+	}
+	denied, _ := NewCDRDiscoveryService(server.URL, "wrong").GetComprehensiveCDRs(CDRSearchCriteria{})
+	if len(denied.EndpointResults) != 1 || denied.EndpointResults[0].HTTPStatus != http.StatusUnauthorized {
+		t.Errorf("wrong token got %+v", denied.EndpointResults)
+	}
+
+	failing, _ := NewMockNetSapiens(MockNetSapiensSettings{CDRs: 5, FailureRate: 1})
+	failingServer := httptest.NewServer(failing)
+	defer failingServer.Close()
+	failed, _ := NewCDRDiscoveryService(failingServer.URL, "any").GetComprehensiveCDRs(CDRSearchCriteria{})
+	if failed.UniqueCDRs != 0 || failed.EndpointResults[0].HTTPStatus != http.StatusServiceUnavailable {
+		t.Errorf("failing mock returned %+v", failed.EndpointResults)
+	}
+}
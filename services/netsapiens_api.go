@@ -0,0 +1,117 @@
+// services/netsapiens_api.go
+// Helpers shared by the NetSapiens v2 API clients (call queues, presence, events, call control)
+
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// netsapiensStatusError is a NetSapiens API response with an unexpected status
+type netsapiensStatusError struct {
+	path       string
+	statusCode int
+	body       string
+}
+
+func (e *netsapiensStatusError) Error() string {
+	return fmt.Sprintf("%s returned status %d: %s", e.path, e.statusCode, e.body)
+}
+
+// isNetsapiensNotFound reports whether err is a 404 from the NetSapiens API
+func isNetsapiensNotFound(err error) bool {
+	var statusErr *netsapiensStatusError
+	return errors.As(err, &statusErr) && statusErr.statusCode == http.StatusNotFound
+}
+
+// netsapiensGet fetches a NetSapiens API path and decodes the JSON body into out
+func netsapiensGet(client *http.Client, baseURL, accessToken, path string, out interface{}) error {
+	return netsapiensRequest(client, baseURL, accessToken, "GET", path, nil, out)
+}
+
+// netsapiensRequest sends payload (if not nil) as JSON to a NetSapiens API path and decodes
+// the JSON response into out (if not nil). Any 2xx status is a success.
+func netsapiensRequest(client *http.Client, baseURL, accessToken, method, path string, payload, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &netsapiensStatusError{path: path, statusCode: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		if err == io.EOF && method != "GET" {
+			return nil // an empty body is fine for calls made for their effect
+		}
+		return fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// recordString returns the first of keys present in an API record, as a string.
+// Field names differ between NetSapiens versions, so callers list the known spellings.
+func recordString(record map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch value := record[key].(type) {
+		case string:
+			if value != "" {
+				return value
+			}
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64)
+		case bool:
+			return strconv.FormatBool(value)
+		}
+	}
+	return ""
+}
+
+// recordTime parses the first of keys present in an API record as a timestamp
+func recordTime(record map[string]interface{}, keys ...string) (time.Time, bool) {
+	value := recordString(record, keys...)
+	if value == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, true
+		}
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,351 @@
+// services/number_enrichment.go
+// Carrier, ported-number (LRN) and caller name (CNAM) enrichment: looks up the originating
+// and terminating numbers of search results through the configured providers, caching each
+// number in SQLite, and attaches the results to the CDRs so they show up in exports
+
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"o-dan-go/models"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// enrichmentWorkers is how many lookups run at once
+const enrichmentWorkers = 4
+
+// CDR fields enrichment adds, for the "orig" and "term" numbers
+const (
+	enrichedCarrierField  = "-carrier"
+	enrichedLineTypeField = "-line-type"
+	enrichedLRNField      = "-lrn"
+	enrichedPortedField   = "-ported"
+
+	enrichedCallerNameField = "-caller-name"
+)
+
+// EnrichmentStats summarizes enriching a set of CDRs
+type EnrichmentStats struct {
+	Numbers  int `json:"numbers"`   // distinct phone numbers found
+	Cached   int `json:"cached"`    // answered from the cache
+	LookedUp int `json:"looked_up"` // sent to the provider
+	Skipped  int `json:"skipped"`   // over the per-search lookup limit
+	Errors   int `json:"errors"`
+}
+
+// NumberEnricher adds carrier and LRN data to CDRs
+type NumberEnricher struct {
+	db         *DatabaseService
+	provider   EnrichmentProvider
+	cnam       CallerNameProvider
+	cacheTTL   time.Duration
+	maxLookups int
+}
+
+// NewNumberEnricher creates an enricher; provider is nil when enrichment is disabled.
+// Lookups are reused for cacheTTL, and at most maxLookups new numbers are looked up per search.
+func NewNumberEnricher(db *DatabaseService, provider EnrichmentProvider, cacheTTL time.Duration, maxLookups int) *NumberEnricher {
+	return &NumberEnricher{
+		db:         db,
+		provider:   provider,
+		cacheTTL:   cacheTTL,
+		maxLookups: maxLookups,
+	}
+}
+
+// LookupCallerNames turns on CNAM lookups of calling numbers; a nil provider leaves them off
+func (ne *NumberEnricher) LookupCallerNames(provider CallerNameProvider) {
+	ne.cnam = provider
+}
+
+// Enabled reports whether numbers are being enriched
+func (ne *NumberEnricher) Enabled() bool {
+	return ne != nil && (ne.provider != nil || ne.cnam != nil)
+}
+
+// Lookup returns a number's carrier and porting data, from the cache when it is fresh
+func (ne *NumberEnricher) Lookup(number string) (*NumberInfo, error) {
+	if ne.provider == nil {
+		return nil, fmt.Errorf("no enrichment provider is configured")
+	}
+	normalized, ok := normalizeE164(number)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a phone number that can be looked up", number)
+	}
+	if info, err := ne.db.GetNumberInfo(normalized, time.Now().Add(-ne.cacheTTL)); err != nil || info != nil {
+		return info, err
+	}
+	return ne.lookup(normalized)
+}
+
+// lookup asks the provider about a normalized number and caches the answer
+func (ne *NumberEnricher) lookup(number string) (*NumberInfo, error) {
+	info, err := ne.provider.Lookup(number)
+	if err != nil {
+		return nil, err
+	}
+	info.Number = number
+	info.LookedUpAt = time.Now().UTC()
+	if err := ne.db.SaveNumberInfo(info); err != nil {
+		log.Printf("[Enrichment] Failed to cache %s: %v", number, err)
+	}
+	return info, nil
+}
+
+// lookupCallerName asks the CNAM provider about a normalized number and caches the answer,
+// including when the number has no name, so it isn't asked again
+func (ne *NumberEnricher) lookupCallerName(number string) (string, error) {
+	name, err := ne.cnam.LookupCallerName(number)
+	if err != nil {
+		return "", err
+	}
+	if err := ne.db.SaveCallerName(number, name, ne.cnam.Name()); err != nil {
+		log.Printf("[Enrichment] Failed to cache caller name of %s: %v", number, err)
+	}
+	return name, nil
+}
+
+// EnrichCDRs attaches carrier, line type, LRN and ported fields for the originating and
+// terminating numbers of each CDR, and the caller name of the originating number when CNAM
+// lookups are on. Extensions and other short numbers are left alone.
+func (ne *NumberEnricher) EnrichCDRs(cdrs []models.FlexibleCDR) EnrichmentStats {
+	stats := EnrichmentStats{}
+	if !ne.Enabled() {
+		return stats
+	}
+
+	carriers := make(map[string]*NumberInfo)
+	names := make(map[string]string)
+	numbers := make(map[string]bool)
+	for i := range cdrs {
+		for _, side := range []string{"orig", "term"} {
+			number, ok := normalizeE164(cdrCallerNumber(&cdrs[i], side))
+			if !ok {
+				continue
+			}
+			numbers[number] = true
+			if ne.provider != nil {
+				carriers[number] = nil
+			}
+			if ne.cnam != nil && side == "orig" {
+				names[number] = ""
+			}
+		}
+	}
+	stats.Numbers = len(numbers)
+
+	since := time.Now().Add(-ne.cacheTTL)
+	var mu sync.Mutex
+	if ne.provider != nil {
+		pending := []string{}
+		for number := range carriers {
+			info, err := ne.db.GetNumberInfo(number, since)
+			if err != nil {
+				log.Printf("[Enrichment] Failed to read cache for %s: %v", number, err)
+			}
+			if info != nil {
+				carriers[number] = info
+				stats.Cached++
+				continue
+			}
+			pending = append(pending, number)
+		}
+		ne.runLookups(pending, &stats, func(number string) error {
+			info, err := ne.lookup(number)
+			if err == nil {
+				mu.Lock()
+				carriers[number] = info
+				mu.Unlock()
+			}
+			return err
+		})
+	}
+	if ne.cnam != nil {
+		pending := []string{}
+		for number := range names {
+			name, found, err := ne.db.GetCallerName(number, since)
+			if err != nil {
+				log.Printf("[Enrichment] Failed to read caller name cache for %s: %v", number, err)
+			}
+			if found {
+				names[number] = name
+				stats.Cached++
+				continue
+			}
+			pending = append(pending, number)
+		}
+		ne.runLookups(pending, &stats, func(number string) error {
+			name, err := ne.lookupCallerName(number)
+			if err == nil {
+				mu.Lock()
+				names[number] = name
+				mu.Unlock()
+			}
+			return err
+		})
+	}
+
+	for i := range cdrs {
+		for _, side := range []string{"orig", "term"} {
+			number, ok := normalizeE164(cdrCallerNumber(&cdrs[i], side))
+			if !ok {
+				continue
+			}
+			if info := carriers[number]; info != nil {
+				attachNumberInfo(&cdrs[i], side, info)
+			}
+			if name := names[number]; name != "" && side == "orig" {
+				setEnrichedField(&cdrs[i], side+enrichedCallerNameField, name)
+			}
+		}
+	}
+
+	log.Printf("[Enrichment] %d numbers: %d cached, %d looked up, %d over the limit, %d errors",
+		stats.Numbers, stats.Cached, stats.LookedUp, stats.Skipped, stats.Errors)
+	return stats
+}
+
+// runLookups sends up to maxLookups numbers to lookup a few at a time, counting the
+// results in stats. lookup must be safe to call concurrently.
+func (ne *NumberEnricher) runLookups(pending []string, stats *EnrichmentStats, lookup func(number string) error) {
+	if ne.maxLookups > 0 && len(pending) > ne.maxLookups {
+		stats.Skipped += len(pending) - ne.maxLookups
+		pending = pending[:ne.maxLookups]
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	numbers := make(chan string)
+	for w := 0; w < enrichmentWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for number := range numbers {
+				err := lookup(number)
+				mu.Lock()
+				if err != nil {
+					stats.Errors++
+					log.Printf("[Enrichment] Failed to look up %s: %v", number, err)
+				} else {
+					stats.LookedUp++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, number := range pending {
+		numbers <- number
+	}
+	close(numbers)
+	wg.Wait()
+}
+
+// attachNumberInfo sets a side's enrichment fields on a CDR
+func attachNumberInfo(cdr *models.FlexibleCDR, side string, info *NumberInfo) {
+	fields := map[string]interface{}{
+		side + enrichedCarrierField:  info.Carrier,
+		side + enrichedLineTypeField: info.LineType,
+		side + enrichedLRNField:      info.LRN,
+		side + enrichedPortedField:   info.Ported,
+	}
+	for field, value := range fields {
+		setEnrichedField(cdr, field, value)
+	}
+}
+
+// setEnrichedField sets a field on a CDR, adding it to the detected fields
+func setEnrichedField(cdr *models.FlexibleCDR, field string, value interface{}) {
+	if _, exists := cdr.RawData[field]; !exists {
+		cdr.DetectedFields = append(cdr.DetectedFields, field)
+	}
+	cdr.RawData[field] = value
+}
+
+// cdrCallerNumber is the "orig" or "term" caller ID of a CDR; JSON numbers are
+// read as integers so they aren't formatted in exponent notation
+func cdrCallerNumber(cdr *models.FlexibleCDR, side string) string {
+	field := "call-" + side + "-caller-id"
+	if number := cdr.GetInt64(field); number > 0 {
+		return strconv.FormatInt(number, 10)
+	}
+	return cdr.GetString(field)
+}
+
+// normalizeE164 turns a phone number into E.164, assuming North America for 10 digits.
+// Numbers shorter than 10 digits (extensions) aren't looked up.
+func normalizeE164(number string) (string, bool) {
+	number = strings.TrimSpace(number)
+	digits := nonDigits.ReplaceAllString(number, "")
+	switch {
+	case len(digits) < 10 || len(digits) > 15:
+		return "", false
+	case strings.HasPrefix(number, "+"):
+		return "+" + digits, true
+	case len(digits) == 10:
+		return "+1" + digits, true
+	default:
+		return "+" + digits, true
+	}
+}
+
+// GetNumberInfo returns a cached lookup made after since, or nil
+func (ds *DatabaseService) GetNumberInfo(number string, since time.Time) (*NumberInfo, error) {
+	var info NumberInfo
+	err := ds.db.QueryRow(`
+	SELECT number, provider, COALESCE(carrier, ''), COALESCE(line_type, ''), COALESCE(lrn, ''), ported, looked_up_at
+	FROM number_enrichments WHERE number = ? AND looked_up_at >= ?`, number, since.UTC(),
+	).Scan(&info.Number, &info.Provider, &info.Carrier, &info.LineType, &info.LRN, &info.Ported, &info.LookedUpAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get number info: %w", err)
+	}
+	return &info, nil
+}
+
+// SaveNumberInfo caches a lookup, replacing an older one
+func (ds *DatabaseService) SaveNumberInfo(info *NumberInfo) error {
+	_, err := ds.db.Exec(`
+	INSERT OR REPLACE INTO number_enrichments (number, provider, carrier, line_type, lrn, ported, looked_up_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		info.Number, info.Provider, info.Carrier, info.LineType, info.LRN, info.Ported, info.LookedUpAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save number info: %w", err)
+	}
+	return nil
+}
+
+// GetCallerName returns a cached CNAM looked up after since; found is false when there is none
+func (ds *DatabaseService) GetCallerName(number string, since time.Time) (name string, found bool, err error) {
+	err = ds.db.QueryRow(`
+	SELECT COALESCE(caller_name, '') FROM caller_names WHERE number = ? AND looked_up_at >= ?`,
+		number, since.UTC(),
+	).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get caller name: %w", err)
+	}
+	return name, true, nil
+}
+
+// SaveCallerName caches a CNAM lookup, replacing an older one
+func (ds *DatabaseService) SaveCallerName(number, name, provider string) error {
+	_, err := ds.db.Exec(`
+	INSERT OR REPLACE INTO caller_names (number, caller_name, provider, looked_up_at)
+	VALUES (?, ?, ?, ?)`,
+		number, name, provider, time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save caller name: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestNormalizeE164(t *testing.T) {
+	tests := []struct {
+		number string
+		want   string
+		ok     bool
+	}{
+		{"4155551234", "+14155551234", true},
+		{"1 (415) 555-1234", "+14155551234", true},
+		{"+44 20 7946 0958", "+442079460958", true},
+		{"1001", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		got, ok := normalizeE164(test.number)
+		if got != test.want || ok != test.ok {
+			t.Errorf("normalizeE164(%q) = %q, %v; want %q, %v", test.number, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestNumberEnricherTelnyx(t *testing.T) {
+	var lookups int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&lookups, 1)
+		if r.Header.Get("Authorization") != "Bearer key" || r.URL.Query().Get("type") != "carrier" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "+14155551234") {
+			w.Write([]byte(`{"data":{"carrier":{"name":"Verizon Wireless","type":"mobile"},
+				"portability":{"lrn":"4155550000","ported_status":"Y","spid_carrier_name":"T-Mobile USA"}}}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"carrier":{"name":"AT&T","type":"landline"},"portability":{"ported_status":"N"}}}`))
+	}))
+	defer server.Close()
+
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "enrichment.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	provider := &TelnyxEnrichmentProvider{client: server.Client(), baseURL: server.URL + "/", apiKey: "key"}
+	enricher := NewNumberEnricher(db, provider, time.Hour, 10)
+
+	// Caller IDs arrive as JSON numbers or strings; extensions aren't looked up
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"call-orig-caller-id": float64(4155551234), "call-term-caller-id": "1001"}},
+		{RawData: map[string]interface{}{"call-orig-caller-id": "+1 212-555-0100", "call-term-caller-id": "4155551234"}},
+	}
+	stats := enricher.EnrichCDRs(cdrs)
+	if stats.Numbers != 2 || stats.LookedUp != 2 || stats.Cached != 0 || stats.Errors != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	if got := cdrs[0].GetString("orig-carrier"); got != "T-Mobile USA" {
+		t.Errorf("orig-carrier = %q, want the carrier the number was ported to", got)
+	}
+	if cdrs[0].GetString("orig-lrn") != "4155550000" || !cdrs[0].GetBool("orig-ported") || cdrs[0].GetString("orig-line-type") != "mobile" {
+		t.Errorf("unexpected orig enrichment: %+v", cdrs[0].RawData)
+	}
+	if cdrs[0].HasField("term-carrier") {
+		t.Errorf("extension was enriched: %+v", cdrs[0].RawData)
+	}
+	if cdrs[1].GetString("orig-carrier") != "AT&T" || cdrs[1].GetBool("orig-ported") || cdrs[1].GetString("term-lrn") != "4155550000" {
+		t.Errorf("unexpected enrichment: %+v", cdrs[1].RawData)
+	}
+
+	// Cached numbers aren't looked up again
+	stats = enricher.EnrichCDRs([]models.FlexibleCDR{{RawData: map[string]interface{}{"call-orig-caller-id": "4155551234"}}})
+	if stats.Cached != 1 || stats.LookedUp != 0 || atomic.LoadInt32(&lookups) != 2 {
+		t.Fatalf("cache not used: %+v, %d lookups", stats, lookups)
+	}
+
+	// New lookups are capped per search
+	enricher = NewNumberEnricher(db, provider, time.Hour, 1)
+	stats = enricher.EnrichCDRs([]models.FlexibleCDR{
+		{RawData: map[string]interface{}{"call-orig-caller-id": "3125550100", "call-term-caller-id": "6175550100"}},
+	})
+	if stats.LookedUp != 1 || stats.Skipped != 1 {
+		t.Fatalf("lookup limit not applied: %+v", stats)
+	}
+}
+
+func TestNumberEnricherCallerNames(t *testing.T) {
+	var lookups int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&lookups, 1)
+		if r.URL.Query().Get("type") != "caller-name" {
+			http.Error(w, "unexpected lookup", http.StatusBadRequest)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "+14155551234") {
+			w.Write([]byte(`{"data":{"caller_name":{"caller_name":"ACME CORP "}}}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"caller_name":{}}}`))
+	}))
+	defer server.Close()
+
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "cnam.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	// Caller names only, without carrier lookups
+	enricher := NewNumberEnricher(db, nil, time.Hour, 10)
+	enricher.LookupCallerNames(&TelnyxEnrichmentProvider{client: server.Client(), baseURL: server.URL + "/", apiKey: "key"})
+	if !enricher.Enabled() {
+		t.Fatal("enricher with only a CNAM provider is not enabled")
+	}
+
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"call-orig-caller-id": "4155551234", "call-term-caller-id": "2125550100"}},
+		{RawData: map[string]interface{}{"call-orig-caller-id": "3125550100"}},
+	}
+	if stats := enricher.EnrichCDRs(cdrs); stats.LookedUp != 2 || stats.Errors != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if got := cdrs[0].GetString("orig-caller-name"); got != "ACME CORP" {
+		t.Errorf("orig-caller-name = %q, want ACME CORP", got)
+	}
+	if cdrs[0].HasField("term-caller-name") || cdrs[0].HasField("orig-carrier") || cdrs[1].HasField("orig-caller-name") {
+		t.Errorf("unexpected fields: %+v, %+v", cdrs[0].RawData, cdrs[1].RawData)
+	}
+
+	// Numbers without a name are cached too
+	if stats := enricher.EnrichCDRs(cdrs); stats.Cached != 2 || atomic.LoadInt32(&lookups) != 2 {
+		t.Fatalf("cache not used: %+v, %d lookups", stats, lookups)
+	}
+}
@@ -0,0 +1,295 @@
+// services/parquet.go
+// Parquet writer: writes columns of strings, integers and timestamps as an uncompressed
+// Parquet file with one row group and PLAIN-encoded pages, enough for warehouses and
+// query engines to read exports without a Parquet library
+
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// parquetMagic opens and closes every file
+const parquetMagic = "PAR1"
+
+// Parquet physical types
+const (
+	parquetInt32     int32 = 1
+	parquetInt64     int32 = 2
+	parquetByteArray int32 = 6
+)
+
+// Parquet converted types; parquetNoConversion leaves a column's type unannotated
+const (
+	parquetNoConversion    int32 = -1
+	parquetUTF8            int32 = 0
+	parquetTimestampMillis int32 = 9
+)
+
+// Parquet encodings, repetition types and thrift compact field types
+const (
+	parquetPlain     int32 = 0
+	parquetRLE       int32 = 3
+	parquetRequired  int32 = 0
+	parquetOptional  int32 = 1
+	thriftI32        byte  = 5
+	thriftI64        byte  = 6
+	thriftBinary     byte  = 8
+	thriftList       byte  = 9
+	thriftStruct     byte  = 12
+	parquetCreatedBy       = "o-dan-go"
+)
+
+// parquetColumn is one column of a file. Values are string, int32 or int64 to match the
+// physical type; nil values are nulls and are only allowed in optional columns.
+type parquetColumn struct {
+	name      string
+	physical  int32
+	converted int32
+	optional  bool
+	values    []interface{}
+}
+
+// writeParquet writes columns of rows values each as a Parquet file
+func writeParquet(w io.Writer, columns []*parquetColumn, rows int) error {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	type chunk struct {
+		offset, size int64
+	}
+	chunks := make([]chunk, len(columns))
+	for i, column := range columns {
+		if len(column.values) != rows {
+			return fmt.Errorf("column %s has %d values for %d rows", column.name, len(column.values), rows)
+		}
+		page, err := column.page()
+		if err != nil {
+			return err
+		}
+		header := newThriftCompact()
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.begin(5)
+		header.i32(1, int32(rows))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.end()
+		header.end()
+
+		chunks[i] = chunk{offset: int64(file.Len()), size: int64(header.buf.Len() + len(page))}
+		file.Write(header.buf.Bytes())
+		file.Write(page)
+	}
+
+	var rowGroupSize int64
+	for _, c := range chunks {
+		rowGroupSize += c.size
+	}
+
+	footer := newThriftCompact()
+	footer.i32(1, 1)
+	footer.list(2, thriftStruct, len(columns)+1)
+	footer.element()
+	footer.binary(4, "schema")
+	footer.i32(5, int32(len(columns)))
+	footer.end()
+	for _, column := range columns {
+		repetition := parquetRequired
+		if column.optional {
+			repetition = parquetOptional
+		}
+		footer.element()
+		footer.i32(1, column.physical)
+		footer.i32(3, repetition)
+		footer.binary(4, column.name)
+		if column.converted != parquetNoConversion {
+			footer.i32(6, column.converted)
+		}
+		footer.end()
+	}
+	footer.i64(3, int64(rows))
+	footer.list(4, thriftStruct, 1)
+	footer.element()
+	footer.list(1, thriftStruct, len(columns))
+	for i, column := range columns {
+		footer.element()
+		footer.i64(2, chunks[i].offset)
+		footer.begin(3)
+		footer.i32(1, column.physical)
+		footer.list(2, thriftI32, 2)
+		footer.listI32(parquetPlain)
+		footer.listI32(parquetRLE)
+		footer.list(3, thriftBinary, 1)
+		footer.listBinary(column.name)
+		footer.i32(4, 0) // UNCOMPRESSED
+		footer.i64(5, int64(rows))
+		footer.i64(6, chunks[i].size)
+		footer.i64(7, chunks[i].size)
+		footer.i64(9, chunks[i].offset)
+		footer.end()
+		footer.end()
+	}
+	footer.i64(2, rowGroupSize)
+	footer.i64(3, int64(rows))
+	footer.end()
+	footer.binary(6, parquetCreatedBy)
+	footer.end()
+
+	file.Write(footer.buf.Bytes())
+	binary.Write(&file, binary.LittleEndian, uint32(footer.buf.Len()))
+	file.WriteString(parquetMagic)
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// page encodes a column's data page: definition levels for optional columns, then the
+// PLAIN values of the rows that aren't null
+func (pc *parquetColumn) page() ([]byte, error) {
+	var page bytes.Buffer
+	if pc.optional {
+		defined := make([]bool, len(pc.values))
+		for i, value := range pc.values {
+			defined[i] = value != nil
+		}
+		levels := parquetLevels(defined)
+		binary.Write(&page, binary.LittleEndian, uint32(len(levels)))
+		page.Write(levels)
+	}
+
+	for i, value := range pc.values {
+		switch v := value.(type) {
+		case nil:
+			if !pc.optional {
+				return nil, fmt.Errorf("column %s is required but row %d is null", pc.name, i+1)
+			}
+		case string:
+			if pc.physical != parquetByteArray {
+				return nil, fmt.Errorf("column %s row %d: string in a non-string column", pc.name, i+1)
+			}
+			binary.Write(&page, binary.LittleEndian, uint32(len(v)))
+			page.WriteString(v)
+		case int32:
+			if pc.physical != parquetInt32 {
+				return nil, fmt.Errorf("column %s row %d: int32 in a non-int32 column", pc.name, i+1)
+			}
+			binary.Write(&page, binary.LittleEndian, v)
+		case int64:
+			if pc.physical != parquetInt64 {
+				return nil, fmt.Errorf("column %s row %d: int64 in a non-int64 column", pc.name, i+1)
+			}
+			binary.Write(&page, binary.LittleEndian, v)
+		default:
+			return nil, fmt.Errorf("column %s row %d: unsupported value %T", pc.name, i+1, value)
+		}
+	}
+	return page.Bytes(), nil
+}
+
+// parquetLevels encodes definition levels of bit width 1 as RLE runs
+func parquetLevels(defined []bool) []byte {
+	var levels []byte
+	for start := 0; start < len(defined); {
+		end := start
+		for end < len(defined) && defined[end] == defined[start] {
+			end++
+		}
+		levels = binary.AppendUvarint(levels, uint64(end-start)<<1)
+		if defined[start] {
+			levels = append(levels, 1)
+		} else {
+			levels = append(levels, 0)
+		}
+		start = end
+	}
+	return levels
+}
+
+// thriftCompact writes the thrift compact protocol Parquet uses for its page headers
+// and footer
+type thriftCompact struct {
+	buf  bytes.Buffer
+	last []int16 // the last field ID written in each open struct
+}
+
+// newThriftCompact starts a top-level struct
+func newThriftCompact() *thriftCompact {
+	return &thriftCompact{last: []int16{0}}
+}
+
+func (tc *thriftCompact) field(id int16, fieldType byte) {
+	last := &tc.last[len(tc.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		tc.buf.WriteByte(byte(delta)<<4 | fieldType)
+	} else {
+		tc.buf.WriteByte(fieldType)
+		tc.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (tc *thriftCompact) varint(v uint64) {
+	tc.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (tc *thriftCompact) i32(id int16, v int32) {
+	tc.field(id, thriftI32)
+	tc.varint(zigzag(int64(v)))
+}
+
+func (tc *thriftCompact) i64(id int16, v int64) {
+	tc.field(id, thriftI64)
+	tc.varint(zigzag(v))
+}
+
+func (tc *thriftCompact) binary(id int16, s string) {
+	tc.field(id, thriftBinary)
+	tc.listBinary(s)
+}
+
+// begin opens a struct field; end closes it
+func (tc *thriftCompact) begin(id int16) {
+	tc.field(id, thriftStruct)
+	tc.element()
+}
+
+func (tc *thriftCompact) end() {
+	tc.buf.WriteByte(0)
+	tc.last = tc.last[:len(tc.last)-1]
+}
+
+// list starts a list field of n elements, which are written with listI32, listBinary
+// or element and end
+func (tc *thriftCompact) list(id int16, elementType byte, n int) {
+	tc.field(id, thriftList)
+	if n < 15 {
+		tc.buf.WriteByte(byte(n)<<4 | elementType)
+		return
+	}
+	tc.buf.WriteByte(0xf0 | elementType)
+	tc.varint(uint64(n))
+}
+
+func (tc *thriftCompact) listI32(v int32) {
+	tc.varint(zigzag(int64(v)))
+}
+
+func (tc *thriftCompact) listBinary(s string) {
+	tc.varint(uint64(len(s)))
+	tc.buf.WriteString(s)
+}
+
+// element opens a struct in a list
+func (tc *thriftCompact) element() {
+	tc.last = append(tc.last, 0)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
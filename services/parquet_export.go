@@ -0,0 +1,203 @@
+// services/parquet_export.go
+// Partitioned Parquet export: writes a session's CDRs, or the stored CDRs of a date
+// range, as Parquet files in hive-style partitions (dt=YYYY-MM-DD/domain=X) under a
+// local directory or S3 bucket, ready for warehouses to load or query in place
+
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"o-dan-go/models"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// hiveDefaultPartition is the partition value of CDRs without a domain, as Hive names it
+const hiveDefaultPartition = "__HIVE_DEFAULT_PARTITION__"
+
+// ParquetFile is one partition file written by an export
+type ParquetFile struct {
+	Key      string `json:"key"`      // path under the destination, e.g. dt=2026-10-12/domain=acme/s1.parquet
+	Location string `json:"location"` // where the destination put it
+	Rows     int    `json:"rows"`
+	Bytes    int    `json:"bytes"`
+}
+
+// ParquetExport is the outcome of one export
+type ParquetExport struct {
+	CDRs  int           `json:"cdrs"`
+	Files []ParquetFile `json:"files"`
+}
+
+// ParquetExporter writes partitioned Parquet exports to a destination
+type ParquetExporter struct {
+	storage ArchiveStorage
+	db      *DatabaseService
+}
+
+// NewParquetExporter creates the exporter; it is disabled without a destination
+func NewParquetExporter(storage ArchiveStorage, db *DatabaseService) *ParquetExporter {
+	return &ParquetExporter{
+		storage: storage,
+		db:      db,
+	}
+}
+
+// Name is the destination scheduled searches name in their exports
+func (pe *ParquetExporter) Name() string {
+	return ScheduledExportParquet
+}
+
+// Enabled reports whether a destination is configured
+func (pe *ParquetExporter) Enabled() bool {
+	return pe != nil && pe.storage != nil
+}
+
+// Export writes a search's CDRs, so scheduled searches can export each run
+func (pe *ParquetExporter) Export(result *CDRDiscoveryResult) error {
+	_, err := pe.ExportSession(result.SessionID, result.AllCDRs, result.StartTime)
+	return err
+}
+
+// ExportSession writes a session's CDRs, one file per partition named after the session,
+// so exporting a session again replaces its files. CDRs without a start time are filed
+// under started.
+func (pe *ParquetExporter) ExportSession(sessionID string, cdrs []models.FlexibleCDR, started time.Time) (*ParquetExport, error) {
+	return pe.write(sessionID, sessionID, cdrs, started)
+}
+
+// ExportRange writes the stored CDRs that started from start to end inclusive, both
+// dates in UTC, optionally for one domain. Files are named after the range.
+func (pe *ParquetExporter) ExportRange(domain string, start, end time.Time) (*ParquetExport, error) {
+	cdrs, err := pe.db.StoredCDRs(domain, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("range-%s-%s", start.Format("20060102"), end.Format("20060102"))
+	return pe.write("", name, cdrs, start)
+}
+
+// write partitions CDRs by day and domain and puts each partition's file
+func (pe *ParquetExporter) write(sessionID, name string, cdrs []models.FlexibleCDR, started time.Time) (*ParquetExport, error) {
+	if !pe.Enabled() {
+		return nil, fmt.Errorf("no Parquet export destination is configured")
+	}
+
+	partitions := map[string][]models.FlexibleCDR{}
+	for i := range cdrs {
+		key := ParquetPartition(&cdrs[i], started)
+		partitions[key] = append(partitions[key], cdrs[i])
+	}
+	keys := make([]string, 0, len(partitions))
+	for key := range partitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	export := &ParquetExport{CDRs: len(cdrs), Files: []ParquetFile{}}
+	for _, partition := range keys {
+		var body bytes.Buffer
+		if err := WriteCDRParquet(&body, sessionID, partitions[partition], started); err != nil {
+			return export, fmt.Errorf("failed to write partition %s: %w", partition, err)
+		}
+		key := path.Join(partition, name+".parquet")
+		location, err := pe.storage.Put(key, body.Bytes(), "application/vnd.apache.parquet")
+		if err != nil {
+			return export, fmt.Errorf("failed to store %s: %w", key, err)
+		}
+		export.Files = append(export.Files, ParquetFile{Key: key, Location: location, Rows: len(partitions[partition]), Bytes: body.Len()})
+	}
+	log.Printf("[Parquet] Exported %d CDRs of %s in %d partitions to %s", len(cdrs), name, len(export.Files), pe.storage.Name())
+	return export, nil
+}
+
+// ParquetPartition is a CDR's partition directory: dt= its start date in UTC, or
+// fallback's when it has none, and domain= its domain with characters that aren't safe
+// in paths percent-escaped
+func ParquetPartition(cdr *models.FlexibleCDR, fallback time.Time) string {
+	started := fallback
+	if start := normalizedTime(cdr, normalizedFields.start); start != nil {
+		started = *start
+	}
+
+	domain := hiveDefaultPartition
+	if value := strings.ToLower(cdrFilterFirst(cdr, normalizedFields.domain...)); value != "" {
+		var escaped strings.Builder
+		for _, b := range []byte(value) {
+			if b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '.' || b == '-' || b == '_' {
+				escaped.WriteByte(b)
+			} else {
+				fmt.Fprintf(&escaped, "%%%02X", b)
+			}
+		}
+		domain = escaped.String()
+	}
+	return "dt=" + started.UTC().Format("2006-01-02") + "/domain=" + domain
+}
+
+// WriteCDRParquet writes CDRs as a Parquet file of their normalized values, the session
+// they came from (null for stored CDRs) and the raw CDR as JSON. The partition columns,
+// dt and domain, are left to the path. CDRs without a start time are filed under started.
+func WriteCDRParquet(w io.Writer, sessionID string, cdrs []models.FlexibleCDR, started time.Time) error {
+	text := func(name string) *parquetColumn {
+		return &parquetColumn{name: name, physical: parquetByteArray, converted: parquetUTF8}
+	}
+	timestamp := func(name string) *parquetColumn {
+		return &parquetColumn{name: name, physical: parquetInt64, converted: parquetTimestampMillis, optional: true}
+	}
+	integer := func(name string) *parquetColumn {
+		return &parquetColumn{name: name, physical: parquetInt32, converted: parquetNoConversion}
+	}
+	sessionColumn := text("session_id")
+	sessionColumn.optional = true
+	columns := []*parquetColumn{
+		sessionColumn, text("cdr_id"), text("direction"),
+		timestamp("start_time"), timestamp("answer_time"), timestamp("end_time"),
+		integer("duration_seconds"), integer("talk_seconds"),
+		text("orig_number"), text("term_number"), text("orig_user"), text("term_user"),
+		text("disposition"), text("call_class"), text("raw"),
+	}
+
+	millis := func(t *time.Time) interface{} {
+		if t == nil {
+			return nil
+		}
+		return t.UnixMilli()
+	}
+	var session interface{}
+	if sessionID != "" {
+		session = sessionID
+	}
+	for i := range cdrs {
+		raw, err := json.Marshal(cdrs[i].RawData)
+		if err != nil {
+			return fmt.Errorf("failed to encode CDR %s: %w", cdrs[i].GetID(), err)
+		}
+		normalized := NormalizeCDR(cdrs[i])
+		if normalized.StartTime == nil {
+			normalized.StartTime = &started
+		}
+		// Stored CDRs carry the class they were given when they were stored
+		if class := cdrs[i].GetString("call_class"); class != "" {
+			normalized.CallClass = class
+		}
+
+		values := []interface{}{
+			session, normalized.ID, normalized.Direction,
+			millis(normalized.StartTime), millis(normalized.AnswerTime), millis(normalized.EndTime),
+			int32(normalized.DurationSeconds), int32(normalized.TalkSeconds),
+			normalized.OrigNumber, normalized.TermNumber, normalized.OrigUser, normalized.TermUser,
+			normalized.Disposition, normalized.CallClass, string(raw),
+		}
+		for j, value := range values {
+			columns[j].values = append(columns[j].values, value)
+		}
+	}
+	return writeParquet(w, columns, len(cdrs))
+}
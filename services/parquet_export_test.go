@@ -0,0 +1,82 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestParquetExporter(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewDatabaseService(filepath.Join(dir, "parquet.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+	exporter := NewParquetExporter(NewArchiveStorage(ArchiveSettings{Storage: "local", Dir: filepath.Join(dir, "out")}), db)
+
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"id": "a", "domain": "Acme.example.com", "call-direction": "1", "call-start-datetime": "2026-10-12T10:00:00Z"}},
+		{RawData: map[string]interface{}{"id": "b", "domain": "acme.example.com", "call-start-datetime": "2026-10-12T23:59:00Z"}},
+		{RawData: map[string]interface{}{"id": "c", "domain": "beta/co", "call-start-datetime": "2026-10-13T00:01:00Z"}},
+		{RawData: map[string]interface{}{"id": "d"}},
+	}
+	export, err := exporter.ExportSession("s1", cdrs, time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("ExportSession: %v", err)
+	}
+
+	want := map[string]int{
+		"dt=2026-10-12/domain=acme.example.com/s1.parquet":           2,
+		"dt=2026-10-13/domain=beta%2Fco/s1.parquet":                  1,
+		"dt=2026-10-14/domain=__HIVE_DEFAULT_PARTITION__/s1.parquet": 1,
+	}
+	if export.CDRs != 4 || len(export.Files) != len(want) {
+		t.Fatalf("export = %+v, want 4 CDRs in %d files", export, len(want))
+	}
+	for _, file := range export.Files {
+		if want[file.Key] != file.Rows {
+			t.Errorf("%s has %d rows, want %d", file.Key, file.Rows, want[file.Key])
+		}
+		body, err := os.ReadFile(filepath.Join(dir, "out", filepath.FromSlash(file.Key)))
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		footer := int(binary.LittleEndian.Uint32(body[len(body)-8:]))
+		if !bytes.HasPrefix(body, []byte(parquetMagic)) || !bytes.HasSuffix(body, []byte(parquetMagic)) || footer <= 0 || footer > len(body)-12 {
+			t.Errorf("%s isn't framed as a Parquet file", file.Key)
+		}
+		if !bytes.Contains(body[len(body)-8-footer:], []byte("talk_seconds")) {
+			t.Errorf("%s footer is missing the schema", file.Key)
+		}
+	}
+
+	// Stored CDRs are exported by the day they started
+	if _, err := db.StoreCDRSummaries(cdrs[:3]); err != nil {
+		t.Fatalf("StoreCDRSummaries: %v", err)
+	}
+	day := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	export, err = exporter.ExportRange("", day, day)
+	if err != nil {
+		t.Fatalf("ExportRange: %v", err)
+	}
+	if export.CDRs != 2 || len(export.Files) != 1 || export.Files[0].Key != "dt=2026-10-12/domain=acme.example.com/range-20261012-20261012.parquet" {
+		t.Errorf("range export = %+v, want the two CDRs of 12 October", export)
+	}
+}
+
+func TestParquetLevels(t *testing.T) {
+	levels := parquetLevels([]bool{true, true, false, true})
+	want := []byte{2 << 1, 1, 1 << 1, 0, 1 << 1, 1}
+	if !bytes.Equal(levels, want) {
+		t.Errorf("parquetLevels = %v, want %v", levels, want)
+	}
+	if err := writeParquet(&bytes.Buffer{}, []*parquetColumn{{name: "x", physical: parquetInt32, converted: parquetNoConversion, values: []interface{}{nil}}}, 1); err == nil {
+		t.Error("writeParquet accepted a null in a required column")
+	}
+}
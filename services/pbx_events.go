@@ -0,0 +1,299 @@
+// services/pbx_events.go
+// Live PBX calls from NetSapiens event subscriptions, fed to the dashboard's event stream
+
+package services
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PBXEventsCallbackPath receives NetSapiens call event notifications
+const PBXEventsCallbackPath = "/pbx/events"
+
+// PBXEventsApp is the app name PBX call events are sent under, so the dashboard can
+// tell them from Web Responder calls
+const PBXEventsApp = "pbx"
+
+// pbxSubscriptionTTL is how long each subscription is requested for; it is renewed at half that
+const pbxSubscriptionTTL = 1 * time.Hour
+
+// pbxCallTimeout forgets calls whose end notification never arrived
+const pbxCallTimeout = 4 * time.Hour
+
+// PBXEventSettings configures the NetSapiens call event subscription
+type PBXEventSettings struct {
+	BaseURL     string
+	AccessToken string
+	Domain      string // domain whose calls are subscribed to
+	CallbackURL string // public URL of this server, e.g. "https://odango.example.com"
+	Secret      string // token NetSapiens must send with each notification
+}
+
+// pbxCall is what has been reported about a call so far
+type pbxCall struct {
+	answered bool
+	domain   string // domain and user whose leg of the call can be controlled
+	user     string
+	lastSeen time.Time
+}
+
+// PBXEventStats summarizes PBX event ingestion for admin introspection
+type PBXEventStats struct {
+	Enabled        bool       `json:"enabled"`
+	Domain         string     `json:"domain,omitempty"`
+	SubscriptionID string     `json:"subscription_id,omitempty"`
+	SubscribedAt   *time.Time `json:"subscribed_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	ActiveCalls    int        `json:"active_calls"`
+	Notifications  uint64     `json:"notifications"`
+	Events         uint64     `json:"events"`
+}
+
+// PBXEventService subscribes to NetSapiens call events and turns each call's progress into
+// call_started, response_sent (answered) and call_ended events on events.Manager
+type PBXEventService struct {
+	settings PBXEventSettings
+	client   *http.Client
+
+	mu             sync.Mutex
+	calls          map[string]*pbxCall
+	subscriptionID string
+	subscribedAt   time.Time
+	lastError      string
+
+	notifications atomic.Uint64
+	events        atomic.Uint64
+}
+
+// NewPBXEventService creates the service; Start subscribes when a domain and callback URL are set
+func NewPBXEventService(settings PBXEventSettings) *PBXEventService {
+	settings.BaseURL = strings.TrimRight(settings.BaseURL, "/")
+	settings.CallbackURL = strings.TrimRight(settings.CallbackURL, "/")
+	return &PBXEventService{
+		settings: settings,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		calls:    make(map[string]*pbxCall),
+	}
+}
+
+// Enabled reports whether notifications are accepted (a secret is configured)
+func (ps *PBXEventService) Enabled() bool {
+	return ps.settings.Secret != ""
+}
+
+// Authorized checks the token a notification was sent with
+func (ps *PBXEventService) Authorized(token string) bool {
+	return ps.Enabled() && subtle.ConstantTimeCompare([]byte(token), []byte(ps.settings.Secret)) == 1
+}
+
+// Start keeps the call subscription alive in the background, renewing it before it expires
+func (ps *PBXEventService) Start() {
+	if !ps.Enabled() {
+		return
+	}
+	if ps.settings.Domain == "" || ps.settings.CallbackURL == "" || ps.settings.AccessToken == "" {
+		log.Printf("[PBX] Event subscription not configured, accepting notifications only")
+		return
+	}
+
+	go func() {
+		for {
+			if err := ps.subscribe(); err != nil {
+				log.Printf("[PBX] Failed to subscribe to %s call events: %v", ps.settings.Domain, err)
+				time.Sleep(time.Minute)
+				continue
+			}
+			time.Sleep(pbxSubscriptionTTL / 2)
+		}
+	}()
+}
+
+// subscribe registers this server's callback URL for the domain's call events, or extends
+// the current subscription so notifications aren't delivered twice
+func (ps *PBXEventService) subscribe() error {
+	ps.mu.Lock()
+	subscriptionID := ps.subscriptionID
+	ps.mu.Unlock()
+
+	if subscriptionID != "" {
+		err := ps.sendSubscription("PUT", "/ns-api/v2/subscriptions/"+url.PathEscape(subscriptionID))
+		if err == nil {
+			return nil
+		}
+		log.Printf("[PBX] Failed to renew subscription %s, creating a new one: %v", subscriptionID, err)
+	}
+	return ps.sendSubscription("POST", "/ns-api/v2/subscriptions")
+}
+
+// sendSubscription creates (POST) or updates (PUT) the call subscription at path
+func (ps *PBXEventService) sendSubscription(method, path string) error {
+	callback := ps.settings.CallbackURL + PBXEventsCallbackPath + "?token=" + url.QueryEscape(ps.settings.Secret)
+	payload, err := json.Marshal(map[string]string{
+		"model":                         "call",
+		"domain":                        ps.settings.Domain,
+		"post-url":                      callback,
+		"subscription-expires-datetime": time.Now().Add(pbxSubscriptionTTL).UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(method, ps.settings.BaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+ps.settings.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := ps.client.Do(req)
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	if err != nil {
+		ps.lastError = err.Error()
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		ps.lastError = fmt.Sprintf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		return fmt.Errorf("subscription returned %s", ps.lastError)
+	}
+
+	var created map[string]interface{}
+	if json.Unmarshal(body, &created) == nil {
+		if id := recordString(created, "id", "subscription-id"); id != "" {
+			ps.subscriptionID = id
+		}
+	}
+	ps.subscribedAt = time.Now()
+	ps.lastError = ""
+	log.Printf("[PBX] Subscribed to %s call events (subscription %s)", ps.settings.Domain, ps.subscriptionID)
+	return nil
+}
+
+// Ingest turns a notification body (one call record or an array of them) into call events
+func (ps *PBXEventService) Ingest(body []byte) (int, error) {
+	var records []map[string]interface{}
+	if err := json.Unmarshal(body, &records); err != nil {
+		var record map[string]interface{}
+		if err := json.Unmarshal(body, &record); err != nil {
+			return 0, fmt.Errorf("notification is not a call record or list of records: %w", err)
+		}
+		records = []map[string]interface{}{record}
+	}
+	ps.notifications.Add(1)
+
+	sent := 0
+	for _, record := range records {
+		sent += ps.ingestCall(record)
+	}
+	ps.events.Add(uint64(sent))
+	return sent, nil
+}
+
+// ingestCall sends the events a call record implies, returning how many were sent
+func (ps *PBXEventService) ingestCall(record map[string]interface{}) int {
+	callID := recordString(record, "orig_callid", "orig-callid", "call-id", "callid")
+	if callID == "" {
+		return 0
+	}
+	caller := recordString(record, "orig_from_user", "orig-from-user", "from-user", "orig_from_uri")
+	if user, _, found := strings.Cut(caller, "@"); found {
+		caller = strings.TrimPrefix(user, "sip:")
+	}
+	answeredBy := recordString(record, "term_to_user", "term-to-user", "to-user")
+	removed := recordString(record, "remove", "removed")
+	ended := removed == "yes" || removed == "true"
+	answerTime := recordString(record, "time_answer", "time-answer")
+	answered := (answerTime != "" && answerTime != "0" && !strings.HasPrefix(answerTime, "0000")) ||
+		recordString(record, "call-state", "status") == "answered"
+	domain := recordString(record, "term_domain", "term-domain", "orig_domain", "orig-domain", "domain")
+	user := recordString(record, "term_user", "term-user", "orig_user", "orig-user")
+
+	now := time.Now()
+	ps.mu.Lock()
+	for id, call := range ps.calls {
+		if now.Sub(call.lastSeen) > pbxCallTimeout {
+			delete(ps.calls, id)
+		}
+	}
+	call, known := ps.calls[callID]
+	if !known && !ended {
+		call = &pbxCall{}
+		ps.calls[callID] = call
+	}
+	newlyAnswered := answered && call != nil && !call.answered
+	if call != nil {
+		call.answered = call.answered || answered
+		call.lastSeen = now
+		if domain != "" && user != "" {
+			call.domain, call.user = domain, user
+		}
+	}
+	if ended {
+		delete(ps.calls, callID)
+	}
+	ps.mu.Unlock()
+
+	sessionID := "pbx_" + callID
+	sent := 0
+	if !known && !ended {
+		dialed := recordString(record, "orig_to_user", "orig-to-user", "dialed")
+		sendCallEvent(PBXEventsApp, sessionID, callID, caller, "call_started", fmt.Sprintf("PBX call to %s", dialed))
+		sent++
+	}
+	if newlyAnswered {
+		sendCallEvent(PBXEventsApp, sessionID, callID, caller, "response_sent", fmt.Sprintf("Answered by %s", answeredBy))
+		sent++
+	}
+	if ended && known {
+		sendCallEvent(PBXEventsApp, sessionID, callID, caller, "call_ended", "PBX call ended")
+		sent++
+	}
+	return sent
+}
+
+// LocateCall returns the domain and user a live PBX call can be controlled through
+func (ps *PBXEventService) LocateCall(callID string) (string, string, bool) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	call, exists := ps.calls[callID]
+	if !exists || call.user == "" {
+		return "", "", false
+	}
+	return call.domain, call.user, true
+}
+
+// Stats returns the subscription state and counters
+func (ps *PBXEventService) Stats() PBXEventStats {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	stats := PBXEventStats{
+		Enabled:        ps.Enabled(),
+		Domain:         ps.settings.Domain,
+		SubscriptionID: ps.subscriptionID,
+		LastError:      ps.lastError,
+		ActiveCalls:    len(ps.calls),
+		Notifications:  ps.notifications.Load(),
+		Events:         ps.events.Load(),
+	}
+	if !ps.subscribedAt.IsZero() {
+		subscribedAt := ps.subscribedAt
+		stats.SubscribedAt = &subscribedAt
+	}
+	return stats
+}
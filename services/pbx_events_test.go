@@ -0,0 +1,55 @@
+package services
+
+import "testing"
+
+func TestPBXEventsIngestCallLifecycle(t *testing.T) {
+	ps := NewPBXEventService(PBXEventSettings{Secret: "secret"})
+
+	notifications := []struct {
+		body string
+		want int
+	}{
+		{`{"orig_callid": "abc", "orig_from_user": "4155551234", "orig_to_user": "100", "time_answer": "0000-00-00 00:00:00"}`, 1},
+		{`[{"orig_callid": "abc", "orig_from_user": "4155551234", "time_answer": "2026-10-16 09:00:05"}]`, 1},
+		{`[{"orig_callid": "abc", "orig_from_user": "4155551234", "time_answer": "2026-10-16 09:00:05", "term_domain": "acme", "term_user": "100"}]`, 0},
+	}
+	for i, n := range notifications {
+		sent, err := ps.Ingest([]byte(n.body))
+		if err != nil {
+			t.Fatalf("notification %d: %v", i, err)
+		}
+		if sent != n.want {
+			t.Errorf("notification %d sent %d events, want %d", i, sent, n.want)
+		}
+	}
+	if domain, user, ok := ps.LocateCall("abc"); !ok || domain != "acme" || user != "100" {
+		t.Errorf("LocateCall = %s, %s, %v", domain, user, ok)
+	}
+
+	notifications = []struct {
+		body string
+		want int
+	}{
+		{`[{"orig_callid": "abc", "orig_from_user": "4155551234", "remove": "yes"}]`, 1},
+		{`[{"orig_callid": "abc", "remove": "yes"}]`, 0},
+	}
+	for i, n := range notifications {
+		sent, err := ps.Ingest([]byte(n.body))
+		if err != nil {
+			t.Fatalf("notification %d: %v", i, err)
+		}
+		if sent != n.want {
+			t.Errorf("notification %d sent %d events, want %d", i, sent, n.want)
+		}
+	}
+
+	if _, err := ps.Ingest([]byte("not json")); err == nil {
+		t.Error("expected invalid notification to be rejected")
+	}
+	if stats := ps.Stats(); stats.ActiveCalls != 0 || stats.Events != 3 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if !ps.Authorized("secret") || ps.Authorized("wrong") {
+		t.Error("token check failed")
+	}
+}
@@ -0,0 +1,301 @@
+// services/preferences.go
+// Per-user preferences persisted in SQLite and applied to search, results and exports
+
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"o-dan-go/models"
+	"regexp"
+	"time"
+)
+
+// ExportColumns lists every column that can appear in the results preview and CSV export
+var ExportColumns = []string{
+	"call_id",
+	"domain",
+	"user",
+	"orig_number",
+	"orig_caller_name",
+	"term_number",
+	"start_time",
+	"end_time",
+	"duration",
+	"call_type",
+	"direction",
+	"disposition",
+	"call_class",
+	"session_id",
+	"orig_carrier",
+	"orig_line_type",
+	"orig_lrn",
+	"orig_ported",
+	"term_carrier",
+	"term_line_type",
+	"term_lrn",
+	"term_ported",
+}
+
+// DefaultCSVColumns are exported to CSV when a user has not chosen their own columns
+var DefaultCSVColumns = []string{
+	"call_id",
+	"domain",
+	"user",
+	"orig_number",
+	"term_number",
+	"start_time",
+	"end_time",
+	"duration",
+	"call_type",
+	"direction",
+	"disposition",
+	"session_id",
+}
+
+// DefaultVisibleColumns are shown when a user has not chosen their own columns
+var DefaultVisibleColumns = []string{
+	"call_id",
+	"domain",
+	"orig_number",
+	"term_number",
+	"start_time",
+	"duration",
+}
+
+// maxExportFields limits how many computed columns a user can define
+const maxExportFields = 20
+
+// exportFieldName is the form of a computed column's name
+var exportFieldName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// ExportField is a computed column: a JMESPath expression evaluated over the raw CDR
+// JSON, e.g. "legs[-1].carrier" or "join(',', tags)"
+type ExportField struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+
+	path *JMESPath
+}
+
+// Value evaluates the field on a CDR, empty when the expression finds nothing
+func (f *ExportField) Value(cdr *models.FlexibleCDR) string {
+	if f.path == nil {
+		path, err := CompileJMESPath(f.Expression)
+		if err != nil {
+			return ""
+		}
+		f.path = path
+	}
+	return JMESPathString(f.path.Search(cdr.RawData))
+}
+
+// UserPreferences holds the defaults a user has chosen for the web interface
+type UserPreferences struct {
+	UserID              string        `json:"user_id"`
+	DefaultLimit        int           `json:"default_limit"`
+	DefaultExportFormat string        `json:"default_export_format"`
+	VisibleColumns      []string      `json:"visible_columns"`
+	ColumnsChosen       bool          `json:"-"`             // false while VisibleColumns are the defaults
+	ExportFields        []ExportField `json:"export_fields"` // computed columns, shown after the visible columns
+	Timezone            string        `json:"timezone"`
+	UpdatedAt           time.Time     `json:"updated_at"`
+}
+
+// DefaultUserPreferences returns the preferences used before a user saves their own
+func DefaultUserPreferences(userID string) *UserPreferences {
+	columns := make([]string, len(DefaultVisibleColumns))
+	copy(columns, DefaultVisibleColumns)
+
+	return &UserPreferences{
+		UserID:              userID,
+		DefaultLimit:        100,
+		DefaultExportFormat: "csv",
+		VisibleColumns:      columns,
+		ExportFields:        []ExportField{},
+		Timezone:            "UTC",
+	}
+}
+
+// Validate checks that preference values are usable
+func (p *UserPreferences) Validate() error {
+	if p.DefaultLimit < 1 || p.DefaultLimit > 5000 {
+		return fmt.Errorf("default_limit must be between 1 and 5000")
+	}
+
+	switch p.DefaultExportFormat {
+	case "csv", "json", "xml":
+	default:
+		return fmt.Errorf("unsupported default_export_format: %s", p.DefaultExportFormat)
+	}
+
+	if len(p.VisibleColumns) == 0 {
+		return fmt.Errorf("at least one visible column is required")
+	}
+	for _, column := range p.VisibleColumns {
+		if !isExportColumn(column) {
+			return fmt.Errorf("unknown column: %s", column)
+		}
+	}
+
+	if len(p.ExportFields) > maxExportFields {
+		return fmt.Errorf("at most %d export fields can be defined", maxExportFields)
+	}
+	names := make(map[string]bool)
+	for i := range p.ExportFields {
+		field := &p.ExportFields[i]
+		if !exportFieldName.MatchString(field.Name) {
+			return fmt.Errorf("export field name %q must be lowercase letters, digits and underscores", field.Name)
+		}
+		if isExportColumn(field.Name) || names[field.Name] {
+			return fmt.Errorf("export field name %q is already a column", field.Name)
+		}
+		names[field.Name] = true
+		path, err := CompileJMESPath(field.Expression)
+		if err != nil {
+			return fmt.Errorf("export field %s: %w", field.Name, err)
+		}
+		field.path = path
+	}
+
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %s: %w", p.Timezone, err)
+	}
+
+	return nil
+}
+
+// Location returns the user's timezone, falling back to UTC
+func (p *UserPreferences) Location() *time.Location {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Columns returns the visible columns in canonical export order, then the export fields
+func (p *UserPreferences) Columns() []string {
+	var columns []string
+	for _, column := range ExportColumns {
+		for _, visible := range p.VisibleColumns {
+			if column == visible {
+				columns = append(columns, column)
+				break
+			}
+		}
+	}
+	for _, field := range p.ExportFields {
+		columns = append(columns, field.Name)
+	}
+	return columns
+}
+
+// CSVColumns returns the columns of a CSV export: the visible columns if the user has
+// chosen them, otherwise DefaultCSVColumns, then the export fields
+func (p *UserPreferences) CSVColumns() []string {
+	if p.ColumnsChosen {
+		return p.Columns()
+	}
+	columns := append([]string{}, DefaultCSVColumns...)
+	for _, field := range p.ExportFields {
+		columns = append(columns, field.Name)
+	}
+	return columns
+}
+
+// ExportField returns the export field named name, or nil
+func (p *UserPreferences) ExportField(name string) *ExportField {
+	for i := range p.ExportFields {
+		if p.ExportFields[i].Name == name {
+			return &p.ExportFields[i]
+		}
+	}
+	return nil
+}
+
+// isExportColumn checks if a column name is a known export column
+func isExportColumn(name string) bool {
+	for _, column := range ExportColumns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUserPreferences loads preferences for a user, returning defaults if none are stored
+func (ds *DatabaseService) GetUserPreferences(userID string) (*UserPreferences, error) {
+	query := `
+	SELECT default_limit, default_export_format, visible_columns, COALESCE(export_fields, '[]'), timezone, updated_at
+	FROM user_preferences WHERE user_id = ?`
+
+	prefs := DefaultUserPreferences(userID)
+	var columnsJSON, fieldsJSON string
+
+	err := ds.db.QueryRow(query, userID).Scan(
+		&prefs.DefaultLimit, &prefs.DefaultExportFormat,
+		&columnsJSON, &fieldsJSON, &prefs.Timezone, &prefs.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return prefs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Users who haven't chosen columns have them stored as null and keep the defaults
+	prefs.ColumnsChosen = columnsJSON != "null"
+	if prefs.ColumnsChosen {
+		if err := json.Unmarshal([]byte(columnsJSON), &prefs.VisibleColumns); err != nil {
+			return nil, fmt.Errorf("failed to decode visible columns: %w", err)
+		}
+	}
+	if err := json.Unmarshal([]byte(fieldsJSON), &prefs.ExportFields); err != nil {
+		return nil, fmt.Errorf("failed to decode export fields: %w", err)
+	}
+
+	return prefs, nil
+}
+
+// SaveUserPreferences validates and stores preferences for a user
+func (ds *DatabaseService) SaveUserPreferences(prefs *UserPreferences) error {
+	if err := prefs.Validate(); err != nil {
+		return err
+	}
+
+	columnsJSON := []byte("null")
+	if prefs.ColumnsChosen {
+		var err error
+		if columnsJSON, err = json.Marshal(prefs.VisibleColumns); err != nil {
+			return err
+		}
+	}
+	if prefs.ExportFields == nil {
+		prefs.ExportFields = []ExportField{}
+	}
+	fieldsJSON, err := json.Marshal(prefs.ExportFields)
+	if err != nil {
+		return err
+	}
+
+	prefs.UpdatedAt = time.Now()
+
+	query := `
+	INSERT OR REPLACE INTO user_preferences (
+		user_id, default_limit, default_export_format, visible_columns, export_fields, timezone, updated_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	_, err = ds.db.Exec(query,
+		prefs.UserID,
+		prefs.DefaultLimit,
+		prefs.DefaultExportFormat,
+		string(columnsJSON),
+		string(fieldsJSON),
+		prefs.Timezone,
+		prefs.UpdatedAt,
+	)
+
+	return err
+}
@@ -0,0 +1,148 @@
+// services/presence.go
+// User presence (available, on a call, do not disturb) from the NetSapiens presence API
+
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Presence states, normalized from the values NetSapiens reports
+const (
+	PresenceAvailable = "available"
+	PresenceOnCall    = "on_call"
+	PresenceRinging   = "ringing"
+	PresenceDND       = "dnd"
+	PresenceOffline   = "offline"
+	PresenceUnknown   = "unknown"
+)
+
+// presenceCacheTTL keeps dashboards that poll from hitting the API on every request
+const presenceCacheTTL = 10 * time.Second
+
+// UserPresence is one user's current state
+type UserPresence struct {
+	User        string `json:"user"`
+	Name        string `json:"name,omitempty"`
+	State       string `json:"state"`     // one of the Presence constants
+	RawState    string `json:"raw_state"` // what NetSapiens reported
+	Message     string `json:"message,omitempty"`
+	ActiveCalls int    `json:"active_calls"`
+}
+
+// DomainPresence is every user's presence in a domain
+type DomainPresence struct {
+	Domain    string         `json:"domain"`
+	Users     []UserPresence `json:"users"`
+	Counts    map[string]int `json:"counts"` // users per state
+	FetchedAt time.Time      `json:"fetched_at"`
+}
+
+// PresenceService reads user presence from the NetSapiens v2 API
+type PresenceService struct {
+	client      *http.Client
+	baseURL     string
+	accessToken string
+
+	mu    sync.Mutex
+	cache map[string]DomainPresence
+}
+
+// NewPresenceService creates a presence client
+func NewPresenceService(baseURL, accessToken string) *PresenceService {
+	return &PresenceService{
+		client:      &http.Client{Timeout: 10 * time.Second},
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		accessToken: accessToken,
+		cache:       make(map[string]DomainPresence),
+	}
+}
+
+// GetDomainPresence returns every user's presence in domain, sorted by user
+func (ps *PresenceService) GetDomainPresence(domain string) (DomainPresence, error) {
+	ps.mu.Lock()
+	cached, exists := ps.cache[domain]
+	ps.mu.Unlock()
+	if exists && time.Since(cached.FetchedAt) < presenceCacheTTL {
+		return cached, nil
+	}
+
+	if ps.accessToken == "" {
+		return DomainPresence{}, fmt.Errorf("no NetSapiens access token configured")
+	}
+
+	var records []map[string]interface{}
+	path := fmt.Sprintf("/ns-api/v2/domains/%s/presence", url.PathEscape(domain))
+	if err := netsapiensGet(ps.client, ps.baseURL, ps.accessToken, path, &records); err != nil {
+		return DomainPresence{}, err
+	}
+
+	presence := DomainPresence{
+		Domain:    domain,
+		Users:     make([]UserPresence, 0, len(records)),
+		Counts:    make(map[string]int),
+		FetchedAt: time.Now(),
+	}
+	for _, record := range records {
+		user := parsePresence(record)
+		if user.User == "" {
+			continue
+		}
+		presence.Users = append(presence.Users, user)
+		presence.Counts[user.State]++
+	}
+	sort.Slice(presence.Users, func(i, j int) bool {
+		return presence.Users[i].User < presence.Users[j].User
+	})
+
+	ps.mu.Lock()
+	ps.cache[domain] = presence
+	ps.mu.Unlock()
+	return presence, nil
+}
+
+// parsePresence reads one presence record, whichever field names the API version uses
+func parsePresence(record map[string]interface{}) UserPresence {
+	presence := UserPresence{
+		User:     recordString(record, "user", "subscriber", "aor", "login-username"),
+		Name:     recordString(record, "name-full-name", "name"),
+		RawState: recordString(record, "presence", "presence-state", "status", "state"),
+		Message:  recordString(record, "message", "presence-message", "note"),
+	}
+	if user, _, found := strings.Cut(presence.User, "@"); found {
+		presence.User = strings.TrimPrefix(user, "sip:")
+	}
+	presence.ActiveCalls, _ = strconv.Atoi(recordString(record, "active-calls", "calls", "session_count"))
+	presence.State = NormalizePresence(presence.RawState, presence.ActiveCalls)
+	return presence
+}
+
+// NormalizePresence maps a NetSapiens presence value to one of the Presence states.
+// A user with active calls is on a call whatever their status says.
+func NormalizePresence(raw string, activeCalls int) string {
+	if activeCalls > 0 {
+		return PresenceOnCall
+	}
+
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "open", "available", "online", "idle":
+		return PresenceAvailable
+	case "inuse", "in-use", "busy", "on-call", "oncall", "progressing", "active":
+		return PresenceOnCall
+	case "ringing", "alerting", "early":
+		return PresenceRinging
+	case "dnd", "do-not-disturb", "away":
+		return PresenceDND
+	case "closed", "offline", "unavailable", "unregistered":
+		return PresenceOffline
+	default:
+		return PresenceUnknown
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetDomainPresence(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/ns-api/v2/domains/acme/presence" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"user": "102", "presence": "open"},
+			{"user": "sip:101@acme", "presence": "open", "active-calls": 1},
+			{"user": "103", "presence": "dnd"},
+			{"presence": "open"},
+		})
+	}))
+	defer server.Close()
+
+	ps := NewPresenceService(server.URL, "token")
+	presence, err := ps.GetDomainPresence("acme")
+	if err != nil {
+		t.Fatalf("GetDomainPresence: %v", err)
+	}
+	if len(presence.Users) != 3 || presence.Users[0].User != "101" || presence.Users[0].State != PresenceOnCall {
+		t.Fatalf("unexpected users: %+v", presence.Users)
+	}
+	if presence.Counts[PresenceAvailable] != 1 || presence.Counts[PresenceDND] != 1 {
+		t.Fatalf("unexpected counts: %v", presence.Counts)
+	}
+
+	if _, err := ps.GetDomainPresence("acme"); err != nil || requests != 1 {
+		t.Fatalf("expected cached presence, got %d requests (%v)", requests, err)
+	}
+}
+
+func TestNormalizePresence(t *testing.T) {
+	tests := map[string]string{
+		"open":    PresenceAvailable,
+		"InUse":   PresenceOnCall,
+		"ringing": PresenceRinging,
+		"closed":  PresenceOffline,
+		"":        PresenceUnknown,
+	}
+	for raw, want := range tests {
+		if got := NormalizePresence(raw, 0); got != want {
+			t.Errorf("NormalizePresence(%q) = %s, want %s", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// services/query_plan.go
+// Count-first query planning: before a search pulls CDRs, asks each data endpoint's count
+// endpoint how many CDRs match, then skips endpoints with nothing to return, splits large
+// pulls into pages fetched in parallel and refuses multi-million-record pulls unless the
+// search allows them
+
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"o-dan-go/models"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	planMaxPageSize = 1000    // CDRs per request when a pull is split into pages
+	planMaxParallel = 4       // pages of one endpoint fetched at a time
+	planLargePull   = 1000000 // CDRs a search may plan to fetch without AllowLargePull
+)
+
+// ErrLargePull is returned for a count-first search that would fetch planLargePull CDRs or
+// more without AllowLargePull
+var ErrLargePull = errors.New("large pull refused")
+
+// countEndpoints names the count endpoint that estimates each data endpoint
+var countEndpoints = map[string]string{
+	"global_cdrs": "global_count",
+	"domain_cdrs": "domain_count",
+	"user_cdrs":   "user_count",
+}
+
+// QueryPlan is what the count endpoints estimated before a count-first search pulled data
+type QueryPlan struct {
+	Estimates     []EndpointEstimate `json:"estimates"`
+	EstimatedCDRs int                `json:"estimated_cdrs"` // matching CDRs, summed over endpoints that counted; endpoints overlap
+	PlannedCDRs   int                `json:"planned_cdrs"`   // CDRs the search fetches within its limit
+	Warnings      []string           `json:"warnings,omitempty"`
+}
+
+// EndpointEstimate is one data endpoint's count and how it is fetched
+type EndpointEstimate struct {
+	EndpointName  string        `json:"endpoint_name"`
+	CountEndpoint string        `json:"count_endpoint,omitempty"`
+	Counted       bool          `json:"counted"` // false when the endpoint has no count endpoint or counting failed
+	Count         int           `json:"count"`
+	Error         string        `json:"error,omitempty"`
+	QueryTime     time.Duration `json:"query_time"`
+	Fetch         int           `json:"fetch"` // CDRs to fetch: the count within the limit
+	PageSize      int           `json:"page_size"`
+	Pages         int           `json:"pages"`
+	Parallel      int           `json:"parallel"`
+}
+
+// estimate returns the plan for an endpoint, or nil
+func (p *QueryPlan) estimate(endpoint string) *EndpointEstimate {
+	if p == nil {
+		return nil
+	}
+	for i := range p.Estimates {
+		if p.Estimates[i].EndpointName == endpoint {
+			return &p.Estimates[i]
+		}
+	}
+	return nil
+}
+
+// planQueries counts what each endpoint would return and plans its pages. Endpoints that
+// can't be counted are fetched as they would be without a plan, in one request.
+func (cds *CDRDiscoveryService) planQueries(endpoints []CDREndpointConfig, criteria CDRSearchCriteria) *QueryPlan {
+	counters := map[string]CDREndpointConfig{}
+	for _, endpoint := range cds.GetSupportedEndpoints() {
+		counters[endpoint.Name] = endpoint
+	}
+
+	plan := &QueryPlan{Estimates: []EndpointEstimate{}}
+	for _, endpoint := range endpoints {
+		estimate := EndpointEstimate{
+			EndpointName:  endpoint.Name,
+			CountEndpoint: countEndpoints[endpoint.Name],
+			Fetch:         criteria.Limit,
+			PageSize:      criteria.Limit,
+			Pages:         1,
+			Parallel:      1,
+		}
+		if counter, exists := counters[estimate.CountEndpoint]; exists {
+			queryStart := time.Now()
+			estimate.Count, estimate.Error = cds.countCDRs(counter, criteria)
+			estimate.QueryTime = time.Since(queryStart)
+			estimate.Counted = estimate.Error == ""
+		}
+		if estimate.Counted {
+			plan.EstimatedCDRs += estimate.Count
+			estimate.Fetch = min(criteria.Limit, estimate.Count)
+			estimate.PageSize = max(1, min(estimate.Fetch, planMaxPageSize))
+			estimate.Pages = (estimate.Fetch + estimate.PageSize - 1) / estimate.PageSize
+			estimate.Parallel = max(1, min(estimate.Pages, planMaxParallel))
+			if estimate.Count > criteria.Limit {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s matches about %d CDRs; only the first %d are fetched",
+					endpoint.Name, estimate.Count, criteria.Limit))
+			}
+		} else if estimate.CountEndpoint != "" {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s couldn't be counted (%s); fetching up to %d CDRs",
+				endpoint.Name, estimate.Error, criteria.Limit))
+		}
+		plan.PlannedCDRs += estimate.Fetch
+		plan.Estimates = append(plan.Estimates, estimate)
+	}
+
+	if plan.PlannedCDRs >= planLargePull {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("this search would fetch about %d CDRs", plan.PlannedCDRs))
+	}
+	return plan
+}
+
+// countCDRs asks a count endpoint how many CDRs match the criteria, returning the count
+// or why it couldn't
+func (cds *CDRDiscoveryService) countCDRs(counter CDREndpointConfig, criteria CDRSearchCriteria) (int, string) {
+	criteria.Start, criteria.Limit = 0, 0
+	url, err := cds.buildEndpointURL(counter, criteria)
+	if err != nil {
+		return 0, fmt.Sprintf("URL build error: %v", err)
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, fmt.Sprintf("Request creation error: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+cds.accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := cds.client.Do(req)
+	if err != nil {
+		return 0, fmt.Sprintf("HTTP request error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Sprintf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+
+	var response interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return 0, fmt.Sprintf("JSON decode error: %v", err)
+	}
+	count, ok := parseCDRCount(response)
+	if !ok {
+		return 0, "response has no count"
+	}
+	return count, ""
+}
+
+// parseCDRCount reads a count endpoint's answer: {"count": n} ("total" also works), a
+// list holding one such object, or a bare number
+func parseCDRCount(response interface{}) (int, bool) {
+	switch value := response.(type) {
+	case float64:
+		return int(value), value >= 0
+	case string:
+		count, err := strconv.Atoi(value)
+		return count, err == nil && count >= 0
+	case []interface{}:
+		if len(value) == 1 {
+			return parseCDRCount(value[0])
+		}
+	case map[string]interface{}:
+		for _, key := range []string{"count", "total", "total_count"} {
+			if count, exists := value[key]; exists {
+				return parseCDRCount(count)
+			}
+		}
+	}
+	return 0, false
+}
+
+// queryEndpointPages fetches an endpoint's planned pages, up to the estimate's parallel
+// pages at a time, and joins them in order. A failed page fails the endpoint, as a failed
+// request does.
+func (cds *CDRDiscoveryService) queryEndpointPages(endpointConfig CDREndpointConfig, criteria CDRSearchCriteria, estimate *EndpointEstimate) EndpointResult {
+	queryStart := time.Now()
+	pages := make([]EndpointResult, estimate.Pages)
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, estimate.Parallel)
+	for i := range pages {
+		pageCriteria := criteria
+		pageCriteria.Start = criteria.Start + i*estimate.PageSize
+		pageCriteria.Limit = min(estimate.PageSize, estimate.Fetch-i*estimate.PageSize)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			pages[i] = cds.queryEndpoint(endpointConfig, pageCriteria)
+		}(i)
+	}
+	wg.Wait()
+
+	result := pages[0]
+	result.CDRs = []models.FlexibleCDR{}
+	result.Pages = len(pages)
+	for i, page := range pages {
+		if !page.Success {
+			result.Success, result.HTTPStatus = false, page.HTTPStatus
+			result.Error = fmt.Sprintf("page %d of %d: %s", i+1, len(pages), page.Error)
+			result.CDRs = []models.FlexibleCDR{}
+			break
+		}
+		result.CDRs = append(result.CDRs, page.CDRs...)
+	}
+	result.RecordCount = len(result.CDRs)
+	result.QueryTime = time.Since(queryStart)
+	return result
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCountFirstSearch(t *testing.T) {
+	mock, err := NewMockNetSapiens(MockNetSapiensSettings{CDRs: 2500, Seed: 3})
+	if err != nil {
+		t.Fatalf("NewMockNetSapiens: %v", err)
+	}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+	discovery := NewCDRDiscoveryService(server.URL, "token")
+	discovery.debug = false
+
+	plain, err := discovery.GetComprehensiveCDRs(CDRSearchCriteria{Limit: 2200})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	counted, err := discovery.GetComprehensiveCDRs(CDRSearchCriteria{Limit: 2200, CountFirst: true})
+	if err != nil {
+		t.Fatalf("count-first search: %v", err)
+	}
+	estimate := counted.Plan.estimate("global_cdrs")
+	if !estimate.Counted || estimate.Count != 2500 || estimate.Fetch != 2200 || estimate.PageSize != planMaxPageSize ||
+		estimate.Pages != 3 || estimate.Parallel != 3 {
+		t.Fatalf("global estimate = %+v, want 2200 of 2500 CDRs in 3 pages", estimate)
+	}
+	if len(counted.Plan.Warnings) != 1 || !strings.Contains(counted.Plan.Warnings[0], "about 2500 CDRs") {
+		t.Errorf("warnings = %v, want the limit cutting the search short", counted.Plan.Warnings)
+	}
+	if counted.UniqueCDRs != plain.UniqueCDRs || counted.EndpointResults[0].Pages != 3 {
+		t.Errorf("paged search found %d CDRs in %d pages, want %d as one request found", counted.UniqueCDRs, counted.EndpointResults[0].Pages, plain.UniqueCDRs)
+	}
+	for i := range plain.AllCDRs {
+		if counted.AllCDRs[i].GetID() != plain.AllCDRs[i].GetID() {
+			t.Fatalf("CDR %d is %s, want pages joined in order (%s)", i, counted.AllCDRs[i].GetID(), plain.AllCDRs[i].GetID())
+		}
+	}
+
+	// An endpoint that counts nothing isn't queried; the site endpoint can't be counted
+	nobody, err := discovery.GetComprehensiveCDRs(CDRSearchCriteria{Domain: "acme.example.com", User: "nobody", Site: "hq", Limit: 10, CountFirst: true})
+	if err != nil {
+		t.Fatalf("count-first user search: %v", err)
+	}
+	for _, result := range nobody.EndpointResults {
+		if result.EndpointName == "user_cdrs" {
+			t.Error("user_cdrs was queried though it counted no CDRs")
+		}
+	}
+	if len(nobody.Skipped) != 1 || nobody.Skipped[0].EndpointName != "user_cdrs" {
+		t.Errorf("skipped = %+v, want user_cdrs", nobody.Skipped)
+	}
+	if site := nobody.Plan.estimate("site_cdrs"); site.Counted || site.CountEndpoint != "" || site.Fetch != 10 {
+		t.Errorf("site estimate = %+v, want it fetched uncounted", site)
+	}
+}
+
+func TestCountFirstLargePull(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if strings.HasSuffix(r.URL.Path, "/count") {
+			w.Write([]byte(`[{"total": "3000000", "sum": 0}]`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+	discovery := NewCDRDiscoveryService(server.URL, "token")
+	discovery.debug = false
+
+	result, err := discovery.GetComprehensiveCDRs(CDRSearchCriteria{Limit: planLargePull, CountFirst: true})
+	if err == nil || result.Plan.PlannedCDRs != planLargePull || requests.Load() != 1 {
+		t.Fatalf("large pull = %v after %d requests, plan %+v; want it refused after counting", err, requests.Load(), result.Plan)
+	}
+	if _, err := discovery.GetComprehensiveCDRs(CDRSearchCriteria{Limit: planLargePull, CountFirst: true, AllowLargePull: true}); err != nil {
+		t.Errorf("allowed large pull: %v", err)
+	}
+
+	for response, want := range map[string]int{`{"count": 12}`: 12, `7`: 7, `"40"`: 40, `{"total_count": 0}`: 0} {
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(response), &parsed); err != nil {
+			t.Fatal(err)
+		}
+		if count, ok := parseCDRCount(parsed); !ok || count != want {
+			t.Errorf("parseCDRCount(%s) = %d, %t; want %d", response, count, ok, want)
+		}
+	}
+	if _, ok := parseCDRCount(map[string]interface{}{"sum": 3.0}); ok {
+		t.Error("parseCDRCount accepted a response without a count")
+	}
+}
@@ -0,0 +1,226 @@
+// services/queue_report.go
+// Queue statistics from CDRs: the legs of a session's calls that went through a call
+// queue or ring group, with how long callers waited, which agents answered and how
+// many callers hung up first, per queue
+
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"o-dan-go/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queueNameFields name the queue a leg went through, when the PBX records it
+var queueNameFields = []string{"call-queue", "call-queue-name", "call-term-queue", "queue"}
+
+// queueApplicationFields describe what answered a leg; a queue or ring group names itself there
+var queueApplicationFields = []string{"call-term-application", "call-term-user-type"}
+
+// queueApplicationPatterns mark a leg answered by a queue or ring group
+var queueApplicationPatterns = []string{"queue", "hunt", "ringgroup", "ring group", "ring-group"}
+
+// queueAgentFields name the agent who took a queue call
+var queueAgentFields = []string{"call-queue-agent", "call-answered-by", "call-answered-by-user"}
+
+// queueWaitFields are how long the caller waited in the queue, in seconds
+var queueWaitFields = []string{"call-queue-wait-seconds", "call-time-queued-seconds"}
+
+// QueueStats is one queue's calls
+type QueueStats struct {
+	Queue             string            `json:"queue"`
+	Calls             int               `json:"calls"`
+	Answered          int               `json:"answered"`
+	Abandoned         int               `json:"abandoned"` // the caller hung up before an agent answered
+	AbandonPercent    float64           `json:"abandon_percent"`
+	AvgWaitSeconds    float64           `json:"avg_wait_seconds"` // of answered calls
+	MaxWaitSeconds    int               `json:"max_wait_seconds"`
+	AvgAbandonSeconds float64           `json:"avg_abandon_seconds"` // how long abandoning callers waited
+	TalkSeconds       int               `json:"talk_seconds"`
+	AnsweredBy        []FieldValueCount `json:"answered_by"`   // agents, most calls first
+	UnknownAgent      int               `json:"unknown_agent"` // answered calls naming no agent
+
+	waitSeconds        int
+	abandonWaitSeconds int
+}
+
+// QueueReport is every queue's calls in a session, busiest first
+type QueueReport struct {
+	sessionReportBase
+	Queues      []QueueStats `json:"queues"`
+	QueueCalls  int          `json:"queue_calls"`
+	OtherCalls  int          `json:"other_calls"` // CDRs that didn't go through a queue
+	GeneratedAt time.Time    `json:"generated_at"`
+}
+
+// CDRQueue returns the call queue or ring group a CDR leg went through, or "" if none.
+// Queues are named by a queue field, or found by an application naming a queue or hunt
+// group on the terminating side, in which case the dialed user is the queue.
+func CDRQueue(cdr models.FlexibleCDR) string {
+	if queue := firstCDRString(cdr, queueNameFields...); queue != "" {
+		return queue
+	}
+	application := strings.ToLower(firstCDRString(cdr, queueApplicationFields...))
+	if application == "" {
+		return ""
+	}
+	for _, pattern := range queueApplicationPatterns {
+		if strings.Contains(application, pattern) {
+			if queue := firstCDRString(cdr, "call-term-to-user", "call-orig-to-user", "call-dialed-user"); queue != "" {
+				return queue
+			}
+			return cdr.GetTermUser()
+		}
+	}
+	return ""
+}
+
+// queueWait is how long a caller waited in the queue: a recorded wait, the time until
+// an agent answered, or for an abandoned call the whole call
+func queueWait(cdr models.FlexibleCDR, answered bool) int {
+	for _, field := range queueWaitFields {
+		if cdr.HasField(field) {
+			return cdr.GetInt(field)
+		}
+	}
+	if answered {
+		return timeToVoicemail(cdr)
+	}
+	return cdr.GetCallDuration()
+}
+
+// queueAgent is the agent who answered a queue leg, or "" if unknown
+func queueAgent(cdr models.FlexibleCDR, queue string) string {
+	if agent := firstCDRString(cdr, queueAgentFields...); agent != "" {
+		return agent
+	}
+	if term := cdr.GetTermUser(); term != queue {
+		return term
+	}
+	return ""
+}
+
+// BuildQueueReport counts the calls through each queue among cdrs
+func BuildQueueReport(cdrs []models.FlexibleCDR) *QueueReport {
+	report := &QueueReport{Queues: []QueueStats{}, GeneratedAt: time.Now().UTC()}
+	queues := make(map[string]*QueueStats)
+	agents := make(map[string]map[string]int)
+
+	for _, cdr := range cdrs {
+		queue := CDRQueue(cdr)
+		if queue == "" {
+			report.OtherCalls++
+			continue
+		}
+		stats := queues[queue]
+		if stats == nil {
+			stats = &QueueStats{Queue: queue}
+			queues[queue] = stats
+			agents[queue] = make(map[string]int)
+		}
+		report.QueueCalls++
+		stats.Calls++
+
+		answered, talkSeconds := callAnswered(cdr)
+		wait := queueWait(cdr, answered)
+		if wait > stats.MaxWaitSeconds {
+			stats.MaxWaitSeconds = wait
+		}
+		if !answered {
+			stats.Abandoned++
+			stats.abandonWaitSeconds += wait
+			continue
+		}
+		stats.Answered++
+		stats.waitSeconds += wait
+		stats.TalkSeconds += talkSeconds
+		if agent := queueAgent(cdr, queue); agent != "" {
+			agents[queue][agent]++
+		} else {
+			stats.UnknownAgent++
+		}
+	}
+
+	for queue, stats := range queues {
+		if stats.Calls > 0 {
+			stats.AbandonPercent = roundTo(100*float64(stats.Abandoned)/float64(stats.Calls), 1)
+		}
+		if stats.Answered > 0 {
+			stats.AvgWaitSeconds = roundTo(float64(stats.waitSeconds)/float64(stats.Answered), 1)
+		}
+		if stats.Abandoned > 0 {
+			stats.AvgAbandonSeconds = roundTo(float64(stats.abandonWaitSeconds)/float64(stats.Abandoned), 1)
+		}
+		stats.AnsweredBy = []FieldValueCount{}
+		for agent, calls := range agents[queue] {
+			stats.AnsweredBy = append(stats.AnsweredBy, FieldValueCount{Value: agent, Count: calls})
+		}
+		sort.Slice(stats.AnsweredBy, func(i, j int) bool {
+			if stats.AnsweredBy[i].Count != stats.AnsweredBy[j].Count {
+				return stats.AnsweredBy[i].Count > stats.AnsweredBy[j].Count
+			}
+			return stats.AnsweredBy[i].Value < stats.AnsweredBy[j].Value
+		})
+		report.Queues = append(report.Queues, *stats)
+	}
+	sort.Slice(report.Queues, func(i, j int) bool {
+		if report.Queues[i].Calls != report.Queues[j].Calls {
+			return report.Queues[i].Calls > report.Queues[j].Calls
+		}
+		return report.Queues[i].Queue < report.Queues[j].Queue
+	})
+	return report
+}
+
+// AlertSummary describes the report in one line
+func (r *QueueReport) AlertSummary() string {
+	abandoned := 0
+	for _, stats := range r.Queues {
+		abandoned += stats.Abandoned
+	}
+	return fmt.Sprintf("Queue statistics: %d calls through %d queues, %d abandoned",
+		r.QueueCalls, len(r.Queues), abandoned)
+}
+
+// AlertDetails lists each queue
+func (r *QueueReport) AlertDetails() []string {
+	details := []string{"Session: " + r.SessionID}
+	for _, stats := range r.Queues {
+		details = append(details, fmt.Sprintf("%s: %d calls, %d abandoned (%.1f%%), %.0fs average wait, %ds longest",
+			stats.Queue, stats.Calls, stats.Abandoned, stats.AbandonPercent, stats.AvgWaitSeconds, stats.MaxWaitSeconds))
+	}
+	return details
+}
+
+// WriteCSV writes a row per queue, its agents as agent:calls pairs
+func (r *QueueReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"queue", "calls", "answered", "abandoned", "abandon_percent", "avg_wait_seconds",
+		"max_wait_seconds", "avg_abandon_seconds", "talk_seconds", "answered_by", "unknown_agent"})
+	for _, stats := range r.Queues {
+		answeredBy := make([]string, len(stats.AnsweredBy))
+		for i, agent := range stats.AnsweredBy {
+			answeredBy[i] = fmt.Sprintf("%s:%d", agent.Value, agent.Count)
+		}
+		writer.Write([]string{
+			stats.Queue,
+			strconv.Itoa(stats.Calls),
+			strconv.Itoa(stats.Answered),
+			strconv.Itoa(stats.Abandoned),
+			strconv.FormatFloat(stats.AbandonPercent, 'f', -1, 64),
+			strconv.FormatFloat(stats.AvgWaitSeconds, 'f', -1, 64),
+			strconv.Itoa(stats.MaxWaitSeconds),
+			strconv.FormatFloat(stats.AvgAbandonSeconds, 'f', -1, 64),
+			strconv.Itoa(stats.TalkSeconds),
+			strings.Join(answeredBy, " "),
+			strconv.Itoa(stats.UnknownAgent),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
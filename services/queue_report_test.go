@@ -0,0 +1,47 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestBuildQueueReport(t *testing.T) {
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"call-queue": "support", "call-term-user": "101", "call-queue-wait-seconds": float64(20), "call-total-duration-seconds": float64(120)}},
+		{RawData: map[string]interface{}{"call-queue": "support", "call-term-user": "101", "call-queue-wait-seconds": float64(40), "call-total-duration-seconds": float64(60)}},
+		{RawData: map[string]interface{}{"call-queue": "support", "call-term-user": "support", "call-total-duration-seconds": float64(0)}},
+		{RawData: map[string]interface{}{
+			"call-term-application": "Hunt Group", "call-term-to-user": "sales", "call-term-user": "201",
+			"call-start-datetime": "2026-01-05T10:00:00Z", "call-answer-datetime": "2026-01-05T10:00:12Z", "call-total-duration-seconds": float64(72),
+		}},
+		{RawData: map[string]interface{}{"call-term-user": "101", "call-total-duration-seconds": float64(30)}},
+	}
+
+	report := BuildQueueReport(cdrs)
+	if len(report.Queues) != 2 || report.QueueCalls != 4 || report.OtherCalls != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	support := report.Queues[0]
+	if support.Queue != "support" || support.Calls != 3 || support.Answered != 2 || support.Abandoned != 1 ||
+		support.AbandonPercent != 33.3 || support.AvgWaitSeconds != 30 || support.MaxWaitSeconds != 40 {
+		t.Errorf("support = %+v", support)
+	}
+	if len(support.AnsweredBy) != 1 || support.AnsweredBy[0] != (FieldValueCount{Value: "101", Count: 2}) {
+		t.Errorf("support answered by = %+v", support.AnsweredBy)
+	}
+	sales := report.Queues[1]
+	if sales.Queue != "sales" || sales.Answered != 1 || sales.AvgWaitSeconds != 12 || sales.TalkSeconds != 60 {
+		t.Errorf("a hunt group leg should count as a queue call: %+v", sales)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 3 || !strings.Contains(lines[1], ",101:2,") {
+		t.Errorf("CSV = %q", buf.String())
+	}
+}
@@ -0,0 +1,510 @@
+// services/recording_archive.go
+// Copies the call recordings behind a search session's CDRs out of NetSapiens into archive
+// storage, with a manifest linking each file to its CDR, and deletes them after retention
+
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"o-dan-go/models"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// archiveMaxRecordingSize keeps a bad URL from filling memory; call recordings are far smaller
+const archiveMaxRecordingSize = 200 << 20
+
+// archiveMaxErrors caps the errors kept per run
+const archiveMaxErrors = 50
+
+// archiveRetentionInterval is how often expired recordings are deleted
+const archiveRetentionInterval = time.Hour
+
+// unsafeKeyChars are replaced in storage keys
+var unsafeKeyChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ArchivedRecording is a manifest entry: one recording file and the CDR it belongs to
+type ArchivedRecording struct {
+	ID          int64     `json:"id"`
+	CDRID       string    `json:"cdr_id"`
+	RecordingID string    `json:"recording_id"`
+	SessionID   string    `json:"session_id"`
+	CallID      string    `json:"call_id"`
+	Domain      string    `json:"domain"`
+	Storage     string    `json:"storage"`
+	Key         string    `json:"key"`
+	Location    string    `json:"location"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	ArchivedAt  time.Time `json:"archived_at"`
+}
+
+// ArchiveRun is the progress of archiving one search session
+type ArchiveRun struct {
+	SessionID   string     `json:"session_id"`
+	CDRs        int        `json:"cdrs"`
+	Archived    int        `json:"archived"`
+	Skipped     int        `json:"skipped"`      // already archived
+	NoRecording int        `json:"no_recording"` // CDRs without recordings
+	Errors      []string   `json:"errors,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// RecordingArchiver archives call recordings to the configured storage
+type RecordingArchiver struct {
+	db          *DatabaseService
+	storage     ArchiveStorage
+	client      *http.Client
+	baseURL     string
+	accessToken string
+
+	retention      time.Duration
+	retentionRules map[string]time.Duration
+
+	mu   sync.Mutex
+	runs map[string]*ArchiveRun
+}
+
+// NewRecordingArchiver creates an archiver; storage is nil when archiving is disabled.
+// Recordings are kept for retention (0 keeps them), or the domain's rule if it has one.
+func NewRecordingArchiver(db *DatabaseService, storage ArchiveStorage, baseURL, accessToken string, retention time.Duration, retentionRules map[string]time.Duration) *RecordingArchiver {
+	return &RecordingArchiver{
+		db:             db,
+		storage:        storage,
+		client:         &http.Client{Timeout: 2 * time.Minute},
+		baseURL:        strings.TrimRight(baseURL, "/"),
+		accessToken:    accessToken,
+		retention:      retention,
+		retentionRules: retentionRules,
+		runs:           make(map[string]*ArchiveRun),
+	}
+}
+
+// ParseRetentionRules parses per-domain retention like "acme.example.com:720h,beta:0"
+func ParseRetentionRules(spec string) (map[string]time.Duration, error) {
+	rules := make(map[string]time.Duration)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		domain, value, found := strings.Cut(entry, ":")
+		if !found || strings.TrimSpace(domain) == "" {
+			return nil, fmt.Errorf("invalid retention rule %q, expected domain:duration", entry)
+		}
+		retention, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || retention < 0 {
+			return nil, fmt.Errorf("invalid retention for %s: %q", domain, value)
+		}
+		rules[strings.ToLower(strings.TrimSpace(domain))] = retention
+	}
+	return rules, nil
+}
+
+// Enabled reports whether archive storage is configured
+func (ra *RecordingArchiver) Enabled() bool {
+	return ra.storage != nil
+}
+
+// RetentionFor returns how long a domain's recordings are kept; 0 keeps them
+func (ra *RecordingArchiver) RetentionFor(domain string) time.Duration {
+	if retention, exists := ra.retentionRules[strings.ToLower(domain)]; exists {
+		return retention
+	}
+	return ra.retention
+}
+
+// Start deletes expired recordings in the background
+func (ra *RecordingArchiver) Start() {
+	if !ra.Enabled() {
+		return
+	}
+	if ra.retention == 0 && len(ra.retentionRules) == 0 {
+		return
+	}
+
+	go func() {
+		for {
+			if deleted, err := ra.ApplyRetention(time.Now()); err != nil {
+				log.Printf("[Archive] Failed to apply retention: %v", err)
+			} else if deleted > 0 {
+				log.Printf("[Archive] Deleted %d expired recordings", deleted)
+			}
+			time.Sleep(archiveRetentionInterval)
+		}
+	}()
+}
+
+// Begin records that a session is being archived, refusing one already in progress
+func (ra *RecordingArchiver) Begin(sessionID string, cdrs int) (ArchiveRun, error) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	if run, exists := ra.runs[sessionID]; exists && run.FinishedAt == nil {
+		return ArchiveRun{}, fmt.Errorf("session %s is already being archived", sessionID)
+	}
+	run := &ArchiveRun{SessionID: sessionID, CDRs: cdrs, StartedAt: time.Now()}
+	ra.runs[sessionID] = run
+	return *run, nil
+}
+
+// Run returns the latest archive run for a session
+func (ra *RecordingArchiver) Run(sessionID string) (ArchiveRun, bool) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+
+	run, exists := ra.runs[sessionID]
+	if !exists {
+		return ArchiveRun{}, false
+	}
+	copied := *run
+	copied.Errors = append([]string(nil), run.Errors...)
+	return copied, true
+}
+
+// Recordings returns the manifest entries archived from a session
+func (ra *RecordingArchiver) Recordings(sessionID string) ([]ArchivedRecording, error) {
+	return ra.db.GetArchivedRecordings(sessionID)
+}
+
+// ArchiveSession archives every recording of the session's CDRs, then writes the
+// session manifest. Begin must have been called for the session.
+func (ra *RecordingArchiver) ArchiveSession(sessionID string, cdrs []models.FlexibleCDR) {
+	ra.mu.Lock()
+	run := ra.runs[sessionID]
+	ra.mu.Unlock()
+
+	for _, cdr := range cdrs {
+		archived, skipped, err := ra.archiveCDR(sessionID, cdr)
+
+		ra.mu.Lock()
+		run.Archived += archived
+		run.Skipped += skipped
+		if archived == 0 && skipped == 0 && err == nil {
+			run.NoRecording++
+		}
+		if err != nil && len(run.Errors) < archiveMaxErrors {
+			run.Errors = append(run.Errors, fmt.Sprintf("CDR %s: %v", cdr.GetID(), err))
+		}
+		ra.mu.Unlock()
+	}
+
+	if err := ra.writeManifest(sessionID); err != nil {
+		ra.mu.Lock()
+		run.Errors = append(run.Errors, fmt.Sprintf("manifest: %v", err))
+		ra.mu.Unlock()
+	}
+
+	ra.mu.Lock()
+	finished := time.Now()
+	run.FinishedAt = &finished
+	log.Printf("[Archive] Session %s: %d archived, %d already archived, %d without recordings, %d errors",
+		sessionID, run.Archived, run.Skipped, run.NoRecording, len(run.Errors))
+	ra.mu.Unlock()
+}
+
+// archiveCDR copies a CDR's recordings, returning how many were archived and skipped
+func (ra *RecordingArchiver) archiveCDR(sessionID string, cdr models.FlexibleCDR) (int, int, error) {
+	cdrID := cdr.GetID()
+	domain := cdr.GetDomain()
+	if domain == "" {
+		domain = firstCDRString(cdr, "call-orig-domain", "call-term-domain")
+	}
+	callID := firstCDRString(cdr, "call-orig-call-id", "call-term-call-id", "call-id", "orig_callid")
+	if cdrID == "" || domain == "" || callID == "" {
+		return 0, 0, nil
+	}
+
+	var records []map[string]interface{}
+	recordingsPath := fmt.Sprintf("/ns-api/v2/domains/%s/recordings/%s", url.PathEscape(domain), url.PathEscape(callID))
+	if err := netsapiensGet(ra.client, ra.baseURL, ra.accessToken, recordingsPath, &records); err != nil {
+		if isNetsapiensNotFound(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+
+	started, _ := cdr.GetCallStartTime()
+	archived, skipped := 0, 0
+	for i, record := range records {
+		recordingURL := recordString(record, "file-access-url", "url", "recording-url")
+		if recordingURL == "" {
+			continue
+		}
+		recordingID := recordString(record, "recording-id", "id")
+		if recordingID == "" {
+			recordingID = strconv.Itoa(i + 1)
+		}
+
+		exists, err := ra.db.archivedRecordingExists(cdrID, recordingID)
+		if err != nil {
+			return archived, skipped, err
+		}
+		if exists {
+			skipped++
+			continue
+		}
+
+		body, contentType, err := ra.download(recordingURL)
+		if err != nil {
+			return archived, skipped, fmt.Errorf("recording %s: %w", recordingID, err)
+		}
+
+		key := archiveKey(domain, started, cdrID, recordingID, recordingExtension(contentType, recordingURL))
+		location, err := ra.storage.Put(key, body, contentType)
+		if err != nil {
+			return archived, skipped, err
+		}
+
+		err = ra.db.SaveArchivedRecording(&ArchivedRecording{
+			CDRID:       cdrID,
+			RecordingID: recordingID,
+			SessionID:   sessionID,
+			CallID:      callID,
+			Domain:      domain,
+			Storage:     ra.storage.Name(),
+			Key:         key,
+			Location:    location,
+			ContentType: contentType,
+			SizeBytes:   int64(len(body)),
+			ArchivedAt:  time.Now().UTC(),
+		})
+		if err != nil {
+			return archived, skipped, err
+		}
+		archived++
+	}
+
+	// Keep a summary of archived calls for reporting on their transcripts
+	if archived > 0 {
+		if err := ra.db.StoreCDRSummary(&cdr); err != nil {
+			log.Printf("[Archive] Failed to store summary for CDR %s: %v", cdrID, err)
+		}
+	}
+	return archived, skipped, nil
+}
+
+// download fetches a recording. NetSapiens file URLs are usually signed; the API token
+// is only sent to the API's own host.
+func (ra *RecordingArchiver) download(recordingURL string) ([]byte, string, error) {
+	req, err := http.NewRequest("GET", recordingURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid recording URL: %w", err)
+	}
+	if strings.HasPrefix(recordingURL, ra.baseURL+"/") {
+		req.Header.Set("Authorization", "Bearer "+ra.accessToken)
+	}
+
+	resp, err := ra.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, archiveMaxRecordingSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download: %w", err)
+	}
+	if len(body) > archiveMaxRecordingSize {
+		return nil, "", fmt.Errorf("recording is larger than %d bytes", archiveMaxRecordingSize)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return body, contentType, nil
+}
+
+// writeManifest stores the session's manifest: every archived recording and its CDR
+func (ra *RecordingArchiver) writeManifest(sessionID string) error {
+	recordings, err := ra.db.GetArchivedRecordings(sessionID)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := json.MarshalIndent(map[string]interface{}{
+		"session_id":   sessionID,
+		"generated_at": time.Now().UTC(),
+		"recordings":   recordings,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = ra.storage.Put(manifestKey(sessionID), manifest, "application/json")
+	return err
+}
+
+// ApplyRetention deletes recordings older than their domain's retention from storage
+// and the manifest, rewriting the manifests of the sessions they belonged to
+func (ra *RecordingArchiver) ApplyRetention(now time.Time) (int, error) {
+	recordings, err := ra.db.GetArchivedRecordingsBefore(now)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	sessions := make(map[string]bool)
+	for _, recording := range recordings {
+		retention := ra.RetentionFor(recording.Domain)
+		if retention == 0 || now.Sub(recording.ArchivedAt) < retention {
+			continue
+		}
+		if err := ra.storage.Delete(recording.Key); err != nil {
+			log.Printf("[Archive] Failed to delete %s: %v", recording.Key, err)
+			continue
+		}
+		if err := ra.db.DeleteArchivedRecording(recording.ID); err != nil {
+			return deleted, err
+		}
+		deleted++
+		sessions[recording.SessionID] = true
+	}
+
+	for sessionID := range sessions {
+		if err := ra.writeManifest(sessionID); err != nil {
+			log.Printf("[Archive] Failed to rewrite manifest for session %s: %v", sessionID, err)
+		}
+	}
+	return deleted, nil
+}
+
+// archiveKey is where a recording is stored: recordings/<domain>/<call date>/<cdr>-<recording><ext>
+func archiveKey(domain string, started time.Time, cdrID, recordingID, ext string) string {
+	date := "undated"
+	if !started.IsZero() {
+		date = started.UTC().Format("2006/01/02")
+	}
+	return path.Join("recordings", unsafeKeyChars.ReplaceAllString(domain, "_"), date,
+		unsafeKeyChars.ReplaceAllString(cdrID, "_")+"-"+unsafeKeyChars.ReplaceAllString(recordingID, "_")+ext)
+}
+
+// manifestKey is where a session's manifest is stored
+func manifestKey(sessionID string) string {
+	return "manifests/" + unsafeKeyChars.ReplaceAllString(sessionID, "_") + ".json"
+}
+
+// recordingExtension picks a file extension from the content type, or the URL
+func recordingExtension(contentType, recordingURL string) string {
+	switch strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0])) {
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return ".wav"
+	case "audio/mpeg", "audio/mp3":
+		return ".mp3"
+	case "audio/ogg":
+		return ".ogg"
+	}
+	if parsed, err := url.Parse(recordingURL); err == nil {
+		switch ext := strings.ToLower(path.Ext(parsed.Path)); ext {
+		case ".wav", ".mp3", ".ogg", ".gsm":
+			return ext
+		}
+	}
+	return ".wav"
+}
+
+// SaveArchivedRecording adds a manifest entry
+func (ds *DatabaseService) SaveArchivedRecording(rec *ArchivedRecording) error {
+	query := `
+	INSERT INTO archived_recordings (
+		cdr_id, recording_id, session_id, call_id, domain, storage, storage_key, location,
+		content_type, size_bytes, archived_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	RETURNING id`
+
+	err := ds.db.QueryRow(query,
+		rec.CDRID,
+		rec.RecordingID,
+		rec.SessionID,
+		rec.CallID,
+		rec.Domain,
+		rec.Storage,
+		rec.Key,
+		rec.Location,
+		rec.ContentType,
+		rec.SizeBytes,
+		rec.ArchivedAt,
+	).Scan(&rec.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save archived recording: %w", err)
+	}
+	return nil
+}
+
+// archivedRecordingExists reports whether a CDR's recording has already been archived
+func (ds *DatabaseService) archivedRecordingExists(cdrID, recordingID string) (bool, error) {
+	var id int64
+	err := ds.db.QueryRow(`SELECT id FROM archived_recordings WHERE cdr_id = ? AND recording_id = ?`, cdrID, recordingID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check archived recording: %w", err)
+	}
+	return true, nil
+}
+
+// GetArchivedRecordings returns the manifest entries archived from a session
+func (ds *DatabaseService) GetArchivedRecordings(sessionID string) ([]ArchivedRecording, error) {
+	return ds.queryArchivedRecordings(`WHERE session_id = ? ORDER BY cdr_id, recording_id`, sessionID)
+}
+
+// GetArchivedRecordingsBefore returns the manifest entries archived before a time
+func (ds *DatabaseService) GetArchivedRecordingsBefore(before time.Time) ([]ArchivedRecording, error) {
+	return ds.queryArchivedRecordings(`WHERE archived_at < ? ORDER BY archived_at`, before.UTC())
+}
+
+// DeleteArchivedRecording removes a manifest entry along with its transcript
+func (ds *DatabaseService) DeleteArchivedRecording(id int64) error {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteTranscripts(tx, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM archived_recordings WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete archived recording: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (ds *DatabaseService) queryArchivedRecordings(where string, args ...interface{}) ([]ArchivedRecording, error) {
+	query := `
+	SELECT id, cdr_id, recording_id, COALESCE(session_id, ''), COALESCE(call_id, ''), COALESCE(domain, ''),
+		storage, storage_key, COALESCE(location, ''), COALESCE(content_type, ''), size_bytes, archived_at
+	FROM archived_recordings ` + where
+
+	rows, err := ds.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query archived recordings: %w", err)
+	}
+	defer rows.Close()
+
+	recordings := []ArchivedRecording{}
+	for rows.Next() {
+		var rec ArchivedRecording
+		if err := rows.Scan(&rec.ID, &rec.CDRID, &rec.RecordingID, &rec.SessionID, &rec.CallID, &rec.Domain,
+			&rec.Storage, &rec.Key, &rec.Location, &rec.ContentType, &rec.SizeBytes, &rec.ArchivedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan archived recording: %w", err)
+		}
+		recordings = append(recordings, rec)
+	}
+	return recordings, rows.Err()
+}
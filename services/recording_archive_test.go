@@ -0,0 +1,98 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestArchiveSessionAndRetention(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ns-api/v2/domains/acme/recordings/call-1":
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"recording-id": "rec-1", "file-access-url": server.URL + "/files/rec-1"},
+			})
+		case "/files/rec-1":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", "audio/wav")
+			w.Write([]byte("RIFF"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "archive.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	dir := t.TempDir()
+	storage := NewArchiveStorage(ArchiveSettings{Storage: "local", Dir: dir})
+	ra := NewRecordingArchiver(db, storage, server.URL, "token", 0, map[string]time.Duration{"acme": time.Hour})
+
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"id": "cdr-1", "domain": "acme", "call-orig-call-id": "call-1", "call-start-datetime": "2026-10-16 09:00:00"}},
+		{RawData: map[string]interface{}{"id": "cdr-2", "domain": "acme", "call-orig-call-id": "call-2"}},
+	}
+	for pass := 0; pass < 2; pass++ {
+		if _, err := ra.Begin("session-1", len(cdrs)); err != nil {
+			t.Fatalf("Begin: %v", err)
+		}
+		ra.ArchiveSession("session-1", cdrs)
+	}
+
+	run, _ := ra.Run("session-1")
+	if run.Archived != 0 || run.Skipped != 1 || run.NoRecording != 1 || len(run.Errors) != 0 || run.FinishedAt == nil {
+		t.Fatalf("unexpected second run: %+v", run)
+	}
+
+	recordings, err := ra.Recordings("session-1")
+	if err != nil || len(recordings) != 1 {
+		t.Fatalf("Recordings = %+v, %v", recordings, err)
+	}
+	file := filepath.Join(dir, "recordings", "acme", "2026", "10", "16", "cdr-1-rec-1.wav")
+	if recordings[0].Location != file {
+		t.Errorf("location = %s, want %s", recordings[0].Location, file)
+	}
+	if body, err := os.ReadFile(file); err != nil || string(body) != "RIFF" {
+		t.Fatalf("archived file: %q, %v", body, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "manifests", "session-1.json")); err != nil {
+		t.Fatalf("manifest not written: %v", err)
+	}
+
+	if deleted, err := ra.ApplyRetention(time.Now()); err != nil || deleted != 0 {
+		t.Fatalf("ApplyRetention before expiry = %d, %v", deleted, err)
+	}
+	if deleted, err := ra.ApplyRetention(time.Now().Add(2 * time.Hour)); err != nil || deleted != 1 {
+		t.Fatalf("ApplyRetention after expiry = %d, %v", deleted, err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("expired recording still on disk: %v", err)
+	}
+}
+
+func TestParseRetentionRules(t *testing.T) {
+	rules, err := ParseRetentionRules("Acme.example.com:720h, legal:0")
+	if err != nil || rules["acme.example.com"] != 720*time.Hour || len(rules) != 2 {
+		t.Fatalf("ParseRetentionRules = %v, %v", rules, err)
+	}
+	for _, bad := range []string{"acme", ":1h", "acme:soon", "acme:-1h"} {
+		if _, err := ParseRetentionRules(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
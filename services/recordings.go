@@ -0,0 +1,118 @@
+// services/recordings.go
+// Caller recordings (voicemails, callback requests) captured by IVR apps
+
+package services
+
+import (
+	"fmt"
+	"time"
+)
+
+// Recording kinds
+const (
+	RecordingVoicemail = "voicemail"
+	RecordingCallback  = "callback"
+)
+
+// Recording is the metadata for a caller recording; the audio stays on NetSapiens
+type Recording struct {
+	ID              int64     `json:"id"`
+	RecordingID     string    `json:"recording_id"`
+	CallID          string    `json:"call_id"`
+	SessionID       string    `json:"session_id"`
+	App             string    `json:"app"`
+	Kind            string    `json:"kind"`
+	CallerNumber    string    `json:"caller_number"`
+	URL             string    `json:"recording_url"`
+	DurationSeconds int       `json:"duration_seconds"`
+	Status          string    `json:"status"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// SaveRecording stores recording metadata. The Record action and the recording status
+// callback can arrive in either order, so fields already stored are only replaced by
+// non-empty values.
+func (ds *DatabaseService) SaveRecording(rec *Recording) error {
+	if rec.RecordingID == "" {
+		rec.RecordingID = rec.URL
+	}
+	if rec.RecordingID == "" {
+		return fmt.Errorf("recording has no ID or URL")
+	}
+
+	query := `
+	INSERT INTO ivr_recordings (
+		recording_id, call_id, session_id, app, kind, caller_number, recording_url, duration_seconds, status
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(recording_id) DO UPDATE SET
+		call_id = COALESCE(NULLIF(excluded.call_id, ''), call_id),
+		session_id = COALESCE(NULLIF(excluded.session_id, ''), session_id),
+		app = COALESCE(NULLIF(excluded.app, ''), app),
+		kind = COALESCE(NULLIF(excluded.kind, ''), kind),
+		caller_number = COALESCE(NULLIF(excluded.caller_number, ''), caller_number),
+		recording_url = COALESCE(NULLIF(excluded.recording_url, ''), recording_url),
+		duration_seconds = MAX(excluded.duration_seconds, duration_seconds),
+		status = COALESCE(NULLIF(excluded.status, ''), status)`
+
+	_, err := ds.db.Exec(query,
+		rec.RecordingID,
+		rec.CallID,
+		rec.SessionID,
+		rec.App,
+		rec.Kind,
+		rec.CallerNumber,
+		rec.URL,
+		rec.DurationSeconds,
+		rec.Status,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save recording: %w", err)
+	}
+
+	return nil
+}
+
+// GetRecordings returns the most recent recordings, optionally of one kind
+func (ds *DatabaseService) GetRecordings(kind string, limit int) ([]Recording, error) {
+	query := `
+	SELECT id, recording_id, COALESCE(call_id, ''), COALESCE(session_id, ''), COALESCE(app, ''),
+		COALESCE(kind, ''), COALESCE(caller_number, ''), COALESCE(recording_url, ''),
+		duration_seconds, COALESCE(status, ''), created_at
+	FROM ivr_recordings`
+
+	args := []interface{}{}
+
+	if kind != "" {
+		query += " WHERE kind = ?"
+		args = append(args, kind)
+	}
+
+	query += " ORDER BY created_at DESC, id DESC"
+
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := ds.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recordings []Recording
+	for rows.Next() {
+		var rec Recording
+		err := rows.Scan(
+			&rec.ID, &rec.RecordingID, &rec.CallID, &rec.SessionID, &rec.App,
+			&rec.Kind, &rec.CallerNumber, &rec.URL,
+			&rec.DurationSeconds, &rec.Status, &rec.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		recordings = append(recordings, rec)
+	}
+
+	return recordings, nil
+}
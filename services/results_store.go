@@ -2,16 +2,26 @@
 package services
 
 import (
-	"sync"
+	"encoding/json"
 	"time"
 )
 
 // ResultsStore provides temporary in-memory storage for CDR results
 // This can be easily replaced with Redis, database, or other storage in the future
 type ResultsStore struct {
-	mu      sync.RWMutex
-	results map[string]*CDRDiscoveryResult
-	ttl     time.Duration // Time to live for stored results
+	results *TTLStore[string, *CDRDiscoveryResult]
+}
+
+// StoredResultInfo describes a cached result for admin introspection
+type StoredResultInfo struct {
+	SessionID     string    `json:"session_id"`
+	TotalCDRs     int       `json:"total_cdrs"`
+	UniqueCDRs    int       `json:"unique_cdrs"`
+	EndpointCount int       `json:"endpoint_count"`
+	ErrorCount    int       `json:"error_count"`
+	SizeBytes     int       `json:"size_bytes"` // Approximate JSON size of the cached CDRs
+	StoredAt      time.Time `json:"stored_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
 }
 
 // GlobalResultsStore is the singleton instance used throughout the application
@@ -19,78 +29,74 @@ var GlobalResultsStore = NewResultsStore(1 * time.Hour)
 
 // NewResultsStore creates a new results store with specified TTL
 func NewResultsStore(ttl time.Duration) *ResultsStore {
-	return &ResultsStore{
-		results: make(map[string]*CDRDiscoveryResult),
-		ttl:     ttl,
-	}
+	return &ResultsStore{results: NewTTLStore[string, *CDRDiscoveryResult](ttl, 0)}
 }
 
 // Store saves a CDR discovery result with automatic expiration
 func (rs *ResultsStore) Store(sessionID string, result *CDRDiscoveryResult) {
-	rs.mu.Lock()
-	defer rs.mu.Unlock()
-
-	// Store the result
-	rs.results[sessionID] = result
-
-	// Schedule cleanup after TTL
-	go func() {
-		time.Sleep(rs.ttl)
-		rs.Delete(sessionID)
-	}()
+	rs.results.Set(sessionID, result)
 }
 
 // Get retrieves a CDR discovery result by session ID
 func (rs *ResultsStore) Get(sessionID string) (*CDRDiscoveryResult, bool) {
-	rs.mu.RLock()
-	defer rs.mu.RUnlock()
-
-	result, exists := rs.results[sessionID]
-	return result, exists
+	return rs.results.Get(sessionID)
 }
 
 // Delete removes a result from storage
 func (rs *ResultsStore) Delete(sessionID string) {
-	rs.mu.Lock()
-	defer rs.mu.Unlock()
-
-	delete(rs.results, sessionID)
+	rs.results.Delete(sessionID)
 }
 
 // GetAll returns all stored results (useful for admin/debugging)
 func (rs *ResultsStore) GetAll() map[string]*CDRDiscoveryResult {
-	rs.mu.RLock()
-	defer rs.mu.RUnlock()
-
-	// Create a copy to avoid race conditions
-	resultsCopy := make(map[string]*CDRDiscoveryResult)
-	for k, v := range rs.results {
-		resultsCopy[k] = v
+	results := make(map[string]*CDRDiscoveryResult)
+	for _, entry := range rs.results.Entries() {
+		results[entry.Key] = entry.Value
 	}
-
-	return resultsCopy
+	return results
 }
 
 // Count returns the number of stored results
 func (rs *ResultsStore) Count() int {
-	rs.mu.RLock()
-	defer rs.mu.RUnlock()
-
-	return len(rs.results)
+	return rs.results.Len()
 }
 
 // Clear removes all stored results
 func (rs *ResultsStore) Clear() {
-	rs.mu.Lock()
-	defer rs.mu.Unlock()
+	rs.results.Clear()
+}
 
-	rs.results = make(map[string]*CDRDiscoveryResult)
+// Stats returns per-session details of everything in the store, newest first
+func (rs *ResultsStore) Stats() []StoredResultInfo {
+	entries := rs.results.Entries()
+	stats := make([]StoredResultInfo, 0, len(entries))
+	for _, entry := range entries {
+		result := entry.Value
+		size := 0
+		if data, err := json.Marshal(result.AllCDRs); err == nil {
+			size = len(data)
+		}
+
+		stats = append(stats, StoredResultInfo{
+			SessionID:     entry.Key,
+			TotalCDRs:     result.TotalCDRs,
+			UniqueCDRs:    result.UniqueCDRs,
+			EndpointCount: len(result.EndpointResults),
+			ErrorCount:    len(result.Errors),
+			SizeBytes:     size,
+			StoredAt:      entry.StoredAt,
+			ExpiresAt:     entry.ExpiresAt,
+		})
+	}
+	return stats
+}
+
+// TTL returns the current time-to-live for new results
+func (rs *ResultsStore) TTL() time.Duration {
+	return rs.results.TTL()
 }
 
 // UpdateTTL updates the time-to-live for new results
 func (rs *ResultsStore) UpdateTTL(ttl time.Duration) {
-	rs.mu.Lock()
-	defer rs.mu.Unlock()
-
-	rs.ttl = ttl
+	rs.results.SetTTL(ttl)
 }
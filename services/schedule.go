@@ -0,0 +1,450 @@
+// services/schedule.go
+// Business hours and holiday calendars per dialed number, for open/closed IVR routing
+
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultScheduleDID is the schedule used for numbers without their own. Holidays
+// stored under it apply to every number.
+const DefaultScheduleDID = "default"
+
+// Schedule status reasons
+const (
+	ScheduleOpen       = "open"
+	ScheduleClosed     = "closed"
+	ScheduleHoliday    = "holiday"
+	ScheduleNoSchedule = "no_schedule"
+)
+
+// BusinessSchedule is the weekly opening hours for one dialed number
+type BusinessSchedule struct {
+	DID       string          `json:"did"`
+	Timezone  string          `json:"timezone"`
+	OnCall    string          `json:"on_call,omitempty"` // handoff target for after-hours calls
+	Hours     []BusinessHours `json:"hours"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// BusinessHours is one opening period. Weekday 0 is Sunday; times are HH:MM in the
+// schedule's timezone. A close time at or before the open time runs past midnight.
+type BusinessHours struct {
+	Weekday int    `json:"weekday"`
+	Open    string `json:"open"`
+	Close   string `json:"close"`
+}
+
+// Holiday is a date a number is closed all day
+type Holiday struct {
+	ID   int64  `json:"id"`
+	DID  string `json:"did"`
+	Date string `json:"date"` // YYYY-MM-DD
+	Name string `json:"name"`
+}
+
+// ScheduleStatus reports whether a number is open at a moment
+type ScheduleStatus struct {
+	DID       string `json:"did"`    // schedule that applied, DefaultScheduleDID when falling back
+	Open      bool   `json:"open"`   // numbers without any schedule are always open
+	Reason    string `json:"reason"` // open, closed, holiday or no_schedule
+	Holiday   string `json:"holiday,omitempty"`
+	OnCall    string `json:"on_call,omitempty"`
+	Timezone  string `json:"timezone,omitempty"`
+	LocalTime string `json:"local_time,omitempty"`
+}
+
+// NormalizeScheduleDID maps a dialed number to the key its schedule is stored under
+func NormalizeScheduleDID(did string) (string, error) {
+	did = strings.TrimSpace(did)
+	if did == "" || strings.EqualFold(did, DefaultScheduleDID) {
+		return DefaultScheduleDID, nil
+	}
+	if normalized := NormalizePhoneNumber(did); normalized != "" {
+		return normalized, nil
+	}
+	if digits := nonDigits.ReplaceAllString(did, ""); digits != "" {
+		return digits, nil
+	}
+	return "", fmt.Errorf("invalid schedule number %q", did)
+}
+
+// parseClock parses HH:MM into minutes after midnight, allowing 24:00 as a close time
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err == nil {
+		return t.Hour()*60 + t.Minute(), nil
+	}
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+}
+
+// Validate normalizes the DID and checks the timezone, on-call target and hours
+func (bs *BusinessSchedule) Validate() error {
+	did, err := NormalizeScheduleDID(bs.DID)
+	if err != nil {
+		return err
+	}
+	bs.DID = did
+
+	if bs.Timezone == "" {
+		return fmt.Errorf("timezone is required")
+	}
+	if _, err := time.LoadLocation(bs.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q", bs.Timezone)
+	}
+
+	if bs.OnCall != "" {
+		target, err := ParseHandoffTarget(bs.OnCall)
+		if err != nil {
+			return fmt.Errorf("invalid on-call target: %w", err)
+		}
+		bs.OnCall = target.String()
+	}
+
+	for _, hours := range bs.Hours {
+		if hours.Weekday < 0 || hours.Weekday > 6 {
+			return fmt.Errorf("invalid weekday %d, expected 0 (Sunday) to 6", hours.Weekday)
+		}
+		open, err := parseClock(hours.Open)
+		if err != nil || open == 24*60 {
+			return fmt.Errorf("invalid open time %q, expected HH:MM", hours.Open)
+		}
+		if _, err := parseClock(hours.Close); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isOpen reports whether local falls in any of the schedule's hours
+func (bs *BusinessSchedule) isOpen(local time.Time) bool {
+	now := local.Hour()*60 + local.Minute()
+	today := int(local.Weekday())
+	yesterday := (today + 6) % 7
+
+	for _, hours := range bs.Hours {
+		open, _ := parseClock(hours.Open)
+		closing, _ := parseClock(hours.Close)
+
+		if open < closing {
+			if hours.Weekday == today && now >= open && now < closing {
+				return true
+			}
+			continue
+		}
+
+		// Overnight: open from the start time on its weekday until the close time the next day
+		if hours.Weekday == today && now >= open {
+			return true
+		}
+		if hours.Weekday == yesterday && now < closing {
+			return true
+		}
+	}
+	return false
+}
+
+// GetBusinessSchedule returns the schedule for a normalized DID, or nil if it has none
+func (ds *DatabaseService) GetBusinessSchedule(did string) (*BusinessSchedule, error) {
+	schedule := &BusinessSchedule{DID: did}
+	err := ds.db.QueryRow(
+		`SELECT timezone, on_call, updated_at FROM business_schedules WHERE did = ?`, did,
+	).Scan(&schedule.Timezone, &schedule.OnCall, &schedule.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule: %w", err)
+	}
+
+	rows, err := ds.db.Query(
+		`SELECT weekday, open_time, close_time FROM business_hours WHERE did = ? ORDER BY weekday, open_time`, did,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get business hours: %w", err)
+	}
+	defer rows.Close()
+
+	schedule.Hours = []BusinessHours{}
+	for rows.Next() {
+		var hours BusinessHours
+		if err := rows.Scan(&hours.Weekday, &hours.Open, &hours.Close); err != nil {
+			return nil, err
+		}
+		schedule.Hours = append(schedule.Hours, hours)
+	}
+
+	return schedule, rows.Err()
+}
+
+// GetBusinessSchedules returns every schedule, the default first
+func (ds *DatabaseService) GetBusinessSchedules() ([]BusinessSchedule, error) {
+	rows, err := ds.db.Query(`SELECT did FROM business_schedules ORDER BY did != ?, did`, DefaultScheduleDID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+
+	var dids []string
+	for rows.Next() {
+		var did string
+		if err := rows.Scan(&did); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		dids = append(dids, did)
+	}
+	rows.Close()
+
+	schedules := []BusinessSchedule{}
+	for _, did := range dids {
+		schedule, err := ds.GetBusinessSchedule(did)
+		if err != nil {
+			return nil, err
+		}
+		if schedule != nil {
+			schedules = append(schedules, *schedule)
+		}
+	}
+	return schedules, nil
+}
+
+// SaveBusinessSchedule validates a schedule and replaces any stored for its DID
+func (ds *DatabaseService) SaveBusinessSchedule(schedule *BusinessSchedule) error {
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+	INSERT INTO business_schedules (did, timezone, on_call, updated_at)
+	VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+	ON CONFLICT(did) DO UPDATE SET
+		timezone = excluded.timezone,
+		on_call = excluded.on_call,
+		updated_at = excluded.updated_at`,
+		schedule.DID, schedule.Timezone, schedule.OnCall,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM business_hours WHERE did = ?`, schedule.DID); err != nil {
+		return fmt.Errorf("failed to clear business hours: %w", err)
+	}
+	for _, hours := range schedule.Hours {
+		_, err := tx.Exec(
+			`INSERT INTO business_hours (did, weekday, open_time, close_time) VALUES (?, ?, ?, ?)`,
+			schedule.DID, hours.Weekday, hours.Open, hours.Close,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save business hours: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit schedule: %w", err)
+	}
+	return nil
+}
+
+// DeleteBusinessSchedule removes a DID's schedule, reporting whether one existed.
+// The number falls back to the default schedule; its holidays are kept.
+func (ds *DatabaseService) DeleteBusinessSchedule(did string) (bool, error) {
+	if _, err := ds.db.Exec(`DELETE FROM business_hours WHERE did = ?`, did); err != nil {
+		return false, fmt.Errorf("failed to delete business hours: %w", err)
+	}
+	result, err := ds.db.Exec(`DELETE FROM business_schedules WHERE did = ?`, did)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete schedule: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// AddHoliday stores a holiday, replacing the name of one already on that date
+func (ds *DatabaseService) AddHoliday(holiday *Holiday) error {
+	did, err := NormalizeScheduleDID(holiday.DID)
+	if err != nil {
+		return err
+	}
+	holiday.DID = did
+
+	if _, err := time.Parse("2006-01-02", holiday.Date); err != nil {
+		return fmt.Errorf("invalid holiday date %q, expected YYYY-MM-DD", holiday.Date)
+	}
+	holiday.Name = strings.TrimSpace(holiday.Name)
+	if holiday.Name == "" {
+		holiday.Name = "Holiday"
+	}
+
+	err = ds.db.QueryRow(`
+	INSERT INTO holidays (did, date, name) VALUES (?, ?, ?)
+	ON CONFLICT(did, date) DO UPDATE SET name = excluded.name
+	RETURNING id`,
+		holiday.DID, holiday.Date, holiday.Name,
+	).Scan(&holiday.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save holiday: %w", err)
+	}
+	return nil
+}
+
+// DeleteHoliday removes a holiday by ID, reporting whether it existed
+func (ds *DatabaseService) DeleteHoliday(id int64) (bool, error) {
+	result, err := ds.db.Exec(`DELETE FROM holidays WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete holiday: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// GetHolidays returns holidays in date order, for one DID (plus the default's) or all when did is ""
+func (ds *DatabaseService) GetHolidays(did string) ([]Holiday, error) {
+	query := `SELECT id, did, date, name FROM holidays`
+	args := []interface{}{}
+
+	if did != "" {
+		query += " WHERE did IN (?, ?)"
+		args = append(args, did, DefaultScheduleDID)
+	}
+	query += " ORDER BY date, did"
+
+	rows, err := ds.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get holidays: %w", err)
+	}
+	defer rows.Close()
+
+	holidays := []Holiday{}
+	for rows.Next() {
+		var holiday Holiday
+		if err := rows.Scan(&holiday.ID, &holiday.DID, &holiday.Date, &holiday.Name); err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, holiday)
+	}
+	return holidays, rows.Err()
+}
+
+// holidayOn returns the name of the DID's (or a default) holiday on date, "" if none
+func (ds *DatabaseService) holidayOn(did, date string) (string, error) {
+	var name string
+	err := ds.db.QueryRow(
+		`SELECT name FROM holidays WHERE did IN (?, ?) AND date = ? ORDER BY did = ? LIMIT 1`,
+		did, DefaultScheduleDID, date, DefaultScheduleDID,
+	).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to check holidays: %w", err)
+	}
+	return name, nil
+}
+
+// ScheduleService answers whether a dialed number is open, for IVR routing
+type ScheduleService struct {
+	db *DatabaseService
+}
+
+// NewScheduleService creates the business hours service
+func NewScheduleService(db *DatabaseService) *ScheduleService {
+	return &ScheduleService{db: db}
+}
+
+// Status reports whether did is open at the given moment, falling back to the
+// default schedule for numbers without their own
+func (ss *ScheduleService) Status(did string, at time.Time) (ScheduleStatus, error) {
+	did, err := NormalizeScheduleDID(did)
+	if err != nil {
+		return ScheduleStatus{}, err
+	}
+
+	schedule, err := ss.db.GetBusinessSchedule(did)
+	if err == nil && schedule == nil && did != DefaultScheduleDID {
+		schedule, err = ss.db.GetBusinessSchedule(DefaultScheduleDID)
+	}
+	if err != nil {
+		return ScheduleStatus{}, err
+	}
+	if schedule == nil {
+		return ScheduleStatus{DID: did, Open: true, Reason: ScheduleNoSchedule}, nil
+	}
+
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return ScheduleStatus{}, fmt.Errorf("schedule %s has invalid timezone %q", schedule.DID, schedule.Timezone)
+	}
+	local := at.In(loc)
+
+	status := ScheduleStatus{
+		DID:       schedule.DID,
+		OnCall:    schedule.OnCall,
+		Timezone:  schedule.Timezone,
+		LocalTime: local.Format("Mon 2006-01-02 15:04"),
+	}
+
+	holiday, err := ss.db.holidayOn(did, local.Format("2006-01-02"))
+	if err != nil {
+		return ScheduleStatus{}, err
+	}
+
+	switch {
+	case holiday != "":
+		status.Reason = ScheduleHoliday
+		status.Holiday = holiday
+	case schedule.isOpen(local):
+		status.Open = true
+		status.Reason = ScheduleOpen
+	default:
+		status.Reason = ScheduleClosed
+	}
+	return status, nil
+}
+
+// FlowActions exposes business hours to declarative flows:
+//
+//	check_hours - fails when the number is closed (use on_error for after-hours handling).
+//	              Checks the schedule variable's number if set, else the dialed number,
+//	              and sets open, holiday and on_call (usable as transfer: "{{.on_call}}").
+func (ss *ScheduleService) FlowActions() map[string]FlowAction {
+	return map[string]FlowAction{
+		"check_hours": func(ctx *FlowContext) error {
+			did := ctx.Vars["schedule"]
+			if did == "" {
+				did = ctx.Params.DialedNumber
+			}
+
+			status, err := ss.Status(did, time.Now())
+			if err != nil {
+				return err
+			}
+
+			ctx.Vars["open"] = ""
+			if status.Open {
+				ctx.Vars["open"] = "true"
+			}
+			ctx.Vars["holiday"] = status.Holiday
+			ctx.Vars["on_call"] = status.OnCall
+
+			if !status.Open {
+				return fmt.Errorf("%s is %s: %w", status.DID, status.Reason, ErrFlowCheckFailed)
+			}
+			return nil
+		},
+	}
+}
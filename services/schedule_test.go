@@ -0,0 +1,88 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestScheduleService(t *testing.T) (*DatabaseService, *ScheduleService) {
+	t.Helper()
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "schedule.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, NewScheduleService(db)
+}
+
+func TestScheduleStatus(t *testing.T) {
+	db, ss := newTestScheduleService(t)
+
+	// Weekdays 9-5, plus a Friday night shift into Saturday
+	schedule := &BusinessSchedule{DID: "+1 (415) 555-0100", Timezone: "America/Los_Angeles", OnCall: "ext:200"}
+	for weekday := 1; weekday <= 5; weekday++ {
+		schedule.Hours = append(schedule.Hours, BusinessHours{Weekday: weekday, Open: "09:00", Close: "17:00"})
+	}
+	schedule.Hours = append(schedule.Hours, BusinessHours{Weekday: 5, Open: "22:00", Close: "02:00"})
+	if err := db.SaveBusinessSchedule(schedule); err != nil {
+		t.Fatalf("SaveBusinessSchedule: %v", err)
+	}
+	if err := db.AddHoliday(&Holiday{Date: "2026-12-25", Name: "Christmas"}); err != nil {
+		t.Fatalf("AddHoliday: %v", err)
+	}
+
+	loc, _ := time.LoadLocation("America/Los_Angeles")
+	tests := []struct {
+		at     time.Time
+		open   bool
+		reason string
+	}{
+		{time.Date(2026, 10, 14, 10, 0, 0, 0, loc), true, ScheduleOpen},          // Wednesday morning
+		{time.Date(2026, 10, 14, 17, 0, 0, 0, loc), false, ScheduleClosed},       // closing time
+		{time.Date(2026, 10, 14, 15, 30, 0, 0, time.UTC), false, ScheduleClosed}, // 8:30 local
+		{time.Date(2026, 10, 17, 1, 30, 0, 0, loc), true, ScheduleOpen},          // Friday night shift
+		{time.Date(2026, 10, 17, 2, 0, 0, 0, loc), false, ScheduleClosed},
+		{time.Date(2026, 12, 25, 10, 0, 0, 0, loc), false, ScheduleHoliday},
+	}
+	for _, tt := range tests {
+		status, err := ss.Status("4155550100", tt.at)
+		if err != nil {
+			t.Fatalf("Status(%s): %v", tt.at, err)
+		}
+		if status.Open != tt.open || status.Reason != tt.reason {
+			t.Errorf("Status(%s) = %v/%s, want %v/%s", tt.at, status.Open, status.Reason, tt.open, tt.reason)
+		}
+		if status.OnCall != "ext:200" {
+			t.Errorf("expected on-call ext:200, got %q", status.OnCall)
+		}
+	}
+
+	// Numbers without a schedule are open until a default is saved
+	status, err := ss.Status("2125550100", time.Now())
+	if err != nil || !status.Open || status.Reason != ScheduleNoSchedule {
+		t.Fatalf("expected no_schedule, got %+v (%v)", status, err)
+	}
+	if err := db.SaveBusinessSchedule(&BusinessSchedule{DID: DefaultScheduleDID, Timezone: "UTC"}); err != nil {
+		t.Fatalf("SaveBusinessSchedule default: %v", err)
+	}
+	status, err = ss.Status("2125550100", time.Now())
+	if err != nil || status.Open || status.DID != DefaultScheduleDID {
+		t.Fatalf("expected default schedule closed, got %+v (%v)", status, err)
+	}
+}
+
+func TestBusinessScheduleValidate(t *testing.T) {
+	invalid := []BusinessSchedule{
+		{DID: "4155550100"},
+		{DID: "4155550100", Timezone: "Mars/Olympus"},
+		{DID: "4155550100", Timezone: "UTC", Hours: []BusinessHours{{Weekday: 7, Open: "09:00", Close: "17:00"}}},
+		{DID: "4155550100", Timezone: "UTC", Hours: []BusinessHours{{Weekday: 1, Open: "9am", Close: "17:00"}}},
+		{DID: "4155550100", Timezone: "UTC", OnCall: "pager:ops"},
+	}
+	for _, schedule := range invalid {
+		if err := schedule.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", schedule)
+		}
+	}
+}
@@ -0,0 +1,712 @@
+// services/scheduled_searches.go
+// Scheduled searches: saved search criteria run on an interval with the server's
+// NetSapiens credentials. Each run is diffed against the previous one (new CDRs, CDRs
+// no longer found, count changes), the delta is stored, and drift past a search's
+// thresholds is sent through the alert notifiers.
+
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minScheduledSearchInterval is the shortest interval a search can run on
+const minScheduledSearchInterval = 5
+
+// ErrScheduledSearchNotFound is returned for a scheduled search that doesn't exist
+var ErrScheduledSearchNotFound = errors.New("scheduled search not found")
+
+// Destinations scheduled searches can export each run's CDRs to
+const (
+	ScheduledExportBigQuery = "bigquery"
+	ScheduledExportParquet  = "parquet"
+)
+
+// scheduledExportDestinations are the destinations a search can name
+var scheduledExportDestinations = []string{ScheduledExportBigQuery, ScheduledExportParquet}
+
+// ScheduledExporter sends a scheduled search run's CDRs to a destination
+type ScheduledExporter interface {
+	Name() string
+	Enabled() bool
+	Export(result *CDRDiscoveryResult) error
+}
+
+// ScheduledSearch is a search run on an interval
+type ScheduledSearch struct {
+	ID                int64             `json:"id"`
+	Name              string            `json:"name"`
+	Criteria          CDRSearchCriteria `json:"criteria"`
+	LookbackDays      int               `json:"lookback_days,omitempty"` // search the last N days on each run instead of fixed dates
+	IntervalMinutes   int               `json:"interval_minutes"`
+	DriftAlertPercent float64           `json:"drift_alert_percent,omitempty"` // alert when unique CDRs change by at least this much; 0 never
+	DriftAlertMissing int               `json:"drift_alert_missing,omitempty"` // alert when at least this many CDRs disappear; 0 never
+	Reports           []string          `json:"reports,omitempty"`             // session reports sent through the alert notifiers after each run
+	Exports           []string          `json:"exports,omitempty"`             // destinations each run's CDRs are exported to
+	Disabled          bool              `json:"disabled"`
+	LastRunAt         *time.Time        `json:"last_run_at"`
+	LastSessionID     string            `json:"last_session_id,omitempty"`
+	LastError         string            `json:"last_error,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
+
+// Validate checks the search before it is saved
+func (s *ScheduledSearch) Validate() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if s.IntervalMinutes < minScheduledSearchInterval {
+		return fmt.Errorf("interval_minutes must be at least %d", minScheduledSearchInterval)
+	}
+	if s.LookbackDays < 0 || s.DriftAlertPercent < 0 || s.DriftAlertMissing < 0 {
+		return fmt.Errorf("lookback_days and drift thresholds cannot be negative")
+	}
+	if (s.Criteria.User != "" || s.Criteria.Site != "") && s.Criteria.Domain == "" {
+		return fmt.Errorf("user or site searches require a domain")
+	}
+	if err := ValidateSessionReports(s.Reports); err != nil {
+		return err
+	}
+	if err := ValidateScheduledExports(s.Exports); err != nil {
+		return err
+	}
+	return s.Criteria.ValidateDedup()
+}
+
+// ValidateScheduledExports checks that every export destination is known
+func ValidateScheduledExports(destinations []string) error {
+	for _, destination := range destinations {
+		known := false
+		for _, name := range scheduledExportDestinations {
+			known = known || destination == name
+		}
+		if !known {
+			return fmt.Errorf("unknown export destination %q: use one of %s", destination, strings.Join(scheduledExportDestinations, ", "))
+		}
+	}
+	return nil
+}
+
+// Due reports whether the search should run at now
+func (s *ScheduledSearch) Due(now time.Time) bool {
+	return !s.Disabled && (s.LastRunAt == nil || !now.Before(s.LastRunAt.Add(time.Duration(s.IntervalMinutes)*time.Minute)))
+}
+
+// criteriaAt is the criteria for a run at now; a lookback replaces the fixed dates
+func (s *ScheduledSearch) criteriaAt(now time.Time) CDRSearchCriteria {
+	criteria := s.Criteria
+	if s.LookbackDays > 0 {
+		end := now.UTC().Truncate(24 * time.Hour)
+		start := end.AddDate(0, 0, -s.LookbackDays)
+		criteria.StartDate, criteria.EndDate = &start, &end
+	}
+	return criteria
+}
+
+// SearchDrift is how a scheduled run differs from the run before it
+type SearchDrift struct {
+	ID                int64                `json:"id"`
+	SearchID          int64                `json:"search_id"`
+	SearchName        string               `json:"search_name"`
+	SessionID         string               `json:"session_id"`
+	PreviousSessionID string               `json:"previous_session_id"`
+	PreviousCDRs      int                  `json:"previous_cdrs"`
+	CurrentCDRs       int                  `json:"current_cdrs"`
+	ChangePercent     float64              `json:"change_percent"`
+	NewCount          int                  `json:"new_count"`
+	MissingCount      int                  `json:"missing_count"`
+	NewCDRIDs         []string             `json:"new_cdr_ids"` // first sessionComparisonIDLimit, sorted
+	MissingCDRIDs     []string             `json:"missing_cdr_ids"`
+	Endpoints         []EndpointComparison `json:"endpoints"`
+	Alerted           bool                 `json:"alerted"`
+	ResultsURL        string               `json:"results_url"`
+	CreatedAt         time.Time            `json:"created_at"`
+}
+
+// AlertSummary describes the drift in one line
+func (sd SearchDrift) AlertSummary() string {
+	return fmt.Sprintf("Search drift (%s): %d -> %d CDRs (%+.1f%%), %d new, %d no longer found",
+		sd.SearchName, sd.PreviousCDRs, sd.CurrentCDRs, sd.ChangePercent, sd.NewCount, sd.MissingCount)
+}
+
+// AlertDetails lists the endpoints whose counts changed
+func (sd SearchDrift) AlertDetails() []string {
+	details := []string{"Session: " + sd.SessionID, "Previous session: " + sd.PreviousSessionID}
+	for _, endpoint := range sd.Endpoints {
+		if endpoint.Change != 0 || endpoint.RerunError != "" {
+			line := fmt.Sprintf("%s: %d -> %d", endpoint.EndpointName, endpoint.OriginalCount, endpoint.RerunCount)
+			if endpoint.RerunError != "" {
+				line += " (" + endpoint.RerunError + ")"
+			}
+			details = append(details, line)
+		}
+	}
+	return details
+}
+
+// AlertLinks links the run's results
+func (sd SearchDrift) AlertLinks() []AlertLink {
+	return []AlertLink{{Label: "Search results", URL: sd.ResultsURL}}
+}
+
+// exceeds reports whether the drift passes the search's alert thresholds
+func (sd *SearchDrift) exceeds(search *ScheduledSearch) bool {
+	return (search.DriftAlertPercent > 0 && math.Abs(sd.ChangePercent) >= search.DriftAlertPercent) ||
+		(search.DriftAlertMissing > 0 && sd.MissingCount >= search.DriftAlertMissing)
+}
+
+// SearchRunSummary reports how a scheduled run went, whether or not it drifted
+type SearchRunSummary struct {
+	SearchID        int64  `json:"search_id"`
+	SearchName      string `json:"search_name"`
+	SessionID       string `json:"session_id,omitempty"`
+	UniqueCDRs      int    `json:"unique_cdrs"`
+	Endpoints       int    `json:"endpoints"`
+	FailedEndpoints int    `json:"failed_endpoints"`
+	Error           string `json:"error,omitempty"`
+	ResultsURL      string `json:"results_url,omitempty"`
+}
+
+// AlertSummary describes the run in one line
+func (rs SearchRunSummary) AlertSummary() string {
+	if rs.Error != "" {
+		return fmt.Sprintf("Scheduled search %s failed", rs.SearchName)
+	}
+	return fmt.Sprintf("Scheduled search %s completed: %d unique CDRs", rs.SearchName, rs.UniqueCDRs)
+}
+
+// AlertDetails gives the error, or the session and how its endpoints did
+func (rs SearchRunSummary) AlertDetails() []string {
+	if rs.Error != "" {
+		return []string{"Error: " + rs.Error}
+	}
+	return []string{
+		"Session: " + rs.SessionID,
+		fmt.Sprintf("Endpoints: %d queried, %d failed", rs.Endpoints, rs.FailedEndpoints),
+	}
+}
+
+// AlertLinks links the run's results; a failed run has none
+func (rs SearchRunSummary) AlertLinks() []AlertLink {
+	if rs.ResultsURL == "" {
+		return nil
+	}
+	return []AlertLink{{Label: "Search results", URL: rs.ResultsURL}}
+}
+
+// SearchScheduler runs scheduled searches as they come due
+type SearchScheduler struct {
+	db          *DatabaseService
+	discovery   *CDRDiscoveryService
+	notifiers   []AlertNotifier
+	summaries   []AlertNotifier // also told about every run, not only drift
+	linkBaseURL string
+	interval    time.Duration // how often due searches are looked for
+	onResult    func(*CDRDiscoveryResult)
+	exporters   map[string]ScheduledExporter
+
+	mu      sync.Mutex
+	running map[int64]bool
+}
+
+// NewSearchScheduler creates a scheduler that searches with discovery; links in alerts
+// start with linkBaseURL
+func NewSearchScheduler(db *DatabaseService, discovery *CDRDiscoveryService, notifiers []AlertNotifier, linkBaseURL string, interval time.Duration) *SearchScheduler {
+	return &SearchScheduler{
+		db:          db,
+		discovery:   discovery,
+		notifiers:   notifiers,
+		linkBaseURL: strings.TrimRight(linkBaseURL, "/"),
+		interval:    interval,
+		running:     make(map[int64]bool),
+	}
+}
+
+// OnResult has the scheduler hand every completed run to fn, e.g. to keep it in the
+// results store and run the search hooks. Call it before Start.
+func (ss *SearchScheduler) OnResult(fn func(*CDRDiscoveryResult)) {
+	ss.onResult = fn
+}
+
+// SendRunSummaries has the scheduler post a summary of every run, completed or failed,
+// through notifiers. Call it before Start.
+func (ss *SearchScheduler) SendRunSummaries(notifiers []AlertNotifier) {
+	ss.summaries = notifiers
+}
+
+// ExportTo gives the scheduler the destinations searches can export their runs to. Call
+// it before Start.
+func (ss *SearchScheduler) ExportTo(exporters ...ScheduledExporter) {
+	ss.exporters = make(map[string]ScheduledExporter, len(exporters))
+	for _, exporter := range exporters {
+		ss.exporters[exporter.Name()] = exporter
+	}
+}
+
+// Start looks for due searches every interval in the background
+func (ss *SearchScheduler) Start() {
+	if ss.interval <= 0 {
+		return
+	}
+	log.Printf("[Scheduler] Checking for due searches every %s", ss.interval)
+
+	go func() {
+		for {
+			time.Sleep(ss.interval)
+			ss.RunDue()
+		}
+	}()
+}
+
+// RunDue runs every enabled search whose interval has passed
+func (ss *SearchScheduler) RunDue() {
+	searches, err := ss.db.GetScheduledSearches()
+	if err != nil {
+		log.Printf("[Scheduler] %v", err)
+		return
+	}
+	now := time.Now()
+	for i := range searches {
+		if !searches[i].Due(now) {
+			continue
+		}
+		if _, _, err := ss.Run(searches[i].ID); err != nil {
+			log.Printf("[Scheduler] Search %s failed: %v", searches[i].Name, err)
+		}
+	}
+}
+
+// Run runs a scheduled search now and returns its result with the drift from the
+// previous run, which is nil for the first run
+func (ss *SearchScheduler) Run(id int64) (*CDRDiscoveryResult, *SearchDrift, error) {
+	ss.mu.Lock()
+	if ss.running[id] {
+		ss.mu.Unlock()
+		return nil, nil, fmt.Errorf("search %d is already running", id)
+	}
+	ss.running[id] = true
+	ss.mu.Unlock()
+	defer func() {
+		ss.mu.Lock()
+		delete(ss.running, id)
+		ss.mu.Unlock()
+	}()
+
+	search, err := ss.db.GetScheduledSearch(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	ranAt := time.Now().UTC()
+	result, err := ss.discovery.GetComprehensiveCDRs(search.criteriaAt(ranAt))
+	if err != nil {
+		if recordErr := ss.db.RecordScheduledSearchFailure(id, ranAt, err); recordErr != nil {
+			log.Printf("[Scheduler] %v", recordErr)
+		}
+		ss.sendSummary(SearchRunSummary{SearchID: id, SearchName: search.Name, Error: err.Error()})
+		return nil, nil, err
+	}
+	ss.sendSummary(ss.runSummary(search, result))
+	if ss.onResult != nil {
+		ss.onResult(result)
+	}
+	ss.sendReports(search, result)
+	ss.runExports(search, result)
+
+	var drift *SearchDrift
+	if search.LastSessionID != "" {
+		if drift, err = ss.measureDrift(search, result); err != nil {
+			return result, nil, err
+		}
+	}
+	if err := ss.db.RecordScheduledSearchRun(id, ranAt, result); err != nil {
+		return result, drift, err
+	}
+	log.Printf("[Scheduler] Search %s found %d unique CDRs (session %s)", search.Name, result.UniqueCDRs, result.SessionID)
+	return result, drift, nil
+}
+
+// runSummary summarizes a completed run
+func (ss *SearchScheduler) runSummary(search *ScheduledSearch, result *CDRDiscoveryResult) SearchRunSummary {
+	summary := SearchRunSummary{
+		SearchID:   search.ID,
+		SearchName: search.Name,
+		SessionID:  result.SessionID,
+		UniqueCDRs: result.UniqueCDRs,
+		Endpoints:  len(result.EndpointResults),
+		ResultsURL: ss.linkBaseURL + "/web/results/" + url.PathEscape(result.SessionID),
+	}
+	for _, endpoint := range result.EndpointResults {
+		if !endpoint.Success {
+			summary.FailedEndpoints++
+		}
+	}
+	return summary
+}
+
+// sendSummary posts a run summary to the summary notifiers
+func (ss *SearchScheduler) sendSummary(summary SearchRunSummary) {
+	for _, notifier := range ss.summaries {
+		if err := notifier.Notify(summary); err != nil {
+			log.Printf("[Scheduler] Failed to send run summary for %s by %s: %v", summary.SearchName, notifier.Name(), err)
+		}
+	}
+}
+
+// sendReports posts the search's session reports over a run to the alert notifiers
+func (ss *SearchScheduler) sendReports(search *ScheduledSearch, result *CDRDiscoveryResult) {
+	for _, reportType := range search.Reports {
+		report, err := BuildSessionReport(reportType, result.SessionID, result.AllCDRs, ss.linkBaseURL)
+		if err != nil {
+			log.Printf("[Scheduler] Search %s: %v", search.Name, err)
+			continue
+		}
+		for _, notifier := range ss.notifiers {
+			if err := notifier.Notify(report); err != nil {
+				log.Printf("[Scheduler] Failed to send %s report for %s by %s: %v", reportType, search.Name, notifier.Name(), err)
+			}
+		}
+	}
+}
+
+// runExports exports a run's CDRs to the search's destinations in the background
+func (ss *SearchScheduler) runExports(search *ScheduledSearch, result *CDRDiscoveryResult) {
+	for _, destination := range search.Exports {
+		exporter, exists := ss.exporters[destination]
+		if !exists || !exporter.Enabled() {
+			log.Printf("[Scheduler] Search %s exports to %s, which isn't configured", search.Name, destination)
+			continue
+		}
+		go func() {
+			if err := exporter.Export(result); err != nil {
+				log.Printf("[Scheduler] Exporting search %s to %s failed: %v", search.Name, destination, err)
+			}
+		}()
+	}
+}
+
+// measureDrift diffs a run against the one before it, stores the delta and alerts when
+// it passes the search's thresholds
+func (ss *SearchScheduler) measureDrift(search *ScheduledSearch, result *CDRDiscoveryResult) (*SearchDrift, error) {
+	previousIDs, previousEndpoints, err := ss.db.GetScheduledSearchLastRun(search.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := &SearchDrift{
+		SearchID:          search.ID,
+		SearchName:        search.Name,
+		SessionID:         result.SessionID,
+		PreviousSessionID: search.LastSessionID,
+		PreviousCDRs:      len(previousIDs),
+		CurrentCDRs:       result.UniqueCDRs,
+		Endpoints:         compareEndpoints(previousEndpoints, result.EndpointResults),
+		ResultsURL:        ss.linkBaseURL + "/web/results/" + url.PathEscape(result.SessionID),
+	}
+	drift.NewCDRIDs, drift.MissingCDRIDs, _ = compareCDRIDs(previousIDs, sessionCDRIDs(result))
+	drift.NewCount, drift.MissingCount = len(drift.NewCDRIDs), len(drift.MissingCDRIDs)
+	drift.NewCDRIDs, drift.MissingCDRIDs = firstSortedIDs(drift.NewCDRIDs), firstSortedIDs(drift.MissingCDRIDs)
+	switch {
+	case drift.PreviousCDRs > 0:
+		drift.ChangePercent = roundTo(float64(drift.CurrentCDRs-drift.PreviousCDRs)/float64(drift.PreviousCDRs)*100, 1)
+	case drift.CurrentCDRs > 0:
+		drift.ChangePercent = 100
+	}
+	drift.Alerted = drift.exceeds(search)
+
+	if err := ss.db.SaveSearchDrift(drift); err != nil {
+		return nil, err
+	}
+	if drift.Alerted {
+		for _, notifier := range ss.notifiers {
+			if err := notifier.Notify(*drift); err != nil {
+				log.Printf("[Scheduler] Failed to send drift alert for %s by %s: %v", search.Name, notifier.Name(), err)
+			}
+		}
+	}
+	return drift, nil
+}
+
+// SaveScheduledSearch creates a search (ID 0) or updates one
+func (ds *DatabaseService) SaveScheduledSearch(search *ScheduledSearch) error {
+	if err := search.Validate(); err != nil {
+		return err
+	}
+	criteria, err := json.Marshal(search.Criteria)
+	if err != nil {
+		return fmt.Errorf("failed to encode criteria: %w", err)
+	}
+	reports, err := json.Marshal(search.Reports)
+	if err != nil {
+		return fmt.Errorf("failed to encode reports: %w", err)
+	}
+	exports, err := json.Marshal(search.Exports)
+	if err != nil {
+		return fmt.Errorf("failed to encode exports: %w", err)
+	}
+	now := time.Now().UTC()
+
+	if search.ID == 0 {
+		err = ds.db.QueryRow(`
+		INSERT INTO scheduled_searches (name, criteria, lookback_days, interval_minutes, drift_alert_percent, drift_alert_missing, reports, exports, disabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id, created_at, updated_at`,
+			search.Name, string(criteria), search.LookbackDays, search.IntervalMinutes, search.DriftAlertPercent,
+			search.DriftAlertMissing, string(reports), string(exports), search.Disabled, now, now,
+		).Scan(&search.ID, &search.CreatedAt, &search.UpdatedAt)
+	} else {
+		err = ds.db.QueryRow(`
+		UPDATE scheduled_searches SET name = ?, criteria = ?, lookback_days = ?, interval_minutes = ?,
+			drift_alert_percent = ?, drift_alert_missing = ?, reports = ?, exports = ?, disabled = ?, updated_at = ?
+		WHERE id = ?
+		RETURNING created_at, updated_at`,
+			search.Name, string(criteria), search.LookbackDays, search.IntervalMinutes, search.DriftAlertPercent,
+			search.DriftAlertMissing, string(reports), string(exports), search.Disabled, now, search.ID,
+		).Scan(&search.CreatedAt, &search.UpdatedAt)
+		if err == sql.ErrNoRows {
+			return ErrScheduledSearchNotFound
+		}
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return fmt.Errorf("a scheduled search named %q already exists", search.Name)
+		}
+		return fmt.Errorf("failed to save scheduled search: %w", err)
+	}
+	return nil
+}
+
+// scheduledSearchColumns are read by scanScheduledSearch
+const scheduledSearchColumns = `id, name, criteria, lookback_days, interval_minutes, drift_alert_percent, drift_alert_missing,
+	COALESCE(reports, ''), COALESCE(exports, ''), disabled, last_run_at, COALESCE(last_session_id, ''), COALESCE(last_error, ''), created_at, updated_at`
+
+// scanScheduledSearch reads a row of scheduledSearchColumns
+func scanScheduledSearch(row interface{ Scan(...interface{}) error }) (*ScheduledSearch, error) {
+	var search ScheduledSearch
+	var criteria, reports, exports string
+	var lastRunAt sql.NullTime
+	if err := row.Scan(&search.ID, &search.Name, &criteria, &search.LookbackDays, &search.IntervalMinutes,
+		&search.DriftAlertPercent, &search.DriftAlertMissing, &reports, &exports, &search.Disabled, &lastRunAt,
+		&search.LastSessionID, &search.LastError, &search.CreatedAt, &search.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(criteria), &search.Criteria); err != nil {
+		return nil, fmt.Errorf("invalid criteria in scheduled search %s: %w", search.Name, err)
+	}
+	if reports != "" {
+		if err := json.Unmarshal([]byte(reports), &search.Reports); err != nil {
+			return nil, fmt.Errorf("invalid reports in scheduled search %s: %w", search.Name, err)
+		}
+	}
+	if exports != "" {
+		if err := json.Unmarshal([]byte(exports), &search.Exports); err != nil {
+			return nil, fmt.Errorf("invalid exports in scheduled search %s: %w", search.Name, err)
+		}
+	}
+	if lastRunAt.Valid {
+		search.LastRunAt = &lastRunAt.Time
+	}
+	return &search, nil
+}
+
+// GetScheduledSearches returns every scheduled search by name
+func (ds *DatabaseService) GetScheduledSearches() ([]ScheduledSearch, error) {
+	rows, err := ds.db.Query(`SELECT ` + scheduledSearchColumns + ` FROM scheduled_searches ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled searches: %w", err)
+	}
+	defer rows.Close()
+
+	searches := []ScheduledSearch{}
+	for rows.Next() {
+		search, err := scanScheduledSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		searches = append(searches, *search)
+	}
+	return searches, rows.Err()
+}
+
+// GetScheduledSearch returns one scheduled search
+func (ds *DatabaseService) GetScheduledSearch(id int64) (*ScheduledSearch, error) {
+	search, err := scanScheduledSearch(ds.db.QueryRow(`SELECT `+scheduledSearchColumns+` FROM scheduled_searches WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrScheduledSearchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled search: %w", err)
+	}
+	return search, nil
+}
+
+// DeleteScheduledSearch removes a search with its last run and drift history
+func (ds *DatabaseService) DeleteScheduledSearch(id int64) (bool, error) {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM scheduled_search_cdrs WHERE search_id = ?`,
+		`DELETE FROM search_drift WHERE search_id = ?`,
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			return false, fmt.Errorf("failed to delete scheduled search: %w", err)
+		}
+	}
+	res, err := tx.Exec(`DELETE FROM scheduled_searches WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete scheduled search: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to delete scheduled search: %w", err)
+	}
+	deleted, _ := res.RowsAffected()
+	return deleted > 0, nil
+}
+
+// RecordScheduledSearchRun keeps a run's CDR IDs and endpoint results for diffing the
+// next run against
+func (ds *DatabaseService) RecordScheduledSearchRun(id int64, ranAt time.Time, result *CDRDiscoveryResult) error {
+	endpoints := make([]EndpointResult, len(result.EndpointResults))
+	for i, endpoint := range result.EndpointResults {
+		endpoint.CDRs = nil
+		endpoints[i] = endpoint
+	}
+	endpointsJSON, err := json.Marshal(endpoints)
+	if err != nil {
+		return fmt.Errorf("failed to encode endpoint results: %w", err)
+	}
+
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+	UPDATE scheduled_searches SET last_run_at = ?, last_session_id = ?, last_error = NULL, last_endpoints = ?
+	WHERE id = ?`, ranAt, result.SessionID, string(endpointsJSON), id); err != nil {
+		return fmt.Errorf("failed to record scheduled search run: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM scheduled_search_cdrs WHERE search_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to record scheduled search run: %w", err)
+	}
+	insert, err := tx.Prepare(`INSERT OR IGNORE INTO scheduled_search_cdrs (search_id, cdr_id) VALUES (?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare scheduled search CDR insert: %w", err)
+	}
+	defer insert.Close()
+	for cdrID := range sessionCDRIDs(result) {
+		if _, err := insert.Exec(id, cdrID); err != nil {
+			return fmt.Errorf("failed to record scheduled search run: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit scheduled search run: %w", err)
+	}
+	return nil
+}
+
+// RecordScheduledSearchFailure notes a run that failed; the last good run is kept to
+// diff the next one against
+func (ds *DatabaseService) RecordScheduledSearchFailure(id int64, ranAt time.Time, runErr error) error {
+	if _, err := ds.db.Exec(`UPDATE scheduled_searches SET last_run_at = ?, last_error = ? WHERE id = ?`,
+		ranAt, runErr.Error(), id); err != nil {
+		return fmt.Errorf("failed to record scheduled search failure: %w", err)
+	}
+	return nil
+}
+
+// GetScheduledSearchLastRun returns the CDR IDs and endpoint results of a search's last
+// successful run
+func (ds *DatabaseService) GetScheduledSearchLastRun(id int64) (map[string]bool, []EndpointResult, error) {
+	var endpointsJSON sql.NullString
+	if err := ds.db.QueryRow(`SELECT last_endpoints FROM scheduled_searches WHERE id = ?`, id).Scan(&endpointsJSON); err != nil {
+		return nil, nil, fmt.Errorf("failed to get last run: %w", err)
+	}
+	endpoints := []EndpointResult{}
+	if endpointsJSON.Valid {
+		if err := json.Unmarshal([]byte(endpointsJSON.String), &endpoints); err != nil {
+			return nil, nil, fmt.Errorf("invalid endpoint results in last run: %w", err)
+		}
+	}
+
+	rows, err := ds.db.Query(`SELECT cdr_id FROM scheduled_search_cdrs WHERE search_id = ?`, id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get last run CDRs: %w", err)
+	}
+	defer rows.Close()
+	ids := make(map[string]bool)
+	for rows.Next() {
+		var cdrID string
+		if err := rows.Scan(&cdrID); err != nil {
+			return nil, nil, err
+		}
+		ids[cdrID] = true
+	}
+	return ids, endpoints, rows.Err()
+}
+
+// SaveSearchDrift stores the delta between two runs
+func (ds *DatabaseService) SaveSearchDrift(drift *SearchDrift) error {
+	newIDs, _ := json.Marshal(drift.NewCDRIDs)
+	missingIDs, _ := json.Marshal(drift.MissingCDRIDs)
+	endpoints, _ := json.Marshal(drift.Endpoints)
+	drift.CreatedAt = time.Now().UTC()
+
+	res, err := ds.db.Exec(`
+	INSERT INTO search_drift (
+		search_id, session_id, previous_session_id, previous_cdrs, current_cdrs, change_percent,
+		new_count, missing_count, new_cdr_ids, missing_cdr_ids, endpoints, alerted, created_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		drift.SearchID, drift.SessionID, drift.PreviousSessionID, drift.PreviousCDRs, drift.CurrentCDRs, drift.ChangePercent,
+		drift.NewCount, drift.MissingCount, string(newIDs), string(missingIDs), string(endpoints), drift.Alerted, drift.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save search drift: %w", err)
+	}
+	drift.ID, _ = res.LastInsertId()
+	return nil
+}
+
+// GetSearchDrift lists a search's drift records, newest first
+func (ds *DatabaseService) GetSearchDrift(searchID int64, limit int) ([]SearchDrift, error) {
+	rows, err := ds.db.Query(`
+	SELECT d.id, d.search_id, s.name, d.session_id, d.previous_session_id, d.previous_cdrs, d.current_cdrs,
+		d.change_percent, d.new_count, d.missing_count, d.new_cdr_ids, d.missing_cdr_ids, d.endpoints, d.alerted, d.created_at
+	FROM search_drift d JOIN scheduled_searches s ON s.id = d.search_id
+	WHERE d.search_id = ? ORDER BY d.created_at DESC, d.id DESC LIMIT ?`, searchID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get search drift: %w", err)
+	}
+	defer rows.Close()
+
+	drifts := []SearchDrift{}
+	for rows.Next() {
+		var drift SearchDrift
+		var newIDs, missingIDs, endpoints string
+		if err := rows.Scan(&drift.ID, &drift.SearchID, &drift.SearchName, &drift.SessionID, &drift.PreviousSessionID,
+			&drift.PreviousCDRs, &drift.CurrentCDRs, &drift.ChangePercent, &drift.NewCount, &drift.MissingCount,
+			&newIDs, &missingIDs, &endpoints, &drift.Alerted, &drift.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan search drift: %w", err)
+		}
+		json.Unmarshal([]byte(newIDs), &drift.NewCDRIDs)
+		json.Unmarshal([]byte(missingIDs), &drift.MissingCDRIDs)
+		json.Unmarshal([]byte(endpoints), &drift.Endpoints)
+		drift.ResultsURL = "/web/results/" + url.PathEscape(drift.SessionID)
+		drifts = append(drifts, drift)
+	}
+	return drifts, rows.Err()
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduledSearchDrift(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "scheduled.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	mock, _ := NewMockNetSapiens(MockNetSapiensSettings{CDRs: 200, Seed: 3})
+	api := httptest.NewServer(mock)
+	defer api.Close()
+	discovery := NewCDRDiscoveryService(api.URL, "token")
+	discovery.debug = false
+
+	received := []SearchDrift{}
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var drift SearchDrift
+		json.NewDecoder(r.Body).Decode(&drift)
+		received = append(received, drift)
+	}))
+	defer webhook.Close()
+
+	search := &ScheduledSearch{Name: "acme", Criteria: CDRSearchCriteria{Domain: "acme.example.com", Limit: 500}, IntervalMinutes: 60, DriftAlertMissing: 5}
+	if err := db.SaveScheduledSearch(search); err != nil {
+		t.Fatalf("SaveScheduledSearch: %v", err)
+	}
+	if err := db.SaveScheduledSearch(&ScheduledSearch{Name: "too often", IntervalMinutes: 1}); err == nil {
+		t.Error("saved a search with a 1 minute interval")
+	}
+
+	scheduler := NewSearchScheduler(db, discovery, NewAlertNotifiers(AlertSettings{WebhookURL: webhook.URL}), "https://odango.example.com", 0)
+	completed := 0
+	scheduler.OnResult(func(*CDRDiscoveryResult) { completed++ })
+
+	first, drift, err := scheduler.Run(search.ID)
+	if err != nil || drift != nil || first.UniqueCDRs == 0 {
+		t.Fatalf("first run = %v, drift %+v, %v; want CDRs and no drift", first, drift, err)
+	}
+	stored, _ := db.GetScheduledSearch(search.ID)
+	if stored.LastSessionID != first.SessionID || stored.Due(time.Now()) || !stored.Due(time.Now().Add(time.Hour)) {
+		t.Errorf("after a run the search is %+v", stored)
+	}
+
+	// The same data again: no drift and no alert
+	_, drift, err = scheduler.Run(search.ID)
+	if err != nil || drift == nil || drift.NewCount != 0 || drift.MissingCount != 0 || drift.Alerted {
+		t.Fatalf("unchanged run drift = %+v, %v", drift, err)
+	}
+
+	// Fewer CDRs come back: the ones no longer found pass the threshold and alert
+	search.Criteria.Limit = 20
+	if err := db.SaveScheduledSearch(search); err != nil {
+		t.Fatalf("SaveScheduledSearch: %v", err)
+	}
+	third, drift, err := scheduler.Run(search.ID)
+	if err != nil || drift == nil || !drift.Alerted || drift.CurrentCDRs != third.UniqueCDRs ||
+		drift.PreviousCDRs != first.UniqueCDRs || drift.MissingCount != first.UniqueCDRs-third.UniqueCDRs || drift.ChangePercent >= 0 {
+		t.Fatalf("shrinking run drift = %+v, %v", drift, err)
+	}
+	if len(received) != 1 || received[0].SearchName != "acme" || received[0].ResultsURL != "https://odango.example.com/web/results/"+third.SessionID {
+		t.Errorf("webhook received %+v", received)
+	}
+	if completed != 3 {
+		t.Errorf("OnResult called %d times, want 3", completed)
+	}
+
+	history, err := db.GetSearchDrift(search.ID, 10)
+	if err != nil || len(history) != 2 || history[0].SessionID != third.SessionID || len(history[0].MissingCDRIDs) == 0 {
+		t.Fatalf("GetSearchDrift = %+v, %v", history, err)
+	}
+
+	if deleted, err := db.DeleteScheduledSearch(search.ID); err != nil || !deleted {
+		t.Fatalf("DeleteScheduledSearch = %v, %v", deleted, err)
+	}
+	if _, _, err := scheduler.Run(search.ID); err != ErrScheduledSearchNotFound {
+		t.Errorf("running a deleted search: %v", err)
+	}
+}
+
+func TestSearchRunSummariesToSlack(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "summaries.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	mock, _ := NewMockNetSapiens(MockNetSapiensSettings{CDRs: 50, Seed: 5})
+	api := httptest.NewServer(mock)
+	defer api.Close()
+	discovery := NewCDRDiscoveryService(api.URL, "token")
+	discovery.debug = false
+
+	messages := []map[string]interface{}{}
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": "invalid_auth"})
+			return
+		}
+		var message map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&message)
+		messages = append(messages, message)
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true})
+	}))
+	defer slack.Close()
+	defer func(original string) { slackPostMessageURL = original }(slackPostMessageURL)
+	slackPostMessageURL = slack.URL
+
+	notifiers := NewAlertNotifiers(AlertSettings{WebhookURL: "http://127.0.0.1:1/unused", SlackBotToken: "xoxb-test", SlackChannel: "#cdr-alerts"})
+	summaries := NotifiersNamed(notifiers, "slack")
+	if len(summaries) != 1 {
+		t.Fatalf("NotifiersNamed picked %d notifiers, want Slack", len(summaries))
+	}
+
+	search := &ScheduledSearch{Name: "acme", Criteria: CDRSearchCriteria{Domain: "acme.example.com"}, IntervalMinutes: 60}
+	if err := db.SaveScheduledSearch(search); err != nil {
+		t.Fatalf("SaveScheduledSearch: %v", err)
+	}
+	scheduler := NewSearchScheduler(db, discovery, nil, "https://odango.example.com", 0)
+	scheduler.SendRunSummaries(summaries)
+
+	result, _, err := scheduler.Run(search.ID)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(messages) != 1 || messages[0]["channel"] != "#cdr-alerts" {
+		t.Fatalf("Slack received %+v", messages)
+	}
+	text, _ := messages[0]["text"].(string)
+	if !strings.Contains(text, "Scheduled search acme completed") || !strings.Contains(text, "https://odango.example.com/web/results/"+result.SessionID) {
+		t.Errorf("summary text = %q", text)
+	}
+
+	// Endpoints that fail are counted in the summary
+	api.Close()
+	result, _, err = scheduler.Run(search.ID)
+	if err != nil {
+		t.Fatalf("Run against a closed API: %v", err)
+	}
+	failed := fmt.Sprintf("Endpoints: %d queried, %d failed", len(result.EndpointResults), len(result.EndpointResults))
+	if len(messages) != 2 || !strings.Contains(messages[1]["text"].(string), failed) {
+		t.Errorf("Slack received %+v after the API went away, want %q", messages, failed)
+	}
+
+	rejected := &SlackAlertNotifier{client: http.DefaultClient, url: slack.URL, token: "wrong", channel: "#cdr-alerts"}
+	if err := rejected.Notify(SearchRunSummary{SearchName: "acme"}); err == nil || !strings.Contains(err.Error(), "invalid_auth") {
+		t.Errorf("rejected message error = %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+// services/search_storage.go
+// Search storage: keeps each completed search and summaries of its CDRs in the database
+// so reports can be generated from them. Summaries are written in batches inside one
+// transaction, and the insert throughput is recorded on the search session.
+
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"o-dan-go/models"
+)
+
+// cdrSummaryColumns is the number of values bound per cdr_summaries row
+const cdrSummaryColumns = 14
+
+// cdrSummaryBatchSize rows go in each INSERT, keeping the bound values under SQLite's
+// default limit of 999
+const cdrSummaryBatchSize = 999 / cdrSummaryColumns
+
+// BulkInsertStats describes how a batch of rows was written
+type BulkInsertStats struct {
+	Rows          int     `json:"rows"`
+	Batches       int     `json:"batches"`
+	DurationMS    int64   `json:"duration_ms"`
+	RowsPerSecond float64 `json:"rows_per_second"`
+}
+
+// SearchStorage stores completed searches when turned on
+type SearchStorage struct {
+	db      *DatabaseService
+	enabled bool
+}
+
+// NewSearchStorage creates search storage; it stores nothing unless enabled
+func NewSearchStorage(db *DatabaseService, enabled bool) *SearchStorage {
+	return &SearchStorage{
+		db:      db,
+		enabled: enabled,
+	}
+}
+
+// StoresSearches reports whether searches are stored as they complete
+func (ss *SearchStorage) StoresSearches() bool {
+	return ss != nil && ss.enabled
+}
+
+// Store records a search session and summaries of its CDRs
+func (ss *SearchStorage) Store(sessionID string, criteria CDRSearchCriteria, cdrs []models.FlexibleCDR) (*BulkInsertStats, error) {
+	if err := ss.db.StoreSearchSession(sessionID, criteria, len(cdrs)); err != nil {
+		return nil, fmt.Errorf("failed to store search session: %w", err)
+	}
+	stats, err := ss.db.StoreCDRSummaries(cdrs)
+	if err != nil {
+		return nil, err
+	}
+	if err := ss.db.LinkSessionCDRs(sessionID, cdrs); err != nil {
+		return stats, err
+	}
+	if err := ss.db.RecordSessionStorage(sessionID, stats); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// StoreCDRSummaries stores summaries of many CDRs in one transaction, several rows per
+// INSERT. CDRs without an ID are skipped.
+func (ds *DatabaseService) StoreCDRSummaries(cdrs []models.FlexibleCDR) (*BulkInsertStats, error) {
+	started := time.Now()
+	stats := &BulkInsertStats{}
+
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Every full batch shares one cached statement; the last, shorter batch gets its own
+	insert, err := ds.prepared(cdrSummaryInsert(cdrSummaryBatchSize))
+	if err != nil {
+		return nil, err
+	}
+	fullBatch := tx.Stmt(insert)
+	defer fullBatch.Close()
+
+	args := make([]interface{}, 0, cdrSummaryBatchSize*cdrSummaryColumns)
+	flush := func() error {
+		rows := len(args) / cdrSummaryColumns
+		if rows == 0 {
+			return nil
+		}
+		var err error
+		if rows == cdrSummaryBatchSize {
+			_, err = fullBatch.Exec(args...)
+		} else {
+			_, err = tx.Exec(cdrSummaryInsert(rows), args...)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to store CDR summaries: %w", err)
+		}
+		stats.Rows += rows
+		stats.Batches++
+		args = args[:0]
+		return nil
+	}
+
+	for i := range cdrs {
+		cdr := &cdrs[i]
+		if cdr.GetID() == "" {
+			continue
+		}
+		startTime, _ := cdr.GetCallStartTime()
+		args = append(args,
+			cdr.GetID(),
+			cdr.GetDomain(),
+			cdr.GetCallDirection(),
+			startTime,
+			cdr.GetCallDuration(),
+			cdr.GetOrigUser(),
+			cdr.GetTermUser(),
+			cdr.GetOrigCallerID(),
+			cdr.GetTermCallerID(),
+			cdr.GetDisconnectReason(),
+			len(cdr.GetFieldNames()),
+			cdr.HasTranscriptionData(),
+			cdr.HasSentimentData(),
+			ClassifyCall(*cdr),
+		)
+		if len(args) == cap(args) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit CDR summaries: %w", err)
+	}
+
+	elapsed := time.Since(started)
+	stats.DurationMS = elapsed.Milliseconds()
+	if elapsed > 0 {
+		stats.RowsPerSecond = roundTo(float64(stats.Rows)/elapsed.Seconds(), 1)
+	}
+	return stats, nil
+}
+
+// cdrSummaryInsert builds an INSERT of rows cdr_summaries rows
+func cdrSummaryInsert(rows int) string {
+	placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", cdrSummaryColumns), ", ") + ")"
+	values := make([]string, rows)
+	for i := range values {
+		values[i] = placeholders
+	}
+	return `
+	INSERT OR REPLACE INTO cdr_summaries (
+		cdr_id, domain, call_direction, call_start_time, call_duration_seconds,
+		orig_user, term_user, orig_caller_id, term_caller_id, disconnect_reason,
+		field_count, has_transcription, has_sentiment, call_class
+	) VALUES ` + strings.Join(values, ", ")
+}
+
+// sessionCDRBatchSize links go in each INSERT, two bound values apiece
+const sessionCDRBatchSize = 999 / 2
+
+// LinkSessionCDRs records which CDRs a session found, so the session's summaries can be
+// told apart from other sessions' when it is deleted. CDRs without an ID are skipped.
+func (ds *DatabaseService) LinkSessionCDRs(sessionID string, cdrs []models.FlexibleCDR) error {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	values := []string{}
+	args := []interface{}{}
+	flush := func() error {
+		if len(values) == 0 {
+			return nil
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO session_cdrs (session_id, cdr_id) VALUES `+strings.Join(values, ", "), args...); err != nil {
+			return fmt.Errorf("failed to link session CDRs: %w", err)
+		}
+		values, args = values[:0], args[:0]
+		return nil
+	}
+	for i := range cdrs {
+		id := cdrs[i].GetID()
+		if id == "" {
+			continue
+		}
+		values = append(values, "(?, ?)")
+		args = append(args, sessionID, id)
+		if len(values) == sessionCDRBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RecordSessionStorage saves how a session's CDR summaries were written
+func (ds *DatabaseService) RecordSessionStorage(sessionID string, stats *BulkInsertStats) error {
+	stmt, err := ds.prepared(`
+	UPDATE search_sessions SET stored_cdrs = ?, store_duration_ms = ?, store_rows_per_second = ?
+	WHERE session_id = ?`)
+	if err != nil {
+		return err
+	}
+	if _, err := stmt.Exec(stats.Rows, stats.DurationMS, stats.RowsPerSecond, sessionID); err != nil {
+		return fmt.Errorf("failed to record session storage: %w", err)
+	}
+	return nil
+}
+
+// StoredCDRs returns the stored summaries of the CDRs that started in [start, end),
+// optionally for one domain, as CDRs with the fields they were summarized from
+func (ds *DatabaseService) StoredCDRs(domain string, start, end time.Time) ([]models.FlexibleCDR, error) {
+	query := `
+	SELECT cdr_id, COALESCE(domain, ''), COALESCE(call_direction, -1), call_start_time,
+		COALESCE(call_duration_seconds, 0), COALESCE(orig_user, ''), COALESCE(term_user, ''),
+		COALESCE(orig_caller_id, 0), COALESCE(term_caller_id, 0), COALESCE(disconnect_reason, ''),
+		COALESCE(call_class, '')
+	FROM cdr_summaries
+	WHERE call_start_time >= ? AND call_start_time < ?`
+	args := []interface{}{start, end}
+	if domain != "" {
+		query += " AND domain = ?"
+		args = append(args, domain)
+	}
+	query += " ORDER BY call_start_time"
+
+	rows, err := ds.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stored CDRs: %w", err)
+	}
+	defer rows.Close()
+
+	cdrs := []models.FlexibleCDR{}
+	for rows.Next() {
+		var record ReportRecord
+		var origCallerID, termCallerID int64
+		if err := rows.Scan(&record.CdrID, &record.Domain, &record.CallDirection, &record.CallStartTime,
+			&record.CallDurationSeconds, &record.OrigUser, &record.TermUser,
+			&origCallerID, &termCallerID, &record.DisconnectReason, &record.CallClass); err != nil {
+			return nil, fmt.Errorf("failed to read stored CDR: %w", err)
+		}
+		cdr := record.filterCDR(false, false)
+		delete(cdr.RawData, "has_transcription")
+		delete(cdr.RawData, "has_sentiment")
+		if record.CallDirection < 0 {
+			delete(cdr.RawData, "call-direction")
+		}
+		if origCallerID != 0 {
+			cdr.RawData["call-orig-caller-id"] = strconv.FormatInt(origCallerID, 10)
+		}
+		if termCallerID != 0 {
+			cdr.RawData["call-term-caller-id"] = strconv.FormatInt(termCallerID, 10)
+		}
+		cdrs = append(cdrs, cdr)
+	}
+	return cdrs, rows.Err()
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestSearchStorage(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "search.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	// Enough CDRs for two full batches and a short one, plus one without an ID
+	cdrs := []models.FlexibleCDR{}
+	for i := 0; i < 2*cdrSummaryBatchSize+5; i++ {
+		cdrs = append(cdrs, models.FlexibleCDR{RawData: map[string]interface{}{
+			"id":                  fmt.Sprintf("cdr-%d", i),
+			"domain":              "acme",
+			"call-start-datetime": "2026-10-13T10:00:00Z",
+		}})
+	}
+	cdrs = append(cdrs, models.FlexibleCDR{RawData: map[string]interface{}{"domain": "acme"}})
+
+	storage := NewSearchStorage(db, true)
+	stats, err := storage.Store("session-1", CDRSearchCriteria{Domain: "acme"}, cdrs)
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if want := 2*cdrSummaryBatchSize + 5; stats.Rows != want || stats.Batches != 3 {
+		t.Errorf("stats = %+v, want %d rows in 3 batches", stats, want)
+	}
+
+	summaries, err := db.GetCDRSummaries("acme", 0)
+	if err != nil || len(summaries) != stats.Rows {
+		t.Fatalf("GetCDRSummaries = %d, %v; want %d", len(summaries), err, stats.Rows)
+	}
+
+	var stored int
+	if err := db.db.QueryRow("SELECT stored_cdrs FROM search_sessions WHERE session_id = ?", "session-1").Scan(&stored); err != nil || stored != stats.Rows {
+		t.Errorf("stored_cdrs = %d, %v; want %d", stored, err, stats.Rows)
+	}
+
+	if NewSearchStorage(db, false).StoresSearches() {
+		t.Error("disabled storage stores searches")
+	}
+}
@@ -0,0 +1,97 @@
+// services/sentiment.go
+// Sentiment for transcribed calls that NetSapiens call intelligence didn't score: the
+// transcription worker runs completed transcripts through the configured analyzer and
+// stores the scores for reporting
+
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// TranscriptSentiment is the stored sentiment of a transcript
+type TranscriptSentiment struct {
+	SentimentScore
+	TranscriptID int64     `json:"transcript_id"`
+	CDRID        string    `json:"cdr_id"`
+	Analyzer     string    `json:"analyzer"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AnalyzeSentiment has the worker score new transcripts with analyzer. Call it before Start.
+func (tw *TranscriptionWorker) AnalyzeSentiment(analyzer SentimentAnalyzer) {
+	tw.analyzer = analyzer
+}
+
+// AnalyzePending scores a batch of completed transcripts that have no sentiment yet,
+// skipping CDRs NetSapiens already scored, and returns how many were scored
+func (tw *TranscriptionWorker) AnalyzePending() (int, error) {
+	if tw.analyzer == nil {
+		return 0, nil
+	}
+	transcripts, err := tw.db.GetTranscriptsWithoutSentiment(transcriptionBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	scored := 0
+	for _, transcript := range transcripts {
+		score, err := tw.analyzer.Analyze(transcript.Text)
+		if err != nil {
+			// Leave the rest for the next pass; the analyzer is likely unavailable
+			return scored, fmt.Errorf("failed to analyze transcript for CDR %s: %w", transcript.CDRID, err)
+		}
+		err = tw.db.SaveTranscriptSentiment(&TranscriptSentiment{
+			SentimentScore: *score,
+			TranscriptID:   transcript.ID,
+			CDRID:          transcript.CDRID,
+			Analyzer:       tw.analyzer.Name(),
+		})
+		if err != nil {
+			return scored, err
+		}
+		scored++
+	}
+	if scored > 0 {
+		log.Printf("[Sentiment] Scored %d transcripts with %s", scored, tw.analyzer.Name())
+	}
+	return scored, nil
+}
+
+// SaveTranscriptSentiment stores a transcript's sentiment, replacing an earlier score
+func (ds *DatabaseService) SaveTranscriptSentiment(sentiment *TranscriptSentiment) error {
+	sentiment.CreatedAt = time.Now().UTC()
+	query := `
+	INSERT OR REPLACE INTO transcript_sentiments (
+		transcript_id, cdr_id, analyzer, score, label,
+		percent_positive, percent_neutral, percent_negative, created_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := ds.db.Exec(query,
+		sentiment.TranscriptID,
+		sentiment.CDRID,
+		sentiment.Analyzer,
+		sentiment.Score,
+		sentiment.Label,
+		sentiment.PercentPositive,
+		sentiment.PercentNeutral,
+		sentiment.PercentNegative,
+		sentiment.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save transcript sentiment: %w", err)
+	}
+	return nil
+}
+
+// GetTranscriptsWithoutSentiment returns completed transcripts still waiting for a score.
+// CDRs stored with NetSapiens sentiment (cdr_summaries.has_sentiment) are left out.
+func (ds *DatabaseService) GetTranscriptsWithoutSentiment(limit int) ([]Transcript, error) {
+	return ds.queryTranscripts(`
+	WHERE t.status = ? AND t.text != ''
+		AND NOT EXISTS (SELECT 1 FROM transcript_sentiments s WHERE s.transcript_id = t.id)
+		AND NOT EXISTS (SELECT 1 FROM cdr_summaries c WHERE c.cdr_id = t.cdr_id AND c.has_sentiment)
+	ORDER BY t.created_at LIMIT ?`, TranscriptCompleted, limit)
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SentimentAnalyzer scores the sentiment of a call transcript
+type SentimentAnalyzer interface {
+	Name() string
+	Analyze(text string) (*SentimentScore, error)
+}
+
+// SentimentScore is the sentiment of a transcript. The percentages split its sentences
+// the way NetSapiens call intelligence does (call-intelligence-percent-positive).
+type SentimentScore struct {
+	Score           float64 `json:"score"` // -1 (negative) to 1 (positive)
+	Label           string  `json:"label"` // positive, neutral, negative
+	PercentPositive float64 `json:"percent_positive"`
+	PercentNeutral  float64 `json:"percent_neutral"`
+	PercentNegative float64 `json:"percent_negative"`
+}
+
+// SentimentSettings selects and configures the sentiment analyzer
+type SentimentSettings struct {
+	Analyzer string // none, lexicon, http
+	URL      string // http analyzer endpoint
+	APIKey   string
+}
+
+// NewSentimentAnalyzer creates the configured analyzer, or nil if sentiment analysis is disabled
+func NewSentimentAnalyzer(settings SentimentSettings) SentimentAnalyzer {
+	switch strings.ToLower(settings.Analyzer) {
+	case "", "none":
+		return nil
+	case "lexicon":
+		return &LexiconSentimentAnalyzer{}
+	case "http":
+		if settings.URL == "" {
+			log.Printf("[Sentiment] The http analyzer needs SENTIMENT_URL, sentiment analysis disabled")
+			return nil
+		}
+		return &HTTPSentimentAnalyzer{
+			client: &http.Client{Timeout: 30 * time.Second},
+			url:    settings.URL,
+			apiKey: settings.APIKey,
+		}
+	default:
+		log.Printf("[Sentiment] Unknown analyzer %q, sentiment analysis disabled", settings.Analyzer)
+		return nil
+	}
+}
+
+// sentimentLabel turns a score into positive, neutral or negative
+func sentimentLabel(score float64) string {
+	switch {
+	case score > 0.2:
+		return "positive"
+	case score < -0.2:
+		return "negative"
+	default:
+		return "neutral"
+	}
+}
+
+var (
+	sentenceBreak = regexp.MustCompile(`[.!?]+`)
+	wordPattern   = regexp.MustCompile(`[a-z']+`)
+)
+
+// positiveWords and negativeWords are common customer service call vocabulary
+var positiveWords = map[string]bool{
+	"thank": true, "thanks": true, "great": true, "good": true, "excellent": true, "perfect": true,
+	"appreciate": true, "appreciated": true, "helpful": true, "happy": true, "glad": true, "awesome": true,
+	"wonderful": true, "resolved": true, "fixed": true, "love": true, "pleased": true, "easy": true,
+	"fantastic": true, "nice": true, "works": true, "working": true, "satisfied": true, "quick": true,
+}
+
+var negativeWords = map[string]bool{
+	"cancel": true, "cancelled": true, "angry": true, "frustrated": true, "frustrating": true, "terrible": true,
+	"awful": true, "bad": true, "broken": true, "problem": true, "problems": true, "issue": true,
+	"issues": true, "complaint": true, "unhappy": true, "disappointed": true, "wrong": true, "refund": true,
+	"lawsuit": true, "lawyer": true, "ridiculous": true, "worst": true, "useless": true, "upset": true,
+	"waiting": true, "failed": true, "error": true, "hate": true, "unacceptable": true, "never": true,
+}
+
+// negations flip the sentiment of the next few words ("not happy")
+var negations = map[string]bool{
+	"not": true, "no": true, "don't": true, "didn't": true, "isn't": true, "wasn't": true,
+	"can't": true, "won't": true, "doesn't": true, "haven't": true,
+}
+
+// LexiconSentimentAnalyzer scores transcripts locally with a word list, for deployments
+// without a sentiment service
+type LexiconSentimentAnalyzer struct{}
+
+func (la *LexiconSentimentAnalyzer) Name() string { return "lexicon" }
+
+// Analyze scores each sentence by its positive and negative words
+func (la *LexiconSentimentAnalyzer) Analyze(text string) (*SentimentScore, error) {
+	var positive, negative, neutral, totalPositive, totalNegative int
+	for _, sentence := range sentenceBreak.Split(strings.ToLower(text), -1) {
+		words := wordPattern.FindAllString(sentence, -1)
+		if len(words) == 0 {
+			continue
+		}
+
+		score, negatedFor := 0, 0
+		for _, word := range words {
+			if negations[word] {
+				negatedFor = 3
+				continue
+			}
+			sign := 1
+			if negatedFor > 0 {
+				sign = -1
+				negatedFor--
+			}
+			if positiveWords[word] {
+				score += sign
+			} else if negativeWords[word] {
+				score -= sign
+			}
+		}
+
+		switch {
+		case score > 0:
+			positive++
+			totalPositive += score
+		case score < 0:
+			negative++
+			totalNegative -= score
+		default:
+			neutral++
+		}
+	}
+
+	result := &SentimentScore{Label: "neutral"}
+	sentences := positive + negative + neutral
+	if sentences == 0 {
+		return result, nil
+	}
+	result.PercentPositive = 100 * float64(positive) / float64(sentences)
+	result.PercentNeutral = 100 * float64(neutral) / float64(sentences)
+	result.PercentNegative = 100 * float64(negative) / float64(sentences)
+	if totalPositive+totalNegative > 0 {
+		result.Score = float64(totalPositive-totalNegative) / float64(totalPositive+totalNegative)
+	}
+	result.Label = sentimentLabel(result.Score)
+	return result, nil
+}
+
+// HTTPSentimentAnalyzer posts transcripts to a sentiment service as {"text": "..."}.
+// The service answers with {"score": -1..1} and optionally "label" and the percent_* splits.
+type HTTPSentimentAnalyzer struct {
+	client *http.Client
+	url    string
+	apiKey string
+}
+
+func (ha *HTTPSentimentAnalyzer) Name() string { return "http" }
+
+// Analyze sends the transcript to the service
+func (ha *HTTPSentimentAnalyzer) Analyze(text string) (*SentimentScore, error) {
+	payload, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	req, err := http.NewRequest("POST", ha.url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if ha.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+ha.apiKey)
+	}
+
+	resp, err := ha.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach sentiment service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("sentiment service returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var result SentimentScore
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode sentiment: %w", err)
+	}
+	if result.Score < -1 || result.Score > 1 {
+		return nil, fmt.Errorf("sentiment score %v is outside -1 to 1", result.Score)
+	}
+	if result.Label == "" {
+		result.Label = sentimentLabel(result.Score)
+	}
+	return &result, nil
+}
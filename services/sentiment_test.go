@@ -0,0 +1,86 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestLexiconSentimentAnalyzer(t *testing.T) {
+	analyzer := NewSentimentAnalyzer(SentimentSettings{Analyzer: "lexicon"})
+
+	tests := []struct {
+		text  string
+		label string
+	}{
+		{"Thanks so much, that was really helpful. Have a great day!", "positive"},
+		{"This is ridiculous. I want to cancel my account. I am not happy.", "negative"},
+		{"My account number is 1234. Let me check.", "neutral"},
+		{"No problem at all.", "positive"},
+	}
+	for _, test := range tests {
+		score, err := analyzer.Analyze(test.text)
+		if err != nil || score.Label != test.label {
+			t.Errorf("Analyze(%q) = %+v, %v; want %s", test.text, score, err, test.label)
+		}
+	}
+
+	score, _ := analyzer.Analyze("Thank you. What is the date. This is terrible.")
+	if score.PercentPositive < 33 || score.PercentPositive > 34 || score.PercentNegative < 33 || score.PercentNeutral < 33 {
+		t.Errorf("unexpected sentence split: %+v", score)
+	}
+}
+
+func TestTranscriptSentimentPipeline(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "sentiment.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	// cdr-2 was already scored by NetSapiens call intelligence
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"id": "cdr-1", "domain": "acme"}},
+		{RawData: map[string]interface{}{"id": "cdr-2", "domain": "acme", "call-intelligence-percent-positive": 80}},
+	}
+	for i, cdr := range cdrs {
+		if err := db.StoreCDRSummary(&cdr); err != nil {
+			t.Fatalf("StoreCDRSummary: %v", err)
+		}
+		recording := &ArchivedRecording{CDRID: cdr.GetID(), RecordingID: "rec", Domain: "acme", Storage: "local", Key: cdr.GetID(), ArchivedAt: time.Now()}
+		if err := db.SaveArchivedRecording(recording); err != nil {
+			t.Fatalf("SaveArchivedRecording: %v", err)
+		}
+		err := db.SaveTranscript(&Transcript{
+			ArchivedRecordingID: recording.ID, CDRID: cdr.GetID(), Backend: "whisper",
+			Text: "I am frustrated, this is the worst service", Status: TranscriptCompleted,
+		})
+		if err != nil {
+			t.Fatalf("SaveTranscript %d: %v", i, err)
+		}
+	}
+
+	tw := NewTranscriptionWorker(db, nil, nil, time.Minute)
+	tw.AnalyzeSentiment(NewSentimentAnalyzer(SentimentSettings{Analyzer: "lexicon"}))
+	if scored, err := tw.AnalyzePending(); err != nil || scored != 1 {
+		t.Fatalf("AnalyzePending = %d, %v; want only the CDR without NetSapiens sentiment", scored, err)
+	}
+	if scored, _ := tw.AnalyzePending(); scored != 0 {
+		t.Fatalf("transcripts scored twice")
+	}
+
+	transcripts, err := db.GetTranscripts("cdr-1")
+	if err != nil || len(transcripts) != 1 || transcripts[0].Sentiment == nil || transcripts[0].Sentiment.Label != "negative" {
+		t.Fatalf("GetTranscripts = %+v, %v", transcripts, err)
+	}
+
+	report, err := db.GenerateSimpleReport("session", "report", ReportCriteria{Domain: "acme"})
+	if err != nil {
+		t.Fatalf("GenerateSimpleReport: %v", err)
+	}
+	if report.Totals.CallsWithSentiment != 2 || report.Totals.CallsWithTranscription != 2 {
+		t.Fatalf("unexpected totals: %+v", report.Totals)
+	}
+}
@@ -0,0 +1,207 @@
+// services/session_deletion.go
+// Session deletion: erases a search session and everything kept from it in one
+// transaction - its CDR summaries and call outcomes, reports, spam scores, fraud and
+// keyword alerts, alert events, notes, flags, drift records and archived recordings with
+// their transcripts - then deletes the recording files and drops its results, endpoint
+// results and correlations from memory, for erasure requests
+
+package services
+
+import (
+	"fmt"
+	"log"
+)
+
+// SessionDeletion counts what deleting a session removed
+type SessionDeletion struct {
+	SessionID     string `json:"session_id"`
+	Stored        bool   `json:"stored"`    // the session was in the database
+	InMemory      bool   `json:"in_memory"` // its results were held for the results page
+	CDRs          int    `json:"cdrs"`
+	SharedCDRs    int    `json:"shared_cdrs"` // kept because another session found them too
+	CallOutcomes  int    `json:"call_outcomes"`
+	Reports       int    `json:"reports"`
+	SpamScores    int    `json:"spam_scores"`
+	FraudAlerts   int    `json:"fraud_alerts"`
+	KeywordAlerts int    `json:"keyword_alerts"`
+	AlertEvents   int    `json:"alert_events"`
+	Notes         int    `json:"notes"`
+	Flags         int    `json:"flags"`
+	Drift         int    `json:"drift"` // scheduled search drift records comparing it with another run
+	Recordings    int    `json:"recordings"`
+	Transcripts   int    `json:"transcripts"`
+	Sentiments    int    `json:"sentiments"`
+	Files         int    `json:"files"` // recording files deleted from the archive
+}
+
+// Found reports whether there was a session to delete
+func (d *SessionDeletion) Found() bool {
+	return d.Stored || d.InMemory
+}
+
+// DeleteSearchSession removes a session from the database and the results store. Its
+// CDRs are those linked to it, plus those of its results in memory for sessions stored
+// before links were kept; CDRs another session also found are left for that session.
+// Recordings archived from the session or for its CDRs are deleted from archive after
+// the transaction commits; archive is nil when archiving is off.
+func (ds *DatabaseService) DeleteSearchSession(sessionID string, archive ArchiveStorage) (*SessionDeletion, error) {
+	deletion := &SessionDeletion{SessionID: sessionID}
+	result, inMemory := GlobalResultsStore.Get(sessionID)
+	deletion.InMemory = inMemory
+
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	cdrIDs := map[string]bool{}
+	rows, err := tx.Query(`SELECT cdr_id FROM session_cdrs WHERE session_id = ?`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session CDRs: %w", err)
+	}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read session CDRs: %w", err)
+		}
+		cdrIDs[id] = true
+	}
+	rows.Close()
+	if inMemory {
+		for i := range result.AllCDRs {
+			if id := result.AllCDRs[i].GetID(); id != "" {
+				cdrIDs[id] = true
+			}
+		}
+	}
+
+	// Recordings archived from the session, or for its CDRs that no other session found
+	recordingKeys := map[int64]string{}
+	selectRecordings := func(where string, arg string) error {
+		rows, err := tx.Query(`SELECT id, storage_key FROM archived_recordings WHERE `+where, arg)
+		if err != nil {
+			return fmt.Errorf("failed to read archived recordings: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int64
+			var key string
+			if err := rows.Scan(&id, &key); err != nil {
+				return fmt.Errorf("failed to read archived recordings: %w", err)
+			}
+			recordingKeys[id] = key
+		}
+		return rows.Err()
+	}
+	if err := selectRecordings(`session_id = ?`, sessionID); err != nil {
+		return nil, err
+	}
+
+	for id := range cdrIDs {
+		var shared bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM session_cdrs WHERE cdr_id = ? AND session_id != ?)`,
+			id, sessionID).Scan(&shared); err != nil {
+			return nil, fmt.Errorf("failed to check CDR %s: %w", id, err)
+		}
+		if shared {
+			deletion.SharedCDRs++
+			continue
+		}
+		res, err := tx.Exec(`DELETE FROM cdr_summaries WHERE cdr_id = ?`, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete CDR %s: %w", id, err)
+		}
+		deleted, _ := res.RowsAffected()
+		deletion.CDRs += int(deleted)
+		if res, err = tx.Exec(`DELETE FROM call_outcomes WHERE cdr_id = ?`, id); err != nil {
+			return nil, fmt.Errorf("failed to delete call outcome of CDR %s: %w", id, err)
+		}
+		deleted, _ = res.RowsAffected()
+		deletion.CallOutcomes += int(deleted)
+		if err := selectRecordings(`cdr_id = ?`, id); err != nil {
+			return nil, err
+		}
+	}
+
+	for id := range recordingKeys {
+		var transcripts, sentiments int
+		if err := tx.QueryRow(`
+		SELECT COUNT(*), (SELECT COUNT(*) FROM transcript_sentiments WHERE transcript_id IN (
+			SELECT id FROM recording_transcripts WHERE archived_recording_id = ?))
+		FROM recording_transcripts WHERE archived_recording_id = ?`, id, id).Scan(&transcripts, &sentiments); err != nil {
+			return nil, fmt.Errorf("failed to count transcripts of recording %d: %w", id, err)
+		}
+		if err := deleteTranscripts(tx, id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`DELETE FROM archived_recordings WHERE id = ?`, id); err != nil {
+			return nil, fmt.Errorf("failed to delete archived recording %d: %w", id, err)
+		}
+		deletion.Recordings++
+		deletion.Transcripts += transcripts
+		deletion.Sentiments += sentiments
+	}
+
+	res, err := tx.Exec(`DELETE FROM search_drift WHERE session_id = ? OR previous_session_id = ?`, sessionID, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete drift of session: %w", err)
+	}
+	deleted, _ := res.RowsAffected()
+	deletion.Drift = int(deleted)
+
+	for _, table := range []struct {
+		name  string
+		count *int
+	}{
+		{"reports", &deletion.Reports},
+		{"spam_scores", &deletion.SpamScores},
+		{"fraud_alerts", &deletion.FraudAlerts},
+		{"keyword_alerts", &deletion.KeywordAlerts},
+		{"alert_rule_events", &deletion.AlertEvents},
+		{"session_notes", &deletion.Notes},
+		{"cdr_flags", &deletion.Flags},
+		{"session_cdrs", nil},
+	} {
+		res, err := tx.Exec(`DELETE FROM `+table.name+` WHERE session_id = ?`, sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete %s of session: %w", table.name, err)
+		}
+		if table.count != nil {
+			deleted, _ := res.RowsAffected()
+			*table.count = int(deleted)
+		}
+	}
+
+	res, err = tx.Exec(`DELETE FROM search_sessions WHERE session_id = ?`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete search session: %w", err)
+	}
+	deleted, _ = res.RowsAffected()
+	deletion.Stored = deleted > 0
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to delete search session: %w", err)
+	}
+	if inMemory {
+		GlobalResultsStore.Delete(sessionID)
+	}
+
+	// The rows are gone; a file that can't be deleted is logged for an operator to remove
+	if archive != nil {
+		for _, key := range recordingKeys {
+			if err := archive.Delete(key); err != nil {
+				log.Printf("[Sessions] Failed to delete archived %s of session %s: %v", key, sessionID, err)
+				continue
+			}
+			deletion.Files++
+		}
+		if err := archive.Delete(manifestKey(sessionID)); err != nil {
+			log.Printf("[Sessions] Failed to delete the archive manifest of session %s: %v", sessionID, err)
+		}
+	} else if len(recordingKeys) > 0 {
+		log.Printf("[Sessions] Archiving is off; %d recording files of session %s were left in storage", len(recordingKeys), sessionID)
+	}
+	return deletion, nil
+}
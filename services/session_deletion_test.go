@@ -0,0 +1,127 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestDeleteSearchSession(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "deletion.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	storage := NewSearchStorage(db, true)
+	cdr := func(id string) models.FlexibleCDR {
+		return models.FlexibleCDR{RawData: map[string]interface{}{"id": id, "domain": "acme"}}
+	}
+	if _, err := storage.Store("s1", CDRSearchCriteria{Domain: "acme"}, []models.FlexibleCDR{cdr("a"), cdr("b")}); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if _, err := storage.Store("s2", CDRSearchCriteria{Domain: "acme"}, []models.FlexibleCDR{cdr("b")}); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if _, err := db.db.Exec(`INSERT INTO reports (session_id, report_name, report_type, report_data) VALUES ('s1', 'r', 'summary', '{}')`); err != nil {
+		t.Fatalf("insert report: %v", err)
+	}
+	GlobalResultsStore.Store("s1", &CDRDiscoveryResult{SessionID: "s1", AllCDRs: []models.FlexibleCDR{cdr("a"), cdr("b")}})
+	defer GlobalResultsStore.Delete("s1")
+
+	deletion, err := db.DeleteSearchSession("s1", nil)
+	if err != nil {
+		t.Fatalf("DeleteSearchSession: %v", err)
+	}
+	if !deletion.Stored || !deletion.InMemory || deletion.CDRs != 1 || deletion.SharedCDRs != 1 || deletion.Reports != 1 {
+		t.Errorf("deletion = %+v, want stored and in memory, 1 CDR, 1 shared and 1 report", deletion)
+	}
+	if _, ok := GlobalResultsStore.Get("s1"); ok {
+		t.Error("s1 still in the results store")
+	}
+
+	var remaining int
+	db.db.QueryRow(`SELECT COUNT(*) FROM cdr_summaries`).Scan(&remaining)
+	if remaining != 1 {
+		t.Errorf("%d CDR summaries left, want s2's 1", remaining)
+	}
+	if session, err := db.GetSearchSession("s2"); err != nil || session == nil {
+		t.Errorf("s2 should be kept: %v", err)
+	}
+
+	if deletion, err := db.DeleteSearchSession("s1", nil); err != nil || deletion.Found() {
+		t.Errorf("deleting again: found %v, err %v", deletion != nil && deletion.Found(), err)
+	}
+}
+
+func TestDeleteSearchSessionRecordings(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "deletion.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+	archive := NewArchiveStorage(ArchiveSettings{Storage: "local", Dir: t.TempDir()})
+
+	storage := NewSearchStorage(db, true)
+	cdr := func(id string) models.FlexibleCDR {
+		return models.FlexibleCDR{RawData: map[string]interface{}{"id": id, "domain": "acme"}}
+	}
+	if _, err := storage.Store("s1", CDRSearchCriteria{Domain: "acme"}, []models.FlexibleCDR{cdr("a"), cdr("b")}); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if _, err := storage.Store("s2", CDRSearchCriteria{Domain: "acme"}, []models.FlexibleCDR{cdr("b")}); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	// a's recording was archived from s1 and transcribed; b's was archived from s2, which keeps it
+	for _, recording := range []struct{ cdr, session, key string }{{"a", "s1", "recordings/a.wav"}, {"b", "s2", "recordings/b.wav"}} {
+		if _, err := archive.Put(recording.key, []byte("RIFF"), "audio/wav"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if _, err := db.db.Exec(`INSERT INTO archived_recordings (cdr_id, recording_id, session_id, domain, storage, storage_key, archived_at)
+			VALUES (?, ?, ?, 'acme', 'local', ?, CURRENT_TIMESTAMP)`, recording.cdr, "rec-"+recording.cdr, recording.session, recording.key); err != nil {
+			t.Fatalf("insert recording: %v", err)
+		}
+	}
+	for _, statement := range []string{
+		`INSERT INTO recording_transcripts (id, archived_recording_id, cdr_id, backend, text, status, created_at, updated_at)
+			VALUES (7, 1, 'a', 'whisper', 'my card number is', 'completed', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+		`INSERT INTO transcripts_fts (docid, text) VALUES (7, 'my card number is')`,
+		`INSERT INTO transcript_sentiments (transcript_id, cdr_id, analyzer, score, label, created_at) VALUES (7, 'a', 'lexicon', -0.5, 'negative', CURRENT_TIMESTAMP)`,
+		`INSERT INTO search_drift (search_id, session_id, previous_session_id, previous_cdrs, current_cdrs, change_percent,
+			new_count, missing_count, new_cdr_ids, missing_cdr_ids, endpoints, created_at)
+			VALUES (1, 's2', 's1', 2, 1, -50, 0, 1, '[]', '["a"]', '[]', CURRENT_TIMESTAMP)`,
+	} {
+		if _, err := db.db.Exec(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	deletion, err := db.DeleteSearchSession("s1", archive)
+	if err != nil {
+		t.Fatalf("DeleteSearchSession: %v", err)
+	}
+	if deletion.Recordings != 1 || deletion.Transcripts != 1 || deletion.Sentiments != 1 || deletion.Drift != 1 || deletion.Files != 1 {
+		t.Errorf("deletion = %+v, want a's recording, transcript, sentiment and file and the drift record", deletion)
+	}
+
+	for table, want := range map[string]int{
+		"archived_recordings":   1,
+		"recording_transcripts": 0,
+		"transcripts_fts":       0,
+		"transcript_sentiments": 0,
+		"search_drift":          0,
+	} {
+		var count int
+		if err := db.db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&count); err != nil || count != want {
+			t.Errorf("%s has %d rows (%v), want %d", table, count, err, want)
+		}
+	}
+	if _, err := archive.Get("recordings/a.wav"); err == nil {
+		t.Error("a's recording file is still archived")
+	}
+	if _, err := archive.Get("recordings/b.wav"); err != nil {
+		t.Errorf("s2's recording file was deleted: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+// services/session_export.go
+// Session export: packages one search session (the search, each endpoint's result and
+// the raw CDRs) into a standalone SQLite file that other tools can open offline
+
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// sessionExportSchema is the layout of an exported session file. Raw CDRs are kept as
+// JSON so every field survives; SQLite's json_extract reads them back.
+var sessionExportSchema = []string{
+	`CREATE TABLE session (
+		session_id TEXT PRIMARY KEY,
+		search_criteria TEXT NOT NULL,  -- JSON of search parameters
+		start_time DATETIME,
+		end_time DATETIME,
+		total_cdrs INTEGER,
+		unique_cdrs INTEGER,
+		errors TEXT,                    -- JSON array
+		masking TEXT,
+		exported_at DATETIME NOT NULL
+	)`,
+	`CREATE TABLE endpoint_results (
+		endpoint_name TEXT PRIMARY KEY,
+		url TEXT,
+		record_count INTEGER,
+		success BOOLEAN,
+		error TEXT,
+		query_time_ms INTEGER,
+		http_status INTEGER,
+		raw_data_used BOOLEAN
+	)`,
+	`CREATE TABLE cdrs (
+		cdr_id TEXT,
+		domain TEXT,
+		call_direction INTEGER,
+		call_start_time DATETIME,
+		call_duration_seconds INTEGER,
+		raw_json TEXT NOT NULL
+	)`,
+	`CREATE TABLE endpoint_cdrs (
+		endpoint_name TEXT NOT NULL,
+		cdr_id TEXT NOT NULL,
+		kept BOOLEAN NOT NULL  -- this endpoint's record is the one in cdrs
+	)`,
+	`CREATE INDEX idx_cdrs_cdr_id ON cdrs(cdr_id)`,
+	`CREATE INDEX idx_endpoint_cdrs_cdr_id ON endpoint_cdrs(cdr_id)`,
+}
+
+// WriteSessionDatabase writes a session to a new SQLite file at path. masking names the
+// masking applied to the CDRs, if any.
+func WriteSessionDatabase(result *CDRDiscoveryResult, masking, path string) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return fmt.Errorf("failed to create session database: %w", err)
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, statement := range sessionExportSchema {
+		if _, err := tx.Exec(statement); err != nil {
+			return fmt.Errorf("failed to create session tables: %w", err)
+		}
+	}
+
+	criteria, _ := json.Marshal(result.SearchCriteria)
+	errors, _ := json.Marshal(result.Errors)
+	if _, err := tx.Exec(`
+	INSERT INTO session (session_id, search_criteria, start_time, end_time, total_cdrs, unique_cdrs, errors, masking, exported_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		result.SessionID, string(criteria), result.StartTime.UTC(), result.EndTime.UTC(),
+		result.TotalCDRs, result.UniqueCDRs, string(errors), masking, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to export session: %w", err)
+	}
+
+	for _, endpoint := range result.EndpointResults {
+		if _, err := tx.Exec(`
+		INSERT OR REPLACE INTO endpoint_results (endpoint_name, url, record_count, success, error, query_time_ms, http_status, raw_data_used)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			endpoint.EndpointName, endpoint.URL, endpoint.RecordCount, endpoint.Success, endpoint.Error,
+			endpoint.QueryTime.Milliseconds(), endpoint.HTTPStatus, endpoint.RawDataUsed); err != nil {
+			return fmt.Errorf("failed to export endpoint result: %w", err)
+		}
+	}
+
+	insertCDR, err := tx.Prepare(`
+	INSERT INTO cdrs (cdr_id, domain, call_direction, call_start_time, call_duration_seconds, raw_json)
+	VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare CDR insert: %w", err)
+	}
+	defer insertCDR.Close()
+	for i := range result.AllCDRs {
+		cdr := &result.AllCDRs[i]
+		raw, err := json.Marshal(cdr.RawData)
+		if err != nil {
+			return fmt.Errorf("failed to encode CDR %s: %w", cdr.GetID(), err)
+		}
+		var started interface{}
+		if startTime, err := cdr.GetCallStartTime(); err == nil {
+			started = startTime.UTC()
+		}
+		if _, err := insertCDR.Exec(cdr.GetID(), cdr.GetDomain(), cdr.GetCallDirection(), started,
+			cdr.GetCallDuration(), string(raw)); err != nil {
+			return fmt.Errorf("failed to export CDR: %w", err)
+		}
+	}
+
+	// Which endpoints returned each CDR, in a stable order
+	endpoints := make([]string, 0, len(result.CDRsByEndpoint))
+	for endpoint := range result.CDRsByEndpoint {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+	insertLink, err := tx.Prepare(`INSERT INTO endpoint_cdrs (endpoint_name, cdr_id, kept) VALUES (?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare endpoint CDR insert: %w", err)
+	}
+	defer insertLink.Close()
+	for _, endpoint := range endpoints {
+		for i := range result.CDRsByEndpoint[endpoint] {
+			id := result.CDRsByEndpoint[endpoint][i].GetID()
+			winner, merged := result.DedupWinners[id]
+			if _, err := insertLink.Exec(endpoint, id, !merged || winner == endpoint); err != nil {
+				return fmt.Errorf("failed to export endpoint CDR: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit session database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestWriteSessionDatabase(t *testing.T) {
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"id": "cdr-1", "domain": "acme", "call-start-datetime": "2026-10-13T10:00:00Z", "orig-from-user": "1001"}},
+		{RawData: map[string]interface{}{"id": "cdr-2", "domain": "acme"}},
+	}
+	result := &CDRDiscoveryResult{
+		SessionID:      "session-1",
+		SearchCriteria: CDRSearchCriteria{Domain: "acme"},
+		StartTime:      time.Now().Add(-time.Second),
+		EndTime:        time.Now(),
+		TotalCDRs:      3,
+		UniqueCDRs:     2,
+		EndpointResults: []EndpointResult{
+			{EndpointName: "domain", RecordCount: 2, Success: true, QueryTime: 1500 * time.Millisecond},
+			{EndpointName: "user", RecordCount: 1, Success: true},
+		},
+		AllCDRs:        cdrs,
+		CDRsByEndpoint: map[string][]models.FlexibleCDR{"domain": cdrs, "user": cdrs[:1]},
+	}
+
+	path := filepath.Join(t.TempDir(), "session.sqlite")
+	if err := WriteSessionDatabase(result, "hash", path); err != nil {
+		t.Fatalf("WriteSessionDatabase: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("open export: %v", err)
+	}
+	defer db.Close()
+
+	var masking string
+	var uniqueCDRs int
+	if err := db.QueryRow("SELECT masking, unique_cdrs FROM session WHERE session_id = 'session-1'").Scan(&masking, &uniqueCDRs); err != nil || masking != "hash" || uniqueCDRs != 2 {
+		t.Errorf("session = %q, %d, %v", masking, uniqueCDRs, err)
+	}
+	var queryTime int
+	if err := db.QueryRow("SELECT query_time_ms FROM endpoint_results WHERE endpoint_name = 'domain'").Scan(&queryTime); err != nil || queryTime != 1500 {
+		t.Errorf("query_time_ms = %d, %v", queryTime, err)
+	}
+	var user string
+	if err := db.QueryRow("SELECT json_extract(raw_json, '$.\"orig-from-user\"') FROM cdrs WHERE cdr_id = 'cdr-1'").Scan(&user); err != nil || user != "1001" {
+		t.Errorf("raw orig-from-user = %q, %v", user, err)
+	}
+	var links int
+	if err := db.QueryRow("SELECT COUNT(*) FROM endpoint_cdrs WHERE cdr_id = 'cdr-1'").Scan(&links); err != nil || links != 2 {
+		t.Errorf("cdr-1 returned by %d endpoints, %v; want 2", links, err)
+	}
+}
@@ -0,0 +1,102 @@
+// services/session_listing.go
+// Session listing: pages through the searches kept in the database, newest first,
+// filtered by date, domain and criteria text, for a sessions management screen
+
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Session listing page sizes
+const (
+	DefaultSessionPageSize = 50
+	MaxSessionPageSize     = 500
+)
+
+// SessionQuery selects a page of stored search sessions
+type SessionQuery struct {
+	Domain string    // the criteria's domain, exactly
+	Text   string    // found anywhere in the session ID or criteria, ignoring case
+	From   time.Time // sessions started at or after
+	To     time.Time // sessions started before
+	Limit  int
+	Offset int
+}
+
+// SessionSummary is one stored search session
+type SessionSummary struct {
+	SessionID    string            `json:"session_id"`
+	Criteria     CDRSearchCriteria `json:"search_criteria"`
+	TotalCDRs    int               `json:"total_cdrs"`
+	StoredCDRs   int               `json:"stored_cdrs"`
+	StartTime    time.Time         `json:"start_time"`
+	QualityScore float64           `json:"quality_score,omitempty"` // 0 when not scored
+	RerunOf      string            `json:"rerun_of,omitempty"`
+	InMemory     bool              `json:"in_memory"` // its results are still held for the results page
+}
+
+// ListSearchSessions returns a page of stored search sessions, newest first, and how
+// many sessions match in all
+func (ds *DatabaseService) ListSearchSessions(query SessionQuery) ([]SessionSummary, int, error) {
+	where := " WHERE 1=1"
+	args := []interface{}{}
+	if query.Domain != "" {
+		where += " AND json_extract(search_criteria, '$.domain') = ?"
+		args = append(args, query.Domain)
+	}
+	if text := strings.TrimSpace(query.Text); text != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(text) + "%"
+		where += ` AND (session_id LIKE ? ESCAPE '\' OR search_criteria LIKE ? ESCAPE '\')`
+		args = append(args, pattern, pattern)
+	}
+	// Start times are stored with their zone, so they're compared as times, not text
+	if !query.From.IsZero() {
+		where += " AND julianday(start_time) >= julianday(?)"
+		args = append(args, query.From.UTC())
+	}
+	if !query.To.IsZero() {
+		where += " AND julianday(start_time) < julianday(?)"
+		args = append(args, query.To.UTC())
+	}
+
+	var total int
+	if err := ds.db.QueryRow(`SELECT COUNT(*) FROM search_sessions`+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count search sessions: %w", err)
+	}
+
+	if query.Limit <= 0 {
+		query.Limit = DefaultSessionPageSize
+	}
+	rows, err := ds.db.Query(`
+	SELECT session_id, search_criteria, total_cdrs, COALESCE(stored_cdrs, 0), start_time,
+		quality_score, COALESCE(rerun_of, '')
+	FROM search_sessions`+where+`
+	ORDER BY julianday(start_time) DESC, session_id LIMIT ? OFFSET ?`, append(args, query.Limit, query.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list search sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []SessionSummary{}
+	for rows.Next() {
+		var session SessionSummary
+		var criteria string
+		var quality sql.NullFloat64
+		if err := rows.Scan(&session.SessionID, &criteria, &session.TotalCDRs, &session.StoredCDRs,
+			&session.StartTime, &quality, &session.RerunOf); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan search session: %w", err)
+		}
+		if err := json.Unmarshal([]byte(criteria), &session.Criteria); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode criteria of session %s: %w", session.SessionID, err)
+		}
+		session.QualityScore = quality.Float64
+		_, session.InMemory = GlobalResultsStore.Get(session.SessionID)
+		sessions = append(sessions, session)
+	}
+	return sessions, total, rows.Err()
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListSearchSessions(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	for _, session := range []struct {
+		id     string
+		domain string
+	}{{"s1", "acme"}, {"s2", "globex"}, {"s3", "acme"}, {"s4", "acme_corp"}} {
+		if err := db.StoreSearchSession(session.id, CDRSearchCriteria{Domain: session.domain}, 1); err != nil {
+			t.Fatalf("StoreSearchSession: %v", err)
+		}
+	}
+
+	sessions, total, err := db.ListSearchSessions(SessionQuery{Domain: "acme"})
+	if err != nil {
+		t.Fatalf("ListSearchSessions: %v", err)
+	}
+	if total != 2 || len(sessions) != 2 {
+		t.Fatalf("domain acme: total %d, %d sessions, want 2", total, len(sessions))
+	}
+	if sessions[0].Criteria.Domain != "acme" {
+		t.Errorf("criteria domain = %q", sessions[0].Criteria.Domain)
+	}
+
+	// _ is matched literally, not as a wildcard
+	if _, total, _ := db.ListSearchSessions(SessionQuery{Text: "ACME_"}); total != 1 {
+		t.Errorf("text ACME_: total %d, want 1", total)
+	}
+
+	sessions, total, err = db.ListSearchSessions(SessionQuery{Limit: 3, Offset: 3})
+	if err != nil {
+		t.Fatalf("ListSearchSessions: %v", err)
+	}
+	if total != 4 || len(sessions) != 1 {
+		t.Errorf("paged: total %d, %d sessions, want 4 and 1", total, len(sessions))
+	}
+
+	if _, total, _ := db.ListSearchSessions(SessionQuery{To: time.Now().Add(-time.Hour)}); total != 0 {
+		t.Errorf("before an hour ago: total %d, want 0", total)
+	}
+	if _, total, _ := db.ListSearchSessions(SessionQuery{From: time.Now().Add(-time.Hour)}); total != 4 {
+		t.Errorf("since an hour ago: total %d, want 4", total)
+	}
+}
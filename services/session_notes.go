@@ -0,0 +1,88 @@
+// services/session_notes.go
+// Session notes: comments analysts attach to a search session or to one of its CDRs,
+// with who wrote them and when, shown on the results page and kept in the session's
+// reports
+
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxNoteLength caps the characters in a note
+const MaxNoteLength = 4000
+
+// SessionNote is a comment on a session, or on one CDR when CDRID is set
+type SessionNote struct {
+	ID        int64     `json:"id"`
+	SessionID string    `json:"session_id"`
+	CDRID     string    `json:"cdr_id,omitempty"`
+	Author    string    `json:"author"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AddSessionNote saves a note, filling in its ID and time
+func (ds *DatabaseService) AddSessionNote(note *SessionNote) error {
+	note.Text = strings.TrimSpace(note.Text)
+	if note.Text == "" {
+		return fmt.Errorf("note text is required")
+	}
+	if len([]rune(note.Text)) > MaxNoteLength {
+		return fmt.Errorf("note is longer than %d characters", MaxNoteLength)
+	}
+	note.CDRID = strings.TrimSpace(note.CDRID)
+	note.Author = strings.TrimSpace(note.Author)
+	if note.Author == "" {
+		note.Author = "anonymous"
+	}
+	note.CreatedAt = time.Now().UTC()
+
+	err := ds.db.QueryRow(`
+	INSERT INTO session_notes (session_id, cdr_id, author, text, created_at) VALUES (?, ?, ?, ?, ?)
+	RETURNING id`,
+		note.SessionID, note.CDRID, note.Author, note.Text, note.CreatedAt,
+	).Scan(&note.ID)
+	if err != nil {
+		return fmt.Errorf("failed to save note: %w", err)
+	}
+	return nil
+}
+
+// GetSessionNotes returns a session's notes, oldest first. A CDR ID narrows them to that
+// CDR's notes.
+func (ds *DatabaseService) GetSessionNotes(sessionID, cdrID string) ([]SessionNote, error) {
+	query := `SELECT id, session_id, cdr_id, author, text, created_at FROM session_notes WHERE session_id = ?`
+	args := []interface{}{sessionID}
+	if cdrID != "" {
+		query += ` AND cdr_id = ?`
+		args = append(args, cdrID)
+	}
+	rows, err := ds.db.Query(query+` ORDER BY created_at, id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []SessionNote{}
+	for rows.Next() {
+		var note SessionNote
+		if err := rows.Scan(&note.ID, &note.SessionID, &note.CDRID, &note.Author, &note.Text, &note.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan note: %w", err)
+		}
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+// DeleteSessionNote removes one of a session's notes, reporting whether it existed
+func (ds *DatabaseService) DeleteSessionNote(sessionID string, id int64) (bool, error) {
+	result, err := ds.db.Exec(`DELETE FROM session_notes WHERE session_id = ? AND id = ?`, sessionID, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete note: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionNotes(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "notes.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.AddSessionNote(&SessionNote{SessionID: "s1", Text: "  "}); err == nil {
+		t.Error("empty note should be rejected")
+	}
+	sessionNote := &SessionNote{SessionID: "s1", Author: "dana", Text: "Carrier outage that morning"}
+	if err := db.AddSessionNote(sessionNote); err != nil {
+		t.Fatalf("AddSessionNote: %v", err)
+	}
+	if err := db.AddSessionNote(&SessionNote{SessionID: "s1", CDRID: "a", Text: "Disputed by the customer"}); err != nil {
+		t.Fatalf("AddSessionNote: %v", err)
+	}
+
+	notes, err := db.GetSessionNotes("s1", "")
+	if err != nil || len(notes) != 2 {
+		t.Fatalf("GetSessionNotes = %d notes, %v; want 2", len(notes), err)
+	}
+	if notes[0].Author != "dana" || notes[1].Author != "anonymous" || notes[1].CDRID != "a" {
+		t.Errorf("notes = %+v", notes)
+	}
+	if notes, _ := db.GetSessionNotes("s1", "a"); len(notes) != 1 {
+		t.Errorf("notes of CDR a = %d, want 1", len(notes))
+	}
+
+	report, err := db.GenerateSimpleReport("s1", "r", ReportCriteria{})
+	if err != nil {
+		t.Fatalf("GenerateSimpleReport: %v", err)
+	}
+	if len(report.Notes) != 2 {
+		t.Errorf("report has %d notes, want 2", len(report.Notes))
+	}
+
+	if deleted, err := db.DeleteSessionNote("s2", sessionNote.ID); err != nil || deleted {
+		t.Errorf("deleting through another session: %v, %v", deleted, err)
+	}
+	if deleted, err := db.DeleteSessionNote("s1", sessionNote.ID); err != nil || !deleted {
+		t.Errorf("DeleteSessionNote: %v, %v", deleted, err)
+	}
+}
@@ -0,0 +1,90 @@
+// services/session_reports.go
+// Session reports: summaries of a search session's CDRs (agent performance, missed
+// calls, queue statistics, transfer chains) that download as JSON or CSV and can be sent
+// through the alert notifiers after each run of a scheduled search
+
+package services
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"o-dan-go/models"
+	"sort"
+	"strings"
+)
+
+// Session report types
+const (
+	ReportAgents    = "agents"    // calls, talk time and missed calls per user
+	ReportMissed    = "missed"    // answered, abandoned and missed calls, with callers to call back
+	ReportQueues    = "queues"    // waits, agents and abandoned calls per call queue
+	ReportTransfers = "transfers" // hops, paths and time to answer of transferred calls
+)
+
+// SessionReport is a report over a session's CDRs
+type SessionReport interface {
+	Alert
+	WriteCSV(w io.Writer) error
+}
+
+// sessionReportBuilders build each report type from a session's CDRs
+var sessionReportBuilders = map[string]func(cdrs []models.FlexibleCDR) SessionReport{
+	ReportAgents:    func(cdrs []models.FlexibleCDR) SessionReport { return BuildAgentReport(cdrs) },
+	ReportMissed:    func(cdrs []models.FlexibleCDR) SessionReport { return BuildMissedCallReport(cdrs) },
+	ReportQueues:    func(cdrs []models.FlexibleCDR) SessionReport { return BuildQueueReport(cdrs) },
+	ReportTransfers: func(cdrs []models.FlexibleCDR) SessionReport { return BuildTransferReport(cdrs) },
+}
+
+// SessionReportTypes lists the report types, sorted
+func SessionReportTypes() []string {
+	types := make([]string, 0, len(sessionReportBuilders))
+	for reportType := range sessionReportBuilders {
+		types = append(types, reportType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// ValidateSessionReports checks a list of report types
+func ValidateSessionReports(reportTypes []string) error {
+	for _, reportType := range reportTypes {
+		if _, ok := sessionReportBuilders[reportType]; !ok {
+			return fmt.Errorf("unknown report %q: use one of %s", reportType, strings.Join(SessionReportTypes(), ", "))
+		}
+	}
+	return nil
+}
+
+// BuildSessionReport builds a report over a session's CDRs. Its links start with
+// linkBase, the server's public URL, or are relative when that is empty.
+func BuildSessionReport(reportType, sessionID string, cdrs []models.FlexibleCDR, linkBase string) (SessionReport, error) {
+	build, ok := sessionReportBuilders[reportType]
+	if !ok {
+		return nil, ValidateSessionReports([]string{reportType})
+	}
+	report := build(cdrs)
+	if linked, ok := report.(interface {
+		setSession(sessionID, reportURL string)
+	}); ok {
+		linked.setSession(sessionID, fmt.Sprintf("%s/api/v1/sessions/%s/reports/%s?format=csv",
+			strings.TrimRight(linkBase, "/"), url.PathEscape(sessionID), reportType))
+	}
+	return report, nil
+}
+
+// sessionReportBase is what every session report carries
+type sessionReportBase struct {
+	SessionID string `json:"session_id"`
+	ReportURL string `json:"report_url"` // the CSV download
+}
+
+// setSession names the session a report covers and where to download it
+func (b *sessionReportBase) setSession(sessionID, reportURL string) {
+	b.SessionID, b.ReportURL = sessionID, reportURL
+}
+
+// AlertLinks links the CSV download
+func (b *sessionReportBase) AlertLinks() []AlertLink {
+	return []AlertLink{{Label: "Download CSV", URL: b.ReportURL}}
+}
@@ -0,0 +1,181 @@
+// services/session_rerun.go
+// Session re-runs: repeats an earlier search's criteria against current data and compares
+// the two sessions CDR by CDR, so changes since the first run stand out
+
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// sessionComparisonIDLimit bounds how many new or missing CDR IDs a comparison lists
+const sessionComparisonIDLimit = 100
+
+// StoredSearchSession is a search kept in the database
+type StoredSearchSession struct {
+	SessionID string            `json:"session_id"`
+	Criteria  CDRSearchCriteria `json:"search_criteria"`
+	TotalCDRs int               `json:"total_cdrs"`
+	StartTime time.Time         `json:"start_time"`
+	RerunOf   string            `json:"rerun_of,omitempty"`
+}
+
+// EndpointComparison is how one endpoint's results changed between two sessions
+type EndpointComparison struct {
+	EndpointName  string `json:"endpoint_name"`
+	OriginalCount int    `json:"original_count"`
+	RerunCount    int    `json:"rerun_count"`
+	Change        int    `json:"change"`
+	OriginalError string `json:"original_error,omitempty"`
+	RerunError    string `json:"rerun_error,omitempty"`
+}
+
+// SessionComparison is what changed between a session and its re-run
+type SessionComparison struct {
+	OriginalSessionID  string               `json:"original_session_id"`
+	RerunSessionID     string               `json:"rerun_session_id"`
+	OriginalUniqueCDRs int                  `json:"original_unique_cdrs"`
+	RerunUniqueCDRs    int                  `json:"rerun_unique_cdrs"`
+	UniqueCDRsChange   int                  `json:"unique_cdrs_change"`
+	NewCount           int                  `json:"new_count"`       // in the re-run only
+	MissingCount       int                  `json:"missing_count"`   // in the original only
+	UnchangedCount     int                  `json:"unchanged_count"` // in both
+	NewCDRIDs          []string             `json:"new_cdr_ids"`     // first sessionComparisonIDLimit, sorted
+	MissingCDRIDs      []string             `json:"missing_cdr_ids"`
+	Endpoints          []EndpointComparison `json:"endpoints"`
+}
+
+// CompareSessions diffs two sessions' unique CDRs by ID and their per-endpoint counts
+func CompareSessions(original, rerun *CDRDiscoveryResult) *SessionComparison {
+	comparison := &SessionComparison{
+		OriginalSessionID:  original.SessionID,
+		RerunSessionID:     rerun.SessionID,
+		OriginalUniqueCDRs: original.UniqueCDRs,
+		RerunUniqueCDRs:    rerun.UniqueCDRs,
+		UniqueCDRsChange:   rerun.UniqueCDRs - original.UniqueCDRs,
+	}
+
+	before, after := sessionCDRIDs(original), sessionCDRIDs(rerun)
+	comparison.NewCDRIDs, comparison.MissingCDRIDs, comparison.UnchangedCount = compareCDRIDs(before, after)
+	comparison.NewCount, comparison.MissingCount = len(comparison.NewCDRIDs), len(comparison.MissingCDRIDs)
+	comparison.NewCDRIDs = firstSortedIDs(comparison.NewCDRIDs)
+	comparison.MissingCDRIDs = firstSortedIDs(comparison.MissingCDRIDs)
+	comparison.Endpoints = compareEndpoints(original.EndpointResults, rerun.EndpointResults)
+	return comparison
+}
+
+// compareCDRIDs splits two sets of CDR IDs into those only in after, those only in
+// before, and how many are in both
+func compareCDRIDs(before, after map[string]bool) ([]string, []string, int) {
+	added, missing, unchanged := []string{}, []string{}, 0
+	for id := range after {
+		if before[id] {
+			unchanged++
+		} else {
+			added = append(added, id)
+		}
+	}
+	for id := range before {
+		if !after[id] {
+			missing = append(missing, id)
+		}
+	}
+	return added, missing, unchanged
+}
+
+// compareEndpoints pairs up the endpoints of two runs, in the order the first queried
+// them, then any only the second queried
+func compareEndpoints(original, rerun []EndpointResult) []EndpointComparison {
+	endpoints := map[string]*EndpointComparison{}
+	order := []string{}
+	entry := func(name string) *EndpointComparison {
+		if _, exists := endpoints[name]; !exists {
+			endpoints[name] = &EndpointComparison{EndpointName: name}
+			order = append(order, name)
+		}
+		return endpoints[name]
+	}
+	for _, endpoint := range original {
+		e := entry(endpoint.EndpointName)
+		e.OriginalCount, e.OriginalError = endpoint.RecordCount, endpoint.Error
+	}
+	for _, endpoint := range rerun {
+		e := entry(endpoint.EndpointName)
+		e.RerunCount, e.RerunError = endpoint.RecordCount, endpoint.Error
+	}
+
+	comparisons := []EndpointComparison{}
+	for _, name := range order {
+		e := endpoints[name]
+		e.Change = e.RerunCount - e.OriginalCount
+		comparisons = append(comparisons, *e)
+	}
+	return comparisons
+}
+
+// sessionCDRIDs is the set of CDR IDs a session found
+func sessionCDRIDs(result *CDRDiscoveryResult) map[string]bool {
+	ids := make(map[string]bool, len(result.AllCDRs))
+	for i := range result.AllCDRs {
+		if id := result.AllCDRs[i].GetID(); id != "" {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// firstSortedIDs sorts IDs and keeps the first sessionComparisonIDLimit
+func firstSortedIDs(ids []string) []string {
+	sort.Strings(ids)
+	if len(ids) > sessionComparisonIDLimit {
+		ids = ids[:sessionComparisonIDLimit]
+	}
+	return ids
+}
+
+// Session returns a stored search, or nil when it wasn't stored
+func (ss *SearchStorage) Session(sessionID string) (*StoredSearchSession, error) {
+	if !ss.StoresSearches() {
+		return nil, nil
+	}
+	return ss.db.GetSearchSession(sessionID)
+}
+
+// LinkRerun records that a stored search re-ran another
+func (ss *SearchStorage) LinkRerun(sessionID, originalID string) error {
+	return ss.db.LinkSessionRerun(sessionID, originalID)
+}
+
+// GetSearchSession loads a stored search; it returns nil when there is none
+func (ds *DatabaseService) GetSearchSession(sessionID string) (*StoredSearchSession, error) {
+	session := &StoredSearchSession{SessionID: sessionID}
+	var criteria string
+	var rerunOf sql.NullString
+	err := ds.db.QueryRow(`
+	SELECT search_criteria, total_cdrs, start_time, rerun_of FROM search_sessions WHERE session_id = ?`, sessionID).
+		Scan(&criteria, &session.TotalCDRs, &session.StartTime, &rerunOf)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load search session: %w", err)
+	}
+	if err := json.Unmarshal([]byte(criteria), &session.Criteria); err != nil {
+		return nil, fmt.Errorf("failed to decode search criteria: %w", err)
+	}
+	session.RerunOf = rerunOf.String
+	return session, nil
+}
+
+// LinkSessionRerun marks a stored search as a re-run of another
+func (ds *DatabaseService) LinkSessionRerun(sessionID, originalID string) error {
+	if _, err := ds.db.Exec(`UPDATE search_sessions SET rerun_of = ? WHERE session_id = ?`, originalID, sessionID); err != nil {
+		return fmt.Errorf("failed to link session re-run: %w", err)
+	}
+	return nil
+}
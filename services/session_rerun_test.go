@@ -0,0 +1,73 @@
+package services
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func sessionWithCDRs(sessionID string, ids ...string) *CDRDiscoveryResult {
+	result := &CDRDiscoveryResult{SessionID: sessionID, UniqueCDRs: len(ids)}
+	for _, id := range ids {
+		result.AllCDRs = append(result.AllCDRs, models.FlexibleCDR{RawData: map[string]interface{}{"id": id}})
+	}
+	result.EndpointResults = []EndpointResult{{EndpointName: "global_cdrs", RecordCount: len(ids), Success: true}}
+	return result
+}
+
+func TestCompareSessions(t *testing.T) {
+	original := sessionWithCDRs("first", "a", "b", "c")
+	rerun := sessionWithCDRs("second", "b", "c", "d", "e")
+	rerun.EndpointResults = append(rerun.EndpointResults, EndpointResult{EndpointName: "domain_cdrs", Error: "HTTP 500"})
+
+	comparison := CompareSessions(original, rerun)
+	if comparison.NewCount != 2 || comparison.MissingCount != 1 || comparison.UnchangedCount != 2 || comparison.UniqueCDRsChange != 1 {
+		t.Errorf("counts = %+v", comparison)
+	}
+	if !reflect.DeepEqual(comparison.NewCDRIDs, []string{"d", "e"}) || !reflect.DeepEqual(comparison.MissingCDRIDs, []string{"a"}) {
+		t.Errorf("new = %v, missing = %v", comparison.NewCDRIDs, comparison.MissingCDRIDs)
+	}
+	want := []EndpointComparison{
+		{EndpointName: "global_cdrs", OriginalCount: 3, RerunCount: 4, Change: 1},
+		{EndpointName: "domain_cdrs", RerunError: "HTTP 500"},
+	}
+	if !reflect.DeepEqual(comparison.Endpoints, want) {
+		t.Errorf("endpoints = %+v, want %+v", comparison.Endpoints, want)
+	}
+}
+
+func TestStoredSessionRerun(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "rerun.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+	storage := NewSearchStorage(db, true)
+
+	criteria := CDRSearchCriteria{Domain: "acme", User: "1001", Limit: 50}
+	if _, err := storage.Store("first", criteria, nil); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if _, err := storage.Store("second", criteria, nil); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if err := storage.LinkRerun("second", "first"); err != nil {
+		t.Fatalf("LinkRerun: %v", err)
+	}
+
+	session, err := storage.Session("second")
+	if err != nil || session == nil {
+		t.Fatalf("Session = %v, %v", session, err)
+	}
+	if session.RerunOf != "first" || session.Criteria.Domain != "acme" || session.Criteria.Limit != 50 {
+		t.Errorf("session = %+v", session)
+	}
+	if session, err := storage.Session("missing"); session != nil || err != nil {
+		t.Errorf("missing session = %v, %v", session, err)
+	}
+	if session, _ := NewSearchStorage(db, false).Session("first"); session != nil {
+		t.Error("disabled storage returned a session")
+	}
+}
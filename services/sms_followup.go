@@ -0,0 +1,153 @@
+// services/sms_followup.go
+// "Press 9 to receive this by text": SMS follow-ups for IVR results, with caller consent
+
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// smsOfferDigit is the key callers press to get the last result by text
+const smsOfferDigit = "9"
+
+// SMSConsent records whether a caller agreed to receive text messages
+type SMSConsent struct {
+	PhoneNumber string    `json:"phone_number"`
+	OptedIn     bool      `json:"opted_in"`
+	Source      string    `json:"source"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SetSMSConsent records a caller's opt-in or opt-out
+func (ds *DatabaseService) SetSMSConsent(phoneNumber string, optedIn bool, source string) error {
+	query := `
+	INSERT OR REPLACE INTO sms_consent (phone_number, opted_in, source, updated_at)
+	VALUES (?, ?, ?, ?)`
+
+	if _, err := ds.db.Exec(query, phoneNumber, optedIn, source, time.Now()); err != nil {
+		return fmt.Errorf("failed to save SMS consent: %w", err)
+	}
+	return nil
+}
+
+// GetSMSConsent returns a caller's consent record, or nil if they never answered
+func (ds *DatabaseService) GetSMSConsent(phoneNumber string) (*SMSConsent, error) {
+	query := `SELECT phone_number, opted_in, COALESCE(source, ''), updated_at FROM sms_consent WHERE phone_number = ?`
+
+	var consent SMSConsent
+	err := ds.db.QueryRow(query, phoneNumber).Scan(&consent.PhoneNumber, &consent.OptedIn, &consent.Source, &consent.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &consent, nil
+}
+
+// SMSFollowUp offers callers the result they just heard as a text message.
+// Pressing 9 is the caller's opt-in; it is recorded before anything is sent.
+type SMSFollowUp struct {
+	provider SMSProvider
+	db       *DatabaseService
+	prompts  *Prompts
+}
+
+// NewSMSFollowUp creates the SMS follow-up helper; provider may be nil to disable it
+func NewSMSFollowUp(provider SMSProvider, db *DatabaseService, prompts *Prompts) *SMSFollowUp {
+	return &SMSFollowUp{
+		provider: provider,
+		db:       db,
+		prompts:  prompts,
+	}
+}
+
+// Available reports whether a text can be offered to this caller
+func (sf *SMSFollowUp) Available(callerNumber string) bool {
+	return sf != nil && sf.provider != nil && NormalizePhoneNumber(callerNumber) != ""
+}
+
+// Offer remembers text for the call and returns a Gather asking the caller to press 9.
+// If nothing is pressed, the actions after the Gather run as usual.
+func (sf *SMSFollowUp) Offer(session *sessions.Session, action, text string) Gather {
+	session.Values["sms_text"] = text
+
+	return Gather{
+		NumDigits: "1",
+		Action:    action,
+		Timeout:   "5",
+		Actions: []interface{}{
+			sf.prompts.Prompt("sms.offer", nil),
+		},
+		SpeechChoices: map[string][]string{
+			smsOfferDigit: {"text", "text me", "send it", "yes"},
+		},
+	}
+}
+
+// Pending reports whether the caller is answering an Offer
+func (sf *SMSFollowUp) Pending(session *sessions.Session) bool {
+	_, pending := session.Values["sms_text"].(string)
+	return pending
+}
+
+// Respond handles the answer to an Offer: sends the text if the caller pressed 9,
+// otherwise returns no actions so the app can just say goodbye
+func (sf *SMSFollowUp) Respond(session *sessions.Session, params IVRParams, source string) []interface{} {
+	text, _ := session.Values["sms_text"].(string)
+	delete(session.Values, "sms_text")
+
+	if params.Digits != smsOfferDigit {
+		return nil
+	}
+
+	if err := sf.Send(params.CallerNumber, text, source); err != nil {
+		log.Printf("[SMS] Follow-up to %s failed: %v", params.CallerNumber, err)
+		return []interface{}{sf.prompts.Prompt("sms.failed", nil)}
+	}
+	return []interface{}{sf.prompts.Prompt("sms.sent", nil)}
+}
+
+// Send records the caller's opt-in and texts them body
+func (sf *SMSFollowUp) Send(callerNumber, body, source string) error {
+	if !sf.Available(callerNumber) {
+		return fmt.Errorf("SMS is not available for %q", callerNumber)
+	}
+	number := NormalizePhoneNumber(callerNumber)
+
+	if err := sf.db.SetSMSConsent(number, true, source); err != nil {
+		return err
+	}
+	if err := sf.provider.Send("+1"+number, body); err != nil {
+		return err
+	}
+
+	log.Printf("[SMS] Sent %s follow-up via %s", source, sf.provider.Name())
+	return nil
+}
+
+// FlowActions exposes SMS follow-ups to declarative flows:
+//
+//	sms_available - fails if a text can't be sent to this caller (use on_error to skip the offer)
+//	send_sms      - texts the caller the sms_text variable (set it with a node's set: block)
+func (sf *SMSFollowUp) FlowActions() map[string]FlowAction {
+	return map[string]FlowAction{
+		"sms_available": func(ctx *FlowContext) error {
+			if !sf.Available(ctx.Params.CallerNumber) {
+				return fmt.Errorf("SMS follow-up not available: %w", ErrFlowCheckFailed)
+			}
+			return nil
+		},
+		"send_sms": func(ctx *FlowContext) error {
+			if ctx.Vars["sms_text"] == "" {
+				return fmt.Errorf("flow did not set sms_text")
+			}
+			return sf.Send(ctx.Params.CallerNumber, ctx.Vars["sms_text"], "ivr:"+ctx.Params.App)
+		},
+	}
+}
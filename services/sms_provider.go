@@ -0,0 +1,166 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SMSProvider sends text messages to callers
+type SMSProvider interface {
+	Name() string
+	Send(to, body string) error
+}
+
+// SMSSettings selects and configures the SMS provider
+type SMSSettings struct {
+	Provider   string // none, log, netsapiens, twilio
+	FromNumber string
+
+	// NetSapiens messaging API (shares the CDR API's base URL and token)
+	NetsapiensBaseURL string
+	NetsapiensToken   string
+	NetsapiensDomain  string
+	NetsapiensUser    string
+
+	TwilioAccountSID string
+	TwilioAuthToken  string
+}
+
+// NewSMSProvider creates the configured provider, or nil if SMS is disabled.
+// A provider without credentials falls back to logging messages instead of sending them.
+func NewSMSProvider(settings SMSSettings) SMSProvider {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	switch strings.ToLower(settings.Provider) {
+	case "", "none":
+		return nil
+	case "log":
+		return &LogSMSProvider{}
+	case "netsapiens":
+		if settings.NetsapiensToken == "" || settings.NetsapiensDomain == "" || settings.NetsapiensUser == "" || settings.FromNumber == "" {
+			log.Printf("[SMS] NetSapiens messaging is missing a token, domain, user or from number, logging messages instead")
+			return &LogSMSProvider{}
+		}
+		return &NetsapiensSMSProvider{
+			client:     client,
+			baseURL:    strings.TrimRight(settings.NetsapiensBaseURL, "/"),
+			token:      settings.NetsapiensToken,
+			domain:     settings.NetsapiensDomain,
+			user:       settings.NetsapiensUser,
+			fromNumber: settings.FromNumber,
+		}
+	case "twilio":
+		if settings.TwilioAccountSID == "" || settings.TwilioAuthToken == "" || settings.FromNumber == "" {
+			log.Printf("[SMS] Twilio is missing an account SID, auth token or from number, logging messages instead")
+			return &LogSMSProvider{}
+		}
+		return &TwilioSMSProvider{
+			client:     client,
+			accountSID: settings.TwilioAccountSID,
+			authToken:  settings.TwilioAuthToken,
+			fromNumber: settings.FromNumber,
+		}
+	default:
+		log.Printf("[SMS] Unknown provider %q, SMS disabled", settings.Provider)
+		return nil
+	}
+}
+
+// LogSMSProvider logs messages instead of sending them, for development
+type LogSMSProvider struct{}
+
+func (lp *LogSMSProvider) Name() string { return "log" }
+
+// Send logs the message
+func (lp *LogSMSProvider) Send(to, body string) error {
+	log.Printf("[SMS] To %s: %s", to, body)
+	return nil
+}
+
+// NetsapiensSMSProvider sends through the NetSapiens v2 messaging API as a domain user
+type NetsapiensSMSProvider struct {
+	client     *http.Client
+	baseURL    string
+	token      string
+	domain     string
+	user       string
+	fromNumber string
+}
+
+func (np *NetsapiensSMSProvider) Name() string { return "netsapiens" }
+
+// Send starts a new message session with the recipient
+func (np *NetsapiensSMSProvider) Send(to, body string) error {
+	sessionID := fmt.Sprintf("odango-%d", time.Now().UnixNano())
+	endpoint := fmt.Sprintf("%s/ns-api/v2/domains/%s/users/%s/messagesessions/%s/messages",
+		np.baseURL, url.PathEscape(np.domain), url.PathEscape(np.user), sessionID)
+
+	payload, err := json.Marshal(map[string]string{
+		"type":        "sms",
+		"message":     body,
+		"destination": to,
+		"from-number": np.fromNumber,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+np.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	return doSMSRequest(np.client, req)
+}
+
+// TwilioSMSProvider sends through the Twilio Messages API
+type TwilioSMSProvider struct {
+	client     *http.Client
+	accountSID string
+	authToken  string
+	fromNumber string
+}
+
+func (tp *TwilioSMSProvider) Name() string { return "twilio" }
+
+// Send creates a Twilio message
+func (tp *TwilioSMSProvider) Send(to, body string) error {
+	endpoint := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", url.PathEscape(tp.accountSID))
+
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", tp.fromNumber)
+	form.Set("Body", body)
+
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.SetBasicAuth(tp.accountSID, tp.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return doSMSRequest(tp.client, req)
+}
+
+func doSMSRequest(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send SMS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("SMS provider returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,475 @@
+// services/spam_scoring.go
+// Robocall and spam scoring: scores each inbound call in search results on short
+// durations, callers that call over and over, and caller IDs that can't be real, then
+// summarizes the scores per domain and per calling number
+
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"o-dan-go/models"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Reasons a call's spam score was raised, with what each adds to the score
+const (
+	SpamReasonShortCall        = "short_call"
+	SpamReasonRepeatCaller     = "repeat_caller"
+	SpamReasonManyDestinations = "many_destinations"
+	SpamReasonInvalidCallerID  = "invalid_caller_id"
+	SpamReasonWithheldCallerID = "withheld_caller_id"
+	SpamReasonNeighborSpoofing = "neighbor_spoofing"
+)
+
+var spamReasonWeights = map[string]int{
+	SpamReasonShortCall:        25,
+	SpamReasonRepeatCaller:     30,
+	SpamReasonManyDestinations: 15,
+	SpamReasonInvalidCallerID:  40,
+	SpamReasonWithheldCallerID: 20,
+	SpamReasonNeighborSpoofing: 20,
+}
+
+// spamShortCallSeconds is how short an answered call is when the caller hangs up on a greeting
+const spamShortCallSeconds = 10
+
+// spamManyDestinations is how many different numbers one caller reaches before it looks like a sweep
+const spamManyDestinations = 3
+
+// withheldCallerIDs are the caller ID values carriers send for blocked numbers
+var withheldCallerIDs = map[string]bool{
+	"": true, "anonymous": true, "restricted": true, "private": true,
+	"unavailable": true, "unknown": true, "blocked": true, "withheld": true,
+}
+
+// SpamSettings configures spam scoring
+type SpamSettings struct {
+	ScoreSearches bool // score every web search as it completes
+	RepeatCalls   int  // calls from one number in a search that make it a repeat caller
+	Threshold     int  // score at which a call counts as likely spam in reports
+}
+
+// SpamScore is the spam score of one inbound call
+type SpamScore struct {
+	CDRID     string    `json:"cdr_id"`
+	SessionID string    `json:"session_id"`
+	Domain    string    `json:"domain"`
+	Caller    string    `json:"caller"` // E.164 when the number can be normalized
+	Called    string    `json:"called,omitempty"`
+	Score     int       `json:"score"` // 0-100
+	Reasons   []string  `json:"reasons"`
+	StartedAt time.Time `json:"started_at"`
+	Duration  int       `json:"duration"` // seconds
+	ScoredAt  time.Time `json:"scored_at"`
+}
+
+// SpamNumberSummary totals the calls from one number
+type SpamNumberSummary struct {
+	Caller    string    `json:"caller"`
+	Calls     int       `json:"calls"`
+	SpamCalls int       `json:"spam_calls"`
+	AvgScore  float64   `json:"avg_score"`
+	MaxScore  int       `json:"max_score"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// SpamSummary is the spam report for a domain
+type SpamSummary struct {
+	Domain       string              `json:"domain"`
+	Since        time.Time           `json:"since"`
+	Threshold    int                 `json:"threshold"`
+	InboundCalls int                 `json:"inbound_calls"`
+	LikelySpam   int                 `json:"likely_spam"`
+	SpamPercent  float64             `json:"spam_percent"`
+	Reasons      map[string]int      `json:"reasons"` // how many likely spam calls had each reason
+	TopNumbers   []SpamNumberSummary `json:"top_numbers"`
+	GeneratedAt  time.Time           `json:"generated_at"`
+}
+
+// SpamNumberReport is the drill-down for one calling number
+type SpamNumberReport struct {
+	SpamNumberSummary
+	Domain     string         `json:"domain"`
+	Since      time.Time      `json:"since"`
+	Reasons    map[string]int `json:"reasons"`
+	CallerName string         `json:"caller_name,omitempty"` // from the CNAM cache
+	Carrier    *NumberInfo    `json:"carrier,omitempty"`     // from the enrichment cache
+	Scores     []SpamScore    `json:"scores"`
+}
+
+// SpamScorer scores inbound calls and stores the scores
+type SpamScorer struct {
+	db       *DatabaseService
+	settings SpamSettings
+}
+
+// NewSpamScorer creates a spam scorer
+func NewSpamScorer(db *DatabaseService, settings SpamSettings) *SpamScorer {
+	return &SpamScorer{
+		db:       db,
+		settings: settings,
+	}
+}
+
+// ScoresSearches reports whether searches are scored as they complete
+func (ss *SpamScorer) ScoresSearches() bool {
+	return ss != nil && ss.settings.ScoreSearches
+}
+
+// Threshold is the score at which calls count as likely spam
+func (ss *SpamScorer) Threshold() int {
+	return ss.settings.Threshold
+}
+
+// ScoreCDRs scores the inbound calls among cdrs and stores the scores, replacing earlier
+// scores of the same calls. Repeat callers are counted within cdrs.
+func (ss *SpamScorer) ScoreCDRs(sessionID string, cdrs []models.FlexibleCDR) ([]SpamScore, error) {
+	type inboundCall struct {
+		score       SpamScore
+		rawCallerID string
+	}
+	calls := []inboundCall{}
+	callsFrom := map[string]int{}
+	destinations := map[string]map[string]bool{}
+
+	for i := range cdrs {
+		cdr := &cdrs[i]
+		if cdr.GetCallDirection() != 1 {
+			continue
+		}
+		raw := strings.TrimSpace(cdrCallerNumber(cdr, "orig"))
+		caller := raw
+		if normalized, ok := normalizeE164(raw); ok {
+			caller = normalized
+		}
+		called := firstCDRString(*cdr, "call-orig-to-user", "call-dialed-number")
+		if called == "" {
+			called = cdrCallerNumber(cdr, "term")
+		}
+		started, _ := cdr.GetCallStartTime()
+
+		calls = append(calls, inboundCall{
+			score: SpamScore{
+				CDRID:     cdr.GetID(),
+				SessionID: sessionID,
+				Domain:    cdr.GetDomain(),
+				Caller:    caller,
+				Called:    called,
+				StartedAt: started,
+				Duration:  cdr.GetCallDuration(),
+			},
+			rawCallerID: raw,
+		})
+		callsFrom[caller]++
+		if destinations[caller] == nil {
+			destinations[caller] = map[string]bool{}
+		}
+		destinations[caller][nonDigits.ReplaceAllString(called, "")] = true
+	}
+
+	scores := make([]SpamScore, 0, len(calls))
+	now := time.Now().UTC()
+	for _, call := range calls {
+		score := call.score
+		reasons := []string{}
+		if score.Duration < spamShortCallSeconds {
+			reasons = append(reasons, SpamReasonShortCall)
+		}
+		switch {
+		case withheldCallerIDs[strings.ToLower(call.rawCallerID)]:
+			reasons = append(reasons, SpamReasonWithheldCallerID)
+		case !plausibleCallerID(call.rawCallerID):
+			reasons = append(reasons, SpamReasonInvalidCallerID)
+		case neighborSpoofed(call.rawCallerID, score.Called):
+			reasons = append(reasons, SpamReasonNeighborSpoofing)
+		}
+		if !withheldCallerIDs[strings.ToLower(call.rawCallerID)] {
+			if ss.settings.RepeatCalls > 0 && callsFrom[score.Caller] >= ss.settings.RepeatCalls {
+				reasons = append(reasons, SpamReasonRepeatCaller)
+			}
+			if len(destinations[score.Caller]) >= spamManyDestinations {
+				reasons = append(reasons, SpamReasonManyDestinations)
+			}
+		}
+
+		for _, reason := range reasons {
+			score.Score += spamReasonWeights[reason]
+		}
+		if score.Score > 100 {
+			score.Score = 100
+		}
+		score.Reasons = reasons
+		score.ScoredAt = now
+		scores = append(scores, score)
+	}
+
+	if err := ss.db.SaveSpamScores(scores); err != nil {
+		return nil, err
+	}
+	return scores, nil
+}
+
+// plausibleCallerID reports whether a caller ID could be a real number. North American
+// numbers must have a valid area code and exchange; numbers elsewhere must fit E.164.
+func plausibleCallerID(callerID string) bool {
+	digits := nonDigits.ReplaceAllString(callerID, "")
+	if digits == "" || strings.Count(digits, digits[:1]) == len(digits) {
+		return false
+	}
+	if isInternationalNumber(callerID) && !strings.HasPrefix(digits, "1") {
+		return len(digits) <= 15
+	}
+	if len(digits) == 11 && digits[0] == '1' {
+		digits = digits[1:]
+	}
+	switch len(digits) {
+	case 7:
+		return digits[0] >= '2'
+	case 10:
+		areaCode, exchange := digits[:3], digits[3:6]
+		switch {
+		case areaCode[0] < '2' || exchange[0] < '2':
+			return false
+		case areaCode[1:] == "11" || exchange[1:] == "11":
+			return false
+		case exchange == "555" && digits[6:8] == "01":
+			return false // 555-0100 through 555-0199 are reserved for fiction
+		case digits == "1234567890" || digits == "2345678901":
+			return false
+		}
+		return true
+	}
+	return len(digits) > 11 && len(digits) <= 15
+}
+
+// neighborSpoofed reports whether a caller ID shares the area code and exchange of the
+// number it called, a trick robocallers use to look local
+func neighborSpoofed(callerID, called string) bool {
+	caller, _ := normalizeE164(callerID)
+	dialed, _ := normalizeE164(called)
+	if !strings.HasPrefix(caller, "+1") || !strings.HasPrefix(dialed, "+1") || caller == dialed {
+		return false
+	}
+	return len(caller) == 12 && len(dialed) == 12 && caller[:8] == dialed[:8]
+}
+
+// SaveSpamScores stores scores, replacing earlier scores of the same calls
+func (ds *DatabaseService) SaveSpamScores(scores []SpamScore) error {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+	INSERT INTO spam_scores (cdr_id, session_id, domain, caller, called, score, reasons, call_start, duration, scored_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(cdr_id) DO UPDATE SET
+		session_id = excluded.session_id, score = excluded.score, reasons = excluded.reasons,
+		scored_at = excluded.scored_at`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare spam score insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, score := range scores {
+		reasons, err := json.Marshal(score.Reasons)
+		if err != nil {
+			return fmt.Errorf("failed to encode reasons: %w", err)
+		}
+		if _, err := stmt.Exec(score.CDRID, score.SessionID, score.Domain, score.Caller, score.Called,
+			score.Score, string(reasons), score.StartedAt.UTC(), score.Duration, score.ScoredAt.UTC()); err != nil {
+			return fmt.Errorf("failed to save spam score: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSpamScore returns a call's score, or nil if it wasn't scored
+func (ds *DatabaseService) GetSpamScore(cdrID string) (*SpamScore, error) {
+	score := &SpamScore{CDRID: cdrID}
+	var sessionID, domain, called sql.NullString
+	var reasons string
+	var startedAt sql.NullTime
+	var duration sql.NullInt64
+	err := ds.db.QueryRow(`
+	SELECT session_id, domain, caller, called, score, reasons, call_start, duration, scored_at
+	FROM spam_scores WHERE cdr_id = ?`, cdrID).Scan(&sessionID, &domain, &score.Caller, &called,
+		&score.Score, &reasons, &startedAt, &duration, &score.ScoredAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load spam score: %w", err)
+	}
+	if err := json.Unmarshal([]byte(reasons), &score.Reasons); err != nil {
+		return nil, fmt.Errorf("failed to decode spam score reasons: %w", err)
+	}
+	score.SessionID, score.Domain, score.Called = sessionID.String, domain.String, called.String
+	score.StartedAt, score.Duration = startedAt.Time, int(duration.Int64)
+	return score, nil
+}
+
+// GetSpamSummary reports the inbound calls scored for a domain since a time, with the
+// numbers that placed the most likely spam calls
+func (ds *DatabaseService) GetSpamSummary(domain string, since time.Time, threshold, limit int) (*SpamSummary, error) {
+	summary := &SpamSummary{
+		Domain:      domain,
+		Since:       since,
+		Threshold:   threshold,
+		Reasons:     map[string]int{},
+		TopNumbers:  []SpamNumberSummary{},
+		GeneratedAt: time.Now(),
+	}
+
+	err := ds.db.QueryRow(`
+	SELECT COUNT(*), COALESCE(SUM(score >= ?), 0)
+	FROM spam_scores WHERE domain = ? AND call_start >= ?`, threshold, domain, since.UTC(),
+	).Scan(&summary.InboundCalls, &summary.LikelySpam)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count spam scores: %w", err)
+	}
+	if summary.InboundCalls > 0 {
+		summary.SpamPercent = float64(summary.LikelySpam) * 100 / float64(summary.InboundCalls)
+	}
+
+	rows, err := ds.db.Query(`
+	SELECT reasons FROM spam_scores WHERE domain = ? AND call_start >= ? AND score >= ?`,
+		domain, since.UTC(), threshold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spam reasons: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var encoded string
+		if err := rows.Scan(&encoded); err != nil {
+			return nil, err
+		}
+		var reasons []string
+		json.Unmarshal([]byte(encoded), &reasons)
+		for _, reason := range reasons {
+			summary.Reasons[reason]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	numbers, err := ds.querySpamNumbers(`
+	SELECT caller, COUNT(*), SUM(score >= ?), AVG(score), MAX(score), MIN(call_start), MAX(call_start)
+	FROM spam_scores WHERE domain = ? AND call_start >= ?
+	GROUP BY caller HAVING SUM(score >= ?) > 0
+	ORDER BY SUM(score >= ?) DESC, AVG(score) DESC LIMIT ?`,
+		threshold, domain, since.UTC(), threshold, threshold, limit)
+	if err != nil {
+		return nil, err
+	}
+	summary.TopNumbers = numbers
+	return summary, nil
+}
+
+// GetSpamNumberReport returns every scored call from one number to a domain since a time,
+// or nil if there are none
+func (ds *DatabaseService) GetSpamNumberReport(domain, caller string, since time.Time, threshold int) (*SpamNumberReport, error) {
+	if normalized, ok := normalizeE164(caller); ok {
+		caller = normalized
+	}
+	numbers, err := ds.querySpamNumbers(`
+	SELECT caller, COUNT(*), SUM(score >= ?), AVG(score), MAX(score), MIN(call_start), MAX(call_start)
+	FROM spam_scores WHERE domain = ? AND caller = ? AND call_start >= ?
+	GROUP BY caller`, threshold, domain, caller, since.UTC())
+	if err != nil {
+		return nil, err
+	}
+	if len(numbers) == 0 {
+		return nil, nil
+	}
+
+	report := &SpamNumberReport{
+		SpamNumberSummary: numbers[0],
+		Domain:            domain,
+		Since:             since,
+		Reasons:           map[string]int{},
+		Scores:            []SpamScore{},
+	}
+	rows, err := ds.db.Query(`
+	SELECT cdr_id, COALESCE(session_id, ''), domain, caller, COALESCE(called, ''), score, reasons,
+		call_start, duration, scored_at
+	FROM spam_scores WHERE domain = ? AND caller = ? AND call_start >= ?
+	ORDER BY call_start DESC`, domain, caller, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get spam scores: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var score SpamScore
+		var reasons string
+		if err := rows.Scan(&score.CDRID, &score.SessionID, &score.Domain, &score.Caller, &score.Called,
+			&score.Score, &reasons, &score.StartedAt, &score.Duration, &score.ScoredAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(reasons), &score.Reasons)
+		for _, reason := range score.Reasons {
+			report.Reasons[reason]++
+		}
+		report.Scores = append(report.Scores, score)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Whatever enrichment already knows about the number; nothing is looked up here
+	if name, found, err := ds.GetCallerName(caller, time.Time{}); err == nil && found {
+		report.CallerName = name
+	}
+	if info, err := ds.GetNumberInfo(caller, time.Time{}); err == nil {
+		report.Carrier = info
+	}
+	return report, nil
+}
+
+// querySpamNumbers runs a per-caller summary query
+func (ds *DatabaseService) querySpamNumbers(query string, args ...interface{}) ([]SpamNumberSummary, error) {
+	rows, err := ds.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize spam scores: %w", err)
+	}
+	defer rows.Close()
+
+	numbers := []SpamNumberSummary{}
+	for rows.Next() {
+		var number SpamNumberSummary
+		var firstSeen, lastSeen string
+		if err := rows.Scan(&number.Caller, &number.Calls, &number.SpamCalls, &number.AvgScore,
+			&number.MaxScore, &firstSeen, &lastSeen); err != nil {
+			return nil, err
+		}
+		number.FirstSeen = parseStoredTime(firstSeen)
+		number.LastSeen = parseStoredTime(lastSeen)
+		numbers = append(numbers, number)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(numbers, func(i, j int) bool { return numbers[i].SpamCalls > numbers[j].SpamCalls })
+	return numbers, nil
+}
+
+// parseStoredTime parses a time as the SQLite driver stores it; aggregates like MIN()
+// return the stored text rather than a time
+func parseStoredTime(value string) time.Time {
+	for _, format := range sqlite3.SQLiteTimestampFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
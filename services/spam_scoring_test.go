@@ -0,0 +1,112 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestPlausibleCallerID(t *testing.T) {
+	tests := map[string]bool{
+		"4155551234":        true,
+		"+1 (415) 555-1234": true,
+		"+44 20 7946 0958":  true,
+		"0155551234":        false, // area codes don't start with 0 or 1
+		"4151551234":        false, // nor do exchanges
+		"9115551234":        false,
+		"4155550123":        false, // fictional
+		"7777777777":        false,
+		"1234567890":        false,
+		"12345":             false,
+	}
+	for callerID, want := range tests {
+		if got := plausibleCallerID(callerID); got != want {
+			t.Errorf("plausibleCallerID(%q) = %v, want %v", callerID, got, want)
+		}
+	}
+}
+
+func TestSpamScorer(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "spam.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	cdrs := []models.FlexibleCDR{}
+	call := func(caller interface{}, called string, seconds int) {
+		cdrs = append(cdrs, models.FlexibleCDR{RawData: map[string]interface{}{
+			"id":                          fmt.Sprintf("cdr-%d", len(cdrs)+1),
+			"domain":                      "acme",
+			"call-direction":              1,
+			"call-orig-caller-id":         caller,
+			"call-orig-to-user":           called,
+			"call-start-datetime":         now.Add(-time.Duration(len(cdrs)) * time.Minute).Format(time.RFC3339),
+			"call-total-duration-seconds": seconds,
+		}})
+	}
+	// A robocaller sweeping the company's numbers with short calls
+	for i := 0; i < 5; i++ {
+		call(float64(2125557147), fmt.Sprintf("415555%04d", 1000+i), 4)
+	}
+	// A customer, a caller with an impossible number and one spoofing a local number
+	call("6175552188", "4155551000", 240)
+	call("0000000000", "4155551000", 3)
+	call("4155551999", "4155551000", 45)
+	// Outbound calls aren't scored
+	cdrs = append(cdrs, models.FlexibleCDR{RawData: map[string]interface{}{"id": "out", "domain": "acme", "call-direction": 0}})
+
+	scorer := NewSpamScorer(db, SpamSettings{RepeatCalls: 5, Threshold: 50})
+	scores, err := scorer.ScoreCDRs("session-1", cdrs)
+	if err != nil || len(scores) != 8 {
+		t.Fatalf("ScoreCDRs = %d scores, %v; want the 8 inbound calls", len(scores), err)
+	}
+	byCDR := map[string]SpamScore{}
+	for _, score := range scores {
+		byCDR[score.CDRID] = score
+	}
+	if robo := byCDR["cdr-1"]; robo.Score != 70 || robo.Caller != "+12125557147" {
+		t.Errorf("robocaller scored %+v, want short_call + repeat_caller + many_destinations", robo)
+	}
+	if customer := byCDR["cdr-6"]; customer.Score != 0 {
+		t.Errorf("customer scored %+v", customer)
+	}
+	if invalid := byCDR["cdr-7"]; invalid.Score != 65 {
+		t.Errorf("invalid caller ID scored %+v", invalid)
+	}
+	if spoofed := byCDR["cdr-8"]; len(spoofed.Reasons) != 1 || spoofed.Reasons[0] != SpamReasonNeighborSpoofing {
+		t.Errorf("neighbor spoofing scored %+v", spoofed)
+	}
+
+	// Scoring again replaces the scores rather than adding to them
+	if _, err := scorer.ScoreCDRs("session-2", cdrs); err != nil {
+		t.Fatalf("ScoreCDRs again: %v", err)
+	}
+
+	summary, err := db.GetSpamSummary("acme", now.Add(-time.Hour), 50, 10)
+	if err != nil {
+		t.Fatalf("GetSpamSummary: %v", err)
+	}
+	if summary.InboundCalls != 8 || summary.LikelySpam != 6 || summary.Reasons[SpamReasonRepeatCaller] != 5 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if len(summary.TopNumbers) != 2 || summary.TopNumbers[0].Caller != "+12125557147" || summary.TopNumbers[0].SpamCalls != 5 ||
+		summary.TopNumbers[0].LastSeen.IsZero() {
+		t.Fatalf("unexpected top numbers: %+v", summary.TopNumbers)
+	}
+
+	report, err := db.GetSpamNumberReport("acme", "(212) 555-7147", now.Add(-time.Hour), 50)
+	if err != nil || report == nil {
+		t.Fatalf("GetSpamNumberReport = %+v, %v", report, err)
+	}
+	if report.Calls != 5 || len(report.Scores) != 5 || report.Scores[0].SessionID != "session-2" || report.MaxScore != 70 {
+		t.Fatalf("unexpected number report: %+v", report)
+	}
+	if report, _ := db.GetSpamNumberReport("acme", "3125550100", now.Add(-time.Hour), 50); report != nil {
+		t.Fatalf("report for a number that never called: %+v", report)
+	}
+}
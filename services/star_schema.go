@@ -0,0 +1,312 @@
+// services/star_schema.go
+// Star-schema export: turns stored CDRs into dimensional tables (dim_domain, dim_user,
+// dim_date and fact_calls) with integer keys, packaged as CSV files and the DDL to load
+// them, so BI tools can join calls to domains, users and calendars without parsing JSON
+
+package services
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"o-dan-go/models"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// starUnknownDomain is the dim_domain row of calls without a domain
+const starUnknownDomain = "unknown"
+
+// starSchemaDDL creates the tables the CSV files load into
+const starSchemaDDL = `CREATE TABLE dim_domain (
+	domain_key INTEGER PRIMARY KEY,
+	domain VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE dim_user (
+	user_key INTEGER PRIMARY KEY,
+	domain_key INTEGER NOT NULL REFERENCES dim_domain (domain_key),
+	user_name VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE dim_date (
+	date_key INTEGER PRIMARY KEY,  -- YYYYMMDD
+	full_date DATE NOT NULL,
+	year INTEGER NOT NULL,
+	quarter INTEGER NOT NULL,
+	month INTEGER NOT NULL,
+	month_name VARCHAR(9) NOT NULL,
+	day_of_month INTEGER NOT NULL,
+	day_of_week INTEGER NOT NULL,  -- 1 Monday to 7 Sunday
+	day_name VARCHAR(9) NOT NULL,
+	is_weekend BOOLEAN NOT NULL
+);
+
+CREATE TABLE fact_calls (
+	cdr_id VARCHAR(255) PRIMARY KEY,
+	date_key INTEGER NOT NULL REFERENCES dim_date (date_key),
+	domain_key INTEGER NOT NULL REFERENCES dim_domain (domain_key),
+	orig_user_key INTEGER REFERENCES dim_user (user_key),
+	term_user_key INTEGER REFERENCES dim_user (user_key),
+	start_time TIMESTAMP NOT NULL,  -- UTC
+	start_hour INTEGER NOT NULL,
+	direction VARCHAR(8) NOT NULL,  -- inbound, outbound or unknown
+	call_class VARCHAR(9) NOT NULL, -- answered, abandoned or missed
+	disposition VARCHAR(255),
+	orig_number VARCHAR(32),
+	term_number VARCHAR(32),
+	duration_seconds INTEGER NOT NULL
+);
+`
+
+// StarDomain is a row of dim_domain
+type StarDomain struct {
+	DomainKey int    `json:"domain_key"`
+	Domain    string `json:"domain"`
+}
+
+// StarUser is a row of dim_user; users are per domain
+type StarUser struct {
+	UserKey   int    `json:"user_key"`
+	DomainKey int    `json:"domain_key"`
+	UserName  string `json:"user_name"`
+}
+
+// StarDate is a row of dim_date
+type StarDate struct {
+	DateKey    int    `json:"date_key"`
+	FullDate   string `json:"full_date"`
+	Year       int    `json:"year"`
+	Quarter    int    `json:"quarter"`
+	Month      int    `json:"month"`
+	MonthName  string `json:"month_name"`
+	DayOfMonth int    `json:"day_of_month"`
+	DayOfWeek  int    `json:"day_of_week"`
+	DayName    string `json:"day_name"`
+	IsWeekend  bool   `json:"is_weekend"`
+}
+
+// StarCall is a row of fact_calls
+type StarCall struct {
+	CDRID           string `json:"cdr_id"`
+	DateKey         int    `json:"date_key"`
+	DomainKey       int    `json:"domain_key"`
+	OrigUserKey     *int   `json:"orig_user_key"`
+	TermUserKey     *int   `json:"term_user_key"`
+	StartTime       string `json:"start_time"`
+	StartHour       int    `json:"start_hour"`
+	Direction       string `json:"direction"`
+	CallClass       string `json:"call_class"`
+	Disposition     string `json:"disposition"`
+	OrigNumber      string `json:"orig_number"`
+	TermNumber      string `json:"term_number"`
+	DurationSeconds int    `json:"duration_seconds"`
+}
+
+// StarSchema is stored CDRs as dimension and fact tables
+type StarSchema struct {
+	Start   string       `json:"start"`
+	End     string       `json:"end"`
+	Domains []StarDomain `json:"dim_domain"`
+	Users   []StarUser   `json:"dim_user"`
+	Dates   []StarDate   `json:"dim_date"`
+	Calls   []StarCall   `json:"fact_calls"`
+}
+
+// BuildStarSchema builds the tables from the stored CDRs that started from start to end
+// inclusive, both dates in UTC, optionally for one domain
+func (ds *DatabaseService) BuildStarSchema(domain string, start, end time.Time) (*StarSchema, error) {
+	cdrs, err := ds.StoredCDRs(domain, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	return NewStarSchema(cdrs, start, end), nil
+}
+
+// NewStarSchema builds the tables from CDRs. dim_date has every day from start to end so
+// days without calls still show; keys of domains and users follow their sorted names so
+// the same CDRs always get the same keys.
+func NewStarSchema(cdrs []models.FlexibleCDR, start, end time.Time) *StarSchema {
+	schema := &StarSchema{
+		Start:   start.Format("2006-01-02"),
+		End:     end.Format("2006-01-02"),
+		Domains: []StarDomain{},
+		Users:   []StarUser{},
+		Dates:   []StarDate{},
+		Calls:   []StarCall{},
+	}
+
+	calls := NormalizeCDRs(cdrs)
+	for i := range calls {
+		if calls[i].Domain == "" {
+			calls[i].Domain = starUnknownDomain
+		}
+		// Stored CDRs carry the class they were given when they were stored
+		if class := cdrs[i].GetString("call_class"); class != "" {
+			calls[i].CallClass = class
+		}
+	}
+
+	// Domains, then each domain's users, in name order
+	users := map[string]map[string]bool{}
+	for _, call := range calls {
+		if users[call.Domain] == nil {
+			users[call.Domain] = map[string]bool{}
+		}
+		for _, user := range []string{call.OrigUser, call.TermUser} {
+			if user != "" {
+				users[call.Domain][user] = true
+			}
+		}
+	}
+	domains := make([]string, 0, len(users))
+	for domain := range users {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	domainKeys := map[string]int{}
+	userKeys := map[[2]string]int{}
+	for _, domain := range domains {
+		domainKeys[domain] = len(schema.Domains) + 1
+		schema.Domains = append(schema.Domains, StarDomain{DomainKey: domainKeys[domain], Domain: domain})
+
+		names := make([]string, 0, len(users[domain]))
+		for user := range users[domain] {
+			names = append(names, user)
+		}
+		sort.Strings(names)
+		for _, user := range names {
+			key := len(schema.Users) + 1
+			userKeys[[2]string{domain, user}] = key
+			schema.Users = append(schema.Users, StarUser{UserKey: key, DomainKey: domainKeys[domain], UserName: user})
+		}
+	}
+
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		schema.Dates = append(schema.Dates, newStarDate(day))
+	}
+
+	userKey := func(domain, user string) *int {
+		if key, exists := userKeys[[2]string{domain, user}]; exists {
+			return &key
+		}
+		return nil
+	}
+	for _, call := range calls {
+		started := start
+		if call.StartTime != nil {
+			started = *call.StartTime
+		}
+		schema.Calls = append(schema.Calls, StarCall{
+			CDRID:           call.ID,
+			DateKey:         starDateKey(started),
+			DomainKey:       domainKeys[call.Domain],
+			OrigUserKey:     userKey(call.Domain, call.OrigUser),
+			TermUserKey:     userKey(call.Domain, call.TermUser),
+			StartTime:       started.UTC().Format(time.RFC3339),
+			StartHour:       started.UTC().Hour(),
+			Direction:       call.Direction,
+			CallClass:       call.CallClass,
+			Disposition:     call.Disposition,
+			OrigNumber:      call.OrigNumber,
+			TermNumber:      call.TermNumber,
+			DurationSeconds: call.DurationSeconds,
+		})
+	}
+	return schema
+}
+
+// newStarDate describes a calendar day
+func newStarDate(day time.Time) StarDate {
+	weekday := int(day.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	return StarDate{
+		DateKey:    starDateKey(day),
+		FullDate:   day.Format("2006-01-02"),
+		Year:       day.Year(),
+		Quarter:    (int(day.Month())-1)/3 + 1,
+		Month:      int(day.Month()),
+		MonthName:  day.Month().String(),
+		DayOfMonth: day.Day(),
+		DayOfWeek:  weekday,
+		DayName:    day.Weekday().String(),
+		IsWeekend:  weekday >= 6,
+	}
+}
+
+// starDateKey is a day's dim_date key, YYYYMMDD in UTC
+func starDateKey(t time.Time) int {
+	t = t.UTC()
+	return t.Year()*10000 + int(t.Month())*100 + t.Day()
+}
+
+// WriteZip writes the tables as CSV files with headers, one per table, and schema.sql
+// with the DDL that creates them
+func (s *StarSchema) WriteZip(w io.Writer) error {
+	archive := zip.NewWriter(w)
+
+	tables := []struct {
+		name   string
+		header []string
+		rows   int
+		row    func(i int) []string
+	}{
+		{"dim_domain", []string{"domain_key", "domain"}, len(s.Domains), func(i int) []string {
+			return []string{strconv.Itoa(s.Domains[i].DomainKey), s.Domains[i].Domain}
+		}},
+		{"dim_user", []string{"user_key", "domain_key", "user_name"}, len(s.Users), func(i int) []string {
+			user := s.Users[i]
+			return []string{strconv.Itoa(user.UserKey), strconv.Itoa(user.DomainKey), user.UserName}
+		}},
+		{"dim_date", []string{"date_key", "full_date", "year", "quarter", "month", "month_name", "day_of_month", "day_of_week", "day_name", "is_weekend"}, len(s.Dates), func(i int) []string {
+			date := s.Dates[i]
+			return []string{strconv.Itoa(date.DateKey), date.FullDate, strconv.Itoa(date.Year), strconv.Itoa(date.Quarter),
+				strconv.Itoa(date.Month), date.MonthName, strconv.Itoa(date.DayOfMonth), strconv.Itoa(date.DayOfWeek),
+				date.DayName, strconv.FormatBool(date.IsWeekend)}
+		}},
+		{"fact_calls", []string{"cdr_id", "date_key", "domain_key", "orig_user_key", "term_user_key", "start_time", "start_hour", "direction", "call_class", "disposition", "orig_number", "term_number", "duration_seconds"}, len(s.Calls), func(i int) []string {
+			call := s.Calls[i]
+			return []string{call.CDRID, strconv.Itoa(call.DateKey), strconv.Itoa(call.DomainKey),
+				starOptionalKey(call.OrigUserKey), starOptionalKey(call.TermUserKey), call.StartTime,
+				strconv.Itoa(call.StartHour), call.Direction, call.CallClass, call.Disposition,
+				call.OrigNumber, call.TermNumber, strconv.Itoa(call.DurationSeconds)}
+		}},
+	}
+
+	file, err := archive.Create("schema.sql")
+	if err != nil {
+		return fmt.Errorf("failed to write schema.sql: %w", err)
+	}
+	if _, err := io.WriteString(file, starSchemaDDL); err != nil {
+		return fmt.Errorf("failed to write schema.sql: %w", err)
+	}
+	for _, table := range tables {
+		file, err := archive.Create(table.name + ".csv")
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", table.name, err)
+		}
+		writer := csv.NewWriter(file)
+		writer.Write(table.header)
+		for i := 0; i < table.rows; i++ {
+			writer.Write(table.row(i))
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", table.name, err)
+		}
+	}
+	return archive.Close()
+}
+
+// starOptionalKey writes a missing key as an empty CSV field, which loads as NULL
+func starOptionalKey(key *int) string {
+	if key == nil {
+		return ""
+	}
+	return strconv.Itoa(*key)
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestStarSchema(t *testing.T) {
+	cdrs := []models.FlexibleCDR{
+		{RawData: map[string]interface{}{"id": "a", "domain": "beta", "call-direction": "0", "call-orig-user": "200", "call-start-datetime": "2026-10-10T09:30:00Z", "call-total-duration-seconds": "60", "call_class": "answered"}},
+		{RawData: map[string]interface{}{"id": "b", "domain": "acme", "call-direction": "1", "call-term-user": "101", "call-start-datetime": "2026-10-11T23:00:00Z"}},
+		{RawData: map[string]interface{}{"id": "c", "domain": "acme", "call-orig-user": "100", "call-term-user": "101", "call-start-datetime": "2026-10-11T01:00:00Z"}},
+		{RawData: map[string]interface{}{"id": "d", "call-start-datetime": "2026-10-12T12:00:00Z"}},
+	}
+	schema := NewStarSchema(cdrs, time.Date(2026, 10, 9, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC))
+
+	domains := []StarDomain{{1, "acme"}, {2, "beta"}, {3, "unknown"}}
+	if len(schema.Domains) != len(domains) {
+		t.Fatalf("dim_domain = %+v, want %+v", schema.Domains, domains)
+	}
+	for i := range domains {
+		if schema.Domains[i] != domains[i] {
+			t.Errorf("dim_domain[%d] = %+v, want %+v", i, schema.Domains[i], domains[i])
+		}
+	}
+	users := []StarUser{{1, 1, "100"}, {2, 1, "101"}, {3, 2, "200"}}
+	if len(schema.Users) != len(users) {
+		t.Fatalf("dim_user = %+v, want %+v", schema.Users, users)
+	}
+	for i := range users {
+		if schema.Users[i] != users[i] {
+			t.Errorf("dim_user[%d] = %+v, want %+v", i, schema.Users[i], users[i])
+		}
+	}
+
+	// Every day of the range, with or without calls
+	if len(schema.Dates) != 4 || schema.Dates[0].DateKey != 20261009 || schema.Dates[1].DayName != "Saturday" || !schema.Dates[1].IsWeekend || schema.Dates[1].DayOfWeek != 6 || schema.Dates[3].Quarter != 4 {
+		t.Errorf("dim_date = %+v", schema.Dates)
+	}
+
+	a, c, d := schema.Calls[0], schema.Calls[2], schema.Calls[3]
+	if a.DateKey != 20261010 || a.DomainKey != 2 || *a.OrigUserKey != 3 || a.TermUserKey != nil || a.Direction != DirectionOutbound || a.StartHour != 9 || a.DurationSeconds != 60 || a.CallClass != "answered" {
+		t.Errorf("fact_calls a = %+v", a)
+	}
+	if *c.OrigUserKey != 1 || *c.TermUserKey != 2 || c.DateKey != 20261011 {
+		t.Errorf("fact_calls c = %+v", c)
+	}
+	if d.DomainKey != 3 || d.OrigUserKey != nil {
+		t.Errorf("fact_calls d = %+v", d)
+	}
+
+	var out bytes.Buffer
+	if err := schema.WriteZip(&out); err != nil {
+		t.Fatalf("WriteZip: %v", err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader: %v", err)
+	}
+	files := map[string]string{}
+	for _, file := range archive.File {
+		reader, _ := file.Open()
+		body, _ := io.ReadAll(reader)
+		files[file.Name] = string(body)
+	}
+	if !strings.Contains(files["schema.sql"], "CREATE TABLE fact_calls") || len(files) != 5 {
+		t.Errorf("zip has %d files, want schema.sql and four tables", len(files))
+	}
+	if !strings.HasPrefix(files["fact_calls.csv"], "cdr_id,date_key,domain_key,orig_user_key,term_user_key,") ||
+		!strings.Contains(files["fact_calls.csv"], "\na,20261010,2,3,,2026-10-10T09:30:00Z,9,outbound,answered,,,,60\n") {
+		t.Errorf("fact_calls.csv = %q", files["fact_calls.csv"])
+	}
+}
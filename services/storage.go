@@ -0,0 +1,53 @@
+// services/storage.go
+// Storage interfaces: what services need from the database, grouped by purpose, so a
+// service can run on another backend or on a fake in tests. DatabaseService is the
+// SQLite implementation of all of them.
+
+package services
+
+import (
+	"o-dan-go/models"
+	"time"
+)
+
+// SessionStore records the searches users have run
+type SessionStore interface {
+	StoreSearchSession(sessionID string, criteria CDRSearchCriteria, totalCDRs int) error
+	RecordSessionStorage(sessionID string, stats *BulkInsertStats) error
+	GetSearchSession(sessionID string) (*StoredSearchSession, error)
+	LinkSessionRerun(sessionID, originalID string) error
+}
+
+// CDRStore keeps summaries of the CDRs found by searches
+type CDRStore interface {
+	StoreCDRSummary(cdr *models.FlexibleCDR) error
+	StoreCDRSummaries(cdrs []models.FlexibleCDR) (*BulkInsertStats, error)
+	GetCDRSummaries(domain string, limit int) ([]CDRSummary, error)
+}
+
+// ReportStore generates reports from stored CDRs and keeps them
+type ReportStore interface {
+	GenerateSimpleReport(sessionID, reportName string, criteria ReportCriteria) (*SimpleReport, error)
+	StoreReport(report *SimpleReport, format string) error
+	GetStoredReports(sessionID string, limit int) ([]StoredReport, error)
+}
+
+// AnalyticsStore keeps call outcomes and answers the KPI, traffic and heatmap queries
+// over them
+type AnalyticsStore interface {
+	SaveCallOutcomes(outcomes []CallOutcome) error
+	GetTelephonyKPIs(query KPIQuery) ([]TelephonyKPIs, error)
+	GetAnsweredCalls(domain string, start, end time.Time) ([]CallOutcome, error)
+	GetCallOutcomes(domain string, start, end time.Time) ([]CallOutcome, error)
+}
+
+// Storage is a complete storage backend
+type Storage interface {
+	SessionStore
+	CDRStore
+	ReportStore
+	AnalyticsStore
+	Close() error
+}
+
+var _ Storage = (*DatabaseService)(nil)
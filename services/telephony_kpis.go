@@ -0,0 +1,282 @@
+// services/telephony_kpis.go
+// Telephony KPIs: records how each call in search results ended and computes the
+// answer-seizure ratio (ASR), average call duration (ACD) and network effectiveness
+// ratio (NER) per domain, trunk and day
+
+package services
+
+import (
+	"fmt"
+	"o-dan-go/models"
+	"strings"
+	"time"
+)
+
+// Dimensions KPIs can be grouped by
+const (
+	KPIGroupDomain = "domain"
+	KPIGroupTrunk  = "trunk"
+	KPIGroupDay    = "day"
+)
+
+// kpiDayFormat is how call days are stored and queried
+const kpiDayFormat = "2006-01-02"
+
+// KPISettings configures KPI recording
+type KPISettings struct {
+	RecordSearches bool   // record every web search as it completes
+	TrunkFields    string // comma-separated CDR fields naming the trunk or route a call used, first found wins
+}
+
+// CallOutcome is how one call ended, as counted by the KPIs
+type CallOutcome struct {
+	CDRID          string    `json:"cdr_id"`
+	Domain         string    `json:"domain"`
+	Trunk          string    `json:"trunk,omitempty"`
+	StartedAt      time.Time `json:"started_at"`
+	Answered       bool      `json:"answered"`
+	NetworkFailure bool      `json:"network_failure"` // unanswered for a reason other than the called party
+	TalkSeconds    int       `json:"talk_seconds"`
+}
+
+// KPIQuery selects the calls a KPI report covers and how they're grouped
+type KPIQuery struct {
+	Domain  string
+	Trunk   string
+	Start   time.Time // first day, inclusive
+	End     time.Time // last day, inclusive
+	GroupBy []string
+}
+
+// TelephonyKPIs are the KPIs of one group of calls. Ratios are percentages of seizures.
+type TelephonyKPIs struct {
+	Domain          string  `json:"domain,omitempty"`
+	Trunk           string  `json:"trunk,omitempty"`
+	Day             string  `json:"day,omitempty"`
+	Seizures        int     `json:"seizures"`
+	Answered        int     `json:"answered"`
+	NetworkFailures int     `json:"network_failures"`
+	TalkSeconds     int     `json:"talk_seconds"`
+	ASR             float64 `json:"asr"`
+	NER             float64 `json:"ner"`
+	ACD             float64 `json:"acd_seconds"`
+}
+
+// KPIReport is a KPI report, one row per group plus the totals
+type KPIReport struct {
+	Start       string          `json:"start"`
+	End         string          `json:"end"`
+	GroupBy     []string        `json:"group_by"`
+	Rows        []TelephonyKPIs `json:"rows"`
+	Totals      TelephonyKPIs   `json:"totals"`
+	GeneratedAt time.Time       `json:"generated_at"`
+}
+
+// KPIService records call outcomes and reports KPIs over them
+type KPIService struct {
+	db          AnalyticsStore
+	settings    KPISettings
+	trunkFields []string
+}
+
+// NewKPIService creates a KPI service
+func NewKPIService(db AnalyticsStore, settings KPISettings) *KPIService {
+	trunkFields := []string{}
+	for _, field := range strings.Split(settings.TrunkFields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			trunkFields = append(trunkFields, field)
+		}
+	}
+	return &KPIService{
+		db:          db,
+		settings:    settings,
+		trunkFields: trunkFields,
+	}
+}
+
+// RecordsSearches reports whether searches are recorded as they complete
+func (ks *KPIService) RecordsSearches() bool {
+	return ks != nil && ks.settings.RecordSearches
+}
+
+// RecordCDRs stores the outcome of each call among cdrs, replacing earlier outcomes of
+// the same calls. CDRs without an ID or start time are skipped.
+func (ks *KPIService) RecordCDRs(cdrs []models.FlexibleCDR) ([]CallOutcome, error) {
+	outcomes := make([]CallOutcome, 0, len(cdrs))
+	for _, cdr := range cdrs {
+		started, err := cdr.GetCallStartTime()
+		if cdr.GetID() == "" || err != nil {
+			continue
+		}
+		answered, talkSeconds := callAnswered(cdr)
+		outcomes = append(outcomes, CallOutcome{
+			CDRID:          cdr.GetID(),
+			Domain:         cdr.GetDomain(),
+			Trunk:          firstCDRString(cdr, ks.trunkFields...),
+			StartedAt:      started,
+			Answered:       answered,
+			NetworkFailure: !answered && IsFailedCall(cdr),
+			TalkSeconds:    talkSeconds,
+		})
+	}
+
+	if err := ks.db.SaveCallOutcomes(outcomes); err != nil {
+		return nil, err
+	}
+	return outcomes, nil
+}
+
+// Report computes the KPIs of the recorded calls matching query
+func (ks *KPIService) Report(query KPIQuery) (*KPIReport, error) {
+	for _, group := range query.GroupBy {
+		if group != KPIGroupDomain && group != KPIGroupTrunk && group != KPIGroupDay {
+			return nil, fmt.Errorf("unknown KPI grouping %q", group)
+		}
+	}
+
+	rows, err := ks.db.GetTelephonyKPIs(query)
+	if err != nil {
+		return nil, err
+	}
+	report := &KPIReport{
+		Start:       query.Start.Format(kpiDayFormat),
+		End:         query.End.Format(kpiDayFormat),
+		GroupBy:     query.GroupBy,
+		Rows:        rows,
+		GeneratedAt: time.Now().UTC(),
+	}
+	for _, row := range rows {
+		report.Totals.Seizures += row.Seizures
+		report.Totals.Answered += row.Answered
+		report.Totals.NetworkFailures += row.NetworkFailures
+		report.Totals.TalkSeconds += row.TalkSeconds
+	}
+	report.Totals.computeRatios()
+	return report, nil
+}
+
+// computeRatios fills in ASR, NER and ACD from the counts
+func (k *TelephonyKPIs) computeRatios() {
+	if k.Seizures > 0 {
+		k.ASR = roundTo(100*float64(k.Answered)/float64(k.Seizures), 1)
+		k.NER = roundTo(100*float64(k.Seizures-k.NetworkFailures)/float64(k.Seizures), 1)
+	}
+	if k.Answered > 0 {
+		k.ACD = roundTo(float64(k.TalkSeconds)/float64(k.Answered), 1)
+	}
+}
+
+// callAnswered reports whether a call was answered and how long it was connected. When
+// the CDR has an answer time the ringing before it isn't counted.
+func callAnswered(cdr models.FlexibleCDR) (bool, int) {
+	duration := cdr.GetCallDuration()
+	if ringing := timeToVoicemail(cdr); ringing > 0 {
+		if talk := duration - ringing; talk > 0 {
+			return true, talk
+		}
+		return true, 0
+	}
+	return duration > 0, duration
+}
+
+// roundTo rounds a value to a number of decimal places
+func roundTo(value float64, places int) float64 {
+	scale := 1.0
+	for i := 0; i < places; i++ {
+		scale *= 10
+	}
+	return float64(int64(value*scale+0.5)) / scale
+}
+
+// SaveCallOutcomes stores call outcomes, replacing earlier outcomes of the same calls
+func (ds *DatabaseService) SaveCallOutcomes(outcomes []CallOutcome) error {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	insert, err := ds.prepared(`
+	INSERT INTO call_outcomes (cdr_id, domain, trunk, call_day, call_start, answered, network_failure, talk_seconds)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(cdr_id) DO UPDATE SET
+		domain = excluded.domain, trunk = excluded.trunk, call_day = excluded.call_day,
+		call_start = excluded.call_start, answered = excluded.answered,
+		network_failure = excluded.network_failure, talk_seconds = excluded.talk_seconds,
+		recorded_at = CURRENT_TIMESTAMP`)
+	if err != nil {
+		return err
+	}
+	stmt := tx.Stmt(insert)
+	defer stmt.Close()
+
+	for _, outcome := range outcomes {
+		started := outcome.StartedAt.UTC()
+		if _, err := stmt.Exec(outcome.CDRID, outcome.Domain, outcome.Trunk, started.Format(kpiDayFormat), started,
+			outcome.Answered, outcome.NetworkFailure, outcome.TalkSeconds); err != nil {
+			return fmt.Errorf("failed to save call outcome: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetTelephonyKPIs counts the recorded calls matching query per group, ordered by group
+func (ds *DatabaseService) GetTelephonyKPIs(query KPIQuery) ([]TelephonyKPIs, error) {
+	// Columns not grouped by are selected empty so every row scans the same way
+	columns := map[string]string{KPIGroupDomain: "domain", KPIGroupTrunk: "trunk", KPIGroupDay: "call_day"}
+	selected, groups := []string{}, []string{}
+	for _, group := range []string{KPIGroupDomain, KPIGroupTrunk, KPIGroupDay} {
+		grouped := false
+		for _, g := range query.GroupBy {
+			grouped = grouped || g == group
+		}
+		if grouped {
+			selected = append(selected, "COALESCE("+columns[group]+", '')")
+			groups = append(groups, columns[group])
+		} else {
+			selected = append(selected, "''")
+		}
+	}
+
+	sqlQuery := `
+	SELECT ` + strings.Join(selected, ", ") + `, COUNT(*), COALESCE(SUM(answered), 0), COALESCE(SUM(network_failure), 0),
+		COALESCE(SUM(CASE WHEN answered THEN talk_seconds ELSE 0 END), 0)
+	FROM call_outcomes WHERE call_day >= ? AND call_day <= ?`
+	args := []interface{}{query.Start.Format(kpiDayFormat), query.End.Format(kpiDayFormat)}
+	if query.Domain != "" {
+		sqlQuery += " AND domain = ?"
+		args = append(args, query.Domain)
+	}
+	if query.Trunk != "" {
+		sqlQuery += " AND trunk = ?"
+		args = append(args, query.Trunk)
+	}
+	if len(groups) > 0 {
+		sqlQuery += " GROUP BY " + strings.Join(groups, ", ") + " ORDER BY " + strings.Join(groups, ", ")
+	}
+
+	stmt, err := ds.prepared(sqlQuery)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query call outcomes: %w", err)
+	}
+	defer rows.Close()
+
+	kpis := []TelephonyKPIs{}
+	for rows.Next() {
+		var row TelephonyKPIs
+		if err := rows.Scan(&row.Domain, &row.Trunk, &row.Day, &row.Seizures, &row.Answered,
+			&row.NetworkFailures, &row.TalkSeconds); err != nil {
+			return nil, fmt.Errorf("failed to scan call outcomes: %w", err)
+		}
+		if row.Seizures == 0 {
+			continue // without grouping, no matching calls still returns a row
+		}
+		row.computeRatios()
+		kpis = append(kpis, row)
+	}
+	return kpis, rows.Err()
+}
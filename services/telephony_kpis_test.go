@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestKPIService(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "kpis.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	cdrs := []models.FlexibleCDR{}
+	call := func(domain, trunk, start string, seconds int, fields map[string]interface{}) {
+		raw := map[string]interface{}{
+			"id":                          fmt.Sprintf("cdr-%d", len(cdrs)+1),
+			"domain":                      domain,
+			"call-route":                  trunk,
+			"call-start-datetime":         start,
+			"call-total-duration-seconds": seconds,
+		}
+		for field, value := range fields {
+			raw[field] = value
+		}
+		cdrs = append(cdrs, models.FlexibleCDR{RawData: raw})
+	}
+	// acme on the 13th: two answered calls (20s of ringing on the first), one busy and
+	// one that failed in the network
+	call("acme", "carrier-a", "2026-10-13T10:00:00Z", 80, map[string]interface{}{"call-answer-datetime": "2026-10-13T10:00:20Z"})
+	call("acme", "carrier-a", "2026-10-13T11:00:00Z", 120, nil)
+	call("acme", "carrier-b", "2026-10-13T12:00:00Z", 0, map[string]interface{}{"call-disconnect-reason-text": "User Busy"})
+	call("acme", "carrier-b", "2026-10-13T13:00:00Z", 0, map[string]interface{}{"call-disconnect-reason-text": "Service Unavailable"})
+	// acme on the 14th and another domain
+	call("acme", "carrier-a", "2026-10-14T09:00:00Z", 30, nil)
+	call("globex", "carrier-a", "2026-10-13T09:00:00Z", 0, map[string]interface{}{"call-disconnect-reason-text": "Request Timeout"})
+	// Without a start time a call can't be placed on a day
+	cdrs = append(cdrs, models.FlexibleCDR{RawData: map[string]interface{}{"id": "no-start", "domain": "acme"}})
+
+	kpis := NewKPIService(db, KPISettings{TrunkFields: "call-term-route, call-route"})
+	outcomes, err := kpis.RecordCDRs(cdrs)
+	if err != nil || len(outcomes) != 6 {
+		t.Fatalf("RecordCDRs = %d outcomes, %v; want 6", len(outcomes), err)
+	}
+	if outcomes[0].TalkSeconds != 60 || outcomes[0].Trunk != "carrier-a" {
+		t.Errorf("unexpected outcome: %+v", outcomes[0])
+	}
+	// Recording the same calls again replaces them
+	if _, err := kpis.RecordCDRs(cdrs); err != nil {
+		t.Fatalf("RecordCDRs again: %v", err)
+	}
+
+	day := func(s string) time.Time {
+		parsed, _ := time.Parse("2006-01-02", s)
+		return parsed
+	}
+	report, err := kpis.Report(KPIQuery{Start: day("2026-10-13"), End: day("2026-10-14"), GroupBy: []string{KPIGroupDomain, KPIGroupDay}})
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	if len(report.Rows) != 3 {
+		t.Fatalf("Report rows = %+v, want acme on two days and globex", report.Rows)
+	}
+	acme := report.Rows[0]
+	if acme.Domain != "acme" || acme.Day != "2026-10-13" || acme.Seizures != 4 || acme.ASR != 50 || acme.NER != 75 || acme.ACD != 90 {
+		t.Errorf("acme on the 13th = %+v", acme)
+	}
+	if report.Totals.Seizures != 6 || report.Totals.NetworkFailures != 2 || report.Totals.ASR != 50 {
+		t.Errorf("totals = %+v", report.Totals)
+	}
+
+	byTrunk, err := kpis.Report(KPIQuery{Domain: "acme", Start: day("2026-10-13"), End: day("2026-10-13"), GroupBy: []string{KPIGroupTrunk}})
+	if err != nil || len(byTrunk.Rows) != 2 || byTrunk.Rows[1].Trunk != "carrier-b" || byTrunk.Rows[1].ASR != 0 || byTrunk.Rows[1].NER != 50 {
+		t.Fatalf("by trunk = %+v, %v", byTrunk, err)
+	}
+
+	empty, err := kpis.Report(KPIQuery{Start: day("2026-01-01"), End: day("2026-01-02")})
+	if err != nil || len(empty.Rows) != 0 || empty.Totals.Seizures != 0 {
+		t.Fatalf("empty report = %+v, %v", empty, err)
+	}
+	if _, err := kpis.Report(KPIQuery{GroupBy: []string{"site"}}); err == nil {
+		t.Fatal("Report accepted an unknown grouping")
+	}
+}
+
+// fakeAnalyticsStore serves canned KPI rows without a database
+type fakeAnalyticsStore struct {
+	saved []CallOutcome
+	rows  []TelephonyKPIs
+}
+
+func (f *fakeAnalyticsStore) SaveCallOutcomes(outcomes []CallOutcome) error {
+	f.saved = append(f.saved, outcomes...)
+	return nil
+}
+
+func (f *fakeAnalyticsStore) GetTelephonyKPIs(query KPIQuery) ([]TelephonyKPIs, error) {
+	return f.rows, nil
+}
+
+func (f *fakeAnalyticsStore) GetAnsweredCalls(domain string, start, end time.Time) ([]CallOutcome, error) {
+	return nil, nil
+}
+
+func (f *fakeAnalyticsStore) GetCallOutcomes(domain string, start, end time.Time) ([]CallOutcome, error) {
+	return f.saved, nil
+}
+
+func TestKPIServiceTotals(t *testing.T) {
+	store := &fakeAnalyticsStore{rows: []TelephonyKPIs{
+		{Domain: "acme", Seizures: 3, Answered: 2, NetworkFailures: 1, TalkSeconds: 100},
+		{Domain: "globex", Seizures: 1, Answered: 1, TalkSeconds: 50},
+	}}
+	kpis := NewKPIService(store, KPISettings{})
+
+	report, err := kpis.Report(KPIQuery{GroupBy: []string{KPIGroupDomain}})
+	if err != nil {
+		t.Fatalf("Report: %v", err)
+	}
+	if totals := report.Totals; totals.Seizures != 4 || totals.ASR != 75 || totals.NER != 75 || totals.ACD != 50 {
+		t.Errorf("totals = %+v", totals)
+	}
+
+	if _, err := kpis.RecordCDRs([]models.FlexibleCDR{{RawData: map[string]interface{}{
+		"id": "cdr-1", "call-start-datetime": "2026-10-13T10:00:00Z", "call-total-duration-seconds": 30,
+	}}}); err != nil || len(store.saved) != 1 || !store.saved[0].Answered {
+		t.Fatalf("RecordCDRs saved %+v, %v", store.saved, err)
+	}
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// openAITranscriptionURL is the hosted Whisper API; local Whisper servers expose the same endpoint
+const openAITranscriptionURL = "https://api.openai.com/v1/audio/transcriptions"
+
+// deepgramListenURL is Deepgram's pre-recorded audio API
+const deepgramListenURL = "https://api.deepgram.com/v1/listen"
+
+// Transcriber turns recorded call audio into text
+type Transcriber interface {
+	Name() string
+	Transcribe(audio []byte, contentType, filename string) (*TranscriptionResult, error)
+}
+
+// TranscriptionResult is the text of a recording
+type TranscriptionResult struct {
+	Text     string
+	Language string
+}
+
+// TranscriptionSettings selects and configures the speech-to-text backend
+type TranscriptionSettings struct {
+	Backend  string // none, whisper, deepgram
+	URL      string // endpoint override, e.g. a local Whisper server
+	APIKey   string
+	Model    string
+	Language string // "" lets the backend detect it
+}
+
+// NewTranscriber creates the configured backend, or nil if transcription is disabled
+func NewTranscriber(settings TranscriptionSettings) Transcriber {
+	client := &http.Client{Timeout: 10 * time.Minute}
+
+	switch strings.ToLower(settings.Backend) {
+	case "", "none":
+		return nil
+	case "whisper":
+		endpoint := settings.URL
+		if endpoint == "" {
+			if settings.APIKey == "" {
+				log.Printf("[Transcription] The Whisper API needs an API key (or a local server URL), transcription disabled")
+				return nil
+			}
+			endpoint = openAITranscriptionURL
+		}
+		model := settings.Model
+		if model == "" {
+			model = "whisper-1"
+		}
+		return &WhisperTranscriber{
+			client:   client,
+			endpoint: endpoint,
+			apiKey:   settings.APIKey,
+			model:    model,
+			language: settings.Language,
+		}
+	case "deepgram":
+		if settings.APIKey == "" {
+			log.Printf("[Transcription] Deepgram is missing an API key, transcription disabled")
+			return nil
+		}
+		endpoint := settings.URL
+		if endpoint == "" {
+			endpoint = deepgramListenURL
+		}
+		model := settings.Model
+		if model == "" {
+			model = "nova-2"
+		}
+		return &DeepgramTranscriber{
+			client:   client,
+			endpoint: endpoint,
+			apiKey:   settings.APIKey,
+			model:    model,
+			language: settings.Language,
+		}
+	default:
+		log.Printf("[Transcription] Unknown backend %q, transcription disabled", settings.Backend)
+		return nil
+	}
+}
+
+// WhisperTranscriber uses the OpenAI audio transcription API, hosted or from a local
+// Whisper server that implements it
+type WhisperTranscriber struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string // optional for local servers
+	model    string
+	language string
+}
+
+func (wt *WhisperTranscriber) Name() string { return "whisper" }
+
+// Transcribe uploads the recording as multipart form data
+func (wt *WhisperTranscriber) Transcribe(audio []byte, contentType, filename string) (*TranscriptionResult, error) {
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	part.Write(audio)
+	writer.WriteField("model", wt.model)
+	writer.WriteField("response_format", "json")
+	if wt.language != "" {
+		writer.WriteField("language", wt.language)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", wt.endpoint, &form)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if wt.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+wt.apiKey)
+	}
+
+	var result struct {
+		Text     string `json:"text"`
+		Language string `json:"language"`
+	}
+	if err := doTranscriptionRequest(wt.client, req, &result); err != nil {
+		return nil, err
+	}
+	language := result.Language
+	if language == "" {
+		language = wt.language
+	}
+	return &TranscriptionResult{Text: strings.TrimSpace(result.Text), Language: language}, nil
+}
+
+// DeepgramTranscriber uses Deepgram's pre-recorded audio API
+type DeepgramTranscriber struct {
+	client   *http.Client
+	endpoint string
+	apiKey   string
+	model    string
+	language string
+}
+
+func (dt *DeepgramTranscriber) Name() string { return "deepgram" }
+
+// Transcribe posts the raw recording
+func (dt *DeepgramTranscriber) Transcribe(audio []byte, contentType, filename string) (*TranscriptionResult, error) {
+	params := url.Values{}
+	params.Set("model", dt.model)
+	params.Set("punctuate", "true")
+	params.Set("smart_format", "true")
+	if dt.language != "" {
+		params.Set("language", dt.language)
+	} else {
+		params.Set("detect_language", "true")
+	}
+
+	req, err := http.NewRequest("POST", dt.endpoint+"?"+params.Encode(), bytes.NewReader(audio))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType == "" {
+		contentType = "audio/wav"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Token "+dt.apiKey)
+
+	var result struct {
+		Results struct {
+			Channels []struct {
+				DetectedLanguage string `json:"detected_language"`
+				Alternatives     []struct {
+					Transcript string `json:"transcript"`
+				} `json:"alternatives"`
+			} `json:"channels"`
+		} `json:"results"`
+	}
+	if err := doTranscriptionRequest(dt.client, req, &result); err != nil {
+		return nil, err
+	}
+
+	transcript := &TranscriptionResult{Language: dt.language}
+	if len(result.Results.Channels) > 0 {
+		channel := result.Results.Channels[0]
+		if len(channel.Alternatives) > 0 {
+			transcript.Text = strings.TrimSpace(channel.Alternatives[0].Transcript)
+		}
+		if channel.DetectedLanguage != "" {
+			transcript.Language = channel.DetectedLanguage
+		}
+	}
+	return transcript, nil
+}
+
+// doTranscriptionRequest sends a request to a speech-to-text API and decodes its JSON response
+func doTranscriptionRequest(client *http.Client, req *http.Request, out interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach %s: %w", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned HTTP %d: %s", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode transcription: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+// services/transcription.go
+// Speech-to-text for archived call recordings: a background worker sends each recording
+// to the configured transcriber and stores the transcript against its CDR, indexed for
+// full-text search
+
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+)
+
+// transcriptionBatchSize is how many recordings are transcribed per pass
+const transcriptionBatchSize = 10
+
+// transcriptionMaxAttempts is how many times a recording is tried before it is left failed
+const transcriptionMaxAttempts = 3
+
+// Transcript statuses
+const (
+	TranscriptCompleted = "completed"
+	TranscriptFailed    = "failed"
+)
+
+// ErrInvalidTranscriptQuery is returned for search text the full-text index can't parse
+var ErrInvalidTranscriptQuery = errors.New("invalid search query")
+
+// Transcript is the text of one archived recording
+type Transcript struct {
+	ID                  int64     `json:"id"`
+	ArchivedRecordingID int64     `json:"archived_recording_id"`
+	CDRID               string    `json:"cdr_id"`
+	RecordingID         string    `json:"recording_id"`
+	Domain              string    `json:"domain"`
+	Backend             string    `json:"backend"`
+	Language            string    `json:"language,omitempty"`
+	Text                string    `json:"text"`
+	Status              string    `json:"status"`
+	Error               string    `json:"error,omitempty"`
+	Attempts            int       `json:"attempts"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+
+	Sentiment *SentimentScore `json:"sentiment,omitempty"`
+}
+
+// TranscriptMatch is a transcript found by a search, with the matching text highlighted
+type TranscriptMatch struct {
+	TranscriptID        int64     `json:"transcript_id"`
+	ArchivedRecordingID int64     `json:"archived_recording_id"`
+	CDRID               string    `json:"cdr_id"`
+	Domain              string    `json:"domain"`
+	Snippet             string    `json:"snippet"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// TranscriptionWorker transcribes archived recordings in the background, scanning new
+// transcripts for keywords and scoring their sentiment when those are set
+type TranscriptionWorker struct {
+	db          *DatabaseService
+	storage     ArchiveStorage
+	transcriber Transcriber
+	analyzer    SentimentAnalyzer
+	spotter     *KeywordSpotter
+	interval    time.Duration
+}
+
+// NewTranscriptionWorker creates a worker; it does nothing unless both archive storage
+// and a transcriber are configured
+func NewTranscriptionWorker(db *DatabaseService, storage ArchiveStorage, transcriber Transcriber, interval time.Duration) *TranscriptionWorker {
+	return &TranscriptionWorker{
+		db:          db,
+		storage:     storage,
+		transcriber: transcriber,
+		interval:    interval,
+	}
+}
+
+// Enabled reports whether recordings are being transcribed
+func (tw *TranscriptionWorker) Enabled() bool {
+	return tw.storage != nil && tw.transcriber != nil
+}
+
+// Start transcribes new recordings, and scores new transcripts, in the background
+func (tw *TranscriptionWorker) Start() {
+	if !tw.Enabled() && tw.analyzer == nil {
+		return
+	}
+	if tw.Enabled() {
+		log.Printf("[Transcription] Transcribing archived recordings with %s every %s", tw.transcriber.Name(), tw.interval)
+	}
+
+	go func() {
+		for {
+			if tw.Enabled() {
+				if done, err := tw.ProcessPending(); err != nil {
+					log.Printf("[Transcription] Failed to process recordings: %v", err)
+				} else if done > 0 {
+					log.Printf("[Transcription] Transcribed %d recordings", done)
+				}
+			}
+			if _, err := tw.AnalyzePending(); err != nil {
+				log.Printf("[Sentiment] %v", err)
+			}
+			time.Sleep(tw.interval)
+		}
+	}()
+}
+
+// ProcessPending transcribes a batch of recordings that have no transcript yet, or
+// failed fewer than transcriptionMaxAttempts times, and returns how many succeeded
+func (tw *TranscriptionWorker) ProcessPending() (int, error) {
+	recordings, err := tw.db.GetUntranscribedRecordings(transcriptionBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, recording := range recordings {
+		transcript := &Transcript{
+			ArchivedRecordingID: recording.ID,
+			CDRID:               recording.CDRID,
+			RecordingID:         recording.RecordingID,
+			Domain:              recording.Domain,
+			Backend:             tw.transcriber.Name(),
+			Status:              TranscriptCompleted,
+		}
+		if err := tw.transcribe(recording, transcript); err != nil {
+			log.Printf("[Transcription] Failed to transcribe %s for CDR %s: %v", recording.Key, recording.CDRID, err)
+			transcript.Status = TranscriptFailed
+			transcript.Error = err.Error()
+		}
+		if err := tw.db.SaveTranscript(transcript); err != nil {
+			return done, err
+		}
+		if transcript.Status != TranscriptCompleted {
+			continue
+		}
+		done++
+		if tw.spotter != nil {
+			if _, err := tw.spotter.Scan(*transcript); err != nil {
+				log.Printf("[Alerts] Failed to scan transcript for CDR %s: %v", transcript.CDRID, err)
+			}
+		}
+	}
+	return done, nil
+}
+
+// transcribe reads a recording back from archive storage and fills in its text
+func (tw *TranscriptionWorker) transcribe(recording ArchivedRecording, transcript *Transcript) error {
+	audio, err := tw.storage.Get(recording.Key)
+	if err != nil {
+		return err
+	}
+	result, err := tw.transcriber.Transcribe(audio, recording.ContentType, path.Base(recording.Key))
+	if err != nil {
+		return err
+	}
+	transcript.Text = result.Text
+	transcript.Language = result.Language
+	return nil
+}
+
+// SaveTranscript stores the outcome of transcribing a recording, replacing an earlier
+// attempt, and keeps the full-text index in step
+func (ds *DatabaseService) SaveTranscript(transcript *Transcript) error {
+	tx, err := ds.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	query := `
+	INSERT INTO recording_transcripts (
+		archived_recording_id, cdr_id, recording_id, domain, backend, language, text,
+		status, error, attempts, created_at, updated_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?)
+	ON CONFLICT(archived_recording_id) DO UPDATE SET
+		backend = excluded.backend,
+		language = excluded.language,
+		text = excluded.text,
+		status = excluded.status,
+		error = excluded.error,
+		attempts = recording_transcripts.attempts + 1,
+		updated_at = excluded.updated_at
+	RETURNING id, attempts, created_at, updated_at`
+
+	err = tx.QueryRow(query,
+		transcript.ArchivedRecordingID,
+		transcript.CDRID,
+		transcript.RecordingID,
+		transcript.Domain,
+		transcript.Backend,
+		transcript.Language,
+		transcript.Text,
+		transcript.Status,
+		transcript.Error,
+		now,
+		now,
+	).Scan(&transcript.ID, &transcript.Attempts, &transcript.CreatedAt, &transcript.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save transcript: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM transcripts_fts WHERE docid = ?`, transcript.ID); err != nil {
+		return fmt.Errorf("failed to update transcript index: %w", err)
+	}
+	if transcript.Status == TranscriptCompleted && transcript.Text != "" {
+		if _, err := tx.Exec(`INSERT INTO transcripts_fts (docid, text) VALUES (?, ?)`, transcript.ID, transcript.Text); err != nil {
+			return fmt.Errorf("failed to update transcript index: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transcript: %w", err)
+	}
+	return nil
+}
+
+// GetUntranscribedRecordings returns archived recordings still waiting for a transcript
+func (ds *DatabaseService) GetUntranscribedRecordings(limit int) ([]ArchivedRecording, error) {
+	return ds.queryArchivedRecordings(`
+	WHERE id NOT IN (
+		SELECT archived_recording_id FROM recording_transcripts
+		WHERE status = ? OR attempts >= ?
+	)
+	ORDER BY archived_at LIMIT ?`, TranscriptCompleted, transcriptionMaxAttempts, limit)
+}
+
+// GetTranscripts returns the transcripts of a CDR's recordings, with their sentiment
+func (ds *DatabaseService) GetTranscripts(cdrID string) ([]Transcript, error) {
+	return ds.queryTranscripts(`WHERE t.cdr_id = ? ORDER BY t.recording_id`, cdrID)
+}
+
+func (ds *DatabaseService) queryTranscripts(where string, args ...interface{}) ([]Transcript, error) {
+	query := `
+	SELECT t.id, t.archived_recording_id, t.cdr_id, COALESCE(t.recording_id, ''), COALESCE(t.domain, ''), t.backend,
+		COALESCE(t.language, ''), COALESCE(t.text, ''), t.status, COALESCE(t.error, ''), t.attempts,
+		t.created_at, t.updated_at,
+		s.score, COALESCE(s.label, ''), COALESCE(s.percent_positive, 0), COALESCE(s.percent_neutral, 0),
+		COALESCE(s.percent_negative, 0)
+	FROM recording_transcripts t
+	LEFT JOIN transcript_sentiments s ON s.transcript_id = t.id ` + where
+
+	rows, err := ds.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transcripts: %w", err)
+	}
+	defer rows.Close()
+
+	transcripts := []Transcript{}
+	for rows.Next() {
+		var t Transcript
+		var score sql.NullFloat64
+		var sentiment SentimentScore
+		if err := rows.Scan(&t.ID, &t.ArchivedRecordingID, &t.CDRID, &t.RecordingID, &t.Domain, &t.Backend,
+			&t.Language, &t.Text, &t.Status, &t.Error, &t.Attempts, &t.CreatedAt, &t.UpdatedAt,
+			&score, &sentiment.Label, &sentiment.PercentPositive, &sentiment.PercentNeutral,
+			&sentiment.PercentNegative); err != nil {
+			return nil, fmt.Errorf("failed to scan transcript: %w", err)
+		}
+		if score.Valid {
+			sentiment.Score = score.Float64
+			t.Sentiment = &sentiment
+		}
+		transcripts = append(transcripts, t)
+	}
+	return transcripts, rows.Err()
+}
+
+// SearchTranscripts finds transcripts matching a full-text query (words, "phrases",
+// prefix*, OR, NOT), newest first, optionally within one domain
+func (ds *DatabaseService) SearchTranscripts(query, domain string, limit int) ([]TranscriptMatch, error) {
+	sqlQuery := `
+	SELECT t.id, t.archived_recording_id, t.cdr_id, COALESCE(t.domain, ''),
+		snippet(transcripts_fts, '[', ']', '...', -1, 16), t.created_at
+	FROM transcripts_fts
+	JOIN recording_transcripts t ON t.id = transcripts_fts.docid
+	WHERE transcripts_fts MATCH ?`
+	args := []interface{}{query}
+	if domain != "" {
+		sqlQuery += ` AND t.domain = ?`
+		args = append(args, domain)
+	}
+	sqlQuery += ` ORDER BY t.created_at DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := ds.db.Query(sqlQuery, args...)
+	if err != nil {
+		if strings.Contains(err.Error(), "MATCH") {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTranscriptQuery, err)
+		}
+		return nil, fmt.Errorf("failed to search transcripts: %w", err)
+	}
+	defer rows.Close()
+
+	matches := []TranscriptMatch{}
+	for rows.Next() {
+		var m TranscriptMatch
+		if err := rows.Scan(&m.TranscriptID, &m.ArchivedRecordingID, &m.CDRID, &m.Domain, &m.Snippet, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan transcript match: %w", err)
+		}
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		if strings.Contains(err.Error(), "MATCH") {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTranscriptQuery, err)
+		}
+		return nil, fmt.Errorf("failed to search transcripts: %w", err)
+	}
+	return matches, nil
+}
+
+// deleteTranscripts removes an archived recording's transcript, its sentiment, keyword
+// alerts (which quote it) and its index entry
+func deleteTranscripts(tx *sql.Tx, archivedRecordingID int64) error {
+	if _, err := tx.Exec(`DELETE FROM keyword_alerts WHERE transcript_id IN (
+		SELECT id FROM recording_transcripts WHERE archived_recording_id = ?)`, archivedRecordingID); err != nil {
+		return fmt.Errorf("failed to delete keyword alerts: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM transcript_sentiments WHERE transcript_id IN (
+		SELECT id FROM recording_transcripts WHERE archived_recording_id = ?)`, archivedRecordingID); err != nil {
+		return fmt.Errorf("failed to delete transcript sentiment: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM transcripts_fts WHERE docid IN (
+		SELECT id FROM recording_transcripts WHERE archived_recording_id = ?)`, archivedRecordingID); err != nil {
+		return fmt.Errorf("failed to delete transcript index: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM recording_transcripts WHERE archived_recording_id = ?`, archivedRecordingID); err != nil {
+		return fmt.Errorf("failed to delete transcript: %w", err)
+	}
+	return nil
+}
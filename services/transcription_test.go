@@ -0,0 +1,85 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTranscriptionWorkerAndSearch(t *testing.T) {
+	fail := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil || r.FormValue("model") != "whisper-1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		file.Close()
+		json.NewEncoder(w).Encode(map[string]string{"text": "I would like a refund for " + header.Filename})
+	}))
+	defer server.Close()
+
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "transcripts.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	storage := NewArchiveStorage(ArchiveSettings{Storage: "local", Dir: t.TempDir()})
+	recording := &ArchivedRecording{
+		CDRID: "cdr-1", RecordingID: "rec-1", Domain: "acme", Storage: "local",
+		Key: "recordings/acme/cdr-1-rec-1.wav", ContentType: "audio/wav", ArchivedAt: time.Now(),
+	}
+	if _, err := storage.Put(recording.Key, []byte("RIFF"), recording.ContentType); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := db.SaveArchivedRecording(recording); err != nil {
+		t.Fatalf("SaveArchivedRecording: %v", err)
+	}
+
+	tw := NewTranscriptionWorker(db, storage, NewTranscriber(TranscriptionSettings{Backend: "whisper", URL: server.URL}), time.Minute)
+	if done, err := tw.ProcessPending(); err != nil || done != 0 {
+		t.Fatalf("ProcessPending with a failing backend = %d, %v", done, err)
+	}
+	if transcripts, _ := db.GetTranscripts("cdr-1"); len(transcripts) != 1 || transcripts[0].Status != TranscriptFailed {
+		t.Fatalf("expected a failed transcript, got %+v", transcripts)
+	}
+
+	fail = false
+	if done, err := tw.ProcessPending(); err != nil || done != 1 {
+		t.Fatalf("ProcessPending retry = %d, %v", done, err)
+	}
+	transcripts, err := db.GetTranscripts("cdr-1")
+	if err != nil || len(transcripts) != 1 || transcripts[0].Attempts != 2 || transcripts[0].Text != "I would like a refund for cdr-1-rec-1.wav" {
+		t.Fatalf("GetTranscripts = %+v, %v", transcripts, err)
+	}
+	if done, _ := tw.ProcessPending(); done != 0 {
+		t.Fatalf("completed recordings should not be transcribed again")
+	}
+
+	matches, err := db.SearchTranscripts("refunds", "acme", 10)
+	if err != nil || len(matches) != 1 || matches[0].CDRID != "cdr-1" || matches[0].Snippet == "" {
+		t.Fatalf("SearchTranscripts = %+v, %v", matches, err)
+	}
+	if matches, _ := db.SearchTranscripts("refund", "other", 10); len(matches) != 0 {
+		t.Fatalf("domain filter ignored: %+v", matches)
+	}
+	if _, err := db.SearchTranscripts(`"refund`, "", 10); !errors.Is(err, ErrInvalidTranscriptQuery) {
+		t.Fatalf("expected ErrInvalidTranscriptQuery, got %v", err)
+	}
+
+	if err := db.DeleteArchivedRecording(recording.ID); err != nil {
+		t.Fatalf("DeleteArchivedRecording: %v", err)
+	}
+	if matches, _ := db.SearchTranscripts("refund", "", 10); len(matches) != 0 {
+		t.Fatalf("transcript kept after its recording was deleted: %+v", matches)
+	}
+}
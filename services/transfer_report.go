@@ -0,0 +1,218 @@
+// services/transfer_report.go
+// Transfer chains: a session's CDR legs grouped into calls by their correlation ID, with
+// how many times each call was transferred, the paths transferred calls took and how
+// long callers waited for the final answer, to spot calls bounced around the PBX
+
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"o-dan-go/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// transferCorrelationFields tie the legs of one call together, first found wins
+var transferCorrelationFields = []string{"call-correlation-id", "call-orig-call-id", "orig_callid", "call-id"}
+
+// transferReportPaths caps how many of the most common paths a report lists
+const transferReportPaths = 20
+
+// TransferChain is one transferred call, its legs in the order they started
+type TransferChain struct {
+	CorrelationID   string   `json:"correlation_id"`
+	CDRIDs          []string `json:"cdr_ids"`
+	Path            []string `json:"path"` // who each leg rang
+	Hops            int      `json:"hops"` // transfers, one fewer than the legs
+	Answered        bool     `json:"answered"`
+	SecondsToAnswer int      `json:"seconds_to_answer,omitempty"` // from the first leg starting to the last being answered
+}
+
+// TransferPath is how many transferred calls took one path
+type TransferPath struct {
+	Path               string  `json:"path"`
+	Calls              int     `json:"calls"`
+	AvgSecondsToAnswer float64 `json:"avg_seconds_to_answer"` // of the answered calls with start times
+
+	answeredWithTimes     int
+	secondsToAnswerTotals int
+}
+
+// HopCount is how many calls were transferred a number of times
+type HopCount struct {
+	Hops  int `json:"hops"`
+	Calls int `json:"calls"`
+}
+
+// TransferReport is a session's calls and the transfers among them
+type TransferReport struct {
+	sessionReportBase
+	Calls              int             `json:"calls"` // correlated calls, however many legs
+	Transferred        int             `json:"transferred"`
+	TransferPercent    float64         `json:"transfer_percent"`
+	MaxHops            int             `json:"max_hops"`
+	Hops               []HopCount      `json:"hops"`  // by hops, untransferred calls first
+	Paths              []TransferPath  `json:"paths"` // the most common paths of transferred calls
+	AvgSecondsToAnswer float64         `json:"avg_seconds_to_answer"`
+	Chains             []TransferChain `json:"chains"`       // the transferred calls, most hops first
+	Uncorrelated       int             `json:"uncorrelated"` // CDRs with no correlation ID
+	GeneratedAt        time.Time       `json:"generated_at"`
+}
+
+// transferLeg is one CDR of a call
+type transferLeg struct {
+	cdr     models.FlexibleCDR
+	started time.Time
+}
+
+// transferParty names who a leg rang: the terminating user, or else the number
+func transferParty(cdr models.FlexibleCDR) string {
+	if user := cdr.GetTermUser(); user != "" {
+		return user
+	}
+	if number, _ := filterFieldValue(&cdr, "term_number"); number != "" {
+		return number
+	}
+	return "?"
+}
+
+// transferAnsweredAt is when a leg was answered, or the zero time if it wasn't or the
+// CDR doesn't say
+func transferAnsweredAt(leg transferLeg) time.Time {
+	for _, field := range []string{"call-answer-datetime", "call-answered-datetime"} {
+		if answered, err := leg.cdr.GetTime(field); err == nil {
+			return answered
+		}
+	}
+	if answered, _ := callAnswered(leg.cdr); answered {
+		return leg.started
+	}
+	return time.Time{}
+}
+
+// BuildTransferReport groups cdrs into calls and follows each call's transfers
+func BuildTransferReport(cdrs []models.FlexibleCDR) *TransferReport {
+	report := &TransferReport{Hops: []HopCount{}, Paths: []TransferPath{}, Chains: []TransferChain{}, GeneratedAt: time.Now().UTC()}
+
+	calls := make(map[string][]transferLeg)
+	for _, cdr := range cdrs {
+		correlationID := firstCDRString(cdr, transferCorrelationFields...)
+		if correlationID == "" {
+			report.Uncorrelated++
+			continue
+		}
+		started, _ := cdr.GetCallStartTime()
+		calls[correlationID] = append(calls[correlationID], transferLeg{cdr: cdr, started: started})
+	}
+
+	hops := make(map[int]int)
+	paths := make(map[string]*TransferPath)
+	answeredWithTimes, secondsToAnswer := 0, 0
+	for correlationID, legs := range calls {
+		report.Calls++
+		hops[len(legs)-1]++
+		if len(legs) < 2 {
+			continue
+		}
+		sort.SliceStable(legs, func(i, j int) bool { return legs[i].started.Before(legs[j].started) })
+
+		chain := TransferChain{CorrelationID: correlationID, Hops: len(legs) - 1}
+		for _, leg := range legs {
+			chain.CDRIDs = append(chain.CDRIDs, leg.cdr.GetID())
+			chain.Path = append(chain.Path, transferParty(leg.cdr))
+		}
+		path := strings.Join(chain.Path, " > ")
+		if paths[path] == nil {
+			paths[path] = &TransferPath{Path: path}
+		}
+		paths[path].Calls++
+
+		answeredAt := transferAnsweredAt(legs[len(legs)-1])
+		chain.Answered = !answeredAt.IsZero()
+		if chain.Answered && !legs[0].started.IsZero() && !answeredAt.Before(legs[0].started) {
+			chain.SecondsToAnswer = int(answeredAt.Sub(legs[0].started).Seconds())
+			paths[path].answeredWithTimes++
+			paths[path].secondsToAnswerTotals += chain.SecondsToAnswer
+			answeredWithTimes++
+			secondsToAnswer += chain.SecondsToAnswer
+		}
+
+		report.Transferred++
+		if chain.Hops > report.MaxHops {
+			report.MaxHops = chain.Hops
+		}
+		report.Chains = append(report.Chains, chain)
+	}
+
+	if report.Calls > 0 {
+		report.TransferPercent = roundTo(100*float64(report.Transferred)/float64(report.Calls), 1)
+	}
+	if answeredWithTimes > 0 {
+		report.AvgSecondsToAnswer = roundTo(float64(secondsToAnswer)/float64(answeredWithTimes), 1)
+	}
+	for count, calls := range hops {
+		report.Hops = append(report.Hops, HopCount{Hops: count, Calls: calls})
+	}
+	sort.Slice(report.Hops, func(i, j int) bool { return report.Hops[i].Hops < report.Hops[j].Hops })
+
+	for _, path := range paths {
+		if path.answeredWithTimes > 0 {
+			path.AvgSecondsToAnswer = roundTo(float64(path.secondsToAnswerTotals)/float64(path.answeredWithTimes), 1)
+		}
+		report.Paths = append(report.Paths, *path)
+	}
+	sort.Slice(report.Paths, func(i, j int) bool {
+		if report.Paths[i].Calls != report.Paths[j].Calls {
+			return report.Paths[i].Calls > report.Paths[j].Calls
+		}
+		return report.Paths[i].Path < report.Paths[j].Path
+	})
+	if len(report.Paths) > transferReportPaths {
+		report.Paths = report.Paths[:transferReportPaths]
+	}
+
+	sort.Slice(report.Chains, func(i, j int) bool {
+		if report.Chains[i].Hops != report.Chains[j].Hops {
+			return report.Chains[i].Hops > report.Chains[j].Hops
+		}
+		return report.Chains[i].CorrelationID < report.Chains[j].CorrelationID
+	})
+	return report
+}
+
+// AlertSummary describes the report in one line
+func (r *TransferReport) AlertSummary() string {
+	return fmt.Sprintf("Transfers: %d of %d calls transferred (%.1f%%), up to %d hops, %.0fs average to answer",
+		r.Transferred, r.Calls, r.TransferPercent, r.MaxHops, r.AvgSecondsToAnswer)
+}
+
+// AlertDetails lists the most common transfer paths
+func (r *TransferReport) AlertDetails() []string {
+	details := []string{"Session: " + r.SessionID}
+	for _, path := range r.Paths {
+		details = append(details, fmt.Sprintf("%s: %d calls, %.0fs average to answer", path.Path, path.Calls, path.AvgSecondsToAnswer))
+	}
+	return details
+}
+
+// WriteCSV writes a row per transferred call
+func (r *TransferReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"correlation_id", "hops", "path", "answered", "seconds_to_answer", "cdr_ids"})
+	for _, chain := range r.Chains {
+		writer.Write([]string{
+			chain.CorrelationID,
+			strconv.Itoa(chain.Hops),
+			strings.Join(chain.Path, " > "),
+			strconv.FormatBool(chain.Answered),
+			strconv.Itoa(chain.SecondsToAnswer),
+			strings.Join(chain.CDRIDs, " "),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+
+	"o-dan-go/models"
+)
+
+func TestBuildTransferReport(t *testing.T) {
+	leg := func(id, correlationID, term, start, answer string) models.FlexibleCDR {
+		raw := map[string]interface{}{"id": id, "call-orig-call-id": correlationID, "call-term-user": term,
+			"call-start-datetime": start, "call-total-duration-seconds": float64(0)}
+		if answer != "" {
+			raw["call-answer-datetime"] = answer
+			raw["call-total-duration-seconds"] = float64(60)
+		}
+		return models.FlexibleCDR{RawData: raw}
+	}
+	cdrs := []models.FlexibleCDR{
+		leg("a2", "call-a", "200", "2026-01-05T10:00:20Z", "2026-01-05T10:00:30Z"),
+		leg("a1", "call-a", "100", "2026-01-05T10:00:00Z", "2026-01-05T10:00:05Z"),
+		leg("b1", "call-b", "100", "2026-01-05T11:00:00Z", "2026-01-05T11:00:05Z"),
+		leg("b2", "call-b", "200", "2026-01-05T11:00:10Z", "2026-01-05T11:00:20Z"),
+		leg("c1", "call-c", "100", "2026-01-05T12:00:00Z", ""),
+		leg("c2", "call-c", "300", "2026-01-05T12:00:30Z", ""),
+		leg("c3", "call-c", "200", "2026-01-05T12:01:00Z", "2026-01-05T12:01:40Z"),
+		leg("d1", "call-d", "100", "2026-01-05T13:00:00Z", "2026-01-05T13:00:05Z"),
+		{RawData: map[string]interface{}{"id": "e1"}},
+	}
+
+	report := BuildTransferReport(cdrs)
+	if report.Calls != 4 || report.Transferred != 3 || report.TransferPercent != 75 || report.MaxHops != 2 || report.Uncorrelated != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	if len(report.Hops) != 3 || report.Hops[0] != (HopCount{Hops: 0, Calls: 1}) || report.Hops[1] != (HopCount{Hops: 1, Calls: 2}) {
+		t.Errorf("hops = %+v", report.Hops)
+	}
+	if len(report.Paths) != 2 || report.Paths[0].Path != "100 > 200" || report.Paths[0].Calls != 2 || report.Paths[0].AvgSecondsToAnswer != 25 {
+		t.Errorf("paths = %+v", report.Paths)
+	}
+	chain := report.Chains[0]
+	if chain.CorrelationID != "call-c" || chain.Hops != 2 || !chain.Answered || chain.SecondsToAnswer != 100 ||
+		len(chain.CDRIDs) != 3 || chain.CDRIDs[0] != "c1" {
+		t.Errorf("longest chain = %+v", chain)
+	}
+	if report.AvgSecondsToAnswer != 50 {
+		t.Errorf("avg seconds to answer = %v", report.AvgSecondsToAnswer)
+	}
+}
@@ -0,0 +1,176 @@
+// services/trunk_sizing.go
+// Erlang traffic analysis: finds each domain's busy hour from the recorded calls and
+// recommends how many trunk channels carry it at a target blocking probability (Erlang B)
+
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultBlockingTargets are the grades of service sized for when none are asked for
+var DefaultBlockingTargets = []float64{0.01, 0.02, 0.05}
+
+// maxTrunkChannels bounds the channel search so a bad input can't loop forever
+const maxTrunkChannels = 10000
+
+// BusyHour is the busiest clock hour of one day
+type BusyHour struct {
+	Day     string    `json:"day"`
+	Hour    time.Time `json:"hour"`
+	Erlangs float64   `json:"erlangs"`
+	Calls   int       `json:"calls"` // answered calls connected during the hour
+}
+
+// TrunkSizing is the channel count for one target blocking probability
+type TrunkSizing struct {
+	TargetBlocking float64 `json:"target_blocking"`
+	Channels       int     `json:"channels"`
+	Blocking       float64 `json:"blocking"` // Erlang B blocking with that many channels
+}
+
+// TrafficReport is the busy-hour traffic of a domain with trunk sizing recommendations
+type TrafficReport struct {
+	Domain          string        `json:"domain"`
+	Start           string        `json:"start"`
+	End             string        `json:"end"`
+	Calls           int           `json:"calls"`
+	BusyHour        *BusyHour     `json:"busy_hour"` // the busiest hour in the period
+	AverageBusyHour float64       `json:"average_busy_hour_erlangs"`
+	DailyBusyHours  []BusyHour    `json:"daily_busy_hours"`
+	Recommendations []TrunkSizing `json:"recommendations"` // sized for the busiest hour
+	GeneratedAt     time.Time     `json:"generated_at"`
+}
+
+// ErlangB is the probability a call is blocked when traffic (in Erlangs) is offered to a
+// number of channels
+func ErlangB(traffic float64, channels int) float64 {
+	blocking := 1.0
+	for n := 1; n <= channels; n++ {
+		blocking = traffic * blocking / (float64(n) + traffic*blocking)
+	}
+	return blocking
+}
+
+// ChannelsFor is the fewest channels that carry traffic with at most the target blocking
+func ChannelsFor(traffic, target float64) int {
+	if traffic <= 0 {
+		return 0
+	}
+	blocking := 1.0
+	for n := 1; n <= maxTrunkChannels; n++ {
+		blocking = traffic * blocking / (float64(n) + traffic*blocking)
+		if blocking <= target {
+			return n
+		}
+	}
+	return maxTrunkChannels
+}
+
+// TrafficReport finds a domain's busy hours between two days (inclusive, UTC) and sizes
+// trunks for the busiest one at each target blocking probability
+func (ks *KPIService) TrafficReport(domain string, start, end time.Time, targets []float64) (*TrafficReport, error) {
+	for _, target := range targets {
+		if target <= 0 || target >= 1 {
+			return nil, fmt.Errorf("blocking targets must be between 0 and 1")
+		}
+	}
+	if len(targets) == 0 {
+		targets = DefaultBlockingTargets
+	}
+
+	calls, err := ks.db.GetAnsweredCalls(domain, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	// Spread each call's connected time over the clock hours it spans
+	seconds := map[time.Time]float64{}
+	callsInHour := map[time.Time]int{}
+	for _, call := range calls {
+		callStart := call.StartedAt.UTC()
+		callEnd := callStart.Add(time.Duration(call.TalkSeconds) * time.Second)
+		for hour := callStart.Truncate(time.Hour); hour.Before(callEnd); hour = hour.Add(time.Hour) {
+			from, to := hour, hour.Add(time.Hour)
+			if callStart.After(from) {
+				from = callStart
+			}
+			if callEnd.Before(to) {
+				to = callEnd
+			}
+			seconds[hour] += to.Sub(from).Seconds()
+			callsInHour[hour]++
+		}
+	}
+
+	busiest := map[string]BusyHour{}
+	for hour, total := range seconds {
+		day := hour.Format(kpiDayFormat)
+		erlangs := roundTo(total/3600, 2)
+		if current, ok := busiest[day]; !ok || erlangs > current.Erlangs || (erlangs == current.Erlangs && hour.Before(current.Hour)) {
+			busiest[day] = BusyHour{Day: day, Hour: hour, Erlangs: erlangs, Calls: callsInHour[hour]}
+		}
+	}
+
+	report := &TrafficReport{
+		Domain:          domain,
+		Start:           start.Format(kpiDayFormat),
+		End:             end.Format(kpiDayFormat),
+		Calls:           len(calls),
+		DailyBusyHours:  []BusyHour{},
+		Recommendations: []TrunkSizing{},
+		GeneratedAt:     time.Now().UTC(),
+	}
+	total := 0.0
+	for _, busyHour := range busiest {
+		report.DailyBusyHours = append(report.DailyBusyHours, busyHour)
+		total += busyHour.Erlangs
+	}
+	sort.Slice(report.DailyBusyHours, func(i, j int) bool {
+		return report.DailyBusyHours[i].Day < report.DailyBusyHours[j].Day
+	})
+	for i := range report.DailyBusyHours {
+		if report.BusyHour == nil || report.DailyBusyHours[i].Erlangs > report.BusyHour.Erlangs {
+			report.BusyHour = &report.DailyBusyHours[i]
+		}
+	}
+	if report.BusyHour == nil {
+		return report, nil
+	}
+	report.AverageBusyHour = roundTo(total/float64(len(busiest)), 2)
+
+	for _, target := range targets {
+		channels := ChannelsFor(report.BusyHour.Erlangs, target)
+		report.Recommendations = append(report.Recommendations, TrunkSizing{
+			TargetBlocking: target,
+			Channels:       channels,
+			Blocking:       roundTo(ErlangB(report.BusyHour.Erlangs, channels), 4),
+		})
+	}
+	return report, nil
+}
+
+// GetAnsweredCalls lists the answered calls recorded for a domain between two days (inclusive)
+func (ds *DatabaseService) GetAnsweredCalls(domain string, start, end time.Time) ([]CallOutcome, error) {
+	rows, err := ds.db.Query(`
+	SELECT cdr_id, COALESCE(domain, ''), COALESCE(trunk, ''), call_start, talk_seconds
+	FROM call_outcomes
+	WHERE domain = ? AND call_day >= ? AND call_day <= ? AND answered
+	ORDER BY call_start`, domain, start.Format(kpiDayFormat), end.Format(kpiDayFormat))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query call outcomes: %w", err)
+	}
+	defer rows.Close()
+
+	calls := []CallOutcome{}
+	for rows.Next() {
+		call := CallOutcome{Answered: true}
+		if err := rows.Scan(&call.CDRID, &call.Domain, &call.Trunk, &call.StartedAt, &call.TalkSeconds); err != nil {
+			return nil, fmt.Errorf("failed to scan call outcome: %w", err)
+		}
+		calls = append(calls, call)
+	}
+	return calls, rows.Err()
+}
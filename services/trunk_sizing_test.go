@@ -0,0 +1,93 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestErlangB(t *testing.T) {
+	if got := ErlangB(10, 15); math.Abs(got-0.0365) > 0.0005 {
+		t.Errorf("ErlangB(10, 15) = %.4f, want 0.0365", got)
+	}
+	// From the Erlang B table
+	tests := []struct {
+		traffic, target float64
+		want            int
+	}{
+		{1, 0.01, 5},
+		{10, 0.01, 18},
+		{10, 0.05, 15},
+		{0, 0.01, 0},
+	}
+	for _, test := range tests {
+		if got := ChannelsFor(test.traffic, test.target); got != test.want {
+			t.Errorf("ChannelsFor(%v, %v) = %d, want %d", test.traffic, test.target, got, test.want)
+		}
+	}
+}
+
+func TestTrafficReport(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "traffic.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	cdrs := []models.FlexibleCDR{}
+	call := func(domain, start string, seconds int) {
+		cdrs = append(cdrs, models.FlexibleCDR{RawData: map[string]interface{}{
+			"id":                          fmt.Sprintf("cdr-%d", len(cdrs)+1),
+			"domain":                      domain,
+			"call-start-datetime":         start,
+			"call-total-duration-seconds": seconds,
+		}})
+	}
+	// The 13th: ten hour-long calls from 10:00 (10 Erlangs), and a call from 10:30 to 11:30
+	// split across two hours
+	for i := 0; i < 10; i++ {
+		call("acme", "2026-10-13T10:00:00Z", 3600)
+	}
+	call("acme", "2026-10-13T10:30:00Z", 3600)
+	// The 14th: two Erlangs at 09:00
+	call("acme", "2026-10-14T09:00:00Z", 3600)
+	call("acme", "2026-10-14T09:00:00Z", 3600)
+	// Other domains and unanswered calls don't count
+	call("globex", "2026-10-13T10:00:00Z", 3600)
+	call("acme", "2026-10-13T10:00:00Z", 0)
+
+	kpis := NewKPIService(db, KPISettings{})
+	if _, err := kpis.RecordCDRs(cdrs); err != nil {
+		t.Fatalf("RecordCDRs: %v", err)
+	}
+
+	start, _ := time.Parse("2006-01-02", "2026-10-13")
+	report, err := kpis.TrafficReport("acme", start, start.AddDate(0, 0, 1), []float64{0.01, 0.05})
+	if err != nil {
+		t.Fatalf("TrafficReport: %v", err)
+	}
+	if report.Calls != 13 || len(report.DailyBusyHours) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.BusyHour == nil || report.BusyHour.Erlangs != 10.5 || report.BusyHour.Hour.Hour() != 10 || report.BusyHour.Calls != 11 {
+		t.Errorf("busy hour = %+v, want 10.5 Erlangs at 10:00 on the 13th", report.BusyHour)
+	}
+	if report.AverageBusyHour != 6.25 {
+		t.Errorf("average busy hour = %v, want 6.25", report.AverageBusyHour)
+	}
+	if len(report.Recommendations) != 2 || report.Recommendations[0].Channels != 19 || report.Recommendations[0].Blocking > 0.01 {
+		t.Errorf("recommendations = %+v", report.Recommendations)
+	}
+
+	empty, err := kpis.TrafficReport("initech", start, start, nil)
+	if err != nil || empty.BusyHour != nil || len(empty.Recommendations) != 0 {
+		t.Fatalf("empty report = %+v, %v", empty, err)
+	}
+	if _, err := kpis.TrafficReport("acme", start, start, []float64{5}); err == nil {
+		t.Fatal("TrafficReport accepted a blocking target above 1")
+	}
+}
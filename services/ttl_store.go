@@ -0,0 +1,178 @@
+// services/ttl_store.go
+// TTL store: a bounded, thread-safe in-memory cache whose entries expire after a time to
+// live, shared by search results, IVR sessions and anything else that would otherwise
+// grow its own map and mutex
+
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// TTLStore keeps values by key until they expire. Each entry is removed when its TTL
+// passes; with a maximum size, storing a new key evicts the oldest entry once full.
+type TTLStore[K comparable, V any] struct {
+	mu         sync.RWMutex
+	entries    map[K]*ttlEntry[V]
+	ttl        time.Duration
+	maxEntries int
+}
+
+// ttlEntry is a stored value and the timer that removes it
+type ttlEntry[V any] struct {
+	value     V
+	storedAt  time.Time
+	expiresAt time.Time
+	timer     *time.Timer
+}
+
+// TTLStoreEntry describes a stored value for introspection
+type TTLStoreEntry[K comparable, V any] struct {
+	Key       K
+	Value     V
+	StoredAt  time.Time
+	ExpiresAt time.Time
+}
+
+// NewTTLStore creates a store whose entries live for ttl. maxEntries bounds how many it
+// holds; 0 leaves it unbounded.
+func NewTTLStore[K comparable, V any](ttl time.Duration, maxEntries int) *TTLStore[K, V] {
+	return &TTLStore[K, V]{
+		entries:    make(map[K]*ttlEntry[V]),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}
+}
+
+// Set stores a value for the store's TTL, replacing any value under the same key
+func (s *TTLStore[K, V]) Set(key K, value V) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(key, value, s.ttl)
+}
+
+// SetWithTTL stores a value that expires after ttl instead of the store's TTL
+func (s *TTLStore[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(key, value, ttl)
+}
+
+// set stores a value; the caller holds the lock
+func (s *TTLStore[K, V]) set(key K, value V, ttl time.Duration) {
+	if existing, ok := s.entries[key]; ok {
+		existing.timer.Stop()
+	} else if s.maxEntries > 0 && len(s.entries) >= s.maxEntries {
+		s.evictOldest()
+	}
+
+	now := time.Now()
+	entry := &ttlEntry[V]{value: value, storedAt: now, expiresAt: now.Add(ttl)}
+	// The timer only removes the entry it was started for, not a later value under the key
+	entry.timer = time.AfterFunc(ttl, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.entries[key] == entry {
+			delete(s.entries, key)
+		}
+	})
+	s.entries[key] = entry
+}
+
+// evictOldest removes the entry stored longest ago; the caller holds the lock
+func (s *TTLStore[K, V]) evictOldest() {
+	var oldestKey K
+	var oldest *ttlEntry[V]
+	for key, entry := range s.entries {
+		if oldest == nil || entry.storedAt.Before(oldest.storedAt) {
+			oldestKey, oldest = key, entry
+		}
+	}
+	if oldest != nil {
+		oldest.timer.Stop()
+		delete(s.entries, oldestKey)
+	}
+}
+
+// Get returns the value stored under key, if it hasn't expired
+func (s *TTLStore[K, V]) Get(key K) (V, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.entries[key]
+	if !ok || !time.Now().Before(entry.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// Delete removes a value
+func (s *TTLStore[K, V]) Delete(key K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok {
+		entry.timer.Stop()
+		delete(s.entries, key)
+	}
+}
+
+// Len returns the number of values stored
+func (s *TTLStore[K, V]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.entries)
+}
+
+// Clear removes every value
+func (s *TTLStore[K, V]) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range s.entries {
+		entry.timer.Stop()
+	}
+	s.entries = make(map[K]*ttlEntry[V])
+}
+
+// Entries returns every value with when it was stored and expires, newest first
+func (s *TTLStore[K, V]) Entries() []TTLStoreEntry[K, V] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entries := make([]TTLStoreEntry[K, V], 0, len(s.entries))
+	for key, entry := range s.entries {
+		entries = append(entries, TTLStoreEntry[K, V]{
+			Key:       key,
+			Value:     entry.value,
+			StoredAt:  entry.storedAt,
+			ExpiresAt: entry.expiresAt,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].StoredAt.After(entries[j].StoredAt)
+	})
+	return entries
+}
+
+// TTL returns the time to live of new values
+func (s *TTLStore[K, V]) TTL() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.ttl
+}
+
+// SetTTL changes the time to live of new values; stored values keep theirs
+func (s *TTLStore[K, V]) SetTTL(ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ttl = ttl
+}
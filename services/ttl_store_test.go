@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLStore(t *testing.T) {
+	store := NewTTLStore[string, int](time.Hour, 2)
+	store.Set("a", 1)
+	store.Set("b", 2)
+	if value, ok := store.Get("a"); !ok || value != 1 {
+		t.Fatalf("Get(a) = %d, %v", value, ok)
+	}
+
+	store.Set("c", 3)
+	if _, ok := store.Get("a"); ok || store.Len() != 2 {
+		t.Errorf("a full store should evict its oldest entry, len %d", store.Len())
+	}
+	store.Set("b", 20)
+	if value, _ := store.Get("b"); value != 20 || store.Len() != 2 {
+		t.Errorf("replacing a key should not evict, b = %d, len %d", value, store.Len())
+	}
+	if entries := store.Entries(); len(entries) != 2 || entries[0].Key != "b" {
+		t.Errorf("entries = %+v, want newest first", entries)
+	}
+
+	store.Clear()
+	if store.Len() != 0 {
+		t.Errorf("len after Clear = %d", store.Len())
+	}
+}
+
+func TestTTLStoreExpiry(t *testing.T) {
+	store := NewTTLStore[string, int](time.Hour, 0)
+	store.Set("long", 1)
+	store.SetWithTTL("short", 2, 20*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := store.Get("short"); ok {
+		t.Error("an expired entry should not be returned")
+	}
+	if store.Len() != 1 {
+		t.Errorf("an expired entry should be removed, len %d", store.Len())
+	}
+
+	// A value stored again under a key outlives the timer of the value it replaced
+	store.SetWithTTL("again", 1, 20*time.Millisecond)
+	store.Set("again", 2)
+	time.Sleep(50 * time.Millisecond)
+	if value, ok := store.Get("again"); !ok || value != 2 {
+		t.Errorf("Get(again) = %d, %v", value, ok)
+	}
+}
@@ -0,0 +1,283 @@
+// services/voicemail_report.go
+// Calls that ended in voicemail, found in the CDRs and matched with the messages left in
+// each user's NetSapiens mailbox
+
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"o-dan-go/models"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// voicemailMatchWindow is how long after a call ends its message may be timestamped
+const voicemailMatchWindow = 2 * time.Minute
+
+// VoicemailCall is one call that went to a user's voicemail
+type VoicemailCall struct {
+	CDRID           string    `json:"cdr_id"`
+	Caller          string    `json:"caller,omitempty"`
+	CallerName      string    `json:"caller_name,omitempty"` // CNAM, when caller names are looked up
+	StartedAt       time.Time `json:"started_at"`
+	Duration        int       `json:"duration"`                    // seconds, including the greeting
+	TimeToVoicemail int       `json:"time_to_voicemail,omitempty"` // seconds the call rang first
+	MessageLeft     bool      `json:"message_left"`                // a matching message is in the mailbox
+}
+
+// UserVoicemailReport counts the calls that went to one user's voicemail
+type UserVoicemailReport struct {
+	User               string          `json:"user"`
+	Calls              []VoicemailCall `json:"calls"`
+	Total              int             `json:"total"`
+	MessagesLeft       int             `json:"messages_left"`
+	MailboxChecked     bool            `json:"mailbox_checked"` // messages_left is only known when true
+	AvgTimeToVoicemail float64         `json:"avg_time_to_voicemail"`
+}
+
+// VoicemailReport is the calls to voicemail for a domain (or one user in it)
+type VoicemailReport struct {
+	Domain      string                `json:"domain"`
+	Since       time.Time             `json:"since"`
+	Users       []UserVoicemailReport `json:"users"`
+	TotalCalls  int                   `json:"total_calls"`
+	CDRErrors   []string              `json:"cdr_errors,omitempty"`
+	GeneratedAt time.Time             `json:"generated_at"`
+}
+
+// voicemailMessage is a message in a NetSapiens mailbox
+type voicemailMessage struct {
+	caller     string
+	receivedAt time.Time
+	matched    bool
+}
+
+// VoicemailReportService builds voicemail reports from CDRs and the NetSapiens v2 voicemail API
+type VoicemailReportService struct {
+	client      *http.Client
+	baseURL     string
+	accessToken string
+	cdrService  *CDRDiscoveryService
+	enricher    *NumberEnricher
+}
+
+// NewVoicemailReportService creates a voicemail report client; calls are looked up through cdrService
+func NewVoicemailReportService(baseURL, accessToken string, cdrService *CDRDiscoveryService) *VoicemailReportService {
+	return &VoicemailReportService{
+		client:      &http.Client{Timeout: 15 * time.Second},
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		accessToken: accessToken,
+		cdrService:  cdrService,
+	}
+}
+
+// EnrichNumbers looks up caller names for the calls in reports through enricher
+func (vs *VoicemailReportService) EnrichNumbers(enricher *NumberEnricher) {
+	vs.enricher = enricher
+}
+
+// GetVoicemailReport lists the calls in the lookback window that went to voicemail, per
+// user, and which of them left a message. Mailboxes that can't be read are reported
+// with mailbox_checked false rather than failing the report.
+func (vs *VoicemailReportService) GetVoicemailReport(domain, user string, lookback time.Duration) (*VoicemailReport, error) {
+	now := time.Now()
+	since := now.Add(-lookback)
+	result, err := vs.cdrService.GetComprehensiveCDRs(CDRSearchCriteria{Domain: domain, User: user, StartDate: &since, Limit: 500})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VoicemailReport{
+		Domain:      domain,
+		Since:       since,
+		CDRErrors:   result.Errors,
+		GeneratedAt: now,
+	}
+
+	voicemailCDRs := []models.FlexibleCDR{}
+	for _, cdr := range result.AllCDRs {
+		mailbox := VoicemailUser(cdr)
+		if mailbox == "" || (user != "" && mailbox != user) {
+			continue
+		}
+		if started, err := cdr.GetCallStartTime(); err == nil && started.Before(since) {
+			continue
+		}
+		voicemailCDRs = append(voicemailCDRs, cdr)
+	}
+	if vs.enricher.Enabled() {
+		vs.enricher.EnrichCDRs(voicemailCDRs)
+	}
+
+	users := make(map[string]*UserVoicemailReport)
+	for _, cdr := range voicemailCDRs {
+		mailbox := VoicemailUser(cdr)
+		started, _ := cdr.GetCallStartTime()
+		entry, exists := users[mailbox]
+		if !exists {
+			entry = &UserVoicemailReport{User: mailbox, Calls: []VoicemailCall{}}
+			users[mailbox] = entry
+		}
+		call := VoicemailCall{
+			CDRID:           cdr.GetID(),
+			StartedAt:       started,
+			Duration:        cdr.GetCallDuration(),
+			TimeToVoicemail: timeToVoicemail(cdr),
+		}
+		if caller := cdr.GetOrigCallerID(); caller > 0 {
+			call.Caller = strconv.FormatInt(caller, 10)
+		}
+		call.CallerName = cdr.GetString("orig" + enrichedCallerNameField)
+		entry.Calls = append(entry.Calls, call)
+	}
+
+	for _, entry := range users {
+		if messages, err := vs.getMessages(domain, entry.User); err == nil {
+			entry.MailboxChecked = true
+			matchVoicemailMessages(entry.Calls, messages)
+		}
+		summarizeVoicemail(entry)
+		report.TotalCalls += entry.Total
+		report.Users = append(report.Users, *entry)
+	}
+	sort.Slice(report.Users, func(i, j int) bool {
+		if report.Users[i].Total != report.Users[j].Total {
+			return report.Users[i].Total > report.Users[j].Total
+		}
+		return report.Users[i].User < report.Users[j].User
+	})
+
+	return report, nil
+}
+
+// getMessages reads the messages in a user's mailbox
+func (vs *VoicemailReportService) getMessages(domain, user string) ([]*voicemailMessage, error) {
+	if vs.accessToken == "" {
+		return nil, fmt.Errorf("no NetSapiens access token configured")
+	}
+
+	var records []map[string]interface{}
+	path := fmt.Sprintf("/ns-api/v2/domains/%s/users/%s/voicemails", url.PathEscape(domain), url.PathEscape(user))
+	if err := netsapiensGet(vs.client, vs.baseURL, vs.accessToken, path, &records); err != nil {
+		return nil, err
+	}
+
+	messages := make([]*voicemailMessage, 0, len(records))
+	for _, record := range records {
+		receivedAt, ok := recordTime(record, "voicemail-received-datetime", "datetime", "time", "received")
+		if !ok {
+			continue
+		}
+		messages = append(messages, &voicemailMessage{
+			caller:     recordString(record, "voicemail-caller-id", "caller-id", "from", "callerid"),
+			receivedAt: receivedAt,
+		})
+	}
+	return messages, nil
+}
+
+// VoicemailUser returns the user whose voicemail answered a call, or "" if the call
+// didn't go to voicemail. NetSapiens terminates these calls on a "vmail" user, either
+// per mailbox (vmail_101) or shared with the mailbox in the dialed user.
+func VoicemailUser(cdr models.FlexibleCDR) string {
+	term := strings.ToLower(cdr.GetTermUser())
+	for _, prefix := range []string{"vmail_", "vmail-", "vmail", "voicemail_", "voicemail"} {
+		if strings.HasPrefix(term, prefix) {
+			if mailbox := strings.TrimPrefix(term, prefix); mailbox != "" {
+				return mailbox
+			}
+			return firstCDRString(cdr, "call-orig-to-user", "call-term-to-user", "call-dialed-user")
+		}
+	}
+
+	application := strings.ToLower(firstCDRString(cdr, "call-term-application", "call-term-to-uri"))
+	if strings.Contains(application, "vmail") || strings.Contains(application, "voicemail") {
+		return cdr.GetTermUser()
+	}
+	return ""
+}
+
+// timeToVoicemail is how long a call rang before voicemail answered, or 0 if unknown
+func timeToVoicemail(cdr models.FlexibleCDR) int {
+	started, err := cdr.GetCallStartTime()
+	if err != nil {
+		return 0
+	}
+	for _, field := range []string{"call-answer-datetime", "call-answered-datetime"} {
+		if answered, err := cdr.GetTime(field); err == nil && answered.After(started) {
+			return int(answered.Sub(started).Seconds())
+		}
+	}
+	return 0
+}
+
+// matchVoicemailMessages marks each call whose message is in the mailbox: received
+// during the call or shortly after, from the same caller when both numbers are known
+func matchVoicemailMessages(calls []VoicemailCall, messages []*voicemailMessage) {
+	for i := range calls {
+		call := &calls[i]
+		if call.StartedAt.IsZero() {
+			continue
+		}
+		latest := call.StartedAt.Add(time.Duration(call.Duration)*time.Second + voicemailMatchWindow)
+		for _, message := range messages {
+			if message.matched || message.receivedAt.Before(call.StartedAt) || message.receivedAt.After(latest) {
+				continue
+			}
+			if call.Caller != "" && message.caller != "" && !sameNumber(call.Caller, message.caller) {
+				continue
+			}
+			message.matched = true
+			call.MessageLeft = true
+			break
+		}
+	}
+}
+
+// summarizeVoicemail fills in a user's totals from their calls
+func summarizeVoicemail(entry *UserVoicemailReport) {
+	sort.Slice(entry.Calls, func(i, j int) bool {
+		return entry.Calls[i].StartedAt.After(entry.Calls[j].StartedAt)
+	})
+
+	entry.Total = len(entry.Calls)
+	timed, totalTime := 0, 0
+	for _, call := range entry.Calls {
+		if call.MessageLeft {
+			entry.MessagesLeft++
+		}
+		if call.TimeToVoicemail > 0 {
+			timed++
+			totalTime += call.TimeToVoicemail
+		}
+	}
+	if timed > 0 {
+		entry.AvgTimeToVoicemail = float64(totalTime) / float64(timed)
+	}
+}
+
+// sameNumber compares two phone numbers by their last 10 digits
+func sameNumber(a, b string) bool {
+	a, b = nonDigits.ReplaceAllString(a, ""), nonDigits.ReplaceAllString(b, "")
+	if len(a) > 10 {
+		a = a[len(a)-10:]
+	}
+	if len(b) > 10 {
+		b = b[len(b)-10:]
+	}
+	return a != "" && a == b
+}
+
+// firstCDRString returns the first of fields set on a CDR
+func firstCDRString(cdr models.FlexibleCDR, fields ...string) string {
+	for _, field := range fields {
+		if value := cdr.GetString(field); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"o-dan-go/models"
+)
+
+func TestGetVoicemailReport(t *testing.T) {
+	start := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	format := func(t time.Time) string { return t.Format("2006-01-02 15:04:05") }
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/cdrs"):
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"id": "1", "call-term-user": "vmail_101", "call-orig-caller-id": 14155551234, "call-start-datetime": format(start),
+					"call-answer-datetime": format(start.Add(20 * time.Second)), "call-total-duration-seconds": 45},
+				{"id": "2", "call-term-user": "vmail_101", "call-orig-caller-id": 14155550000, "call-start-datetime": format(start.Add(10 * time.Minute)),
+					"call-answer-datetime": format(start.Add(10*time.Minute + 30*time.Second)), "call-total-duration-seconds": 35},
+				{"id": "3", "call-term-user": "vmail", "call-orig-to-user": "102", "call-start-datetime": format(start)},
+				{"id": "4", "call-term-user": "101", "call-start-datetime": format(start)},
+			})
+		case r.URL.Path == "/ns-api/v2/domains/acme/users/101/voicemails":
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"caller-id": "4155551234", "datetime": format(start.Add(40 * time.Second))},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	vs := NewVoicemailReportService(server.URL, "token", NewCDRDiscoveryService(server.URL, "token"))
+	report, err := vs.GetVoicemailReport("acme", "", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("GetVoicemailReport: %v", err)
+	}
+	if report.TotalCalls != 3 || len(report.Users) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	first, second := report.Users[0], report.Users[1]
+	if first.User != "101" || first.Total != 2 || first.MessagesLeft != 1 || !first.MailboxChecked || first.AvgTimeToVoicemail != 25 {
+		t.Errorf("unexpected report for 101: %+v", first)
+	}
+	if first.Calls[1].CDRID != "1" || !first.Calls[1].MessageLeft || first.Calls[0].MessageLeft {
+		t.Errorf("message matched to the wrong call: %+v", first.Calls)
+	}
+	if second.User != "102" || second.Total != 1 || second.MailboxChecked {
+		t.Errorf("unexpected report for 102: %+v", second)
+	}
+}
+
+func TestVoicemailUser(t *testing.T) {
+	tests := []struct {
+		fields map[string]interface{}
+		want   string
+	}{
+		{map[string]interface{}{"call-term-user": "vmail_101"}, "101"},
+		{map[string]interface{}{"call-term-user": "vmail", "call-orig-to-user": "102"}, "102"},
+		{map[string]interface{}{"call-term-user": "103", "call-term-application": "voicemail"}, "103"},
+		{map[string]interface{}{"call-term-user": "104"}, ""},
+	}
+	for _, tt := range tests {
+		if got := VoicemailUser(models.FlexibleCDR{RawData: tt.fields}); got != tt.want {
+			t.Errorf("VoicemailUser(%v) = %q, want %q", tt.fields, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// services/weather_provider.go
+// Current conditions for the weather IVR, cached per location
+
+package services
+
+import (
+	"math/rand"
+	"time"
+)
+
+// WeatherData structure
+type WeatherData struct {
+	Temperature int `json:"temperature"`
+}
+
+// WeatherProvider looks up current conditions for a location
+type WeatherProvider interface {
+	Name() string
+	GetWeather(lat, lon float64) (WeatherData, error)
+}
+
+// NewWeatherProvider builds the weather provider wrapped in a per-location cache
+func NewWeatherProvider(cacheTTL time.Duration, cachePrecision int) WeatherProvider {
+	// TODO: Replace with actual weather API provider
+	return NewCachedWeatherProvider(&SimulatedWeatherProvider{}, cacheTTL, cachePrecision)
+}
+
+// SimulatedWeatherProvider returns random conditions for development
+type SimulatedWeatherProvider struct{}
+
+func (sp *SimulatedWeatherProvider) Name() string { return "simulated" }
+
+// GetWeather returns a random temperature between 45 and 85°F
+func (sp *SimulatedWeatherProvider) GetWeather(lat, lon float64) (WeatherData, error) {
+	return WeatherData{
+		Temperature: rand.Intn(40) + 45,
+	}, nil
+}
+
+// CachedWeatherProvider caches conditions per location so a burst of calls costs one lookup
+type CachedWeatherProvider struct {
+	provider WeatherProvider
+	cache    *geoCache[WeatherData]
+}
+
+// NewCachedWeatherProvider wraps a provider with a per-location cache, rounding coordinates to precision decimal places
+func NewCachedWeatherProvider(provider WeatherProvider, ttl time.Duration, precision int) *CachedWeatherProvider {
+	return &CachedWeatherProvider{
+		provider: provider,
+		cache:    newGeoCache[WeatherData]("weather:"+provider.Name(), ttl, precision),
+	}
+}
+
+func (cp *CachedWeatherProvider) Name() string { return cp.provider.Name() }
+
+// GetWeather returns cached conditions for the location or fetches fresh ones
+func (cp *CachedWeatherProvider) GetWeather(lat, lon float64) (WeatherData, error) {
+	return cp.cache.Get(lat, lon, func() (WeatherData, error) {
+		return cp.provider.GetWeather(lat, lon)
+	})
+}
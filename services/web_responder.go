@@ -1,30 +1,116 @@
 package services
 
 import (
-	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"log"
-	"math/rand"
 	"net/http"
 	"o-dan-go/events"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/sessions"
 )
 
-// WebResponderService handles IVR functionality
+// IVRParams carries the call parameters NetSapiens sends with each Web Responder request
+type IVRParams struct {
+	App          string            // app the request was routed to
+	CallerNumber string            // NmsAni
+	DialedNumber string            // NmsDnis, the number the caller dialed
+	Digits       string            // DTMF digits gathered by the previous response (or resolved from speech)
+	Speech       string            // SpeechResult, when the caller spoke instead of pressing keys
+	Confidence   float64           // recognizer confidence for Speech, 0-1
+	Values       map[string]string // every query/form parameter, for app-specific fields
+	Timezone     string            // caller's timezone from their area code, "" if unknown
+
+	// Set when another app (e.g. the main menu) already started the call, so the
+	// dashboard keeps tracking it as one call instead of starting a new one
+	SessionID string
+	CallID    string
+}
+
+// HasInput reports whether the request answers a previous Gather or Record
+func (p IVRParams) HasInput() bool {
+	return p.Digits != "" || p.Values["RecordingUrl"] != ""
+}
+
+// IVRApp is a Web Responder application mounted at /wr/<Name()>
+type IVRApp interface {
+	Name() string
+	Handle(session *sessions.Session, params IVRParams) (Response, error)
+}
+
+// WebResponderService handles IVR functionality and keeps the registry of IVR apps
 type WebResponderService struct {
-	store *sessions.CookieStore
+	store  sessions.Store
+	mu     sync.RWMutex
+	apps   map[string]IVRApp
+	speech SpeechSettings
 }
 
-// NewWebResponderService creates a new Web Responder service
-func NewWebResponderService(sessionSecret string) *WebResponderService {
+// NewWebResponderService creates a new Web Responder service (see NewIVRSessionStore)
+func NewWebResponderService(store sessions.Store) *WebResponderService {
 	return &WebResponderService{
-		store: sessions.NewCookieStore([]byte(sessionSecret)),
+		store: store,
+		apps:  make(map[string]IVRApp),
+	}
+}
+
+// RegisterApp mounts an IVR app under /wr/<name>
+func (wr *WebResponderService) RegisterApp(app IVRApp) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	name := app.Name()
+	if name == "" {
+		return fmt.Errorf("IVR app name cannot be empty")
+	}
+	if _, exists := wr.apps[name]; exists {
+		return fmt.Errorf("IVR app %q is already registered", name)
+	}
+
+	wr.apps[name] = app
+	log.Printf("[WR] Registered IVR app: /wr/%s", name)
+	return nil
+}
+
+// MountApp mounts an IVR app under /wr/<name>, replacing any app already there.
+// Used for flow definitions, which may override a built-in app and are reloadable.
+func (wr *WebResponderService) MountApp(app IVRApp) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	if _, exists := wr.apps[app.Name()]; exists {
+		log.Printf("[WR] Replacing IVR app: /wr/%s", app.Name())
+	} else {
+		log.Printf("[WR] Mounted IVR app: /wr/%s", app.Name())
+	}
+	wr.apps[app.Name()] = app
+}
+
+// GetApp looks up a registered IVR app by name
+func (wr *WebResponderService) GetApp(name string) (IVRApp, bool) {
+	wr.mu.RLock()
+	defer wr.mu.RUnlock()
+
+	app, exists := wr.apps[name]
+	return app, exists
+}
+
+// AppNames returns the names of registered IVR apps, sorted
+func (wr *WebResponderService) AppNames() []string {
+	wr.mu.RLock()
+	defer wr.mu.RUnlock()
+
+	names := make([]string, 0, len(wr.apps))
+	for name := range wr.apps {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	return names
 }
 
 // XML Response structures for NetSapiens
@@ -37,15 +123,29 @@ type Say struct {
 	XMLName  xml.Name `xml:"Say"`
 	Voice    string   `xml:"voice,attr,omitempty"`
 	Language string   `xml:"language,attr,omitempty"`
+	Rate     string   `xml:"rate,attr,omitempty"`
 	Text     string   `xml:",chardata"`
 }
 
+// Play streams a pre-recorded audio file (WAV or MP3) from URL
+type Play struct {
+	XMLName xml.Name `xml:"Play"`
+	Loop    string   `xml:"loop,attr,omitempty"`
+	URL     string   `xml:",chardata"`
+}
+
 type Gather struct {
 	XMLName   xml.Name `xml:"Gather"`
 	NumDigits string   `xml:"numDigits,attr"`
 	Action    string   `xml:"action,attr"`
 	Timeout   string   `xml:"timeout,attr,omitempty"`
+	Input     string   `xml:"input,attr,omitempty"` // "dtmf speech" when speech input is enabled
+	Hints     string   `xml:"hints,attr,omitempty"` // expected phrases, to help the recognizer
 	Actions   []interface{}
+
+	// SpeechChoices maps digits to the words a caller can say instead (e.g. "1": {"time"}).
+	// Not rendered; PrepareSpeech turns them into Input/Hints.
+	SpeechChoices map[string][]string `xml:"-"`
 }
 
 type Wait struct {
@@ -57,6 +157,53 @@ type Hangup struct {
 	XMLName xml.Name `xml:"Hangup"`
 }
 
+// Record captures caller audio, then requests Action with RecordingUrl and RecordingDuration
+type Record struct {
+	XMLName                 xml.Name `xml:"Record"`
+	Action                  string   `xml:"action,attr,omitempty"`
+	MaxLength               string   `xml:"maxLength,attr,omitempty"`   // seconds
+	Timeout                 string   `xml:"timeout,attr,omitempty"`     // seconds of silence that end the recording
+	FinishOnKey             string   `xml:"finishOnKey,attr,omitempty"` // e.g. "#"
+	PlayBeep                string   `xml:"playBeep,attr,omitempty"`
+	RecordingStatusCallback string   `xml:"recordingStatusCallback,attr,omitempty"`
+}
+
+// Dial connects the caller to a number, queue or extension, ending the IVR when answered
+type Dial struct {
+	XMLName  xml.Name      `xml:"Dial"`
+	Action   string        `xml:"action,attr,omitempty"` // URL requested when the dialed party hangs up or doesn't answer
+	Timeout  string        `xml:"timeout,attr,omitempty"`
+	CallerID string        `xml:"callerId,attr,omitempty"`
+	Targets  []interface{} // DialNumber, DialQueue, DialExtension
+}
+
+type DialNumber struct {
+	XMLName xml.Name `xml:"Number"`
+	Number  string   `xml:",chardata"`
+}
+
+type DialQueue struct {
+	XMLName xml.Name `xml:"Queue"`
+	Queue   string   `xml:",chardata"`
+}
+
+type DialExtension struct {
+	XMLName   xml.Name `xml:"Extension"`
+	Extension string   `xml:",chardata"`
+}
+
+// Forward hands the call to any destination in the NetSapiens dial plan
+type Forward struct {
+	XMLName     xml.Name `xml:"Forward"`
+	Destination string   `xml:",chardata"`
+}
+
+// Transfer blind-transfers the call to a user or extension on the same domain
+type Transfer struct {
+	XMLName     xml.Name `xml:"Transfer"`
+	Destination string   `xml:",chardata"`
+}
+
 // Location data structure
 type Location struct {
 	City     string  `json:"city"`
@@ -66,14 +213,8 @@ type Location struct {
 	Timezone string  `json:"timezone"`
 }
 
-// WeatherData structure
-type WeatherData struct {
-	Temperature int `json:"temperature"`
-	AQI         int `json:"aqi"`
-}
-
 // ExtractAreaCode extracts area code from phone number
-func (wr *WebResponderService) ExtractAreaCode(phoneNumber string) string {
+func ExtractAreaCode(phoneNumber string) string {
 	// Remove all non-digits
 	re := regexp.MustCompile(`[^0-9]`)
 	cleaned := re.ReplaceAllString(phoneNumber, "")
@@ -91,49 +232,6 @@ func (wr *WebResponderService) ExtractAreaCode(phoneNumber string) string {
 	return ""
 }
 
-// GetLocationFromAreaCode looks up location by area code
-func (wr *WebResponderService) GetLocationFromAreaCode(areaCode string) (Location, bool) {
-	location, exists := CompleteAreaCodes[areaCode]
-	return location, exists
-}
-
-// GetWeatherData fetches weather for location (simulated for now)
-func (wr *WebResponderService) GetWeatherData(lat, lon float64) WeatherData {
-	// TODO: Replace with actual weather API call
-	// For now, return simulated data
-	rand.Seed(time.Now().UnixNano())
-	return WeatherData{
-		Temperature: rand.Intn(40) + 45,  // 45-85°F
-		AQI:         rand.Intn(130) + 20, // 20-150
-	}
-}
-
-// GetLocalTime returns local time for timezone
-func (wr *WebResponderService) GetLocalTime(timezone string) string {
-	loc, err := time.LoadLocation(timezone)
-	if err != nil {
-		log.Printf("Error loading timezone %s: %v", timezone, err)
-		return "unknown"
-	}
-
-	now := time.Now().In(loc)
-	return now.Format("3:04 PM")
-}
-
-// GetAQIDescription returns human-readable AQI description
-func (wr *WebResponderService) GetAQIDescription(aqi int) string {
-	switch {
-	case aqi <= 50:
-		return "Good. Air quality is satisfactory."
-	case aqi <= 100:
-		return "Moderate. Air quality is acceptable for most people."
-	case aqi <= 150:
-		return "Unhealthy for sensitive groups."
-	default:
-		return "Unhealthy. Everyone may experience health effects."
-	}
-}
-
 // GenerateXMLResponse converts Response struct to XML string
 func (wr *WebResponderService) GenerateXMLResponse(response Response) string {
 	output, err := xml.MarshalIndent(response, "", "  ")
@@ -144,307 +242,31 @@ func (wr *WebResponderService) GenerateXMLResponse(response Response) string {
 	return xml.Header + string(output)
 }
 
-// ProcessWeatherIVR processes the main weather IVR logic with event logging
-func (wr *WebResponderService) ProcessWeatherIVR(session *sessions.Session, callerNumber string, digits string) (string, error) {
-	// First call - no digits pressed
-	if digits == "" {
-		log.Printf("[WR] New call from: %s", callerNumber)
-
-		areaCode := wr.ExtractAreaCode(callerNumber)
-		if areaCode == "" {
-			log.Printf("[WR] Could not extract area code from: %s", callerNumber)
-
-			// Send error event
-			events.SendEvent(events.CallEvent{
-				SessionID: "error",
-				CallID:    fmt.Sprintf("call_%d", time.Now().Unix()),
-				CallerNum: callerNumber,
-				AreaCode:  "Unknown",
-				Location:  "Unknown",
-				EventType: "error",
-				Details:   "Could not extract area code",
-				Timestamp: time.Now(),
-			})
-
-			response := Response{
-				Actions: []interface{}{
-					Say{
-						Voice:    "female",
-						Language: "en-US",
-						Text:     "I'm sorry, I couldn't identify your area code. Please try calling from a valid US phone number. Goodbye!",
-					},
-					Hangup{},
-				},
-			}
-			return wr.GenerateXMLResponse(response), nil
-		}
-
-		location, exists := wr.GetLocationFromAreaCode(areaCode)
-		if !exists {
-			log.Printf("[WR] Area code not found: %s", areaCode)
-
-			// Send error event
-			events.SendEvent(events.CallEvent{
-				SessionID: "error",
-				CallID:    fmt.Sprintf("call_%d", time.Now().Unix()),
-				CallerNum: callerNumber,
-				AreaCode:  areaCode,
-				Location:  "Unknown",
-				EventType: "error",
-				Details:   fmt.Sprintf("Area code %s not in database", areaCode),
-				Timestamp: time.Now(),
-			})
-
-			response := Response{
-				Actions: []interface{}{
-					Say{
-						Voice:    "female",
-						Language: "en-US",
-						Text:     fmt.Sprintf("I'm sorry, I couldn't identify the location for area code %s. This service may not be available for your area yet. Goodbye!", areaCode),
-					},
-					Hangup{},
-				},
-			}
-			return wr.GenerateXMLResponse(response), nil
-		}
-
-		log.Printf("[WR] Location identified: %s, %s", location.City, location.State)
-
-		// Generate session ID and call ID
-		sessionID := fmt.Sprintf("wr_%s_%d", areaCode, time.Now().Unix())
-		callID := fmt.Sprintf("call_%d", time.Now().Unix())
-
-		// Store in session
-		session.Values["session_id"] = sessionID
-		session.Values["call_id"] = callID
-
-		// Send call started event
-		events.SendEvent(events.CallEvent{
-			SessionID: sessionID,
-			CallID:    callID,
-			CallerNum: callerNumber,
-			AreaCode:  areaCode,
-			Location:  fmt.Sprintf("%s, %s", location.City, location.State),
-			EventType: "call_started",
-			Details:   "New incoming call",
-			Timestamp: time.Now(),
-		})
-
-		// Store location in session
-		locationJSON, _ := json.Marshal(location)
-		session.Values["location_json"] = string(locationJSON)
-		session.Values["area_code"] = areaCode
-
-		// Build welcome message with menu
-		cityState := fmt.Sprintf("%s, %s", location.City, location.State)
-
-		gatherAction := Gather{
-			NumDigits: "1",
-			Action:    "/wr/weather",
-			Timeout:   "10",
-			Actions: []interface{}{
-				Say{
-					Voice:    "female",
-					Language: "en-US",
-					Text:     fmt.Sprintf("For the current local time in %s, press 1. For the current temperature, press 2. For the air quality index, press 3.", location.City),
-				},
-			},
-		}
-
-		response := Response{
-			Actions: []interface{}{
-				Say{
-					Voice:    "female",
-					Language: "en-US",
-					Text:     fmt.Sprintf("Welcome! I've detected you're calling from area code %s, which covers %s.", areaCode, cityState),
-				},
-				gatherAction,
-				Say{
-					Voice:    "female",
-					Language: "en-US",
-					Text:     "I didn't receive your selection. Goodbye!",
-				},
-			},
-		}
-
-		return wr.GenerateXMLResponse(response), nil
-	}
-
-	// Handle menu selection
-	log.Printf("[WR] DTMF received: %s", digits)
-
-	// Get session data
-	callID, _ := session.Values["call_id"].(string)
-	sessionID, _ := session.Values["session_id"].(string)
-	areaCode, _ := session.Values["area_code"].(string)
-
-	// Send DTMF event
-	events.SendEvent(events.CallEvent{
-		SessionID: sessionID,
-		CallID:    callID,
-		CallerNum: callerNumber,
-		AreaCode:  areaCode,
-		Location:  "", // Will be filled from session
-		EventType: "dtmf_received",
-		Details:   fmt.Sprintf("Pressed %s", digits),
-		Timestamp: time.Now(),
-	})
+// GetSession retrieves or creates a session
+func (wr *WebResponderService) GetSession(r *http.Request, name string) (*sessions.Session, error) {
+	return wr.store.Get(r, name)
+}
 
-	locationJSON, ok := session.Values["location_json"].(string)
-	if !ok {
-		log.Printf("[WR] No location in session")
-
-		// Send error event
-		events.SendEvent(events.CallEvent{
-			SessionID: sessionID,
-			CallID:    callID,
-			CallerNum: callerNumber,
-			AreaCode:  areaCode,
-			Location:  "Unknown",
-			EventType: "error",
-			Details:   "Session expired",
-			Timestamp: time.Now(),
-		})
-
-		response := Response{
-			Actions: []interface{}{
-				Say{
-					Voice:    "female",
-					Language: "en-US",
-					Text:     "I'm sorry, there was an error processing your request. Please try again.",
-				},
-				Hangup{},
-			},
-		}
-		return wr.GenerateXMLResponse(response), nil
+// sendCallEvent sends a dashboard event for an IVR app, deriving area code and location from the caller's number
+func sendCallEvent(app, sessionID, callID, callerNumber, eventType, details string) {
+	areaCode := ExtractAreaCode(callerNumber)
+	location := "Unknown"
+	if loc, exists := GlobalAreaCodes.Lookup(areaCode); exists {
+		location = fmt.Sprintf("%s, %s", loc.City, loc.State)
 	}
-
-	var location Location
-	json.Unmarshal([]byte(locationJSON), &location)
-
-	var responseText string
-	var actionDetail string
-
-	switch digits {
-	case "1":
-		log.Printf("[WR] User selected: Local Time")
-		localTime := wr.GetLocalTime(location.Timezone)
-		responseText = fmt.Sprintf("The current time in %s, %s is %s.",
-			location.City, location.State, localTime)
-		actionDetail = fmt.Sprintf("Local time: %s", localTime)
-
-	case "2":
-		log.Printf("[WR] User selected: Temperature")
-		weather := wr.GetWeatherData(location.Lat, location.Lon)
-		responseText = fmt.Sprintf("The current temperature in %s, %s is %d degrees Fahrenheit.",
-			location.City, location.State, weather.Temperature)
-		actionDetail = fmt.Sprintf("Temperature: %d°F", weather.Temperature)
-
-	case "3":
-		log.Printf("[WR] User selected: Air Quality")
-		weather := wr.GetWeatherData(location.Lat, location.Lon)
-		aqiDescription := wr.GetAQIDescription(weather.AQI)
-		responseText = fmt.Sprintf("The current Air Quality Index in %s, %s is %d. This is considered %s",
-			location.City, location.State, weather.AQI, aqiDescription)
-		actionDetail = fmt.Sprintf("AQI: %d (%s)", weather.AQI, aqiDescription)
-
-	default:
-		log.Printf("[WR] Invalid selection: %s", digits)
-		actionDetail = "Invalid selection"
-
-		// Send invalid selection event
-		events.SendEvent(events.CallEvent{
-			SessionID: sessionID,
-			CallID:    callID,
-			CallerNum: callerNumber,
-			AreaCode:  areaCode,
-			Location:  fmt.Sprintf("%s, %s", location.City, location.State),
-			EventType: "invalid_selection",
-			Details:   fmt.Sprintf("Invalid digit: %s", digits),
-			Timestamp: time.Now(),
-		})
-
-		// Re-present menu
-		gatherAction := Gather{
-			NumDigits: "1",
-			Action:    "/wr/weather",
-			Timeout:   "10",
-			Actions: []interface{}{
-				Say{
-					Voice:    "female",
-					Language: "en-US",
-					Text:     fmt.Sprintf("For the current local time in %s, press 1. For the current temperature, press 2. For the air quality index, press 3.", location.City),
-				},
-			},
-		}
-
-		response := Response{
-			Actions: []interface{}{
-				Say{
-					Voice:    "female",
-					Language: "en-US",
-					Text:     "Invalid selection. Let me repeat the options.",
-				},
-				gatherAction,
-				Say{
-					Voice:    "female",
-					Language: "en-US",
-					Text:     "I didn't receive your selection. Goodbye!",
-				},
-				Hangup{},
-			},
-		}
-
-		return wr.GenerateXMLResponse(response), nil
+	if areaCode == "" {
+		areaCode = "Unknown"
 	}
 
-	// Send response event for valid selections
 	events.SendEvent(events.CallEvent{
+		App:       app,
 		SessionID: sessionID,
 		CallID:    callID,
 		CallerNum: callerNumber,
 		AreaCode:  areaCode,
-		Location:  fmt.Sprintf("%s, %s", location.City, location.State),
-		EventType: "response_sent",
-		Details:   actionDetail,
+		Location:  location,
+		EventType: eventType,
+		Details:   details,
 		Timestamp: time.Now(),
 	})
-
-	// Send call ending event
-	events.SendEvent(events.CallEvent{
-		SessionID: sessionID,
-		CallID:    callID,
-		CallerNum: callerNumber,
-		AreaCode:  areaCode,
-		Location:  fmt.Sprintf("%s, %s", location.City, location.State),
-		EventType: "call_ended",
-		Details:   "Call completed successfully",
-		Timestamp: time.Now(),
-	})
-
-	// Send response for valid selections
-	response := Response{
-		Actions: []interface{}{
-			Say{
-				Voice:    "female",
-				Language: "en-US",
-				Text:     responseText,
-			},
-			Wait{Timeout: "1"},
-			Say{
-				Voice:    "female",
-				Language: "en-US",
-				Text:     "Thank you for calling. Goodbye!",
-			},
-			Hangup{},
-		},
-	}
-
-	log.Printf("[WR] Sending response: %s", responseText)
-	return wr.GenerateXMLResponse(response), nil
-}
-
-// GetSession retrieves or creates a session
-func (wr *WebResponderService) GetSession(r *http.Request, name string) (*sessions.Session, error) {
-	return wr.store.Get(r, name)
 }
@@ -0,0 +1,353 @@
+// services/wr_analytics.go
+// Durable Web Responder call analytics, recorded from the dashboard's call events
+
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"o-dan-go/events"
+)
+
+// SimulatedCallPrefix starts the call ID of every simulated call, which analytics ignores
+const SimulatedCallPrefix = "sim_"
+
+// Call outcomes
+const (
+	CallOutcomeInProgress  = "in_progress"
+	CallOutcomeCompleted   = "completed"
+	CallOutcomeTransferred = "transferred"
+)
+
+// WRCall is one Web Responder call
+type WRCall struct {
+	CallID            string     `json:"call_id"`
+	SessionID         string     `json:"session_id"`
+	CallerNumber      string     `json:"caller_number"`
+	AreaCode          string     `json:"area_code"`
+	Location          string     `json:"location"`
+	EntryApp          string     `json:"entry_app"`
+	Selections        int        `json:"selections"`
+	InvalidSelections int        `json:"invalid_selections"`
+	Errors            int        `json:"errors"`
+	LastAction        string     `json:"last_action"`
+	Outcome           string     `json:"outcome"`
+	StartedAt         time.Time  `json:"started_at"`
+	EndedAt           *time.Time `json:"ended_at,omitempty"`
+	DurationSeconds   int        `json:"duration_seconds"`
+}
+
+// CallVolumePoint is the call count for one hour or day
+type CallVolumePoint struct {
+	Period             string  `json:"period"`
+	Calls              int     `json:"calls"`
+	Completed          int     `json:"completed"`
+	Transferred        int     `json:"transferred"`
+	WithErrors         int     `json:"with_errors"`
+	AvgDurationSeconds float64 `json:"avg_duration_seconds"`
+}
+
+// OptionPopularity counts how often a digit was pressed in an app's menus
+type OptionPopularity struct {
+	App        string  `json:"app"`
+	Digits     string  `json:"digits"`
+	Selections int     `json:"selections"`
+	Share      float64 `json:"share"` // fraction of the app's selections
+}
+
+// WRAnalyticsService records every Web Responder call and its menu selections
+type WRAnalyticsService struct {
+	db *DatabaseService
+}
+
+// NewWRAnalyticsService creates the analytics service
+func NewWRAnalyticsService(db *DatabaseService) *WRAnalyticsService {
+	return &WRAnalyticsService{db: db}
+}
+
+// Start records events from the event manager in the background
+func (was *WRAnalyticsService) Start(manager *events.EventManager) {
+	listener := manager.Subscribe()
+	go func() {
+		for event := range listener {
+			// Each instance persists its own calls; events from other instances are only shown live
+			if event.Remote {
+				continue
+			}
+			if err := was.SaveEvent(event); err != nil {
+				log.Printf("[Analytics] Failed to save %s event: %v", event.EventType, err)
+			}
+			if err := was.RecordEvent(event); err != nil {
+				log.Printf("[Analytics] Failed to record %s for %s: %v", event.EventType, event.CallID, err)
+			}
+		}
+	}()
+}
+
+// RecordEvent updates the call's row for one event
+func (was *WRAnalyticsService) RecordEvent(event events.CallEvent) error {
+	// PBX and click-to-call calls are shown live but aren't IVR calls; their history is in the CDRs
+	if event.CallID == "" || strings.HasPrefix(event.CallID, SimulatedCallPrefix) || event.App == PBXEventsApp || event.App == ClickToCallApp {
+		return nil
+	}
+
+	timestamp := event.Timestamp.UTC()
+	if event.Timestamp.IsZero() {
+		timestamp = time.Now().UTC()
+	}
+
+	// Every event creates the call if it's new, so calls are recorded even if call_started was dropped
+	_, err := was.db.db.Exec(`
+	INSERT OR IGNORE INTO wr_calls (call_id, session_id, caller_number, area_code, location, entry_app, outcome, started_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.CallID, event.SessionID, event.CallerNum, event.AreaCode, event.Location, event.App,
+		CallOutcomeInProgress, timestamp,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Fill in the location once an app has worked it out
+	if event.Location != "" && event.Location != "Unknown" {
+		_, err = was.db.db.Exec(`
+		UPDATE wr_calls SET area_code = ?, location = ?
+		WHERE call_id = ? AND (location IS NULL OR location IN ('', 'Unknown'))`,
+			event.AreaCode, event.Location, event.CallID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch event.EventType {
+	case "dtmf_received":
+		digits := strings.TrimPrefix(event.Details, "Pressed ")
+		_, err = was.db.db.Exec(`INSERT INTO wr_call_selections (call_id, app, digits, created_at) VALUES (?, ?, ?, ?)`,
+			event.CallID, event.App, digits, timestamp)
+		if err == nil {
+			err = was.update(event.CallID, `selections = selections + 1, last_action = ?`, event.Details)
+		}
+
+	case "invalid_selection":
+		err = was.update(event.CallID, `invalid_selections = invalid_selections + 1`)
+
+	case "app_selected", "response_sent":
+		err = was.update(event.CallID, `last_action = ?`, event.Details)
+
+	case "error":
+		err = was.update(event.CallID, `errors = errors + 1, last_action = ?`, event.Details)
+
+	case "call_ended":
+		err = was.end(event.CallID, CallOutcomeCompleted, timestamp)
+
+	case "call_transferred":
+		err = was.end(event.CallID, CallOutcomeTransferred, timestamp)
+	}
+
+	return err
+}
+
+func (was *WRAnalyticsService) update(callID, set string, args ...interface{}) error {
+	_, err := was.db.db.Exec("UPDATE wr_calls SET "+set+" WHERE call_id = ?", append(args, callID)...)
+	return err
+}
+
+// end records how and when a call finished; the first ending wins
+func (was *WRAnalyticsService) end(callID, outcome string, endedAt time.Time) error {
+	var startedAt time.Time
+	err := was.db.db.QueryRow(`SELECT started_at FROM wr_calls WHERE call_id = ?`, callID).Scan(&startedAt)
+	if err != nil {
+		return err
+	}
+
+	duration := int(endedAt.Sub(startedAt).Seconds())
+	if duration < 0 {
+		duration = 0
+	}
+
+	_, err = was.db.db.Exec(`
+	UPDATE wr_calls SET outcome = ?, ended_at = ?, duration_seconds = ?
+	WHERE call_id = ? AND ended_at IS NULL`,
+		outcome, endedAt, duration, callID,
+	)
+	return err
+}
+
+// RecentCalls returns the latest calls, newest first
+func (was *WRAnalyticsService) RecentCalls(limit int) ([]WRCall, error) {
+	rows, err := was.db.db.Query(`
+	SELECT call_id, COALESCE(session_id, ''), COALESCE(caller_number, ''), COALESCE(area_code, ''),
+		COALESCE(location, ''), COALESCE(entry_app, ''), selections, invalid_selections, errors,
+		COALESCE(last_action, ''), outcome, started_at, ended_at, duration_seconds
+	FROM wr_calls ORDER BY started_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	calls := []WRCall{}
+	for rows.Next() {
+		var call WRCall
+		var endedAt sql.NullTime
+		err := rows.Scan(
+			&call.CallID, &call.SessionID, &call.CallerNumber, &call.AreaCode,
+			&call.Location, &call.EntryApp, &call.Selections, &call.InvalidSelections, &call.Errors,
+			&call.LastAction, &call.Outcome, &call.StartedAt, &endedAt, &call.DurationSeconds,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if endedAt.Valid {
+			call.EndedAt = &endedAt.Time
+		}
+		calls = append(calls, call)
+	}
+
+	return calls, nil
+}
+
+// CallVolume counts calls per hour or day between from and to (UTC)
+func (was *WRAnalyticsService) CallVolume(from, to time.Time, interval string) ([]CallVolumePoint, error) {
+	var format string
+	switch interval {
+	case "hour":
+		format = "%Y-%m-%d %H:00"
+	case "", "day":
+		format = "%Y-%m-%d"
+	default:
+		return nil, fmt.Errorf("invalid interval %q (expected hour or day)", interval)
+	}
+
+	rows, err := was.db.db.Query(`
+	SELECT strftime(?, started_at) AS period,
+		COUNT(*),
+		SUM(outcome = 'completed'),
+		SUM(outcome = 'transferred'),
+		SUM(errors > 0),
+		COALESCE(AVG(CASE WHEN ended_at IS NOT NULL THEN duration_seconds END), 0)
+	FROM wr_calls
+	WHERE started_at >= ? AND started_at < ?
+	GROUP BY period ORDER BY period`,
+		format, from.UTC(), to.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []CallVolumePoint{}
+	for rows.Next() {
+		var point CallVolumePoint
+		err := rows.Scan(&point.Period, &point.Calls, &point.Completed, &point.Transferred,
+			&point.WithErrors, &point.AvgDurationSeconds)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+
+	return points, nil
+}
+
+// MenuPopularity ranks the digits pressed in each app's menus between from and to,
+// optionally for a single app
+func (was *WRAnalyticsService) MenuPopularity(from, to time.Time, app string) ([]OptionPopularity, error) {
+	query := `
+	SELECT app, digits, COUNT(*) AS selections
+	FROM wr_call_selections
+	WHERE created_at >= ? AND created_at < ?`
+	args := []interface{}{from.UTC(), to.UTC()}
+
+	if app != "" {
+		query += " AND app = ?"
+		args = append(args, app)
+	}
+	query += " GROUP BY app, digits ORDER BY app, selections DESC, digits"
+
+	rows, err := was.db.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := []OptionPopularity{}
+	totals := make(map[string]int)
+	for rows.Next() {
+		var option OptionPopularity
+		if err := rows.Scan(&option.App, &option.Digits, &option.Selections); err != nil {
+			return nil, err
+		}
+		totals[option.App] += option.Selections
+		options = append(options, option)
+	}
+
+	for i := range options {
+		options[i].Share = float64(options[i].Selections) / float64(totals[options[i].App])
+	}
+
+	return options, nil
+}
+
+// WRStats summarizes one day of Web Responder calls for the dashboard's summary cards
+type WRStats struct {
+	Date               string             `json:"date"`
+	Calls              int                `json:"calls"`
+	Completed          int                `json:"completed"`
+	Transferred        int                `json:"transferred"`
+	InProgress         int                `json:"in_progress"`
+	AvgDurationSeconds float64            `json:"avg_duration_seconds"`
+	DTMFDistribution   []OptionPopularity `json:"dtmf_distribution"`
+	Failures           WRFailureCounts    `json:"failures"`
+}
+
+// WRFailureCounts counts things that went wrong for callers
+type WRFailureCounts struct {
+	InvalidSelections int `json:"invalid_selections"`
+	Errors            int `json:"errors"`
+	CallsWithErrors   int `json:"calls_with_errors"`
+}
+
+// Stats summarizes calls that started on day, in day's time zone
+func (was *WRAnalyticsService) Stats(day time.Time) (WRStats, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	to := from.AddDate(0, 0, 1)
+	stats := WRStats{Date: from.Format("2006-01-02")}
+
+	err := was.db.db.QueryRow(`
+	SELECT COUNT(*),
+		COALESCE(SUM(outcome = 'completed'), 0),
+		COALESCE(SUM(outcome = 'transferred'), 0),
+		COALESCE(SUM(outcome = 'in_progress'), 0),
+		COALESCE(AVG(CASE WHEN ended_at IS NOT NULL THEN duration_seconds END), 0),
+		COALESCE(SUM(errors > 0), 0)
+	FROM wr_calls
+	WHERE started_at >= ? AND started_at < ?`,
+		from.UTC(), to.UTC(),
+	).Scan(&stats.Calls, &stats.Completed, &stats.Transferred, &stats.InProgress,
+		&stats.AvgDurationSeconds, &stats.Failures.CallsWithErrors)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count calls: %w", err)
+	}
+
+	// Count failures from the event history, since a call's counters only cover recorded calls
+	err = was.db.db.QueryRow(`
+	SELECT COALESCE(SUM(event_type = 'invalid_selection'), 0), COALESCE(SUM(event_type = 'error'), 0)
+	FROM wr_events
+	WHERE created_at >= ? AND created_at < ? AND substr(COALESCE(call_id, ''), 1, ?) != ?`,
+		from.UTC(), to.UTC(), len(SimulatedCallPrefix), SimulatedCallPrefix,
+	).Scan(&stats.Failures.InvalidSelections, &stats.Failures.Errors)
+	if err != nil {
+		return stats, fmt.Errorf("failed to count failures: %w", err)
+	}
+
+	stats.DTMFDistribution, err = was.MenuPopularity(from, to, "")
+	if err != nil {
+		return stats, fmt.Errorf("failed to load DTMF distribution: %w", err)
+	}
+
+	return stats, nil
+}
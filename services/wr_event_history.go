@@ -0,0 +1,156 @@
+// services/wr_event_history.go
+// Web Responder event history, so the dashboard shows past events after a refresh
+
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"o-dan-go/events"
+)
+
+// EventQuery selects a page of event history. Empty filters and zero times match everything.
+type EventQuery struct {
+	From         time.Time
+	To           time.Time
+	AreaCode     string
+	EventType    string
+	CallerPrefix string // digits the caller number starts with, with or without the leading 1
+	Limit        int
+	Offset       int
+}
+
+// Matches reports whether an event passes the query's filters
+func (q EventQuery) Matches(event events.CallEvent) bool {
+	if !q.From.IsZero() && event.Timestamp.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !event.Timestamp.Before(q.To) {
+		return false
+	}
+	if q.EventType != "" && event.EventType != q.EventType {
+		return false
+	}
+	return q.MatchesCaller(event.AreaCode, event.CallerNum)
+}
+
+// MatchesCaller applies the area code and caller prefix filters, which also apply to active calls
+func (q EventQuery) MatchesCaller(areaCode, callerNumber string) bool {
+	if q.AreaCode != "" && areaCode != q.AreaCode {
+		return false
+	}
+	return q.CallerPrefix == "" || callerHasPrefix(callerNumber, q.CallerPrefix)
+}
+
+// callerDigitsSQL strips the punctuation PBX caller IDs are sent with, like +1 (415) 555-1234
+const callerDigitsSQL = `REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(caller_number, '+', ''), '-', ''), ' ', ''), '(', ''), ')', ''), '.', '')`
+
+// callerHasPrefix matches the caller's digits against the prefix as typed, or both normalized
+// to 10-digit form, so 1415 finds +14155551234 and 415 finds 14155551234.
+// Events builds the same match in SQL.
+func callerHasPrefix(callerNumber, prefix string) bool {
+	digits := nonDigits.ReplaceAllString(callerNumber, "")
+	if strings.HasPrefix(digits, prefix) {
+		return true
+	}
+	normalized := NormalizePhoneNumber(digits)
+	return normalized != "" && strings.HasPrefix(normalized, normalizePhonePrefix(prefix))
+}
+
+// normalizePhonePrefix drops the country code from a full or partial NANP number.
+// Area codes never start with 1, so a leading 1 is always the country code.
+func normalizePhonePrefix(prefix string) string {
+	if normalized := NormalizePhoneNumber(prefix); normalized != "" {
+		return normalized
+	}
+	return strings.TrimPrefix(nonDigits.ReplaceAllString(prefix, ""), "1")
+}
+
+// SaveEvent appends a call event to the history, including simulated calls
+func (was *WRAnalyticsService) SaveEvent(event events.CallEvent) error {
+	timestamp := event.Timestamp.UTC()
+	if event.Timestamp.IsZero() {
+		timestamp = time.Now().UTC()
+	}
+
+	_, err := was.db.db.Exec(`
+	INSERT INTO wr_events (app, session_id, call_id, caller_number, area_code, location, event_type, details, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.App, event.SessionID, event.CallID, event.CallerNum, event.AreaCode, event.Location,
+		event.EventType, event.Details, timestamp,
+	)
+	return err
+}
+
+// PruneWREvents deletes the events recorded before a time and returns how many were deleted
+func (ds *DatabaseService) PruneWREvents(before time.Time) (int64, error) {
+	result, err := ds.db.Exec("DELETE FROM wr_events WHERE created_at < ?", before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune Web Responder events: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// Events returns a page of event history, newest first, and the number of events matching the query
+func (was *WRAnalyticsService) Events(query EventQuery) ([]events.CallEvent, int, error) {
+	where := " WHERE 1=1"
+	args := []interface{}{}
+	if !query.From.IsZero() {
+		where += " AND created_at >= ?"
+		args = append(args, query.From.UTC())
+	}
+	if !query.To.IsZero() {
+		where += " AND created_at < ?"
+		args = append(args, query.To.UTC())
+	}
+	if query.AreaCode != "" {
+		where += " AND area_code = ?"
+		args = append(args, query.AreaCode)
+	}
+	if query.EventType != "" {
+		where += " AND event_type = ?"
+		args = append(args, query.EventType)
+	}
+	if query.CallerPrefix != "" {
+		digits := callerDigitsSQL
+		normalized := normalizePhonePrefix(query.CallerPrefix)
+		where += " AND (" + digits + " LIKE ?" +
+			" OR (length(" + digits + ") = 10 AND " + digits + " LIKE ?)" +
+			" OR (length(" + digits + ") = 11 AND " + digits + " LIKE ?))"
+		args = append(args, query.CallerPrefix+"%", normalized+"%", "1"+normalized+"%")
+	}
+
+	var total int
+	if err := was.db.db.QueryRow("SELECT COUNT(*) FROM wr_events"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := was.db.db.Query(`
+	SELECT COALESCE(app, ''), COALESCE(session_id, ''), COALESCE(call_id, ''), COALESCE(caller_number, ''),
+		COALESCE(area_code, ''), COALESCE(location, ''), event_type, COALESCE(details, ''), created_at
+	FROM wr_events`+where+`
+	ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?`,
+		append(args, query.Limit, query.Offset)...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	history := []events.CallEvent{}
+	for rows.Next() {
+		var event events.CallEvent
+		err := rows.Scan(
+			&event.App, &event.SessionID, &event.CallID, &event.CallerNum,
+			&event.AreaCode, &event.Location, &event.EventType, &event.Details, &event.Timestamp,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+		history = append(history, event)
+	}
+
+	return history, total, rows.Err()
+}
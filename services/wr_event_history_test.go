@@ -0,0 +1,83 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"o-dan-go/events"
+)
+
+func TestEventQueryMatches(t *testing.T) {
+	now := time.Now()
+	event := events.CallEvent{
+		CallerNum: "4155551234",
+		AreaCode:  "415",
+		EventType: "dtmf_received",
+		Timestamp: now,
+	}
+
+	tests := []struct {
+		name  string
+		query EventQuery
+		want  bool
+	}{
+		{"no filters", EventQuery{}, true},
+		{"area code", EventQuery{AreaCode: "415"}, true},
+		{"other area code", EventQuery{AreaCode: "212"}, false},
+		{"caller prefix", EventQuery{CallerPrefix: "415555"}, true},
+		{"other caller prefix", EventQuery{CallerPrefix: "4156"}, false},
+		{"caller prefix with country code", EventQuery{CallerPrefix: "1415"}, true},
+		{"full number with country code", EventQuery{CallerPrefix: "14155551234"}, true},
+		{"other prefix with country code", EventQuery{CallerPrefix: "1212"}, false},
+		{"event type", EventQuery{EventType: "call_started"}, false},
+		{"in range", EventQuery{From: now.Add(-time.Minute), To: now.Add(time.Minute)}, true},
+		{"to is exclusive", EventQuery{To: now}, false},
+		{"before range", EventQuery{From: now.Add(time.Second)}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.query.Matches(event); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEventsCallerPrefixNormalized(t *testing.T) {
+	db, err := NewDatabaseService(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatalf("NewDatabaseService: %v", err)
+	}
+	defer db.Close()
+
+	analytics := NewWRAnalyticsService(db)
+	for _, caller := range []string{"4155551234", "+14155559876", "1 (415) 555-0000", "2125551234", "1415"} {
+		if err := analytics.SaveEvent(events.CallEvent{CallerNum: caller, EventType: "call_started"}); err != nil {
+			t.Fatalf("SaveEvent: %v", err)
+		}
+	}
+
+	tests := []struct {
+		prefix string
+		want   int
+	}{
+		{"415", 3},
+		{"1415", 4}, // the four-digit caller matches as typed
+		{"14155551234", 1},
+		{"212", 1},
+		{"1212", 1},
+	}
+	for _, tt := range tests {
+		query := EventQuery{CallerPrefix: tt.prefix, Limit: 10}
+		history, total, err := analytics.Events(query)
+		if err != nil || total != tt.want {
+			t.Errorf("prefix %s: total = %d, %v, want %d", tt.prefix, total, err, tt.want)
+			continue
+		}
+		for _, event := range history {
+			if !query.Matches(event) {
+				t.Errorf("prefix %s: %s was found in the database but fails Matches", tt.prefix, event.CallerNum)
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+// services/zip_codes.go
+// ZIP code database for callers who enter their location instead of relying on caller ID
+
+package services
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// embeddedZIPCodes is a sample of major-city ZIP codes shipped with the binary
+//
+//go:embed data/zip_codes.csv
+var embeddedZIPCodes []byte
+
+// GlobalZIPCodes is the ZIP code database used by the weather IVR
+var GlobalZIPCodes = NewZIPCodeDatabase()
+
+// ZIPCodeDatabase maps 5-digit US ZIP codes to a location.
+// It is safe for concurrent use and can be reloaded at runtime.
+type ZIPCodeDatabase struct {
+	mu       sync.RWMutex
+	zips     map[string]Location
+	source   string
+	loadedAt time.Time
+}
+
+// NewZIPCodeDatabase creates a database with the embedded sample ZIP codes
+func NewZIPCodeDatabase() *ZIPCodeDatabase {
+	db := &ZIPCodeDatabase{}
+	if err := db.Load(""); err != nil {
+		// The embedded file is compiled into the binary, so this is a programming error
+		panic(err)
+	}
+	return db
+}
+
+// Load replaces the ZIP codes with the CSV file at path (zip,city,state,lat,lon,timezone;
+// "" for the embedded sample). Blank city, state or timezone columns are filled in from
+// the nearest area code. On error the current ZIP codes are left untouched.
+func (db *ZIPCodeDatabase) Load(path string) error {
+	data := embeddedZIPCodes
+	source := "embedded"
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return fmt.Errorf("failed to read ZIP codes file: %w", err)
+		}
+		source = path
+	}
+
+	areaCodes := GlobalAreaCodes.All()
+	zips := make(map[string]Location)
+	err := readLocationCSV(bytes.NewReader(data), "zip", func(record AreaCodeRecord) error {
+		zip := record.AreaCode
+		if !IsZIPCode(zip) {
+			return fmt.Errorf("invalid ZIP code %q", zip)
+		}
+		if _, exists := zips[zip]; exists {
+			return fmt.Errorf("duplicate ZIP code %s", zip)
+		}
+
+		if record.City == "" || record.State == "" || record.Timezone == "" {
+			nearest, found := nearestLocation(areaCodes, record.Lat, record.Lon)
+			if !found {
+				return fmt.Errorf("ZIP code %s needs a city, state and timezone", zip)
+			}
+			if record.City == "" {
+				record.City = nearest.City
+			}
+			if record.State == "" {
+				record.State = nearest.State
+			}
+			if record.Timezone == "" {
+				record.Timezone = nearest.Timezone
+			}
+		}
+
+		zips[zip] = Location{
+			City:     record.City,
+			State:    strings.ToUpper(record.State),
+			Lat:      record.Lat,
+			Lon:      record.Lon,
+			Timezone: record.Timezone,
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(zips) == 0 {
+		return fmt.Errorf("no ZIP codes in %s", source)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.zips = zips
+	db.source = source
+	db.loadedAt = time.Now()
+	return nil
+}
+
+// Lookup returns the location for a ZIP code
+func (db *ZIPCodeDatabase) Lookup(zip string) (Location, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	location, exists := db.zips[zip]
+	return location, exists
+}
+
+// Info returns where the ZIP codes were loaded from and how many there are
+func (db *ZIPCodeDatabase) Info() LocationDataInfo {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return LocationDataInfo{Source: db.source, Count: len(db.zips), LoadedAt: db.loadedAt}
+}
+
+// IsZIPCode reports whether s is 5 digits
+func IsZIPCode(s string) bool {
+	return len(s) == 5 && !nonDigits.MatchString(s)
+}
+
+// nearestLocation returns the location closest to lat/lon
+func nearestLocation(locations map[string]Location, lat, lon float64) (Location, bool) {
+	var nearest Location
+	best := math.Inf(1)
+	for _, location := range locations {
+		if distance := HaversineMiles(lat, lon, location.Lat, location.Lon); distance < best {
+			nearest, best = location, distance
+		}
+	}
+	return nearest, !math.IsInf(best, 1)
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZIPCodeDatabaseLoad(t *testing.T) {
+	db := NewZIPCodeDatabase()
+	if location, exists := db.Lookup("10001"); !exists || location.City != "New York" {
+		t.Errorf("Lookup(10001) = %+v, %v", location, exists)
+	}
+
+	// Blank city, state and timezone are filled in from the nearest area code
+	path := filepath.Join(t.TempDir(), "zips.csv")
+	content := `zip,city,state,lat,lon,timezone
+98104,,,47.6021,-122.3300,
+02134,Allston,MA,42.3539,-71.1337,America/New_York
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	location, exists := db.Lookup("98104")
+	if !exists || location.City != "Seattle" || location.State != "WA" || location.Timezone != "America/Los_Angeles" {
+		t.Errorf("Lookup(98104) = %+v, %v", location, exists)
+	}
+	if _, exists := db.Lookup("10001"); exists {
+		t.Error("10001 still present after loading a file without it")
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad.csv")
+	os.WriteFile(bad, []byte("zip,city,state,lat,lon,timezone\n9810,Seattle,WA,47.6,-122.3,America/Los_Angeles\n"), 0644)
+	if err := db.Load(bad); err == nil {
+		t.Error("Load accepted a 4-digit ZIP code")
+	}
+	if info := db.Info(); info.Source != path || info.Count != 2 {
+		t.Errorf("Info() after failed load = %+v", info)
+	}
+}